@@ -0,0 +1,10 @@
+// Package api holds the OpenAPI description of the public API. The client packages
+// under clients/ are generated from it (go generate ./clients).
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document, served at GET /api/openapi.json.
+//
+//go:embed openapi.json
+var Spec []byte
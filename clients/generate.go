@@ -0,0 +1,8 @@
+// Package clients holds the API clients generated from api/openapi.json: a Go package
+// (weatherclient) and a TypeScript package (typescript/). Regenerate them after
+// changing the OpenAPI document:
+//
+//	go generate ./clients
+package clients
+
+//go:generate go run ../cmd/sdkgen -spec ../api/openapi.json -go weatherclient/client.gen.go -ts typescript/src/client.gen.ts
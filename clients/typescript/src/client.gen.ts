@@ -0,0 +1,429 @@
+// Code generated by sdkgen from api/openapi.json. DO NOT EDIT.
+
+/** Typed client for the Weather Forecast API, version 1.0.0. */
+
+/** A changelog entry. */
+export interface APIChange {
+  announced_at: string;
+  description?: string;
+  id: number;
+  kind: "added" | "changed" | "deprecated" | "removed";
+  link?: string;
+  method?: string;
+  path?: string;
+  /** When a deprecated route stops working. */
+  sunset_at?: string;
+  title: string;
+}
+
+export interface Changelog {
+  items: APIChange[];
+}
+
+export interface ErrorResponse {
+  error: string;
+}
+
+/** The JSON Schema of one version of an event type's data. */
+export interface EventSchema {
+  schema: Record<string, unknown>;
+  type: string;
+  version: number;
+}
+
+export interface EventSchemas {
+  /** JSON Schema of the envelope wrapping every event. */
+  envelope: Record<string, unknown>;
+  events: EventSchema[];
+}
+
+export interface Message {
+  message: string;
+}
+
+export interface PhoneConfirmation {
+  /** One-time code texted to the phone. */
+  code: string;
+  /** Phone the subscription was made with (E.164). */
+  phone: string;
+}
+
+export interface PrivacyDelivery {
+  city: string;
+  email: string;
+  opened_at?: string;
+  scheduled_for: string;
+  sent_at?: string;
+  status: "sent" | "failed";
+  subscription_id: number;
+}
+
+export interface PrivacyErasure {
+  /** Delivery log entries erased. */
+  deliveries: number;
+  /** History entries erased. */
+  events: number;
+  /** Subscriptions erased. */
+  subscriptions: number;
+}
+
+export interface PrivacyEvent {
+  at: string;
+  detail?: string;
+  kind: string;
+  subscription_id: number;
+}
+
+export interface PrivacyExport {
+  deliveries: PrivacyDelivery[];
+  events: PrivacyEvent[];
+  subscriptions: PrivacySubscription[];
+}
+
+export interface PrivacySubscription {
+  cities: string[];
+  confirmed: boolean;
+  confirmed_at?: string;
+  created_at: string;
+  /** Set once unsubscribed. */
+  deleted_at?: string;
+  email: string;
+  first_name?: string;
+  frequency: "hourly" | "daily";
+  id: number;
+  last_sent_at?: string;
+  locale: string;
+  phone?: string;
+  timezone: string;
+  units: "metric" | "imperial";
+}
+
+/** A key signing webhook and event payloads; the secret is shared out of band. */
+export interface SigningKey {
+  expires_at?: string;
+  /** First 16 hex digits of the SHA-256 of the secret. */
+  fingerprint: string;
+  id: string;
+  status: "active" | "expired";
+}
+
+export interface SigningKeys {
+  algorithm: string;
+  format: string;
+  header: string;
+  keys: SigningKey[];
+}
+
+/** A new subscription. Either city or cities is required. */
+export interface SubscribeRequest {
+  /** CAPTCHA token, when the server requires one. */
+  captcha_token?: string;
+  cities?: string[];
+  /** A single city name, commas included; use cities for several. */
+  city?: string;
+  email: string;
+  /** Optional; used to greet the subscriber in emails. */
+  first_name?: string;
+  /** alert only emails when one of the alert conditions is met; warnings emails every new government severe weather warning for the cities. */
+  frequency: "hourly" | "daily" | "alert" | "warnings";
+  /** Language of the emails, e.g. "uk"; taken from Accept-Language when empty or unsupported, English by default. */
+  locale?: string;
+  /** Phone (E.164) the updates are texted to instead of emailed. Daily subscriptions only; confirmed with a code texted to it (POST /confirm-phone) instead of a link emailed. */
+  phone?: string;
+  /** Alert when it rains or snows, or precipitation is likely tomorrow. */
+  rain_expected?: boolean;
+  /** Hour of daily updates, local to timezone. */
+  send_hour?: number;
+  /** Slack incoming webhook (https://hooks.slack.com/services/...) the updates are posted to instead of emailed. Hourly and daily subscriptions only. */
+  slack_webhook_url?: string;
+  /** Alert when the temperature (°C) rises above it, now or tomorrow. */
+  temp_above?: number;
+  /** Alert when the temperature (°C) drops below it, now or tomorrow. */
+  temp_below?: number;
+  /** IANA timezone name; derived from the first city when empty. */
+  timezone?: string;
+  /** Alert when the wind (km/h) is stronger, now or tomorrow. */
+  wind_above?: number;
+}
+
+/** A subscription as seen through its manage token. */
+export interface Subscription {
+  /** Whether the subscriber opted in to experimental email content. */
+  beta_features: boolean;
+  /** Where weather updates are delivered. */
+  channel?: "email" | "slack" | "sms";
+  cities: string[];
+  /** The first of cities. */
+  city: string;
+  confirmed: boolean;
+  email: string;
+  /** When updates stop unless renewed; absent when the subscription does not expire. */
+  expires_at?: string;
+  frequency: "hourly" | "daily" | "alert" | "warnings";
+  /** Language of the emails. */
+  locale: string;
+  /** Whether updates are skipped while the weather does not change. */
+  notify_on_change: boolean;
+  /** "HH:MM" in timezone; hourly updates use the minute only. */
+  send_time: string;
+  /** Whether updates are posted to a Slack incoming webhook instead of emailed. */
+  slack_delivery?: boolean;
+  /** Whether updates are texted to a phone instead of emailed. */
+  sms_delivery?: boolean;
+  timezone: string;
+  units: "metric" | "imperial";
+}
+
+/** A set of changes to a subscription; omitted fields stay unchanged. */
+export interface SubscriptionUpdate {
+  /** Opt in to experimental email content before it is rolled out to everyone. */
+  beta_features?: boolean;
+  /** Replaces city when present. */
+  cities?: string[];
+  /** A single city name, commas included; use cities for several. */
+  city?: string;
+  frequency?: "hourly" | "daily";
+  /** Language of the emails, e.g. "uk"; 400 when unsupported. */
+  locale?: string;
+  /** Only send an update when the weather changed noticeably since the last one. */
+  notify_on_change?: boolean;
+  /** "HH:MM", local to timezone. */
+  send_time?: string;
+  /** IANA timezone name, e.g. "Europe/Kyiv". */
+  timezone?: string;
+  units?: "metric" | "imperial";
+}
+
+/** The current weather in a city. */
+export interface Weather {
+  description: string;
+  /** Relative humidity in percent. */
+  humidity: number;
+  meta?: WeatherMeta;
+  /** Temperature in degrees Celsius. */
+  temperature: number;
+}
+
+/** Where an observation came from; omitted when the provider is unknown. */
+export interface WeatherMeta {
+  /** Attribution line the provider's terms require to be shown with the data. */
+  attribution?: string;
+  /** Provider that supplied the observation, e.g. weatherapi.com. */
+  provider: string;
+}
+
+/** Query parameters of confirmErasure. */
+export interface ConfirmErasureParams {
+  /** Email address the token was sent to. */
+  email: string;
+}
+
+/** Query parameters of confirmSubscription. */
+export interface ConfirmSubscriptionParams {
+  /** Link signature from the email; required when the server requires signed links. */
+  sig?: string;
+}
+
+/** Query parameters of disownSignup. */
+export interface DisownSignupParams {
+  /** Link signature from the email; required when the server requires signed links. */
+  sig?: string;
+}
+
+/** Query parameters of erasePersonalData. */
+export interface ErasePersonalDataParams {
+  /** Token from the privacy email. */
+  token?: string;
+}
+
+/** Query parameters of getWeather. */
+export interface GetWeatherParams {
+  /** City name. */
+  city: string;
+}
+
+/** Query parameters of listChanges. */
+export interface ListChangesParams {
+  /** Only changes announced after this time. */
+  since?: string;
+}
+
+/** Query parameters of resendConfirmation. */
+export interface ResendConfirmationParams {
+  /** Link signature from the email; required when the server requires signed links. */
+  sig?: string;
+}
+
+/** Query parameters of unsubscribe. */
+export interface UnsubscribeParams {
+  /** Link signature from the email; required when the server requires signed links. */
+  sig?: string;
+}
+
+/** Query parameters of unsubscribeOneClick. */
+export interface UnsubscribeOneClickParams {
+  /** Link signature from the email; required when the server requires signed links. */
+  sig?: string;
+}
+
+/** Thrown for responses with a non-2xx status. */
+export class APIError extends Error {
+  constructor(
+    readonly status: number,
+    message: string,
+  ) {
+    super(message);
+    this.name = "APIError";
+  }
+}
+
+export interface ClientOptions {
+  /** Server URL, e.g. "https://weather.example.com"; empty for the same origin. */
+  baseUrl?: string;
+  /** fetch implementation; defaults to the global fetch. */
+  fetch?: typeof fetch;
+}
+
+/** Base path of the API on the server. */
+const basePath = "/api";
+
+export class Client {
+  private readonly baseUrl: string;
+  private readonly fetchImpl: typeof fetch;
+
+  constructor(options: ClientOptions = {}) {
+    this.baseUrl = options.baseUrl ?? "";
+    this.fetchImpl = options.fetch ?? ((input, init) => fetch(input, init));
+  }
+
+  /** GDPR erasure from the link of the privacy email: erases every subscription of the address, their history and delivery log. GET shows a page whose button posts here. POST /privacy/erase/{token} */
+  confirmErasure(token: string, params: ConfirmErasureParams, init?: RequestInit): Promise<PrivacyErasure> {
+    return this.request<PrivacyErasure>("POST", `/privacy/erase/${encodeURIComponent(token)}`, { email: params?.email }, undefined, init);
+  }
+
+  /** Confirms an SMS subscription with the code texted to its phone. POST /confirm-phone */
+  confirmPhone(body: PhoneConfirmation, init?: RequestInit): Promise<Message> {
+    return this.request<Message>("POST", `/confirm-phone`, undefined, body, init);
+  }
+
+  /** Confirms a subscription with the token from the confirmation email. GET /confirm/{token} */
+  confirmSubscription(token: string, params?: ConfirmSubscriptionParams, init?: RequestInit): Promise<Message> {
+    return this.request<Message>("GET", `/confirm/${encodeURIComponent(token)}`, { sig: params?.sig }, undefined, init);
+  }
+
+  /** Deletes a pending subscription at the request of the address owner ("this wasn't me") and stops emailing the address for a while. POST /confirm/{token}/not-me */
+  disownSignup(token: string, params?: DisownSignupParams, init?: RequestInit): Promise<Message> {
+    return this.request<Message>("POST", `/confirm/${encodeURIComponent(token)}/not-me`, { sig: params?.sig }, undefined, init);
+  }
+
+  /** GDPR erasure. Without a token, emails the address links exporting or erasing its data; with the emailed token, erases every subscription of the address, their history and delivery log. DELETE /privacy/{email} */
+  erasePersonalData(email: string, params?: ErasePersonalDataParams, init?: RequestInit): Promise<PrivacyErasure> {
+    return this.request<PrivacyErasure>("DELETE", `/privacy/${encodeURIComponent(email)}`, { token: params?.token }, undefined, init);
+  }
+
+  /** GDPR export: the subscriptions of the address the token was emailed to, their history and delivery log. GET /privacy/export/{token} */
+  exportPersonalData(token: string, init?: RequestInit): Promise<PrivacyExport> {
+    return this.request<PrivacyExport>("GET", `/privacy/export/${encodeURIComponent(token)}`, undefined, undefined, init);
+  }
+
+  /** Returns a subscription by its manage token. GET /manage/{token} */
+  getSubscription(token: string, init?: RequestInit): Promise<Subscription> {
+    return this.request<Subscription>("GET", `/manage/${encodeURIComponent(token)}`, undefined, undefined, init);
+  }
+
+  /** Returns the current weather for a city. GET /weather */
+  getWeather(params: GetWeatherParams, init?: RequestInit): Promise<Weather> {
+    return this.request<Weather>("GET", `/weather`, { city: params?.city }, undefined, init);
+  }
+
+  /** Lists API changes and deprecations, newest first. GET /changelog */
+  listChanges(params?: ListChangesParams, init?: RequestInit): Promise<Changelog> {
+    return this.request<Changelog>("GET", `/changelog`, { since: params?.since }, undefined, init);
+  }
+
+  /** Lists the JSON Schemas of the published domain events. GET /events/schemas */
+  listEventSchemas(init?: RequestInit): Promise<EventSchemas> {
+    return this.request<EventSchemas>("GET", `/events/schemas`, undefined, undefined, init);
+  }
+
+  /** Describes the keys that sign webhook and event payloads. GET /signing-keys */
+  listSigningKeys(init?: RequestInit): Promise<SigningKeys> {
+    return this.request<SigningKeys>("GET", `/signing-keys`, undefined, undefined, init);
+  }
+
+  /** Emails a new confirmation link in place of an expired one. POST /confirm/{token}/resend */
+  resendConfirmation(token: string, params?: ResendConfirmationParams, init?: RequestInit): Promise<Message> {
+    return this.request<Message>("POST", `/confirm/${encodeURIComponent(token)}/resend`, { sig: params?.sig }, undefined, init);
+  }
+
+  /** Subscribes an email to weather updates and sends a confirmation email. POST /subscribe */
+  subscribe(body: SubscribeRequest, init?: RequestInit): Promise<Message> {
+    return this.request<Message>("POST", `/subscribe`, undefined, body, init);
+  }
+
+  /** Cancels a subscription with the token from an update email. GET /unsubscribe/{token} */
+  unsubscribe(token: string, params?: UnsubscribeParams, init?: RequestInit): Promise<Message> {
+    return this.request<Message>("GET", `/unsubscribe/${encodeURIComponent(token)}`, { sig: params?.sig }, undefined, init);
+  }
+
+  /** RFC 8058 one-click unsubscribe, sent by mailbox providers to the List-Unsubscribe URL of an email. POST /unsubscribe/{token} */
+  unsubscribeOneClick(token: string, params?: UnsubscribeOneClickParams, init?: RequestInit): Promise<Message> {
+    return this.request<Message>("POST", `/unsubscribe/${encodeURIComponent(token)}`, { sig: params?.sig }, undefined, init);
+  }
+
+  /** Changes a subscription by its manage token; omitted fields stay unchanged. PATCH /subscriptions/{token} */
+  updateSubscription(token: string, body: SubscriptionUpdate, init?: RequestInit): Promise<Subscription> {
+    return this.request<Subscription>("PATCH", `/subscriptions/${encodeURIComponent(token)}`, undefined, body, init);
+  }
+
+  private async request<T>(
+    method: string,
+    path: string,
+    query: Record<string, string | number | boolean | undefined> | undefined,
+    body: unknown,
+    init: RequestInit | undefined,
+  ): Promise<T> {
+    let url = this.baseUrl + basePath + path;
+    if (query) {
+      const search = new URLSearchParams();
+      for (const [key, value] of Object.entries(query)) {
+        if (value !== undefined) {
+          search.set(key, String(value));
+        }
+      }
+      const qs = search.toString();
+      if (qs) {
+        url += "?" + qs;
+      }
+    }
+
+    const headers = new Headers(init?.headers);
+    headers.set("Accept", "application/json");
+    if (body !== undefined) {
+      headers.set("Content-Type", "application/json");
+    }
+    const resp = await this.fetchImpl(url, {
+      ...init,
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+
+    if (!resp.ok) {
+      let message = resp.statusText;
+      try {
+        const data = await resp.json();
+        if (data && typeof data.error === "string") {
+          message = data.error;
+        }
+      } catch {
+        // not a JSON error body
+      }
+      throw new APIError(resp.status, message);
+    }
+    if (resp.status === 204) {
+      return undefined as T;
+    }
+    return (await resp.json()) as T;
+  }
+}
@@ -0,0 +1,572 @@
+// Code generated by sdkgen from api/openapi.json. DO NOT EDIT.
+
+// Package weatherclient is a typed client for the Weather Forecast API, version 1.0.0.
+package weatherclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// APIChange is a changelog entry.
+type APIChange struct {
+	AnnouncedAt time.Time `json:"announced_at"`
+	Description *string   `json:"description,omitempty"`
+	ID          int       `json:"id"`
+	// One of: added, changed, deprecated, removed.
+	Kind   string  `json:"kind"`
+	Link   *string `json:"link,omitempty"`
+	Method *string `json:"method,omitempty"`
+	Path   *string `json:"path,omitempty"`
+	// When a deprecated route stops working.
+	SunsetAt *time.Time `json:"sunset_at,omitempty"`
+	Title    string     `json:"title"`
+}
+
+type Changelog struct {
+	Items []APIChange `json:"items"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// EventSchema is the JSON Schema of one version of an event type's data.
+type EventSchema struct {
+	Schema  map[string]any `json:"schema"`
+	Type    string         `json:"type"`
+	Version int            `json:"version"`
+}
+
+type EventSchemas struct {
+	// JSON Schema of the envelope wrapping every event.
+	Envelope map[string]any `json:"envelope"`
+	Events   []EventSchema  `json:"events"`
+}
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+type PhoneConfirmation struct {
+	// One-time code texted to the phone.
+	Code string `json:"code"`
+	// Phone the subscription was made with (E.164).
+	Phone string `json:"phone"`
+}
+
+type PrivacyDelivery struct {
+	City         string     `json:"city"`
+	Email        string     `json:"email"`
+	OpenedAt     *time.Time `json:"opened_at,omitempty"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	SentAt       *time.Time `json:"sent_at,omitempty"`
+	// One of: sent, failed.
+	Status         string `json:"status"`
+	SubscriptionID int    `json:"subscription_id"`
+}
+
+type PrivacyErasure struct {
+	// Delivery log entries erased.
+	Deliveries int `json:"deliveries"`
+	// History entries erased.
+	Events int `json:"events"`
+	// Subscriptions erased.
+	Subscriptions int `json:"subscriptions"`
+}
+
+type PrivacyEvent struct {
+	At             time.Time `json:"at"`
+	Detail         *string   `json:"detail,omitempty"`
+	Kind           string    `json:"kind"`
+	SubscriptionID int       `json:"subscription_id"`
+}
+
+type PrivacyExport struct {
+	Deliveries    []PrivacyDelivery     `json:"deliveries"`
+	Events        []PrivacyEvent        `json:"events"`
+	Subscriptions []PrivacySubscription `json:"subscriptions"`
+}
+
+type PrivacySubscription struct {
+	Cities      []string   `json:"cities"`
+	Confirmed   bool       `json:"confirmed"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	// Set once unsubscribed.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Email     string     `json:"email"`
+	FirstName *string    `json:"first_name,omitempty"`
+	// One of: hourly, daily.
+	Frequency  string     `json:"frequency"`
+	ID         int        `json:"id"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	Locale     string     `json:"locale"`
+	Phone      *string    `json:"phone,omitempty"`
+	Timezone   string     `json:"timezone"`
+	// One of: metric, imperial.
+	Units string `json:"units"`
+}
+
+// SigningKey is a key signing webhook and event payloads; the secret is shared out of band.
+type SigningKey struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// First 16 hex digits of the SHA-256 of the secret.
+	Fingerprint string `json:"fingerprint"`
+	ID          string `json:"id"`
+	// One of: active, expired.
+	Status string `json:"status"`
+}
+
+type SigningKeys struct {
+	Algorithm string       `json:"algorithm"`
+	Format    string       `json:"format"`
+	Header    string       `json:"header"`
+	Keys      []SigningKey `json:"keys"`
+}
+
+// SubscribeRequest is a new subscription. Either city or cities is required.
+type SubscribeRequest struct {
+	// CAPTCHA token, when the server requires one.
+	CaptchaToken *string  `json:"captcha_token,omitempty"`
+	Cities       []string `json:"cities,omitempty"`
+	// A single city name, commas included; use cities for several.
+	City  *string `json:"city,omitempty"`
+	Email string  `json:"email"`
+	// Optional; used to greet the subscriber in emails.
+	FirstName *string `json:"first_name,omitempty"`
+	// alert only emails when one of the alert conditions is met; warnings emails every new government severe weather warning for the cities. One of: hourly, daily, alert, warnings.
+	Frequency string `json:"frequency"`
+	// Language of the emails, e.g. "uk"; taken from Accept-Language when empty or unsupported, English by default.
+	Locale *string `json:"locale,omitempty"`
+	// Phone (E.164) the updates are texted to instead of emailed. Daily subscriptions only; confirmed with a code texted to it (POST /confirm-phone) instead of a link emailed.
+	Phone *string `json:"phone,omitempty"`
+	// Alert when it rains or snows, or precipitation is likely tomorrow.
+	RainExpected *bool `json:"rain_expected,omitempty"`
+	// Hour of daily updates, local to timezone.
+	SendHour *int `json:"send_hour,omitempty"`
+	// Slack incoming webhook (https://hooks.slack.com/services/...) the updates are posted to instead of emailed. Hourly and daily subscriptions only.
+	SlackWebhookURL *string `json:"slack_webhook_url,omitempty"`
+	// Alert when the temperature (°C) rises above it, now or tomorrow.
+	TempAbove *float64 `json:"temp_above,omitempty"`
+	// Alert when the temperature (°C) drops below it, now or tomorrow.
+	TempBelow *float64 `json:"temp_below,omitempty"`
+	// IANA timezone name; derived from the first city when empty.
+	Timezone *string `json:"timezone,omitempty"`
+	// Alert when the wind (km/h) is stronger, now or tomorrow.
+	WindAbove *float64 `json:"wind_above,omitempty"`
+}
+
+// Subscription is a subscription as seen through its manage token.
+type Subscription struct {
+	// Whether the subscriber opted in to experimental email content.
+	BetaFeatures bool `json:"beta_features"`
+	// Where weather updates are delivered. One of: email, slack, sms.
+	Channel *string  `json:"channel,omitempty"`
+	Cities  []string `json:"cities"`
+	// The first of cities.
+	City      string `json:"city"`
+	Confirmed bool   `json:"confirmed"`
+	Email     string `json:"email"`
+	// When updates stop unless renewed; absent when the subscription does not expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// One of: hourly, daily, alert, warnings.
+	Frequency string `json:"frequency"`
+	// Language of the emails.
+	Locale string `json:"locale"`
+	// Whether updates are skipped while the weather does not change.
+	NotifyOnChange bool `json:"notify_on_change"`
+	// "HH:MM" in timezone; hourly updates use the minute only.
+	SendTime string `json:"send_time"`
+	// Whether updates are posted to a Slack incoming webhook instead of emailed.
+	SlackDelivery *bool `json:"slack_delivery,omitempty"`
+	// Whether updates are texted to a phone instead of emailed.
+	SmsDelivery *bool  `json:"sms_delivery,omitempty"`
+	Timezone    string `json:"timezone"`
+	// One of: metric, imperial.
+	Units string `json:"units"`
+}
+
+// SubscriptionUpdate is a set of changes to a subscription; omitted fields stay unchanged.
+type SubscriptionUpdate struct {
+	// Opt in to experimental email content before it is rolled out to everyone.
+	BetaFeatures *bool `json:"beta_features,omitempty"`
+	// Replaces city when present.
+	Cities []string `json:"cities,omitempty"`
+	// A single city name, commas included; use cities for several.
+	City *string `json:"city,omitempty"`
+	// One of: hourly, daily.
+	Frequency *string `json:"frequency,omitempty"`
+	// Language of the emails, e.g. "uk"; 400 when unsupported.
+	Locale *string `json:"locale,omitempty"`
+	// Only send an update when the weather changed noticeably since the last one.
+	NotifyOnChange *bool `json:"notify_on_change,omitempty"`
+	// "HH:MM", local to timezone.
+	SendTime *string `json:"send_time,omitempty"`
+	// IANA timezone name, e.g. "Europe/Kyiv".
+	Timezone *string `json:"timezone,omitempty"`
+	// One of: metric, imperial.
+	Units *string `json:"units,omitempty"`
+}
+
+// Weather is the current weather in a city.
+type Weather struct {
+	Description string `json:"description"`
+	// Relative humidity in percent.
+	Humidity int          `json:"humidity"`
+	Meta     *WeatherMeta `json:"meta,omitempty"`
+	// Temperature in degrees Celsius.
+	Temperature float64 `json:"temperature"`
+}
+
+// WeatherMeta is where an observation came from; omitted when the provider is unknown.
+type WeatherMeta struct {
+	// Attribution line the provider's terms require to be shown with the data.
+	Attribution *string `json:"attribution,omitempty"`
+	// Provider that supplied the observation, e.g. weatherapi.com.
+	Provider string `json:"provider"`
+}
+
+// ConfirmErasureParams holds the query parameters of ConfirmErasure.
+type ConfirmErasureParams struct {
+	// Email address the token was sent to.
+	Email string
+}
+
+// ConfirmSubscriptionParams holds the query parameters of ConfirmSubscription.
+type ConfirmSubscriptionParams struct {
+	// Link signature from the email; required when the server requires signed links.
+	Sig *string
+}
+
+// DisownSignupParams holds the query parameters of DisownSignup.
+type DisownSignupParams struct {
+	// Link signature from the email; required when the server requires signed links.
+	Sig *string
+}
+
+// ErasePersonalDataParams holds the query parameters of ErasePersonalData.
+type ErasePersonalDataParams struct {
+	// Token from the privacy email.
+	Token *string
+}
+
+// GetWeatherParams holds the query parameters of GetWeather.
+type GetWeatherParams struct {
+	// City name.
+	City string
+}
+
+// ListChangesParams holds the query parameters of ListChanges.
+type ListChangesParams struct {
+	// Only changes announced after this time.
+	Since *time.Time
+}
+
+// ResendConfirmationParams holds the query parameters of ResendConfirmation.
+type ResendConfirmationParams struct {
+	// Link signature from the email; required when the server requires signed links.
+	Sig *string
+}
+
+// UnsubscribeParams holds the query parameters of Unsubscribe.
+type UnsubscribeParams struct {
+	// Link signature from the email; required when the server requires signed links.
+	Sig *string
+}
+
+// UnsubscribeOneClickParams holds the query parameters of UnsubscribeOneClick.
+type UnsubscribeOneClickParams struct {
+	// Link signature from the email; required when the server requires signed links.
+	Sig *string
+}
+
+// basePath is the path of the API on the server.
+const basePath = "/api"
+
+// Client calls the API. The zero value is not usable; create one with NewClient.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the server at baseURL, e.g. "https://weather.example.com".
+// A nil httpClient means http.DefaultClient.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: baseURL, httpClient: httpClient}
+}
+
+// APIError is returned for responses with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Message    string // the "error" field of the response, when present
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("weather api: %s", http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("weather api: %s: %s", http.StatusText(e.StatusCode), e.Message)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := c.baseURL + basePath + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var e struct {
+			Error string `json:"error"`
+		}
+		if data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10)); err == nil && json.Unmarshal(data, &e) == nil {
+			apiErr.Message = e.Error
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ConfirmErasure GDPR erasure from the link of the privacy email: erases every subscription of the address, their history and delivery log. GET shows a page whose button posts here.
+//
+// POST /privacy/erase/{token}
+func (c *Client) ConfirmErasure(ctx context.Context, token string, params ConfirmErasureParams) (*PrivacyErasure, error) {
+	query := url.Values{}
+	query.Set("email", params.Email)
+	var out PrivacyErasure
+	if err := c.do(ctx, "POST", "/privacy/erase/"+url.PathEscape(token), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ConfirmPhone confirms an SMS subscription with the code texted to its phone.
+//
+// POST /confirm-phone
+func (c *Client) ConfirmPhone(ctx context.Context, body PhoneConfirmation) (*Message, error) {
+	var out Message
+	if err := c.do(ctx, "POST", "/confirm-phone", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ConfirmSubscription confirms a subscription with the token from the confirmation email.
+//
+// GET /confirm/{token}
+func (c *Client) ConfirmSubscription(ctx context.Context, token string, params ConfirmSubscriptionParams) (*Message, error) {
+	query := url.Values{}
+	if params.Sig != nil {
+		query.Set("sig", *params.Sig)
+	}
+	var out Message
+	if err := c.do(ctx, "GET", "/confirm/"+url.PathEscape(token), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DisownSignup deletes a pending subscription at the request of the address owner ("this wasn't me") and stops emailing the address for a while.
+//
+// POST /confirm/{token}/not-me
+func (c *Client) DisownSignup(ctx context.Context, token string, params DisownSignupParams) (*Message, error) {
+	query := url.Values{}
+	if params.Sig != nil {
+		query.Set("sig", *params.Sig)
+	}
+	var out Message
+	if err := c.do(ctx, "POST", "/confirm/"+url.PathEscape(token)+"/not-me", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ErasePersonalData GDPR erasure. Without a token, emails the address links exporting or erasing its data; with the emailed token, erases every subscription of the address, their history and delivery log.
+//
+// DELETE /privacy/{email}
+func (c *Client) ErasePersonalData(ctx context.Context, email string, params ErasePersonalDataParams) (*PrivacyErasure, error) {
+	query := url.Values{}
+	if params.Token != nil {
+		query.Set("token", *params.Token)
+	}
+	var out PrivacyErasure
+	if err := c.do(ctx, "DELETE", "/privacy/"+url.PathEscape(email), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportPersonalData GDPR export: the subscriptions of the address the token was emailed to, their history and delivery log.
+//
+// GET /privacy/export/{token}
+func (c *Client) ExportPersonalData(ctx context.Context, token string) (*PrivacyExport, error) {
+	var out PrivacyExport
+	if err := c.do(ctx, "GET", "/privacy/export/"+url.PathEscape(token), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSubscription returns a subscription by its manage token.
+//
+// GET /manage/{token}
+func (c *Client) GetSubscription(ctx context.Context, token string) (*Subscription, error) {
+	var out Subscription
+	if err := c.do(ctx, "GET", "/manage/"+url.PathEscape(token), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWeather returns the current weather for a city.
+//
+// GET /weather
+func (c *Client) GetWeather(ctx context.Context, params GetWeatherParams) (*Weather, error) {
+	query := url.Values{}
+	query.Set("city", params.City)
+	var out Weather
+	if err := c.do(ctx, "GET", "/weather", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListChanges lists API changes and deprecations, newest first.
+//
+// GET /changelog
+func (c *Client) ListChanges(ctx context.Context, params ListChangesParams) (*Changelog, error) {
+	query := url.Values{}
+	if params.Since != nil {
+		query.Set("since", params.Since.Format(time.RFC3339))
+	}
+	var out Changelog
+	if err := c.do(ctx, "GET", "/changelog", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListEventSchemas lists the JSON Schemas of the published domain events.
+//
+// GET /events/schemas
+func (c *Client) ListEventSchemas(ctx context.Context) (*EventSchemas, error) {
+	var out EventSchemas
+	if err := c.do(ctx, "GET", "/events/schemas", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSigningKeys describes the keys that sign webhook and event payloads.
+//
+// GET /signing-keys
+func (c *Client) ListSigningKeys(ctx context.Context) (*SigningKeys, error) {
+	var out SigningKeys
+	if err := c.do(ctx, "GET", "/signing-keys", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResendConfirmation emails a new confirmation link in place of an expired one.
+//
+// POST /confirm/{token}/resend
+func (c *Client) ResendConfirmation(ctx context.Context, token string, params ResendConfirmationParams) (*Message, error) {
+	query := url.Values{}
+	if params.Sig != nil {
+		query.Set("sig", *params.Sig)
+	}
+	var out Message
+	if err := c.do(ctx, "POST", "/confirm/"+url.PathEscape(token)+"/resend", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Subscribe subscribes an email to weather updates and sends a confirmation email.
+//
+// POST /subscribe
+func (c *Client) Subscribe(ctx context.Context, body SubscribeRequest) (*Message, error) {
+	var out Message
+	if err := c.do(ctx, "POST", "/subscribe", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Unsubscribe cancels a subscription with the token from an update email.
+//
+// GET /unsubscribe/{token}
+func (c *Client) Unsubscribe(ctx context.Context, token string, params UnsubscribeParams) (*Message, error) {
+	query := url.Values{}
+	if params.Sig != nil {
+		query.Set("sig", *params.Sig)
+	}
+	var out Message
+	if err := c.do(ctx, "GET", "/unsubscribe/"+url.PathEscape(token), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UnsubscribeOneClick RFC 8058 one-click unsubscribe, sent by mailbox providers to the List-Unsubscribe URL of an email.
+//
+// POST /unsubscribe/{token}
+func (c *Client) UnsubscribeOneClick(ctx context.Context, token string, params UnsubscribeOneClickParams) (*Message, error) {
+	query := url.Values{}
+	if params.Sig != nil {
+		query.Set("sig", *params.Sig)
+	}
+	var out Message
+	if err := c.do(ctx, "POST", "/unsubscribe/"+url.PathEscape(token), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateSubscription changes a subscription by its manage token; omitted fields stay unchanged.
+//
+// PATCH /subscriptions/{token}
+func (c *Client) UpdateSubscription(ctx context.Context, token string, body SubscriptionUpdate) (*Subscription, error) {
+	var out Subscription
+	if err := c.do(ctx, "PATCH", "/subscriptions/"+url.PathEscape(token), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
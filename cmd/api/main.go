@@ -1,21 +1,49 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // the image has no zoneinfo; subscriber timezones are validated against this copy
 
-	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/captcha"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
-	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/handlers"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/events"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/mailqueue"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/middleware"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/overload"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository/migrations"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/signing"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slo"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/sms"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
 )
 
 func main() {
+	// `api migrate up|down N|version|force V` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
 	// 1) Load configuration from environment
 	cfg, err := config.Load()
 	if err != nil {
@@ -29,46 +57,232 @@ func main() {
 	}
 	defer logger.Sync()
 
-	// 3) Connect to Postgres
-	db, err := repository.OpenDB(cfg.DatabaseURL)
+	// SIGTERM (rolling deploys) and SIGINT stop background loops and drain the server
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 3) Connect to Postgres, waiting for it while it starts
+	repository.SetSlowQueryThreshold(cfg.DBSlowQueryThreshold)
+	dbPool := repository.Pool{MaxConns: cfg.DBPoolMaxConns, MinConns: cfg.DBPoolMinConns,
+		MaxConnLifetime: cfg.DBPoolMaxConnLifetime}
+	dbRetry := repository.Retry{For: cfg.DBStartupRetryTimeout, Backoff: cfg.DBStartupRetryBackoff}
+	db, err := repository.OpenDB(ctx, cfg.DatabaseURL, dbPool, dbRetry, logger)
 	if err != nil {
 		logger.Fatal("failed to connect to database", zap.Error(err))
 	}
+	// replicas starting together take turns; the later ones find nothing to apply
+	if cfg.MigrateOnStart {
+		migrator, err := migrations.New(cfg.DatabaseURL, logger)
+		if err != nil {
+			logger.Fatal("failed to load migrations", zap.Error(err))
+		}
+		_, err = migrator.Up(ctx)
+		migrator.Close()
+		if err != nil {
+			logger.Fatal("failed to migrate database", zap.Error(err))
+		}
+	}
+	// the admin listings go to the read replica, when there is one
+	replica, err := repository.OpenReplica(ctx, cfg.ReplicaDatabaseURL, dbPool, dbRetry, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to the read replica", zap.Error(err))
+	}
+
+	// 3a) Encryption of subscriber emails at rest (PII_ENCRYPTION_KEYS)
+	piiCipher, err := pii.NewCipher(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize email encryption", zap.Error(err))
+	}
+	linkTokens, err := linktoken.NewDeriver(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize link tokens", zap.Error(err))
+	}
+	if !piiCipher.Enabled() {
+		logger.Warn("PII_ENCRYPTION_KEYS is not set, subscriber emails are stored in plaintext")
+	}
 
-	// 4) Initialize SMTP email sender
-	smtpSender, err := email.NewSMTPSender(cfg, logger)
+	// 3b) Signing of confirm and unsubscribe links (LINK_SIGNING_KEY)
+	linkSigner, err := linksign.NewSigner(cfg)
 	if err != nil {
-		logger.Fatal("failed to initialize SMTP sender", zap.Error(err))
+		logger.Fatal("failed to initialize link signing", zap.Error(err))
+	}
+
+	// 3c) Feature flags of experimental email content, offered on the manage page
+	featureFlags, err := features.New(cfg)
+	if err != nil {
+		logger.Fatal("invalid FEATURE_FLAGS", zap.Error(err))
+	}
+
+	// 3d) White-label tenants: every request is scoped to its tenant, and the links
+	// emailed to its subscribers start with its host
+	tenants, err := tenant.New(cfg)
+	if err != nil {
+		logger.Fatal("invalid TENANTS", zap.Error(err))
+	}
+	tenant.Use(tenants)
+
+	// 4) Initialize the email sender (SMTP or SendGrid)
+	emailSender, err := email.NewSender(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize email sender", zap.Error(err))
+	}
+	if err := email.SetStrict(cfg.EmailTemplatesStrict); err != nil {
+		logger.Fatal("email templates failed the strict check", zap.Error(err))
+	}
+	if cfg.EmailProvider == "smtp" && cfg.SMTPCheckAlignment {
+		go email.LogSenderAlignment(cfg.SMTPFrom, cfg.SMTPHost, logger)
 	}
 
-	// 5) Build the weather fetcher (with caching & multiple providers)
-	weatherFetcher, err := weather.BuildCachingFetcher(cfg, logger)
+	// 5) Connect to Redis and build the weather fetcher (with caching & multiple providers)
+	rdb, err := redisclient.Open(cfg)
+	if err != nil {
+		logger.Fatal("failed to connect to redis", zap.Error(err))
+	}
+	// OOM errors and evictions degrade the Redis-backed caches instead of failing them
+	redisMemory := redisclient.MonitorMemory(ctx, rdb, logger)
+	if cfg.DBHealthCheckInterval > 0 {
+		go repository.MonitorPools(ctx, cfg.DBHealthCheckInterval, logger)
+	}
+	weatherFetcher, err := weather.BuildCachingFetcher(cfg, rdb, redisMemory, logger)
 	if err != nil {
 		logger.Fatal("failed to initialize weather fetcher", zap.Error(err))
 	}
+	// with EMAIL_QUEUE_ENABLED, emails are queued for cmd/worker instead of sent in the request
+	if cfg.EmailQueueEnabled {
+		queueRedis, err := redisclient.OpenMailQueue(cfg)
+		if err != nil {
+			logger.Fatal("failed to connect to the mail queue redis", zap.Error(err))
+		}
+		emailSender = mailqueue.New(queueRedis, piiCipher, logger)
+	}
+
+	// 5a) Keys signing outbound webhook/event payloads (published at /api/signing-keys),
+	// and the domain events, validated against their schemas before delivery
+	signingKeys, err := signing.NewKeyring(cfg.EventSigningKeys)
+	if err != nil {
+		logger.Fatal("failed to initialize event signing keys", zap.Error(err))
+	}
+	eventSink := events.NewWebhookSink(cfg.EventWebhookURL, signingKeys)
+	// with EVENT_OUTBOX_ENABLED (the default), events are stored in the outbox and relayed
+	// to the consumer by every replica, so that a consumer outage delays them instead of
+	// losing them; without it they are still POSTed in the background, off the request
+	switch {
+	case eventSink == nil:
+	case cfg.EventOutboxEnabled:
+		outbox := repository.NewOutboxRepository(db, logger)
+		relayID := fmt.Sprintf("%s-api-%d", cfg.SchedulerInstanceID, os.Getpid())
+		go events.NewRelay(outbox, eventSink, relayID, cfg.EventOutboxRetryBackoff, logger).Run(ctx)
+		eventSink = events.NewOutboxSink(outbox)
+	default:
+		async := events.NewAsyncSink(eventSink, 1000, logger)
+		go async.Run(ctx)
+		eventSink = async
+	}
+	eventPublisher := events.NewPublisher(eventSink, logger)
 
 	// 6) Wire up the subscription service
-	subRepo := repository.NewSubscriptionRepository(db, logger)
-	subSvc := services.NewSubscriptionService(subRepo, smtpSender, weatherFetcher, cfg, logger)
+	subRepo := repository.NewReplicatedSubscriptionRepository(db, replica, piiCipher, linkTokens, logger)
+	suppressionRepo := repository.NewSuppressionRepository(db, logger)
+	subscriptionEvents := repository.NewSubscriptionEventRepository(db, piiCipher, logger)
+	timezones := weather.BuildTimezoneResolver(cfg, rdb, logger)
+	subSvc := services.NewSubscriptionService(subRepo, repository.NewAlertRepository(db, piiCipher, linkTokens, logger),
+		repository.NewPhoneRepository(db, piiCipher, logger), suppressionRepo, emailSender, sms.New(cfg), weatherFetcher, timezones,
+		linkSigner, eventPublisher, cfg, logger)
+	privacySvc := services.NewPrivacyService(repository.NewPrivacyRepository(db, piiCipher, linkTokens, logger), suppressionRepo,
+		emailSender, cfg.BaseURL, cfg.PrivacyRequestTTL, logger)
+	engagementSvc := services.NewEngagementService(repository.NewEngagementRepository(db, piiCipher, linkTokens, logger), cfg.SubscriptionTTL, logger)
+
+	// 6a) SLO tracking: /api/weather latency (in-process) and scheduled delivery delay (delivery log)
+	weatherLatency := slo.NewLatencyRecorder(cfg.SLOWeatherLatencyThreshold, 24*time.Hour)
+	sloTracker := slo.NewTracker()
+	sloTracker.Register(slo.Objective{
+		Name:        "weather_latency",
+		Description: fmt.Sprintf("/api/weather requests served under %s", cfg.SLOWeatherLatencyThreshold),
+		Target:      cfg.SLOWeatherLatencyTarget,
+	}, weatherLatency)
+	sloTracker.Register(slo.Objective{
+		Name:        "scheduled_delivery",
+		Description: fmt.Sprintf("scheduled emails delivered within %s of their slot", cfg.SLODeliveryMaxDelay),
+		Target:      cfg.SLODeliveryTarget,
+	}, slo.NewDeliverySource(repository.NewDeliveryRepository(db, piiCipher, linkTokens, logger), cfg.SLODeliveryMaxDelay))
+
+	// 6b) Optional CAPTCHA on subscribe
+	captchaVerifier, err := captcha.NewVerifier(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize captcha verifier", zap.Error(err))
+	}
+
+	// 6c) API changelog; deprecated routes get Deprecation/Sunset headers (reloaded every minute)
+	changelogRepo := repository.NewChangelogRepository(db, logger)
+	changelogSvc := services.NewChangelogService(changelogRepo, logger)
+	deprecations := middleware.NewDeprecations(changelogRepo, time.Minute, logger).WithPathPrefix(cfg.PathPrefix)
+	go deprecations.Run(ctx)
+
+	// 6d) Load shedding: uncached weather lookups and admin reports are turned away
+	// while the process is overloaded
+	overloadDetector := overload.NewDetector(overload.Limits{
+		MaxGoroutines:   cfg.OverloadMaxGoroutines,
+		MaxSchedulerLag: cfg.OverloadMaxSchedulerLag,
+		MaxDBWait:       cfg.OverloadMaxDBWait,
+	}, db, time.Second, logger)
+	go overloadDetector.Run(ctx)
+	shedder := middleware.NewLoadShedder(overloadDetector, cfg.OverloadRetryAfter)
+
+	// 6e) Metrics: served for Prometheus and/or pushed to statsd or an OTLP collector
+	if cfg.MetricsAddr != "" {
+		go metrics.Serve(ctx, cfg.MetricsAddr, logger)
+	}
+	metricsExporter, err := metrics.NewExporter(cfg, "api")
+	if err != nil {
+		logger.Fatal("failed to initialize metrics push", zap.Error(err))
+	}
+	if metricsExporter != nil {
+		go metrics.Push(ctx, metricsExporter, cfg.MetricsPushInterval, logger)
+	}
 
 	// 7) Set up Gin router and handlers
-	router := gin.Default()
-	api := router.Group("/api")
-	{
-		api.GET("/weather", handlers.WeatherHandler(weatherFetcher))
-		api.POST("/subscribe", handlers.SubscribeHandler(subSvc))
-		api.GET("/confirm/:token", handlers.ConfirmHandler(subSvc))
-		api.GET("/unsubscribe/:token", handlers.UnsubscribeHandler(subSvc))
+	router, err := newRouter(cfg, apiServices{
+		db: db, replica: replica, rdb: rdb, tenants: tenants, deprecations: deprecations, shedder: shedder,
+		weatherFetcher: weatherFetcher, weatherLatency: weatherLatency, sloTracker: sloTracker, linkSigner: linkSigner,
+		featureFlags: featureFlags, signingKeys: signingKeys, captcha: captchaVerifier, subRepo: subRepo,
+		suppressionRepo: suppressionRepo, subscriptionEvents: subscriptionEvents, subscriptions: subSvc,
+		privacy: privacySvc, engagement: engagementSvc, changelog: changelogSvc,
+	}, logger)
+	if err != nil {
+		logger.Fatal("failed to set up the router", zap.Error(err))
 	}
 
 	// 8) Start HTTP server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	addr := ":" + cfg.Port
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      router,
+		ReadTimeout:  cfg.HTTPReadTimeout,
+		WriteTimeout: cfg.HTTPWriteTimeout,
+		IdleTimeout:  cfg.HTTPIdleTimeout,
+	}
+	go func() {
+		logger.Info("starting API server", zap.String("address", addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("server error", zap.Error(err))
+		}
+	}()
+
+	// 9) On SIGTERM stop accepting connections, let requests in flight finish, then close
+	// the connections they used
+	<-ctx.Done()
+	stop()
+	logger.Info("shutting down API server", zap.Duration("timeout", cfg.HTTPShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("requests still in flight at shutdown timeout", zap.Error(err))
+	}
+	if err := rdb.Close(); err != nil {
+		logger.Warn("failed to close redis", zap.Error(err))
 	}
-	addr := ":" + port
-	logger.Info("starting API server", zap.String("address", addr))
-	if err := router.Run(addr); err != nil {
-		logger.Fatal("server error", zap.Error(err))
+	if err := db.Close(); err != nil {
+		logger.Warn("failed to close database", zap.Error(err))
 	}
+	logger.Info("API server stopped")
 }
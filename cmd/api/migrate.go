@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository/migrations"
+)
+
+const migrateUsage = "usage: api migrate up | down N | version | force V"
+
+// migrate runs `api migrate up|down N|version|force V` on the database of the config:
+// it applies the pending migrations, reverts the N newest, prints the version, or
+// records version V as applied after a failed migration was fixed by hand.
+func migrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	var n uint64
+	if args[0] == "down" || args[0] == "force" {
+		if len(args) != 2 {
+			log.Fatal(migrateUsage)
+		}
+		var err error
+		if n, err = strconv.ParseUint(args[1], 10, 32); err != nil {
+			log.Fatalf("invalid %s argument %q: %v", args[0], args[1], err)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("configuration error: %v", err)
+	}
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("cannot initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	db, err := repository.OpenDB(context.Background(), cfg.DatabaseURL,
+		repository.Pool{MaxConns: cfg.DBPoolMaxConns, MinConns: cfg.DBPoolMinConns,
+			MaxConnLifetime: cfg.DBPoolMaxConnLifetime},
+		repository.Retry{For: cfg.DBStartupRetryTimeout, Backoff: cfg.DBStartupRetryBackoff}, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to database", zap.Error(err))
+	}
+	db.Close() // Postgres is up: the migrator has a connection of its own
+	migrator, err := migrations.New(cfg.DatabaseURL, logger)
+	if err != nil {
+		logger.Fatal("failed to load migrations", zap.Error(err))
+	}
+	defer migrator.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+		fmt.Printf("applied %d migrations, at version %d\n", applied, migrator.Latest())
+	case "down":
+		reverted, err := migrator.Down(ctx, int(n))
+		if err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+		fmt.Printf("reverted %d migrations\n", reverted)
+	case "version":
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			log.Fatalf("migrate version: %v", err)
+		}
+		if dirty {
+			fmt.Printf("%d (dirty: the migration failed halfway)\n", version)
+		} else {
+			fmt.Printf("%d of %d\n", version, migrator.Latest())
+		}
+	case "force":
+		if err := migrator.Force(ctx, uint(n)); err != nil {
+			log.Fatalf("migrate force: %v", err)
+		}
+		fmt.Printf("forced version %d\n", n)
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
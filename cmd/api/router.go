@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	apispec "github.com/namefreezers/Software-Engineering-School-5.0-weather-api/api"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/captcha"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/handlers"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/middleware"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/signing"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slo"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/synthetic"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+)
+
+// apiServices holds what main wires up for the routes of the API.
+type apiServices struct {
+	db, replica        *sqlx.DB // replica is nil without POSTGRES_REPLICA_HOST
+	rdb                *redis.Client
+	tenants            *tenant.Registry
+	deprecations       *middleware.Deprecations
+	shedder            *middleware.LoadShedder
+	weatherFetcher     weather.Fetcher
+	weatherLatency     *slo.LatencyRecorder
+	sloTracker         *slo.Tracker
+	linkSigner         *linksign.Signer
+	featureFlags       *features.Flags
+	signingKeys        *signing.Keyring
+	captcha            captcha.Verifier
+	subRepo            repository.SubscriptionRepository
+	suppressionRepo    repository.SuppressionRepository
+	subscriptionEvents repository.SubscriptionEventRepository
+	subscriptions      services.SubscriptionService
+	privacy            services.PrivacyService
+	engagement         services.EngagementService
+	changelog          services.ChangelogService
+}
+
+// newRouter sets up the routes of the API on s, every one of them under
+// cfg.PathPrefix; the admin routes only when admin credentials are configured.
+func newRouter(cfg *config.Config, s apiServices, logger *zap.Logger) (*gin.Engine, error) {
+	// Per-IP rate limits, shared across replicas through Redis
+	limiter := middleware.NewRateLimiter(s.rdb, logger)
+	weatherLimit := limiter.Middleware("weather", middleware.RateLimit{
+		PerMinute: cfg.RateLimitWeatherPerMinute, Burst: cfg.RateLimitWeatherBurst,
+	})
+	subscribeLimit := limiter.Middleware("subscribe", middleware.RateLimit{
+		PerMinute: cfg.RateLimitSubscribePerMinute, Burst: cfg.RateLimitSubscribeBurst,
+	})
+
+	// Per-replica limits on requests in flight, shared by the routes of each group
+	weatherConcurrency := middleware.ConcurrencyLimit(cfg.ConcurrencyLimitWeather)
+	subscribeConcurrency := middleware.ConcurrencyLimit(cfg.ConcurrencyLimitSubscribe)
+
+	// Idempotency-Key replays for retried subscribe requests
+	idempotency := middleware.NewIdempotency(s.rdb, cfg.IdempotencyTTL, logger)
+
+	router := gin.Default()
+	// client IPs come from X-Forwarded-For only behind the proxies configured
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	router.Use(middleware.RequestID())
+	router.Use(s.deprecations.Middleware())
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		router.Use(middleware.CORS(middleware.CORSConfig{
+			AllowedOrigins: cfg.CORSAllowedOrigins,
+			AllowedMethods: cfg.CORSAllowedMethods,
+			AllowedHeaders: cfg.CORSAllowedHeaders,
+			MaxAge:         10 * time.Minute,
+		}))
+	}
+	// every route lives under PATH_PREFIX ("" by default)
+	api := router.Group(cfg.PathPrefix+"/api", middleware.Tenant(s.tenants))
+	{
+		api.GET("/weather", middleware.ObserveLatency(s.weatherLatency), weatherConcurrency, weatherLimit, s.shedder.CacheOnly(), handlers.WeatherHandler(s.weatherFetcher, weather.ProviderAttributions(cfg)))
+		api.POST("/subscribe", subscribeConcurrency, subscribeLimit, idempotency.Middleware("subscribe"), handlers.SubscribeHandler(s.subscriptions, s.captcha, logger))
+		api.GET("/confirm/:token", handlers.ConfirmHandler(s.subscriptions, s.linkSigner))
+		api.POST("/confirm/:token/resend", subscribeConcurrency, subscribeLimit, handlers.ResendConfirmationHandler(s.subscriptions, s.linkSigner))
+		api.GET("/confirm/:token/not-me", handlers.NotMeHandler(s.subscriptions, s.linkSigner))
+		api.POST("/confirm-phone", subscribeConcurrency, subscribeLimit, handlers.ConfirmPhoneHandler(s.subscriptions))
+		api.POST("/confirm/:token/not-me", handlers.NotMeHandler(s.subscriptions, s.linkSigner))
+		api.GET("/unsubscribe/:token", handlers.UnsubscribeHandler(s.subscriptions, s.linkSigner))
+		api.POST("/unsubscribe/:token", handlers.UnsubscribeHandler(s.subscriptions, s.linkSigner))
+		api.GET("/manage/:token", handlers.ManageHandler(s.subscriptions, s.linkSigner, s.featureFlags, cfg.PathPrefix))
+		api.POST("/manage/:token", handlers.UpdateManagedHandler(s.subscriptions, s.linkSigner, s.featureFlags, cfg.PathPrefix))
+		// the keep link asks before renewing, like the not-me link
+		api.GET("/manage/:token/keep", handlers.KeepSubscriptionHandler(s.engagement))
+		api.POST("/manage/:token/keep", handlers.KeepSubscriptionHandler(s.engagement))
+		api.GET("/open/:token", handlers.OpenPixelHandler(s.engagement, logger))
+		api.PATCH("/subscriptions/:token", handlers.UpdateSubscriptionHandler(s.subscriptions))
+		// GDPR requests: the challenge email is rate limited like subscribing
+		api.DELETE("/privacy/:email", subscribeConcurrency, subscribeLimit, handlers.PrivacyEraseHandler(s.privacy))
+		api.GET("/privacy/export/:token", handlers.PrivacyExportHandler(s.privacy))
+		// the erase link of the email asks before erasing, like the not-me link
+		api.GET("/privacy/erase/:token", handlers.PrivacyEraseConfirmHandler(s.privacy))
+		api.POST("/privacy/erase/:token", handlers.PrivacyEraseConfirmHandler(s.privacy))
+		api.GET("/changelog", handlers.ChangelogHandler(s.changelog))
+		api.GET("/signing-keys", handlers.SigningKeysHandler(s.signingKeys))
+		api.GET("/events/schemas", handlers.EventSchemasHandler())
+		api.GET("/openapi.json", handlers.OpenAPIHandler(apispec.Spec, cfg.PathPrefix))
+		// bounce and complaint notifications of the email provider
+		if cfg.BounceWebhookToken != "" {
+			bounceSvc := services.NewBounceService(s.suppressionRepo, s.subscriptionEvents, cfg.BounceSoftSuppression, logger)
+			api.POST("/webhooks/bounces/:provider", handlers.BounceWebhookHandler(bounceSvc, cfg.BounceWebhookToken, logger))
+		}
+		// synthetic monitoring: the synthetic subscriber's mailbox reports received emails
+		if cfg.SyntheticEmail != "" {
+			api.POST("/synthetic/observed", handlers.SyntheticObservedHandler(synthetic.NewStore(s.rdb), cfg.SyntheticWebhookToken))
+		}
+	}
+
+	// Admin routes, only when credentials are configured
+	adminAuth := middleware.NewAdminAuth(cfg.AdminUser, cfg.AdminPassword, cfg.AdminJWTSecret, cfg.AdminJWTTTL)
+	if adminAuth.Enabled() {
+		adminSvc := services.NewAdminService(s.subRepo, s.suppressionRepo, repository.NewDeadLetterRepository(s.db, logger),
+			s.subscriptionEvents, weather.NewCityCache(s.rdb, weather.CacheNamespace(cfg)), logger)
+		// the statistics tolerate replication lag
+		statsDB := s.db
+		if s.replica != nil {
+			statsDB = s.replica
+		}
+		admin := api.Group("/admin", adminAuth.Middleware())
+		{
+			admin.POST("/token", adminAuth.IssueTokenHandler())
+			admin.GET("/usage", s.shedder.Reject(), handlers.UsageReportHandler(weather.NewUsageTracker(s.rdb, logger), weather.ProviderPrices(cfg)))
+			admin.GET("/slo", s.shedder.Reject(), handlers.SLOReportHandler(s.sloTracker))
+			admin.GET("/stats", s.shedder.Reject(), handlers.StatsHandler(services.NewStatsService(
+				repository.NewStatsRepository(statsDB, logger), s.rdb, cfg.AdminStatsCacheTTL, logger)))
+			admin.GET("/templates", handlers.TemplatesHandler())
+			admin.GET("/emails/preview", handlers.EmailPreviewHandler(services.NewPreviewService(s.weatherFetcher, s.linkSigner, s.featureFlags, cfg, logger)))
+			if cfg.WeatherRawCacheEnabled {
+				rawStore := weather.NewRawStore(s.rdb, cfg.WeatherRawCacheTTL, cfg.WeatherRawCacheMaxBytes, logger)
+				admin.GET("/weather/raw", handlers.RawWeatherHandler(rawStore))
+			}
+			admin.GET("/subscriptions", handlers.ListSubscriptionsHandler(adminSvc))
+			admin.GET("/subscriptions/export", s.shedder.Reject(), handlers.ExportSubscriptionsHandler(adminSvc, logger))
+			admin.GET("/subscriptions/by-email/:email", handlers.SubscriptionsByEmailHandler(adminSvc))
+			admin.DELETE("/subscriptions/:id", handlers.DeleteSubscriptionHandler(adminSvc))
+			admin.POST("/subscriptions/:id/revive", handlers.ReviveSubscriptionHandler(adminSvc))
+			admin.GET("/subscriptions/:id/events", handlers.SubscriptionHistoryHandler(adminSvc))
+			admin.POST("/cities/merge", handlers.MergeCitiesHandler(adminSvc))
+			admin.POST("/suppressions/import", handlers.ImportSuppressionsHandler(adminSvc))
+			admin.POST("/changelog", handlers.AnnounceChangeHandler(s.changelog))
+			admin.DELETE("/changelog/:id", handlers.DeleteChangeHandler(s.changelog))
+		}
+	}
+	return router, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/middleware"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// managedService manages one confirmed subscription, whatever the token.
+type managedService struct {
+	services.SubscriptionService
+}
+
+func (managedService) GetManaged(context.Context, string) (repository.Subscription, error) {
+	return repository.Subscription{ID: 1, City: "Kyiv", Frequency: repository.FrequencyDaily, Confirmed: true,
+		UnsubscribeToken: uuid.New()}, nil
+}
+
+func TestNewRouter_PathPrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{PathPrefix: "/weather-api", BaseURL: "https://example.com/weather-api"}
+	router, err := newRouter(cfg, apiServices{
+		deprecations:  middleware.NewDeprecations(nil, time.Minute, zap.NewNop()).WithPathPrefix(cfg.PathPrefix),
+		shedder:       middleware.NewLoadShedder(nil, time.Second),
+		subscriptions: managedService{},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("newRouter() error: %v", err)
+	}
+
+	token := uuid.NewString()
+	for _, tc := range []struct {
+		name, path string
+		want       int
+		links      string // in the body
+	}{
+		{"prefixed route", "/weather-api/api/events/schemas", http.StatusOK, ""},
+		{"unprefixed route", "/api/events/schemas", http.StatusNotFound, ""},
+		{"OpenAPI servers", "/weather-api/api/openapi.json", http.StatusOK, `"url":"/weather-api/api"`},
+		{"manage page links", "/weather-api/api/manage/" + token, http.StatusOK, `"/weather-api/api/unsubscribe/`},
+		{"unprefixed manage page", "/api/manage/" + token, http.StatusNotFound, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("Accept", "text/html")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("GET %s: status %d, want %d", tc.path, w.Code, tc.want)
+			}
+			if body := strings.Join(strings.Fields(w.Body.String()), ""); !strings.Contains(body, tc.links) {
+				t.Errorf("GET %s: body lacks %s:\n%s", tc.path, tc.links, w.Body)
+			}
+		})
+	}
+}
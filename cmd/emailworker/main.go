@@ -0,0 +1,124 @@
+// Command emailworker sends the weather updates the scheduler queues in Postgres
+// (send_jobs), so that slow SMTP or a slow weather provider never delays the
+// scheduler's batch selection, and sending scales apart from it. Every process runs
+// SEND_WORKERS workers claiming due jobs with FOR UPDATE SKIP LOCKED; run as many as
+// needed, each with its own WORKER_ID (the host name by default). It reads the same
+// environment as the scheduler and stops on SIGINT/SIGTERM after the batches in hand.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/signal"
+	"sync"
+	"syscall"
+	_ "time/tzdata" // the image has no zoneinfo; updates are rendered in subscriber timezones
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/scheduler"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("configuration error: %v", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("cannot initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	repository.SetSlowQueryThreshold(cfg.DBSlowQueryThreshold)
+	db, err := repository.OpenDB(context.Background(), cfg.DatabaseURL,
+		repository.Pool{MaxConns: cfg.DBPoolMaxConns, MinConns: cfg.DBPoolMinConns,
+			MaxConnLifetime: cfg.DBPoolMaxConnLifetime},
+		repository.Retry{For: cfg.DBStartupRetryTimeout, Backoff: cfg.DBStartupRetryBackoff}, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+	piiCipher, err := pii.NewCipher(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize email encryption", zap.Error(err))
+	}
+	linkTokens, err := linktoken.NewDeriver(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize link tokens", zap.Error(err))
+	}
+
+	// the real sender (SMTP or SendGrid): this process is where updates leave
+	emailSender, err := email.NewSender(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize email sender", zap.Error(err))
+	}
+	if err := email.SetStrict(cfg.EmailTemplatesStrict); err != nil {
+		logger.Fatal("email templates failed the strict check", zap.Error(err))
+	}
+	tenants, err := tenant.New(cfg)
+	if err != nil {
+		logger.Fatal("invalid TENANTS", zap.Error(err))
+	}
+	tenant.Use(tenants)
+
+	rdb, err := redisclient.Open(cfg)
+	if err != nil {
+		logger.Fatal("failed to connect to redis", zap.Error(err))
+	}
+	defer rdb.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	redisMemory := redisclient.MonitorMemory(ctx, rdb, logger)
+	if cfg.DBHealthCheckInterval > 0 {
+		go repository.MonitorPools(ctx, cfg.DBHealthCheckInterval, logger)
+	}
+	weatherFetcher, err := weather.BuildCachingFetcher(cfg, rdb, redisMemory, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize weather fetcher", zap.Error(err))
+	}
+	dispatcher, err := scheduler.BuildDispatcher(cfg, db, rdb, piiCipher, weatherFetcher, emailSender, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize the dispatcher", zap.Error(err))
+	}
+
+	if cfg.MetricsAddr != "" {
+		go metrics.Serve(ctx, cfg.MetricsAddr, logger)
+	}
+	metricsExporter, err := metrics.NewExporter(cfg, "emailworker")
+	if err != nil {
+		logger.Fatal("failed to initialize metrics push", zap.Error(err))
+	}
+	if metricsExporter != nil {
+		go metrics.Push(ctx, metricsExporter, cfg.MetricsPushInterval, logger)
+	}
+
+	logger.Info("starting email worker", zap.String("id", cfg.WorkerID), zap.Int("workers", cfg.SendWorkers))
+	jobRepo := repository.NewJobRepository(db, logger)
+	subRepo := repository.NewSubscriptionRepository(db, piiCipher, linkTokens, logger)
+	var workers sync.WaitGroup
+	for i := range cfg.SendWorkers {
+		worker := scheduler.NewJobWorker(jobRepo, subRepo, dispatcher, fmt.Sprintf("%s-%d", cfg.WorkerID, i),
+			cfg.SendJobLease, cfg.SendJobMaxAttempts, cfg.SendJobRetryBackoff, logger)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.Run(ctx)
+		}()
+	}
+	workers.Wait()
+	logger.Info("email worker stopped")
+}
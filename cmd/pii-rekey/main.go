@@ -0,0 +1,68 @@
+// Command pii-rekey encrypts legacy plaintext subscriber emails and other personal data,
+// re-encrypts values stored under a retired key and fills in missing blind indexes. Run
+// it after enabling PII_ENCRYPTION_KEYS and after every key rotation, before removing
+// the old key.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+func main() {
+	batchSize := flag.Int("batch-size", 500, "rows rewritten per transaction")
+	flag.Parse()
+
+	// 1) Load configuration from environment
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("configuration error: %v", err)
+	}
+
+	// 2) Initialize structured logger
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("cannot initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	// 3) Build the cipher; without keys there is nothing to encrypt with
+	piiCipher, err := pii.NewCipher(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize email encryption", zap.Error(err))
+	}
+	if !piiCipher.Enabled() {
+		logger.Fatal("PII_ENCRYPTION_KEYS is not set")
+	}
+
+	// 4) Connect to Postgres
+	db, err := repository.OpenDB(context.Background(), cfg.DatabaseURL,
+		repository.Pool{MaxConns: cfg.DBPoolMaxConns, MinConns: cfg.DBPoolMinConns,
+			MaxConnLifetime: cfg.DBPoolMaxConnLifetime},
+		repository.Retry{For: cfg.DBStartupRetryTimeout, Backoff: cfg.DBStartupRetryBackoff}, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// 5) Rewrite the stored emails; an interrupted run is resumed by running again
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	stats, err := repository.RekeyEmails(ctx, db, piiCipher, *batchSize, logger)
+	if err != nil {
+		logger.Fatal("re-keying failed", zap.Error(err))
+	}
+	logger.Info("re-keyed subscriber emails",
+		zap.Int("subscriptions", stats.Subscriptions), zap.Int("first_names", stats.FirstNames),
+		zap.Int("slack_webhooks", stats.SlackWebhooks), zap.Int("phones", stats.Phones),
+		zap.Int("deliveries", stats.Deliveries))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/scheduler"
+)
+
+// maxBackfill bounds the range of a backfill: past a day, daily subscribers would be
+// owed several updates and only the latest is worth sending.
+const maxBackfill = 24 * time.Hour
+
+// backfill runs `scheduler backfill --from --to [--dry-run]`: it selects the batches of
+// every minute slot in [from, to) as the tick would have, and queues their updates for
+// the send workers of the running scheduler. Hourly subscribers are queued for the last
+// hour of the range only. Subscriptions already sent an update in the current window
+// (last_sent_at) are skipped, and updates already queued for a slot are not queued
+// again, so a backfill may be run again, or over a range partly sent.
+func backfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	from := fs.String("from", "", "start of the range of slots, RFC 3339 (required)")
+	to := fs.String("to", "", "end of the range of slots, exclusive, RFC 3339 (required)")
+	dryRun := fs.Bool("dry-run", false, "only count the updates that would be queued")
+	_ = fs.Parse(args)
+
+	fromT, err := time.Parse(time.RFC3339, *from)
+	if err != nil {
+		log.Fatalf("invalid --from %q: %v", *from, err)
+	}
+	toT, err := time.Parse(time.RFC3339, *to)
+	if err != nil {
+		log.Fatalf("invalid --to %q: %v", *to, err)
+	}
+	fromT, toT = fromT.Truncate(time.Minute), toT.Truncate(time.Minute)
+	if !fromT.Before(toT) {
+		log.Fatalf("--from must be before --to")
+	}
+	if toT.Sub(fromT) > maxBackfill {
+		log.Fatalf("the range must not exceed %s", maxBackfill)
+	}
+	if toT.After(time.Now()) {
+		log.Fatalf("--to must not be in the future: the scheduler sends those slots")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("configuration error: %v", err)
+	}
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("cannot initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	piiCipher, err := pii.NewCipher(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize email encryption", zap.Error(err))
+	}
+	linkTokens, err := linktoken.NewDeriver(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize link tokens", zap.Error(err))
+	}
+	dbPool := repository.Pool{MaxConns: cfg.DBPoolMaxConns, MinConns: cfg.DBPoolMinConns,
+		MaxConnLifetime: cfg.DBPoolMaxConnLifetime}
+	dbRetry := repository.Retry{For: cfg.DBStartupRetryTimeout, Backoff: cfg.DBStartupRetryBackoff}
+	db, err := repository.OpenDB(context.Background(), cfg.DatabaseURL, dbPool, dbRetry, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+	replica, err := repository.OpenReplica(context.Background(), cfg.ReplicaDatabaseURL, dbPool, dbRetry, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to the read replica", zap.Error(err))
+	}
+
+	var queue scheduler.JobQueue = repository.NewJobRepository(db, logger)
+	if *dryRun {
+		queue = countingQueue{}
+	}
+	subRepo := repository.NewReplicatedSubscriptionRepository(db, replica, piiCipher, linkTokens, logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	reading := scheduler.BatchReading{PageSize: cfg.SchedulerBatchPageSize, Stream: cfg.SchedulerBatchStream}
+	report := scheduler.StartTick(fromT)
+	for slot := fromT; slot.Before(toT) && ctx.Err() == nil; slot = slot.Add(time.Minute) {
+		scheduler.QueueSlot(ctx, subRepo, queue, slot, toT.Sub(slot) <= time.Hour, reading, report, logger)
+	}
+
+	verb := "queued"
+	if *dryRun {
+		verb = "would queue"
+	}
+	fmt.Printf("%s %d updates (%d hourly, %d daily selected) for %s to %s\n", verb, report.Enqueued,
+		report.Hourly, report.Daily, fromT.Format(time.RFC3339), toT.Format(time.RFC3339))
+	if ctx.Err() != nil {
+		log.Fatalf("interrupted")
+	}
+}
+
+// countingQueue is the queue of a dry run: it queues nothing and counts everything.
+type countingQueue struct{ scheduler.JobQueue }
+
+func (countingQueue) Enqueue(_ context.Context, _ time.Time, ids []int) (int64, error) {
+	return int64(len(ids)), nil
+}
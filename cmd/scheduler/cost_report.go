@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+)
+
+// costReportSpec runs the report at 08:00 on the first day of every month.
+const costReportSpec = "0 8 1 * *"
+
+// sendCostReport emails the estimated provider cost for month to the operator.
+func sendCostReport(
+	ctx context.Context,
+	usage *weather.UsageTracker,
+	prices map[string]float64,
+	month time.Time,
+	sender email.EmailSender,
+	operatorEmail string,
+	logger *zap.Logger,
+) {
+	report, err := weather.BuildCostReport(ctx, usage, prices, month)
+	if err != nil {
+		logger.Error("failed to build cost report", zap.Error(err))
+		return
+	}
+
+	var rows strings.Builder
+	for _, p := range report.Providers {
+		fmt.Fprintf(&rows, "  <tr><td>%s</td><td>%d</td><td>%.5f</td><td>%.2f</td></tr>\n",
+			p.Provider, p.Calls, p.PricePerCall, p.Cost)
+	}
+	body := fmt.Sprintf(
+		`<p>Estimated weather provider usage for <b>%s</b>:</p>
+<table border="1" cellpadding="4">
+  <tr><th>Provider</th><th>Calls</th><th>Price per call</th><th>Cost</th></tr>
+%s</table>
+<p>Total estimated cost: <b>%.2f</b></p>`,
+		report.Month, rows.String(), report.TotalCost,
+	)
+
+	msg := email.EmailMessage{
+		To:      []string{operatorEmail},
+		Subject: fmt.Sprintf("Weather provider cost report for %s", report.Month),
+		Body:    body,
+	}
+	if err := sender.SendBatch([]email.EmailMessage{msg}); err != nil {
+		logger.Error("failed to send cost report", zap.Error(err))
+		return
+	}
+	logger.Info("sent cost report", zap.String("month", report.Month), zap.Float64("total", report.TotalCost))
+}
@@ -4,18 +4,40 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+	_ "time/tzdata" // the image has no zoneinfo; daily slots are computed in subscriber timezones
 
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
 
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/mailqueue"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/scheduler"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/synthetic"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tracing"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
 )
 
 func main() {
+	// `scheduler backfill --from --to` queues the updates of a past range and exits
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		backfill(os.Args[2:])
+		return
+	}
+
 	// 1) Load config (includes BASE_URL)
 	cfg, err := config.Load()
 	if err != nil {
@@ -30,117 +52,360 @@ func main() {
 	defer logger.Sync()
 
 	// 3) Open DB
-	db, err := repository.OpenDB(cfg.DatabaseURL)
+	repository.SetSlowQueryThreshold(cfg.DBSlowQueryThreshold)
+	dbPool := repository.Pool{MaxConns: cfg.DBPoolMaxConns, MinConns: cfg.DBPoolMinConns,
+		MaxConnLifetime: cfg.DBPoolMaxConnLifetime}
+	dbRetry := repository.Retry{For: cfg.DBStartupRetryTimeout, Backoff: cfg.DBStartupRetryBackoff}
+	db, err := repository.OpenDB(context.Background(), cfg.DatabaseURL, dbPool, dbRetry, logger)
 	if err != nil {
 		logger.Fatal("failed to connect to database", zap.Error(err))
 	}
+	// the batch queries go to the read replica, when there is one
+	replica, err := repository.OpenReplica(context.Background(), cfg.ReplicaDatabaseURL, dbPool, dbRetry, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to the read replica", zap.Error(err))
+	}
+
+	// 3a) Encryption of subscriber emails at rest (PII_ENCRYPTION_KEYS)
+	piiCipher, err := pii.NewCipher(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize email encryption", zap.Error(err))
+	}
+	linkTokens, err := linktoken.NewDeriver(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize link tokens", zap.Error(err))
+	}
+	if !piiCipher.Enabled() {
+		logger.Warn("PII_ENCRYPTION_KEYS is not set, subscriber emails are stored in plaintext")
+	}
+
+	// 4) Wire up repositories, email sender, weather fetcher
+	subRepo := repository.NewReplicatedSubscriptionRepository(db, replica, piiCipher, linkTokens, logger)
+	suppressionRepo := repository.NewSuppressionRepository(db, logger)
 
-	// 4) Wire up repository, email sender, weather fetcher
-	subRepo := repository.NewSubscriptionRepository(db, logger)
+	emailSender, err := email.NewSender(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize email sender", zap.Error(err))
+	}
+	if err := email.SetStrict(cfg.EmailTemplatesStrict); err != nil {
+		logger.Fatal("email templates failed the strict check", zap.Error(err))
+	}
+	// the links of every email start with the host of the tenant of its subscription
+	tenants, err := tenant.New(cfg)
+	if err != nil {
+		logger.Fatal("invalid TENANTS", zap.Error(err))
+	}
+	tenant.Use(tenants)
+	if cfg.EmailProvider == "smtp" && cfg.SMTPCheckAlignment {
+		go email.LogSenderAlignment(cfg.SMTPFrom, cfg.SMTPHost, logger)
+	}
 
-	smtpSender, err := email.NewSMTPSender(cfg, logger)
+	rdb, err := redisclient.Open(cfg)
 	if err != nil {
-		logger.Fatal("failed to initialize SMTP sender", zap.Error(err))
+		logger.Fatal("failed to connect to redis", zap.Error(err))
 	}
 
-	weatherFetcher, err := weather.BuildCachingFetcher(cfg, logger)
+	// OOM errors and evictions degrade the Redis-backed caches instead of failing them
+	redisMemory := redisclient.MonitorMemory(context.Background(), rdb, logger)
+	if cfg.DBHealthCheckInterval > 0 {
+		go repository.MonitorPools(context.Background(), cfg.DBHealthCheckInterval, logger)
+	}
+	weatherFetcher, err := weather.BuildCachingFetcher(cfg, rdb, redisMemory, logger)
 	if err != nil {
 		logger.Fatal("failed to initialize weather fetcher", zap.Error(err))
 	}
+	// with EMAIL_QUEUE_ENABLED, emails are queued for cmd/worker instead of sent in the
+	// cron loop; deliveries are logged as queued, and as sent by the worker
+	if cfg.EmailQueueEnabled {
+		queueRedis, err := redisclient.OpenMailQueue(cfg)
+		if err != nil {
+			logger.Fatal("failed to connect to the mail queue redis", zap.Error(err))
+		}
+		emailSender = mailqueue.New(queueRedis, piiCipher, logger)
+	}
 
-	// 5) Build cron (standard 5-field, minute resolution)
-	c := cron.New()
-	const spec = "* * * * *" // every minute, at second 0
+	dispatcher, err := scheduler.BuildDispatcher(cfg, db, rdb, piiCipher, weatherFetcher, emailSender, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize the dispatcher", zap.Error(err))
+	}
+	linkSigner, err := linksign.NewSigner(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize link signing", zap.Error(err))
+	}
+	featureFlags, err := features.New(cfg)
+	if err != nil {
+		logger.Fatal("invalid FEATURE_FLAGS", zap.Error(err))
+	}
+	alertRepo := repository.NewAlertRepository(db, piiCipher, linkTokens, logger)
+	dispatcher.WithAlerts(alertRepo)
+	warningRepo := repository.NewWarningRepository(db, piiCipher, linkTokens, logger)
+	warningsFeed := weather.BuildWarningsFetcher(cfg, rdb, logger)
+	if warningsFeed != nil {
+		dispatcher.WithWarnings(warningsFeed, warningRepo)
+	}
+	// weather history for the temperature charts, pruned while the charts are rolled out
+	var snapshotRepo repository.SnapshotRepository
+	if featureFlags.Stage(features.TemperatureCharts) != features.StageOff {
+		snapshotRepo = repository.NewSnapshotRepository(db, logger)
+	}
 
-	_, err = c.AddFunc(spec, func() {
-		// Add 30s to avoid rolling edge cases (e.g. 12:05:59.999)
-		now := time.Now().Add(30 * time.Second)
-		minute := now.Minute()
-		hour := now.Hour()
+	// 4a) Postgres notifications: first email right after confirmation, and
+	// (optionally) the in-memory schedule kept fresh; otherwise plain batch queries
+	listener := repository.NewListener(cfg.DatabaseURL, logger)
+	listener.Handle(repository.ChannelSubscriptionConfirmed, scheduler.NewWelcomeSender(subRepo, dispatcher, logger))
+
+	var batches scheduler.BatchSource = subRepo
+	if cfg.ScheduleCacheEnabled {
+		cache := scheduler.NewScheduleCache(subRepo, cfg.ScheduleCacheMaxAge, logger)
+		listener.Handle(repository.ChannelSubscriptionsChanged, cache)
+		go cache.RunRefresh(context.Background())
+		batches = cache
+	}
+	go listener.Run(context.Background())
 
-		ctx := context.Background()
+	// 4b) Minimum interval between emails to the same address (shared through Redis),
+	// for the lifecycle emails; the dispatcher has its own
+	var sendGuard scheduler.RecipientGuard
+	if cfg.MinEmailInterval > 0 {
+		sendGuard = scheduler.NewSendGuard(rdb, cfg.MinEmailInterval, logger)
+	}
 
-		// 5a) Hourly subscribers
-		hourlySubs, err := subRepo.HourlyBatch(ctx, minute)
+	// 4c) Metrics: served for Prometheus and/or pushed to statsd or an OTLP collector
+	if cfg.MetricsAddr != "" {
+		go metrics.Serve(context.Background(), cfg.MetricsAddr, logger)
+	}
+	metricsExporter, err := metrics.NewExporter(cfg, "scheduler")
+	if err != nil {
+		logger.Fatal("failed to initialize metrics push", zap.Error(err))
+	}
+	if metricsExporter != nil {
+		go metrics.Push(context.Background(), metricsExporter, cfg.MetricsPushInterval, logger)
+	}
+
+	// 4d) The weather updates queued by the tick are sent by cmd/emailworker; without
+	// it, SCHEDULER_SEND_WORKERS workers send them in every replica
+	jobRepo := repository.NewJobRepository(db, logger)
+	if cfg.SchedulerSendWorkers == 0 {
+		logger.Warn("SCHEDULER_SEND_WORKERS is 0: weather updates are queued but only sent while cmd/emailworker runs")
+	}
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	for i := range cfg.SchedulerSendWorkers {
+		worker := scheduler.NewJobWorker(jobRepo, subRepo, dispatcher, fmt.Sprintf("%s-%d", cfg.SchedulerInstanceID, i),
+			cfg.SendJobLease, cfg.SendJobMaxAttempts, cfg.SendJobRetryBackoff, logger)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.Run(workersCtx)
+		}()
+	}
+
+	// 5) Build cron (standard 5-field, minute resolution, or 6-field with seconds); with
+	// several replicas, each tick of each job runs in the replica that claims it first
+	c := cron.New(cron.WithParser(scheduler.CronParser))
+	ticks := scheduler.NewTickLock(rdb, cfg.SchedulerInstanceID, cfg.SchedulerLockTTL, logger)
+	// with leader election, standby replicas run no job at all
+	leaderCtx, stopLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	if cfg.SchedulerLeaderElection {
+		leader := scheduler.NewLeader(rdb, cfg.SchedulerInstanceID, cfg.SchedulerLeaderLease, logger)
+		ticks.WithLeader(leader)
+		go func() {
+			leader.Run(leaderCtx)
+			close(leaderDone)
+		}()
+	} else {
+		close(leaderDone)
+	}
+	spec := cfg.SchedulerCronSpec // every minute, at second 0, by default
+	resolution := scheduler.TickResolution(spec)
+
+	reading := scheduler.BatchReading{PageSize: cfg.SchedulerBatchPageSize, Stream: cfg.SchedulerBatchStream}
+	// runUpdates queues the updates and evaluates the alerts of the subscriptions due
+	// at slot; hourly ones only when the slot is their latest. What it did is added to report.
+	runUpdates := func(ctx context.Context, slot time.Time, hourly bool, report *scheduler.TickReport) {
+		// 5a) Hourly and daily subscribers, queued for the send workers
+		scheduler.QueueSlot(ctx, batches, jobRepo, slot, hourly, reading, report, logger)
+
+		if !hourly {
+			return
+		}
+		// 5b) Alert subscriptions, evaluated every hour at their minute like hourly ones
+		minute := slot.Minute()
+		alerts, err := alertRepo.AlertBatch(ctx, minute)
 		if err != nil {
-			logger.Error("failed to fetch hourly subscriptions",
+			logger.Error("failed to fetch alert subscriptions",
 				zap.Int("minute", minute), zap.Error(err))
+			report.Fail("alerts")
 		} else {
-			sendWeatherUpdates(ctx, hourlySubs, weatherFetcher, smtpSender, cfg.BaseURL, logger)
+			report.Alerts += len(alerts)
+			dispatcher.SendAlerts(ctx, alerts, slot)
 		}
+	}
 
-		// 5b) Daily subscribers
-		dailySubs, err := subRepo.DailyBatch(ctx, hour, minute)
-		if err != nil {
-			logger.Error("failed to fetch daily subscriptions",
-				zap.Int("hour", hour), zap.Int("minute", minute), zap.Error(err))
-		} else {
-			sendWeatherUpdates(ctx, dailySubs, weatherFetcher, smtpSender, cfg.BaseURL, logger)
+	// ticks missed while no scheduler ran are run by the next one
+	catchUp := scheduler.NewCatchUp(repository.NewTickRepository(db, logger), "updates", cfg.SchedulerCatchUpHorizon, logger)
+
+	_, err = c.AddFunc(spec, func() {
+		// Add the offset (30s) to avoid rolling edge cases (e.g. 12:05:59.999)
+		now := time.Now().Add(cfg.SchedulerTickOffset)
+		slot := now.Truncate(resolution)
+
+		// one trace per tick, forwarded to weather providers as X-Request-ID/traceparent
+		ctx := tracing.NewContext(context.Background(), tracing.New())
+		if !ticks.Acquire(ctx, "updates", slot) {
+			return
 		}
+
+		// Missed ticks first, oldest first; an hourly subscription missed more than
+		// an hour ago has a later missed tick, its latest update only is sent
+		report := scheduler.StartTick(slot)
+		for _, missed := range catchUp.Missed(ctx, slot) {
+			if ticks.Acquire(ctx, "updates", missed) {
+				runUpdates(ctx, missed, slot.Sub(missed) < time.Hour, report)
+				report.CaughtUp++
+			}
+		}
+		runUpdates(ctx, slot, true, report)
+		catchUp.Record(ctx, slot)
+		report.Finish(logger)
 	})
 	if err != nil {
 		logger.Fatal("unable to schedule cron job", zap.Error(err))
 	}
 
-	logger.Info("starting scheduler", zap.String("cronSpec", spec))
-	c.Start()
+	// 5c) Monthly provider cost report for the operator (previous month, on the 1st)
+	if cfg.OperatorEmail != "" {
+		usage := weather.NewUsageTracker(rdb, logger)
+		prices := weather.ProviderPrices(cfg)
+		_, err = c.AddFunc(costReportSpec, func() {
+			if !ticks.Acquire(context.Background(), "cost_report", time.Now().Truncate(time.Minute)) {
+				return
+			}
+			lastMonth := time.Now().UTC().AddDate(0, -1, 0)
+			sendCostReport(context.Background(), usage, prices, lastMonth, emailSender, cfg.OperatorEmail, logger)
+		})
+		if err != nil {
+			logger.Fatal("unable to schedule cost report job", zap.Error(err))
+		}
+	}
 
-	// block forever
-	select {}
-}
+	// 5d) Lifecycle emails: one-year anniversary and re-engagement of inactive subscribers
+	if cfg.LifecycleEmailsEnabled {
+		lifecycle := scheduler.NewLifecycleMailer(repository.NewEngagementRepository(db, piiCipher, linkTokens, logger), suppressionRepo,
+			emailSender, cfg.BaseURL, cfg.ReEngagementAfter, logger).WithLinkSigner(linkSigner)
+		if sendGuard != nil {
+			lifecycle.WithRecipientGuard(sendGuard)
+		}
+		_, err = c.AddFunc(scheduler.LifecycleSpec, func() {
+			if ticks.Acquire(context.Background(), "lifecycle", time.Now().Truncate(time.Minute)) {
+				lifecycle.Run(context.Background(), time.Now())
+			}
+		})
+		if err != nil {
+			logger.Fatal("unable to schedule lifecycle emails job", zap.Error(err))
+		}
+	}
 
-// sendWeatherUpdates fetches weather for each subscription and
-// sends all emails in one batch (one SMTP session), including an unsubscribe link.
-func sendWeatherUpdates(
-	ctx context.Context,
-	subs []repository.Subscription,
-	fetcher weather.Fetcher,
-	sender email.EmailSender,
-	baseURL string,
-	logger *zap.Logger,
-) {
-	if len(subs) == 0 {
-		return
+	// 5e) Delete subscriptions never confirmed within the retention period
+	if cfg.UnconfirmedRetentionDays > 0 {
+		cleaner := scheduler.NewUnconfirmedCleaner(subRepo, time.Duration(cfg.UnconfirmedRetentionDays)*24*time.Hour, logger)
+		_, err = c.AddFunc(scheduler.CleanupSpec, func() {
+			if ticks.Acquire(context.Background(), "unconfirmed_cleanup", time.Now().Truncate(time.Minute)) {
+				cleaner.Run(context.Background(), time.Now())
+			}
+		})
+		if err != nil {
+			logger.Fatal("unable to schedule unconfirmed cleanup job", zap.Error(err))
+		}
 	}
 
-	var messages []email.EmailMessage
-	for _, sub := range subs {
-		w, err := fetcher.FetchCurrent(ctx, sub.City)
+	// ... and subscriptions unsubscribed longer ago than theirs
+	if cfg.DeletedRetentionDays > 0 {
+		purger := scheduler.NewDeletedCleaner(subRepo, time.Duration(cfg.DeletedRetentionDays)*24*time.Hour, logger)
+		_, err = c.AddFunc(scheduler.CleanupSpec, func() {
+			if ticks.Acquire(context.Background(), "deleted_purge", time.Now().Truncate(time.Minute)) {
+				purger.Run(context.Background(), time.Now())
+			}
+		})
 		if err != nil {
-			logger.Error("weather fetch failed",
-				zap.String("email", sub.Email),
-				zap.String("city", sub.City),
-				zap.Error(err))
-			continue
+			logger.Fatal("unable to schedule deleted purge job", zap.Error(err))
 		}
+	}
+
+	// 5f) Subscription counts for the metrics
+	stats := scheduler.NewSubscriptionStats(subRepo, logger)
+	go stats.Run(context.Background())
+	_, err = c.AddFunc(scheduler.StatsSpec, func() {
+		stats.Run(context.Background())
+	})
+	if err != nil {
+		logger.Fatal("unable to schedule subscription stats job", zap.Error(err))
+	}
 
-		confirmUnsubURL := fmt.Sprintf("%s/api/unsubscribe/%s", baseURL, sub.UnsubscribeToken.String())
-
-		body := fmt.Sprintf(
-			`<p>Current weather in <b>%s</b>:</p>
-<ul>
-  <li>Temperature: %.2f°C</li>
-  <li>Humidity: %d%%</li>
-  <li>Description: %s</li>
-</ul>
-<p><a href="%s">Unsubscribe</a> from these updates.</p>`,
-			sub.City, w.Temp, w.Humidity, w.Description,
-			confirmUnsubURL,
-		)
-
-		messages = append(messages, email.EmailMessage{
-			To:      []string{sub.Email},
-			Subject: fmt.Sprintf("Weather update for %s", sub.City),
-			Body:    body,
+	// 5g) Synthetic monitoring: a real subscriber whose hourly email must reach its mailbox
+	if cfg.SyntheticEmail != "" {
+		if err := synthetic.EnsureSubscription(context.Background(), subRepo, cfg.SyntheticEmail, cfg.SyntheticCity, logger); err != nil {
+			logger.Fatal("failed to set up the synthetic subscription", zap.Error(err))
+		}
+		monitor := synthetic.NewMonitor(synthetic.NewStore(rdb), cfg.SyntheticSLO, synthetic.NewWebhookAlerter(cfg.AlertWebhookURL), logger)
+		_, err = c.AddFunc(synthetic.CheckSpec, func() {
+			if ticks.Acquire(context.Background(), "synthetic", time.Now().Truncate(time.Minute)) {
+				monitor.Check(context.Background(), time.Now())
+			}
 		})
+		if err != nil {
+			logger.Fatal("unable to schedule synthetic monitoring job", zap.Error(err))
+		}
 	}
 
-	if len(messages) == 0 {
-		return
+	// 5h) Record the weather of every subscribed city hourly, and delete weather
+	// snapshots too old to be charted
+	if snapshotRepo != nil {
+		_, err = c.AddFunc(scheduler.SnapshotSpec, func() {
+			ctx := tracing.NewContext(context.Background(), tracing.New())
+			if ticks.Acquire(ctx, "snapshots", time.Now().Truncate(time.Minute)) {
+				scheduler.RecordSnapshots(ctx, subRepo, weatherFetcher, snapshotRepo, time.Now(), logger)
+			}
+		})
+		if err != nil {
+			logger.Fatal("unable to schedule weather snapshot job", zap.Error(err))
+		}
+		_, err = c.AddFunc(scheduler.SnapshotPruneSpec, func() {
+			if ticks.Acquire(context.Background(), "snapshot_prune", time.Now().Truncate(time.Minute)) {
+				scheduler.PruneSnapshots(context.Background(), snapshotRepo, time.Now(), logger)
+			}
+		})
+		if err != nil {
+			logger.Fatal("unable to schedule weather snapshot cleanup job", zap.Error(err))
+		}
 	}
-	if err := sender.SendBatch(messages); err != nil {
-		logger.Error("failed to send weather update emails", zap.Error(err))
-	} else {
-		logger.Info("sent weather update emails", zap.Int("count", len(messages)))
+
+	// 5i) Government severe weather warnings for warning subscriptions
+	if warningsFeed != nil {
+		_, err = c.AddFunc("@every "+cfg.WarningsCheckInterval.String(), func() {
+			ctx := tracing.NewContext(context.Background(), tracing.New())
+			// @every ticks drift apart across replicas: claim the whole interval
+			if ticks.Acquire(ctx, "warnings", time.Now().Truncate(cfg.WarningsCheckInterval)) {
+				scheduler.CheckWarnings(ctx, warningRepo, dispatcher, time.Now(), logger)
+			}
+		})
+		if err != nil {
+			logger.Fatal("unable to schedule weather warnings job", zap.Error(err))
+		}
 	}
+
+	logger.Info("starting scheduler", zap.String("cronSpec", spec), zap.Duration("tickOffset", cfg.SchedulerTickOffset))
+	c.Start()
+
+	// 6) On SIGTERM let running jobs and sends finish, then hand the leader lease over
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	logger.Info("stopping scheduler")
+	<-c.Stop().Done()
+	stopWorkers()
+	workers.Wait()
+	stopLeader()
+	<-leaderDone
 }
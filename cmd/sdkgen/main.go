@@ -0,0 +1,47 @@
+// Command sdkgen generates the API clients under clients/ from the OpenAPI document.
+// It is run through go generate:
+//
+//	go generate ./clients
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/sdkgen"
+)
+
+func main() {
+	specPath := flag.String("spec", "api/openapi.json", "OpenAPI document")
+	goOut := flag.String("go", "", "output file of the Go client (skipped when empty)")
+	goPkg := flag.String("go-package", "weatherclient", "package name of the Go client")
+	tsOut := flag.String("ts", "", "output file of the TypeScript client (skipped when empty)")
+	flag.Parse()
+
+	spec, err := sdkgen.Load(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *goOut != "" {
+		src, err := sdkgen.GenerateGo(spec, *goPkg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		write(*goOut, src)
+	}
+	if *tsOut != "" {
+		write(*tsOut, sdkgen.GenerateTypeScript(spec))
+	}
+}
+
+func write(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
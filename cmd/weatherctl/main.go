@@ -0,0 +1,174 @@
+// Command weatherctl runs operator tasks against the service's database.
+//
+//	weatherctl deliveries replay --from 2026-10-16T08:00:00Z --to 2026-10-16T11:00:00Z [--status failed] [--dry-run]
+//
+// deliveries replay sends weather updates again to subscribers whose delivery failed
+// in the time range, e.g. after an SMTP outage. It reads the same environment as the
+// scheduler.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/notify"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/scheduler"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slack"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/sms"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/summary"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+)
+
+const usage = `usage: weatherctl <command> [flags]
+
+commands:
+  deliveries replay   send failed deliveries of a time range again
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] + " " + os.Args[2] {
+	case "deliveries replay":
+		replayDeliveries(os.Args[3:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func replayDeliveries(args []string) {
+	fs := flag.NewFlagSet("deliveries replay", flag.ExitOnError)
+	from := fs.String("from", "", "start of the range of slots, RFC 3339 (required)")
+	to := fs.String("to", "", "end of the range of slots, exclusive, RFC 3339 (required)")
+	status := fs.String("status", repository.DeliveryStatusFailed, "status of the deliveries to replay; only failed ones can be")
+	dryRun := fs.Bool("dry-run", false, "only count the subscriptions that would be sent to")
+	_ = fs.Parse(args)
+
+	fromT, err := time.Parse(time.RFC3339, *from)
+	if err != nil {
+		log.Fatalf("invalid --from %q: %v", *from, err)
+	}
+	toT, err := time.Parse(time.RFC3339, *to)
+	if err != nil {
+		log.Fatalf("invalid --to %q: %v", *to, err)
+	}
+	if !fromT.Before(toT) {
+		log.Fatalf("--from must be before --to")
+	}
+	if *status != repository.DeliveryStatusFailed {
+		log.Fatalf("--status %q: only failed deliveries can be replayed", *status)
+	}
+
+	// 1) Load configuration from environment
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("configuration error: %v", err)
+	}
+
+	// 2) Initialize structured logger
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("cannot initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	// 3) Connect to Postgres
+	piiCipher, err := pii.NewCipher(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize email encryption", zap.Error(err))
+	}
+	linkTokens, err := linktoken.NewDeriver(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize link tokens", zap.Error(err))
+	}
+	db, err := repository.OpenDB(context.Background(), cfg.DatabaseURL,
+		repository.Pool{MaxConns: cfg.DBPoolMaxConns, MinConns: cfg.DBPoolMinConns,
+			MaxConnLifetime: cfg.DBPoolMaxConnLifetime},
+		repository.Retry{For: cfg.DBStartupRetryTimeout, Backoff: cfg.DBStartupRetryBackoff}, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+
+	// 4) Build the dispatcher as the scheduler does, so replays render the same emails
+	subRepo := repository.NewSubscriptionRepository(db, piiCipher, linkTokens, logger)
+	deliveryRepo := repository.NewDeliveryRepository(db, piiCipher, linkTokens, logger)
+	suppressionRepo := repository.NewSuppressionRepository(db, logger)
+
+	emailSender, err := email.NewSender(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize email sender", zap.Error(err))
+	}
+	if err := email.SetStrict(cfg.EmailTemplatesStrict); err != nil {
+		logger.Fatal("email templates failed the strict check", zap.Error(err))
+	}
+	tenants, err := tenant.New(cfg)
+	if err != nil {
+		logger.Fatal("invalid TENANTS", zap.Error(err))
+	}
+	tenant.Use(tenants)
+	rdb, err := redisclient.Open(cfg)
+	if err != nil {
+		logger.Fatal("failed to connect to redis", zap.Error(err))
+	}
+	weatherFetcher, err := weather.BuildCachingFetcher(cfg, rdb, nil, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize weather fetcher", zap.Error(err))
+	}
+	linkSigner, err := linksign.NewSigner(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize link signing", zap.Error(err))
+	}
+	featureFlags, err := features.New(cfg)
+	if err != nil {
+		logger.Fatal("invalid FEATURE_FLAGS", zap.Error(err))
+	}
+	dispatcher := scheduler.NewDispatcher(subRepo, suppressionRepo, weatherFetcher, emailSender, deliveryRepo, cfg.BaseURL, logger).
+		WithPrefetchLimiter(weather.NewPrefetchLimiter(cfg.WeatherPrefetchConcurrency)).
+		WithAttributions(weather.ProviderAttributions(cfg)).
+		WithLinkSigner(linkSigner).
+		WithFeatures(featureFlags).
+		WithSummarizer(summary.New(cfg, rdb, logger)).
+		WithNotifier(notify.NewSlack(slack.NewClient(cfg.SlackPostTimeout))).
+		WithDeadLetter(repository.NewDeadLetterRepository(db, logger), cfg.DeadLetterAfter).
+		WithSendRecorder(subRepo)
+	if cfg.SendClaimLease > 0 {
+		dispatcher.WithSendClaims(subRepo, cfg.SendClaimLease)
+	}
+	if texts := sms.New(cfg); texts != nil {
+		dispatcher.WithNotifier(notify.NewSMS(texts))
+	}
+
+	// 5) Replay; an interrupted run is resumed by running it again
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	n, err := scheduler.NewReplayer(deliveryRepo, dispatcher, logger).Run(ctx, fromT, toT, *dryRun)
+	if err != nil {
+		logger.Fatal("replay failed", zap.Int("replayed", n), zap.Error(err))
+	}
+	if *dryRun {
+		logger.Info("dry run: subscriptions to replay", zap.Int("count", n))
+		return
+	}
+	logger.Info("replayed failed deliveries", zap.Int("count", n))
+}
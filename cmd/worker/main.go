@@ -0,0 +1,91 @@
+// Command worker sends the emails the API and scheduler queue in Redis when
+// EMAIL_QUEUE_ENABLED is set, so that neither waits for SMTP, and records in the
+// delivery log whether the scheduled ones were sent. Run as many as needed, each with
+// its own WORKER_ID (the host name by default); it reads the same environment as the
+// scheduler and stops on SIGINT/SIGTERM after the job in hand.
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/mailqueue"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("configuration error: %v", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("cannot initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	// the real sender (SMTP or SendGrid); the queue is only for producers
+	emailSender, err := email.NewSender(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize email sender", zap.Error(err))
+	}
+
+	if !cfg.EmailQueueEnabled {
+		logger.Fatal("EMAIL_QUEUE_ENABLED is not set: nothing queues emails for the worker")
+	}
+	rdb, err := redisclient.OpenMailQueue(cfg)
+	if err != nil {
+		logger.Fatal("failed to connect to the mail queue redis", zap.Error(err))
+	}
+	defer rdb.Close()
+	piiCipher, err := pii.NewCipher(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize email encryption", zap.Error(err))
+	}
+	linkTokens, err := linktoken.NewDeriver(cfg)
+	if err != nil {
+		logger.Fatal("failed to initialize link tokens", zap.Error(err))
+	}
+
+	// the delivery log, where the worker records what became of scheduled emails
+	repository.SetSlowQueryThreshold(cfg.DBSlowQueryThreshold)
+	db, err := repository.OpenDB(context.Background(), cfg.DatabaseURL,
+		repository.Pool{MaxConns: cfg.DBPoolMaxConns, MinConns: cfg.DBPoolMinConns,
+			MaxConnLifetime: cfg.DBPoolMaxConnLifetime},
+		repository.Retry{For: cfg.DBStartupRetryTimeout, Backoff: cfg.DBStartupRetryBackoff}, logger)
+	if err != nil {
+		logger.Fatal("failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+	deliveries := repository.NewDeliveryRepository(db, piiCipher, linkTokens, logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.MetricsAddr != "" {
+		go metrics.Serve(ctx, cfg.MetricsAddr, logger)
+	}
+	metricsExporter, err := metrics.NewExporter(cfg, "worker")
+	if err != nil {
+		logger.Fatal("failed to initialize metrics push", zap.Error(err))
+	}
+	if metricsExporter != nil {
+		go metrics.Push(ctx, metricsExporter, cfg.MetricsPushInterval, logger)
+	}
+
+	logger.Info("starting email worker", zap.String("id", cfg.WorkerID))
+	mailqueue.NewWorker(rdb, piiCipher, emailSender, deliveries, cfg.WorkerID, cfg.EmailQueueMaxAttempts,
+		cfg.EmailQueueRetryBackoff, logger).Run(ctx)
+	logger.Info("email worker stopped")
+}
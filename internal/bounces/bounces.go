@@ -0,0 +1,183 @@
+// Package bounces reads the bounce and complaint notifications that email providers
+// post to webhooks, so the addresses behind them can be suppressed.
+//
+// Supported formats:
+//   - "ses": Amazon SES notifications delivered through an SNS HTTP subscription
+//   - "sendgrid": the SendGrid Event Webhook (a JSON array of events)
+//   - "mailgun": Mailgun webhooks (one event per request)
+package bounces
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind of a notification.
+type Kind string
+
+const (
+	KindBounce    Kind = "bounce"
+	KindComplaint Kind = "complaint"
+)
+
+// Event is a bounce or complaint of one recipient.
+type Event struct {
+	Email string // lower-cased
+	Kind  Kind
+	// Permanent bounces (the address does not exist, the domain does not accept mail)
+	// will never be delivered; temporary ones (mailbox full, greylisting) may be later.
+	// Complaints are always permanent.
+	Permanent bool
+}
+
+// ErrUnknownProvider is returned for a provider whose format is not supported.
+var ErrUnknownProvider = errors.New("unknown bounce notification provider")
+
+// SNSConfirmation is returned for an SNS subscription confirmation request: SES
+// notifications only arrive once the subscription is confirmed by visiting URL.
+type SNSConfirmation struct {
+	URL string
+}
+
+func (c *SNSConfirmation) Error() string {
+	return "SNS subscription confirmation, visit " + c.URL
+}
+
+// Parse returns the bounces and complaints in the body of a notification from
+// provider. Other events (deliveries, opens, ...) are skipped.
+func Parse(provider string, body []byte) ([]Event, error) {
+	switch provider {
+	case "ses":
+		return parseSES(body)
+	case "sendgrid":
+		return parseSendGrid(body)
+	case "mailgun":
+		return parseMailgun(body)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownProvider, provider)
+	}
+}
+
+func newEvent(email string, kind Kind, permanent bool) (Event, bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	return Event{Email: email, Kind: kind, Permanent: permanent}, email != ""
+}
+
+// snsEnvelope is an SNS HTTP(S) message; Message holds the SES notification as JSON.
+type snsEnvelope struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+type sesRecipient struct {
+	EmailAddress string `json:"emailAddress"`
+}
+
+type sesNotification struct {
+	NotificationType string `json:"notificationType"` // "Bounce", "Complaint", "Delivery"
+	EventType        string `json:"eventType"`        // the same, from configuration set event publishing
+	Bounce           struct {
+		BounceType        string         `json:"bounceType"` // "Permanent", "Transient", "Undetermined"
+		BouncedRecipients []sesRecipient `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplainedRecipients []sesRecipient `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+func parseSES(body []byte) ([]Event, error) {
+	var env snsEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, fmt.Errorf("invalid SNS message: %w", err)
+	}
+	switch env.Type {
+	case "SubscriptionConfirmation":
+		return nil, &SNSConfirmation{URL: env.SubscribeURL}
+	case "Notification":
+	default:
+		return nil, nil
+	}
+
+	var n sesNotification
+	if err := json.Unmarshal([]byte(env.Message), &n); err != nil {
+		return nil, fmt.Errorf("invalid SES notification: %w", err)
+	}
+	kind := n.NotificationType
+	if kind == "" {
+		kind = n.EventType
+	}
+	var events []Event
+	switch kind {
+	case "Bounce":
+		for _, r := range n.Bounce.BouncedRecipients {
+			if e, ok := newEvent(r.EmailAddress, KindBounce, n.Bounce.BounceType == "Permanent"); ok {
+				events = append(events, e)
+			}
+		}
+	case "Complaint":
+		for _, r := range n.Complaint.ComplainedRecipients {
+			if e, ok := newEvent(r.EmailAddress, KindComplaint, true); ok {
+				events = append(events, e)
+			}
+		}
+	}
+	return events, nil
+}
+
+type sendGridEvent struct {
+	Email string `json:"email"`
+	Event string `json:"event"` // "bounce", "dropped", "spamreport", "delivered", ...
+	Type  string `json:"type"`  // of a bounce: "bounce" (permanent) or "blocked" (temporary)
+}
+
+func parseSendGrid(body []byte) ([]Event, error) {
+	var in []sendGridEvent
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, fmt.Errorf("invalid SendGrid events: %w", err)
+	}
+	var events []Event
+	for _, ev := range in {
+		var e Event
+		var ok bool
+		switch ev.Event {
+		case "bounce":
+			e, ok = newEvent(ev.Email, KindBounce, ev.Type != "blocked")
+		case "spamreport":
+			e, ok = newEvent(ev.Email, KindComplaint, true)
+		}
+		if ok {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+type mailgunWebhook struct {
+	EventData struct {
+		Event     string `json:"event"`    // "failed", "complained", "delivered", ...
+		Severity  string `json:"severity"` // of a failure: "permanent" or "temporary"
+		Recipient string `json:"recipient"`
+	} `json:"event-data"`
+}
+
+func parseMailgun(body []byte) ([]Event, error) {
+	var in mailgunWebhook
+	if err := json.Unmarshal(body, &in); err != nil {
+		return nil, fmt.Errorf("invalid Mailgun webhook: %w", err)
+	}
+	var e Event
+	var ok bool
+	switch d := in.EventData; d.Event {
+	case "failed":
+		e, ok = newEvent(d.Recipient, KindBounce, d.Severity == "permanent")
+	case "complained":
+		e, ok = newEvent(d.Recipient, KindComplaint, true)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return []Event{e}, nil
+}
@@ -0,0 +1,49 @@
+package bounces
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		provider, body string
+		want           []Event
+	}{
+		{"ses", `{"Type":"Notification","Message":"{\"notificationType\":\"Bounce\",\"bounce\":{\"bounceType\":\"Permanent\",\"bouncedRecipients\":[{\"emailAddress\":\"Gone@Example.com\"}]}}"}`,
+			[]Event{{Email: "gone@example.com", Kind: KindBounce, Permanent: true}}},
+		{"ses", `{"Type":"Notification","Message":"{\"notificationType\":\"Complaint\",\"complaint\":{\"complainedRecipients\":[{\"emailAddress\":\"angry@example.com\"}]}}"}`,
+			[]Event{{Email: "angry@example.com", Kind: KindComplaint, Permanent: true}}},
+		{"ses", `{"Type":"Notification","Message":"{\"notificationType\":\"Delivery\"}"}`, nil},
+		{"sendgrid", `[{"email":"gone@example.com","event":"bounce","type":"bounce"},{"email":"full@example.com","event":"bounce","type":"blocked"},{"email":"ok@example.com","event":"delivered"},{"email":"angry@example.com","event":"spamreport"}]`,
+			[]Event{
+				{Email: "gone@example.com", Kind: KindBounce, Permanent: true},
+				{Email: "full@example.com", Kind: KindBounce},
+				{Email: "angry@example.com", Kind: KindComplaint, Permanent: true},
+			}},
+		{"mailgun", `{"signature":{},"event-data":{"event":"failed","severity":"temporary","recipient":"full@example.com"}}`,
+			[]Event{{Email: "full@example.com", Kind: KindBounce}}},
+		{"mailgun", `{"event-data":{"event":"complained","recipient":"angry@example.com"}}`,
+			[]Event{{Email: "angry@example.com", Kind: KindComplaint, Permanent: true}}},
+	}
+	for _, c := range cases {
+		got, err := Parse(c.provider, []byte(c.body))
+		if err != nil {
+			t.Errorf("Parse(%s, %s) error: %v", c.provider, c.body, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Parse(%s, %s) = %+v, want %+v", c.provider, c.body, got, c.want)
+		}
+	}
+
+	var confirm *SNSConfirmation
+	_, err := Parse("ses", []byte(`{"Type":"SubscriptionConfirmation","SubscribeURL":"https://sns.example.com/confirm"}`))
+	if !errors.As(err, &confirm) || confirm.URL != "https://sns.example.com/confirm" {
+		t.Errorf("Parse(subscription confirmation) error = %v, want the SubscribeURL", err)
+	}
+	if _, err := Parse("postmark", []byte(`{}`)); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Parse(postmark) error = %v, want ErrUnknownProvider", err)
+	}
+}
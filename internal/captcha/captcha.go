@@ -0,0 +1,106 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+const (
+	ProviderRecaptcha = "recaptcha"
+	ProviderTurnstile = "turnstile"
+
+	recaptchaVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+	turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+)
+
+// ErrVerificationFailed is returned when the token is missing, invalid, expired or reused.
+var ErrVerificationFailed = errors.New("captcha verification failed")
+
+// Verifier checks a CAPTCHA token produced by the client-side widget.
+type Verifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// siteVerifier talks to a siteverify endpoint; reCAPTCHA and Turnstile share the protocol.
+type siteVerifier struct {
+	name     string
+	endpoint string
+	secret   string
+	minScore float64
+	client   *http.Client
+}
+
+// NewVerifier returns the verifier configured by CAPTCHA_PROVIDER, or nil when CAPTCHA is disabled.
+func NewVerifier(cfg *config.Config) (Verifier, error) {
+	var endpoint string
+	switch cfg.CaptchaProvider {
+	case "":
+		return nil, nil
+	case ProviderRecaptcha:
+		endpoint = recaptchaVerifyURL
+	case ProviderTurnstile:
+		endpoint = turnstileVerifyURL
+	default:
+		return nil, fmt.Errorf("unknown CAPTCHA_PROVIDER %q", cfg.CaptchaProvider)
+	}
+	return &siteVerifier{
+		name:     cfg.CaptchaProvider,
+		endpoint: endpoint,
+		secret:   cfg.CaptchaSecret,
+		minScore: cfg.CaptchaMinScore,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// Verify implements Verifier. Network or provider errors are returned as-is so the
+// caller can tell them apart from ErrVerificationFailed.
+func (v *siteVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrVerificationFailed
+	}
+
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("%s: failed to build request: %w", v.name, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: HTTP request failed: %w", v.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d %s", v.name, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	var body struct {
+		Success    bool     `json:"success"`
+		Score      *float64 `json:"score"` // reCAPTCHA v3 only
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("%s: JSON decode error: %w", v.name, err)
+	}
+
+	if !body.Success {
+		return fmt.Errorf("%w: %s", ErrVerificationFailed, strings.Join(body.ErrorCodes, ", "))
+	}
+	if body.Score != nil && *body.Score < v.minScore {
+		return fmt.Errorf("%w: score %.2f below %.2f", ErrVerificationFailed, *body.Score, v.minScore)
+	}
+	return nil
+}
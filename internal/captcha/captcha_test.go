@@ -0,0 +1,82 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+func TestNewVerifier(t *testing.T) {
+	for _, tc := range []struct {
+		provider, endpoint string
+		wantErr            bool
+	}{
+		{"", "", false},
+		{ProviderRecaptcha, recaptchaVerifyURL, false},
+		{ProviderTurnstile, turnstileVerifyURL, false},
+		{"hcaptcha", "", true},
+	} {
+		v, err := NewVerifier(&config.Config{CaptchaProvider: tc.provider, CaptchaSecret: "secret"})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("NewVerifier(%q) error = %v, want error %t", tc.provider, err, tc.wantErr)
+			continue
+		}
+		if tc.endpoint == "" {
+			if v != nil {
+				t.Errorf("NewVerifier(%q) = %+v, want none", tc.provider, v)
+			}
+		} else if sv := v.(*siteVerifier); sv.endpoint != tc.endpoint {
+			t.Errorf("NewVerifier(%q) verifies at %s, want %s", tc.provider, sv.endpoint, tc.endpoint)
+		}
+	}
+}
+
+func TestSiteVerifier_Verify(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		token    string
+		status   int
+		body     string
+		wantErr  bool
+		rejected bool // ErrVerificationFailed rather than the provider unavailable
+	}{
+		{"valid token", "ok", http.StatusOK, `{"success": true}`, false, false},
+		{"score high enough", "ok", http.StatusOK, `{"success": true, "score": 0.7}`, false, false},
+		{"score too low", "ok", http.StatusOK, `{"success": true, "score": 0.3}`, true, true},
+		{"invalid token", "bad", http.StatusOK, `{"success": false, "error-codes": ["invalid-input-response"]}`, true, true},
+		{"no token", "", http.StatusOK, `{"success": true}`, true, true},
+		{"provider failing", "ok", http.StatusBadGateway, ``, true, false},
+		{"malformed answer", "ok", http.StatusOK, `<html>`, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var form map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				form = map[string]string{"secret": r.PostForm.Get("secret"), "response": r.PostForm.Get("response"),
+					"remoteip": r.PostForm.Get("remoteip")}
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+			v := &siteVerifier{name: ProviderTurnstile, endpoint: srv.URL, secret: "secret", minScore: 0.5, client: srv.Client()}
+
+			err := v.Verify(context.Background(), tc.token, "203.0.113.7")
+			if (err != nil) != tc.wantErr || errors.Is(err, ErrVerificationFailed) != tc.rejected {
+				t.Fatalf("Verify() error = %v, want error %t, rejected %t", err, tc.wantErr, tc.rejected)
+			}
+			if tc.token == "" {
+				if form != nil {
+					t.Error("Verify() without a token asked the provider")
+				}
+				return
+			}
+			if form["secret"] != "secret" || form["response"] != tc.token || form["remoteip"] != "203.0.113.7" {
+				t.Errorf("siteverify form = %v", form)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Package chart draws the small temperature trend charts embedded in daily emails.
+//
+// Charts are plain PNG images drawn with the standard library: a line over a shaded
+// area, with a horizontal guide at every whole 5 degrees. They carry no text, so the
+// email around them gives the numbers and nothing needs a font.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"time"
+)
+
+// Point is one reading of a series.
+type Point struct {
+	At    time.Time
+	Value float64
+}
+
+// ErrTooFewPoints is returned for a series that cannot show a trend.
+var ErrTooFewPoints = errors.New("a chart needs at least two points")
+
+// padding keeps the line and its thickness off the edges of the image.
+const padding = 4
+
+var (
+	background = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	guide      = color.RGBA{R: 0xe5, G: 0xe7, B: 0xeb, A: 0xff}
+	area       = color.RGBA{R: 0xdb, G: 0xea, B: 0xfe, A: 0xff}
+	line       = color.RGBA{R: 0x25, G: 0x63, B: 0xeb, A: 0xff}
+)
+
+// TemperaturePNG draws points, ordered oldest first, as a width x height PNG. The
+// horizontal axis is time, so a gap in the readings shows as a straight segment.
+func TemperaturePNG(points []Point, width, height int) ([]byte, error) {
+	if len(points) < 2 {
+		return nil, ErrTooFewPoints
+	}
+	if width <= 2*padding || height <= 2*padding {
+		return nil, fmt.Errorf("chart of %dx%d is too small", width, height)
+	}
+
+	lo, hi := points[0].Value, points[0].Value
+	for _, p := range points {
+		lo, hi = math.Min(lo, p.Value), math.Max(hi, p.Value)
+	}
+	// a flat series is drawn across the middle, with a little room around it
+	if hi-lo < 1 {
+		mid := (hi + lo) / 2
+		lo, hi = mid-0.5, mid+0.5
+	}
+	start, span := points[0].At, points[len(points)-1].At.Sub(points[0].At)
+	if span <= 0 {
+		return nil, errors.New("chart points must span some time, oldest first")
+	}
+
+	plotW, plotH := float64(width-2*padding), float64(height-2*padding)
+	x := func(t time.Time) float64 { return padding + plotW*float64(t.Sub(start))/float64(span) }
+	y := func(v float64) float64 { return padding + plotH*(hi-v)/(hi-lo) }
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill(img, img.Bounds(), background)
+	for g := math.Ceil(lo/5) * 5; g <= hi; g += 5 {
+		gy := int(math.Round(y(g)))
+		fill(img, image.Rect(padding, gy, width-padding, gy+1), guide)
+	}
+
+	// shade under the line, column by column, then draw the line over it
+	for col := padding; col < width-padding; col++ {
+		top := int(math.Round(y(valueAt(points, start.Add(time.Duration(float64(span)*float64(col-padding)/plotW))))))
+		for row := top; row < height-padding; row++ {
+			if img.RGBAAt(col, row) == background {
+				img.SetRGBA(col, row, area)
+			}
+		}
+	}
+	for i := 1; i < len(points); i++ {
+		drawLine(img, x(points[i-1].At), y(points[i-1].Value), x(points[i].At), y(points[i].Value), line)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode chart: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// valueAt interpolates the series at t.
+func valueAt(points []Point, t time.Time) float64 {
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if t.After(b.At) {
+			continue
+		}
+		d := b.At.Sub(a.At)
+		if d <= 0 {
+			return b.Value
+		}
+		return a.Value + (b.Value-a.Value)*float64(t.Sub(a.At))/float64(d)
+	}
+	return points[len(points)-1].Value
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	r = r.Intersect(img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// drawLine draws a two pixel thick segment by stepping along its longer axis.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.RGBA) {
+	steps := int(math.Ceil(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))))
+	for i := 0; i <= steps; i++ {
+		f := 0.0
+		if steps > 0 {
+			f = float64(i) / float64(steps)
+		}
+		px, py := int(math.Round(x0+(x1-x0)*f)), int(math.Round(y0+(y1-y0)*f))
+		fill(img, image.Rect(px-1, py-1, px+1, py+1), c)
+	}
+}
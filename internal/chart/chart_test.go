@@ -0,0 +1,37 @@
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestTemperaturePNG(t *testing.T) {
+	start := time.Date(2026, 10, 15, 7, 0, 0, 0, time.UTC)
+	var points []Point
+	for h := 0; h < 24; h++ {
+		points = append(points, Point{At: start.Add(time.Duration(h) * time.Hour), Value: 8 + float64(h%12)})
+	}
+
+	data, err := TemperaturePNG(points, 240, 80)
+	if err != nil {
+		t.Fatalf("TemperaturePNG() error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode chart: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 240 || b.Dy() != 80 {
+		t.Errorf("chart is %dx%d, want 240x80", b.Dx(), b.Dy())
+	}
+	// the coldest reading is at the left, so the line runs along the bottom there
+	if img.At(padding, 80-padding-1) != line {
+		t.Errorf("pixel at the first reading = %v, want the line color", img.At(padding, 80-padding-1))
+	}
+
+	if _, err := TemperaturePNG(points[:1], 240, 80); !errors.Is(err, ErrTooFewPoints) {
+		t.Errorf("TemperaturePNG(one point) error = %v, want ErrTooFewPoints", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all the environment‐driven settings for the application.
@@ -16,13 +18,126 @@ type Config struct {
 	PostgresPort     int
 	DatabaseURL      string
 
-	// SMTP
+	// Read replica of the database (POSTGRES_REPLICA_HOST), for the batch queries and
+	// admin listings; empty reads them from the primary
+	ReplicaDatabaseURL string
+
+	// Apply the embedded schema migrations when the API starts
+	MigrateOnStart bool
+
+	// pgxpool connection pool of every process: DBPoolMaxConns open at most,
+	// DBPoolMinConns kept open, each replaced after DBPoolMaxConnLifetime. They are
+	// kept out of the URLs, which the LISTEN connection dials with plain pgx.
+	DBPoolMaxConns        int
+	DBPoolMinConns        int
+	DBPoolMaxConnLifetime time.Duration
+
+	// Processes starting before Postgres accepts connections retry for
+	// DBStartupRetryTimeout (0: fail at once), waiting DBStartupRetryBackoff, doubling
+	DBStartupRetryTimeout time.Duration
+	DBStartupRetryBackoff time.Duration
+	// How often the connection pools are checked (ping, saturation); 0 disables
+	DBHealthCheckInterval time.Duration
+	// Queries slower than this are logged, with their parameters redacted; 0 disables
+	DBSlowQueryThreshold time.Duration
+
+	// Email provider: "smtp" (the default) or "sendgrid"
+	EmailProvider string
+
+	// SMTP; SMTPFrom is the sender address of every provider
 	SMTPHost string
 	SMTPPort int
 	SMTPUser string
 	SMTPPass string
 	SMTPFrom string
 
+	// SMTP sessions kept open between batches, and how long an unused one is kept
+	SMTPPoolSize    int // 0: a new session per batch
+	SMTPIdleTimeout time.Duration
+
+	// SendGrid v3 API, when EmailProvider is "sendgrid"
+	SendGridAPIKey string
+	SendGridAPIURL string
+
+	// Twilio Programmable Messaging, for SMS subscriptions; SMS is off when the account
+	// SID is empty. TwilioFrom is a phone number (E.164) or a messaging service SID.
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string
+	TwilioAPIURL     string
+	SMSCodeTTL       time.Duration // lifetime of the one-time code confirming an SMS subscription
+
+	// Warn at startup when SMTP_FROM's SPF/DMARC would not align with SMTP_HOST
+	SMTPCheckAlignment bool
+
+	// Fail on email templates rendered with data of another type than their sample data,
+	// or lacking a map key they use, and render every template with sample data at startup
+	EmailTemplatesStrict bool
+
+	// Retries of a message failing transiently (SMTP 4xx, SendGrid 429/5xx, a dropped
+	// connection), the first after EmailRetryBackoff, doubling
+	EmailSendRetries  int
+	EmailRetryBackoff time.Duration
+
+	// Email delivery through a Redis queue worked by cmd/worker, instead of in the
+	// request or scheduler loop; a failed email is tried EmailQueueMaxAttempts times in
+	// all, the second after EmailQueueRetryBackoff, doubling. WorkerID names the
+	// worker's in-flight list and must be unique per worker (the host name by default).
+	// The queue lives in a Redis of its own, which must not evict keys, and requires
+	// PII encryption: jobs hold addresses and unsubscribe links.
+	EmailQueueEnabled       bool
+	EmailQueueMaxAttempts   int
+	EmailQueueRetryBackoff  time.Duration
+	EmailQueueRedisAddr     string
+	EmailQueueRedisPassword string
+	WorkerID                string
+
+	// Claims of scheduler ticks in Redis, so that of several scheduler replicas one runs
+	// each tick. SchedulerInstanceID names the replica (the host name by default); a claim
+	// expires after SchedulerLockTTL.
+	SchedulerInstanceID string
+	SchedulerLockTTL    time.Duration
+
+	// Leader election among scheduler replicas: only the holder of a Redis lease of
+	// SchedulerLeaderLease runs jobs, a standby taking over when it dies
+	SchedulerLeaderElection bool
+	SchedulerLeaderLease    time.Duration
+
+	// Cron spec of the update tick (5 fields, or 6 with seconds first for testing), and
+	// how far ahead of the tick its slot is taken, so that a tick fired a little late
+	// still picks the minute it was meant for
+	SchedulerCronSpec   string
+	SchedulerTickOffset time.Duration
+
+	// Minute ticks missed while no scheduler ran (e.g. during a deploy) are run late,
+	// back to at most SchedulerCatchUpHorizon ago; 0 never catches up
+	SchedulerCatchUpHorizon time.Duration
+
+	// Batches of a slot are read and queued SchedulerBatchPageSize subscriptions at a
+	// time (keyset on id), so a crowded minute is neither held in memory nor read by one
+	// long query; 0 reads each batch whole. With SchedulerBatchStream, each batch is
+	// instead read by one query streaming its rows, and queued a page at a time as they
+	// come. The schedule cache is always read whole.
+	SchedulerBatchPageSize int
+	SchedulerBatchStream   bool
+
+	// Durable queue of weather update sends (send_jobs), worked by SendWorkers workers
+	// in every cmd/emailworker process, and by SchedulerSendWorkers in every scheduler
+	// replica (0: none, the email workers send). A claimed job is locked for
+	// SendJobLease; a failed send is tried SendJobMaxAttempts times in all, the second
+	// after SendJobRetryBackoff, doubling.
+	SendWorkers          int
+	SchedulerSendWorkers int
+	SendJobLease         time.Duration
+	SendJobMaxAttempts   int
+	SendJobRetryBackoff  time.Duration
+	// Every batch claims its subscriptions for SendClaimLease before sending, so that
+	// overlapping runs do not both send them; 0 disables claims
+	SendClaimLease time.Duration
+
+	// Failed sends in a row after which a subscription is dead-lettered; 0 never does
+	DeadLetterAfter int
+
 	// Weather API keys
 	WeatherAPIComKey     string
 	OpenWeatherMapOrgKey string
@@ -30,34 +145,244 @@ type Config struct {
 	// Redis
 	RedisPassword string
 	RedisAddr     string
+	CacheVersion  int // bumped by operators to start the weather cache afresh, see weather.CacheNamespace
 
 	// API
-	BaseURL string
+	BaseURL    string // public URL of the API that links start with, PathPrefix included
+	PathPrefix string // path the routes are served under behind a shared-domain proxy, e.g. "/weather-api"; "" for the root
+
+	// White-label tenants with isolated subscribers, "id=host", and their API keys,
+	// "id=key"; see package tenant. Requests of no tenant belong to the default one.
+	Tenants       []string
+	TenantAPIKeys []string
+
+	// Admin API (basic auth and/or JWT). Admin routes are disabled when neither is set.
+	AdminUser      string
+	AdminPassword  string
+	AdminJWTSecret string
+	AdminJWTTTL    time.Duration
+	// How long GET /api/admin/stats answers are cached in Redis; 0 disables the cache
+	AdminStatsCacheTTL time.Duration
+
+	// Weather provider ranking: provider names, most preferred first, and how long a less
+	// preferred result waits for them (0: the first success wins)
+	WeatherProviderPreference []string
+	WeatherPreferenceGrace    time.Duration
+
+	// Cities whose weather is fetched at once by a batch prefetch, across all the batches
+	// of the process, to keep within provider quotas
+	WeatherPrefetchConcurrency int
+
+	// Weather provider pricing (per call) used by the usage cost report
+	WeatherAPIComPricePerCall     float64
+	OpenWeatherMapOrgPricePerCall float64
+
+	// Attribution line each provider's terms require wherever its data is shown
+	WeatherAPIComAttribution     string
+	OpenWeatherMapOrgAttribution string
+
+	// Operator address receiving the monthly cost report; optional
+	OperatorEmail string
+
+	// Synthetic monitoring: a real hourly subscription of SyntheticEmail, whose mailbox
+	// reports every email it receives to the observation webhook (authenticated with
+	// SyntheticWebhookToken); disabled when SyntheticEmail is empty. An email not
+	// observed within an hour plus SyntheticSLO alerts the operator, through
+	// AlertWebhookURL when set.
+	SyntheticEmail        string
+	SyntheticCity         string
+	SyntheticSLO          time.Duration
+	SyntheticWebhookToken string
+	AlertWebhookURL       string
+
+	// Bounce and complaint notifications of the email provider, at
+	// POST /api/webhooks/bounces/:provider; disabled when BounceWebhookToken is empty.
+	// Soft bounces suppress the address for BounceSoftSuppression.
+	BounceWebhookToken    string
+	BounceSoftSuppression time.Duration
+
+	// Service level objectives
+	SLOWeatherLatencyThreshold time.Duration
+	SLOWeatherLatencyTarget    float64
+	SLODeliveryMaxDelay        time.Duration
+	SLODeliveryTarget          float64
+
+	// Scheduler in-memory schedule cache (refreshed via LISTEN/NOTIFY)
+	ScheduleCacheEnabled bool
+	ScheduleCacheMaxAge  time.Duration
+
+	// Encryption of subscriber emails at rest; disabled when no key is set.
+	// Keys are "id:base64(32 bytes)", the first one encrypts.
+	PIIEncryptionKeys []string
+	PIIBlindIndexKey  string
+
+	// HMAC keys signing outbound webhook and event payloads, "id:base64secret[:RFC 3339 expiry]";
+	// signing is disabled when empty
+	EventSigningKeys []string
+
+	// Consumer URL receiving every domain event as a signed POST; events are validated
+	// and dropped when empty
+	EventWebhookURL string
+
+	// Events go through the outbox table and are relayed to EventWebhookURL by every API
+	// replica, retried after EventOutboxRetryBackoff (doubling) while the consumer fails;
+	// when disabled they are POSTed once, in the background
+	EventOutboxEnabled      bool
+	EventOutboxRetryBackoff time.Duration
+
+	// HMAC key (base64, >= 32 bytes) the unsubscribe and manage link tokens of every
+	// subscription are derived from, see package linktoken
+	LinkTokenKey string
+
+	// HMAC key (base64, >= 32 bytes) signing confirm and unsubscribe links; links are
+	// unsigned when empty. Unsigned links are still accepted unless signatures are required.
+	LinkSigningKey         string
+	LinkSignaturesRequired bool
+
+	// Rollout stage of experimental email content, "name=off|beta|on"; unlisted flags are off
+	FeatureFlags []string
+
+	// Chat completions endpoint writing the summaries of daily emails (ai_summaries flag);
+	// fixed-phrase summaries are used when empty or when a call fails or times out
+	SummaryLLMURL     string
+	SummaryLLMAPIKey  string
+	SummaryLLMModel   string
+	SummaryLLMTimeout time.Duration
+	SummaryCacheTTL   time.Duration
+
+	// Lifecycle emails: one-year anniversary and re-engagement of subscribers without opens
+	LifecycleEmailsEnabled bool
+	ReEngagementAfter      time.Duration
+
+	// Confirmation links expire this long after they are sent; 0 never expires
+	ConfirmTokenTTL time.Duration
+
+	// How long an address is not emailed after "this wasn't me" on its confirmation email
+	NotMeSuppressionTTL time.Duration
+
+	// GDPR export/erase links, emailed on DELETE /api/privacy/:email, work this long
+	PrivacyRequestTTL time.Duration
+
+	// Subscriptions expire this long after confirmation (or renewal) and get a renewal
+	// email; 0 never expires
+	SubscriptionTTL time.Duration
+
+	// Subscriptions still unconfirmed this many days after sign-up are deleted; 0 keeps them
+	UnconfirmedRetentionDays int
+
+	// Unsubscribed subscriptions are kept (soft-deleted) this many days, then deleted for
+	// good; 0 keeps them
+	DeletedRetentionDays int
+
+	// Address of the Prometheus metrics endpoint (GET /metrics) of each process, e.g.
+	// ":9090"; empty disables it
+	MetricsAddr string
+
+	// Push metrics instead of (or as well as) serving them: "statsd" or "otlp"; empty
+	// disables pushing. The address is host:port for statsd and the OTLP/HTTP metrics URL
+	// for otlp.
+	MetricsPush         string
+	MetricsPushAddr     string
+	MetricsPushInterval time.Duration
+
+	// Minimum interval between non-transactional emails to the same address, guarding
+	// against batching or scheduling bugs; 0 disables the guard
+	MinEmailInterval time.Duration
+
+	// Notify-on-change subscriptions get an update only when, in one of their cities, the
+	// temperature moved by NotifyChangeTempDelta (°C) or more, the humidity by
+	// NotifyChangeHumidityDelta (points) or more, or the description changed; and at
+	// least once every NotifyChangeMaxSilence
+	NotifyChangeTempDelta     float64
+	NotifyChangeHumidityDelta int
+	NotifyChangeMaxSilence    time.Duration
+
+	// How often the scheduler checks the government warnings feed for the cities of
+	// warning subscriptions
+	WarningsCheckInterval time.Duration
+
+	// Timeout of each post of a weather update to a subscription's Slack webhook
+	SlackPostTimeout time.Duration
+
+	// Last raw provider response per city, kept in Redis for debugging; off by default
+	WeatherRawCacheEnabled  bool
+	WeatherRawCacheTTL      time.Duration
+	WeatherRawCacheMaxBytes int
+
+	// Per-IP rate limits (requests per minute and burst); 0 per minute disables
+	RateLimitWeatherPerMinute   int
+	RateLimitWeatherBurst       int
+	RateLimitSubscribePerMinute int
+	RateLimitSubscribeBurst     int
+
+	// Maximum requests handled at once per route group; 0 disables
+	ConcurrencyLimitWeather   int
+	ConcurrencyLimitSubscribe int
+
+	// CAPTCHA on POST /subscribe ("recaptcha" or "turnstile"); disabled when provider is empty
+	CaptchaProvider string
+	CaptchaSecret   string
+	CaptchaMinScore float64
+
+	// How long responses to POST /subscribe are kept for Idempotency-Key replays
+	IdempotencyTTL time.Duration
+
+	// Load shedding: limits over which low-priority requests are rejected (0 disables
+	// each signal) and the Retry-After given to them
+	OverloadMaxGoroutines   int
+	OverloadMaxSchedulerLag time.Duration
+	OverloadMaxDBWait       time.Duration
+	OverloadRetryAfter      time.Duration
+
+	// CORS; disabled when no origin is allowed
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+
+	// Reverse proxies (IP addresses or CIDRs) whose X-Forwarded-For and X-Real-IP
+	// headers name the client, for rate limits and captcha checks; without any, the
+	// client is the peer address and the headers are ignored
+	TrustedProxies []string
+
+	// Port the API listens on
+	Port string
+
+	// Timeouts of the API's HTTP server, and how long it drains requests in flight on
+	// SIGTERM before closing them
+	HTTPReadTimeout     time.Duration
+	HTTPWriteTimeout    time.Duration
+	HTTPIdleTimeout     time.Duration
+	HTTPShutdownTimeout time.Duration
 }
 
 // Load reads and validates all required environment variables, applying defaults
-// where appropriate. It returns an error if any required variable is missing or malformed.
+// where appropriate. Variables may also come from the YAML or TOML file at CONFIG_FILE
+// (see newSource), the environment taking precedence. It returns an error if any
+// required variable is missing or malformed, or the config file sets an unknown one.
 func Load() (*Config, error) {
-	var err error
+	env, err := newSource(strings.TrimSpace(os.Getenv("CONFIG_FILE")))
+	if err != nil {
+		return nil, err
+	}
 
 	// Postgres settings
-	pgUser := os.Getenv("POSTGRES_USER")
+	pgUser := env.get("POSTGRES_USER")
 	if pgUser == "" {
 		return nil, fmt.Errorf("POSTGRES_USER is required")
 	}
-	pgPass := os.Getenv("POSTGRES_PASSWORD")
+	pgPass := env.get("POSTGRES_PASSWORD")
 	if pgPass == "" {
 		return nil, fmt.Errorf("POSTGRES_PASSWORD is required")
 	}
-	pgDB := os.Getenv("POSTGRES_DB")
+	pgDB := env.get("POSTGRES_DB")
 	if pgDB == "" {
 		return nil, fmt.Errorf("POSTGRES_DB is required")
 	}
-	pgHost := os.Getenv("POSTGRES_HOST")
+	pgHost := env.get("POSTGRES_HOST")
 	if pgHost == "" {
 		pgHost = "db"
 	}
-	pgPortStr := os.Getenv("POSTGRES_PORT")
+	pgPortStr := env.get("POSTGRES_PORT")
 	if pgPortStr == "" {
 		pgPortStr = "5432"
 	}
@@ -65,65 +390,642 @@ func Load() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid POSTGRES_PORT %q: %w", pgPortStr, err)
 	}
-	databaseURL := fmt.Sprintf(
-		"postgres://%s:%s@%s:%d/%s?sslmode=disable",
-		pgUser, pgPass, pgHost, pgPort, pgDB,
-	)
+	// pgxpool connection pool, see repository.Pool
+	dbPoolMaxConns, err := env.intEnv("DB_POOL_MAX_CONNS", 10)
+	if err != nil {
+		return nil, err
+	}
+	dbPoolMinConns, err := env.intEnv("DB_POOL_MIN_CONNS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if dbPoolMaxConns < 1 || dbPoolMinConns < 0 || dbPoolMinConns > dbPoolMaxConns {
+		return nil, fmt.Errorf("DB_POOL_MAX_CONNS must be at least 1 and DB_POOL_MIN_CONNS between 0 and it")
+	}
+	dbPoolMaxConnLifetime, err := env.durationEnv("DB_POOL_MAX_CONN_LIFETIME", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	dbStatementCacheCapacity, err := env.intEnv("DB_STATEMENT_CACHE_CAPACITY", 512)
+	if err != nil {
+		return nil, err
+	}
+	if dbStatementCacheCapacity < 0 {
+		return nil, fmt.Errorf("DB_STATEMENT_CACHE_CAPACITY must not be negative")
+	}
+	dsn := func(host string, port int) string {
+		// only parameters plain pgx understands: Postgres rejects unknown ones
+		url := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable&statement_cache_capacity=%d",
+			pgUser, pgPass, host, port, pgDB, dbStatementCacheCapacity)
+		if dbStatementCacheCapacity == 0 {
+			// without a cache, statements are not prepared at all
+			url += "&default_query_exec_mode=exec"
+		}
+		return url
+	}
+	databaseURL := dsn(pgHost, pgPort)
 
-	// SMTP settings
-	smtpHost := os.Getenv("SMTP_HOST")
-	if smtpHost == "" {
-		return nil, fmt.Errorf("SMTP_HOST is required")
+	// Read replica, with the credentials of the primary; its sessions are read-only so
+	// that a write routed to it by mistake fails instead of diverging
+	var replicaDatabaseURL string
+	replicaPort, err := env.intEnv("POSTGRES_REPLICA_PORT", pgPort)
+	if err != nil {
+		return nil, err
+	}
+	if replicaHost := env.get("POSTGRES_REPLICA_HOST"); replicaHost != "" {
+		replicaDatabaseURL = dsn(replicaHost, replicaPort) + "&default_transaction_read_only=on"
+	}
+	migrateOnStart, err := env.boolEnv("MIGRATE_ON_START", true)
+	if err != nil {
+		return nil, err
 	}
-	smtpPortStr := os.Getenv("SMTP_PORT")
-	if smtpPortStr == "" {
-		return nil, fmt.Errorf("SMTP_PORT is required")
+	dbStartupRetryTimeout, err := env.durationEnv("DB_STARTUP_RETRY_TIMEOUT", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	dbStartupRetryBackoff, err := env.durationEnv("DB_STARTUP_RETRY_BACKOFF", 500*time.Millisecond)
+	if err != nil {
+		return nil, err
 	}
-	smtpPort, err := strconv.Atoi(smtpPortStr)
+	if dbStartupRetryTimeout < 0 || dbStartupRetryBackoff <= 0 {
+		return nil, fmt.Errorf("DB_STARTUP_RETRY_TIMEOUT must be >= 0 and DB_STARTUP_RETRY_BACKOFF > 0")
+	}
+	dbHealthCheckInterval, err := env.durationEnv("DB_HEALTH_CHECK_INTERVAL", 15*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("invalid SMTP_PORT %q: %w", smtpPortStr, err)
+		return nil, err
+	}
+	if dbHealthCheckInterval < 0 {
+		return nil, fmt.Errorf("DB_HEALTH_CHECK_INTERVAL must be >= 0")
 	}
-	smtpUser := os.Getenv("SMTP_USER")
-	if smtpUser == "" {
-		return nil, fmt.Errorf("SMTP_USER is required")
+	dbSlowQueryThreshold, err := env.durationEnv("DB_SLOW_QUERY_THRESHOLD", 500*time.Millisecond)
+	if err != nil {
+		return nil, err
 	}
-	smtpPass := os.Getenv("SMTP_PASS")
-	if smtpPass == "" {
-		return nil, fmt.Errorf("SMTP_PASS is required")
+	if dbSlowQueryThreshold < 0 {
+		return nil, fmt.Errorf("DB_SLOW_QUERY_THRESHOLD must be >= 0")
+	}
+
+	// Email provider: SMTP, or the SendGrid HTTP API where outbound SMTP is blocked
+	emailProvider := strings.ToLower(env.stringEnv("EMAIL_PROVIDER", "smtp"))
+	smtpHost, smtpPortStr := env.get("SMTP_HOST"), env.get("SMTP_PORT")
+	smtpUser, smtpPass := env.get("SMTP_USER"), env.get("SMTP_PASS")
+	sendGridAPIKey := env.get("SENDGRID_API_KEY")
+	smtpFrom := env.get("SMTP_FROM")
+	var smtpPort int
+	switch emailProvider {
+	case "smtp":
+		if smtpHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required")
+		}
+		if smtpPortStr == "" {
+			return nil, fmt.Errorf("SMTP_PORT is required")
+		}
+		smtpPort, err = strconv.Atoi(smtpPortStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT %q: %w", smtpPortStr, err)
+		}
+		if smtpUser == "" {
+			return nil, fmt.Errorf("SMTP_USER is required")
+		}
+		if smtpPass == "" {
+			return nil, fmt.Errorf("SMTP_PASS is required")
+		}
+	case "sendgrid":
+		if sendGridAPIKey == "" {
+			return nil, fmt.Errorf("SENDGRID_API_KEY is required when EMAIL_PROVIDER is sendgrid")
+		}
+		if smtpFrom == "" {
+			return nil, fmt.Errorf("SMTP_FROM is required when EMAIL_PROVIDER is sendgrid")
+		}
+	default:
+		return nil, fmt.Errorf("invalid EMAIL_PROVIDER %q: must be smtp or sendgrid", emailProvider)
 	}
-	smtpFrom := os.Getenv("SMTP_FROM")
 	if smtpFrom == "" {
 		// default to the authenticated user
 		smtpFrom = smtpUser
 	}
 
 	// Weather API keys. Might be present only one of them.
-	weatherApiComKey := os.Getenv("WEATHERAPI_COM_API_KEY")
-	openWeatherMapOrgKey := os.Getenv("OPENWEATHERMAP_ORG_API_KEY")
+	weatherApiComKey := env.get("WEATHERAPI_COM_API_KEY")
+	openWeatherMapOrgKey := env.get("OPENWEATHERMAP_ORG_API_KEY")
 
 	// Redis settings
-	redisPass := os.Getenv("REDIS_PASSWORD")
+	redisPass := env.get("REDIS_PASSWORD")
 	if redisPass == "" {
 		return nil, fmt.Errorf("REDIS_PASSWORD is required")
 	}
-	redisAddr := os.Getenv("REDIS_ADDR")
+	redisAddr := env.get("REDIS_ADDR")
 	if redisAddr == "" {
 		redisAddr = "redis:6379"
 	}
+	cacheVersion, err := env.intEnv("CACHE_VERSION", 1)
+	if err != nil {
+		return nil, err
+	}
+	if cacheVersion < 1 {
+		return nil, fmt.Errorf("CACHE_VERSION must be positive")
+	}
 
 	// Base URL for constructing confirmation/unsubscribe links
-	baseURL := os.Getenv("BASE_URL")
+	baseURL := env.get("BASE_URL")
 	if baseURL == "" {
 		return nil, fmt.Errorf("BASE_URL is required")
 	}
+	linkTokenKey := env.get("LINK_TOKEN_KEY")
+	if linkTokenKey == "" {
+		return nil, fmt.Errorf("LINK_TOKEN_KEY is required: generate one with `openssl rand -base64 32` and keep it")
+	}
 
-	return &Config{
-		PostgresUser:     pgUser,
-		PostgresPassword: pgPass,
-		PostgresDB:       pgDB,
-		PostgresHost:     pgHost,
-		PostgresPort:     pgPort,
-		DatabaseURL:      databaseURL,
+	// Optional path prefix of every route; BASE_URL may include it or not
+	pathPrefix := strings.Trim(env.get("PATH_PREFIX"), "/")
+	if pathPrefix != "" {
+		pathPrefix = "/" + pathPrefix
+		if strings.ContainsAny(pathPrefix, "?#%") {
+			return nil, fmt.Errorf("PATH_PREFIX must be a plain path, e.g. /weather-api")
+		}
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if !strings.HasSuffix(baseURL, pathPrefix) {
+		baseURL += pathPrefix
+	}
+
+	// Admin credentials are optional, but must be set together
+	adminUser := env.get("ADMIN_USER")
+	adminPass := env.get("ADMIN_PASSWORD")
+	if (adminUser == "") != (adminPass == "") {
+		return nil, fmt.Errorf("ADMIN_USER and ADMIN_PASSWORD must be set together")
+	}
+
+	adminJWTSecret := env.get("ADMIN_JWT_SECRET")
+	adminJWTTTL, err := env.durationEnv("ADMIN_JWT_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	adminStatsCacheTTL, err := env.durationEnv("ADMIN_STATS_CACHE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if adminStatsCacheTTL < 0 {
+		return nil, fmt.Errorf("ADMIN_STATS_CACHE_TTL must be >= 0")
+	}
+
+	// Provider pricing for the usage cost report. Defaults to free tier.
+	weatherApiComPrice, err := env.floatEnv("WEATHERAPI_COM_PRICE_PER_CALL", 0)
+	if err != nil {
+		return nil, err
+	}
+	openWeatherMapOrgPrice, err := env.floatEnv("OPENWEATHERMAP_ORG_PRICE_PER_CALL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// SLOs: 99% of /api/weather under 800ms, 99.5% of scheduled emails within 5 minutes of slot
+	sloLatencyThreshold, err := env.durationEnv("SLO_WEATHER_LATENCY_THRESHOLD", 800*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	sloLatencyTarget, err := env.ratioEnv("SLO_WEATHER_LATENCY_TARGET", 0.99)
+	if err != nil {
+		return nil, err
+	}
+	sloDeliveryMaxDelay, err := env.durationEnv("SLO_DELIVERY_MAX_DELAY", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	sloDeliveryTarget, err := env.ratioEnv("SLO_DELIVERY_TARGET", 0.995)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scheduler schedule cache
+	scheduleCacheEnabled, err := env.boolEnv("SCHEDULE_CACHE_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	scheduleCacheMaxAge, err := env.durationEnv("SCHEDULE_CACHE_MAX_AGE", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lifecycle emails
+	linkSignaturesRequired, err := env.boolEnv("LINK_SIGNATURES_REQUIRED", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Event outbox
+	eventOutboxEnabled, err := env.boolEnv("EVENT_OUTBOX_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	eventOutboxRetryBackoff, err := env.durationEnv("EVENT_OUTBOX_RETRY_BACKOFF", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if eventOutboxRetryBackoff <= 0 {
+		return nil, fmt.Errorf("EVENT_OUTBOX_RETRY_BACKOFF must be > 0")
+	}
+
+	lifecycleEmailsEnabled, err := env.boolEnv("LIFECYCLE_EMAILS_ENABLED", true)
+	if err != nil {
+		return nil, err
+	}
+	reEngagementAfter, err := env.durationEnv("REENGAGEMENT_AFTER", 90*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Confirmation links are valid for 48 hours unless configured otherwise
+	confirmTokenTTL, err := env.durationEnv("CONFIRM_TOKEN_TTL", 48*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	notMeSuppressionTTL, err := env.durationEnv("NOT_ME_SUPPRESSION_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	privacyRequestTTL, err := env.durationEnv("PRIVACY_REQUEST_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if privacyRequestTTL <= 0 {
+		return nil, fmt.Errorf("PRIVACY_REQUEST_TTL must be > 0")
+	}
+
+	// Subscription expiry
+	subscriptionTTL, err := env.durationEnv("SUBSCRIPTION_TTL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unconfirmed subscription cleanup
+	unconfirmedRetentionDays, err := env.intEnv("UNCONFIRMED_RETENTION_DAYS", 7)
+	if err != nil {
+		return nil, err
+	}
+
+	// Purge of unsubscribed subscriptions
+	deletedRetentionDays, err := env.intEnv("DELETED_RETENTION_DAYS", 90)
+	if err != nil {
+		return nil, err
+	}
+	if deletedRetentionDays < 0 {
+		return nil, fmt.Errorf("DELETED_RETENTION_DAYS must be >= 0")
+	}
+
+	// Per-recipient send guard
+	minEmailInterval, err := env.durationEnv("MIN_EMAIL_INTERVAL", 0)
+	if err != nil {
+		return nil, err
+	}
+	notifyChangeTempDelta, err := env.floatEnv("NOTIFY_CHANGE_TEMP_DELTA", 2)
+	if err != nil {
+		return nil, err
+	}
+	notifyChangeHumidityDelta, err := env.intEnv("NOTIFY_CHANGE_HUMIDITY_DELTA", 10)
+	if err != nil {
+		return nil, err
+	}
+	notifyChangeMaxSilence, err := env.durationEnv("NOTIFY_CHANGE_MAX_SILENCE", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	warningsCheckInterval, err := env.durationEnv("WARNINGS_CHECK_INTERVAL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	slackPostTimeout, err := env.durationEnv("SLACK_POST_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// SMS
+	twilioAccountSID := env.get("TWILIO_ACCOUNT_SID")
+	if twilioAccountSID != "" && (env.get("TWILIO_AUTH_TOKEN") == "" || env.get("TWILIO_FROM") == "") {
+		return nil, fmt.Errorf("TWILIO_AUTH_TOKEN and TWILIO_FROM are required when TWILIO_ACCOUNT_SID is set")
+	}
+	smsCodeTTL, err := env.durationEnv("SMS_CODE_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// Synthetic monitoring
+	syntheticEmail := strings.TrimSpace(env.get("SYNTHETIC_EMAIL"))
+	syntheticWebhookToken := env.get("SYNTHETIC_WEBHOOK_TOKEN")
+	if syntheticEmail != "" && syntheticWebhookToken == "" {
+		return nil, fmt.Errorf("SYNTHETIC_WEBHOOK_TOKEN must be set when SYNTHETIC_EMAIL is set")
+	}
+	syntheticSLO, err := env.durationEnv("SYNTHETIC_SLO", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bounce notifications
+	bounceSoftSuppression, err := env.durationEnv("BOUNCE_SOFT_SUPPRESSION", 72*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Weather provider ranking
+	weatherPreferenceGrace, err := env.durationEnv("WEATHER_PREFERENCE_GRACE", 0)
+	if err != nil {
+		return nil, err
+	}
+	weatherPrefetchConcurrency, err := env.intEnv("WEATHER_PREFETCH_CONCURRENCY", 8)
+	if err != nil {
+		return nil, err
+	}
+	if weatherPrefetchConcurrency < 1 {
+		return nil, fmt.Errorf("WEATHER_PREFETCH_CONCURRENCY must be at least 1")
+	}
+
+	// Raw provider response cache
+	weatherRawCacheEnabled, err := env.boolEnv("WEATHER_RAW_CACHE_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	weatherRawCacheTTL, err := env.durationEnv("WEATHER_RAW_CACHE_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	weatherRawCacheMaxBytes, err := env.intEnv("WEATHER_RAW_CACHE_MAX_BYTES", 16*1024)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rate limits
+	rateLimitWeatherPerMinute, err := env.intEnv("RATE_LIMIT_WEATHER_PER_MINUTE", 60)
+	if err != nil {
+		return nil, err
+	}
+	rateLimitWeatherBurst, err := env.intEnv("RATE_LIMIT_WEATHER_BURST", 30)
+	if err != nil {
+		return nil, err
+	}
+	rateLimitSubscribePerMinute, err := env.intEnv("RATE_LIMIT_SUBSCRIBE_PER_MINUTE", 5)
+	if err != nil {
+		return nil, err
+	}
+	rateLimitSubscribeBurst, err := env.intEnv("RATE_LIMIT_SUBSCRIBE_BURST", 3)
+	if err != nil {
+		return nil, err
+	}
+
+	// Concurrency limits
+	concurrencyLimitWeather, err := env.intEnv("CONCURRENCY_LIMIT_WEATHER", 100)
+	if err != nil {
+		return nil, err
+	}
+	concurrencyLimitSubscribe, err := env.intEnv("CONCURRENCY_LIMIT_SUBSCRIBE", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	smtpPoolSize, err := env.intEnv("SMTP_POOL_SIZE", 2)
+	if err != nil {
+		return nil, err
+	}
+	if smtpPoolSize < 0 {
+		return nil, fmt.Errorf("SMTP_POOL_SIZE must not be negative")
+	}
+	smtpIdleTimeout, err := env.durationEnv("SMTP_IDLE_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	smtpCheckAlignment, err := env.boolEnv("SMTP_CHECK_ALIGNMENT", true)
+	if err != nil {
+		return nil, err
+	}
+	emailTemplatesStrict, err := env.boolEnv("EMAIL_TEMPLATES_STRICT", false)
+	if err != nil {
+		return nil, err
+	}
+	emailSendRetries, err := env.intEnv("EMAIL_SEND_RETRIES", 2)
+	if err != nil {
+		return nil, err
+	}
+	if emailSendRetries < 0 {
+		return nil, fmt.Errorf("EMAIL_SEND_RETRIES must not be negative")
+	}
+	emailRetryBackoff, err := env.durationEnv("EMAIL_RETRY_BACKOFF", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// Email queue
+	emailQueueEnabled, err := env.boolEnv("EMAIL_QUEUE_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	emailQueueMaxAttempts, err := env.intEnv("EMAIL_QUEUE_MAX_ATTEMPTS", 5)
+	if err != nil {
+		return nil, err
+	}
+	if emailQueueMaxAttempts < 1 {
+		return nil, fmt.Errorf("EMAIL_QUEUE_MAX_ATTEMPTS must be at least 1")
+	}
+	emailQueueRetryBackoff, err := env.durationEnv("EMAIL_QUEUE_RETRY_BACKOFF", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	emailQueueRedisAddr := env.get("EMAIL_QUEUE_REDIS_ADDR")
+	piiEncryptionKeys := env.listEnv("PII_ENCRYPTION_KEYS", nil)
+	if emailQueueEnabled {
+		if emailQueueRedisAddr == "" {
+			return nil, fmt.Errorf("EMAIL_QUEUE_REDIS_ADDR is required with EMAIL_QUEUE_ENABLED")
+		}
+		if len(piiEncryptionKeys) == 0 {
+			return nil, fmt.Errorf("PII_ENCRYPTION_KEYS is required with EMAIL_QUEUE_ENABLED")
+		}
+	}
+	workerID := env.get("WORKER_ID")
+	if workerID == "" {
+		workerID, _ = os.Hostname()
+	}
+	schedulerInstanceID := env.get("SCHEDULER_INSTANCE_ID")
+	if schedulerInstanceID == "" {
+		schedulerInstanceID, _ = os.Hostname()
+	}
+	schedulerLockTTL, err := env.durationEnv("SCHEDULER_LOCK_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	schedulerLeaderElection, err := env.boolEnv("SCHEDULER_LEADER_ELECTION", false)
+	if err != nil {
+		return nil, err
+	}
+	schedulerLeaderLease, err := env.durationEnv("SCHEDULER_LEADER_LEASE", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if schedulerLeaderLease < 3*time.Second {
+		return nil, fmt.Errorf("SCHEDULER_LEADER_LEASE must be at least 3s")
+	}
+	schedulerTickOffset, err := env.durationEnv("SCHEDULER_TICK_OFFSET", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if schedulerTickOffset < 0 {
+		return nil, fmt.Errorf("SCHEDULER_TICK_OFFSET must not be negative")
+	}
+	schedulerCatchUpHorizon, err := env.durationEnv("SCHEDULER_CATCHUP_HORIZON", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	schedulerBatchPageSize, err := env.intEnv("SCHEDULER_BATCH_PAGE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+	if schedulerBatchPageSize < 0 {
+		return nil, fmt.Errorf("SCHEDULER_BATCH_PAGE_SIZE must not be negative")
+	}
+	schedulerBatchStream, err := env.boolEnv("SCHEDULER_BATCH_STREAM", false)
+	if err != nil {
+		return nil, err
+	}
+	if schedulerBatchStream && schedulerBatchPageSize == 0 {
+		return nil, fmt.Errorf("SCHEDULER_BATCH_STREAM needs a positive SCHEDULER_BATCH_PAGE_SIZE")
+	}
+	sendWorkers, err := env.intEnv("SEND_WORKERS", 2)
+	if err != nil {
+		return nil, err
+	}
+	if sendWorkers < 1 {
+		return nil, fmt.Errorf("SEND_WORKERS must be at least 1")
+	}
+	schedulerSendWorkers, err := env.intEnv("SCHEDULER_SEND_WORKERS", 0)
+	if err != nil {
+		return nil, err
+	}
+	if schedulerSendWorkers < 0 {
+		return nil, fmt.Errorf("SCHEDULER_SEND_WORKERS must not be negative")
+	}
+	sendJobLease, err := env.durationEnv("SEND_JOB_LEASE", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	sendJobMaxAttempts, err := env.intEnv("SEND_JOB_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return nil, err
+	}
+	if sendJobMaxAttempts < 1 {
+		return nil, fmt.Errorf("SEND_JOB_MAX_ATTEMPTS must be at least 1")
+	}
+	sendJobRetryBackoff, err := env.durationEnv("SEND_JOB_RETRY_BACKOFF", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	sendClaimLease, err := env.durationEnv("SEND_CLAIM_LEASE", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	if sendClaimLease < 0 {
+		return nil, fmt.Errorf("SEND_CLAIM_LEASE must be >= 0")
+	}
+	deadLetterAfter, err := env.intEnv("DEAD_LETTER_AFTER", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	idempotencyTTL, err := env.durationEnv("IDEMPOTENCY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load shedding, on by default with limits only reached under real overload
+	overloadMaxGoroutines, err := env.intEnv("OVERLOAD_MAX_GOROUTINES", 10000)
+	if err != nil {
+		return nil, err
+	}
+	overloadMaxSchedulerLag, err := env.durationEnv("OVERLOAD_MAX_SCHEDULER_LAG", 250*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	overloadMaxDBWait, err := env.durationEnv("OVERLOAD_MAX_DB_WAIT", 500*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	overloadRetryAfter, err := env.durationEnv("OVERLOAD_RETRY_AFTER", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// HTTP server
+	httpReadTimeout, err := env.durationEnv("HTTP_READ_TIMEOUT", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	httpWriteTimeout, err := env.durationEnv("HTTP_WRITE_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	httpIdleTimeout, err := env.durationEnv("HTTP_IDLE_TIMEOUT", 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	httpShutdownTimeout, err := env.durationEnv("HTTP_SHUTDOWN_TIMEOUT", 20*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// Weather summaries
+	summaryLLMURL := env.get("SUMMARY_LLM_URL")
+	summaryLLMModel := env.get("SUMMARY_LLM_MODEL")
+	if summaryLLMURL != "" && summaryLLMModel == "" {
+		return nil, fmt.Errorf("SUMMARY_LLM_MODEL must be set when SUMMARY_LLM_URL is set")
+	}
+	summaryLLMTimeout, err := env.durationEnv("SUMMARY_LLM_TIMEOUT", 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	summaryCacheTTL, err := env.durationEnv("SUMMARY_CACHE_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Metrics push
+	metricsPush := strings.ToLower(env.get("METRICS_PUSH"))
+	metricsPushAddr := env.get("METRICS_PUSH_ADDR")
+	if metricsPush != "" && metricsPushAddr == "" {
+		return nil, fmt.Errorf("METRICS_PUSH_ADDR must be set when METRICS_PUSH is set")
+	}
+	metricsPushInterval, err := env.durationEnv("METRICS_PUSH_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// CAPTCHA
+	captchaProvider := strings.ToLower(env.get("CAPTCHA_PROVIDER"))
+	captchaSecret := env.get("CAPTCHA_SECRET")
+	if captchaProvider != "" && captchaSecret == "" {
+		return nil, fmt.Errorf("CAPTCHA_SECRET must be set when CAPTCHA_PROVIDER is set")
+	}
+	captchaMinScore, err := env.floatEnv("CAPTCHA_MIN_SCORE", 0.5)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		PostgresUser:       pgUser,
+		PostgresPassword:   pgPass,
+		PostgresDB:         pgDB,
+		PostgresHost:       pgHost,
+		PostgresPort:       pgPort,
+		DatabaseURL:        databaseURL,
+		ReplicaDatabaseURL: replicaDatabaseURL,
+		MigrateOnStart:     migrateOnStart,
+
+		DBPoolMaxConns:        dbPoolMaxConns,
+		DBPoolMinConns:        dbPoolMinConns,
+		DBPoolMaxConnLifetime: dbPoolMaxConnLifetime,
+
+		DBStartupRetryTimeout: dbStartupRetryTimeout,
+		DBStartupRetryBackoff: dbStartupRetryBackoff,
+		DBHealthCheckInterval: dbHealthCheckInterval,
+		DBSlowQueryThreshold:  dbSlowQueryThreshold,
+
+		EmailProvider: emailProvider,
 
 		SMTPHost: smtpHost,
 		SMTPPort: smtpPort,
@@ -131,12 +1033,272 @@ func Load() (*Config, error) {
 		SMTPPass: smtpPass,
 		SMTPFrom: smtpFrom,
 
+		SMTPPoolSize:    smtpPoolSize,
+		SMTPIdleTimeout: smtpIdleTimeout,
+
+		SendGridAPIKey: sendGridAPIKey,
+		SendGridAPIURL: env.stringEnv("SENDGRID_API_URL", "https://api.sendgrid.com/v3/mail/send"),
+
+		TwilioAccountSID: twilioAccountSID,
+		TwilioAuthToken:  env.get("TWILIO_AUTH_TOKEN"),
+		TwilioFrom:       env.get("TWILIO_FROM"),
+		TwilioAPIURL:     env.stringEnv("TWILIO_API_URL", "https://api.twilio.com"),
+		SMSCodeTTL:       smsCodeTTL,
+
+		SMTPCheckAlignment:   smtpCheckAlignment,
+		EmailTemplatesStrict: emailTemplatesStrict,
+		EmailSendRetries:     emailSendRetries,
+		EmailRetryBackoff:    emailRetryBackoff,
+
+		EmailQueueEnabled:       emailQueueEnabled,
+		EmailQueueMaxAttempts:   emailQueueMaxAttempts,
+		EmailQueueRetryBackoff:  emailQueueRetryBackoff,
+		EmailQueueRedisAddr:     emailQueueRedisAddr,
+		EmailQueueRedisPassword: env.get("EMAIL_QUEUE_REDIS_PASSWORD"),
+		WorkerID:                workerID,
+
+		SchedulerInstanceID: schedulerInstanceID,
+		SchedulerLockTTL:    schedulerLockTTL,
+
+		SchedulerLeaderElection: schedulerLeaderElection,
+		SchedulerLeaderLease:    schedulerLeaderLease,
+		SchedulerCronSpec:       env.stringEnv("SCHEDULER_CRON_SPEC", "* * * * *"),
+		SchedulerTickOffset:     schedulerTickOffset,
+		SchedulerCatchUpHorizon: schedulerCatchUpHorizon,
+		SchedulerBatchPageSize:  schedulerBatchPageSize,
+		SchedulerBatchStream:    schedulerBatchStream,
+
+		SendWorkers:          sendWorkers,
+		SchedulerSendWorkers: schedulerSendWorkers,
+		SendJobLease:         sendJobLease,
+		SendJobMaxAttempts:   sendJobMaxAttempts,
+		SendJobRetryBackoff:  sendJobRetryBackoff,
+		SendClaimLease:       sendClaimLease,
+		DeadLetterAfter:      deadLetterAfter,
+
 		WeatherAPIComKey:     weatherApiComKey,
 		OpenWeatherMapOrgKey: openWeatherMapOrgKey,
 
 		RedisPassword: redisPass,
 		RedisAddr:     redisAddr,
+		CacheVersion:  cacheVersion,
+
+		BaseURL:    baseURL,
+		PathPrefix: pathPrefix,
+
+		Tenants:       env.listEnv("TENANTS", nil),
+		TenantAPIKeys: env.listEnv("TENANT_API_KEYS", nil),
+
+		AdminUser:      adminUser,
+		AdminPassword:  adminPass,
+		AdminJWTSecret: adminJWTSecret,
+		AdminJWTTTL:    adminJWTTTL,
+
+		AdminStatsCacheTTL: adminStatsCacheTTL,
+
+		WeatherProviderPreference:  env.listEnv("WEATHER_PROVIDER_PREFERENCE", nil),
+		WeatherPreferenceGrace:     weatherPreferenceGrace,
+		WeatherPrefetchConcurrency: weatherPrefetchConcurrency,
+
+		WeatherAPIComPricePerCall:     weatherApiComPrice,
+		OpenWeatherMapOrgPricePerCall: openWeatherMapOrgPrice,
+		WeatherAPIComAttribution:      env.stringEnv("WEATHERAPI_COM_ATTRIBUTION", "Powered by WeatherAPI.com"),
+		OpenWeatherMapOrgAttribution:  env.stringEnv("OPENWEATHERMAP_ORG_ATTRIBUTION", "Weather data provided by OpenWeather"),
+
+		OperatorEmail: env.get("OPERATOR_EMAIL"),
+
+		SyntheticEmail:        syntheticEmail,
+		SyntheticCity:         env.stringEnv("SYNTHETIC_CITY", "Kyiv"),
+		SyntheticSLO:          syntheticSLO,
+		SyntheticWebhookToken: syntheticWebhookToken,
+		AlertWebhookURL:       env.stringEnv("ALERT_WEBHOOK_URL", ""),
+
+		BounceWebhookToken:    env.get("BOUNCE_WEBHOOK_TOKEN"),
+		BounceSoftSuppression: bounceSoftSuppression,
+
+		SLOWeatherLatencyThreshold: sloLatencyThreshold,
+		SLOWeatherLatencyTarget:    sloLatencyTarget,
+		SLODeliveryMaxDelay:        sloDeliveryMaxDelay,
+		SLODeliveryTarget:          sloDeliveryTarget,
+
+		ScheduleCacheEnabled: scheduleCacheEnabled,
+		ScheduleCacheMaxAge:  scheduleCacheMaxAge,
+
+		PIIEncryptionKeys: piiEncryptionKeys,
+		PIIBlindIndexKey:  env.get("PII_BLIND_INDEX_KEY"),
+
+		EventSigningKeys: env.listEnv("EVENT_SIGNING_KEYS", nil),
+		EventWebhookURL:  env.stringEnv("EVENT_WEBHOOK_URL", ""),
+
+		EventOutboxEnabled:      eventOutboxEnabled,
+		EventOutboxRetryBackoff: eventOutboxRetryBackoff,
+
+		LinkTokenKey: linkTokenKey,
+
+		LinkSigningKey:         env.get("LINK_SIGNING_KEY"),
+		LinkSignaturesRequired: linkSignaturesRequired,
+
+		FeatureFlags: env.listEnv("FEATURE_FLAGS", nil),
+
+		SummaryLLMURL:     summaryLLMURL,
+		SummaryLLMAPIKey:  env.get("SUMMARY_LLM_API_KEY"),
+		SummaryLLMModel:   summaryLLMModel,
+		SummaryLLMTimeout: summaryLLMTimeout,
+		SummaryCacheTTL:   summaryCacheTTL,
 
-		BaseURL: baseURL,
-	}, nil
+		LifecycleEmailsEnabled:   lifecycleEmailsEnabled,
+		ReEngagementAfter:        reEngagementAfter,
+		ConfirmTokenTTL:          confirmTokenTTL,
+		NotMeSuppressionTTL:      notMeSuppressionTTL,
+		PrivacyRequestTTL:        privacyRequestTTL,
+		SubscriptionTTL:          subscriptionTTL,
+		UnconfirmedRetentionDays: unconfirmedRetentionDays,
+		DeletedRetentionDays:     deletedRetentionDays,
+		MetricsAddr:              env.get("METRICS_ADDR"),
+		MetricsPush:              metricsPush,
+		MetricsPushAddr:          metricsPushAddr,
+		MetricsPushInterval:      metricsPushInterval,
+		MinEmailInterval:         minEmailInterval,
+
+		NotifyChangeTempDelta:     notifyChangeTempDelta,
+		NotifyChangeHumidityDelta: notifyChangeHumidityDelta,
+		NotifyChangeMaxSilence:    notifyChangeMaxSilence,
+		WarningsCheckInterval:     warningsCheckInterval,
+		SlackPostTimeout:          slackPostTimeout,
+
+		WeatherRawCacheEnabled:  weatherRawCacheEnabled,
+		WeatherRawCacheTTL:      weatherRawCacheTTL,
+		WeatherRawCacheMaxBytes: weatherRawCacheMaxBytes,
+
+		RateLimitWeatherPerMinute:   rateLimitWeatherPerMinute,
+		RateLimitWeatherBurst:       rateLimitWeatherBurst,
+		RateLimitSubscribePerMinute: rateLimitSubscribePerMinute,
+		RateLimitSubscribeBurst:     rateLimitSubscribeBurst,
+
+		ConcurrencyLimitWeather:   concurrencyLimitWeather,
+		ConcurrencyLimitSubscribe: concurrencyLimitSubscribe,
+
+		CaptchaProvider: captchaProvider,
+		CaptchaSecret:   captchaSecret,
+		CaptchaMinScore: captchaMinScore,
+
+		IdempotencyTTL: idempotencyTTL,
+
+		OverloadMaxGoroutines:   overloadMaxGoroutines,
+		OverloadMaxSchedulerLag: overloadMaxSchedulerLag,
+		OverloadMaxDBWait:       overloadMaxDBWait,
+		OverloadRetryAfter:      overloadRetryAfter,
+
+		CORSAllowedOrigins: env.listEnv("CORS_ALLOWED_ORIGINS", nil),
+		CORSAllowedMethods: env.listEnv("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PATCH", "OPTIONS"}),
+		CORSAllowedHeaders: env.listEnv("CORS_ALLOWED_HEADERS", []string{"Content-Type", "Idempotency-Key"}),
+
+		TrustedProxies: env.listEnv("TRUSTED_PROXIES", nil),
+
+		Port:                env.stringEnv("PORT", "8080"),
+		HTTPReadTimeout:     httpReadTimeout,
+		HTTPWriteTimeout:    httpWriteTimeout,
+		HTTPIdleTimeout:     httpIdleTimeout,
+		HTTPShutdownTimeout: httpShutdownTimeout,
+	}
+	if err := env.checkUnread(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// floatEnv parses an optional float variable, returning def when it is unset.
+func (env *source) floatEnv(key string, def float64) (float64, error) {
+	raw := env.get(key)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", key, raw)
+	}
+	return v, nil
+}
+
+// ratioEnv parses an optional fraction in (0, 1], returning def when it is unset.
+func (env *source) ratioEnv(key string, def float64) (float64, error) {
+	v, err := env.floatEnv(key, def)
+	if err != nil {
+		return 0, err
+	}
+	if v <= 0 || v > 1 {
+		return 0, fmt.Errorf("invalid %s %v: must be in (0, 1]", key, v)
+	}
+	return v, nil
+}
+
+// durationEnv parses an optional positive duration (e.g. "800ms"), returning def when it is unset.
+func (env *source) durationEnv(key string, def time.Duration) (time.Duration, error) {
+	raw := env.get(key)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", key, raw)
+	}
+	return v, nil
+}
+
+// boolEnv parses an optional boolean ("true", "false", "1", "0", ...), returning def when it is unset.
+func (env *source) boolEnv(key string, def bool) (bool, error) {
+	raw := env.get(key)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	return v, nil
+}
+
+// intEnv parses an optional non-negative integer, returning def when it is unset.
+func (env *source) intEnv(key string, def int) (int, error) {
+	raw := env.get(key)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, raw, err)
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", key, raw)
+	}
+	return v, nil
+}
+
+// stringEnv returns the trimmed value of key, or def when it is unset or blank.
+func (env *source) stringEnv(key, def string) string {
+	if v := strings.TrimSpace(env.get(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// listEnv parses an optional comma-separated list, returning def when it is unset.
+func (env *source) listEnv(key string, def []string) []string {
+	raw := env.get(key)
+	if raw == "" {
+		return def
+	}
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
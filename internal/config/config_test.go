@@ -0,0 +1,295 @@
+package config
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// setRequiredEnv sets the variables Load cannot do without.
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	for k, v := range map[string]string{
+		"POSTGRES_USER":     "weatherapp",
+		"POSTGRES_PASSWORD": "secret",
+		"POSTGRES_DB":       "weatherapp_db",
+		"SMTP_HOST":         "smtp.example.com",
+		"SMTP_PORT":         "587",
+		"SMTP_USER":         "user",
+		"SMTP_PASS":         "pass",
+		"REDIS_PASSWORD":    "secret",
+		"BASE_URL":          "https://api.example.com",
+		"LINK_TOKEN_KEY":    "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=",
+	} {
+		t.Setenv(k, v)
+	}
+}
+
+// fakePostgres accepts connections like Postgres does as far as the startup message:
+// a session asking for a runtime parameter Postgres does not know is refused.
+func fakePostgres(t *testing.T) (host string, port int) {
+	t.Helper()
+	known := map[string]bool{"user": true, "database": true, "application_name": true,
+		"client_encoding": true, "default_transaction_read_only": true}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				backend := pgproto3.NewBackend(conn, conn)
+				msg, err := backend.ReceiveStartupMessage()
+				if err != nil {
+					return
+				}
+				startup, ok := msg.(*pgproto3.StartupMessage)
+				if !ok {
+					return
+				}
+				for name := range startup.Parameters {
+					if !known[name] {
+						backend.Send(&pgproto3.ErrorResponse{Severity: "FATAL", Code: "42704",
+							Message: "unrecognized configuration parameter \"" + name + "\""})
+						backend.Flush()
+						return
+					}
+				}
+				backend.Send(&pgproto3.AuthenticationOk{})
+				backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+				backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+				backend.Flush()
+				backend.Receive() // Terminate
+			}()
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestLoad_DatabaseURLsDialWithPlainPgx(t *testing.T) {
+	host, port := fakePostgres(t)
+	for _, cacheCapacity := range []string{"512", "0"} {
+		setRequiredEnv(t)
+		t.Setenv("POSTGRES_HOST", host)
+		t.Setenv("POSTGRES_PORT", strconv.Itoa(port))
+		t.Setenv("POSTGRES_REPLICA_HOST", host)
+		t.Setenv("DB_POOL_MAX_CONNS", "20")
+		t.Setenv("DB_POOL_MIN_CONNS", "2")
+		t.Setenv("DB_POOL_MAX_CONN_LIFETIME", "10m")
+		t.Setenv("DB_STATEMENT_CACHE_CAPACITY", cacheCapacity)
+
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if cfg.DBPoolMaxConns != 20 || cfg.DBPoolMinConns != 2 || cfg.DBPoolMaxConnLifetime != 10*time.Minute {
+			t.Errorf("pool settings = %d, %d, %s", cfg.DBPoolMaxConns, cfg.DBPoolMinConns, cfg.DBPoolMaxConnLifetime)
+		}
+		// the LISTEN connection of the scheduler dials DatabaseURL with plain pgx
+		for _, dsn := range []string{cfg.DatabaseURL, cfg.ReplicaDatabaseURL} {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			conn, err := pgx.Connect(ctx, dsn)
+			if err != nil {
+				t.Errorf("pgx.Connect() with statement cache %s: %v", cacheCapacity, err)
+			} else {
+				conn.Close(ctx)
+			}
+			cancel()
+		}
+	}
+}
+
+// unsetEnv unsets key for the test, restoring it afterwards.
+func unsetEnv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+// writeFile writes a config file named name and points CONFIG_FILE at it.
+func writeFile(t *testing.T, name, body string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+func TestLoad_ConfigFile(t *testing.T) {
+	for _, tc := range []struct{ name, body string }{
+		{"config.yaml", `
+postgres:
+  user: weatherapp
+  password: secret
+  db: weatherapp_db
+smtp: {host: smtp.example.com, port: 2525, user: user, pass: pass}
+redis_password: secret
+base_url: https://api.example.com
+link_token_key: AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=
+http:
+  read_timeout: 20s
+cors_allowed_origins: [https://a.example.com, https://b.example.com]
+synthetic_city: Lviv
+port: 9090
+`},
+		{"config.toml", `
+redis_password = "secret"
+base_url = "https://api.example.com"
+link_token_key = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+cors_allowed_origins = ["https://a.example.com", "https://b.example.com"]
+synthetic_city = "Lviv"
+port = 9090
+
+[postgres]
+user = "weatherapp"
+password = "secret"
+db = "weatherapp_db"
+
+[smtp]
+host = "smtp.example.com"
+port = 2525
+user = "user"
+pass = "pass"
+
+[http]
+read_timeout = "20s"
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, k := range []string{"POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB", "SMTP_HOST", "SMTP_USER",
+				"SMTP_PASS", "REDIS_PASSWORD", "BASE_URL", "LINK_TOKEN_KEY", "HTTP_READ_TIMEOUT", "CORS_ALLOWED_ORIGINS", "PORT"} {
+				unsetEnv(t, k)
+			}
+			writeFile(t, tc.name, tc.body)
+			t.Setenv("SMTP_PORT", "587")   // the environment wins over the file
+			t.Setenv("SYNTHETIC_CITY", "") // but not when set to nothing, as compose's ${X:-} does
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.PostgresUser != "weatherapp" || cfg.SMTPHost != "smtp.example.com" || cfg.HTTPReadTimeout != 20*time.Second {
+				t.Errorf("file settings not applied: %q, %q, %s", cfg.PostgresUser, cfg.SMTPHost, cfg.HTTPReadTimeout)
+			}
+			if got := strings.Join(cfg.CORSAllowedOrigins, " "); got != "https://a.example.com https://b.example.com" {
+				t.Errorf("CORSAllowedOrigins = %q", got)
+			}
+			if cfg.Port != "9090" {
+				t.Errorf("Port = %q, want 9090", cfg.Port)
+			}
+			if cfg.SMTPPort != 587 {
+				t.Errorf("SMTPPort = %d, want the environment's 587", cfg.SMTPPort)
+			}
+			if cfg.SyntheticCity != "Lviv" {
+				t.Errorf("SyntheticCity = %q, want the file's: SYNTHETIC_CITY is set, to nothing", cfg.SyntheticCity)
+			}
+			if _, set := os.LookupEnv("POSTGRES_USER"); set {
+				t.Error("Load() changed the environment")
+			}
+		})
+	}
+}
+
+func TestLoad_EmptyVariablesKeepTheFile(t *testing.T) {
+	setRequiredEnv(t)
+	writeFile(t, "config.yaml", `
+path_prefix: /weather-api
+postgres: {replica_host: replica.internal}
+tenants: [acme, globex]
+`)
+	// docker-compose.yml passes these as ${X:-}
+	for _, k := range []string{"PATH_PREFIX", "POSTGRES_REPLICA_HOST", "TENANTS"} {
+		t.Setenv(k, "")
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.PathPrefix != "/weather-api" {
+		t.Errorf("PathPrefix = %q, want the file's /weather-api", cfg.PathPrefix)
+	}
+	if !strings.Contains(cfg.ReplicaDatabaseURL, "replica.internal") {
+		t.Errorf("ReplicaDatabaseURL = %q, want the file's replica host", cfg.ReplicaDatabaseURL)
+	}
+	if got := strings.Join(cfg.Tenants, " "); got != "acme globex" {
+		t.Errorf("Tenants = %q, want the file's", got)
+	}
+}
+
+func TestLoad_ConfigFileRejects(t *testing.T) {
+	for _, tc := range []struct{ name, body, want string }{
+		{"config.yaml", "smtp_hots: smtp.example.com\n", "unknown settings SMTP_HOTS"},
+		{"config.yaml", "weather_cache: {ttl: 1m}\nweather: {cache: {ttl: 2m}}\n", "WEATHER_CACHE_TTL is set twice"},
+		{"config.yaml", "cors_allowed_origins: [{origin: a}]\n", "list items must be plain values"},
+		{"config.yaml", "smtp: [\n", "CONFIG_FILE"},
+		{"config.json", "{}", "unsupported format"},
+	} {
+		setRequiredEnv(t)
+		writeFile(t, tc.name, tc.body)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load() with %s %q: error %v, want %q", tc.name, tc.body, err, tc.want)
+		}
+	}
+}
+
+func TestLoad_EmailQueueRequires(t *testing.T) {
+	for _, tc := range []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"PII_ENCRYPTION_KEYS": "k1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="}, "EMAIL_QUEUE_REDIS_ADDR is required"},
+		{map[string]string{"EMAIL_QUEUE_REDIS_ADDR": "mailqueue-redis:6379"}, "PII_ENCRYPTION_KEYS is required"},
+	} {
+		setRequiredEnv(t)
+		unsetEnv(t, "EMAIL_QUEUE_REDIS_ADDR")
+		unsetEnv(t, "PII_ENCRYPTION_KEYS")
+		t.Setenv("EMAIL_QUEUE_ENABLED", "true")
+		for k, v := range tc.env {
+			t.Setenv(k, v)
+		}
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load() with %v: error %v, want %q", tc.env, err, tc.want)
+		}
+	}
+}
+
+func TestLoad_SchedulerSendWorkersDefault(t *testing.T) {
+	for _, tc := range []struct {
+		scheduler string
+		want      int
+	}{
+		{"", 0}, // cmd/emailworker sends, whatever SEND_WORKERS says
+		{"3", 3},
+	} {
+		setRequiredEnv(t)
+		t.Setenv("SEND_WORKERS", "4")
+		unsetEnv(t, "SCHEDULER_SEND_WORKERS")
+		if tc.scheduler != "" {
+			t.Setenv("SCHEDULER_SEND_WORKERS", tc.scheduler)
+		}
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load() error: %v", err)
+		}
+		if cfg.SchedulerSendWorkers != tc.want {
+			t.Errorf("SchedulerSendWorkers with %q = %d, want %d", tc.scheduler, cfg.SchedulerSendWorkers, tc.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// source is where Load reads the settings from: the environment, then the optional
+// config file at CONFIG_FILE. A variable set in the environment wins over the file,
+// unless it is set to "": docker-compose.yml passes `${X:-}` for optional settings, which
+// must not blank out those of the file. It remembers the names Load read, so that keys of the file naming no
+// setting (typos, removed settings) are reported instead of silently ignored.
+type source struct {
+	path string            // CONFIG_FILE; empty without a file
+	file map[string]string // settings of the file, by variable name
+	read map[string]bool
+}
+
+// newSource reads the config file at path, or none when path is empty. The file is
+// YAML (.yaml, .yml) or TOML (.toml); nested keys join with "_" into the variable
+// names, case-insensitively, so `weather: {cache_ttl: 10m}` sets WEATHER_CACHE_TTL,
+// and lists become comma-separated.
+func newSource(path string) (*source, error) {
+	env := &source{path: path, file: map[string]string{}, read: map[string]bool{}}
+	if path == "" {
+		return env, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("CONFIG_FILE: %w", err)
+	}
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &doc)
+	case ".toml":
+		err = toml.Unmarshal(raw, &doc)
+	default:
+		return nil, fmt.Errorf("CONFIG_FILE %s: unsupported format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CONFIG_FILE %s: %w", path, err)
+	}
+	if err := flatten("", doc, env.file); err != nil {
+		return nil, fmt.Errorf("CONFIG_FILE %s: %w", path, err)
+	}
+	return env, nil
+}
+
+// get returns the value of the variable key: the environment's when it is set there
+// to something, else the config file's, else "".
+func (env *source) get(key string) string {
+	env.read[key] = true
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return env.file[key]
+}
+
+// checkUnread returns an error naming the keys of the config file that are no
+// setting, once Load has read every setting.
+func (env *source) checkUnread() error {
+	var unknown []string
+	for k := range env.file {
+		if !env.read[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("CONFIG_FILE %s: unknown settings %s", env.path, strings.Join(unknown, ", "))
+}
+
+// flatten adds the settings of m to vars, their names prefixed with prefix. Two keys
+// naming the same variable, e.g. `weather_cache: {ttl: 1m}` and `weather: {cache_ttl: 2m}`,
+// are an error.
+func flatten(prefix string, m map[string]any, vars map[string]string) error {
+	for k, v := range m {
+		name := strings.ToUpper(strings.TrimSpace(k))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		if err := flattenValue(name, v, vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func flattenValue(name string, v any, vars map[string]string) error {
+	if _, dup := vars[name]; dup {
+		return fmt.Errorf("%s is set twice", name)
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		return flatten(name, v, vars)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				return fmt.Errorf("%s: list items must be plain values", name)
+			}
+			items[i] = fmt.Sprint(item)
+		}
+		vars[name] = strings.Join(items, ",")
+	case nil:
+		// an empty key leaves the variable unset
+	default:
+		vars[name] = fmt.Sprint(v)
+	}
+	return nil
+}
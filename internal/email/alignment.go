@@ -0,0 +1,193 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Resolver is the subset of *net.Resolver used by the sender alignment check.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// maxSPFLookups is the RFC 7208 limit on DNS-querying mechanisms per evaluation.
+const maxSPFLookups = 10
+
+// CheckSenderAlignment looks up the SPF and DMARC records of the From domain and
+// returns human-readable warnings when mail relayed through smtpHost would likely
+// fail them. The relay's public address is approximated by its hostname's A/AAAA
+// records, so a warning means "verify this", not "definitely broken".
+func CheckSenderAlignment(ctx context.Context, r Resolver, from, smtpHost string) []string {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return []string{fmt.Sprintf("SMTP_FROM %q is not a valid address: %v", from, err)}
+	}
+	domain := strings.ToLower(addr.Address[strings.LastIndex(addr.Address, "@")+1:])
+
+	var warnings []string
+	spf := findRecord(ctx, r, domain, "v=spf1")
+	switch {
+	case spf == "":
+		warnings = append(warnings, fmt.Sprintf("%s has no SPF record; receivers may treat our mail as spam", domain))
+	default:
+		relayIPs, err := r.LookupIP(ctx, "ip", smtpHost)
+		if err != nil || len(relayIPs) == 0 {
+			warnings = append(warnings, fmt.Sprintf("cannot resolve SMTP host %s to check SPF: %v", smtpHost, err))
+			break
+		}
+		e := &spfEval{r: r}
+		for _, ip := range relayIPs {
+			if !e.authorizes(ctx, domain, spf, ip) {
+				warnings = append(warnings, fmt.Sprintf(
+					"SPF record of %s does not authorize SMTP host %s (%s)", domain, smtpHost, ip))
+				break
+			}
+		}
+	}
+
+	dmarc := findRecord(ctx, r, "_dmarc."+domain, "v=DMARC1")
+	switch {
+	case dmarc == "":
+		warnings = append(warnings, fmt.Sprintf("%s has no DMARC record", domain))
+	case len(warnings) > 0 && dmarcEnforces(dmarc):
+		warnings = append(warnings, fmt.Sprintf(
+			"DMARC policy of %s is enforcing (%s); misaligned mail will be quarantined or rejected", domain, dmarc))
+	}
+	return warnings
+}
+
+// LogSenderAlignment runs CheckSenderAlignment with the system resolver and logs each
+// warning. It is meant to run in the background at startup and never blocks sending.
+func LogSenderAlignment(from, smtpHost string, logger *zap.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	for _, w := range CheckSenderAlignment(ctx, net.DefaultResolver, from, smtpHost) {
+		logger.Warn("sender domain alignment", zap.String("from", from), zap.String("warning", w))
+	}
+}
+
+// findRecord returns the TXT record of name starting with prefix, or "".
+func findRecord(ctx context.Context, r Resolver, name, prefix string) string {
+	records, err := r.LookupTXT(ctx, name)
+	if err != nil {
+		return ""
+	}
+	for _, rec := range records {
+		if strings.HasPrefix(strings.ToLower(rec), strings.ToLower(prefix)) {
+			return rec
+		}
+	}
+	return ""
+}
+
+func dmarcEnforces(record string) bool {
+	for _, tag := range strings.Split(record, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		if strings.EqualFold(k, "p") {
+			v = strings.ToLower(strings.TrimSpace(v))
+			return v == "quarantine" || v == "reject"
+		}
+	}
+	return false
+}
+
+// spfEval is a simplified SPF evaluator: it supports ip4, ip6, a, mx, include and
+// redirect, and treats anything it cannot resolve as "not authorized".
+type spfEval struct {
+	r       Resolver
+	lookups int
+}
+
+func (e *spfEval) authorizes(ctx context.Context, domain, record string, ip net.IP) bool {
+	var redirect string
+	for _, term := range strings.Fields(record)[1:] {
+		term = strings.ToLower(term)
+		if strings.HasPrefix(term, "redirect=") {
+			redirect = strings.TrimPrefix(term, "redirect=")
+			continue
+		}
+		qualifier := byte('+')
+		if strings.ContainsRune("+-~?", rune(term[0])) {
+			qualifier, term = term[0], term[1:]
+		}
+		if e.matches(ctx, domain, term, ip) {
+			return qualifier == '+'
+		}
+	}
+	if redirect != "" && e.lookup() {
+		if rec := findRecord(ctx, e.r, redirect, "v=spf1"); rec != "" {
+			return e.authorizes(ctx, redirect, rec, ip)
+		}
+	}
+	return false
+}
+
+func (e *spfEval) matches(ctx context.Context, domain, term string, ip net.IP) bool {
+	name, arg, _ := strings.Cut(term, ":")
+	switch name {
+	case "all":
+		return true
+	case "ip4", "ip6":
+		if !strings.Contains(arg, "/") {
+			return net.ParseIP(arg).Equal(ip)
+		}
+		_, cidr, err := net.ParseCIDR(arg)
+		return err == nil && cidr.Contains(ip)
+	case "a":
+		return e.lookup() && e.hostHasIP(ctx, orDefault(arg, domain), ip)
+	case "mx":
+		if !e.lookup() {
+			return false
+		}
+		mxs, err := e.r.LookupMX(ctx, orDefault(arg, domain))
+		if err != nil {
+			return false
+		}
+		for _, mx := range mxs {
+			if e.hostHasIP(ctx, strings.TrimSuffix(mx.Host, "."), ip) {
+				return true
+			}
+		}
+	case "include":
+		if arg == "" || !e.lookup() {
+			return false
+		}
+		rec := findRecord(ctx, e.r, arg, "v=spf1")
+		return rec != "" && e.authorizes(ctx, arg, rec, ip)
+	}
+	return false
+}
+
+// lookup counts one DNS-querying mechanism and reports whether the limit still allows it.
+func (e *spfEval) lookup() bool {
+	e.lookups++
+	return e.lookups <= maxSPFLookups
+}
+
+func (e *spfEval) hostHasIP(ctx context.Context, host string, ip net.IP) bool {
+	ips, err := e.r.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return false
+	}
+	for _, candidate := range ips {
+		if candidate.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+type fakeResolver struct {
+	txt map[string][]string
+	ips map[string][]net.IP
+	mx  map[string][]*net.MX
+}
+
+func (f fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if rec, ok := f.txt[name]; ok {
+		return rec, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (f fakeResolver) LookupIP(_ context.Context, _, host string) ([]net.IP, error) {
+	if ips, ok := f.ips[host]; ok {
+		return ips, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (f fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	return f.mx[name], nil
+}
+
+func TestCheckSenderAlignment_AlignedViaInclude(t *testing.T) {
+	r := fakeResolver{
+		txt: map[string][]string{
+			"example.com":        {"google-site-verification=x", "v=spf1 include:_spf.relay.net -all"},
+			"_spf.relay.net":     {"v=spf1 ip4:203.0.113.0/24 ~all"},
+			"_dmarc.example.com": {"v=DMARC1; p=reject"},
+		},
+		ips: map[string][]net.IP{"smtp.relay.net": {net.ParseIP("203.0.113.7")}},
+	}
+
+	if w := CheckSenderAlignment(context.Background(), r, "Weather <noreply@example.com>", "smtp.relay.net"); len(w) != 0 {
+		t.Fatalf("unexpected warnings: %v", w)
+	}
+}
+
+func TestCheckSenderAlignment_MisalignedWithEnforcingDMARC(t *testing.T) {
+	r := fakeResolver{
+		txt: map[string][]string{
+			"example.com":        {"v=spf1 mx -all"},
+			"_dmarc.example.com": {"v=DMARC1; p=quarantine; rua=mailto:d@example.com"},
+		},
+		ips: map[string][]net.IP{
+			"smtp.relay.net":   {net.ParseIP("203.0.113.7")},
+			"mail.example.com": {net.ParseIP("198.51.100.1")},
+		},
+		mx: map[string][]*net.MX{"example.com": {{Host: "mail.example.com.", Pref: 10}}},
+	}
+
+	w := CheckSenderAlignment(context.Background(), r, "noreply@example.com", "smtp.relay.net")
+	if len(w) != 2 || !strings.Contains(w[0], "does not authorize") || !strings.Contains(w[1], "DMARC") {
+		t.Fatalf("got warnings %v, want SPF and DMARC warnings", w)
+	}
+}
+
+func TestCheckSenderAlignment_MissingRecords(t *testing.T) {
+	w := CheckSenderAlignment(context.Background(), fakeResolver{}, "noreply@example.com", "smtp.relay.net")
+	if len(w) != 2 || !strings.Contains(w[0], "no SPF") || !strings.Contains(w[1], "no DMARC") {
+		t.Fatalf("got warnings %v", w)
+	}
+}
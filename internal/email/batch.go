@@ -0,0 +1,76 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BatchError is returned by SendBatch when some messages of a batch were not sent. The
+// other messages were: a failing recipient does not hold back the rest of the batch.
+type BatchError struct {
+	Total  int           // messages in the batch
+	Failed map[int]error // by index in the batch
+}
+
+func (e *BatchError) Error() string {
+	first := -1
+	for i := range e.Failed {
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	return fmt.Sprintf("%d of %d messages not sent, first: %v", len(e.Failed), e.Total, e.Failed[first])
+}
+
+// FailedIndexes returns the indexes of the messages not sent, in order.
+func (e *BatchError) FailedIndexes() []int {
+	out := make([]int, 0, len(e.Failed))
+	for i := range e.Failed {
+		out = append(out, i)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// MessageErr returns why message i of a batch was not sent, given the error SendBatch
+// returned for the batch, or nil when it was sent. Any error other than a *BatchError
+// failed the whole batch.
+func MessageErr(batchErr error, i int) error {
+	if batchErr == nil {
+		return nil
+	}
+	var be *BatchError
+	if errors.As(batchErr, &be) {
+		return be.Failed[i]
+	}
+	return batchErr
+}
+
+// batchResult collects the failures of a batch.
+func batchResult(total int, failed map[int]error) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return &BatchError{Total: total, Failed: failed}
+}
+
+// retryPolicy is how often and how patiently a transiently failing message is retried.
+type retryPolicy struct {
+	retries int
+	backoff time.Duration // before the first retry, doubling
+}
+
+// do runs send until it succeeds, fails permanently or runs out of retries. transient
+// classifies errors; retrying is logged through onRetry.
+func (p retryPolicy) do(send func() error, transient func(error) bool, onRetry func(attempt int, err error)) error {
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil || attempt >= p.retries || !transient(err) {
+			return err
+		}
+		onRetry(attempt+1, err)
+		time.Sleep(p.backoff << attempt)
+	}
+}
@@ -0,0 +1,246 @@
+package email
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+// TemplateDoc describes the variables a template is rendered with, for operators
+// customizing templates (GET /api/admin/templates).
+type TemplateDoc struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Variables   []Variable `json:"variables"`
+}
+
+// Variable is one value available to a template. Name is its template expression;
+// "[]" marks a list, used with {{range}}.
+type Variable struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Sample any    `json:"sample"`
+}
+
+// FuncDoc describes a template function.
+type FuncDoc struct {
+	Name        string `json:"name"`
+	Usage       string `json:"usage"`
+	Description string `json:"description"`
+}
+
+// Funcs documents the functions available to every template.
+var Funcs = []FuncDoc{
+	{Name: "t", Usage: `{{t .Locale "update.footer" .ManageURL .UnsubscribeURL}}`,
+		Description: "Message of the i18n catalog of the locale, formatted with the (HTML-escaped) arguments."},
+	{Name: "temp", Usage: "{{temp .Weather.Temp $.Units}}",
+		Description: "Celsius temperature in the subscriber's units, e.g. 21.50°C or 70.7°F."},
+	{Name: "join", Usage: "{{join .Cities}}", Description: "Comma-separated list of strings."},
+	{Name: "cid", Usage: `{{with .Chart}}<img src="{{cid .}}">{{end}}`,
+		Description: "URL of an inline image of the email, by content ID."},
+}
+
+var (
+	sampleCities  = []string{"Kyiv", "Lviv"}
+	sampleWeather = []CityWeather{
+		{City: "Kyiv", Weather: types.Weather{Temp: 21.5, Humidity: 64, Description: "Partly cloudy",
+			Sunrise: time.Date(2026, 10, 16, 4, 28, 0, 0, time.UTC)}, Sunrise: "07:28",
+			Chart: "chart-kyiv@weather",
+			Tomorrow: &types.Forecast{Date: "2026-10-17", MinTemp: 9.4, MaxTemp: 17.8, ChanceOfRain: 20,
+				Description: "Sunny"}},
+		{City: "Lviv", Weather: types.Weather{Temp: 18.2, Humidity: 71, Description: "Light rain",
+			Sunrise: time.Date(2026, 10, 16, 4, 54, 0, 0, time.UTC)}, Sunrise: "07:54"},
+	}
+	sampleSubscriber = Subscriber{FirstName: "Anna", Days: 42}
+	sampleFeatures   = (*features.Flags)(nil).For(false)
+)
+
+// samples are the templates with a description and realistic data, in the order they
+// are documented. Strict mode renders each of them at startup.
+var samples = []struct {
+	name, description string
+	data              any
+}{
+	{TemplateConfirmation, "Sent on subscribe, with the link that activates the subscription.", ConfirmationData{
+		Locale:         "en",
+		Subscriber:     Subscriber{FirstName: "Anna"},
+		Cities:         sampleCities,
+		Schedule:       "every day between 07:00 and 08:00 (Europe/Kyiv time)",
+		ConfirmURL:     "https://weather.example.com/api/confirm/<token>",
+		UnsubscribeURL: "https://weather.example.com/api/unsubscribe/<token>",
+		NotMeURL:       "https://weather.example.com/api/confirm/<token>/not-me",
+		NotMeDays:      30,
+	}},
+	{TemplateWeatherUpdate, "The hourly or daily weather update.", WeatherUpdateData{
+		Locale:         "en",
+		Subscriber:     sampleSubscriber,
+		Summary:        "A mild day in Kyiv and Lviv, with rain in Lviv in the afternoon.",
+		Cities:         sampleWeather,
+		Timezone:       "Europe/Kyiv",
+		Units:          UnitsMetric,
+		ManageURL:      "https://weather.example.com/api/manage/<token>",
+		UnsubscribeURL: "https://weather.example.com/api/unsubscribe/<token>",
+		OpenPixelURL:   "https://weather.example.com/api/open/<token>",
+		Attributions:   []string{"Weather data by WeatherAPI.com"},
+		Features:       sampleFeatures,
+	}},
+	{TemplateWelcome, "Sent right after confirmation, with the current weather.", WelcomeData{
+		Subscriber:     Subscriber{FirstName: "Anna"},
+		Cities:         sampleWeather,
+		Timezone:       "Europe/Kyiv",
+		Frequency:      "daily",
+		Schedule:       "every day at 07:00 (Europe/Kyiv time)",
+		Units:          UnitsMetric,
+		ManageURL:      "https://weather.example.com/api/manage/<token>",
+		UnsubscribeURL: "https://weather.example.com/api/unsubscribe/<token>",
+		OpenPixelURL:   "https://weather.example.com/api/open/<token>",
+		Attributions:   []string{"Weather data by WeatherAPI.com"},
+		Features:       sampleFeatures,
+	}},
+	{TemplateAnniversary, "Sent one year after confirmation.", AnniversaryData{
+		Cities:         sampleCities,
+		Since:          "16 October 2025",
+		ManageURL:      "https://weather.example.com/api/manage/<token>",
+		UnsubscribeURL: "https://weather.example.com/api/unsubscribe/<token>",
+	}},
+	{TemplateReEngagement, "Sent to subscribers who did not ask to keep their updates for a while.", ReEngagementData{
+		Cities:         sampleCities,
+		KeepURL:        "https://weather.example.com/api/manage/<token>/keep",
+		ManageURL:      "https://weather.example.com/api/manage/<token>",
+		UnsubscribeURL: "https://weather.example.com/api/unsubscribe/<token>",
+	}},
+	{TemplateRenewal, "Sent when a subscription expires, with a one-click renewal.", RenewalData{
+		Cities:         sampleCities,
+		ExpiredOn:      "16 October 2026",
+		KeepURL:        "https://weather.example.com/api/manage/<token>/keep",
+		ManageURL:      "https://weather.example.com/api/manage/<token>",
+		UnsubscribeURL: "https://weather.example.com/api/unsubscribe/<token>",
+	}},
+	{TemplateAlert, "Sent to alert subscriptions when one of their conditions starts to hold.", AlertData{
+		Locale:     "en",
+		Subscriber: Subscriber{FirstName: "Anna"},
+		Triggers: []AlertTrigger{
+			{City: "Kyiv", Condition: AlertTempBelowTomorrow, Value: -6.5, Threshold: -5},
+			{City: "Lviv", Condition: AlertRain, Value: 100, Description: "Light rain"},
+		},
+		Units:          UnitsMetric,
+		ManageURL:      "https://weather.example.com/api/manage/<token>",
+		UnsubscribeURL: "https://weather.example.com/api/unsubscribe/<token>",
+		OpenPixelURL:   "https://weather.example.com/api/open/<token>",
+		Attributions:   []string{"Weather data by WeatherAPI.com"},
+	}},
+	{TemplateWarning, "Sent to warning subscriptions for every new government severe weather warning.", WarningData{
+		Locale:     "en",
+		Subscriber: Subscriber{FirstName: "Anna"},
+		Warnings: []CityWarning{{City: "Kyiv", Until: "17 Oct 18:00", Warning: types.Warning{
+			ID: "9f2c41d07a3be815c6d2e0f4b7a19c38", Event: "Wind warning",
+			Headline: "Strong wind warning issued for Kyiv region", Severity: "Severe", Areas: "Kyiv; Kyiv Oblast",
+			Description: "Gusts of 20-25 m/s are expected in the afternoon.",
+			Instruction: "Stay away from trees and temporary structures.",
+			Effective:   time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+			Expires:     time.Date(2026, 10, 17, 15, 0, 0, 0, time.UTC),
+			Provider:    "weatherapi.com"}}},
+		Timezone:       "Europe/Kyiv",
+		ManageURL:      "https://weather.example.com/api/manage/<token>",
+		UnsubscribeURL: "https://weather.example.com/api/unsubscribe/<token>",
+		OpenPixelURL:   "https://weather.example.com/api/open/<token>",
+		Attributions:   []string{"Weather data by WeatherAPI.com"},
+	}},
+	{TemplatePrivacy, "Sent on a GDPR request, with the links exporting or erasing the data of the address.", PrivacyData{
+		ExportURL: "https://weather.example.com/api/privacy/export/<token>",
+		EraseURL:  "https://weather.example.com/api/privacy/erase/<token>?email=anna%40example.com",
+		ValidFor:  "24 hours",
+	}},
+}
+
+// Docs documents the variables of every template, with sample values.
+func Docs() []TemplateDoc {
+	docs := make([]TemplateDoc, 0, len(samples))
+	for _, s := range samples {
+		docs = append(docs, TemplateDoc{Name: s.name, Description: s.description, Variables: variables("", reflect.ValueOf(s.data))})
+	}
+	return docs
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// variables flattens v into template expressions: struct fields become ".Field", the
+// elements of lists ".List[].Field" (sampled from the first element) and map entries
+// ".Map.key". Pointers, set in the samples, stand for their value.
+func variables(prefix string, v reflect.Value) []Variable {
+	switch {
+	case v.Kind() == reflect.Pointer && !v.IsNil():
+		return variables(prefix, v.Elem())
+	case v.Kind() == reflect.Struct && v.Type() != timeType:
+		var out []Variable
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() {
+				out = append(out, variables(prefix+"."+f.Name, v.Field(i))...)
+			}
+		}
+		return out
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct && v.Len() > 0:
+		return variables(prefix+"[]", v.Index(0))
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		out := make([]Variable, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, variables(prefix+"."+k, v.MapIndex(reflect.ValueOf(k)))...)
+		}
+		return out
+	default:
+		return []Variable{{Name: prefix, Type: typeName(v.Type()), Sample: v.Interface()}}
+	}
+}
+
+func typeName(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "time"
+	case t.Kind() == reflect.Slice:
+		return "list of " + typeName(t.Elem())
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "number"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// sampleTypes are the types of the sample data of the templates, by name: strict mode
+// renders a template with data of that type only.
+var sampleTypes = func() map[string]reflect.Type {
+	types := make(map[string]reflect.Type, len(samples))
+	for _, s := range samples {
+		types[s.name] = reflect.TypeOf(s.data)
+	}
+	return types
+}()
+
+// checkSamples renders every template with its sample data, and checks that every
+// template is documented.
+func checkSamples() error {
+	documented := make(map[string]bool, len(samples))
+	for _, s := range samples {
+		if _, err := Render(s.name, s.data); err != nil {
+			return err
+		}
+		documented[s.name] = true
+	}
+	for _, t := range templates.Templates() {
+		if strings.HasSuffix(t.Name(), ".html") && !documented[t.Name()] {
+			return fmt.Errorf("email template %s has no sample data", t.Name())
+		}
+	}
+	return nil
+}
@@ -2,11 +2,14 @@ package email
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
 	"net"
 	"net/smtp"
-	"strings"
+	"net/textproto"
+	"strconv"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -17,15 +20,30 @@ type EmailMessage struct {
 	To      []string // Recipient email addresses.
 	Subject string   // Email subject.
 	Body    string   // HTML or plain text email content.
+
+	// UnsubscribeURL, when set, is advertised in RFC 8058 List-Unsubscribe headers, so
+	// mailbox providers can offer one-click unsubscribe (a POST to the URL).
+	UnsubscribeURL string
+
+	// Inline files the HTML body refers to by content ID, such as charts. A message
+	// with inline parts is sent as multipart/related.
+	Inline []InlinePart
+
+	// DeliveryToken is the open token of the delivery log entry of a queued message,
+	// by which the queue worker records its outcome; empty outside the delivery log.
+	DeliveryToken string
 }
 
 // EmailSender defines an interface for sending batches of emails.
 type EmailSender interface {
-	// SendBatch sends multiple EmailMessage objects in a single SMTP session.
+	// SendBatch sends multiple EmailMessage objects in a single SMTP session. A message
+	// that cannot be sent does not stop the others: the error is then a *BatchError
+	// telling which ones failed (see MessageErr), any other error failed the batch.
 	SendBatch(messages []EmailMessage) error
 }
 
-// SMTPSender is a concrete implementation of EmailSender using SMTP.
+// SMTPSender is a concrete implementation of EmailSender using SMTP. Authenticated
+// sessions are kept open between batches, see smtp_pool.go.
 type SMTPSender struct {
 	host      string
 	port      int
@@ -35,6 +53,14 @@ type SMTPSender struct {
 	tlsConfig *tls.Config
 	cfg       *config.Config
 	logger    *zap.Logger
+
+	poolSize    int
+	idleTimeout time.Duration
+	retry       retryPolicy
+	dial        func() (*smtp.Client, error) // connect; replaced in tests
+
+	mu   sync.Mutex
+	idle []*pooledClient // most recently used last
 }
 
 // NewSMTPSender reads SMTP configuration from environment variables and returns an SMTPSender.
@@ -50,21 +76,26 @@ func NewSMTPSender(cfg *config.Config, logger *zap.Logger) (*SMTPSender, error)
 	auth := smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPass, cfg.SMTPHost)
 	tlsConfig := &tls.Config{ServerName: cfg.SMTPHost}
 
-	return &SMTPSender{
-		host:      cfg.SMTPHost,
-		port:      cfg.SMTPPort,
-		user:      cfg.SMTPUser,
-		from:      cfg.SMTPFrom,
-		auth:      auth,
-		tlsConfig: tlsConfig,
-		logger:    logger,
-	}, nil
+	s := &SMTPSender{
+		host:        cfg.SMTPHost,
+		port:        cfg.SMTPPort,
+		user:        cfg.SMTPUser,
+		from:        cfg.SMTPFrom,
+		auth:        auth,
+		tlsConfig:   tlsConfig,
+		logger:      logger,
+		poolSize:    cfg.SMTPPoolSize,
+		idleTimeout: cfg.SMTPIdleTimeout,
+		retry:       retryPolicy{retries: cfg.EmailSendRetries, backoff: cfg.EmailRetryBackoff},
+	}
+	s.dial = s.connect
+	return s, nil
 }
 
 // createClient encapsulates dialing and setting up an SMTP client connection.
 // It handles both implicit TLS (port 465) and STARTTLS (other ports).
 func (s *SMTPSender) createClient() (*smtp.Client, error) {
-	addr := fmt.Sprintf("%s:%d", s.host, s.port)
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
 	var conn net.Conn
 	var err error
 
@@ -116,43 +147,110 @@ func (s *SMTPSender) createClient() (*smtp.Client, error) {
 	return client, nil
 }
 
-// SendBatch opens a single SMTP session and sends all provided emails sequentially.
-func (s *SMTPSender) SendBatch(messages []EmailMessage) (err error) {
+// connect dials the server and authenticates, returning a session ready for MAIL FROM.
+func (s *SMTPSender) connect() (*smtp.Client, error) {
 	client, err := s.createClient()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// ensure QUIT is sent and connection closed
-	defer func() {
-		if quitErr := client.Quit(); quitErr != nil && err == nil {
-			s.logger.Error("failed to close SMTP connection", zap.Error(quitErr))
-			err = fmt.Errorf("failed to close SMTP connection: %w", quitErr)
-		}
-	}()
-
-	// Authenticate once per session
 	if err := client.Auth(s.auth); err != nil {
+		if cerr := client.Close(); cerr != nil {
+			s.logger.Warn("failed to close SMTP client after authentication failure", zap.Error(cerr))
+		}
 		s.logger.Error("SMTP authentication failed", zap.Error(err))
-		return fmt.Errorf("failed to authenticate: %w", err)
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
 	}
+	return client, nil
+}
 
-	// Send each message, resetting the envelope between them
-	for _, msg := range messages {
-		if err := client.Reset(); err != nil {
-			s.logger.Error("failed to reset SMTP session", zap.Error(err))
-			return fmt.Errorf("failed to reset SMTP session: %w", err)
-		}
-		if err := s.send(client, msg); err != nil {
+// SendBatch sends all provided emails sequentially over one SMTP session, reused from
+// the pool when one is idle. A rejected message (5xx) is skipped; one failing
+// transiently (4xx, or a session the server dropped) is retried with backoff, over a
+// new session when needed. Only when the server cannot be reached at all are the
+// remaining messages given up.
+func (s *SMTPSender) SendBatch(messages []EmailMessage) error {
+	c, err := s.get()
+	if err != nil {
+		return err
+	}
+
+	failed := make(map[int]error)
+	for i, msg := range messages {
+		var dialErr error
+		err := s.retry.do(func() error {
+			if c == nil {
+				if c, dialErr = s.newSession(); dialErr != nil {
+					return dialErr
+				}
+			}
+			// reset the envelope left by the previous message, then send
+			err := c.Reset()
+			if err == nil {
+				err = s.send(c.Client, msg)
+			}
+			if err != nil && smtpSessionLost(err) {
+				s.discard(c)
+				c = nil
+			}
 			return err
+		}, smtpTransient, func(attempt int, err error) {
+			s.logger.Warn("transient SMTP failure, retrying",
+				zap.Strings("to", msg.To), zap.Int("attempt", attempt), zap.Error(err))
+		})
+		if err == nil {
+			continue
 		}
+		failed[i] = err
+		if c == nil && dialErr != nil {
+			for j := i + 1; j < len(messages); j++ {
+				failed[j] = err
+			}
+			break
+		}
+	}
+	if c != nil {
+		s.put(c)
 	}
 
+	if len(failed) > 0 {
+		s.logger.Warn("some messages were not sent",
+			zap.Int("failed", len(failed)), zap.Int("count", len(messages)))
+		return batchResult(len(messages), failed)
+	}
 	s.logger.Info("all messages sent successfully", zap.Int("count", len(messages)))
 	return nil
 }
 
+// smtpReplyCode returns the reply code of an error the server answered with, or 0 for
+// other errors such as a broken connection.
+func smtpReplyCode(err error) int {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code
+	}
+	return 0
+}
+
+// smtpTransient reports whether a failed message may succeed when retried: the server
+// deferred it (4xx) or the connection broke.
+func smtpTransient(err error) bool {
+	code := smtpReplyCode(err)
+	return code == 0 || code/100 == 4
+}
+
+// smtpSessionLost reports whether the session cannot be used any more: the connection
+// broke or the server is closing it (421).
+func smtpSessionLost(err error) bool {
+	code := smtpReplyCode(err)
+	return code == 0 || code == 421
+}
+
 // send sends a single EmailMessage using an existing SMTP client session.
 func (s *SMTPSender) send(client *smtp.Client, m EmailMessage) error {
+	fullMessage, err := messageData(s.from, m, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
 	// MAIL FROM
 	if err := client.Mail(s.user); err != nil {
 		s.logger.Error("MAIL FROM failed", zap.String("from", s.user), zap.Error(err))
@@ -161,7 +259,7 @@ func (s *SMTPSender) send(client *smtp.Client, m EmailMessage) error {
 	// RCPT TO
 	for _, addr := range m.To {
 		if err := client.Rcpt(addr); err != nil {
-			s.logger.Error("RCPT TO failed", zap.String("to", addr), zap.Error(err))
+			s.logger.Error("RCPT TO failed", zap.Error(err))
 			return fmt.Errorf("failed to add RCPT TO %q: %w", addr, err)
 		}
 	}
@@ -172,19 +270,8 @@ func (s *SMTPSender) send(client *smtp.Client, m EmailMessage) error {
 		return fmt.Errorf("failed to start DATA command: %w", err)
 	}
 
-	// Build headers
-	headers := []string{
-		fmt.Sprintf("Date: %s", time.Now().Format(time.RFC1123Z)),
-		fmt.Sprintf("From: %s", s.from),
-		fmt.Sprintf("To: %s", strings.Join(m.To, ",")),
-		fmt.Sprintf("Subject: %s", m.Subject),
-		"MIME-Version: 1.0",
-		`Content-Type: text/html; charset="utf-8"`,
-	}
-	fullMessage := strings.Join(headers, "\r\n") + "\r\n\r\n" + m.Body
-
 	// Write body
-	if _, writeErr := wc.Write([]byte(fullMessage)); writeErr != nil {
+	if _, writeErr := wc.Write(fullMessage); writeErr != nil {
 		// handle Close() error
 		if cErr := wc.Close(); cErr != nil {
 			s.logger.Warn("failed to close DATA writer after write error", zap.Error(cErr))
@@ -0,0 +1,79 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// InlinePart is a file embedded in the HTML body of a message, referenced from it as
+// "cid:" + ContentID (see the "cid" template function).
+type InlinePart struct {
+	ContentID   string // unique within the message, e.g. "chart-kyiv@weather"
+	ContentType string // e.g. "image/png"
+	Filename    string
+	Data        []byte
+}
+
+// base64LineLength is the longest encoded line RFC 2045 allows.
+const base64LineLength = 76
+
+// messageData renders m as an RFC 5322 message from from: a plain HTML message, or a
+// multipart/related one carrying the inline parts after the HTML.
+func messageData(from string, m EmailMessage, now time.Time) ([]byte, error) {
+	headers := []string{
+		fmt.Sprintf("Date: %s", now.Format(time.RFC1123Z)),
+		fmt.Sprintf("From: %s", from),
+		fmt.Sprintf("To: %s", strings.Join(m.To, ",")),
+		fmt.Sprintf("Subject: %s", m.Subject),
+		"MIME-Version: 1.0",
+	}
+	if m.UnsubscribeURL != "" {
+		headers = append(headers,
+			fmt.Sprintf("List-Unsubscribe: <%s>", m.UnsubscribeURL),
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		)
+	}
+	if len(m.Inline) == 0 {
+		headers = append(headers, `Content-Type: text/html; charset="utf-8"`)
+		return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + m.Body), nil
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	html, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {`text/html; charset="utf-8"`}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := html.Write([]byte(m.Body)); err != nil {
+		return nil, err
+	}
+	for _, p := range m.Inline {
+		part, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("inline; filename=%q", p.Filename)},
+			"Content-Id":                {"<" + p.ContentID + ">"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(p.Data)
+		for len(encoded) > 0 {
+			n := min(base64LineLength, len(encoded))
+			if _, err := part.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+				return nil, err
+			}
+			encoded = encoded[n:]
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	headers = append(headers, fmt.Sprintf(`Content-Type: multipart/related; type="text/html"; boundary=%q`, w.Boundary()))
+	return append([]byte(strings.Join(headers, "\r\n")+"\r\n\r\n"), body.Bytes()...), nil
+}
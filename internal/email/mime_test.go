@@ -0,0 +1,61 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageData_InlineParts(t *testing.T) {
+	now := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	chart := bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 40)
+	data, err := messageData("notify@example.com", EmailMessage{
+		To:      []string{"a@example.com"},
+		Subject: "Weather",
+		Body:    `<img src="cid:chart-kyiv@weather">`,
+		Inline:  []InlinePart{{ContentID: "chart-kyiv@weather", ContentType: "image/png", Filename: "kyiv.png", Data: chart}},
+	}, now)
+	if err != nil {
+		t.Fatalf("messageData() error: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/related" {
+		t.Fatalf("Content-Type = %q, want multipart/related", msg.Header.Get("Content-Type"))
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+
+	html, err := r.NextPart()
+	if err != nil {
+		t.Fatalf("html part: %v", err)
+	}
+	if body, _ := io.ReadAll(html); !strings.Contains(string(body), "cid:chart-kyiv@weather") {
+		t.Errorf("html part = %q", body)
+	}
+
+	img, err := r.NextPart()
+	if err != nil {
+		t.Fatalf("image part: %v", err)
+	}
+	if got := img.Header.Get("Content-Id"); got != "<chart-kyiv@weather>" {
+		t.Errorf("Content-ID = %q", got)
+	}
+	encoded, _ := io.ReadAll(img)
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if err != nil || !bytes.Equal(decoded, chart) {
+		t.Errorf("image part does not decode to the chart: %v", err)
+	}
+	if _, err := r.NextPart(); err != io.EOF {
+		t.Errorf("NextPart() after the image = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,182 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+// NewSender returns the EmailSender selected by EMAIL_PROVIDER.
+func NewSender(cfg *config.Config, logger *zap.Logger) (EmailSender, error) {
+	if cfg.EmailProvider == "sendgrid" {
+		return NewSendGridSender(cfg, logger)
+	}
+	return NewSMTPSender(cfg, logger)
+}
+
+// SendGridSender is an EmailSender using the SendGrid v3 mail send API over HTTPS, for
+// networks where outbound SMTP ports are blocked.
+type SendGridSender struct {
+	url    string
+	apiKey string
+	from   sendGridAddress
+	client *http.Client
+	retry  retryPolicy
+	logger *zap.Logger
+}
+
+// NewSendGridSender sends from SMTP_FROM with SENDGRID_API_KEY.
+func NewSendGridSender(cfg *config.Config, logger *zap.Logger) (*SendGridSender, error) {
+	from, err := mail.ParseAddress(cfg.SMTPFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM %q: %w", cfg.SMTPFrom, err)
+	}
+	return &SendGridSender{
+		url:    cfg.SendGridAPIURL,
+		apiKey: cfg.SendGridAPIKey,
+		from:   sendGridAddress{Email: from.Address, Name: from.Name},
+		client: &http.Client{Timeout: 15 * time.Second},
+		retry:  retryPolicy{retries: cfg.EmailSendRetries, backoff: cfg.EmailRetryBackoff},
+		logger: logger,
+	}, nil
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sendGridAttachment is an inline part, referenced from the HTML by ContentID.
+type sendGridAttachment struct {
+	Content     string `json:"content"` // base64
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+	ContentID   string `json:"content_id"`
+}
+
+type sendGridMessage struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+}
+
+// sendGridError is a message SendGrid answered with an error status.
+type sendGridError struct {
+	status string
+	code   int
+	detail []byte
+}
+
+func (e *sendGridError) Error() string {
+	return fmt.Sprintf("SendGrid answered %s: %s", e.status, e.detail)
+}
+
+// sendGridTransient reports whether a failed request may succeed when retried: it was
+// throttled, SendGrid failed, or the request did not get an answer.
+func sendGridTransient(err error) bool {
+	var sgErr *sendGridError
+	if !errors.As(err, &sgErr) {
+		return true
+	}
+	return sgErr.code == http.StatusTooManyRequests || sgErr.code >= 500
+}
+
+// SendBatch sends the messages one API request each. A rejected message is skipped and
+// reported in a *BatchError; throttled and failed requests are retried with backoff.
+func (s *SendGridSender) SendBatch(messages []EmailMessage) error {
+	failed := make(map[int]error)
+	for i, m := range messages {
+		err := s.retry.do(func() error { return s.send(m) }, sendGridTransient, func(attempt int, err error) {
+			s.logger.Warn("transient SendGrid failure, retrying",
+				zap.Strings("to", m.To), zap.Int("attempt", attempt), zap.Error(err))
+		})
+		if err != nil {
+			failed[i] = err
+		}
+	}
+	if len(failed) > 0 {
+		s.logger.Warn("some messages were not sent",
+			zap.Int("failed", len(failed)), zap.Int("count", len(messages)))
+		return batchResult(len(messages), failed)
+	}
+	s.logger.Info("all messages sent successfully", zap.Int("count", len(messages)))
+	return nil
+}
+
+func (s *SendGridSender) send(m EmailMessage) error {
+	msg := sendGridMessage{
+		From:    s.from,
+		Subject: m.Subject,
+		Content: []sendGridContent{{Type: "text/html", Value: m.Body}},
+	}
+	var to sendGridPersonalization
+	for _, addr := range m.To {
+		to.To = append(to.To, sendGridAddress{Email: addr})
+	}
+	msg.Personalizations = []sendGridPersonalization{to}
+	if m.UnsubscribeURL != "" {
+		msg.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + m.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+	for _, p := range m.Inline {
+		msg.Attachments = append(msg.Attachments, sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(p.Data),
+			Type:        p.ContentType,
+			Filename:    p.Filename,
+			Disposition: "inline",
+			ContentID:   p.ContentID,
+		})
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode SendGrid request: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build SendGrid request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Error("SendGrid request failed", zap.Error(err))
+		return fmt.Errorf("failed to call SendGrid: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		s.logger.Error("SendGrid rejected message", zap.Strings("to", m.To),
+			zap.Int("status", resp.StatusCode), zap.ByteString("response", detail))
+		return &sendGridError{status: resp.Status, code: resp.StatusCode, detail: bytes.TrimSpace(detail)}
+	}
+
+	s.logger.Debug("email sent", zap.Strings("to", m.To), zap.String("subject", m.Subject))
+	return nil
+}
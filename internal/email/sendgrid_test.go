@@ -0,0 +1,73 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+func TestSendGridSender_SendBatch(t *testing.T) {
+	var got []sendGridMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer SG.key" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		var m sendGridMessage
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		got = append(got, m)
+		if m.Personalizations[0].To[0].Email == "throttled@example.com" && len(got) == 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if m.Personalizations[0].To[0].Email == "bounce@example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"invalid recipient"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s, err := NewSendGridSender(&config.Config{
+		SMTPFrom:          `"Weather Notify" <notify@example.com>`,
+		SendGridAPIKey:    "SG.key",
+		SendGridAPIURL:    srv.URL,
+		EmailSendRetries:  1,
+		EmailRetryBackoff: time.Millisecond,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewSendGridSender() error: %v", err)
+	}
+
+	err = s.SendBatch([]EmailMessage{
+		{To: []string{"a@example.com"}, Subject: "Weather", Body: "<p>Sunny</p>", UnsubscribeURL: "https://example.com/u"},
+		{To: []string{"bounce@example.com"}, Subject: "Weather", Body: "<p>Sunny</p>"},
+		{To: []string{"throttled@example.com"}, Subject: "Weather", Body: "<p>Sunny</p>"},
+	})
+	var be *BatchError
+	if !errors.As(err, &be) || len(be.Failed) != 1 || !strings.Contains(MessageErr(err, 1).Error(), "invalid recipient") {
+		t.Fatalf("SendBatch() error = %v, want only the rejection of the second message", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("sent %d requests, want 4 (go on past the rejection, retry the throttled one)", len(got))
+	}
+	first := got[0]
+	if first.From != (sendGridAddress{Email: "notify@example.com", Name: "Weather Notify"}) ||
+		first.Content[0] != (sendGridContent{Type: "text/html", Value: "<p>Sunny</p>"}) ||
+		first.Headers["List-Unsubscribe"] != "<https://example.com/u>" {
+		t.Errorf("first request = %+v", first)
+	}
+	if got[1].Headers != nil {
+		t.Errorf("List-Unsubscribe headers sent without an unsubscribe URL: %v", got[1].Headers)
+	}
+}
@@ -0,0 +1,110 @@
+package email
+
+import (
+	"net/smtp"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+var (
+	smtpSessions = metrics.NewCounter("weather_smtp_sessions_total",
+		"SMTP sessions opened (connected and authenticated).")
+	smtpSessionsReused = metrics.NewCounter("weather_smtp_sessions_reused_total",
+		"Batches sent over an SMTP session kept open from an earlier batch.")
+)
+
+// pooledClient is an authenticated SMTP session kept open between batches.
+type pooledClient struct {
+	*smtp.Client
+	idleSince time.Time
+}
+
+// get returns an idle session that still answers NOOP, or a new one. Sessions idle for
+// idleTimeout or longer are closed instead: servers drop them about then anyway.
+func (s *SMTPSender) get() (*pooledClient, error) {
+	for {
+		s.mu.Lock()
+		n := len(s.idle)
+		if n == 0 {
+			s.mu.Unlock()
+			return s.newSession()
+		}
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+
+		if time.Since(c.idleSince) >= s.idleTimeout {
+			s.quit(c)
+			continue
+		}
+		if err := c.Noop(); err != nil {
+			s.logger.Debug("idle SMTP session is gone", zap.Error(err))
+			s.discard(c)
+			continue
+		}
+		smtpSessionsReused.Inc()
+		return c, nil
+	}
+}
+
+func (s *SMTPSender) newSession() (*pooledClient, error) {
+	client, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	smtpSessions.Inc()
+	return &pooledClient{Client: client}, nil
+}
+
+// put returns a healthy session to the pool, or quits it when the pool is full. It is
+// closed after idleTimeout unless reused before.
+func (s *SMTPSender) put(c *pooledClient) {
+	c.idleSince = time.Now()
+	s.mu.Lock()
+	if len(s.idle) >= s.poolSize {
+		s.mu.Unlock()
+		s.quit(c)
+		return
+	}
+	s.idle = append(s.idle, c)
+	s.mu.Unlock()
+	time.AfterFunc(s.idleTimeout, s.closeExpired)
+}
+
+// closeExpired quits the sessions that have been idle for idleTimeout.
+func (s *SMTPSender) closeExpired() {
+	s.mu.Lock()
+	var expired []*pooledClient
+	kept := s.idle[:0]
+	for _, c := range s.idle {
+		if time.Since(c.idleSince) >= s.idleTimeout {
+			expired = append(expired, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	s.idle = kept
+	s.mu.Unlock()
+
+	for _, c := range expired {
+		s.quit(c)
+	}
+}
+
+// quit ends a healthy session politely.
+func (s *SMTPSender) quit(c *pooledClient) {
+	if err := c.Quit(); err != nil {
+		s.logger.Debug("failed to quit SMTP session", zap.Error(err))
+		s.discard(c)
+	}
+}
+
+// discard closes the connection of a session in an unknown state, without QUIT.
+func (s *SMTPSender) discard(c *pooledClient) {
+	if err := c.Close(); err != nil {
+		s.logger.Debug("failed to close SMTP connection", zap.Error(err))
+	}
+}
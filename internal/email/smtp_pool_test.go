@@ -0,0 +1,192 @@
+package email
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/smtp"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeSMTP accepts plain SMTP sessions, counting connections and delivered messages.
+type fakeSMTP struct {
+	ln        net.Listener
+	conns     atomic.Int32
+	delivered atomic.Int32
+
+	mu   sync.Mutex
+	open []net.Conn
+
+	// rcpt, when set, answers RCPT TO lines instead of accepting them all
+	rcpt func(line string) string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.conns.Add(1)
+			f.mu.Lock()
+			f.open = append(f.open, conn)
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+// dropAll closes every session, as servers do with idle ones.
+func (f *fakeSMTP) dropAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.open {
+		c.Close()
+	}
+	f.open = nil
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+		case "RCPT":
+			if f.rcpt != nil {
+				reply(f.rcpt(line))
+			} else {
+				reply("250 OK")
+			}
+		case "EHLO", "HELO", "NOOP", "RSET", "MAIL":
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+			}
+			f.delivered.Add(1)
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unknown")
+		}
+	}
+}
+
+func newTestSMTPSender(srv *fakeSMTP, idleTimeout time.Duration) *SMTPSender {
+	s := &SMTPSender{user: "from@example.com", from: "from@example.com", logger: zap.NewNop(),
+		poolSize: 1, idleTimeout: idleTimeout}
+	s.dial = func() (*smtp.Client, error) { return smtp.Dial(srv.ln.Addr().String()) }
+	return s
+}
+
+func TestSMTPSender_ReusesSessions(t *testing.T) {
+	srv := newFakeSMTP(t)
+	s := newTestSMTPSender(srv, time.Minute)
+
+	msg := []EmailMessage{{To: []string{"a@example.com"}, Subject: "s", Body: "b"}}
+	for i := 0; i < 3; i++ {
+		if err := s.SendBatch(msg); err != nil {
+			t.Fatalf("SendBatch #%d: %v", i, err)
+		}
+	}
+	if got := srv.conns.Load(); got != 1 {
+		t.Errorf("connections = %d, want 1 reused session", got)
+	}
+
+	// the server drops idle sessions: the next batch reconnects transparently
+	srv.dropAll()
+	time.Sleep(20 * time.Millisecond)
+	if err := s.SendBatch(msg); err != nil {
+		t.Fatalf("SendBatch after drop: %v", err)
+	}
+	if got, delivered := srv.conns.Load(), srv.delivered.Load(); got != 2 || delivered != 4 {
+		t.Errorf("connections = %d, delivered = %d; want 2 and 4", got, delivered)
+	}
+}
+
+func TestSMTPSender_ClosesIdleSessions(t *testing.T) {
+	srv := newFakeSMTP(t)
+	s := newTestSMTPSender(srv, 10*time.Millisecond)
+
+	msg := []EmailMessage{{To: []string{"a@example.com"}, Subject: "s", Body: "b"}}
+	for i := 0; i < 2; i++ {
+		if err := s.SendBatch(msg); err != nil {
+			t.Fatalf("SendBatch #%d: %v", i, err)
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+	if got := srv.conns.Load(); got != 2 {
+		t.Errorf("connections = %d, want a new session after the idle timeout", got)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.idle) != 0 {
+		t.Errorf("%d sessions still idle after the idle timeout", len(s.idle))
+	}
+}
+
+func TestSMTPSender_IsolatesFailedMessages(t *testing.T) {
+	srv := newFakeSMTP(t)
+	var deferred atomic.Bool
+	srv.rcpt = func(line string) string {
+		switch {
+		case strings.Contains(line, "rejected@"):
+			return "550 no such user"
+		case strings.Contains(line, "busy@") && !deferred.Swap(true):
+			return "451 try again later"
+		}
+		return "250 OK"
+	}
+	s := newTestSMTPSender(srv, time.Minute)
+	s.retry = retryPolicy{retries: 1, backoff: time.Millisecond}
+
+	var msgs []EmailMessage
+	for _, to := range []string{"a@example.com", "rejected@example.com", "busy@example.com", "b@example.com"} {
+		msgs = append(msgs, EmailMessage{To: []string{to}, Subject: "s", Body: "b"})
+	}
+	err := s.SendBatch(msgs)
+	var be *BatchError
+	if !errors.As(err, &be) {
+		t.Fatalf("SendBatch error = %v, want *BatchError", err)
+	}
+	if got := be.FailedIndexes(); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("failed = %v, want [1]", got)
+	}
+	if MessageErr(err, 0) != nil || MessageErr(err, 1) == nil {
+		t.Errorf("MessageErr(0) = %v, MessageErr(1) = %v", MessageErr(err, 0), MessageErr(err, 1))
+	}
+	if got := srv.delivered.Load(); got != 3 {
+		t.Errorf("delivered = %d, want 3 (the deferred message retried)", got)
+	}
+}
@@ -0,0 +1,258 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+//go:embed templates/*.html templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"temp": FormatTemperature,
+	"join": func(items []string) string { return strings.Join(items, ", ") },
+	"t":    translate,
+	"cid":  contentURL,
+}).ParseFS(templateFS, "templates/*.html", "templates/*.tmpl"))
+
+// strictTemplates fail on a missing map key instead of printing "<no value>".
+var strictTemplates = template.Must(templates.Clone()).Option("missingkey=error")
+
+var strict atomic.Bool
+
+// SetStrict turns strict rendering (EMAIL_TEMPLATES_STRICT) on or off. In strict mode a
+// template given data of another type than its sample data, or referring to a map key
+// its data does not have, fails to render; and every template is rendered with its
+// sample data right away, so a broken template stops the process at startup instead of
+// failing its sends.
+func SetStrict(on bool) error {
+	strict.Store(on)
+	if !on {
+		return nil
+	}
+	return checkSamples()
+}
+
+// translate is the "t" template function: the message key of locale, formatted with the
+// HTML-escaped args. Catalog messages are trusted and may contain markup.
+func translate(locale, key string, args ...any) template.HTML {
+	escaped := make([]any, len(args))
+	for i, a := range args {
+		if s, ok := a.(string); ok {
+			a = template.HTMLEscapeString(s)
+		}
+		escaped[i] = a
+	}
+	return template.HTML(i18n.T(locale, key, escaped...))
+}
+
+// contentURL is the "cid" template function: the URL of the inline part with content
+// ID id. html/template would otherwise replace the unknown cid: scheme.
+func contentURL(id string) template.URL {
+	return template.URL("cid:" + url.PathEscape(id))
+}
+
+// Display units of a subscription
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+// FormatTemperature renders a Celsius reading in the subscriber's units, e.g. "21.50°C".
+func FormatTemperature(celsius float64, units string) string {
+	if units == UnitsImperial {
+		return fmt.Sprintf("%.1f°F", celsius*9/5+32)
+	}
+	return fmt.Sprintf("%.2f°C", celsius)
+}
+
+// Template names
+const (
+	TemplateConfirmation  = "confirmation.html"
+	TemplateWeatherUpdate = "weather_update.html"
+	TemplateWelcome       = "welcome.html"
+	TemplateAnniversary   = "anniversary.html"
+	TemplateReEngagement  = "re_engagement.html"
+	TemplateRenewal       = "renewal.html"
+	TemplateAlert         = "alert.html"
+	TemplateWarning       = "warning.html"
+	TemplatePrivacy       = "privacy.html"
+)
+
+// ConfirmationData is the rendering context of TemplateConfirmation.
+type ConfirmationData struct {
+	Locale         string // selects the message catalog, see i18n
+	Subscriber     Subscriber
+	Cities         []string
+	Schedule       string // e.g. "every day at 07:00 UTC"
+	ConfirmURL     string
+	UnsubscribeURL string
+	NotMeURL       string // deletes the sign-up and suppresses the address for NotMeDays
+	NotMeDays      int
+}
+
+// Subscriber holds the personalization tokens of the recipient. Every one may be
+// missing, and templates fall back to neutral wording then.
+type Subscriber struct {
+	FirstName string // given on subscribe; empty when not
+	Days      int    // whole days since confirmation; 0 on the first day
+}
+
+// CityWeather is the current weather of one city of a subscription.
+type CityWeather struct {
+	City    string
+	Weather types.Weather
+	Sunrise string // local time of Weather.Sunrise in the subscription's timezone, e.g. "05:42"; empty when unknown
+	Chart   string // content ID of the inline temperature chart of the last 24 hours; empty when none
+	// Tomorrow is the forecast for the next local day, in daily emails; nil when none.
+	Tomorrow *types.Forecast
+}
+
+// WeatherUpdateData is the rendering context of TemplateWeatherUpdate.
+// It lists every city of the subscription.
+type WeatherUpdateData struct {
+	Locale         string // selects the message catalog, see i18n
+	Subscriber     Subscriber
+	Summary        string // two-sentence summary at the top; omitted when empty
+	Cities         []CityWeather
+	Timezone       string // IANA name the sunrise times are in
+	Units          string // 'metric' | 'imperial'
+	ManageURL      string
+	UnsubscribeURL string
+	OpenPixelURL   string       // open tracking; omitted when empty
+	Attributions   []string     // provider attribution lines for the footer
+	Features       features.Set // experimental content blocks enabled for the subscription
+}
+
+// WelcomeData is the rendering context of TemplateWelcome.
+type WelcomeData struct {
+	Subscriber     Subscriber
+	Cities         []CityWeather
+	Timezone       string // IANA name the sunrise times are in; no label when empty
+	Frequency      string // 'hourly' | 'daily' | 'alert' | 'warnings'
+	Schedule       string // human readable, e.g. "every day at 09:30 (Europe/Kyiv time)"
+	Units          string // 'metric' | 'imperial'
+	ManageURL      string
+	UnsubscribeURL string
+	OpenPixelURL   string       // open tracking; omitted when empty
+	Attributions   []string     // provider attribution lines for the footer
+	Features       features.Set // experimental content blocks enabled for the subscription
+}
+
+// AnniversaryData is the rendering context of TemplateAnniversary.
+type AnniversaryData struct {
+	Cities         []string
+	Since          string // confirmation date, e.g. "16 October 2025"
+	ManageURL      string
+	UnsubscribeURL string
+}
+
+// ReEngagementData is the rendering context of TemplateReEngagement.
+type ReEngagementData struct {
+	Cities         []string
+	KeepURL        string // a page confirming "keep sending"
+	ManageURL      string
+	UnsubscribeURL string
+}
+
+// RenewalData is the rendering context of TemplateRenewal.
+type RenewalData struct {
+	Cities         []string
+	ExpiredOn      string // e.g. "16 October 2026"
+	KeepURL        string // a page confirming the renewal
+	ManageURL      string
+	UnsubscribeURL string
+}
+
+// PrivacyData is the rendering context of TemplatePrivacy.
+type PrivacyData struct {
+	ExportURL string // downloads the data of the address
+	EraseURL  string // a page asking before erasing it
+	ValidFor  string // e.g. "24 hours"
+}
+
+// AlertData is the rendering context of TemplateAlert.
+type AlertData struct {
+	Locale         string // selects the message catalog, see i18n
+	Subscriber     Subscriber
+	Triggers       []AlertTrigger // every condition that holds, at least one
+	Units          string         // 'metric' | 'imperial'
+	ManageURL      string
+	UnsubscribeURL string
+	OpenPixelURL   string   // open tracking; omitted when empty
+	Attributions   []string // provider attribution lines for the footer
+}
+
+// Conditions of an AlertTrigger. The "_tomorrow" ones hold in tomorrow's forecast.
+const (
+	AlertTempAbove         = "temp_above"
+	AlertTempAboveTomorrow = "temp_above_tomorrow"
+	AlertTempBelow         = "temp_below"
+	AlertTempBelowTomorrow = "temp_below_tomorrow"
+	AlertWindAbove         = "wind_above"
+	AlertWindAboveTomorrow = "wind_above_tomorrow"
+	AlertRain              = "rain"
+	AlertRainTomorrow      = "rain_tomorrow"
+)
+
+// AlertTrigger is one alert condition that holds in a city.
+type AlertTrigger struct {
+	City        string
+	Condition   string  // one of the Alert* conditions, the message key after "alert."
+	Value       float64 // the observed or forecast °C, km/h or chance of precipitation (%)
+	Threshold   float64 // the subscriber's °C or km/h; unused for rain
+	Description string  // current conditions, for AlertRain
+}
+
+// WarningData is the rendering context of TemplateWarning.
+type WarningData struct {
+	Locale         string // selects the message catalog, see i18n
+	Subscriber     Subscriber
+	Warnings       []CityWarning // the new warnings, at least one
+	Timezone       string        // IANA name the expiry times are in
+	ManageURL      string
+	UnsubscribeURL string
+	OpenPixelURL   string   // open tracking; omitted when empty
+	Attributions   []string // provider attribution lines for the footer
+}
+
+// CityWarning is a severe weather warning issued for one city of a subscription.
+type CityWarning struct {
+	City    string
+	Warning types.Warning
+	Until   string // local time of Warning.Expires in the subscription's timezone, e.g. "17 Oct 18:00"; empty when open-ended
+}
+
+// DescribeCities names the cities of an email for its subject line.
+func DescribeCities(cities []CityWeather, locale string) string {
+	if len(cities) == 1 {
+		return cities[0].City
+	}
+	return i18n.T(locale, "update.cities_more", cities[0].City, len(cities)-1)
+}
+
+// Render executes the named template with data and returns the HTML body.
+func Render(name string, data any) (string, error) {
+	set := templates
+	if strict.Load() {
+		if want, ok := sampleTypes[name]; ok && reflect.TypeOf(data) != want {
+			return "", fmt.Errorf("render %s: data is %T, want %s", name, data, want)
+		}
+		set = strictTemplates
+	}
+	var buf bytes.Buffer
+	if err := set.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("render %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,108 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+func TestRender_Localized(t *testing.T) {
+	data := WeatherUpdateData{
+		Locale:         "uk",
+		Cities:         []CityWeather{{City: "<Kyiv>", Weather: types.Weather{Temp: 12.5, Humidity: 80, Description: "Rain"}}},
+		Units:          UnitsMetric,
+		ManageURL:      "https://example.com/api/manage/m",
+		UnsubscribeURL: "https://example.com/api/unsubscribe/u?sig=a&b",
+	}
+	body, err := Render(TemplateWeatherUpdate, data)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	for _, want := range []string{
+		"Поточна погода — <b>&lt;Kyiv&gt;</b>:", // markup of the catalog kept, arguments escaped
+		"Вологість: 80%",
+		`<a href="https://example.com/api/unsubscribe/u?sig=a&amp;b">відписатися</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+
+	data.Locale = "" // subscriptions without a supported locale get English
+	if body, _ = Render(TemplateWeatherUpdate, data); !strings.Contains(body, "Humidity: 80%") {
+		t.Errorf("body is not in English:\n%s", body)
+	}
+}
+
+func TestRender_Personalized(t *testing.T) {
+	data := WeatherUpdateData{
+		Locale:     "en",
+		Subscriber: Subscriber{FirstName: "<Anna>", Days: 12},
+		Cities:     []CityWeather{{City: "Kyiv", Sunrise: "07:28", Chart: "chart-kyiv@weather"}},
+		Timezone:   "Europe/Kyiv",
+	}
+	body, err := Render(TemplateWeatherUpdate, data)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	for _, want := range []string{"Hi &lt;Anna&gt;,", "Sunrise: 07:28 (Europe/Kyiv time)", "Day 12 of your weather updates",
+		`<img src="cid:chart-kyiv@weather"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+
+	// nothing known about the subscriber: neutral greeting, no sunrise, day count or chart
+	data.Subscriber, data.Cities[0].Sunrise, data.Cities[0].Chart = Subscriber{}, "", ""
+	body, _ = Render(TemplateWeatherUpdate, data)
+	if !strings.Contains(body, "Hi there,") || strings.Contains(body, "Sunrise") || strings.Contains(body, "Day ") ||
+		strings.Contains(body, "<img") {
+		t.Errorf("body without personalization tokens:\n%s", body)
+	}
+}
+
+func TestSetStrict(t *testing.T) {
+	if err := SetStrict(true); err != nil {
+		t.Fatalf("SetStrict(true): a template does not render with its sample data: %v", err)
+	}
+	defer SetStrict(false)
+
+	for _, tc := range []struct {
+		name, template string
+		data           any
+		wantErr        bool
+	}{
+		{"sample data", TemplateRenewal, samples[5].data, false},
+		{"data of another template", TemplateRenewal, AnniversaryData{Cities: sampleCities}, true},
+		{"pointer to the data", TemplatePrivacy, &PrivacyData{}, true},
+		{"other data of the template", TemplateAnniversary, AnniversaryData{Cities: sampleCities}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Render(tc.template, tc.data); (err != nil) != tc.wantErr {
+				t.Errorf("Render(%s) error = %v, want error %t", tc.template, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDocs(t *testing.T) {
+	docs := Docs()
+	if len(docs) != len(samples) || docs[1].Name != TemplateWeatherUpdate {
+		t.Fatalf("Docs() = %+v", docs)
+	}
+	vars := make(map[string]Variable)
+	for _, v := range docs[1].Variables {
+		vars[v.Name] = v
+	}
+	for name, typ := range map[string]string{
+		".Cities[].City":         "string",
+		".Cities[].Weather.Temp": "number",
+		".Attributions":          "list of string",
+		".Features.ai_summaries": "bool",
+	} {
+		if v, ok := vars[name]; !ok || v.Type != typ {
+			t.Errorf("variable %s = %+v, want type %s", name, v, typ)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+var asyncDropped = metrics.NewCounter("weather_events_dropped_total",
+	"Events dropped without an outbox: the delivery queue was full or the consumer failed.")
+
+// errQueueFull is returned by AsyncSink.Deliver when the queue has no room left.
+var errQueueFull = errors.New("events: delivery queue full")
+
+// AsyncSink delivers events to a sink in the background, so that a slow consumer never
+// holds up the request that published them. Delivery is best effort: an event is
+// dropped when the queue is full or its delivery fails; the outbox is the reliable way.
+type AsyncSink struct {
+	sink   Sink
+	queue  chan Event
+	logger *zap.Logger
+}
+
+// NewAsyncSink returns a sink queueing up to size events for sink; Run delivers them.
+func NewAsyncSink(sink Sink, size int, logger *zap.Logger) *AsyncSink {
+	return &AsyncSink{sink: sink, queue: make(chan Event, size), logger: logger}
+}
+
+// Deliver implements Sink: it queues e, without waiting.
+func (s *AsyncSink) Deliver(_ context.Context, e Event) error {
+	select {
+	case s.queue <- e:
+		return nil
+	default:
+		asyncDropped.Inc()
+		return errQueueFull
+	}
+}
+
+// Run delivers the queued events until ctx is done.
+func (s *AsyncSink) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-s.queue:
+			if err := s.sink.Deliver(ctx, e); err != nil {
+				asyncDropped.Inc()
+				s.logger.Warn("event delivery failed, dropped", zap.String("type", e.Type), zap.String("id", e.ID),
+					zap.Error(err))
+			}
+		}
+	}
+}
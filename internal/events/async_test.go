@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// blockingSink holds every delivery until released.
+type blockingSink struct {
+	release   chan struct{}
+	delivered chan Event
+}
+
+func (s *blockingSink) Deliver(ctx context.Context, e Event) error {
+	<-s.release
+	s.delivered <- e
+	return nil
+}
+
+func TestAsyncSink_DeliversOffTheCaller(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{}), delivered: make(chan Event, 2)}
+	async := NewAsyncSink(sink, 1, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go async.Run(ctx)
+
+	// the consumer hangs: the first event is in delivery, the second queued once Run
+	// took the first
+	deliver := func(id string) error {
+		deadline := time.Now().Add(time.Second)
+		for {
+			err := async.Deliver(ctx, Event{ID: id})
+			if !errors.Is(err, errQueueFull) || time.Now().After(deadline) {
+				return err
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for _, id := range []string{"e1", "e2"} {
+		if err := deliver(id); err != nil {
+			t.Fatalf("Deliver(%s) error: %v", id, err)
+		}
+	}
+	if err := async.Deliver(ctx, Event{ID: "e3"}); !errors.Is(err, errQueueFull) {
+		t.Errorf("Deliver() with a full queue = %v, want errQueueFull", err)
+	}
+
+	close(sink.release)
+	for _, want := range []string{"e1", "e2"} {
+		select {
+		case e := <-sink.delivered:
+			if e.ID != want {
+				t.Errorf("delivered %s, want %s", e.ID, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s not delivered", want)
+		}
+	}
+}
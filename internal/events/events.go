@@ -0,0 +1,143 @@
+// Package events defines the domain events the service publishes to downstream
+// consumers, and their versioned JSON Schemas, served at GET /api/events/schemas.
+//
+// Every event is validated against its schema before it leaves the process, so a
+// code change that drifts from the published contract fails loudly instead of
+// breaking consumers.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// Event types.
+const (
+	TypeSubscriptionCreated      = "subscription.created"
+	TypeSubscriptionConfirmed    = "subscription.confirmed"
+	TypeSubscriptionUnsubscribed = "subscription.unsubscribed"
+)
+
+// Event is the envelope of a published event.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Payload is the data of an event, tied to the schema version it is built for.
+type Payload interface {
+	EventType() string
+	EventVersion() int
+}
+
+// SubscriptionCreated is the data of subscription.created v1.
+type SubscriptionCreated struct {
+	SubscriptionID int      `json:"subscription_id"`
+	Cities         []string `json:"cities"`
+	Frequency      string   `json:"frequency"`
+	Timezone       string   `json:"timezone"`
+	SendHour       *int16   `json:"send_hour"`
+}
+
+func (SubscriptionCreated) EventType() string { return TypeSubscriptionCreated }
+func (SubscriptionCreated) EventVersion() int { return 1 }
+
+// SubscriptionConfirmed is the data of subscription.confirmed v1.
+type SubscriptionConfirmed struct {
+	SubscriptionID int `json:"subscription_id"`
+}
+
+func (SubscriptionConfirmed) EventType() string { return TypeSubscriptionConfirmed }
+func (SubscriptionConfirmed) EventVersion() int { return 1 }
+
+// SubscriptionUnsubscribed is the data of subscription.unsubscribed v1.
+type SubscriptionUnsubscribed struct {
+	SubscriptionID int `json:"subscription_id"`
+}
+
+func (SubscriptionUnsubscribed) EventType() string { return TypeSubscriptionUnsubscribed }
+func (SubscriptionUnsubscribed) EventVersion() int { return 1 }
+
+// Sink delivers validated events.
+type Sink interface {
+	Deliver(ctx context.Context, e Event) error
+}
+
+// Publisher validates events and hands them to a sink.
+type Publisher struct {
+	sink   Sink // nil: events are validated and dropped
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+func NewPublisher(sink Sink, logger *zap.Logger) *Publisher {
+	return &Publisher{sink: sink, logger: logger, now: time.Now}
+}
+
+// Publish wraps payload in an envelope, validates it against its schema and delivers it.
+// A payload that does not match its schema is never delivered.
+func (p *Publisher) Publish(ctx context.Context, payload Payload) error {
+	e, err := p.wrap(payload)
+	if err != nil {
+		return err
+	}
+	if p.sink == nil {
+		p.logger.Debug("event published without a sink", zap.String("type", e.Type), zap.String("id", e.ID))
+		return nil
+	}
+	return p.sink.Deliver(ctx, e)
+}
+
+// Stage prepares the event build returns for a change of a subscription, before the
+// change. When p delivers to the outbox, the returned context carries the event, which
+// the repository writes in the transaction of the change made with it. publish, called
+// once the change of subscription id is committed, publishes the event unless that
+// transaction stored it already.
+func (p *Publisher) Stage(ctx context.Context, build func(id int) Payload,
+) (_ context.Context, publish func(ctx context.Context, id int) error) {
+	staged := &repository.OutboxEvent{Build: func(id int) (string, []byte, error) {
+		e, err := p.wrap(build(id))
+		if err != nil {
+			return "", nil, err
+		}
+		body, err := json.Marshal(e)
+		return e.Type, body, err
+	}}
+	if _, ok := p.sink.(*OutboxSink); ok {
+		ctx = repository.WithOutboxEvent(ctx, staged)
+	}
+	return ctx, func(ctx context.Context, id int) error {
+		if staged.Written() {
+			return nil
+		}
+		return p.Publish(ctx, build(id))
+	}
+}
+
+// wrap wraps payload in an envelope, once validated against its schema.
+func (p *Publisher) wrap(payload Payload) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("events: marshal %s: %w", payload.EventType(), err)
+	}
+	if err := Validate(payload.EventType(), payload.EventVersion(), data); err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:         uuid.NewString(),
+		Type:       payload.EventType(),
+		Version:    payload.EventVersion(),
+		OccurredAt: p.now().UTC(),
+		Data:       data,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type recordingSink struct {
+	events []Event
+}
+
+func (s *recordingSink) Deliver(_ context.Context, e Event) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestSchemas_CoverEveryPayload(t *testing.T) {
+	for _, p := range []Payload{SubscriptionCreated{}, SubscriptionConfirmed{}, SubscriptionUnsubscribed{}} {
+		if _, ok := registry[schemaKey(p.EventType(), p.EventVersion())]; !ok {
+			t.Errorf("no schema for %s v%d", p.EventType(), p.EventVersion())
+		}
+	}
+	if envelope.root == nil {
+		t.Error("envelope schema not loaded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want string // substring of the error, "" for valid
+	}{
+		{"valid", `{"subscription_id":1,"cities":["Kyiv"],"frequency":"daily","timezone":"UTC","send_hour":8}`, ""},
+		{"null send hour", `{"subscription_id":1,"cities":["Kyiv"],"frequency":"hourly","timezone":"UTC","send_hour":null}`, ""},
+		{"missing", `{"subscription_id":1,"cities":["Kyiv"],"frequency":"daily"}`, `missing required property "timezone"`},
+		{"enum", `{"subscription_id":1,"cities":["Kyiv"],"frequency":"weekly","timezone":"UTC"}`, "data.frequency"},
+		{"extra", `{"subscription_id":1,"cities":["Kyiv"],"frequency":"daily","timezone":"UTC","email":"a@b.c"}`, `unexpected property "email"`},
+		{"empty cities", `{"subscription_id":1,"cities":[],"frequency":"daily","timezone":"UTC"}`, "at least 1 items"},
+		{"fractional id", `{"subscription_id":1.5,"cities":["Kyiv"],"frequency":"daily","timezone":"UTC"}`, "type integer"},
+		{"hour range", `{"subscription_id":1,"cities":["Kyiv"],"frequency":"daily","timezone":"UTC","send_hour":24}`, "at most 23"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(TypeSubscriptionCreated, 1, []byte(tc.data))
+			if tc.want == "" && err != nil {
+				t.Fatalf("Validate() error: %v", err)
+			}
+			if tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+				t.Fatalf("Validate() error = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+
+	if err := Validate("subscription.paused", 1, []byte(`{}`)); !errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("Validate() of an unknown type: err = %v, want ErrUnknownSchema", err)
+	}
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	sink := &recordingSink{}
+	p := NewPublisher(sink, zap.NewNop())
+
+	if err := p.Publish(context.Background(), SubscriptionConfirmed{SubscriptionID: 7}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if len(sink.events) != 1 {
+		t.Fatalf("delivered %d events, want 1", len(sink.events))
+	}
+	e := sink.events[0]
+	if e.Type != TypeSubscriptionConfirmed || e.Version != 1 || e.ID == "" || string(e.Data) != `{"subscription_id":7}` {
+		t.Errorf("delivered %+v", e)
+	}
+	body, _ := json.Marshal(e)
+	if err := envelope.root.validate("event", decodeNumbers(t, body)); err != nil {
+		t.Errorf("envelope does not match its schema: %v", err)
+	}
+
+	// an invalid payload never reaches the sink
+	if err := p.Publish(context.Background(), SubscriptionCreated{SubscriptionID: 7, Frequency: "daily"}); err == nil {
+		t.Error("Publish() accepted a payload that does not match its schema")
+	}
+	if len(sink.events) != 1 {
+		t.Errorf("an invalid event was delivered")
+	}
+}
+
+func decodeNumbers(t *testing.T, b []byte) any {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
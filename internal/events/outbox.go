@@ -0,0 +1,135 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+const (
+	relayClaimLimit   = 100
+	relayPollInterval = time.Second
+	relayLease        = time.Minute
+	relayMaxBackoff   = time.Hour
+	// sent messages are kept this long for tracing, then purged by the relays
+	relaySentRetention = 7 * 24 * time.Hour
+)
+
+var (
+	relayDelivered = metrics.NewCounter("weather_outbox_delivered_total",
+		"Outbox messages relayed to their consumer.")
+	relayFailed = metrics.NewCounter("weather_outbox_failures_total",
+		"Outbox message deliveries that failed and were rescheduled.")
+)
+
+// Outbox is the durable queue events go through, see repository.OutboxRepository.
+type Outbox interface {
+	EnqueueMessage(ctx context.Context, topic string, payload []byte) (int64, error)
+	ClaimPending(ctx context.Context, worker string, limit int, lease time.Duration) ([]repository.OutboxMessage, error)
+	MarkSent(ctx context.Context, ids []int64) error
+	Retry(ctx context.Context, id int64, runAt time.Time, reason string) error
+	PurgeSent(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// OutboxSink stores events in the outbox, under their type, for a Relay to deliver: a
+// consumer that is down delays events instead of losing them. Events staged with
+// Publisher.Stage are written in the transaction of their change instead, so only
+// those of changes made without one go through Deliver.
+type OutboxSink struct {
+	outbox Outbox
+}
+
+func NewOutboxSink(outbox Outbox) *OutboxSink {
+	return &OutboxSink{outbox: outbox}
+}
+
+// Deliver implements Sink.
+func (s *OutboxSink) Deliver(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("events: marshal envelope: %w", err)
+	}
+	if _, err := s.outbox.EnqueueMessage(ctx, e.Type, body); err != nil {
+		return fmt.Errorf("events: enqueue %s: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Relay delivers the events of the outbox to a sink. Relays in every replica share the
+// outbox: each claims pending events for a lease, delivers them and marks them sent. A
+// failed delivery is tried again after backoff, doubling up to an hour, until the
+// consumer takes it; a relay that dies leaves its events to be claimed again when
+// their lease is over.
+type Relay struct {
+	outbox    Outbox
+	sink      Sink
+	id        string
+	backoff   time.Duration
+	logger    *zap.Logger
+	lastPurge time.Time
+}
+
+// NewRelay returns a relay named id, unique among the running relays.
+func NewRelay(outbox Outbox, sink Sink, id string, backoff time.Duration, logger *zap.Logger) *Relay {
+	return &Relay{outbox: outbox, sink: sink, id: id, backoff: backoff, logger: logger}
+}
+
+// Run relays events until ctx is done, finishing the events in hand.
+func (r *Relay) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.RelayOnce(context.WithoutCancel(ctx), time.Now())
+		if err != nil || n == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(relayPollInterval):
+			}
+		}
+	}
+}
+
+// RelayOnce claims a batch of pending events and delivers them, returning how many it
+// claimed. Once a day it also purges the events sent long ago.
+func (r *Relay) RelayOnce(ctx context.Context, now time.Time) (int, error) {
+	if now.Sub(r.lastPurge) >= 24*time.Hour {
+		r.lastPurge = now
+		if n, err := r.outbox.PurgeSent(ctx, now.Add(-relaySentRetention)); err == nil && n > 0 {
+			r.logger.Info("purged sent outbox messages", zap.Int64("deleted", n))
+		}
+	}
+
+	msgs, err := r.outbox.ClaimPending(ctx, r.id, relayClaimLimit, relayLease)
+	if err != nil {
+		return 0, err
+	}
+	var sent []int64
+	for _, m := range msgs {
+		var e Event
+		err := json.Unmarshal(m.Payload, &e)
+		if err == nil {
+			err = r.sink.Deliver(ctx, e)
+		}
+		if err == nil {
+			sent = append(sent, m.ID)
+			continue
+		}
+		relayFailed.Inc()
+		runAt := now.Add(min(r.backoff<<min(m.Attempts-1, 20), relayMaxBackoff))
+		r.logger.Warn("event delivery failed, retrying", zap.Int64("message", m.ID), zap.String("topic", m.Topic),
+			zap.Int("attempts", m.Attempts), zap.Time("retry_at", runAt), zap.Error(err))
+		if err := r.outbox.Retry(ctx, m.ID, runAt, err.Error()); err != nil {
+			r.logger.Warn("failed to reschedule event, retrying after the lease", zap.Int64("message", m.ID), zap.Error(err))
+		}
+	}
+	if err := r.outbox.MarkSent(ctx, sent); err != nil {
+		// the lease runs out and the events are delivered again
+		return len(msgs), err
+	}
+	relayDelivered.Add(float64(len(sent)))
+	return len(msgs), nil
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// memOutbox is an outbox whose pending messages are all claimed at once.
+type memOutbox struct {
+	pending []repository.OutboxMessage
+	sent    []int64
+	retried map[int64]time.Time
+}
+
+func (o *memOutbox) EnqueueMessage(_ context.Context, topic string, payload []byte) (int64, error) {
+	id := int64(len(o.pending) + 1)
+	o.pending = append(o.pending, repository.OutboxMessage{ID: id, Topic: topic, Payload: payload})
+	return id, nil
+}
+
+func (o *memOutbox) ClaimPending(context.Context, string, int, time.Duration) ([]repository.OutboxMessage, error) {
+	claimed := o.pending
+	o.pending = nil
+	for i := range claimed {
+		claimed[i].Attempts++
+	}
+	return claimed, nil
+}
+
+func (o *memOutbox) MarkSent(_ context.Context, ids []int64) error {
+	o.sent = append(o.sent, ids...)
+	return nil
+}
+
+func (o *memOutbox) Retry(_ context.Context, id int64, runAt time.Time, _ string) error {
+	o.retried[id] = runAt
+	return nil
+}
+
+func (o *memOutbox) PurgeSent(context.Context, time.Time) (int64, error) { return 0, nil }
+
+// flakySink rejects events of one type.
+type flakySink struct {
+	recordingSink
+	reject string
+}
+
+func (s *flakySink) Deliver(ctx context.Context, e Event) error {
+	if e.Type == s.reject {
+		return errors.New("consumer unavailable")
+	}
+	return s.recordingSink.Deliver(ctx, e)
+}
+
+func TestRelay_DeliversThroughOutbox(t *testing.T) {
+	outbox := &memOutbox{retried: map[int64]time.Time{}}
+	p := NewPublisher(NewOutboxSink(outbox), zap.NewNop())
+	if err := p.Publish(context.Background(), SubscriptionConfirmed{SubscriptionID: 1}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if err := p.Publish(context.Background(), SubscriptionUnsubscribed{SubscriptionID: 2}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	sink := &flakySink{reject: TypeSubscriptionUnsubscribed}
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	n, err := NewRelay(outbox, sink, "api-1", 10*time.Second, zap.NewNop()).RelayOnce(context.Background(), now)
+	if err != nil || n != 2 {
+		t.Fatalf("RelayOnce() = %d, %v; want 2 claimed", n, err)
+	}
+	if len(sink.events) != 1 || sink.events[0].Type != TypeSubscriptionConfirmed {
+		t.Errorf("delivered %+v, want the confirmed event", sink.events)
+	}
+	if len(outbox.sent) != 1 || outbox.sent[0] != 1 {
+		t.Errorf("marked sent %v, want [1]", outbox.sent)
+	}
+	if got := outbox.retried[2]; !got.Equal(now.Add(10 * time.Second)) {
+		t.Errorf("retry of message 2 at %v, want %v", got, now.Add(10*time.Second))
+	}
+}
+
+func TestPublisher_Stage_PublishesWhatTheChangeDidNotStore(t *testing.T) {
+	outbox := &memOutbox{}
+	sink := &recordingSink{}
+	for _, p := range []*Publisher{NewPublisher(NewOutboxSink(outbox), zap.NewNop()), NewPublisher(sink, zap.NewNop())} {
+		_, publish := p.Stage(context.Background(), func(id int) Payload {
+			return SubscriptionUnsubscribed{SubscriptionID: id}
+		})
+		// no repository change wrote the event: it is published after the change
+		if err := publish(context.Background(), 7); err != nil {
+			t.Fatalf("publish() error: %v", err)
+		}
+	}
+	if len(outbox.pending) != 1 || outbox.pending[0].Topic != TypeSubscriptionUnsubscribed {
+		t.Errorf("outbox holds %+v, want the event", outbox.pending)
+	}
+	if len(sink.events) != 1 || string(sink.events[0].Data) != `{"subscription_id":7}` {
+		t.Errorf("sink received %+v, want the event", sink.events)
+	}
+}
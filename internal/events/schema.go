@@ -0,0 +1,262 @@
+package events
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The schemas are JSON Schema documents named "<type>.v<version>.json". A published
+// version is never changed incompatibly: adding an optional property is done in place,
+// anything else (removing or renaming a property, narrowing a type) adds a new version
+// next to the old one.
+//
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// envelopeName is the file of the envelope schema, which is not an event type.
+const envelopeName = "envelope.v1.json"
+
+// ErrUnknownSchema is returned when no schema is registered for an event type and version.
+var ErrUnknownSchema = errors.New("events: unknown event type or version")
+
+// Schema is the published JSON Schema of one version of an event type's data.
+type Schema struct {
+	Type     string
+	Version  int
+	Document json.RawMessage
+	root     *schema
+}
+
+var (
+	envelope Schema
+	registry = map[string]Schema{} // by schemaKey
+)
+
+func init() {
+	entries, err := schemaFS.ReadDir("schemas")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		doc, err := schemaFS.ReadFile(path.Join("schemas", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var root schema
+		if err := json.Unmarshal(doc, &root); err != nil {
+			panic(fmt.Sprintf("events: schema %s: %v", e.Name(), err))
+		}
+		if e.Name() == envelopeName {
+			envelope = Schema{Type: "envelope", Version: 1, Document: doc, root: &root}
+			continue
+		}
+		typ, version, ok := parseSchemaName(e.Name())
+		if !ok {
+			panic(fmt.Sprintf("events: schema file %s must be named <type>.v<version>.json", e.Name()))
+		}
+		registry[schemaKey(typ, version)] = Schema{Type: typ, Version: version, Document: doc, root: &root}
+	}
+}
+
+func parseSchemaName(name string) (string, int, bool) {
+	base, ok := strings.CutSuffix(name, ".json")
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndex(base, ".v")
+	if i <= 0 {
+		return "", 0, false
+	}
+	version, err := strconv.Atoi(base[i+2:])
+	if err != nil || version < 1 {
+		return "", 0, false
+	}
+	return base[:i], version, true
+}
+
+func schemaKey(typ string, version int) string {
+	return typ + ".v" + strconv.Itoa(version)
+}
+
+// Envelope returns the schema of the envelope wrapping every event.
+func Envelope() Schema {
+	return envelope
+}
+
+// Schemas returns the schemas of every event type and version, ordered by type and version.
+func Schemas() []Schema {
+	out := make([]Schema, 0, len(registry))
+	for _, s := range registry {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Type != out[j].Type {
+			return out[i].Type < out[j].Type
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out
+}
+
+// Validate checks data against the schema of typ at version.
+func Validate(typ string, version int, data []byte) error {
+	s, ok := registry[schemaKey(typ, version)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSchema, schemaKey(typ, version))
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("events: %s: %w", schemaKey(typ, version), err)
+	}
+	if err := s.root.validate("data", v); err != nil {
+		return fmt.Errorf("events: %s: %w", schemaKey(typ, version), err)
+	}
+	return nil
+}
+
+// schema is the subset of JSON Schema the event schemas use.
+type schema struct {
+	Type                 typeList           `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []any              `json:"enum"`
+	MinItems             *int               `json:"minItems"`
+	MinLength            *int               `json:"minLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Format               string             `json:"format"`
+}
+
+// typeList is the "type" keyword: one type name or a list of them.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*t = typeList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+// validate checks v, decoded with UseNumber, against s; at names v in errors.
+func (s *schema) validate(at string, v any) error {
+	if len(s.Type) > 0 && !s.Type.matches(v) {
+		return fmt.Errorf("%s: must be of type %s", at, strings.Join(s.Type, " or "))
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", at, v, s.Enum)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		for name, value := range v {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unexpected property %q", at, name)
+				}
+				continue
+			}
+			if err := prop.validate(at+"."+name, value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fmt.Errorf("%s: must have at least %d items", at, *s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", at, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if s.MinLength != nil && len([]rune(v)) < *s.MinLength {
+			return fmt.Errorf("%s: must be at least %d characters", at, *s.MinLength)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return fmt.Errorf("%s: must be an RFC 3339 date-time", at)
+			}
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: must be at least %v", at, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: must be at most %v", at, *s.Maximum)
+		}
+	}
+	return nil
+}
+
+func (t typeList) matches(v any) bool {
+	for _, name := range t {
+		switch v := v.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case json.Number:
+			if name == "number" {
+				return true
+			}
+			if f, err := v.Float64(); name == "integer" && err == nil && f == math.Trunc(f) {
+				return true
+			}
+		case []any:
+			if name == "array" {
+				return true
+			}
+		case map[string]any:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(enum []any, v any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
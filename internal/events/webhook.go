@@ -0,0 +1,54 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/signing"
+)
+
+// WebhookSink POSTs every event as JSON to a consumer URL, signed with the event
+// signing keys when they are configured.
+type WebhookSink struct {
+	url    string
+	keys   *signing.Keyring
+	client *http.Client
+}
+
+// NewWebhookSink returns a sink posting to url, or nil when url is empty.
+func NewWebhookSink(url string, keys *signing.Keyring) Sink {
+	if url == "" {
+		return nil
+	}
+	return &WebhookSink{url: url, keys: keys, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Deliver implements Sink. Any status but 2xx is an error.
+func (w *WebhookSink) Deliver(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("events: marshal envelope: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("events: failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", e.Type)
+	if err := w.keys.SignRequest(req, body); err != nil {
+		return fmt.Errorf("events: sign: %w", err)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("events: HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("events: unexpected status %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package features holds the feature flags gating experimental email content.
+//
+// Every flag is in one rollout stage, set with FEATURE_FLAGS: "off" (the default), "beta"
+// (only subscriptions that opted in to beta features on the manage page get it) or "on"
+// (everyone). A new content block starts in beta with volunteers and is switched on
+// once it has proven itself.
+package features
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+// Stage is the rollout stage of a flag.
+type Stage string
+
+const (
+	StageOff  Stage = "off"
+	StageBeta Stage = "beta"
+	StageOn   Stage = "on"
+)
+
+// Flag is an experimental feature.
+type Flag struct {
+	Name        string
+	Description string // shown to subscribers deciding whether to opt in
+}
+
+var (
+	// AISummaries adds a short written summary of the weather to the top of emails.
+	AISummaries = Flag{Name: "ai_summaries", Description: "A short written summary of the weather at the top of your emails."}
+	// RadarImages adds precipitation radar images of the subscribed cities.
+	RadarImages = Flag{Name: "radar_images", Description: "Precipitation radar images of your cities."}
+	// TemperatureCharts adds a chart of the last 24 hours of temperature to daily emails.
+	TemperatureCharts = Flag{Name: "temperature_charts", Description: "A chart of the last 24 hours of temperature in your daily emails."}
+)
+
+// All lists every flag, in the order they are shown to subscribers.
+var All = []Flag{AISummaries, RadarImages, TemperatureCharts}
+
+// Flags holds the configured stage of every flag. A nil *Flags has every flag off.
+type Flags struct {
+	stages map[string]Stage
+}
+
+// New parses FEATURE_FLAGS.
+func New(cfg *config.Config) (*Flags, error) {
+	return Parse(cfg.FeatureFlags)
+}
+
+// Parse parses "name=stage" entries. Flags not listed are off.
+func Parse(entries []string) (*Flags, error) {
+	known := make(map[string]bool, len(All))
+	for _, f := range All {
+		known[f.Name] = true
+	}
+	f := &Flags{stages: make(map[string]Stage, len(entries))}
+	for _, e := range entries {
+		name, stage, ok := strings.Cut(e, "=")
+		name, stage = strings.TrimSpace(name), strings.ToLower(strings.TrimSpace(stage))
+		if !ok || !known[name] {
+			return nil, fmt.Errorf("unknown feature flag %q (known: %s)", e, names())
+		}
+		switch Stage(stage) {
+		case StageOff, StageBeta, StageOn:
+			f.stages[name] = Stage(stage)
+		default:
+			return nil, fmt.Errorf("feature flag %s: stage must be off, beta or on, got %q", name, stage)
+		}
+	}
+	return f, nil
+}
+
+func names() string {
+	out := make([]string, 0, len(All))
+	for _, f := range All {
+		out = append(out, f.Name)
+	}
+	return strings.Join(out, ", ")
+}
+
+// Stage returns the stage of flag.
+func (f *Flags) Stage(flag Flag) Stage {
+	if f == nil {
+		return StageOff
+	}
+	if s, ok := f.stages[flag.Name]; ok {
+		return s
+	}
+	return StageOff
+}
+
+// Enabled reports whether flag is on for a subscription that did (beta) or did not
+// opt in to beta features.
+func (f *Flags) Enabled(flag Flag, beta bool) bool {
+	switch f.Stage(flag) {
+	case StageOn:
+		return true
+	case StageBeta:
+		return beta
+	default:
+		return false
+	}
+}
+
+// InBeta returns the flags currently offered to subscribers who opt in.
+func (f *Flags) InBeta() []Flag {
+	var out []Flag
+	for _, flag := range All {
+		if f.Stage(flag) == StageBeta {
+			out = append(out, flag)
+		}
+	}
+	return out
+}
+
+// Set is the flags enabled for one subscription, by name, for use in email templates:
+// {{if .Features.ai_summaries}}.
+type Set map[string]bool
+
+// For returns the flags enabled for a subscription that did or did not opt in to beta
+// features. Every flag has an entry, so strict template rendering accepts it.
+func (f *Flags) For(beta bool) Set {
+	set := make(Set, len(All))
+	for _, flag := range All {
+		set[flag.Name] = f.Enabled(flag, beta)
+	}
+	return set
+}
+
+// Has reports whether flag is in the set.
+func (s Set) Has(flag Flag) bool {
+	return s[flag.Name]
+}
@@ -0,0 +1,36 @@
+package features
+
+import "testing"
+
+func TestFlags_Enabled(t *testing.T) {
+	f, err := Parse([]string{"ai_summaries=beta", "radar_images=ON"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	if f.Enabled(AISummaries, false) || !f.Enabled(AISummaries, true) {
+		t.Error("a beta flag must be enabled for opted-in subscriptions only")
+	}
+	if !f.Enabled(RadarImages, false) {
+		t.Error("a flag that is on must be enabled for everyone")
+	}
+	if got := f.InBeta(); len(got) != 1 || got[0] != AISummaries {
+		t.Errorf("InBeta() = %v, want [ai_summaries]", got)
+	}
+	if set := f.For(false); set.Has(AISummaries) || !set.Has(RadarImages) {
+		t.Errorf("For(false) = %v", set)
+	}
+
+	var none *Flags
+	if none.Enabled(RadarImages, true) {
+		t.Error("a nil *Flags must have every flag off")
+	}
+}
+
+func TestParse_Rejects(t *testing.T) {
+	for _, entries := range [][]string{{"teleport=on"}, {"ai_summaries"}, {"ai_summaries=maybe"}} {
+		if _, err := Parse(entries); err == nil {
+			t.Errorf("Parse(%q) accepted an invalid entry", entries)
+		}
+	}
+}
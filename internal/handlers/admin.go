@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slo"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+)
+
+// UsageReportHandler handles GET /api/admin/usage?month=YYYY-MM
+// and returns the estimated provider cost for that month (current month by default).
+func UsageReportHandler(usage *weather.UsageTracker, prices map[string]float64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		month, err := weather.ParseReportMonth(c.Query("month"))
+		if err != nil {
+			// 400 Invalid month
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month must be in YYYY-MM format"})
+			return
+		}
+
+		report, err := weather.BuildCostReport(c.Request.Context(), usage, prices, month)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 200 Report
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// SLOReportHandler handles GET /api/admin/slo and returns SLI, burn rate and
+// alerting state for every objective, for consumption by alerting systems.
+func SLOReportHandler(tracker *slo.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"objectives": tracker.Report(c.Request.Context())})
+	}
+}
+
+// StatsHandler handles GET /api/admin/stats?days=30 and returns the subscription
+// statistics of the last days days (30 by default): subscribers per city and per
+// frequency, confirmations and unsubscribes per day, and churn over the period.
+func StatsHandler(svc services.StatsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+		if err != nil {
+			// 400 Invalid days
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidStatsPeriod.Error()})
+			return
+		}
+
+		stats, err := svc.Stats(c.Request.Context(), days)
+		switch {
+		case err == nil:
+			// 200 Statistics
+			c.JSON(http.StatusOK, stats)
+		case errors.Is(err, services.ErrInvalidStatsPeriod):
+			// 400 Period out of range
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// RawWeatherHandler handles GET /api/admin/weather/raw?city=... and returns the last raw
+// response of every provider for that city, as stored by the raw response cache.
+func RawWeatherHandler(store *weather.RawStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		city := c.Query("city")
+		if city == "" {
+			// 400 Missing city
+			c.JSON(http.StatusBadRequest, gin.H{"error": "city is required"})
+			return
+		}
+
+		responses, err := store.Get(c.Request.Context(), city)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(responses) == 0 {
+			// 404 Nothing cached (expired, never fetched, or cache disabled)
+			c.JSON(http.StatusNotFound, gin.H{"error": "no raw responses stored for this city"})
+			return
+		}
+
+		// 200 Raw responses by provider
+		c.JSON(http.StatusOK, gin.H{"city": city, "providers": responses})
+	}
+}
+
+// TemplatesHandler handles GET /api/admin/templates and lists the variables of every
+// email template, with sample values, and the template functions.
+func TemplatesHandler() gin.HandlerFunc {
+	docs := email.Docs()
+	return func(c *gin.Context) {
+		// 200 Template variables
+		c.JSON(http.StatusOK, gin.H{"templates": docs, "functions": email.Funcs})
+	}
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// listSubscriptionsRequest defines the query parameters of GET /api/admin/subscriptions
+// and, but for the page, of its CSV export
+type listSubscriptionsRequest struct {
+	City         string               `form:"city"`
+	Frequency    repository.Frequency `form:"frequency" binding:"omitempty,oneof=hourly daily"`
+	Confirmed    *bool                `form:"confirmed"`
+	DeadLettered bool                 `form:"dead_lettered"` // only dead-lettered subscriptions
+	Deleted      bool                 `form:"deleted"`       // only unsubscribed subscriptions not yet purged
+	CreatedFrom  time.Time            `form:"created_from"`  // RFC 3339, inclusive
+	CreatedTo    time.Time            `form:"created_to"`    // RFC 3339, exclusive
+	Page         int                  `form:"page"      binding:"omitempty,min=1"`
+	PageSize     int                  `form:"page_size" binding:"omitempty,min=1"`
+}
+
+func (r listSubscriptionsRequest) filter() repository.SubscriptionFilter {
+	return repository.SubscriptionFilter{
+		City:         r.City,
+		Frequency:    r.Frequency,
+		Confirmed:    r.Confirmed,
+		DeadLettered: r.DeadLettered,
+		Deleted:      r.Deleted,
+		CreatedFrom:  r.CreatedFrom,
+		CreatedTo:    r.CreatedTo,
+	}
+}
+
+// adminSubscription is the admin view of a subscription (tokens are never exposed)
+type adminSubscription struct {
+	ID              int                  `json:"id"`
+	Email           string               `json:"email"`
+	City            string               `json:"city"`
+	Cities          []string             `json:"cities"`
+	Frequency       repository.Frequency `json:"frequency"`
+	Confirmed       bool                 `json:"confirmed"`
+	ScheduledHour   int16                `json:"scheduled_hour"`
+	ScheduledMinute int16                `json:"scheduled_minute"`
+	CreatedAt       time.Time            `json:"created_at"`
+	ConfirmedAt     *time.Time           `json:"confirmed_at,omitempty"`
+	Failures        int16                `json:"consecutive_failures"` // failed sends in a row
+	DeadLetteredAt  *time.Time           `json:"dead_lettered_at,omitempty"`
+	LastSentAt      *time.Time           `json:"last_sent_at,omitempty"`
+	LastAttemptedAt *time.Time           `json:"last_attempted_at,omitempty"`
+	DeletedAt       *time.Time           `json:"deleted_at,omitempty"` // unsubscribed
+}
+
+func toAdminSubscriptions(subs []repository.Subscription) []adminSubscription {
+	out := make([]adminSubscription, 0, len(subs))
+	for _, s := range subs {
+		item := adminSubscription{
+			ID:              s.ID,
+			Email:           s.Email,
+			City:            s.City,
+			Cities:          s.AllCities(),
+			Frequency:       s.Frequency,
+			Confirmed:       s.Confirmed,
+			ScheduledHour:   s.ScheduledHour,
+			ScheduledMinute: s.ScheduledMinute,
+			CreatedAt:       s.CreatedAt,
+			Failures:        s.ConsecutiveFailures,
+		}
+		if s.ConfirmedAt.Valid {
+			item.ConfirmedAt = &s.ConfirmedAt.Time
+		}
+		if s.DeadLetteredAt.Valid {
+			item.DeadLetteredAt = &s.DeadLetteredAt.Time
+		}
+		if s.LastSentAt.Valid {
+			item.LastSentAt = &s.LastSentAt.Time
+		}
+		if s.LastAttemptedAt.Valid {
+			item.LastAttemptedAt = &s.LastAttemptedAt.Time
+		}
+		if s.DeletedAt.Valid {
+			item.DeletedAt = &s.DeletedAt.Time
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// ListSubscriptionsHandler handles GET /api/admin/subscriptions
+func ListSubscriptionsHandler(svc services.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req listSubscriptionsRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			// 400 Invalid filters
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := svc.ListSubscriptions(c.Request.Context(), req.filter(), req.Page, req.PageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 200 Page of subscriptions
+		c.JSON(http.StatusOK, gin.H{
+			"items":     toAdminSubscriptions(page.Items),
+			"total":     page.Total,
+			"page":      page.Page,
+			"page_size": page.PageSize,
+		})
+	}
+}
+
+// csvHeader is the first row of the CSV export of subscriptions.
+var csvHeader = []string{"id", "email", "cities", "frequency", "units", "timezone", "locale", "confirmed",
+	"created_at", "confirmed_at", "last_sent_at", "consecutive_failures", "dead_lettered_at", "deleted_at"}
+
+func csvRecord(s repository.Subscription) []string {
+	at := func(t sql.NullTime) string {
+		if !t.Valid {
+			return ""
+		}
+		return t.Time.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		strconv.Itoa(s.ID), csvText(s.Email), csvText(strings.Join(s.AllCities(), ", ")), string(s.Frequency),
+		string(s.Units), csvText(s.Timezone), csvText(s.Locale), strconv.FormatBool(s.Confirmed),
+		s.CreatedAt.UTC().Format(time.RFC3339), at(s.ConfirmedAt), at(s.LastSentAt),
+		strconv.Itoa(int(s.ConsecutiveFailures)), at(s.DeadLetteredAt), at(s.DeletedAt),
+	}
+}
+
+// csvText returns a cell of text a subscriber chose, quoted with a leading ' when a
+// spreadsheet opening the export would take it for a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ExportSubscriptionsHandler handles GET /api/admin/subscriptions/export, every
+// subscription matching the filters of the listing as text/csv, by id. Rows are
+// streamed a batch at a time, so large exports are not held in memory; a failure
+// midway truncates the file, and is logged.
+func ExportSubscriptionsHandler(svc services.AdminService, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req listSubscriptionsRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			// 400 Invalid filters
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="subscriptions.csv"`)
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(csvHeader); err != nil {
+			return
+		}
+		err := svc.ExportSubscriptions(c.Request.Context(), req.filter(), func(subs []repository.Subscription) error {
+			for _, s := range subs {
+				if err := w.Write(csvRecord(s)); err != nil {
+					return err
+				}
+			}
+			w.Flush()
+			c.Writer.Flush()
+			return w.Error()
+		})
+		w.Flush()
+		if err != nil {
+			logger.Error("subscription export failed midway", zap.Error(err))
+		}
+	}
+}
+
+// SubscriptionsByEmailHandler handles GET /api/admin/subscriptions/by-email/:email
+func SubscriptionsByEmailHandler(svc services.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subs, err := svc.FindByEmail(c.Request.Context(), c.Param("email"))
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, gin.H{"items": toAdminSubscriptions(subs)})
+		case errors.Is(err, services.ErrSubscriptionNotFound):
+			// 404 No subscription for this email
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// DeleteSubscriptionHandler handles DELETE /api/admin/subscriptions/:id
+func DeleteSubscriptionHandler(svc services.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			// 400 Invalid id
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription id"})
+			return
+		}
+
+		err = svc.DeleteSubscription(c.Request.Context(), id)
+		switch {
+		case err == nil:
+			// 204 Deleted
+			c.Status(http.StatusNoContent)
+		case errors.Is(err, services.ErrSubscriptionNotFound):
+			// 404 Not found
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// ReviveSubscriptionHandler handles POST /api/admin/subscriptions/:id/revive, resuming
+// the sends of a dead-lettered subscription.
+func ReviveSubscriptionHandler(svc services.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			// 400 Invalid id
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription id"})
+			return
+		}
+
+		err = svc.ReviveSubscription(c.Request.Context(), id)
+		switch {
+		case err == nil:
+			// 204 Revived
+			c.Status(http.StatusNoContent)
+		case errors.Is(err, services.ErrSubscriptionNotFound):
+			// 404 Missing or not dead-lettered
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// subscriptionEvent is the admin view of a state transition of a subscription
+type subscriptionEvent struct {
+	Kind   string    `json:"kind"`
+	Detail string    `json:"detail,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// SubscriptionHistoryHandler handles GET /api/admin/subscriptions/:id/events, the
+// state transitions of a subscription, oldest first.
+func SubscriptionHistoryHandler(svc services.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			// 400 Invalid id
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription id"})
+			return
+		}
+
+		events, err := svc.SubscriptionHistory(c.Request.Context(), id)
+		switch {
+		case err == nil:
+			items := make([]subscriptionEvent, len(events))
+			for i, e := range events {
+				items[i] = subscriptionEvent{Kind: e.Kind, Detail: e.Detail, At: e.CreatedAt}
+			}
+			c.JSON(http.StatusOK, gin.H{"items": items})
+		case errors.Is(err, services.ErrSubscriptionNotFound):
+			// 404 No history: never existed or purged
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// mergeCitiesRequest is the JSON payload of POST /api/admin/cities/merge
+type mergeCitiesRequest struct {
+	From   string `json:"from"    binding:"required,max=100"`
+	To     string `json:"to"      binding:"required,max=100"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// MergeCitiesHandler handles POST /api/admin/cities/merge, which re-points every
+// subscription and delivery of one city spelling to the canonical one. With dry_run the
+// counts are returned without changing anything.
+func MergeCitiesHandler(svc services.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req mergeCitiesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			// 400 Invalid input
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		res, err := svc.MergeCities(c.Request.Context(), req.From, req.To, req.DryRun)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidCityMerge) {
+				// 400 Same or empty spellings
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 200 Merged (or previewed)
+		c.JSON(http.StatusOK, gin.H{
+			"from":                  req.From,
+			"to":                    req.To,
+			"dry_run":               req.DryRun,
+			"subscriptions":         res.Subscriptions,
+			"duplicates_dropped":    res.DuplicatesDropped,
+			"subscriptions_removed": res.SubscriptionsRemoved,
+			"deliveries":            res.Deliveries,
+		})
+	}
+}
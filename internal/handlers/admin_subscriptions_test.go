@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// exportingAdmin exports subs in one batch.
+type exportingAdmin struct {
+	services.AdminService
+
+	subs []repository.Subscription
+}
+
+func (s *exportingAdmin) ExportSubscriptions(_ context.Context, _ repository.SubscriptionFilter,
+	fn func([]repository.Subscription) error,
+) error {
+	return fn(s.subs)
+}
+
+func TestExportSubscriptionsHandler_EscapesFormulas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &exportingAdmin{subs: []repository.Subscription{
+		{ID: 1, Email: "anna@example.com", City: "Kyiv", Frequency: repository.FrequencyDaily, Timezone: "Europe/Kyiv"},
+		{ID: 2, Email: "=HYPERLINK(\"https://evil.example\")@example.com", City: "+Lviv", Frequency: repository.FrequencyHourly,
+			Timezone: "-1", Locale: "@SUM(A1)"},
+	}}
+	r := gin.New()
+	r.GET("/api/admin/subscriptions/export", ExportSubscriptionsHandler(svc, zap.NewNop()))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/subscriptions/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("export = %d rows, %v; want a header and 2 rows", len(rows), err)
+	}
+	for _, tc := range []struct {
+		row, col int
+		want     string
+	}{
+		{1, 1, "anna@example.com"},
+		{1, 2, "Kyiv"},
+		{2, 1, "'=HYPERLINK(\"https://evil.example\")@example.com"},
+		{2, 2, "'+Lviv"},
+		{2, 5, "'-1"},
+		{2, 6, "'@SUM(A1)"},
+	} {
+		if got := rows[tc.row][tc.col]; got != tc.want {
+			t.Errorf("row %d %s = %q, want %q", tc.row, rows[0][tc.col], got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// importSuppressionsRequest is the JSON payload of POST /api/admin/suppressions/import
+type importSuppressionsRequest struct {
+	Emails []string `json:"emails" binding:"required"`
+	Reason string   `json:"reason" binding:"omitempty,oneof=bounce complaint manual"`
+	Source string   `json:"source" binding:"max=100"`
+}
+
+// ImportSuppressionsHandler handles POST /api/admin/suppressions/import.
+// It accepts either JSON ({"emails": [...], "reason": "bounce", "source": "old-esp"})
+// or a text/csv body whose first column holds the addresses (reason/source via query).
+func ImportSuppressionsHandler(svc services.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req importSuppressionsRequest
+		if strings.HasPrefix(c.ContentType(), "text/csv") {
+			emails, err := readCSVColumn(c.Request.Body)
+			if err != nil {
+				// 400 Malformed CSV
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			req = importSuppressionsRequest{Emails: emails, Reason: c.Query("reason"), Source: c.Query("source")}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			// 400 Invalid input
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = repository.SuppressionReasonBounce
+		}
+		if req.Reason != repository.SuppressionReasonBounce &&
+			req.Reason != repository.SuppressionReasonComplaint &&
+			req.Reason != repository.SuppressionReasonManual {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be one of bounce, complaint, manual"})
+			return
+		}
+
+		res, err := svc.ImportSuppressions(c.Request.Context(), req.Emails, req.Reason, req.Source)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "added": res.Added})
+			return
+		}
+
+		// 200 Imported
+		c.JSON(http.StatusOK, gin.H{
+			"received": res.Received,
+			"added":    res.Added,
+			"invalid":  res.Invalid,
+		})
+	}
+}
+
+// readCSVColumn returns the first column of every record, skipping an "email" header.
+func readCSVColumn(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var out []string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+		value := strings.TrimSpace(record[0])
+		if len(out) == 0 && strings.EqualFold(value, "email") {
+			continue
+		}
+		out = append(out, value)
+	}
+}
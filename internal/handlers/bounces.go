@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/bounces"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// maxBounceNotification bounds the body of a bounce notification.
+const maxBounceNotification = 1 << 20
+
+// BounceWebhookHandler handles POST /api/webhooks/bounces/:provider, the bounce and
+// complaint notifications of the email provider ("ses", "sendgrid" or "mailgun"; see
+// package bounces). The caller authenticates with the shared token, in the
+// X-Webhook-Token header or the token query parameter, as providers differ in what
+// they can send.
+func BounceWebhookHandler(svc services.BounceService, token string, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validToken(c, "X-Webhook-Token", token) {
+			// 401 Missing or wrong token
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook token"})
+			return
+		}
+
+		provider := c.Param("provider")
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBounceNotification))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		events, err := bounces.Parse(provider, body)
+		var confirm *bounces.SNSConfirmation
+		switch {
+		case errors.As(err, &confirm):
+			logger.Warn("SNS subscription for bounce notifications awaits confirmation", zap.String("url", confirm.URL))
+			// 200 Confirmation logged for the operator
+			c.JSON(http.StatusOK, gin.H{"status": "confirmation pending"})
+			return
+		case errors.Is(err, bounces.ErrUnknownProvider):
+			// 404 Unsupported provider
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			// 400 Malformed notification
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		res, err := svc.Record(c.Request.Context(), provider, events)
+		if err != nil {
+			// 500 so that the provider retries the notification
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 200 Processed
+		c.JSON(http.StatusOK, gin.H{"events": len(events), "suppressed": res.Suppressed, "deferred": res.Deferred})
+	}
+}
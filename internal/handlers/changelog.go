@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// apiChange is the public representation of a changelog entry.
+type apiChange struct {
+	ID          int        `json:"id"`
+	Kind        string     `json:"kind"` // added | changed | deprecated | removed
+	Method      string     `json:"method,omitempty"`
+	Path        string     `json:"path,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Link        string     `json:"link,omitempty"`
+	AnnouncedAt time.Time  `json:"announced_at"`
+	SunsetAt    *time.Time `json:"sunset_at,omitempty"`
+}
+
+func toAPIChange(c repository.APIChange) apiChange {
+	out := apiChange{
+		ID:          c.ID,
+		Kind:        c.Kind,
+		Method:      c.Method.String,
+		Path:        c.Path.String,
+		Title:       c.Title,
+		Description: c.Description,
+		Link:        c.Link.String,
+		AnnouncedAt: c.AnnouncedAt,
+	}
+	if c.SunsetAt.Valid {
+		out.SunsetAt = &c.SunsetAt.Time
+	}
+	return out
+}
+
+// announceChangeRequest is the body of POST /api/admin/changelog.
+type announceChangeRequest struct {
+	Kind        string     `json:"kind"         binding:"required,oneof=added changed deprecated removed"`
+	Method      string     `json:"method"       binding:"omitempty,oneof=GET POST PUT PATCH DELETE get post put patch delete"`
+	Path        string     `json:"path"`
+	Title       string     `json:"title"        binding:"required,max=200"`
+	Description string     `json:"description"`
+	Link        string     `json:"link"         binding:"omitempty,url"`
+	AnnouncedAt *time.Time `json:"announced_at"` // defaults to now
+	SunsetAt    *time.Time `json:"sunset_at"`
+}
+
+func (r announceChangeRequest) toAPIChange() repository.APIChange {
+	c := repository.APIChange{
+		Kind:        r.Kind,
+		Method:      sql.NullString{String: r.Method, Valid: r.Method != ""},
+		Path:        sql.NullString{String: r.Path, Valid: r.Path != ""},
+		Title:       r.Title,
+		Description: r.Description,
+		Link:        sql.NullString{String: r.Link, Valid: r.Link != ""},
+	}
+	if r.AnnouncedAt != nil {
+		c.AnnouncedAt = *r.AnnouncedAt
+	}
+	if r.SunsetAt != nil {
+		c.SunsetAt = sql.NullTime{Time: *r.SunsetAt, Valid: true}
+	}
+	return c
+}
+
+// ChangelogHandler handles GET /api/changelog?since=RFC3339, the public feed of API
+// changes and deprecations, newest first.
+func ChangelogHandler(svc services.ChangelogService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var since time.Time
+		if s := c.Query("since"); s != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, s); err != nil {
+				// 400 Invalid since
+				c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+				return
+			}
+		}
+
+		changes, err := svc.List(c.Request.Context(), since)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		items := make([]apiChange, 0, len(changes))
+		for _, ch := range changes {
+			items = append(items, toAPIChange(ch))
+		}
+		// 200 Changelog
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"items": items})
+	}
+}
+
+// AnnounceChangeHandler handles POST /api/admin/changelog
+func AnnounceChangeHandler(svc services.ChangelogService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req announceChangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			// 400 Invalid input
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		created, err := svc.Announce(c.Request.Context(), req.toAPIChange())
+		switch {
+		case err == nil:
+			// 201 Announced
+			c.JSON(http.StatusCreated, toAPIChange(created))
+		case errors.Is(err, services.ErrInvalidAPIChange):
+			// 400 Inconsistent change
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// DeleteChangeHandler handles DELETE /api/admin/changelog/:id
+func DeleteChangeHandler(svc services.ChangelogService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			// 400 Invalid id
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid change id"})
+			return
+		}
+
+		err = svc.Delete(c.Request.Context(), id)
+		switch {
+		case err == nil:
+			// 204 Deleted
+			c.Status(http.StatusNoContent)
+		case errors.Is(err, services.ErrAPIChangeNotFound):
+			// 404 Not found
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
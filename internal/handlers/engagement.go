@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// transparentGIF is a 1x1 transparent GIF, the open-tracking pixel.
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// OpenPixelHandler handles GET /api/open/:token, the tracking pixel of weather emails.
+// It only records the open, never engagement or a renewal: scanners fetch it too.
+// The pixel is always served, whatever the outcome, so mail clients never show a broken image.
+func OpenPixelHandler(svc services.EngagementService, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := svc.RecordOpen(c.Request.Context(), c.Param("token"))
+		if err != nil && !errors.Is(err, services.ErrInvalidToken) && !errors.Is(err, services.ErrTokenNotFound) {
+			logger.Error("failed to record email open", zap.Error(err))
+		}
+
+		// 200 Pixel; never cached, so every open reaches us
+		c.Header("Cache-Control", "no-store, max-age=0")
+		c.Data(http.StatusOK, "image/gif", transparentGIF)
+	}
+}
+
+// KeepSubscriptionHandler handles the "keep sending" link of re-engagement and renewal
+// emails. GET /api/manage/:token/keep asks for confirmation with a button, so link
+// scanners prefetching the email cannot renew an abandoned mailbox; POST records the
+// engagement and renews the subscription.
+// Browsers get an HTML page, API clients get JSON.
+func KeepSubscriptionHandler(svc services.EngagementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet {
+			// 200 Ask before renewing
+			actionURL := c.Request.URL.RequestURI()
+			respond(c, http.StatusOK, resultPage{
+				Title:       "Keep your weather updates?",
+				Message:     "Confirm that you still want to receive your weather updates.",
+				ActionURL:   actionURL,
+				ActionLabel: "Keep sending them",
+			}, gin.H{"message": "POST to action_url to keep the subscription", "action_url": actionURL})
+			return
+		}
+
+		err := svc.KeepSubscription(c.Request.Context(), c.Param("token"))
+		switch {
+		case err == nil:
+			// 200 OK
+			respond(c, http.StatusOK, resultPage{
+				Title:   "Thanks for letting us know",
+				Message: "You will keep receiving your weather updates as before.",
+				Success: true,
+			}, gin.H{"message": "Subscription kept"})
+		case errors.Is(err, services.ErrInvalidToken):
+			// 400 Invalid token
+			respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTokenNotFound):
+			// 404 Token not found
+			respond(c, http.StatusNotFound, pageLinkNotFound, gin.H{"error": err.Error()})
+		default:
+			// 500 Unexpected error
+			respond(c, http.StatusInternalServerError, pageServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// countingEngagement counts the subscriptions kept and the opens recorded.
+type countingEngagement struct {
+	services.EngagementService
+
+	kept, opens int
+}
+
+func (s *countingEngagement) KeepSubscription(context.Context, string) error {
+	s.kept++
+	return nil
+}
+
+func (s *countingEngagement) RecordOpen(context.Context, string) error {
+	s.opens++
+	return nil
+}
+
+func TestKeepSubscriptionHandler_KeepsOnPostOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &countingEngagement{}
+	r := gin.New()
+	r.GET("/api/manage/:token/keep", KeepSubscriptionHandler(svc))
+	r.POST("/api/manage/:token/keep", KeepSubscriptionHandler(svc))
+	r.GET("/api/open/:token", OpenPixelHandler(svc, nil))
+	link := "/api/manage/tok/keep"
+
+	// a link scanner prefetching the email: the pixel and the link
+	for _, path := range []string{"/api/open/tok", link} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s = %d: %s", path, w.Code, w.Body)
+		}
+	}
+	if svc.kept != 0 {
+		t.Fatalf("GET kept the subscription %d times", svc.kept)
+	}
+	if svc.opens != 1 {
+		t.Errorf("pixel recorded %d opens, want 1", svc.opens)
+	}
+
+	// the button of the page
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, link, nil))
+	if w.Code != http.StatusOK || svc.kept != 1 {
+		t.Errorf("POST = %d, kept %d times; want 200, once", w.Code, svc.kept)
+	}
+}
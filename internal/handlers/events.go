@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/events"
+)
+
+// eventSchema is the JSON Schema of one version of an event type's data.
+type eventSchema struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Schema  json.RawMessage `json:"schema"`
+}
+
+// EventSchemasHandler handles GET /api/events/schemas and lists the JSON Schemas of
+// the event envelope and of every version of every published event type.
+func EventSchemasHandler() gin.HandlerFunc {
+	items := make([]eventSchema, 0)
+	for _, s := range events.Schemas() {
+		items = append(items, eventSchema{Type: s.Type, Version: s.Version, Schema: s.Document})
+	}
+	envelope := events.Envelope().Document
+
+	return func(c *gin.Context) {
+		// 200 Schemas
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{
+			"envelope": envelope,
+			"events":   items,
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/notify"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// manageRequest matches both JSON and the HTML form; omitted fields stay unchanged.
+// city is a single city; cities, when present, replaces it.
+type manageRequest struct {
+	City           *string               `form:"city"          json:"city"      binding:"omitempty,min=1"`
+	Cities         []string              `form:"cities"        json:"cities"`
+	Frequency      *repository.Frequency `form:"frequency"     json:"frequency" binding:"omitempty,oneof=hourly daily"`
+	Units          *repository.Units     `form:"units"         json:"units"     binding:"omitempty,oneof=metric imperial"`
+	SendTime       *string               `form:"send_time"     json:"send_time"`           // "HH:MM", local to timezone
+	Timezone       *string               `form:"timezone"      json:"timezone"`            // IANA name, e.g. "Europe/Kyiv"
+	BetaFeatures   *bool                 `form:"beta_features" json:"beta_features"`       // the form's checkbox comes before a hidden "false"
+	Locale         *string               `form:"locale"        json:"locale"`              // language of the emails, e.g. "uk"
+	NotifyOnChange *bool                 `form:"notify_on_change" json:"notify_on_change"` // a checkbox like beta_features
+}
+
+// toUpdate converts the request into a repository update, parsing the send time.
+func (r manageRequest) toUpdate() (repository.SubscriptionUpdate, error) {
+	u := repository.SubscriptionUpdate{Frequency: r.Frequency, Units: r.Units, BetaFeatures: r.BetaFeatures,
+		NotifyOnChange: r.NotifyOnChange}
+	if r.Timezone != nil && *r.Timezone != "" {
+		u.Timezone = r.Timezone
+	}
+	if r.Locale != nil && *r.Locale != "" {
+		u.Locale = r.Locale
+	}
+	switch {
+	case r.Cities != nil:
+		u.Cities = r.Cities
+	case r.City != nil:
+		u.Cities = []string{*r.City}
+	}
+	if r.SendTime != nil && *r.SendTime != "" {
+		t, err := time.Parse("15:04", *r.SendTime)
+		if err != nil {
+			return u, fmt.Errorf("send_time must be HH:MM")
+		}
+		hour, minute := int16(t.Hour()), int16(t.Minute())
+		u.ScheduledHour, u.ScheduledMinute = &hour, &minute
+	}
+	return u, nil
+}
+
+// manageView is what the manage link shows; tokens other than the manage token are not exposed.
+type manageView struct {
+	Email          string               `json:"email"`
+	City           string               `json:"city"` // the first of Cities
+	Cities         []string             `json:"cities"`
+	Frequency      repository.Frequency `json:"frequency"`
+	Units          repository.Units     `json:"units"`
+	SendTime       string               `json:"send_time"` // "HH:MM" in Timezone; hourly updates use the minute only
+	Timezone       string               `json:"timezone"`
+	Confirmed      bool                 `json:"confirmed"`
+	ExpiresAt      *time.Time           `json:"expires_at,omitempty"` // renewed through the renewal email's link
+	BetaFeatures   bool                 `json:"beta_features"`        // opted in to experimental email content
+	Locale         string               `json:"locale"`
+	NotifyOnChange bool                 `json:"notify_on_change"` // updates are skipped while the weather does not change
+	SlackDelivery  bool                 `json:"slack_delivery"`   // updates are posted to a Slack webhook; the URL is a secret
+	SMSDelivery    bool                 `json:"sms_delivery"`     // updates are texted to a phone
+	Channel        repository.Channel   `json:"channel"`          // where updates are delivered
+}
+
+// managePage is the rendering context of pages/manage.html.
+type managePage struct {
+	manageView
+	UnsubscribeURL string
+	BetaFlags      []features.Flag // experiments currently offered to beta volunteers
+	Languages      []i18n.Language
+	Notice         string
+	Error          string
+}
+
+func newManageView(sub repository.Subscription) manageView {
+	view := manageView{
+		Email:          sub.Email,
+		City:           sub.City,
+		Cities:         sub.AllCities(),
+		Frequency:      sub.Frequency,
+		Units:          sub.Units,
+		SendTime:       fmt.Sprintf("%02d:%02d", sub.ScheduledHour, sub.ScheduledMinute),
+		Timezone:       sub.Timezone,
+		Confirmed:      sub.Confirmed,
+		BetaFeatures:   sub.BetaFeatures,
+		Locale:         sub.Locale,
+		NotifyOnChange: sub.NotifyOnChange,
+		SlackDelivery:  sub.SlackWebhookURL != "",
+		SMSDelivery:    sub.Phone != "",
+		Channel:        notify.ChannelOf(sub),
+	}
+	if sub.ExpiresAt.Valid {
+		view.ExpiresAt = &sub.ExpiresAt.Time
+	}
+	return view
+}
+
+// newManagePage links to the unsubscribe route under pathPrefix (PATH_PREFIX).
+func newManagePage(sub repository.Subscription, links *linksign.Signer, flags *features.Flags, pathPrefix string,
+) managePage {
+	return managePage{
+		manageView:     newManageView(sub),
+		UnsubscribeURL: links.URL(pathPrefix, linksign.PurposeUnsubscribe, sub.UnsubscribeToken.String()),
+		BetaFlags:      flags.InBeta(),
+		Languages:      i18n.Languages(),
+	}
+}
+
+// ManageHandler handles GET /api/manage/:token.
+// Browsers get a form to edit the subscription, API clients get JSON.
+func ManageHandler(svc services.SubscriptionService, links *linksign.Signer, flags *features.Flags,
+	pathPrefix string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sub, err := svc.GetManaged(c.Request.Context(), c.Param("token"))
+		if err != nil {
+			respondManageError(c, err)
+			return
+		}
+
+		// 200 OK
+		if wantsHTML(c) {
+			renderPage(c, http.StatusOK, "manage.html", newManagePage(sub, links, flags, pathPrefix))
+			return
+		}
+		c.JSON(http.StatusOK, newManageView(sub))
+	}
+}
+
+// UpdateManagedHandler handles POST /api/manage/:token, submitted by the manage page
+// form or by API clients with JSON.
+func UpdateManagedHandler(svc services.SubscriptionService, links *linksign.Signer, flags *features.Flags,
+	pathPrefix string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		token := c.Param("token")
+
+		var req manageRequest
+		err := c.ShouldBind(&req)
+		var u repository.SubscriptionUpdate
+		if err == nil {
+			u, err = req.toUpdate()
+		}
+		if err != nil {
+			// 400 Invalid input
+			if wantsHTML(c) {
+				sub, getErr := svc.GetManaged(ctx, token)
+				if getErr != nil {
+					respondManageError(c, getErr)
+					return
+				}
+				page := newManagePage(sub, links, flags, pathPrefix)
+				page.Error = "Please check the values you entered."
+				renderPage(c, http.StatusBadRequest, "manage.html", page)
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sub, err := svc.UpdateManaged(ctx, token, u)
+		if errors.Is(err, services.ErrNotConfirmed) {
+			// 409 Not confirmed yet
+			respond(c, http.StatusConflict, resultPage{
+				Title:   "Subscription not confirmed",
+				Message: "Please confirm your subscription using the link in the confirmation email first.",
+			}, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrAlreadySubscribed) {
+			// 409 Email already subscribed for one of the new cities
+			if wantsHTML(c) {
+				if sub, getErr := svc.GetManaged(ctx, token); getErr == nil {
+					page := newManagePage(sub, links, flags, pathPrefix)
+					page.Error = "You already have another subscription for one of these cities."
+					renderPage(c, http.StatusConflict, "manage.html", page)
+					return
+				}
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidCity) || errors.Is(err, services.ErrInvalidCityCount) ||
+			errors.Is(err, services.ErrInvalidTimezone) || errors.Is(err, services.ErrInvalidLocale) {
+			// 400 Unknown city, too many cities, unknown timezone or unsupported locale
+			if wantsHTML(c) {
+				if sub, getErr := svc.GetManaged(ctx, token); getErr == nil {
+					page := newManagePage(sub, links, flags, pathPrefix)
+					page.Error = "We could not find weather for that city."
+					switch {
+					case errors.Is(err, services.ErrInvalidCityCount):
+						page.Error = fmt.Sprintf("Please enter between 1 and %d cities.", repository.MaxCitiesPerSubscription)
+					case errors.Is(err, services.ErrInvalidTimezone):
+						page.Error = "Please enter a timezone such as Europe/Kyiv."
+					case errors.Is(err, services.ErrInvalidLocale):
+						page.Error = "Please choose one of the listed languages."
+					}
+					renderPage(c, http.StatusBadRequest, "manage.html", page)
+					return
+				}
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			respondManageError(c, err)
+			return
+		}
+
+		// 200 Updated
+		if wantsHTML(c) {
+			page := newManagePage(sub, links, flags, pathPrefix)
+			page.Notice = "Your preferences were saved."
+			renderPage(c, http.StatusOK, "manage.html", page)
+			return
+		}
+		c.JSON(http.StatusOK, newManageView(sub))
+	}
+}
+
+func respondManageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidToken):
+		// 400 Invalid token
+		respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTokenNotFound):
+		// 404 Token not found
+		respond(c, http.StatusNotFound, pageLinkNotFound, gin.H{"error": err.Error()})
+	default:
+		// 500 Unexpected error
+		respond(c, http.StatusInternalServerError, pageServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// UpdateSubscriptionHandler handles PATCH /api/subscriptions/:token, where token is
+// the manage token. Only the JSON fields present are changed.
+func UpdateSubscriptionHandler(svc services.SubscriptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req manageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			// 400 Invalid input
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		u, err := req.toUpdate()
+		if err != nil {
+			// 400 Invalid send time
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sub, err := svc.UpdateManaged(c.Request.Context(), c.Param("token"), u)
+		switch {
+		case err == nil:
+			// 200 Updated
+			c.JSON(http.StatusOK, newManageView(sub))
+		case errors.Is(err, services.ErrInvalidToken), errors.Is(err, services.ErrInvalidCity),
+			errors.Is(err, services.ErrInvalidCityCount), errors.Is(err, services.ErrInvalidTimezone),
+			errors.Is(err, services.ErrInvalidLocale):
+			// 400 Invalid token, unknown city, too many cities, unknown timezone or unsupported locale
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTokenNotFound):
+			// 404 Token not found
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrNotConfirmed), errors.Is(err, services.ErrAlreadySubscribed):
+			// 409 Not confirmed yet, or a new city is already subscribed by this email
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
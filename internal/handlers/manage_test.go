@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// updatingService answers UpdateManaged with err, or the subscription updated.
+type updatingService struct {
+	services.SubscriptionService
+
+	err     error
+	updates []repository.SubscriptionUpdate
+}
+
+func (s *updatingService) UpdateManaged(_ context.Context, _ string, u repository.SubscriptionUpdate,
+) (repository.Subscription, error) {
+	if s.err != nil {
+		return repository.Subscription{}, s.err
+	}
+	s.updates = append(s.updates, u)
+	sub := repository.Subscription{ID: 1, City: "Kyiv", Frequency: repository.FrequencyDaily, Confirmed: true}
+	if u.Cities != nil {
+		sub.City = u.Cities[0]
+	}
+	if u.Frequency != nil {
+		sub.Frequency = *u.Frequency
+	}
+	return sub, nil
+}
+
+func TestUpdateSubscriptionHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"city", `{"city":"Lviv"}`, nil, http.StatusOK},
+		{"frequency", `{"frequency":"hourly"}`, nil, http.StatusOK},
+		{"unknown frequency", `{"frequency":"weekly"}`, nil, http.StatusBadRequest},
+		{"malformed send time", `{"send_time":"8am"}`, nil, http.StatusBadRequest},
+		{"malformed JSON", `{"city":`, nil, http.StatusBadRequest},
+		{"unknown city", `{"city":"Atlantis"}`, services.ErrInvalidCity, http.StatusBadRequest},
+		{"malformed token", `{"city":"Lviv"}`, services.ErrInvalidToken, http.StatusBadRequest},
+		{"unknown token", `{"city":"Lviv"}`, services.ErrTokenNotFound, http.StatusNotFound},
+		{"not confirmed", `{"city":"Lviv"}`, services.ErrNotConfirmed, http.StatusConflict},
+		{"city subscribed already", `{"city":"Lviv"}`, services.ErrAlreadySubscribed, http.StatusConflict},
+		{"database down", `{"city":"Lviv"}`, fmt.Errorf("repo.Update: %w", context.DeadlineExceeded),
+			http.StatusInternalServerError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &updatingService{err: tc.err}
+			r := gin.New()
+			r.PATCH("/api/subscriptions/:token", UpdateSubscriptionHandler(svc))
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/6f1c", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+			if tc.want != http.StatusOK {
+				return
+			}
+			var view struct {
+				City      string `json:"city"`
+				Frequency string `json:"frequency"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil || view.City == "" || view.Frequency == "" {
+				t.Errorf("body = %s, want the updated subscription", w.Body)
+			}
+			if len(svc.updates) != 1 || (svc.updates[0].Cities == nil) == (svc.updates[0].Frequency == nil) {
+				t.Errorf("updates = %+v, want only the field given", svc.updates)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIHandler handles GET /api/openapi.json, the OpenAPI document the clients under
+// clients/ are generated from. Under a PATH_PREFIX its server URL is prefixed, so
+// generated clients and API explorers reach the prefixed routes.
+func OpenAPIHandler(spec []byte, pathPrefix string) gin.HandlerFunc {
+	if pathPrefix != "" {
+		spec = prefixServers(spec, pathPrefix)
+	}
+	return func(c *gin.Context) {
+		// 200 OpenAPI document
+		c.Header("Cache-Control", "public, max-age=300")
+		c.Data(http.StatusOK, "application/json", spec)
+	}
+}
+
+// prefixServers returns spec with pathPrefix in front of every server URL. A document
+// that cannot be rewritten is returned unchanged.
+func prefixServers(spec []byte, pathPrefix string) []byte {
+	var doc map[string]json.RawMessage
+	var servers []map[string]any
+	if json.Unmarshal(spec, &doc) != nil || json.Unmarshal(doc["servers"], &servers) != nil {
+		return spec
+	}
+	for _, s := range servers {
+		if url, ok := s["url"].(string); ok {
+			s["url"] = pathPrefix + url
+		}
+	}
+	raw, err := json.Marshal(servers)
+	if err != nil {
+		return spec
+	}
+	doc["servers"] = raw
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return spec
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+//go:embed pages/*.html
+var pageFS embed.FS
+
+var pages = template.Must(template.New("").ParseFS(pageFS, "pages/*.html"))
+
+// resultPage is the rendering context of pages/result.html, shown to people who
+// open confirm/unsubscribe links in a browser.
+type resultPage struct {
+	Title   string
+	Message string
+	Success bool
+
+	// Optional button posting to ActionURL, e.g. to re-send an expired confirmation
+	ActionURL   string
+	ActionLabel string
+}
+
+// respond writes page for browsers (Accept prefers text/html) and body as JSON otherwise.
+// JSON is offered first, so clients sending "*/*" or no Accept keep getting JSON.
+func respond(c *gin.Context, status int, page resultPage, body gin.H) {
+	if !wantsHTML(c) {
+		c.JSON(status, body)
+		return
+	}
+
+	renderPage(c, status, "result.html", page)
+}
+
+// wantsHTML reports whether the client prefers an HTML page over JSON.
+func wantsHTML(c *gin.Context) bool {
+	return c.NegotiateFormat(binding.MIMEJSON, binding.MIMEHTML) == binding.MIMEHTML
+}
+
+// renderPage writes the named page template.
+func renderPage(c *gin.Context, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Pages shared by the confirm and unsubscribe links
+var (
+	pageInvalidLink = resultPage{
+		Title:   "Invalid link",
+		Message: "This link is malformed. Please use the link exactly as it appears in the email.",
+	}
+	pageLinkNotFound = resultPage{
+		Title:   "Link no longer valid",
+		Message: "This link has already been used or the subscription no longer exists.",
+	}
+	pageServerError = resultPage{
+		Title:   "Something went wrong",
+		Message: "We could not process your request. Please try again in a few minutes.",
+	}
+)
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// previewRequest is the query of GET /api/admin/emails/preview
+type previewRequest struct {
+	Template  string           `form:"template"   binding:"required,oneof=confirmation weather_update"`
+	City      string           `form:"city"       binding:"required_without=Cities"` // one city
+	Cities    []string         `form:"cities"`
+	Frequency string           `form:"frequency"  binding:"omitempty,oneof=hourly daily"`
+	SendHour  *int             `form:"send_hour"  binding:"omitempty,min=0,max=23"`
+	Timezone  string           `form:"timezone"`
+	Locale    string           `form:"locale"`
+	Units     repository.Units `form:"units"      binding:"omitempty,oneof=metric imperial"`
+	FirstName string           `form:"first_name"`
+	Format    string           `form:"format"     binding:"omitempty,oneof=html json"`
+}
+
+// previewInputErrors are the service errors caused by the request.
+var previewInputErrors = []error{
+	services.ErrInvalidTemplate, services.ErrInvalidCity, services.ErrInvalidCityCount, services.ErrInvalidFrequency,
+	services.ErrInvalidSendHour, services.ErrInvalidTimezone, services.ErrInvalidFirstName,
+}
+
+// EmailPreviewHandler handles GET /api/admin/emails/preview?template=...&city=...
+// and renders the confirmation or weather update email of such a subscription, with
+// the current weather, without sending anything. It returns the HTML itself (subject
+// in the X-Email-Subject header), or {"subject", "html"} with format=json.
+func EmailPreviewHandler(svc services.PreviewService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req previewRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			// 400 Invalid input
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Frequency == "" {
+			req.Frequency = string(repository.FrequencyDaily)
+		}
+
+		preview, err := svc.Preview(c.Request.Context(), services.PreviewRequest{
+			Template:  req.Template,
+			Cities:    withCity(req.City, req.Cities),
+			Frequency: req.Frequency,
+			SendHour:  req.SendHour,
+			Timezone:  req.Timezone,
+			Locale:    req.Locale,
+			Units:     req.Units,
+			FirstName: req.FirstName,
+		})
+		if err != nil {
+			for _, inputErr := range previewInputErrors {
+				if errors.Is(err, inputErr) {
+					// 400 Invalid subscription (e.g. unknown city)
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 200 Rendered email
+		if req.Format == "json" {
+			c.JSON(http.StatusOK, preview)
+			return
+		}
+		c.Header("X-Email-Subject", preview.Subject)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(preview.HTML))
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/middleware"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+// sunnyWeather is sunny everywhere but Atlantis, which has no weather.
+type sunnyWeather struct{}
+
+func (sunnyWeather) FetchCurrent(_ context.Context, city string) (types.Weather, error) {
+	if city == "Atlantis" {
+		return types.Weather{}, errors.New("no matching location found")
+	}
+	return types.Weather{Temp: 21.5, Humidity: 40, Description: "Sunny", Provider: "weatherapi.com"}, nil
+}
+
+// previewRouter serves the preview as the API does: to the admin only.
+func previewRouter() *gin.Engine {
+	svc := services.NewPreviewService(sunnyWeather{}, nil, nil, &config.Config{BaseURL: "https://example.com"},
+		zap.NewNop())
+	r := gin.New()
+	admin := r.Group("/api/admin", middleware.NewAdminAuth("admin", "secret", "", time.Hour).Middleware())
+	admin.GET("/emails/preview", EmailPreviewHandler(svc))
+	return r
+}
+
+func TestEmailPreviewHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name    string
+		query   string
+		want    int
+		subject string // of the rendered email
+		content string // in its HTML
+	}{
+		{"confirmation", "template=confirmation&city=Kyiv", http.StatusOK, "Confirm", "Kyiv"},
+		{"weather update", "template=weather_update&cities=Kyiv&cities=Lviv", http.StatusOK, "Kyiv", "21.5"},
+		{"weather update in JSON", "template=weather_update&city=Kyiv&format=json", http.StatusOK, "", ""},
+		{"unknown template", "template=welcome&city=Kyiv", http.StatusBadRequest, "", ""},
+		{"no template", "city=Kyiv", http.StatusBadRequest, "", ""},
+		{"city without weather", "template=weather_update&city=Atlantis", http.StatusBadRequest, "", ""},
+		{"unknown timezone", "template=confirmation&city=Kyiv&timezone=Mars/Olympus", http.StatusBadRequest, "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/emails/preview?"+tc.query, nil)
+			req.SetBasicAuth("admin", "secret")
+			w := httptest.NewRecorder()
+			previewRouter().ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+			if tc.want != http.StatusOK {
+				return
+			}
+			if strings.Contains(tc.query, "format=json") {
+				var preview services.Preview
+				if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil || preview.Subject == "" ||
+					!strings.Contains(preview.HTML, "Kyiv") {
+					t.Errorf("body = %s, want the subject and HTML of the email", w.Body)
+				}
+				return
+			}
+			subject := w.Header().Get("X-Email-Subject")
+			if !strings.Contains(subject, tc.subject) || !strings.Contains(w.Body.String(), tc.content) {
+				t.Errorf("subject %q, body %s; want %q in the subject, %q in the body", subject, w.Body, tc.subject,
+					tc.content)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+				t.Errorf("Content-Type = %q, want HTML", ct)
+			}
+		})
+	}
+}
+
+func TestEmailPreviewHandler_AdminOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name           string
+		user, password string
+		want           int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "admin", "guess", http.StatusUnauthorized},
+		{"admin", "admin", "secret", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/emails/preview?template=confirmation&city=Kyiv", nil)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.password)
+			}
+			w := httptest.NewRecorder()
+			previewRouter().ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("status = %d, want %d", w.Code, tc.want)
+			}
+			if tc.want == http.StatusUnauthorized && w.Header().Get("X-Email-Subject") != "" {
+				t.Error("rendered the email for an unauthenticated request")
+			}
+		})
+	}
+}
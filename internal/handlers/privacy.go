@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// privacySubscription is the export view of a subscription: what the subscriber gave
+// and what the service recorded about it (tokens are never exposed)
+type privacySubscription struct {
+	ID          int                  `json:"id"`
+	Email       string               `json:"email"`
+	FirstName   string               `json:"first_name,omitempty"`
+	Phone       string               `json:"phone,omitempty"`
+	Cities      []string             `json:"cities"`
+	Frequency   repository.Frequency `json:"frequency"`
+	Units       repository.Units     `json:"units"`
+	Timezone    string               `json:"timezone"`
+	Locale      string               `json:"locale"`
+	Confirmed   bool                 `json:"confirmed"`
+	CreatedAt   time.Time            `json:"created_at"`
+	ConfirmedAt *time.Time           `json:"confirmed_at,omitempty"`
+	LastSentAt  *time.Time           `json:"last_sent_at,omitempty"`
+	DeletedAt   *time.Time           `json:"deleted_at,omitempty"` // unsubscribed
+}
+
+// privacyEvent is the export view of a state transition of a subscription
+type privacyEvent struct {
+	SubscriptionID int       `json:"subscription_id"`
+	Kind           string    `json:"kind"`
+	Detail         string    `json:"detail,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// privacyDelivery is the export view of a scheduled email
+type privacyDelivery struct {
+	SubscriptionID int64      `json:"subscription_id"`
+	Email          string     `json:"email"`
+	City           string     `json:"city"`
+	ScheduledFor   time.Time  `json:"scheduled_for"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+	Status         string     `json:"status"`
+	OpenedAt       *time.Time `json:"opened_at,omitempty"`
+}
+
+func toPrivacyExport(data repository.PrivacyExport) gin.H {
+	subs := make([]privacySubscription, 0, len(data.Subscriptions))
+	for _, s := range data.Subscriptions {
+		item := privacySubscription{
+			ID:        s.ID,
+			Email:     s.Email,
+			FirstName: s.FirstName,
+			Phone:     s.Phone,
+			Cities:    s.AllCities(),
+			Frequency: s.Frequency,
+			Units:     s.Units,
+			Timezone:  s.Timezone,
+			Locale:    s.Locale,
+			Confirmed: s.Confirmed,
+			CreatedAt: s.CreatedAt,
+		}
+		if s.ConfirmedAt.Valid {
+			item.ConfirmedAt = &s.ConfirmedAt.Time
+		}
+		if s.LastSentAt.Valid {
+			item.LastSentAt = &s.LastSentAt.Time
+		}
+		if s.DeletedAt.Valid {
+			item.DeletedAt = &s.DeletedAt.Time
+		}
+		subs = append(subs, item)
+	}
+	events := make([]privacyEvent, len(data.Events))
+	for i, e := range data.Events {
+		events[i] = privacyEvent{SubscriptionID: e.SubscriptionID, Kind: e.Kind, Detail: e.Detail, At: e.CreatedAt}
+	}
+	deliveries := make([]privacyDelivery, len(data.Deliveries))
+	for i, d := range data.Deliveries {
+		deliveries[i] = privacyDelivery{SubscriptionID: d.SubscriptionID.Int64, Email: d.Email, City: d.City,
+			ScheduledFor: d.ScheduledFor, Status: d.Status}
+		if d.SentAt.Valid {
+			deliveries[i].SentAt = &d.SentAt.Time
+		}
+		if d.OpenedAt.Valid {
+			deliveries[i].OpenedAt = &d.OpenedAt.Time
+		}
+	}
+	return gin.H{"subscriptions": subs, "events": events, "deliveries": deliveries}
+}
+
+// PrivacyEraseHandler handles DELETE /api/privacy/:email. Without a token it emails the
+// address the challenge: links exporting or erasing its data, valid for a while. With
+// the emailed ?token= it erases every subscription of the address, their history and
+// delivery log, like PrivacyEraseConfirmHandler.
+func PrivacyEraseHandler(svc services.PrivacyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addr := c.Param("email")
+		token := c.Query("token")
+		if token == "" {
+			err := svc.RequestChallenge(c.Request.Context(), addr)
+			switch {
+			case err == nil:
+				// 202 Challenge emailed (or nothing to email: the same answer)
+				c.JSON(http.StatusAccepted, gin.H{"message": "Check your inbox to confirm the request"})
+			case errors.Is(err, services.ErrInvalidEmail):
+				// 400 Malformed address
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		res, err := svc.Erase(c.Request.Context(), addr, token)
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, gin.H{"subscriptions": res.Subscriptions, "events": res.Events,
+				"deliveries": res.Deliveries})
+		case errors.Is(err, services.ErrInvalidToken):
+			// 400 Invalid token
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTokenNotFound):
+			// 404 Unknown, expired or another address's token
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// PrivacyEraseConfirmHandler handles the erase link of privacy emails. GET
+// /api/privacy/erase/:token?email= asks for confirmation with a button, so link scanners
+// cannot erase anything; POST erases every subscription of the address, their history
+// and delivery log.
+// Browsers get an HTML page, API clients get JSON.
+func PrivacyEraseConfirmHandler(svc services.PrivacyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet {
+			// 200 Ask before erasing
+			actionURL := c.Request.URL.RequestURI()
+			respond(c, http.StatusOK, resultPage{
+				Title:       "Erase your data?",
+				Message:     "Every weather subscription of " + c.Query("email") + " ends, with its history and the log of the emails sent. This cannot be undone.",
+				ActionURL:   actionURL,
+				ActionLabel: "Erase my data",
+			}, gin.H{"message": "POST to action_url to erase the data of the address", "action_url": actionURL})
+			return
+		}
+
+		res, err := svc.Erase(c.Request.Context(), c.Query("email"), c.Param("token"))
+		switch {
+		case err == nil:
+			// 200 OK
+			respond(c, http.StatusOK, resultPage{
+				Title:   "Data erased",
+				Message: "Every subscription of the address and its data are deleted.",
+				Success: true,
+			}, gin.H{"subscriptions": res.Subscriptions, "events": res.Events, "deliveries": res.Deliveries})
+		case errors.Is(err, services.ErrInvalidToken):
+			// 400 Invalid token
+			respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTokenNotFound):
+			// 404 Unknown, expired or another address's token
+			respond(c, http.StatusNotFound, pageLinkNotFound, gin.H{"error": err.Error()})
+		default:
+			// 500 Unexpected error
+			respond(c, http.StatusInternalServerError, pageServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// PrivacyExportHandler handles GET /api/privacy/export/:token, the data of the address
+// the token was emailed to, as a JSON download.
+func PrivacyExportHandler(svc services.PrivacyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := svc.Export(c.Request.Context(), c.Param("token"))
+		switch {
+		case err == nil:
+			c.Header("Content-Disposition", `attachment; filename="weather-subscription-data.json"`)
+			c.JSON(http.StatusOK, toPrivacyExport(data))
+		case errors.Is(err, services.ErrInvalidToken):
+			// 400 Invalid token
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTokenNotFound):
+			// 404 Unknown or expired token
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
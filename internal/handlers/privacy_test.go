@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+// erasingPrivacy records the erasures asked of it.
+type erasingPrivacy struct {
+	services.PrivacyService
+
+	erased []string
+}
+
+func (s *erasingPrivacy) Erase(_ context.Context, addr, token string) (repository.PrivacyErasure, error) {
+	s.erased = append(s.erased, addr+" "+token)
+	return repository.PrivacyErasure{Subscriptions: 1}, nil
+}
+
+func TestPrivacyEraseConfirmHandler_ErasesOnPostOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &erasingPrivacy{}
+	r := gin.New()
+	r.GET("/api/privacy/erase/:token", PrivacyEraseConfirmHandler(svc))
+	r.POST("/api/privacy/erase/:token", PrivacyEraseConfirmHandler(svc))
+	link := "/api/privacy/erase/tok?email=anna%40example.com"
+
+	// a link scanner, or the reader, opening the emailed link
+	req := httptest.NewRequest(http.MethodGet, link, nil)
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `action="`+link) {
+		t.Errorf("GET = %d, want a page posting to the link:\n%s", w.Code, w.Body)
+	}
+	if len(svc.erased) != 0 {
+		t.Fatalf("GET erased %q", svc.erased)
+	}
+
+	// the button of the page
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, link, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("POST = %d: %s", w.Code, w.Body)
+	}
+	if want := []string{"anna@example.com tok"}; len(svc.erased) != 1 || svc.erased[0] != want[0] {
+		t.Errorf("POST erased %q, want %q", svc.erased, want)
+	}
+}
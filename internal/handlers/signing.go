@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/signing"
+)
+
+// signingKey describes a signing key to consumers. HMAC keys are symmetric, so the
+// secret itself is shared out of band; the fingerprint lets consumers check they hold
+// the right one, and the status tells them which keys to accept.
+type signingKey struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"` // "active" or "expired"
+	Fingerprint string     `json:"fingerprint"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// SigningKeysHandler handles GET /api/signing-keys and describes the keys that sign
+// webhook and event payloads, and how the signature header is built.
+func SigningKeysHandler(keys *signing.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		items := make([]signingKey, 0)
+		for _, k := range keys.Keys() {
+			item := signingKey{ID: k.ID, Status: "active", Fingerprint: k.Fingerprint()}
+			if !k.Active(now) {
+				item.Status = "expired"
+			}
+			if !k.ExpiresAt.IsZero() {
+				expires := k.ExpiresAt.UTC()
+				item.ExpiresAt = &expires
+			}
+			items = append(items, item)
+		}
+
+		// 200 Keys (empty when payloads are not signed)
+		c.JSON(http.StatusOK, gin.H{
+			"algorithm": signing.Algorithm,
+			"header":    signing.Header,
+			"format":    "t=<unix seconds>,<key id>=<hex HMAC of \"<unix seconds>.<body>\">,...",
+			"keys":      items,
+		})
+	}
+}
@@ -3,20 +3,95 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/captcha"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
 )
 
 // subscribeRequest matches both JSON and x-www-form-urlencoded payloads
 type subscribeRequest struct {
-	Email     string `form:"email"     json:"email"     binding:"required,email"`
-	City      string `form:"city"      json:"city"      binding:"required"`
-	Frequency string `form:"frequency" json:"frequency" binding:"required,oneof=hourly daily"`
+	Email     string   `form:"email"     json:"email"     binding:"required,email"`
+	City      string   `form:"city"      json:"city"      binding:"required_without=Cities"` // one city, commas and all
+	Cities    []string `form:"cities"    json:"cities"`
+	Frequency string   `form:"frequency" json:"frequency" binding:"required,oneof=hourly daily alert warnings"`
+	SendHour  *int     `form:"send_hour" json:"send_hour" binding:"omitempty,min=0,max=23"` // daily only, local to timezone
+	Timezone  string   `form:"timezone"  json:"timezone"`                                   // IANA name; derived from the first city when empty
+	Locale    string   `form:"locale"    json:"locale"`                                     // language of the emails; Accept-Language when empty or unsupported
+
+	// optional, used to greet the subscriber in emails
+	FirstName string `form:"first_name" json:"first_name"`
+
+	// conditions of alert subscriptions, at least one of them
+	TempAbove    *float64 `form:"temp_above"    json:"temp_above"` // °C
+	TempBelow    *float64 `form:"temp_below"    json:"temp_below"` // °C
+	WindAbove    *float64 `form:"wind_above"    json:"wind_above"` // km/h
+	RainExpected bool     `form:"rain_expected" json:"rain_expected"`
+
+	// optional Slack incoming webhook the updates are posted to instead of emailed
+	SlackWebhookURL string `form:"slack_webhook_url" json:"slack_webhook_url"`
+
+	// optional phone (E.164) daily updates are texted to instead of emailed; confirmed
+	// with a texted code, see ConfirmPhoneHandler
+	Phone string `form:"phone" json:"phone"`
+
+	// CAPTCHA token; the widgets' default form field names are accepted as well
+	CaptchaToken   string `form:"captcha_token" json:"captcha_token"`
+	RecaptchaToken string `form:"g-recaptcha-response"`
+	TurnstileToken string `form:"cf-turnstile-response"`
+}
+
+// cities merges the city field with the cities list. city is a single city, as in
+// the first version of the API: names such as "Washington, D.C." contain commas.
+func (r subscribeRequest) cities() []string {
+	return withCity(r.City, r.Cities)
+}
+
+// withCity returns city followed by cities, city left out when empty; blanks are
+// dropped by the service.
+func withCity(city string, cities []string) []string {
+	if city == "" {
+		return cities
+	}
+	return append([]string{city}, cities...)
 }
 
-// SubscribeHandler handles POST /api/subscribe
-func SubscribeHandler(svc services.SubscriptionService) gin.HandlerFunc {
+// subscription is the subscription r asks for, in the locale of r or else of the
+// Accept-Language header acceptLanguage.
+func (r subscribeRequest) subscription(acceptLanguage string) services.SubscribeRequest {
+	return services.SubscribeRequest{
+		Email:     r.Email,
+		FirstName: r.FirstName,
+		Cities:    r.cities(),
+		Frequency: r.Frequency,
+		SendHour:  r.SendHour,
+		Timezone:  r.Timezone,
+		Locale:    i18n.Negotiate(r.Locale, acceptLanguage),
+		Alert: repository.AlertConditions{TempAbove: r.TempAbove, TempBelow: r.TempBelow, WindAbove: r.WindAbove,
+			RainExpected: r.RainExpected},
+		SlackWebhookURL: r.SlackWebhookURL,
+		Phone:           r.Phone,
+	}
+}
+
+func (r subscribeRequest) captchaToken() string {
+	for _, t := range []string{r.CaptchaToken, r.RecaptchaToken, r.TurnstileToken} {
+		if t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// SubscribeHandler handles POST /api/subscribe.
+// When verifier is non-nil, a valid CAPTCHA token is required before anything is sent.
+func SubscribeHandler(svc services.SubscriptionService, verifier captcha.Verifier, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req subscribeRequest
 		if err := c.ShouldBind(&req); err != nil {
@@ -25,8 +100,22 @@ func SubscribeHandler(svc services.SubscriptionService) gin.HandlerFunc {
 			return
 		}
 
-		if err := svc.Subscribe(c.Request.Context(), req.Email, req.City, req.Frequency); err != nil {
-			// 409 Conflict when email already subscribed
+		if verifier != nil {
+			if err := verifier.Verify(c.Request.Context(), req.captchaToken(), c.ClientIP()); err != nil {
+				if errors.Is(err, captcha.ErrVerificationFailed) {
+					// 403 Bot check failed
+					c.JSON(http.StatusForbidden, gin.H{"error": captcha.ErrVerificationFailed.Error()})
+					return
+				}
+				logger.Error("captcha verification unavailable", zap.Error(err))
+				// 503 Cannot verify right now; refuse rather than let bots through
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "captcha verification unavailable, try again later"})
+				return
+			}
+		}
+
+		if err := svc.Subscribe(c.Request.Context(), req.subscription(c.GetHeader("Accept-Language"))); err != nil {
+			// 409 Conflict when the email is already subscribed for one of the cities
 			if errors.Is(err, services.ErrAlreadySubscribed) {
 				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 				return
@@ -37,17 +126,58 @@ func SubscribeHandler(svc services.SubscriptionService) gin.HandlerFunc {
 		}
 
 		// 200 Subscription successful
+		if req.Phone != "" {
+			c.JSON(http.StatusOK, gin.H{"message": "Subscription successful. Confirmation code sent by SMS."})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Subscription successful. Confirmation email sent."})
 	}
 }
 
-// ConfirmHandler handles GET /api/confirm/:token
-func ConfirmHandler(svc services.SubscriptionService) gin.HandlerFunc {
+type confirmPhoneRequest struct {
+	Phone string `form:"phone" json:"phone" binding:"required"`
+	Code  string `form:"code"  json:"code"  binding:"required,len=6,numeric"`
+}
+
+// ConfirmPhoneHandler handles POST /api/confirm-phone, which confirms an SMS
+// subscription with the code texted to its phone.
+func ConfirmPhoneHandler(svc services.SubscriptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req confirmPhoneRequest
+		if err := c.ShouldBind(&req); err != nil {
+			// 400 Invalid input
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := svc.ConfirmPhone(c.Request.Context(), req.Phone, req.Code)
+		switch {
+		case err == nil:
+			// 200 OK
+			c.JSON(http.StatusOK, gin.H{"message": "Subscription confirmed successfully"})
+		case errors.Is(err, services.ErrInvalidPhone):
+			// 400 Invalid phone
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidCode):
+			// 404 No pending subscription with this code
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			// 500 Unexpected error
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// ConfirmHandler handles GET /api/confirm/:token.
+// Browsers get an HTML page, API clients get JSON.
+func ConfirmHandler(svc services.SubscriptionService, links *linksign.Signer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.Param("token")
 		if token == "" {
 			// 400 Invalid token
-			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidToken.Error()})
+			respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": services.ErrInvalidToken.Error()})
+			return
+		}
+		if !signedLink(c, links, linksign.PurposeConfirm) {
 			return
 		}
 
@@ -55,24 +185,129 @@ func ConfirmHandler(svc services.SubscriptionService) gin.HandlerFunc {
 		switch {
 		case err == nil:
 			// 200 OK
-			c.JSON(http.StatusOK, gin.H{"message": "Subscription confirmed successfully"})
+			respond(c, http.StatusOK, resultPage{
+				Title:   "Subscription confirmed",
+				Message: "Thank you! Your subscription is active and the first weather update is on its way.",
+				Success: true,
+			}, gin.H{"message": "Subscription confirmed successfully"})
 		case errors.Is(err, services.ErrInvalidToken):
 			// 400 Invalid token
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": err.Error()})
 		case errors.Is(err, services.ErrTokenNotFound):
 			// 404 Token not found
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respond(c, http.StatusNotFound, pageLinkNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTokenExpired):
+			// 410 Link expired; offer a fresh one
+			resendURL := c.Request.URL.Path + "/resend"
+			if sig := c.Query(linksign.Query); sig != "" {
+				resendURL += "?" + linksign.Query + "=" + url.QueryEscape(sig)
+			}
+			respond(c, http.StatusGone, resultPage{
+				Title:       "Confirmation link expired",
+				Message:     "This confirmation link has expired. We can email you a new one.",
+				ActionURL:   resendURL,
+				ActionLabel: "Send a new link",
+			}, gin.H{"error": err.Error(), "resend_url": resendURL})
+		default:
+			// 500 Unexpected error
+			respond(c, http.StatusInternalServerError, pageServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// ResendConfirmationHandler handles POST /api/confirm/:token/resend, which emails a new
+// confirmation link in place of an expired one.
+// Browsers get an HTML page, API clients get JSON.
+func ResendConfirmationHandler(svc services.SubscriptionService, links *linksign.Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !signedLink(c, links, linksign.PurposeConfirm) {
+			return
+		}
+		err := svc.ResendConfirmation(c.Request.Context(), c.Param("token"))
+		switch {
+		case err == nil:
+			// 200 OK
+			respond(c, http.StatusOK, resultPage{
+				Title:   "Check your inbox",
+				Message: "We have sent you a new confirmation link.",
+				Success: true,
+			}, gin.H{"message": "Confirmation email sent."})
+		case errors.Is(err, services.ErrInvalidToken):
+			// 400 Invalid token
+			respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTokenNotFound):
+			// 404 Token not found (already confirmed, or the sign-up was cleaned up)
+			respond(c, http.StatusNotFound, pageLinkNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEmailSuppressed):
+			// 400 The address cannot receive emails
+			respond(c, http.StatusBadRequest, resultPage{
+				Title:   "Cannot send email",
+				Message: "This email address cannot receive emails from us.",
+			}, gin.H{"error": err.Error()})
+		default:
+			// 500 Unexpected error
+			respond(c, http.StatusInternalServerError, pageServerError, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// NotMeHandler handles the "this wasn't me" link of confirmation emails. GET
+// /api/confirm/:token/not-me asks for confirmation with a button, so link scanners
+// cannot delete sign-ups; POST deletes the pending subscription and suppresses the
+// address for a while.
+// Browsers get an HTML page, API clients get JSON.
+func NotMeHandler(svc services.SubscriptionService, links *linksign.Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !signedLink(c, links, linksign.PurposeConfirm) {
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			// 200 Ask before deleting
+			actionURL := c.Request.URL.RequestURI()
+			respond(c, http.StatusOK, resultPage{
+				Title:       "Didn't sign up?",
+				Message:     "Someone asked for weather updates to be sent to your address. We can delete the request and stop emailing you.",
+				ActionURL:   actionURL,
+				ActionLabel: "This wasn't me",
+			}, gin.H{"message": "POST to action_url to delete the pending subscription", "action_url": actionURL})
+			return
+		}
+
+		err := svc.DisownSignup(c.Request.Context(), c.Param("token"))
+		switch {
+		case err == nil:
+			// 200 OK
+			respond(c, http.StatusOK, resultPage{
+				Title:   "Request deleted",
+				Message: "Sorry for the trouble. The sign-up is deleted and we will not email you again.",
+				Success: true,
+			}, gin.H{"message": "Pending subscription deleted"})
+		case errors.Is(err, services.ErrInvalidToken):
+			// 400 Invalid token
+			respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTokenNotFound):
+			// 404 Token not found (already confirmed, or already deleted)
+			respond(c, http.StatusNotFound, pageLinkNotFound, gin.H{"error": err.Error()})
+		default:
+			// 500 Unexpected error
+			respond(c, http.StatusInternalServerError, pageServerError, gin.H{"error": err.Error()})
 		}
 	}
 }
 
-// UnsubscribeHandler handles GET /api/unsubscribe/:token
-func UnsubscribeHandler(svc services.SubscriptionService) gin.HandlerFunc {
+// UnsubscribeHandler handles GET /api/unsubscribe/:token, the link in every email, and
+// POST /api/unsubscribe/:token, the RFC 8058 one-click unsubscribe that mailbox providers
+// send to the List-Unsubscribe URL (its "List-Unsubscribe=One-Click" body is ignored).
+// Browsers get an HTML page, API clients get JSON.
+func UnsubscribeHandler(svc services.SubscriptionService, links *linksign.Signer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.Param("token")
 		if token == "" {
 			// 400 Invalid token
-			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidToken.Error()})
+			respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": services.ErrInvalidToken.Error()})
+			return
+		}
+		if !signedLink(c, links, linksign.PurposeUnsubscribe) {
 			return
 		}
 
@@ -80,13 +315,32 @@ func UnsubscribeHandler(svc services.SubscriptionService) gin.HandlerFunc {
 		switch {
 		case err == nil:
 			// 200 OK
-			c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed successfully"})
+			respond(c, http.StatusOK, resultPage{
+				Title:   "Unsubscribed",
+				Message: "You will no longer receive weather updates for this subscription.",
+				Success: true,
+			}, gin.H{"message": "Unsubscribed successfully"})
 		case errors.Is(err, services.ErrInvalidToken):
 			// 400 Invalid token
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			respond(c, http.StatusBadRequest, pageInvalidLink, gin.H{"error": err.Error()})
 		case errors.Is(err, services.ErrTokenNotFound):
 			// 404 Token not found
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respond(c, http.StatusNotFound, pageLinkNotFound, gin.H{"error": err.Error()})
+		default:
+			// 500 Unexpected error
+			respond(c, http.StatusInternalServerError, pageServerError, gin.H{"error": err.Error()})
 		}
 	}
 }
+
+// signedLink reports whether the token of the link being served carries a valid
+// signature. A forged or scanned token gets the same 404 as an unknown one, without
+// a database lookup.
+func signedLink(c *gin.Context, links *linksign.Signer, purpose string) bool {
+	if links.Verify(purpose, c.Param("token"), c.Query(linksign.Query)) {
+		return true
+	}
+	// 404 Token not found
+	respond(c, http.StatusNotFound, pageLinkNotFound, gin.H{"error": services.ErrTokenNotFound.Error()})
+	return false
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/captcha"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/services"
+)
+
+func TestSubscribeRequest_Cities(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		req  subscribeRequest
+		want []string
+	}{
+		{"city", subscribeRequest{City: "Kyiv"}, []string{"Kyiv"}},
+		{"city with a comma", subscribeRequest{City: "Washington, D.C."}, []string{"Washington, D.C."}},
+		{"cities", subscribeRequest{Cities: []string{"Kyiv", "Lviv"}}, []string{"Kyiv", "Lviv"}},
+		{"both", subscribeRequest{City: "Odesa", Cities: []string{"Kyiv"}}, []string{"Odesa", "Kyiv"}},
+	} {
+		if got := tc.req.cities(); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: cities() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestManageRequest_ToUpdateKeepsTheCityWhole(t *testing.T) {
+	city := "Washington, D.C."
+	u, err := manageRequest{City: &city}.toUpdate()
+	if err != nil {
+		t.Fatalf("toUpdate() error: %v", err)
+	}
+	if want := []string{"Washington, D.C."}; !slices.Equal(u.Cities, want) {
+		t.Errorf("toUpdate() cities = %q, want %q", u.Cities, want)
+	}
+
+	u, _ = manageRequest{City: &city, Cities: []string{"Kyiv", "Lviv"}}.toUpdate()
+	if want := []string{"Kyiv", "Lviv"}; !slices.Equal(u.Cities, want) {
+		t.Errorf("toUpdate() with cities = %q, want %q", u.Cities, want)
+	}
+}
+
+// verifierFunc verifies CAPTCHA tokens with a function.
+type verifierFunc func(token string) error
+
+func (f verifierFunc) Verify(_ context.Context, token, _ string) error { return f(token) }
+
+// subscribingService records the subscriptions asked for.
+type subscribingService struct {
+	services.SubscriptionService
+
+	subscribed []services.SubscribeRequest
+}
+
+func (s *subscribingService) Subscribe(_ context.Context, req services.SubscribeRequest) error {
+	s.subscribed = append(s.subscribed, req)
+	return nil
+}
+
+func TestSubscribeHandler_Captcha(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	verifier := verifierFunc(func(token string) error {
+		switch token {
+		case "human":
+			return nil
+		case "down":
+			return errors.New("turnstile: unexpected status 502 Bad Gateway")
+		default:
+			return fmt.Errorf("%w: invalid-input-response", captcha.ErrVerificationFailed)
+		}
+	})
+	for _, tc := range []struct {
+		name     string
+		verifier captcha.Verifier
+		body     string
+		want     int
+	}{
+		{"captcha disabled", nil, `{"email":"anna@example.com","city":"Kyiv","frequency":"daily"}`, http.StatusOK},
+		{"valid token", verifier, `{"email":"anna@example.com","city":"Kyiv","frequency":"daily","captcha_token":"human"}`,
+			http.StatusOK},
+		{"no token", verifier, `{"email":"anna@example.com","city":"Kyiv","frequency":"daily"}`, http.StatusForbidden},
+		{"invalid token", verifier, `{"email":"anna@example.com","city":"Kyiv","frequency":"daily","captcha_token":"bot"}`,
+			http.StatusForbidden},
+		{"provider down", verifier, `{"email":"anna@example.com","city":"Kyiv","frequency":"daily","captcha_token":"down"}`,
+			http.StatusServiceUnavailable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &subscribingService{}
+			r := gin.New()
+			r.POST("/api/subscribe", SubscribeHandler(svc, tc.verifier, zap.NewNop()))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/subscribe", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.want, w.Body)
+			}
+			if subscribed := len(svc.subscribed) == 1; subscribed != (tc.want == http.StatusOK) {
+				t.Errorf("subscribed %d times with status %d", len(svc.subscribed), w.Code)
+			}
+		})
+	}
+}
+
+func TestSubscribeHandler_CaptchaFormFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, field := range []string{"captcha_token", "g-recaptcha-response", "cf-turnstile-response"} {
+		var got string
+		verifier := verifierFunc(func(token string) error { got = token; return nil })
+		r := gin.New()
+		r.POST("/api/subscribe", SubscribeHandler(&subscribingService{}, verifier, zap.NewNop()))
+
+		form := url.Values{"email": {"anna@example.com"}, "city": {"Kyiv"}, "frequency": {"daily"}, field: {"human"}}
+		req := httptest.NewRequest(http.MethodPost, "/api/subscribe", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || got != "human" {
+			t.Errorf("%s: status %d, verified token %q", field, w.Code, got)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/synthetic"
+)
+
+// SyntheticObservedHandler handles POST /api/synthetic/observed, called by the mailbox
+// of the synthetic subscriber for every email it receives. The caller authenticates
+// with the shared token, in the X-Synthetic-Token header or, for inbound mail services
+// that cannot set headers, the token query parameter. The body is ignored.
+func SyntheticObservedHandler(store *synthetic.Store, token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validToken(c, "X-Synthetic-Token", token) {
+			// 401 Missing or wrong token
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid synthetic token"})
+			return
+		}
+
+		if err := store.Observe(c.Request.Context(), time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 200 Observation recorded
+		c.JSON(http.StatusOK, gin.H{"status": "observed"})
+	}
+}
+
+// validToken reports whether the shared secret of a webhook is given in header or, for
+// callers that cannot set headers, the token query parameter.
+func validToken(c *gin.Context, header, want string) bool {
+	got := c.GetHeader(header)
+	if got == "" {
+		got = c.Query("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,13 +15,22 @@ type weatherRequest struct {
 
 // weatherResponse mirrors the Swagger schema for a successful weather lookup
 type weatherResponse struct {
-	Temperature float64 `json:"temperature"`
-	Humidity    int     `json:"humidity"`
-	Description string  `json:"description"`
+	Temperature float64      `json:"temperature"`
+	Humidity    int          `json:"humidity"`
+	Description string       `json:"description"`
+	Meta        *weatherMeta `json:"meta,omitempty"`
 }
 
-// WeatherHandler returns a Gin handler for GET /api/weather
-func WeatherHandler(fetcher weather.Fetcher) gin.HandlerFunc {
+// weatherMeta names the provider of the observation and the attribution its terms
+// require; it is omitted for observations whose provider is unknown
+type weatherMeta struct {
+	Provider    string `json:"provider"`
+	Attribution string `json:"attribution,omitempty"`
+}
+
+// WeatherHandler returns a Gin handler for GET /api/weather. attributions supplies the
+// attribution line of the provider reported in the response metadata.
+func WeatherHandler(fetcher weather.Fetcher, attributions weather.Attributions) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1) Bind and validate the 'city' query parameter
 		var req weatherRequest
@@ -32,6 +42,11 @@ func WeatherHandler(fetcher weather.Fetcher) gin.HandlerFunc {
 
 		// 2) Fetch current weather
 		w, err := fetcher.FetchCurrent(c.Request.Context(), req.City)
+		if errors.Is(err, weather.ErrNotCached) {
+			// 503 Overloaded and the city is not cached (Retry-After set by the load shedder)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			// 404 City not found (or any fetch error)
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -39,10 +54,14 @@ func WeatherHandler(fetcher weather.Fetcher) gin.HandlerFunc {
 		}
 
 		// 3) 200 Successful operation
-		c.JSON(http.StatusOK, weatherResponse{
+		resp := weatherResponse{
 			Temperature: w.Temp,
 			Humidity:    w.Humidity,
 			Description: w.Description,
-		})
+		}
+		if w.Provider != "" {
+			resp.Meta = &weatherMeta{Provider: w.Provider, Attribution: attributions[w.Provider]}
+		}
+		c.JSON(http.StatusOK, resp)
 	}
 }
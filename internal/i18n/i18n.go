@@ -0,0 +1,120 @@
+// Package i18n holds the message catalogs of subscriber emails, one per locale in
+// catalogs/<locale>.json. A subscription's stored locale selects the catalog; anything
+// missing from it, and every unsupported locale, falls back to English.
+//
+// Messages are fmt format strings. Catalogs are trusted: messages used in HTML may
+// contain markup, and the email templates escape the arguments instead.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale of the fallback catalog.
+const Default = "en"
+
+//go:embed catalogs/*.json
+var catalogFS embed.FS
+
+var catalogs = mustLoad()
+
+func mustLoad() map[string]map[string]string {
+	files, err := catalogFS.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+	out := make(map[string]map[string]string, len(files))
+	for _, f := range files {
+		data, err := catalogFS.ReadFile("catalogs/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: catalog %s: %v", f.Name(), err))
+		}
+		out[strings.TrimSuffix(f.Name(), path.Ext(f.Name()))] = messages
+	}
+	if _, ok := out[Default]; !ok {
+		panic("i18n: no catalog of the default locale " + Default)
+	}
+	return out
+}
+
+// Language is a supported locale and its name in that language, for pickers.
+type Language struct {
+	Locale string
+	Name   string
+}
+
+// Languages lists the supported locales, the default first.
+func Languages() []Language {
+	out := make([]Language, 0, len(catalogs))
+	for locale := range catalogs {
+		out = append(out, Language{Locale: locale, Name: T(locale, "language.name")})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].Locale == Default) != (out[j].Locale == Default) {
+			return out[i].Locale == Default
+		}
+		return out[i].Locale < out[j].Locale
+	})
+	return out
+}
+
+// Match returns the supported locale of a language tag ("uk-UA" is "uk"), or "" when
+// there is none.
+func Match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if _, ok := catalogs[tag]; ok {
+		return tag
+	}
+	base, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	if _, ok := catalogs[base]; ok {
+		return base
+	}
+	return ""
+}
+
+// Negotiate picks the locale of a new subscription: explicit when supported, otherwise
+// the most preferred supported language of an Accept-Language header, otherwise Default.
+func Negotiate(explicit, acceptLanguage string) string {
+	if l := Match(explicit); l != "" {
+		return l
+	}
+	best, bestQ := Default, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if l := Match(tag); l != "" && q > bestQ {
+			best, bestQ = l, q
+		}
+	}
+	return best
+}
+
+// T returns the message key of locale formatted with args, falling back to the default
+// catalog, and to the key itself when no catalog has it.
+func T(locale, key string, args ...any) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		if msg, ok = catalogs[Default][key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,55 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+var verbs = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+// Every translation has to take the same arguments as the English message it replaces.
+func TestCatalogs_MatchDefault(t *testing.T) {
+	for locale, messages := range catalogs {
+		for key, msg := range messages {
+			def, ok := catalogs[Default][key]
+			if !ok {
+				t.Errorf("%s: %q is not in the default catalog", locale, key)
+				continue
+			}
+			if got, want := verbs.FindAllString(msg, -1), verbs.FindAllString(def, -1); len(got) != len(want) {
+				t.Errorf("%s: %q has verbs %v, the default has %v", locale, key, got, want)
+			}
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct{ explicit, header, want string }{
+		{"uk", "", "uk"},
+		{"uk-UA", "en", "uk"},
+		{"", "fr-FR,uk;q=0.8,en;q=0.5", "uk"},
+		{"", "en-GB,uk;q=0.3", "en"},
+		{"pl", "de", Default},
+		{"", "", Default},
+	}
+	for _, tc := range cases {
+		if got := Negotiate(tc.explicit, tc.header); got != tc.want {
+			t.Errorf("Negotiate(%q, %q) = %q, want %q", tc.explicit, tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestT_FallsBack(t *testing.T) {
+	if got := T("uk", "update.humidity", 40); got != "Вологість: 40%" {
+		t.Errorf("T(uk) = %q", got)
+	}
+	if got := T("pl", "update.humidity", 40); got != "Humidity: 40%" {
+		t.Errorf("T() of an unsupported locale = %q, want English", got)
+	}
+	if got := T("uk", "no.such.key"); got != "no.such.key" {
+		t.Errorf("T() of an unknown key = %q", got)
+	}
+	if langs := Languages(); len(langs) < 2 || langs[0].Locale != Default {
+		t.Errorf("Languages() = %v, want the default first", langs)
+	}
+}
@@ -0,0 +1,60 @@
+package linksign
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSigner_SignVerify(t *testing.T) {
+	s := New(bytes.Repeat([]byte{1}, 32), false)
+	token := "5f8e0a43-8d0c-4a4f-9d5e-2a3b8c7d6e1f"
+
+	sig := s.Sign(PurposeUnsubscribe, token)
+	if !s.Verify(PurposeUnsubscribe, token, sig) {
+		t.Fatal("Verify() rejected its own signature")
+	}
+	if s.Verify(PurposeConfirm, token, sig) {
+		t.Error("an unsubscribe signature must not verify a confirm link")
+	}
+	if s.Verify(PurposeUnsubscribe, "00000000-0000-0000-0000-000000000000", sig) {
+		t.Error("Verify() accepted the signature of another token")
+	}
+	if !s.Verify(PurposeUnsubscribe, token, "") {
+		t.Error("unsigned links must be accepted unless signatures are required")
+	}
+
+	other := New(bytes.Repeat([]byte{2}, 32), false)
+	if other.Verify(PurposeUnsubscribe, token, sig) {
+		t.Error("Verify() accepted a signature made with another key")
+	}
+}
+
+func TestSigner_Required(t *testing.T) {
+	s := New(bytes.Repeat([]byte{1}, 32), true)
+	if s.Verify(PurposeConfirm, "token", "") {
+		t.Error("unsigned link accepted while signatures are required")
+	}
+}
+
+func TestSigner_URL(t *testing.T) {
+	var disabled *Signer
+	if got := disabled.URL("https://x.test", PurposeConfirm, "abc"); got != "https://x.test/api/confirm/abc" {
+		t.Errorf("URL() without a key = %q", got)
+	}
+	if !disabled.Verify(PurposeConfirm, "abc", "anything") {
+		t.Error("a nil signer must accept every link")
+	}
+
+	s := New(bytes.Repeat([]byte{1}, 32), false)
+	got := s.URL("https://x.test", PurposeConfirm, "abc")
+	want := "https://x.test/api/confirm/abc?sig=" + s.Sign(PurposeConfirm, "abc")
+	if got != want || !strings.Contains(got, "?sig=") {
+		t.Errorf("URL() = %q, want %q", got, want)
+	}
+	got = s.ActionURL("https://x.test", PurposeConfirm, "abc", "not-me")
+	want = "https://x.test/api/confirm/abc/not-me?sig=" + s.Sign(PurposeConfirm, "abc")
+	if got != want {
+		t.Errorf("ActionURL() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Package linktoken derives the tokens of the unsubscribe and manage links of a
+// subscription from its id with HMAC-SHA256 under LINK_TOKEN_KEY, so tokens are never
+// stored: a leaked database yields no working links, and the links in every email are
+// rebuilt from the id alone.
+//
+// A token is a UUID (version 8) holding the subscription id in its first 4 bytes and
+// 90 bits of the HMAC of its purpose and id in the rest. Links emailed before tokens
+// were derived carry random (version 4) UUIDs, which the repository still finds by
+// their hash.
+package linktoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+// Token purposes: a token opens only the links of its purpose.
+const (
+	Unsubscribe = "unsubscribe"
+	Manage      = "manage"
+)
+
+// Deriver derives and checks link tokens.
+type Deriver struct {
+	key []byte
+}
+
+// NewDeriver builds a deriver from LINK_TOKEN_KEY.
+func NewDeriver(cfg *config.Config) (*Deriver, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.LinkTokenKey)
+	if err != nil || len(key) < 32 {
+		return nil, errors.New("LINK_TOKEN_KEY must be at least 32 base64-encoded bytes")
+	}
+	return New(key), nil
+}
+
+// New returns a deriver keyed by key.
+func New(key []byte) *Deriver {
+	return &Deriver{key: key}
+}
+
+// Token returns the token of the link of purpose of subscription id.
+func (d *Deriver) Token(purpose string, id int) uuid.UUID {
+	var t uuid.UUID
+	binary.BigEndian.PutUint32(t[:4], uint32(id))
+	h := hmac.New(sha256.New, d.key)
+	h.Write([]byte(purpose))
+	h.Write([]byte{0})
+	h.Write(t[:4])
+	copy(t[4:], h.Sum(nil))
+	t[6] = t[6]&0x0f | 0x80 // version 8
+	t[8] = t[8]&0x3f | 0x80 // RFC 4122 variant
+	return t
+}
+
+// ID returns the subscription id token was derived from for purpose, and false for
+// any other token: a legacy random one, one of another purpose or key, or a forgery.
+func (d *Deriver) ID(purpose string, token uuid.UUID) (int, bool) {
+	if token.Version() != 8 {
+		return 0, false
+	}
+	id := int(binary.BigEndian.Uint32(token[:4]))
+	want := d.Token(purpose, id)
+	if !hmac.Equal(token[:], want[:]) {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,66 @@
+package linktoken
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+func TestDeriver_TokenID(t *testing.T) {
+	d := New(bytes.Repeat([]byte{1}, 32))
+	token := d.Token(Unsubscribe, 42)
+	if token.Version() != 8 || token.Variant() != uuid.RFC4122 {
+		t.Errorf("Token() = %s, want a version 8 RFC 4122 UUID", token)
+	}
+	if token != d.Token(Unsubscribe, 42) {
+		t.Error("Token() is not deterministic")
+	}
+
+	for _, tc := range []struct {
+		name    string
+		d       *Deriver
+		purpose string
+		token   uuid.UUID
+		wantID  int
+		wantOK  bool
+	}{
+		{"own token", d, Unsubscribe, token, 42, true},
+		{"other purpose", d, Manage, token, 0, false},
+		{"other key", New(bytes.Repeat([]byte{2}, 32)), Unsubscribe, token, 0, false},
+		{"other id", d, Unsubscribe, withID(token, 43), 0, false},
+		{"tampered mac", d, Unsubscribe, withByte(token, 15, token[15]^1), 0, false},
+		{"legacy random token", d, Unsubscribe, uuid.New(), 0, false},
+		{"large id", d, Manage, d.Token(Manage, 1<<31-1), 1<<31 - 1, true},
+	} {
+		id, ok := tc.d.ID(tc.purpose, tc.token)
+		if id != tc.wantID || ok != tc.wantOK {
+			t.Errorf("%s: ID() = %d, %v; want %d, %v", tc.name, id, ok, tc.wantID, tc.wantOK)
+		}
+	}
+}
+
+func TestNewDeriver_KeyLength(t *testing.T) {
+	for key, wantErr := range map[string]bool{
+		"":            true,
+		"c2hvcnQ=":    true, // "short"
+		"not base64!": true,
+		"AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=": false,
+	} {
+		_, err := NewDeriver(&config.Config{LinkTokenKey: key})
+		if (err != nil) != wantErr {
+			t.Errorf("NewDeriver(%q) error = %v, want error %v", key, err, wantErr)
+		}
+	}
+}
+
+func withID(t uuid.UUID, id byte) uuid.UUID {
+	return withByte(t, 3, id)
+}
+
+func withByte(t uuid.UUID, i int, b byte) uuid.UUID {
+	t[i] = b
+	return t
+}
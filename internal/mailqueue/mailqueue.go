@@ -0,0 +1,121 @@
+// Package mailqueue takes email delivery out of the HTTP request path and the scheduler
+// loop: producers enqueue batches in Redis and cmd/worker sends them.
+//
+// Jobs are JSON in a Redis list, encrypted with the PII cipher: they hold addresses and
+// unsubscribe links. The queue needs a Redis of its own that never evicts keys (see
+// redisclient.OpenMailQueue), not the cache's. A worker moves the job it works on to a
+// processing list of its own, so a job survives the worker being killed mid-send: on
+// start the worker puts its unfinished jobs back in the queue (a batch interrupted half
+// way may then be sent twice, never lost). Messages that fail are retried with backoff
+// from a delayed set, up to a maximum number of attempts. Scheduled emails are logged
+// as queued in the delivery log; the worker records them sent, or failed once it gives
+// up on them.
+package mailqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+const (
+	pendingKey       = "mailqueue:pending"
+	retryKey         = "mailqueue:retry" // sorted set scored by the Unix time a retry is due
+	processingPrefix = "mailqueue:processing:"
+)
+
+// enqueueTimeout bounds how long a producer waits for Redis.
+const enqueueTimeout = 5 * time.Second
+
+var (
+	enqueued = metrics.NewCounter("weather_mailqueue_enqueued_total", "Emails put in the queue.")
+	sent     = metrics.NewCounter("weather_mailqueue_sent_total", "Emails sent by the queue worker.")
+	retried  = metrics.NewCounter("weather_mailqueue_retried_total", "Emails scheduled for another attempt after failing.")
+	dropped  = metrics.NewCounter("weather_mailqueue_dropped_total", "Emails given up after the last attempt, or unreadable.")
+)
+
+// Job is a batch of emails in the queue.
+type Job struct {
+	ID         string               `json:"id"`
+	Messages   []email.EmailMessage `json:"messages"`
+	Attempt    int                  `json:"attempt"` // failed attempts so far
+	EnqueuedAt time.Time            `json:"enqueued_at"`
+}
+
+// Queue is an email.EmailSender that enqueues batches instead of sending them: a nil
+// error from SendBatch means the batch is safely queued, not delivered.
+type Queue struct {
+	rdb    *redis.Client
+	cipher *pii.Cipher
+	logger *zap.Logger
+}
+
+// New returns a queue in rdb, which encrypts its jobs with cipher.
+func New(rdb *redis.Client, cipher *pii.Cipher, logger *zap.Logger) *Queue {
+	return &Queue{rdb: rdb, cipher: cipher, logger: logger}
+}
+
+// Queues implements notify.Queuing.
+func (q *Queue) Queues() bool {
+	return true
+}
+
+// SendBatch implements email.EmailSender.
+func (q *Queue) SendBatch(messages []email.EmailMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), enqueueTimeout)
+	defer cancel()
+	return q.Enqueue(ctx, messages)
+}
+
+// Enqueue queues messages as one job.
+func (q *Queue) Enqueue(ctx context.Context, messages []email.EmailMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	job := Job{ID: uuid.NewString(), Messages: messages, EnqueuedAt: time.Now()}
+	raw, err := encodeJob(q.cipher, job)
+	if err != nil {
+		return err
+	}
+	if err := q.rdb.LPush(ctx, pendingKey, raw).Err(); err != nil {
+		q.logger.Error("failed to enqueue emails", zap.Int("count", len(messages)), zap.Error(err))
+		return fmt.Errorf("mailqueue: enqueue: %w", err)
+	}
+	enqueued.Add(float64(len(messages)))
+	q.logger.Debug("queued emails", zap.String("job", job.ID), zap.Int("count", len(messages)))
+	return nil
+}
+
+// encodeJob returns job as stored in Redis: JSON encrypted with cipher.
+func encodeJob(cipher *pii.Cipher, job Job) (string, error) {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return "", fmt.Errorf("mailqueue: marshal job: %w", err)
+	}
+	sealed, err := cipher.Encrypt(string(raw))
+	if err != nil {
+		return "", fmt.Errorf("mailqueue: encrypt job: %w", err)
+	}
+	return sealed, nil
+}
+
+// decodeJob reads a job stored by encodeJob; plaintext jobs queued before encryption are
+// read as they are.
+func decodeJob(cipher *pii.Cipher, stored string) (Job, error) {
+	var job Job
+	raw, err := cipher.Decrypt(stored)
+	if err != nil {
+		return job, err
+	}
+	err = json.Unmarshal([]byte(raw), &job)
+	return job, err
+}
@@ -0,0 +1,204 @@
+package mailqueue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// pollTimeout is how long the worker blocks waiting for a job before it looks for due
+// retries again.
+const pollTimeout = time.Second
+
+// promoteScript moves a due retry back to the queue, once even with several workers.
+var promoteScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+    redis.call('LPUSH', KEYS[2], ARGV[1])
+end
+return 0`)
+
+// Outcomes records in the delivery log what became of queued emails, by the open token
+// of their entry (email.EmailMessage.DeliveryToken); see repository.DeliveryRepository.
+type Outcomes interface {
+	Settle(ctx context.Context, sent []uuid.UUID, sentAt time.Time, failed map[uuid.UUID]string) error
+}
+
+// Worker sends the queued emails with the real sender.
+type Worker struct {
+	rdb         *redis.Client
+	cipher      *pii.Cipher
+	sender      email.EmailSender
+	outcomes    Outcomes
+	processing  string
+	maxAttempts int
+	backoff     time.Duration // before the first retry, doubling
+	logger      *zap.Logger
+}
+
+// NewWorker returns a worker named id, which must be unique among the running workers
+// and stable across restarts of the same one (e.g. the host name). It decrypts jobs with
+// cipher and records the outcome of the scheduled emails in outcomes.
+func NewWorker(rdb *redis.Client, cipher *pii.Cipher, sender email.EmailSender, outcomes Outcomes, id string,
+	maxAttempts int, backoff time.Duration, logger *zap.Logger,
+) *Worker {
+	return &Worker{rdb: rdb, cipher: cipher, sender: sender, outcomes: outcomes, processing: processingPrefix + id,
+		maxAttempts: maxAttempts, backoff: backoff, logger: logger}
+}
+
+// Run works the queue until ctx is done, finishing the job in hand first.
+func (w *Worker) Run(ctx context.Context) {
+	w.requeueUnfinished(ctx)
+	for ctx.Err() == nil {
+		w.promoteDue(ctx, time.Now())
+
+		raw, err := w.rdb.BLMove(ctx, pendingKey, w.processing, "RIGHT", "LEFT", pollTimeout).Result()
+		if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			w.logger.Error("failed to take a job from the email queue", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollTimeout):
+			}
+			continue
+		}
+
+		// the job is finished even if the worker is being stopped meanwhile
+		done := context.WithoutCancel(ctx)
+		w.process(done, raw)
+		if err := w.rdb.LRem(done, w.processing, 1, raw).Err(); err != nil {
+			w.logger.Error("failed to remove a finished job, it will be sent again", zap.Error(err))
+		}
+	}
+}
+
+// requeueUnfinished puts back in the queue the jobs this worker was processing when it
+// last stopped.
+func (w *Worker) requeueUnfinished(ctx context.Context) {
+	n := 0
+	for {
+		err := w.rdb.LMove(ctx, w.processing, pendingKey, "RIGHT", "RIGHT").Err()
+		if errors.Is(err, redis.Nil) {
+			break
+		}
+		if err != nil {
+			w.logger.Error("failed to requeue unfinished jobs", zap.Error(err))
+			return
+		}
+		n++
+	}
+	if n > 0 {
+		w.logger.Warn("requeued jobs left unfinished by the last run", zap.Int("jobs", n))
+	}
+}
+
+// promoteDue moves retries due at now back to the queue.
+func (w *Worker) promoteDue(ctx context.Context, now time.Time) {
+	due, err := w.rdb.ZRangeByScore(ctx, retryKey, &redis.ZRangeBy{
+		Min: "-inf", Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.Error("failed to read due email retries", zap.Error(err))
+		}
+		return
+	}
+	for _, raw := range due {
+		if err := promoteScript.Run(ctx, w.rdb, []string{retryKey, pendingKey}, raw).Err(); err != nil {
+			w.logger.Error("failed to requeue an email retry", zap.Error(err))
+		}
+	}
+}
+
+// process sends the emails of a job and schedules another attempt for the failed ones.
+func (w *Worker) process(ctx context.Context, raw string) {
+	job, err := decodeJob(w.cipher, raw)
+	if err != nil {
+		dropped.Inc()
+		w.logger.Error("dropping an unreadable email job", zap.Error(err))
+		return
+	}
+
+	batchErr := w.sender.SendBatch(job.Messages)
+	failed := failedMessages(job.Messages, batchErr)
+	sent.Add(float64(len(job.Messages) - len(failed)))
+	w.settle(ctx, job.Messages, batchErr, false)
+	if len(failed) == 0 {
+		return
+	}
+
+	job.Attempt++
+	if job.Attempt >= w.maxAttempts {
+		dropped.Add(float64(len(failed)))
+		w.logger.Error("giving up on emails after the last attempt", zap.String("job", job.ID),
+			zap.Int("attempts", job.Attempt), zap.Int("count", len(failed)), zap.Error(batchErr))
+		w.settle(ctx, job.Messages, batchErr, true)
+		return
+	}
+	job.Messages = failed
+	retry, err := encodeJob(w.cipher, job)
+	if err == nil {
+		due := time.Now().Add(w.backoff << (job.Attempt - 1))
+		err = w.rdb.ZAdd(ctx, retryKey, redis.Z{Score: float64(due.Unix()), Member: retry}).Err()
+	}
+	if err != nil {
+		dropped.Add(float64(len(failed)))
+		w.logger.Error("failed to schedule an email retry", zap.String("job", job.ID), zap.Error(err))
+		w.settle(ctx, failed, err, true)
+		return
+	}
+	retried.Add(float64(len(failed)))
+	w.logger.Warn("emails failed, retrying later", zap.String("job", job.ID),
+		zap.Int("attempt", job.Attempt), zap.Int("count", len(failed)))
+}
+
+// settle records in the delivery log the messages SendBatch sent, with batchErr its
+// error, or, when final, the ones it failed to send.
+func (w *Worker) settle(ctx context.Context, messages []email.EmailMessage, batchErr error, final bool) {
+	var sentTokens []uuid.UUID
+	var failedTokens map[uuid.UUID]string
+	for i, m := range messages {
+		token, err := uuid.Parse(m.DeliveryToken)
+		if err != nil {
+			continue // not in the delivery log
+		}
+		switch msgErr := email.MessageErr(batchErr, i); {
+		case msgErr == nil:
+			if !final {
+				sentTokens = append(sentTokens, token)
+			}
+		case final:
+			if failedTokens == nil {
+				failedTokens = make(map[uuid.UUID]string)
+			}
+			failedTokens[token] = msgErr.Error()
+		}
+	}
+	if len(sentTokens) == 0 && len(failedTokens) == 0 {
+		return
+	}
+	if err := w.outcomes.Settle(ctx, sentTokens, time.Now(), failedTokens); err != nil {
+		w.logger.Error("failed to record the outcome of queued emails", zap.Error(err))
+	}
+}
+
+// failedMessages returns the messages of a batch that SendBatch did not send.
+func failedMessages(messages []email.EmailMessage, batchErr error) []email.EmailMessage {
+	var failed []email.EmailMessage
+	for i, m := range messages {
+		if email.MessageErr(batchErr, i) != nil {
+			failed = append(failed, m)
+		}
+	}
+	return failed
+}
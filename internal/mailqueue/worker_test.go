@@ -0,0 +1,212 @@
+package mailqueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+func TestFailedMessages(t *testing.T) {
+	msgs := []email.EmailMessage{{To: []string{"a@example.com"}}, {To: []string{"b@example.com"}}, {To: []string{"c@example.com"}}}
+
+	if got := failedMessages(msgs, nil); len(got) != 0 {
+		t.Errorf("failedMessages(nil) = %v, want none", got)
+	}
+	if got := failedMessages(msgs, errors.New("dial tcp: connection refused")); len(got) != 3 {
+		t.Errorf("failedMessages(batch error) = %v, want all", got)
+	}
+	partial := &email.BatchError{Total: 3, Failed: map[int]error{1: errors.New("451 try later")}}
+	if got := failedMessages(msgs, partial); len(got) != 1 || got[0].To[0] != "b@example.com" {
+		t.Errorf("failedMessages(partial) = %v, want only b@example.com", got)
+	}
+}
+
+// fakeSender refuses the recipients in reject, and everything with down set.
+type fakeSender struct {
+	reject map[string]bool
+	down   error
+}
+
+func (s *fakeSender) SendBatch(messages []email.EmailMessage) error {
+	if s.down != nil {
+		return s.down
+	}
+	failed := map[int]error{}
+	for i, m := range messages {
+		if s.reject[m.To[0]] {
+			failed[i] = errors.New("550 no such user")
+		}
+	}
+	if len(failed) > 0 {
+		return &email.BatchError{Total: len(messages), Failed: failed}
+	}
+	return nil
+}
+
+// settled is an Outcomes remembering what it was told.
+type settled struct {
+	sent   []uuid.UUID
+	failed map[uuid.UUID]string
+}
+
+func (s *settled) Settle(_ context.Context, sent []uuid.UUID, _ time.Time, failed map[uuid.UUID]string) error {
+	s.sent = append(s.sent, sent...)
+	for token, msg := range failed {
+		if s.failed == nil {
+			s.failed = make(map[uuid.UUID]string)
+		}
+		s.failed[token] = msg
+	}
+	return nil
+}
+
+// retryHook stands for Redis, remembering the retries scheduled.
+type retryHook struct {
+	retries []string
+}
+
+func (h *retryHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *retryHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (h *retryHook) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "zadd" {
+			h.retries = append(h.retries, fmt.Sprint(cmd.Args()[3]))
+		}
+		return nil
+	}
+}
+
+func testCipher(t *testing.T) *pii.Cipher {
+	t.Helper()
+	c, err := pii.New([]pii.Key{{ID: "k1", Secret: bytes.Repeat([]byte{1}, 32)}}, bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestJob_StoredEncrypted(t *testing.T) {
+	cipher := testCipher(t)
+	job := Job{ID: "j1", Messages: []email.EmailMessage{{To: []string{"a@example.com"},
+		UnsubscribeURL: "https://example.com/api/unsubscribe/secret"}}}
+
+	stored, err := encodeJob(cipher, job)
+	if err != nil {
+		t.Fatalf("encodeJob() error: %v", err)
+	}
+	if strings.Contains(stored, "a@example.com") || strings.Contains(stored, "secret") {
+		t.Errorf("stored job %q holds plaintext", stored)
+	}
+	got, err := decodeJob(cipher, stored)
+	if err != nil || got.Messages[0].UnsubscribeURL != job.Messages[0].UnsubscribeURL {
+		t.Errorf("decodeJob() = %+v, %v; want the job back", got, err)
+	}
+	// jobs queued before encryption are still read
+	if got, err := decodeJob(cipher, `{"id":"j0","messages":[]}`); err != nil || got.ID != "j0" {
+		t.Errorf("decodeJob(plaintext) = %+v, %v", got, err)
+	}
+}
+
+func TestWorker_Process_SettlesDeliveries(t *testing.T) {
+	sentToken, rejectedToken := uuid.New(), uuid.New()
+	messages := []email.EmailMessage{
+		{To: []string{"a@example.com"}, DeliveryToken: sentToken.String()},
+		{To: []string{"b@example.com"}, DeliveryToken: rejectedToken.String()},
+		{To: []string{"c@example.com"}}, // not in the delivery log, e.g. a confirmation
+	}
+	for _, tc := range []struct {
+		name        string
+		maxAttempts int
+		wantFailed  bool // the rejected one settled failed rather than retried
+	}{
+		{"retried", 3, false},
+		{"last attempt", 1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cipher := testCipher(t)
+			hook := &retryHook{}
+			rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+			rdb.AddHook(hook)
+			defer rdb.Close()
+			outcomes := &settled{}
+			sender := &fakeSender{reject: map[string]bool{"b@example.com": true}}
+			w := NewWorker(rdb, cipher, sender, outcomes, "worker-1", tc.maxAttempts, time.Minute, zap.NewNop())
+
+			raw, err := encodeJob(cipher, Job{ID: "j1", Messages: messages})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.process(context.Background(), raw)
+
+			if len(outcomes.sent) != 1 || outcomes.sent[0] != sentToken {
+				t.Errorf("settled sent %v, want only %v", outcomes.sent, sentToken)
+			}
+			_, failed := outcomes.failed[rejectedToken]
+			if failed != tc.wantFailed || len(outcomes.failed) > 1 {
+				t.Errorf("settled failed %v, want rejected one failed: %v", outcomes.failed, tc.wantFailed)
+			}
+			if wantRetries := map[bool]int{false: 1, true: 0}[tc.wantFailed]; len(hook.retries) != wantRetries {
+				t.Fatalf("scheduled %d retries, want %d", len(hook.retries), wantRetries)
+			}
+			if len(hook.retries) == 1 {
+				retry, err := decodeJob(cipher, hook.retries[0])
+				if err != nil || len(retry.Messages) != 1 || retry.Messages[0].DeliveryToken != rejectedToken.String() {
+					t.Errorf("retry = %+v, %v; want the rejected message only", retry, err)
+				}
+			}
+		})
+	}
+}
+
+// downHook stands for a Redis that cannot be reached.
+type downHook struct{}
+
+func (downHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (downHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (downHook) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		err := errors.New("connection refused")
+		cmd.SetErr(err)
+		return err
+	}
+}
+
+func TestWorker_Run_StopsWhileRedisIsDown(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	rdb.AddHook(downHook{})
+	defer rdb.Close()
+	w := NewWorker(rdb, testCipher(t), &fakeSender{}, &settled{}, "worker-1", 3, time.Minute, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(stopped)
+	}()
+	time.Sleep(50 * time.Millisecond) // the worker waits out a failed poll
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(pollTimeout / 2):
+		t.Fatal("Run() did not return on cancel while waiting to poll again")
+	}
+}
@@ -0,0 +1,319 @@
+// Package metrics keeps process-wide counters, gauges and histograms and serves them in
+// the Prometheus text exposition format, so any Prometheus-compatible scraper can
+// collect them from GET /metrics.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Counter is a value that only goes up, e.g. the number of rows purged.
+type Counter struct {
+	bits atomic.Uint64 // float64 bits
+}
+
+// Add increases the counter by v; negative values are ignored.
+func (c *Counter) Add(v float64) {
+	if v <= 0 {
+		return
+	}
+	addFloat(&c.bits, v)
+}
+
+// Inc increases the counter by one.
+func (c *Counter) Inc() { c.Add(1) }
+
+// Value returns the current value.
+func (c *Counter) Value() float64 { return math.Float64frombits(c.bits.Load()) }
+
+// Gauge is a value that goes up and down, e.g. the time of the last successful run.
+type Gauge struct {
+	bits atomic.Uint64 // float64 bits
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// SetToTime sets the gauge to t in Unix seconds.
+func (g *Gauge) SetToTime(t time.Time) { g.Set(float64(t.UnixNano()) / 1e9) }
+
+// Add changes the gauge by v.
+func (g *Gauge) Add(v float64) { addFloat(&g.bits, v) }
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// HistogramVec counts observations (e.g. latencies) in buckets, one histogram per value
+// of its label (e.g. the query). Pushed exporters get the count and sum of every
+// observation, as <name>_count and <name>_sum counters.
+type HistogramVec struct {
+	label   string
+	buckets []float64 // upper bounds, ascending; +Inf is implied
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, the last one +Inf; not cumulative
+	sum    float64
+}
+
+// Observe records v in the histogram of labelValue.
+func (h *HistogramVec) Observe(labelValue string, v float64) {
+	i := sort.SearchFloat64s(h.buckets, v) // first bound >= v
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[labelValue]
+	if s == nil {
+		s = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[labelValue] = s
+	}
+	s.counts[i]++
+	s.sum += v
+}
+
+// totals returns the number and the sum of every observation.
+func (h *HistogramVec) totals() (count, sum float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range h.series {
+		for _, n := range s.counts {
+			count += float64(n)
+		}
+		sum += s.sum
+	}
+	return count, sum
+}
+
+// writeTo writes the bucket, sum and count lines of every series, sorted by label value.
+func (h *HistogramVec) writeTo(w io.Writer, name string) (int64, error) {
+	h.mu.Lock()
+	values := make([]string, 0, len(h.series))
+	for v := range h.series {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	var b strings.Builder
+	for _, v := range values {
+		s := h.series[v]
+		label := fmt.Sprintf("%s=%q", h.label, v)
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = strconv.FormatFloat(h.buckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(&b, "%s_bucket{%s,le=%q} %d\n", name, label, le, cumulative)
+		}
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", name, label, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", name, label, cumulative)
+	}
+	h.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+type metric struct {
+	name, help, kind string
+	value            func() float64
+	histogram        *HistogramVec // kind "histogram"; value is unused
+}
+
+// Registry holds named metrics. Names must be unique.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// Default is the registry of the process, served by Handler.
+var Default = NewRegistry()
+
+func (r *Registry) register(name, help, kind string, value func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.metrics[name]; dup {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.metrics[name] = metric{name: name, help: help, kind: kind, value: value}
+}
+
+// NewCounter registers a counter; by convention its name ends in _total.
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, help, "counter", c.Value)
+	return c
+}
+
+// NewGauge registers a gauge.
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, help, "gauge", g.Value)
+	return g
+}
+
+// NewHistogramVec registers a histogram per value of label, with the given bucket upper
+// bounds (ascending); by convention its name ends in the unit, e.g. _seconds.
+func (r *Registry) NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	h := &HistogramVec{label: label, buckets: buckets, series: map[string]*histogram{}}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.metrics[name]; dup {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.metrics[name] = metric{name: name, help: help, kind: "histogram", histogram: h}
+	return h
+}
+
+// NewCounterFunc registers a counter read from value at every scrape, for counts kept
+// elsewhere (e.g. by a connection pool).
+func (r *Registry) NewCounterFunc(name, help string, value func() float64) {
+	r.register(name, help, "counter", value)
+}
+
+// NewGaugeFunc registers a gauge read from value at every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(name, help, "gauge", value)
+}
+
+// NewCounter registers a counter in Default.
+func NewCounter(name, help string) *Counter { return Default.NewCounter(name, help) }
+
+// NewGauge registers a gauge in Default.
+func NewGauge(name, help string) *Gauge { return Default.NewGauge(name, help) }
+
+// NewHistogramVec registers a histogram per value of label in Default.
+func NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	return Default.NewHistogramVec(name, help, label, buckets)
+}
+
+// NewCounterFunc registers a counter read from value in Default.
+func NewCounterFunc(name, help string, value func() float64) {
+	Default.NewCounterFunc(name, help, value)
+}
+
+// NewGaugeFunc registers a gauge read from value in Default.
+func NewGaugeFunc(name, help string, value func() float64) {
+	Default.NewGaugeFunc(name, help, value)
+}
+
+// Sample is the value of one metric at the time of a Snapshot.
+type Sample struct {
+	Name, Help string
+	Counter    bool // a counter; otherwise a gauge
+	Value      float64
+}
+
+// Snapshot returns the current value of every metric, sorted by name. A histogram is
+// its <name>_count and <name>_sum counters.
+func (r *Registry) Snapshot() []Sample {
+	r.mu.Lock()
+	all := make([]Sample, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		if m.histogram != nil {
+			count, sum := m.histogram.totals()
+			all = append(all,
+				Sample{Name: m.name + "_count", Help: m.help + " (observations)", Counter: true, Value: count},
+				Sample{Name: m.name + "_sum", Help: m.help + " (sum)", Counter: true, Value: sum})
+			continue
+		}
+		all = append(all, Sample{Name: m.name, Help: m.help, Counter: m.kind == "counter", Value: m.value()})
+	}
+	r.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// sorted returns the metrics by name.
+func (r *Registry) sorted() []metric {
+	r.mu.Lock()
+	all := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		all = append(all, m)
+	}
+	r.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	return all
+}
+
+// WriteTo writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, m := range r.sorted() {
+		n, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		if m.histogram != nil {
+			n, err := m.histogram.writeTo(w, m.name)
+			written += n
+			if err != nil {
+				return written, err
+			}
+			continue
+		}
+		n, err = fmt.Fprintf(w, "%s %s\n", m.name, strconv.FormatFloat(m.value(), 'g', -1, 64))
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Handler serves the metrics of r.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// Handler serves the metrics of Default.
+func Handler() http.Handler { return Default.Handler() }
+
+// Serve serves GET /metrics of Default on addr until ctx is done. Processes without an
+// HTTP server of their own (the scheduler) run it in a goroutine.
+func Serve(ctx context.Context, addr string, logger *zap.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	logger.Info("serving metrics", zap.String("addr", addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("metrics server stopped", zap.Error(err))
+	}
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_PrometheusTextFormat(t *testing.T) {
+	r := NewRegistry()
+	purged := r.NewCounter("rows_purged_total", "Rows purged.")
+	lastRun := r.NewGauge("last_run_timestamp_seconds", "Last run.")
+
+	purged.Add(3)
+	purged.Inc()
+	purged.Add(-5) // counters never go down
+	lastRun.Set(1.5e9)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := "# HELP last_run_timestamp_seconds Last run.\n" +
+		"# TYPE last_run_timestamp_seconds gauge\n" +
+		"last_run_timestamp_seconds 1.5e+09\n" +
+		"# HELP rows_purged_total Rows purged.\n" +
+		"# TYPE rows_purged_total counter\n" +
+		"rows_purged_total 4\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestRegistry_DuplicateNamePanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("x_total", "x")
+	defer func() {
+		if recover() == nil {
+			t.Error("registering x_total twice did not panic")
+		}
+	}()
+	r.NewGauge("x_total", "x")
+}
+
+func TestRegistry_FuncsReadAtSnapshot(t *testing.T) {
+	r := NewRegistry()
+	conns := 2.0
+	r.NewGaugeFunc("conns", "Open connections.", func() float64 { return conns })
+	r.NewCounterFunc("acquires_total", "Acquires.", func() float64 { return 7 })
+
+	conns = 3
+	got := r.Snapshot()
+	if len(got) != 2 || got[0].Value != 7 || !got[0].Counter || got[1].Value != 3 || got[1].Counter {
+		t.Errorf("Snapshot() = %+v, want acquires_total 7 (counter) and conns 3 (gauge)", got)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("query_duration_seconds", "Query latency.", "query", []float64{0.01, 0.1})
+	h.Observe("b", 0.05)
+	h.Observe("a", 0.005)
+	h.Observe("a", 0.01)
+	h.Observe("a", 2)
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error: %v", err)
+	}
+	want := "# HELP query_duration_seconds Query latency.\n" +
+		"# TYPE query_duration_seconds histogram\n" +
+		`query_duration_seconds_bucket{query="a",le="0.01"} 2` + "\n" +
+		`query_duration_seconds_bucket{query="a",le="0.1"} 2` + "\n" +
+		`query_duration_seconds_bucket{query="a",le="+Inf"} 3` + "\n" +
+		`query_duration_seconds_sum{query="a"} 2.015` + "\n" +
+		`query_duration_seconds_count{query="a"} 3` + "\n" +
+		`query_duration_seconds_bucket{query="b",le="0.01"} 0` + "\n" +
+		`query_duration_seconds_bucket{query="b",le="0.1"} 1` + "\n" +
+		`query_duration_seconds_bucket{query="b",le="+Inf"} 1` + "\n" +
+		`query_duration_seconds_sum{query="b"} 0.05` + "\n" +
+		`query_duration_seconds_count{query="b"} 1` + "\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", got, want)
+	}
+
+	got := r.Snapshot()
+	if len(got) != 2 || got[0].Name != "query_duration_seconds_count" || got[0].Value != 4 || !got[0].Counter ||
+		got[1].Name != "query_duration_seconds_sum" {
+		t.Errorf("Snapshot() = %+v, want the count (4) and sum counters", got)
+	}
+}
@@ -0,0 +1,233 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+// Exporter sends a snapshot of the metrics to a collector.
+type Exporter interface {
+	Export(ctx context.Context, samples []Sample) error
+}
+
+// NewExporter returns the exporter selected by METRICS_PUSH, or nil when pushing is
+// disabled. service names the process ("api", "scheduler") to the collector.
+func NewExporter(cfg *config.Config, service string) (Exporter, error) {
+	switch cfg.MetricsPush {
+	case "":
+		return nil, nil
+	case "statsd":
+		return NewStatsdExporter(cfg.MetricsPushAddr)
+	case "otlp":
+		return NewOTLPExporter(cfg.MetricsPushAddr, service), nil
+	default:
+		return nil, fmt.Errorf("unknown METRICS_PUSH %q (want statsd or otlp)", cfg.MetricsPush)
+	}
+}
+
+// Push exports the metrics of Default every interval until ctx is done, and once more
+// then, so counts since the last push are not lost on shutdown. Processes without a
+// Prometheus scraper run it in a goroutine.
+func Push(ctx context.Context, exp Exporter, interval time.Duration, logger *zap.Logger) {
+	push := func(ctx context.Context) {
+		if err := exp.Export(ctx, Default.Snapshot()); err != nil {
+			logger.Warn("failed to push metrics", zap.Error(err))
+		}
+	}
+
+	logger.Info("pushing metrics", zap.Duration("interval", interval))
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			push(ctx)
+		case <-ctx.Done():
+			flush, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			push(flush)
+			cancel()
+			return
+		}
+	}
+}
+
+// statsdMaxPacket keeps every datagram within a typical MTU.
+const statsdMaxPacket = 1432
+
+// StatsdExporter sends metrics to a statsd daemon over UDP. Counters are sent as the
+// increase since the previous export, gauges as their current value.
+type StatsdExporter struct {
+	conn net.Conn
+	sent map[string]float64 // counter values as of the previous export
+}
+
+func NewStatsdExporter(addr string) (*StatsdExporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: statsd %s: %w", addr, err)
+	}
+	return &StatsdExporter{conn: conn, sent: map[string]float64{}}, nil
+}
+
+func (e *StatsdExporter) Export(_ context.Context, samples []Sample) error {
+	var packet bytes.Buffer
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := e.conn.Write(bytes.TrimSuffix(packet.Bytes(), []byte("\n")))
+		packet.Reset()
+		return err
+	}
+
+	for _, s := range samples {
+		var lines string
+		switch {
+		case s.Counter:
+			delta := s.Value - e.sent[s.Name]
+			e.sent[s.Name] = s.Value
+			if delta <= 0 {
+				continue
+			}
+			lines = s.Name + ":" + formatFloat(delta) + "|c\n"
+		case s.Value < 0:
+			// a signed gauge value is an adjustment in statsd, so reset it first
+			lines = s.Name + ":0|g\n" + s.Name + ":" + formatFloat(s.Value) + "|g\n"
+		default:
+			lines = s.Name + ":" + formatFloat(s.Value) + "|g\n"
+		}
+		if packet.Len()+len(lines) > statsdMaxPacket {
+			if err := flush(); err != nil {
+				return fmt.Errorf("metrics: statsd: %w", err)
+			}
+		}
+		packet.WriteString(lines)
+	}
+	if err := flush(); err != nil {
+		return fmt.Errorf("metrics: statsd: %w", err)
+	}
+	return nil
+}
+
+func formatFloat(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+// OTLPExporter posts metrics to an OpenTelemetry collector in the OTLP/HTTP JSON
+// encoding. Counters are cumulative sums since the process started.
+type OTLPExporter struct {
+	url     string
+	service string
+	start   time.Time
+	client  *http.Client
+}
+
+// NewOTLPExporter posts to url, the collector's metrics endpoint
+// (e.g. http://otel-collector:4318/v1/metrics).
+func NewOTLPExporter(url, service string) *OTLPExporter {
+	return &OTLPExporter{url: url, service: service, start: time.Now(), client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpDataPoint struct {
+	AsDouble          float64 `json:"asDouble"`
+	StartTimeUnixNano string  `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string  `json:"timeUnixNano"`
+}
+
+type otlpSum struct {
+	AggregationTemporality int             `json:"aggregationTemporality"` // 2: cumulative
+	IsMonotonic            bool            `json:"isMonotonic"`
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, samples []Sample) error {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	start := strconv.FormatInt(e.start.UnixNano(), 10)
+
+	metrics := make([]otlpMetric, 0, len(samples))
+	for _, s := range samples {
+		m := otlpMetric{Name: s.Name, Description: s.Help}
+		if s.Counter {
+			m.Sum = &otlpSum{AggregationTemporality: 2, IsMonotonic: true,
+				DataPoints: []otlpDataPoint{{AsDouble: s.Value, StartTimeUnixNano: start, TimeUnixNano: now}}}
+		} else {
+			m.Gauge = &otlpGauge{DataPoints: []otlpDataPoint{{AsDouble: s.Value, TimeUnixNano: now}}}
+		}
+		metrics = append(metrics, m)
+	}
+
+	req := otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: "weather-" + e.service}},
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: "weather-api/internal/metrics"}, Metrics: metrics}},
+	}}}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("metrics: otlp: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("metrics: otlp: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("metrics: otlp: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("metrics: otlp: collector answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatsdExporter_SendsCounterIncreases(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	exp, err := NewStatsdExporter(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func() string {
+		t.Helper()
+		buf := make([]byte, statsdMaxPacket)
+		_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no packet: %v", err)
+		}
+		return string(buf[:n])
+	}
+
+	r := NewRegistry()
+	sent := r.NewCounter("emails_sent_total", "Emails sent.")
+	queue := r.NewGauge("queue_depth", "Queue depth.")
+	sent.Add(5)
+	queue.Set(3)
+	if err := exp.Export(context.Background(), r.Snapshot()); err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	if got, want := receive(), "emails_sent_total:5|c\nqueue_depth:3|g"; got != want {
+		t.Errorf("first packet = %q, want %q", got, want)
+	}
+
+	sent.Add(2)
+	queue.Set(-1)
+	if err := exp.Export(context.Background(), r.Snapshot()); err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	if got, want := receive(), "emails_sent_total:2|c\nqueue_depth:0|g\nqueue_depth:-1|g"; got != want {
+		t.Errorf("second packet = %q, want %q", got, want)
+	}
+}
+
+func TestOTLPExporter_PostsSumsAndGauges(t *testing.T) {
+	var got otlpRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	err := NewOTLPExporter(srv.URL, "api").Export(context.Background(), []Sample{
+		{Name: "emails_sent_total", Help: "Emails sent.", Counter: true, Value: 5},
+		{Name: "queue_depth", Help: "Queue depth.", Value: 3},
+	})
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+
+	if len(got.ResourceMetrics) != 1 || got.ResourceMetrics[0].Resource.Attributes[0].Value.StringValue != "weather-api" {
+		t.Fatalf("resource = %+v", got.ResourceMetrics)
+	}
+	ms := got.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(ms) != 2 || ms[0].Sum == nil || !ms[0].Sum.IsMonotonic || ms[0].Sum.DataPoints[0].AsDouble != 5 ||
+		ms[1].Gauge == nil || ms[1].Gauge.DataPoints[0].AsDouble != 3 {
+		t.Errorf("metrics = %+v", ms)
+	}
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// adminSubjectKey is the gin context key holding the authenticated admin identity.
+const adminSubjectKey = "admin_subject"
+
+// adminBearerKey is the gin context key set when the admin authenticated with a JWT.
+const adminBearerKey = "admin_bearer"
+
+// AdminAuth authenticates admin requests with either HTTP basic credentials
+// or an HS256-signed JWT bearer token. Empty credentials disable that method.
+type AdminAuth struct {
+	user      string
+	password  string
+	jwtSecret []byte
+	tokenTTL  time.Duration
+}
+
+func NewAdminAuth(user, password, jwtSecret string, tokenTTL time.Duration) *AdminAuth {
+	return &AdminAuth{user: user, password: password, jwtSecret: []byte(jwtSecret), tokenTTL: tokenTTL}
+}
+
+// Enabled reports whether any authentication method is configured.
+func (a *AdminAuth) Enabled() bool {
+	return a.user != "" || len(a.jwtSecret) > 0
+}
+
+// Middleware rejects unauthenticated requests with 401.
+func (a *AdminAuth) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject, bearer, ok := a.authenticate(c.Request)
+		if !ok {
+			if a.user != "" {
+				c.Header("WWW-Authenticate", `Basic realm="admin"`)
+			}
+			// 401 Unauthorized
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Set(adminSubjectKey, subject)
+		c.Set(adminBearerKey, bearer)
+		c.Next()
+	}
+}
+
+// authenticate returns the admin identity of r, and whether it came from a JWT.
+func (a *AdminAuth) authenticate(r *http.Request) (subject string, bearer, ok bool) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		subject, err := a.verifyToken(strings.TrimPrefix(header, "Bearer "))
+		return subject, true, err == nil
+	}
+	if user, pass, ok := r.BasicAuth(); ok && a.checkBasic(user, pass) {
+		return user, false, true
+	}
+	return "", false, false
+}
+
+func (a *AdminAuth) checkBasic(user, pass string) bool {
+	if a.user == "" {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.user)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(a.password)) == 1
+	return userOK && passOK
+}
+
+func (a *AdminAuth) verifyToken(raw string) (string, error) {
+	if len(a.jwtSecret) == 0 {
+		return "", errors.New("jwt authentication disabled")
+	}
+	token, err := jwt.ParseWithClaims(raw, &jwt.RegisteredClaims{}, func(*jwt.Token) (any, error) {
+		return a.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+	return token.Claims.GetSubject()
+}
+
+// IssueTokenHandler handles POST /api/admin/token: an admin authenticated with basic
+// credentials receives a short-lived JWT for subsequent calls. A JWT cannot be
+// exchanged for a new one, so a leaked token is good until it expires, not forever.
+func (a *AdminAuth) IssueTokenHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(a.jwtSecret) == 0 {
+			// 404 JWT not configured
+			c.JSON(http.StatusNotFound, gin.H{"error": "jwt authentication is not configured"})
+			return
+		}
+		if c.GetBool(adminBearerKey) {
+			// 403 Tokens are issued for basic credentials only
+			c.JSON(http.StatusForbidden, gin.H{"error": "tokens are issued for basic credentials only"})
+			return
+		}
+		now := time.Now()
+		expires := now.Add(a.tokenTTL)
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			Subject:   c.GetString(adminSubjectKey),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		})
+		signed, err := token.SignedString(a.jwtSecret)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"token": signed, "expires_at": expires.UTC()})
+	}
+}
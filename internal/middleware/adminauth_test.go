@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminAuth_IssuesTokensForBasicCredentialsOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAdminAuth("admin", "secret", "jwt-secret", time.Hour)
+	r := gin.New()
+	admin := r.Group("/api/admin", auth.Middleware())
+	admin.POST("/token", auth.IssueTokenHandler())
+	admin.GET("/stats", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(method, path string, authorize func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		authorize(req)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	basic := func(req *http.Request) { req.SetBasicAuth("admin", "secret") }
+
+	w := do(http.MethodPost, "/api/admin/token", basic)
+	var issued struct{ Token string }
+	if err := json.Unmarshal(w.Body.Bytes(), &issued); w.Code != http.StatusOK || err != nil || issued.Token == "" {
+		t.Fatalf("POST /token with basic credentials = %d %s", w.Code, w.Body)
+	}
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+issued.Token) }
+
+	for _, tc := range []struct {
+		name      string
+		method    string
+		path      string
+		authorize func(*http.Request)
+		want      int
+	}{
+		{"jwt on an admin endpoint", http.MethodGet, "/api/admin/stats", bearer, http.StatusOK},
+		{"jwt minting a jwt", http.MethodPost, "/api/admin/token", bearer, http.StatusForbidden},
+		{"wrong password", http.MethodPost, "/api/admin/token",
+			func(req *http.Request) { req.SetBasicAuth("admin", "guess") }, http.StatusUnauthorized},
+		{"forged jwt", http.MethodGet, "/api/admin/stats",
+			func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+issued.Token+"x") }, http.StatusUnauthorized},
+	} {
+		if w := do(tc.method, tc.path, tc.authorize); w.Code != tc.want {
+			t.Errorf("%s: %s %s = %d, want %d", tc.name, tc.method, tc.path, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+var concurrencyRejected = metrics.NewCounter("weather_http_concurrency_rejected_total",
+	"Requests rejected because their route group was at its concurrency limit.")
+
+// ConcurrencyLimit allows at most limit requests through at once; the routes sharing
+// the returned middleware share the limit. Requests over it are not queued: they get
+// 503 right away, so a traffic spike cannot pile up on the database and the weather
+// providers. A limit of 0 or less disables it.
+func ConcurrencyLimit(limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	slots := make(chan struct{}, limit)
+
+	return func(c *gin.Context) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			concurrencyRejected.Inc()
+			c.Header("Retry-After", "1")
+			// 503 Too many requests in flight
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server busy, try again later"})
+			return
+		}
+		defer func() { <-slots }()
+		c.Next()
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	entered, release := make(chan struct{}), make(chan struct{})
+	r := gin.New()
+	limit := ConcurrencyLimit(2)
+	r.GET("/api/weather", limit, func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/api/subscribe", limit, func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// two slow requests fill the limit
+	var inFlight sync.WaitGroup
+	codes := make(chan int, 2)
+	for range 2 {
+		inFlight.Add(1)
+		go func() {
+			defer inFlight.Done()
+			codes <- get("/api/weather").Code
+		}()
+		<-entered
+	}
+
+	// the routes sharing it are refused meanwhile, right away
+	w := get("/api/subscribe")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("at the limit: status %d, Retry-After %q; want 503, 1", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	inFlight.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("a request within the limit got %d, want 200", code)
+		}
+	}
+
+	// the finished requests gave their slots back
+	if w := get("/api/subscribe"); w.Code != http.StatusOK {
+		t.Errorf("after the requests finished: status %d, want 200", w.Code)
+	}
+}
+
+func TestConcurrencyLimit_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/weather", ConcurrencyLimit(0), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want 200: a limit of 0 lets everything through", w.Code)
+	}
+}
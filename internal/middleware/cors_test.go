@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := CORSConfig{
+		AllowedOrigins: []string{"https://weather.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "Idempotency-Key"},
+		MaxAge:         10 * time.Minute,
+	}
+	for _, tc := range []struct {
+		name          string
+		cfg           CORSConfig
+		method        string
+		origin        string
+		requestMethod string // Access-Control-Request-Method of a preflight
+		want          int
+		allowOrigin   string
+		preflight     bool // the Allow-Methods, Allow-Headers and Max-Age headers are set
+	}{
+		{"no origin", cfg, http.MethodGet, "", "", http.StatusOK, "", false},
+		{"allowed origin", cfg, http.MethodGet, "https://weather.example.com", "", http.StatusOK,
+			"https://weather.example.com", false},
+		{"allowed origin in another case", cfg, http.MethodGet, "https://Weather.Example.com", "", http.StatusOK,
+			"https://Weather.Example.com", false},
+		{"disallowed origin", cfg, http.MethodGet, "https://evil.example", "", http.StatusOK, "", false},
+		{"preflight", cfg, http.MethodOptions, "https://weather.example.com", "POST", http.StatusNoContent,
+			"https://weather.example.com", true},
+		{"preflight from a disallowed origin", cfg, http.MethodOptions, "https://evil.example", "POST",
+			http.StatusForbidden, "", false},
+		{"OPTIONS without a request method", cfg, http.MethodOptions, "https://weather.example.com", "",
+			http.StatusOK, "https://weather.example.com", false},
+		{"any origin", CORSConfig{AllowedOrigins: []string{"*"}}, http.MethodGet, "https://evil.example", "",
+			http.StatusOK, "https://evil.example", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(CORS(tc.cfg))
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			r.GET("/api/weather", ok)
+			r.OPTIONS("/api/weather", ok)
+
+			req := httptest.NewRequest(tc.method, "/api/weather", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tc.requestMethod)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			h := w.Header()
+			if w.Code != tc.want || h.Get("Access-Control-Allow-Origin") != tc.allowOrigin {
+				t.Fatalf("status %d, Allow-Origin %q; want %d, %q", w.Code, h.Get("Access-Control-Allow-Origin"),
+					tc.want, tc.allowOrigin)
+			}
+			if (tc.origin != "") != (h.Get("Vary") == "Origin") {
+				t.Errorf("Vary = %q, want Origin on requests with one", h.Get("Vary"))
+			}
+			// cookies and HTTP auth are never shared with other origins
+			if h.Get("Access-Control-Allow-Credentials") != "" {
+				t.Errorf("Allow-Credentials = %q, want none", h.Get("Access-Control-Allow-Credentials"))
+			}
+			if tc.allowOrigin != "" && h.Get("Access-Control-Expose-Headers") == "" {
+				t.Error("no Expose-Headers on a response to an allowed origin")
+			}
+			if !tc.preflight {
+				if h.Get("Access-Control-Allow-Methods") != "" || h.Get("Access-Control-Max-Age") != "" {
+					t.Errorf("preflight headers on a %s request: %v", tc.method, h)
+				}
+				return
+			}
+			if h.Get("Access-Control-Allow-Methods") != "GET, POST" ||
+				h.Get("Access-Control-Allow-Headers") != "Content-Type, Idempotency-Key" ||
+				h.Get("Access-Control-Max-Age") != "600" {
+				t.Errorf("preflight headers = %v, want the configured methods, headers and max age", h)
+			}
+		})
+	}
+}
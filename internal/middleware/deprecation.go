@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// DeprecationSource lists the route deprecations announced in the API changelog.
+type DeprecationSource interface {
+	Deprecations(ctx context.Context) ([]repository.APIChange, error)
+}
+
+// Deprecations adds Deprecation (RFC 9745), Sunset (RFC 8594) and Link rel="deprecation"
+// headers to responses of deprecated routes, so integrators notice programmatically.
+//
+// Deprecations are kept in memory and reloaded every refresh interval; a failed reload
+// keeps the previous set.
+type Deprecations struct {
+	source  DeprecationSource
+	refresh time.Duration
+	prefix  string // PATH_PREFIX, left out of the routes the changelog names
+	logger  *zap.Logger
+
+	mu     sync.RWMutex
+	routes map[string]repository.APIChange // "METHOD path" or " path" (every method)
+}
+
+func NewDeprecations(source DeprecationSource, refresh time.Duration, logger *zap.Logger) *Deprecations {
+	return &Deprecations{source: source, refresh: refresh, logger: logger}
+}
+
+// WithPathPrefix makes d match routes served under prefix (PATH_PREFIX) against the
+// unprefixed paths of the changelog.
+func (d *Deprecations) WithPathPrefix(prefix string) *Deprecations {
+	d.prefix = prefix
+	return d
+}
+
+// Run loads the deprecations and reloads them until ctx is cancelled.
+func (d *Deprecations) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.refresh)
+	defer ticker.Stop()
+	for {
+		d.reload(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Deprecations) reload(ctx context.Context) {
+	changes, err := d.source.Deprecations(ctx)
+	if err != nil {
+		d.logger.Warn("failed to reload api deprecations", zap.Error(err))
+		return
+	}
+	routes := make(map[string]repository.APIChange, len(changes))
+	for _, c := range changes {
+		routes[routeKey(c.Method.String, c.Path.String)] = c
+	}
+	d.mu.Lock()
+	d.routes = routes
+	d.mu.Unlock()
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+func (d *Deprecations) lookup(method, path string) (repository.APIChange, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if c, ok := d.routes[routeKey(method, path)]; ok {
+		return c, true
+	}
+	c, ok := d.routes[routeKey("", path)]
+	return c, ok
+}
+
+// Middleware decorates responses of deprecated routes. It must be installed on the
+// router (or a group) so the matched route pattern is known.
+func (d *Deprecations) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if change, ok := d.lookup(c.Request.Method, strings.TrimPrefix(c.FullPath(), d.prefix)); ok {
+			h := c.Writer.Header()
+			h.Set("Deprecation", fmt.Sprintf("@%d", change.AnnouncedAt.Unix()))
+			if change.SunsetAt.Valid {
+				h.Set("Sunset", change.SunsetAt.Time.UTC().Format(http.TimeFormat))
+			}
+			if change.Link.Valid && change.Link.String != "" {
+				h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", change.Link.String))
+			}
+		}
+		c.Next()
+	}
+}
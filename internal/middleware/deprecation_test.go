@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// deprecationList serves changes, or fails with err.
+type deprecationList struct {
+	changes []repository.APIChange
+	err     error
+}
+
+func (l *deprecationList) Deprecations(context.Context) ([]repository.APIChange, error) {
+	return l.changes, l.err
+}
+
+func TestDeprecations_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	announced := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC)
+	source := &deprecationList{changes: []repository.APIChange{
+		{Kind: repository.APIChangeDeprecated, Method: sql.NullString{String: "GET", Valid: true},
+			Path: sql.NullString{String: "/api/manage/:token", Valid: true}, AnnouncedAt: announced,
+			SunsetAt: sql.NullTime{Time: sunset, Valid: true},
+			Link:     sql.NullString{String: "https://weather.example.com/changelog#manage", Valid: true}},
+		{Kind: repository.APIChangeDeprecated, Path: sql.NullString{String: "/api/weather", Valid: true},
+			AnnouncedAt: announced},
+	}}
+	d := NewDeprecations(source, time.Hour, zap.NewNop()).WithPathPrefix("/weather-api")
+	d.reload(context.Background())
+
+	r := gin.New()
+	r.Use(d.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/weather-api/api/manage/:token", ok)
+	r.POST("/weather-api/api/manage/:token", ok)
+	r.GET("/weather-api/api/weather", ok)
+	r.GET("/weather-api/api/subscribe", ok)
+
+	for _, tc := range []struct {
+		method, path                  string
+		deprecation, sunsetAt, linkTo string
+	}{
+		{http.MethodGet, "/weather-api/api/manage/6f1c", "@1788220800", "Mon, 01 Mar 2027 00:00:00 GMT",
+			`<https://weather.example.com/changelog#manage>; rel="deprecation"`},
+		{http.MethodPost, "/weather-api/api/manage/6f1c", "", "", ""},
+		{http.MethodGet, "/weather-api/api/weather", "@1788220800", "", ""},
+		{http.MethodGet, "/weather-api/api/subscribe", "", "", ""},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		h := w.Header()
+		if h.Get("Deprecation") != tc.deprecation || h.Get("Sunset") != tc.sunsetAt || h.Get("Link") != tc.linkTo {
+			t.Errorf("%s %s: Deprecation %q, Sunset %q, Link %q; want %q, %q, %q", tc.method, tc.path,
+				h.Get("Deprecation"), h.Get("Sunset"), h.Get("Link"), tc.deprecation, tc.sunsetAt, tc.linkTo)
+		}
+	}
+}
+
+func TestDeprecations_FailedReloadKeepsTheRoutes(t *testing.T) {
+	source := &deprecationList{changes: []repository.APIChange{
+		{Kind: repository.APIChangeDeprecated, Path: sql.NullString{String: "/api/weather", Valid: true}},
+	}}
+	d := NewDeprecations(source, time.Hour, zap.NewNop())
+	d.reload(context.Background())
+
+	source.changes, source.err = nil, errors.New("connection refused")
+	d.reload(context.Background())
+	if _, ok := d.lookup(http.MethodGet, "/api/weather"); !ok {
+		t.Error("a failed reload dropped the deprecation of /api/weather")
+	}
+}
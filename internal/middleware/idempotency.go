@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+)
+
+// IdempotencyKeyHeader is the request header clients set to make a POST safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	maxIdempotencyKeyLen  = 255
+	maxIdempotentBodySize = 1 << 20
+)
+
+// idempotentResponse is what is kept in Redis per key. Status 0 means the first
+// request is still being processed.
+type idempotentResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency replays stored responses for requests repeating an Idempotency-Key.
+type Idempotency struct {
+	redis  *redis.Client
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+func NewIdempotency(rdb *redis.Client, ttl time.Duration, logger *zap.Logger) *Idempotency {
+	return &Idempotency{redis: rdb, ttl: ttl, logger: logger}
+}
+
+// recordingWriter keeps a copy of the response body so it can be stored for replays.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Middleware makes the named route idempotent for requests carrying an Idempotency-Key.
+// The first request with a key is processed and its response kept for the TTL; retries
+// with the same key and body get that response back without running the handler again.
+// Reusing a key with a different body is rejected, and so are bodies over 1 MiB (413).
+// Keys are scoped to the tenant of the request. Server errors (5xx) are not kept, so
+// they can be retried. Requests without the header and Redis failures pass through.
+func (m *Idempotency) Middleware(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			// 400 Key too long
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		// one byte more than allowed tells an oversize body from one of the maximum size
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "cannot read request body"})
+			return
+		}
+		if len(body) > maxIdempotentBodySize {
+			// 413 Body too large to fingerprint
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body is too large"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		ctx := c.Request.Context()
+		redisKey := "idempotency:" + tenant.OrDefault(ctx) + ":" + name + ":" + key
+		pending, _ := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+		first, err := m.redis.SetNX(ctx, redisKey, pending, m.ttl).Result()
+		if err != nil {
+			m.logger.Warn("idempotency store unavailable, processing request", zap.String("route", name), zap.Error(err))
+			c.Next()
+			return
+		}
+
+		if !first {
+			m.replay(c, redisKey, fingerprint)
+			return
+		}
+
+		rw := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = rw
+		c.Next()
+
+		// the handler has already written the response; only the stored copy is updated below
+		status := rw.Status()
+		if status >= http.StatusInternalServerError {
+			if err := m.redis.Del(ctx, redisKey).Err(); err != nil {
+				m.logger.Warn("failed to release idempotency key", zap.String("route", name), zap.Error(err))
+			}
+			return
+		}
+		stored, _ := json.Marshal(idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: rw.Header().Get("Content-Type"),
+			Body:        rw.body.Bytes(),
+		})
+		if err := m.redis.Set(ctx, redisKey, stored, m.ttl).Err(); err != nil {
+			m.logger.Warn("failed to store idempotent response", zap.String("route", name), zap.Error(err))
+		}
+	}
+}
+
+// replay answers a repeated key with the stored response.
+func (m *Idempotency) replay(c *gin.Context, redisKey, fingerprint string) {
+	raw, err := m.redis.Get(c.Request.Context(), redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// expired or released between SETNX and GET; ask the client to retry
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this Idempotency-Key is being processed"})
+		return
+	}
+	var stored idempotentResponse
+	if err == nil {
+		err = json.Unmarshal(raw, &stored)
+	}
+	if err != nil {
+		m.logger.Warn("cannot read idempotent response", zap.String("key", redisKey), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	switch {
+	case stored.Fingerprint != fingerprint:
+		// 422 Same key, different request
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+	case stored.Status == 0:
+		// 409 First request still in flight
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "request with this Idempotency-Key is being processed"})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+	}
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+)
+
+// kvHook answers SET (NX), GET and DEL from a map in place of Redis. With err set,
+// every command fails.
+type kvHook struct {
+	kv  map[string]string
+	err error
+}
+
+func (h *kvHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *kvHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (h *kvHook) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if h.err != nil {
+			cmd.SetErr(h.err)
+			return h.err
+		}
+		args := cmd.Args()
+		key, _ := args[1].(string)
+		switch cmd.Name() {
+		case "set":
+			nx := false
+			for _, a := range args[3:] {
+				nx = nx || a == "nx"
+			}
+			_, exists := h.kv[key]
+			if nx && exists {
+				cmd.(*redis.BoolCmd).SetVal(false)
+				return nil
+			}
+			h.kv[key] = string(args[2].([]byte))
+			switch c := cmd.(type) {
+			case *redis.BoolCmd:
+				c.SetVal(true)
+			case *redis.StatusCmd:
+				c.SetVal("OK")
+			}
+		case "get":
+			v, ok := h.kv[key]
+			if !ok {
+				cmd.SetErr(redis.Nil)
+				return redis.Nil
+			}
+			cmd.(*redis.StringCmd).SetVal(v)
+		case "del":
+			delete(h.kv, key)
+			cmd.(*redis.IntCmd).SetVal(1)
+		}
+		return nil
+	}
+}
+
+// newIdempotentRouter serves POST /subscribe, answering status, under the idempotency
+// middleware; the tenant comes from the X-Tenant header.
+func newIdempotentRouter(t *testing.T, hook *kvHook, status int, runs *int) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	rdb.AddHook(hook)
+	t.Cleanup(func() { rdb.Close() })
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Tenant"); id != "" {
+			c.Request = c.Request.WithContext(tenant.WithID(c.Request.Context(), id))
+		}
+	})
+	r.POST("/subscribe", NewIdempotency(rdb, time.Hour, zap.NewNop()).Middleware("subscribe"), func(c *gin.Context) {
+		*runs++
+		c.JSON(status, gin.H{"run": *runs})
+	})
+	return r
+}
+
+func TestIdempotency_Middleware(t *testing.T) {
+	type request struct {
+		key, tenant, body string
+	}
+	for _, tc := range []struct {
+		name     string
+		status   int
+		requests []request
+		want     []int // status of each request
+		wantRuns int
+	}{
+		{"no key", http.StatusOK, []request{{"", "", "a"}, {"", "", "a"}}, []int{200, 200}, 2},
+		{"retry replayed", http.StatusOK, []request{{"k1", "", "a"}, {"k1", "", "a"}}, []int{200, 200}, 1},
+		{"key reused with another body", http.StatusOK, []request{{"k1", "", "a"}, {"k1", "", "b"}}, []int{200, 422}, 1},
+		{"server error not kept", http.StatusInternalServerError, []request{{"k1", "", "a"}, {"k1", "", "a"}},
+			[]int{500, 500}, 2},
+		{"same key in two tenants", http.StatusOK, []request{{"k1", "acme", "a"}, {"k1", "globex", "b"}}, []int{200, 200}, 2},
+		{"body too large", http.StatusOK, []request{{"k1", "", strings.Repeat("x", maxIdempotentBodySize+1)}},
+			[]int{413}, 0},
+		{"body of the maximum size", http.StatusOK, []request{{"k1", "", strings.Repeat("x", maxIdempotentBodySize)}},
+			[]int{200}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			runs := 0
+			r := newIdempotentRouter(t, &kvHook{kv: map[string]string{}}, tc.status, &runs)
+			for i, req := range tc.requests {
+				httpReq := httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader(req.body))
+				if req.key != "" {
+					httpReq.Header.Set(IdempotencyKeyHeader, req.key)
+				}
+				if req.tenant != "" {
+					httpReq.Header.Set("X-Tenant", req.tenant)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httpReq)
+				if w.Code != tc.want[i] {
+					t.Errorf("request %d: status = %d, want %d (%s)", i, w.Code, tc.want[i], w.Body)
+				}
+				if i > 0 && tc.wantRuns == 1 && w.Code == http.StatusOK && w.Header().Get("Idempotent-Replayed") != "true" {
+					t.Errorf("request %d: not marked as replayed", i)
+				}
+			}
+			if runs != tc.wantRuns {
+				t.Errorf("handler ran %d times, want %d", runs, tc.wantRuns)
+			}
+		})
+	}
+}
+
+func TestIdempotency_Middleware_RedisDown(t *testing.T) {
+	runs := 0
+	r := newIdempotentRouter(t, &kvHook{err: errors.New("connection refused")}, http.StatusOK, &runs)
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader("a"))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d while Redis is down, want 200", w.Code)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("handler ran %d times while Redis is down, want 2", runs)
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slo"
+)
+
+// ObserveLatency records the handling time of every request into rec.
+// Server errors (5xx) are recorded as bad regardless of their latency.
+func ObserveLatency(rec *slo.LatencyRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		elapsed := time.Since(start)
+		if c.Writer.Status() >= 500 {
+			rec.ObserveFailure()
+			return
+		}
+		rec.Observe(elapsed)
+	}
+}
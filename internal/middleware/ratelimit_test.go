@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// bucketHook answers the token bucket script in place of Redis: every key has
+// tokens requests, then none. With err set, every command fails.
+type bucketHook struct {
+	tokens int
+	taken  map[string]int
+	err    error
+}
+
+func (h *bucketHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *bucketHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (h *bucketHook) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if h.err != nil {
+			cmd.SetErr(h.err)
+			return h.err
+		}
+		key := cmd.Args()[3].(string) // EVALSHA sha numkeys key ...
+		h.taken[key]++
+		c := cmd.(*redis.Cmd)
+		if h.taken[key] > h.tokens {
+			c.SetVal([]any{int64(0), int64(1500)})
+		} else {
+			c.SetVal([]any{int64(1), int64(0)})
+		}
+		return nil
+	}
+}
+
+func newLimitedRouter(t *testing.T, hook *bucketHook, trusted []string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	rdb.AddHook(hook)
+	t.Cleanup(func() { rdb.Close() })
+	r := gin.New()
+	if err := r.SetTrustedProxies(trusted); err != nil {
+		t.Fatal(err)
+	}
+	limiter := NewRateLimiter(rdb, zap.NewNop())
+	r.GET("/weather", limiter.Middleware("weather", RateLimit{PerMinute: 60, Burst: 1}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestRateLimiter_Middleware(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		forwarded  string
+		wantKey    string
+	}{
+		{"peer address", nil, "203.0.113.7:4711", "", "ratelimit:weather:203.0.113.7"},
+		{"forwarded for by anyone is ignored", nil, "203.0.113.7:4711", "198.51.100.9", "ratelimit:weather:203.0.113.7"},
+		{"forwarded for by an untrusted proxy is ignored", []string{"10.0.0.0/8"}, "203.0.113.7:4711", "198.51.100.9", "ratelimit:weather:203.0.113.7"},
+		{"forwarded for by a trusted proxy", []string{"10.0.0.0/8"}, "10.1.2.3:4711", "198.51.100.9", "ratelimit:weather:198.51.100.9"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hook := &bucketHook{tokens: 1, taken: map[string]int{}}
+			r := newLimitedRouter(t, hook, tc.trusted)
+
+			var codes []int
+			for range 2 {
+				req := httptest.NewRequest(http.MethodGet, "/weather", nil)
+				req.RemoteAddr = tc.remoteAddr
+				if tc.forwarded != "" {
+					req.Header.Set("X-Forwarded-For", tc.forwarded)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				codes = append(codes, w.Code)
+				if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "2" {
+					t.Errorf("Retry-After = %q, want 2", w.Header().Get("Retry-After"))
+				}
+			}
+			if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+				t.Errorf("status codes = %v, want 200 then 429", codes)
+			}
+			if hook.taken[tc.wantKey] != 2 || len(hook.taken) != 1 {
+				t.Errorf("buckets taken from = %v, want only %s", hook.taken, tc.wantKey)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_Middleware_RedisDown(t *testing.T) {
+	r := newLimitedRouter(t, &bucketHook{err: errors.New("connection refused")}, nil)
+	for range 3 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/weather", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d while Redis is down, want 200", w.Code)
+		}
+	}
+}
+
+func TestRateLimiter_Middleware_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/weather", NewRateLimiter(nil, zap.NewNop()).Middleware("weather", RateLimit{}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/weather", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d with the limit disabled, want 200", w.Code)
+	}
+}
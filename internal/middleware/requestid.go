@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tracing"
+)
+
+// RequestID attaches a trace to every request, reusing the client's X-Request-ID and
+// traceparent when they are well-formed, and echoes the request ID in the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := tracing.FromHeaders(c.Request.Header)
+		c.Request = c.Request.WithContext(tracing.NewContext(c.Request.Context(), t))
+		c.Header(tracing.HeaderRequestID, t.RequestID)
+		c.Next()
+	}
+}
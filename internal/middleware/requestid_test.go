@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tracing"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name, header string
+		keep         bool
+	}{
+		{"client request ID", "req-42", true},
+		{"no request ID", "", false},
+		{"malformed request ID", "req 42", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen string
+			r := gin.New()
+			r.Use(RequestID())
+			r.GET("/api/weather", func(c *gin.Context) {
+				seen = tracing.RequestID(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+			req.Header.Set(tracing.HeaderRequestID, tc.header)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			echoed := w.Header().Get(tracing.HeaderRequestID)
+			if seen == "" || echoed != seen {
+				t.Fatalf("handler saw request ID %q, response echoes %q", seen, echoed)
+			}
+			if (seen == tc.header) != tc.keep {
+				t.Errorf("request ID = %q, want the client's kept %t", seen, tc.keep)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/overload"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+)
+
+// LoadShedder turns away low-priority requests while the overload detector reports
+// the process overloaded. Routes without its middleware (subscribe, confirm, ...) are
+// never shed. A nil detector sheds nothing.
+type LoadShedder struct {
+	detector   *overload.Detector
+	retryAfter time.Duration
+}
+
+func NewLoadShedder(detector *overload.Detector, retryAfter time.Duration) *LoadShedder {
+	return &LoadShedder{detector: detector, retryAfter: retryAfter}
+}
+
+// Reject answers 503 with Retry-After while overloaded.
+func (s *LoadShedder) Reject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if overloaded, _ := s.detector.Overloaded(); overloaded {
+			s.setRetryAfter(c)
+			// 503 Shed under overload
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server overloaded, try again later"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CacheOnly keeps serving weather from the cache while overloaded, but stops requests
+// from going through to the providers: the fetch fails with weather.ErrNotCached, which
+// the handler answers with 503. Retry-After is set up front; clients ignore it on
+// successful responses.
+func (s *LoadShedder) CacheOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if overloaded, _ := s.detector.Overloaded(); overloaded {
+			s.setRetryAfter(c)
+			c.Request = c.Request.WithContext(weather.WithCacheOnly(c.Request.Context()))
+		}
+		c.Next()
+	}
+}
+
+func (s *LoadShedder) setRetryAfter(c *gin.Context) {
+	seconds := int((s.retryAfter + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+}
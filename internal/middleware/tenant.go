@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+)
+
+// Tenant scopes every request to its tenant, resolved by tenants from its API key or
+// Host (see tenant.Registry.Resolve); the repository queries of the request only see
+// the data of that tenant.
+func Tenant(tenants *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(tenant.WithID(c.Request.Context(), tenants.Resolve(c.Request)))
+		c.Next()
+	}
+}
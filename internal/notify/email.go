@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// Email is the Notifier of email subscriptions. Its messages are email.EmailMessage,
+// delivered in one batch (one SMTP session).
+type Email struct {
+	sender email.EmailSender
+}
+
+func NewEmail(sender email.EmailSender) *Email {
+	return &Email{sender: sender}
+}
+
+func (e *Email) Channel() repository.Channel {
+	return repository.ChannelEmail
+}
+
+// Queues reports whether the sender only queues the emails, see Queuing.
+func (e *Email) Queues() bool {
+	q, ok := e.sender.(Queuing)
+	return ok && q.Queues()
+}
+
+// Render renders the weather update email of u.
+func (e *Email) Render(u Update) (Message, error) {
+	sub := u.Subscription
+	body, err := email.Render(email.TemplateWeatherUpdate, email.WeatherUpdateData{
+		Locale:         sub.Locale,
+		Subscriber:     u.Subscriber,
+		Summary:        u.Summary,
+		Cities:         u.Cities,
+		Timezone:       u.Timezone,
+		Units:          string(sub.Units),
+		ManageURL:      u.ManageURL,
+		UnsubscribeURL: u.UnsubscribeURL,
+		OpenPixelURL:   u.OpenPixelURL,
+		Attributions:   u.Attributions,
+		Features:       u.Features,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return email.EmailMessage{
+		To:             []string{sub.Email},
+		Subject:        i18n.T(sub.Locale, "update.subject", email.DescribeCities(u.Cities, sub.Locale)),
+		Body:           body,
+		UnsubscribeURL: u.UnsubscribeURL,
+		Inline:         u.Inline,
+	}, nil
+}
+
+// Deliver sends messages in one batch; a message refused by the server fails alone.
+func (e *Email) Deliver(_ context.Context, messages []Message) []error {
+	batch := make([]email.EmailMessage, len(messages))
+	for i, m := range messages {
+		batch[i] = m.(email.EmailMessage)
+	}
+	err := e.sender.SendBatch(batch)
+	errs := make([]error, len(messages))
+	for i := range errs {
+		errs[i] = email.MessageErr(err, i)
+	}
+	return errs
+}
@@ -0,0 +1,57 @@
+// Package notify renders and delivers weather updates through the channel of each
+// subscription: email, Slack or SMS. Every channel is a Notifier; the scheduler picks
+// the one of repository.Subscription.Channel and leaves the rest to it.
+package notify
+
+import (
+	"context"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// Update is the weather update of one subscription, before it is rendered for its
+// channel. Channels use what they can show and ignore the rest.
+type Update struct {
+	Subscription   repository.Subscription
+	Subscriber     email.Subscriber
+	Cities         []email.CityWeather
+	Summary        string       // summary at the top of daily emails; omitted when empty
+	Features       features.Set // experimental email content enabled for the subscription
+	Inline         []email.InlinePart
+	Timezone       string // of the subscription, never empty
+	ManageURL      string
+	UnsubscribeURL string
+	OpenPixelURL   string   // open tracking of emails
+	Attributions   []string // provider attribution lines
+	Intro          string   // opening line of text channels, e.g. a welcome; omitted when empty
+}
+
+// Message is an update rendered by a Notifier, to be delivered by the same Notifier.
+type Message any
+
+// Notifier renders and delivers the updates of one channel.
+type Notifier interface {
+	// Channel is the channel of the subscriptions the Notifier serves.
+	Channel() repository.Channel
+	// Render builds the message of u.
+	Render(u Update) (Message, error)
+	// Deliver sends messages and returns the error of each, in order; nil when sent.
+	Deliver(ctx context.Context, messages []Message) []error
+}
+
+// Queuing is implemented by Notifiers, and email senders, that only queue messages:
+// Deliver returning no error means queued, and the queue worker records in the
+// delivery log whether they were sent.
+type Queuing interface {
+	Queues() bool
+}
+
+// ChannelOf returns the channel of sub; subscriptions selected without it get emails.
+func ChannelOf(sub repository.Subscription) repository.Channel {
+	if sub.Channel == "" {
+		return repository.ChannelEmail
+	}
+	return sub.Channel
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+type batchSender struct {
+	sent []email.EmailMessage
+	err  error
+}
+
+func (s *batchSender) SendBatch(messages []email.EmailMessage) error {
+	s.sent = append(s.sent, messages...)
+	return s.err
+}
+
+type textSender struct {
+	sent map[string]string
+}
+
+func (s *textSender) Send(_ context.Context, to, body string) error {
+	s.sent[to] = body
+	return nil
+}
+
+func testUpdate(channel repository.Channel) Update {
+	return Update{
+		Subscription: repository.Subscription{ID: 1, Email: "a@example.com", City: "Kyiv", Locale: "en",
+			Units: repository.UnitsMetric, Channel: channel, Phone: "+380501234567"},
+		Cities:    []email.CityWeather{{City: "Kyiv", Weather: types.Weather{Temp: 20, Humidity: 50, Description: "Sunny"}}},
+		Timezone:  "UTC",
+		ManageURL: "https://example.com/api/manage/x",
+	}
+}
+
+func TestChannelOf(t *testing.T) {
+	if got := ChannelOf(repository.Subscription{}); got != repository.ChannelEmail {
+		t.Errorf("ChannelOf(no channel) = %q, want email", got)
+	}
+	if got := ChannelOf(repository.Subscription{Channel: repository.ChannelSMS}); got != repository.ChannelSMS {
+		t.Errorf("ChannelOf(sms) = %q", got)
+	}
+}
+
+func TestEmail_FailsMessagesOnTheirOwn(t *testing.T) {
+	sender := &batchSender{}
+	n := NewEmail(sender)
+	msg, err := n.Render(testUpdate(repository.ChannelEmail))
+	if err != nil {
+		t.Fatalf("Render() unexpected error: %v", err)
+	}
+	if m := msg.(email.EmailMessage); m.To[0] != "a@example.com" || !strings.Contains(m.Subject, "Kyiv") {
+		t.Errorf("Render() = %+v", m)
+	}
+
+	refused := errors.New("550 mailbox unavailable")
+	sender.err = &email.BatchError{Total: 2, Failed: map[int]error{1: refused}}
+	errs := n.Deliver(context.Background(), []Message{msg, msg})
+	if len(sender.sent) != 2 || errs[0] != nil || !errors.Is(errs[1], refused) {
+		t.Errorf("Deliver() = %v, want the second message failed alone", errs)
+	}
+}
+
+func TestSMS_OpensWithIntro(t *testing.T) {
+	texts := &textSender{sent: map[string]string{}}
+	n := NewSMS(texts)
+	u := testUpdate(repository.ChannelSMS)
+	u.Intro = "Hello!"
+	msg, err := n.Render(u)
+	if err != nil {
+		t.Fatalf("Render() unexpected error: %v", err)
+	}
+	if errs := n.Deliver(context.Background(), []Message{msg}); errs[0] != nil {
+		t.Fatalf("Deliver() = %v", errs)
+	}
+	want := "Hello!\nKyiv: 20.00°C, Sunny, humidity 50%.\nManage or stop: https://example.com/api/manage/x"
+	if got := texts.sent["+380501234567"]; got != want {
+		t.Errorf("texted %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slack"
+)
+
+// SlackPoster posts messages to Slack incoming webhooks.
+type SlackPoster interface {
+	Post(ctx context.Context, webhookURL string, msg slack.Message) error
+}
+
+// Slack is the Notifier of Slack subscriptions, posting Block Kit messages to their
+// webhook one request each.
+type Slack struct {
+	poster SlackPoster
+}
+
+func NewSlack(poster SlackPoster) *Slack {
+	return &Slack{poster: poster}
+}
+
+func (s *Slack) Channel() repository.Channel {
+	return repository.ChannelSlack
+}
+
+type slackMessage struct {
+	webhookURL string
+	msg        slack.Message
+}
+
+func (s *Slack) Render(u Update) (Message, error) {
+	sub := u.Subscription
+	return slackMessage{webhookURL: sub.SlackWebhookURL, msg: slack.UpdateMessage(slack.Update{
+		Locale:       sub.Locale,
+		Cities:       u.Cities,
+		Units:        string(sub.Units),
+		ManageURL:    u.ManageURL,
+		Attributions: u.Attributions,
+	})}, nil
+}
+
+func (s *Slack) Deliver(ctx context.Context, messages []Message) []error {
+	errs := make([]error, len(messages))
+	for i, m := range messages {
+		m := m.(slackMessage)
+		errs[i] = s.poster.Post(ctx, m.webhookURL, m.msg)
+	}
+	return errs
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"strings"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/sms"
+)
+
+// SMS is the Notifier of SMS subscriptions, texting the short form of updates to their
+// phone one request each.
+type SMS struct {
+	sender sms.Sender
+}
+
+func NewSMS(sender sms.Sender) *SMS {
+	return &SMS{sender: sender}
+}
+
+func (s *SMS) Channel() repository.Channel {
+	return repository.ChannelSMS
+}
+
+type smsMessage struct {
+	to   string
+	body string
+}
+
+func (s *SMS) Render(u Update) (Message, error) {
+	body := smsText(u.Subscription.Locale, string(u.Subscription.Units), u.Cities, u.ManageURL)
+	if u.Intro != "" {
+		body = u.Intro + "\n" + body
+	}
+	return smsMessage{to: u.Subscription.Phone, body: body}, nil
+}
+
+func (s *SMS) Deliver(ctx context.Context, messages []Message) []error {
+	errs := make([]error, len(messages))
+	for i, m := range messages {
+		m := m.(smsMessage)
+		errs[i] = s.sender.Send(ctx, m.to, m.body)
+	}
+	return errs
+}
+
+// smsText is the short form of a weather update: a line per city, with tomorrow's
+// forecast when known, and the manage link.
+func smsText(locale, units string, cities []email.CityWeather, manageURL string) string {
+	var b strings.Builder
+	for _, c := range cities {
+		w := c.Weather
+		b.WriteString(i18n.T(locale, "sms.city", c.City, email.FormatTemperature(w.Temp, units), w.Description, w.Humidity))
+		if f := c.Tomorrow; f != nil {
+			b.WriteString(" ")
+			b.WriteString(i18n.T(locale, "sms.tomorrow", email.FormatTemperature(f.MinTemp, units),
+				email.FormatTemperature(f.MaxTemp, units), f.ChanceOfRain))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(i18n.T(locale, "sms.manage", manageURL))
+	return b.String()
+}
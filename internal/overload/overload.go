@@ -0,0 +1,127 @@
+// Package overload detects when the API process is overloaded, so low-priority requests
+// can be shed before they slow down the ones that matter (subscribe and confirm).
+//
+// A Detector samples three signals once per interval:
+//   - the number of goroutines, which grows with requests stuck in flight;
+//   - scheduling lag, how late its own timer fires: the Go runtime's equivalent of
+//     event-loop latency, high when the CPU is saturated;
+//   - the average time requests waited for a database connection since the last sample.
+//
+// The process is overloaded while any signal is over its limit. A zero limit disables
+// that signal.
+package overload
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Limits are the thresholds above which the process counts as overloaded.
+type Limits struct {
+	MaxGoroutines   int
+	MaxSchedulerLag time.Duration
+	MaxDBWait       time.Duration
+}
+
+// Enabled reports whether any signal is checked.
+func (l Limits) Enabled() bool {
+	return l.MaxGoroutines > 0 || l.MaxSchedulerLag > 0 || l.MaxDBWait > 0
+}
+
+// DBStatser is the part of *sql.DB the detector reads its connection wait times from.
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+// Detector tracks whether the process is overloaded. A nil *Detector never is.
+type Detector struct {
+	limits   Limits
+	db       DBStatser // nil: database wait is not checked
+	interval time.Duration
+	logger   *zap.Logger
+
+	mu         sync.RWMutex
+	overloaded bool
+	reason     string
+
+	lastWaitCount    int64
+	lastWaitDuration time.Duration
+}
+
+// NewDetector returns a detector sampling every interval, or nil when limits checks nothing.
+func NewDetector(limits Limits, db DBStatser, interval time.Duration, logger *zap.Logger) *Detector {
+	if !limits.Enabled() {
+		return nil
+	}
+	return &Detector{limits: limits, db: db, interval: interval, logger: logger}
+}
+
+// Overloaded reports whether the last sample was over a limit, and which one.
+func (d *Detector) Overloaded() (bool, string) {
+	if d == nil {
+		return false, ""
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.overloaded, d.reason
+}
+
+// Run samples until ctx is cancelled.
+func (d *Detector) Run(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	timer := time.NewTimer(d.interval)
+	defer timer.Stop()
+	due := time.Now().Add(d.interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case fired := <-timer.C:
+			d.sample(runtime.NumGoroutine(), fired.Sub(due))
+			due = time.Now().Add(d.interval)
+			timer.Reset(d.interval)
+		}
+	}
+}
+
+// sample records one observation of the goroutine count and scheduling lag, reading
+// the database wait since the previous sample.
+func (d *Detector) sample(goroutines int, lag time.Duration) {
+	var dbWait time.Duration
+	if d.db != nil {
+		stats := d.db.Stats()
+		if waits := stats.WaitCount - d.lastWaitCount; waits > 0 {
+			dbWait = (stats.WaitDuration - d.lastWaitDuration) / time.Duration(waits)
+		}
+		d.lastWaitCount, d.lastWaitDuration = stats.WaitCount, stats.WaitDuration
+	}
+
+	var reason string
+	switch {
+	case d.limits.MaxGoroutines > 0 && goroutines > d.limits.MaxGoroutines:
+		reason = fmt.Sprintf("%d goroutines", goroutines)
+	case d.limits.MaxSchedulerLag > 0 && lag > d.limits.MaxSchedulerLag:
+		reason = fmt.Sprintf("scheduler lag %s", lag)
+	case d.limits.MaxDBWait > 0 && dbWait > d.limits.MaxDBWait:
+		reason = fmt.Sprintf("database connection wait %s", dbWait)
+	}
+
+	d.mu.Lock()
+	changed := d.overloaded != (reason != "")
+	d.overloaded, d.reason = reason != "", reason
+	d.mu.Unlock()
+
+	if changed && reason != "" {
+		d.logger.Warn("overloaded, shedding low-priority requests", zap.String("reason", reason))
+	} else if changed {
+		d.logger.Info("load back to normal, no longer shedding requests")
+	}
+}
@@ -0,0 +1,59 @@
+package overload
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fakeDB struct {
+	stats sql.DBStats
+}
+
+func (f *fakeDB) Stats() sql.DBStats { return f.stats }
+
+func TestDetector_Sample(t *testing.T) {
+	db := &fakeDB{}
+	d := NewDetector(Limits{MaxGoroutines: 100, MaxSchedulerLag: 50 * time.Millisecond, MaxDBWait: 100 * time.Millisecond},
+		db, time.Second, zap.NewNop())
+
+	d.sample(10, time.Millisecond)
+	if over, _ := d.Overloaded(); over {
+		t.Fatal("overloaded under every limit")
+	}
+
+	d.sample(500, 0)
+	if over, reason := d.Overloaded(); !over || reason != "500 goroutines" {
+		t.Errorf("Overloaded() = %v, %q; want the goroutine count", over, reason)
+	}
+
+	d.sample(10, 200*time.Millisecond)
+	if over, _ := d.Overloaded(); !over {
+		t.Error("scheduler lag over the limit not detected")
+	}
+
+	// 4 waits totalling 1s since the last sample: 250ms each
+	db.stats = sql.DBStats{WaitCount: 4, WaitDuration: time.Second}
+	d.sample(10, 0)
+	if over, reason := d.Overloaded(); !over || reason != "database connection wait 250ms" {
+		t.Errorf("Overloaded() = %v, %q; want the database wait", over, reason)
+	}
+
+	// no new waits: back to normal
+	d.sample(10, 0)
+	if over, _ := d.Overloaded(); over {
+		t.Error("still overloaded after the signals recovered")
+	}
+}
+
+func TestNewDetector_DisabledIsNil(t *testing.T) {
+	d := NewDetector(Limits{}, nil, time.Second, zap.NewNop())
+	if d != nil {
+		t.Fatal("NewDetector() with no limits must return nil")
+	}
+	if over, _ := d.Overloaded(); over {
+		t.Error("a nil detector must never be overloaded")
+	}
+}
@@ -0,0 +1,185 @@
+// Package pii encrypts subscriber email addresses at rest.
+//
+// Addresses are encrypted with AES-256-GCM under a versioned key ring, so keys can be
+// rotated: the first configured key encrypts, every key decrypts. Because the
+// ciphertext is randomized, lookups and uniqueness use a blind index instead, a keyed
+// HMAC-SHA256 of the normalized address.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+// encryptedPrefix marks encrypted values: "enc:v1:<key id>:<base64(nonce|ciphertext)>".
+// Values without it are legacy plaintext, stored before encryption was enabled.
+const encryptedPrefix = "enc:v1:"
+
+// ErrUnknownKey is returned when a value was encrypted under a key that is no longer configured.
+var ErrUnknownKey = errors.New("pii: value encrypted with an unknown key")
+
+// Key is one entry of the key ring.
+type Key struct {
+	ID     string
+	Secret []byte // 32 bytes, AES-256
+}
+
+// Cipher encrypts, decrypts and blind-indexes email addresses.
+//
+// A nil *Cipher is valid and means encryption is disabled: values are stored as they
+// are and the blind index is an unkeyed SHA-256.
+type Cipher struct {
+	activeID string
+	aeads    map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// NewCipher builds the cipher configured by PII_ENCRYPTION_KEYS and PII_BLIND_INDEX_KEY,
+// or returns nil when no encryption key is configured.
+func NewCipher(cfg *config.Config) (*Cipher, error) {
+	if len(cfg.PIIEncryptionKeys) == 0 {
+		return nil, nil
+	}
+	keys, err := ParseKeys(cfg.PIIEncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PIIBlindIndexKey == "" {
+		return nil, errors.New("PII_BLIND_INDEX_KEY must be set when PII_ENCRYPTION_KEYS is set")
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(cfg.PIIBlindIndexKey)
+	if err != nil || len(indexKey) < 32 {
+		return nil, errors.New("PII_BLIND_INDEX_KEY must be at least 32 base64-encoded bytes")
+	}
+	return New(keys, indexKey)
+}
+
+// ParseKeys parses "id:base64secret" entries; the first one is the active key.
+func ParseKeys(entries []string) ([]Key, error) {
+	keys := make([]Key, 0, len(entries))
+	for _, e := range entries {
+		id, secret, ok := strings.Cut(e, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("pii: key entry must be id:base64secret")
+		}
+		raw, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, fmt.Errorf("pii: key %q is not valid base64: %w", id, err)
+		}
+		keys = append(keys, Key{ID: id, Secret: raw})
+	}
+	return keys, nil
+}
+
+// New builds a cipher from a key ring (the first key encrypts) and a blind index key.
+func New(keys []Key, indexKey []byte) (*Cipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("pii: at least one key is required")
+	}
+	c := &Cipher{activeID: keys[0].ID, aeads: make(map[string]cipher.AEAD, len(keys)), indexKey: indexKey}
+	for _, k := range keys {
+		if strings.Contains(k.ID, ":") {
+			return nil, fmt.Errorf("pii: key id %q must not contain ':'", k.ID)
+		}
+		if len(k.Secret) != 32 {
+			return nil, fmt.Errorf("pii: key %q must be 32 bytes, got %d", k.ID, len(k.Secret))
+		}
+		if _, dup := c.aeads[k.ID]; dup {
+			return nil, fmt.Errorf("pii: duplicate key id %q", k.ID)
+		}
+		block, err := aes.NewCipher(k.Secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads[k.ID] = aead
+	}
+	return c, nil
+}
+
+// Enabled reports whether values are encrypted.
+func (c *Cipher) Enabled() bool {
+	return c != nil
+}
+
+// Encrypt encrypts plaintext under the active key.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	aead := c.aeads[c.activeID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("pii: nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + c.activeID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a value produced by Encrypt. Legacy plaintext values
+// are returned unchanged.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	id, data, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("pii: malformed encrypted value")
+	}
+	if c == nil {
+		return "", fmt.Errorf("%w %q (encryption is disabled)", ErrUnknownKey, id)
+	}
+	aead, ok := c.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("pii: malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("pii: decrypt with key %q: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// NeedsRekey reports whether value should be rewritten: plaintext while encryption is
+// enabled, or encrypted under a key other than the active one.
+func (c *Cipher) NeedsRekey(value string) bool {
+	rest, encrypted := strings.CutPrefix(value, encryptedPrefix)
+	if c == nil {
+		return false
+	}
+	if !encrypted {
+		return true
+	}
+	return !strings.HasPrefix(rest, c.activeID+":")
+}
+
+// BlindIndex returns the deterministic lookup hash of an email address. Addresses are
+// trimmed and lower-cased first, so lookups are case-insensitive.
+func (c *Cipher) BlindIndex(email string) string {
+	normalized := []byte(strings.ToLower(strings.TrimSpace(email)))
+	if c == nil {
+		sum := sha256.Sum256(normalized)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write(normalized)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,109 @@
+package pii
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, ids ...string) *Cipher {
+	t.Helper()
+	keys := make([]Key, 0, len(ids))
+	for i, id := range ids {
+		keys = append(keys, Key{ID: id, Secret: bytes.Repeat([]byte{byte(i + 1)}, 32)})
+	}
+	c, err := New(keys, bytes.Repeat([]byte{0xAA}, 32))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return c
+}
+
+func TestCipher_RoundTrip(t *testing.T) {
+	c := newTestCipher(t, "k1")
+
+	enc, err := c.Encrypt("foo@bar.com")
+	if err != nil {
+		t.Fatalf("Encrypt() error: %v", err)
+	}
+	if !strings.HasPrefix(enc, "enc:v1:k1:") || strings.Contains(enc, "foo@bar.com") {
+		t.Errorf("Encrypt() = %q, want an enc:v1:k1: value without the plaintext", enc)
+	}
+	if again, _ := c.Encrypt("foo@bar.com"); again == enc {
+		t.Error("Encrypt() must be randomized")
+	}
+
+	got, err := c.Decrypt(enc)
+	if err != nil || got != "foo@bar.com" {
+		t.Errorf("Decrypt() = %q, %v; want foo@bar.com", got, err)
+	}
+}
+
+func TestCipher_LegacyPlaintext(t *testing.T) {
+	c := newTestCipher(t, "k1")
+
+	got, err := c.Decrypt("foo@bar.com")
+	if err != nil || got != "foo@bar.com" {
+		t.Errorf("Decrypt(plaintext) = %q, %v; want it unchanged", got, err)
+	}
+	if !c.NeedsRekey("foo@bar.com") {
+		t.Error("NeedsRekey(plaintext) = false, want true")
+	}
+
+	var disabled *Cipher
+	if disabled.NeedsRekey("foo@bar.com") {
+		t.Error("nil cipher must never ask for a rekey")
+	}
+}
+
+func TestCipher_Rotation(t *testing.T) {
+	k1 := Key{ID: "k1", Secret: bytes.Repeat([]byte{1}, 32)}
+	k2 := Key{ID: "k2", Secret: bytes.Repeat([]byte{2}, 32)}
+	indexKey := bytes.Repeat([]byte{0xAA}, 32)
+
+	old, _ := New([]Key{k1}, indexKey)
+	enc, _ := old.Encrypt("foo@bar.com")
+	rotated, _ := New([]Key{k2, k1}, indexKey)
+
+	if got, err := rotated.Decrypt(enc); err != nil || got != "foo@bar.com" {
+		t.Errorf("Decrypt() with old key = %q, %v", got, err)
+	}
+	if !rotated.NeedsRekey(enc) {
+		t.Error("NeedsRekey() = false for a value under a retired key")
+	}
+	reenc, _ := rotated.Encrypt("foo@bar.com")
+	if rotated.NeedsRekey(reenc) {
+		t.Error("NeedsRekey() = true for a value under the active key")
+	}
+
+	dropped, _ := New([]Key{k2}, indexKey)
+	if _, err := dropped.Decrypt(enc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt() after removing the key: err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestCipher_BlindIndex(t *testing.T) {
+	c := newTestCipher(t, "k1")
+
+	if c.BlindIndex("Foo@Bar.com ") != c.BlindIndex("foo@bar.com") {
+		t.Error("BlindIndex() must ignore case and surrounding spaces")
+	}
+	if c.BlindIndex("foo@bar.com") == c.BlindIndex("baz@bar.com") {
+		t.Error("BlindIndex() collided for different addresses")
+	}
+	var disabled *Cipher
+	if disabled.BlindIndex("foo@bar.com") == c.BlindIndex("foo@bar.com") {
+		t.Error("keyed and unkeyed blind indexes must differ")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys([]string{"k1:" + strings.Repeat("A", 43) + "="})
+	if err != nil || len(keys) != 1 || keys[0].ID != "k1" || len(keys[0].Secret) != 32 {
+		t.Errorf("ParseKeys() = %+v, %v", keys, err)
+	}
+	if _, err := ParseKeys([]string{"no-separator"}); err == nil {
+		t.Error("ParseKeys() accepted an entry without an id")
+	}
+}
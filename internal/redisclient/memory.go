@@ -0,0 +1,201 @@
+package redisclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+var (
+	oomErrors = metrics.NewCounter("weather_redis_oom_errors_total",
+		"Redis commands rejected because Redis reached maxmemory.")
+	evictedKeys = metrics.NewCounter("weather_redis_evicted_keys_total",
+		"Keys Redis evicted to stay under maxmemory, observed since the process started.")
+	memoryPressure = metrics.NewGauge("weather_redis_memory_pressure",
+		"1 while Redis is under memory pressure (OOM errors, evictions or near maxmemory), else 0.")
+)
+
+const (
+	// pressureHold is how long pressure lasts after its last sign.
+	pressureHold = 2 * time.Minute
+	// watchInterval is how often INFO is polled for evictions and memory use.
+	watchInterval = 30 * time.Second
+	// nearMaxMemory is the share of maxmemory in use counted as pressure.
+	nearMaxMemory = 0.95
+)
+
+// IsOOM reports whether err is Redis refusing a write because it reached maxmemory.
+func IsOOM(err error) bool {
+	var rerr redis.Error
+	return errors.As(err, &rerr) && strings.HasPrefix(rerr.Error(), "OOM ")
+}
+
+// MemoryMonitor tells when the Redis server is under memory pressure, so caches can
+// degrade (stop writing, keep entries in process memory, drop debug data) instead of
+// failing and logging every write. It watches the OOM errors of every command of the
+// client and polls INFO for evictions. A nil *MemoryMonitor never reports pressure.
+type MemoryMonitor struct {
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu          sync.Mutex
+	until       time.Time // pressure lasts until then
+	relief      []func(ctx context.Context)
+	lastEvicted int64 // evicted_keys at the last poll, -1 before the first one
+}
+
+// MonitorMemory attaches a MemoryMonitor to rdb and polls rdb until ctx is done.
+func MonitorMemory(ctx context.Context, rdb *redis.Client, logger *zap.Logger) *MemoryMonitor {
+	m := newMemoryMonitor(logger)
+	rdb.AddHook(m)
+	go m.watch(ctx, rdb)
+	return m
+}
+
+func newMemoryMonitor(logger *zap.Logger) *MemoryMonitor {
+	return &MemoryMonitor{logger: logger, now: time.Now, lastEvicted: -1}
+}
+
+// UnderPressure reports whether Redis showed signs of memory pressure recently.
+func (m *MemoryMonitor) UnderPressure() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now().Before(m.until)
+}
+
+// OnPressure registers fn to free memory whenever a pressure episode begins. It runs in
+// its own goroutine.
+func (m *MemoryMonitor) OnPressure(fn func(ctx context.Context)) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.relief = append(m.relief, fn)
+	m.mu.Unlock()
+}
+
+// signal records a sign of pressure; the first one of an episode is logged and runs
+// the relief functions, later ones only extend it.
+func (m *MemoryMonitor) signal(reason string) {
+	m.mu.Lock()
+	now := m.now()
+	started := !now.Before(m.until)
+	m.until = now.Add(pressureHold)
+	relief := m.relief
+	m.mu.Unlock()
+
+	memoryPressure.Set(1)
+	if !started {
+		return
+	}
+	m.logger.Warn("redis under memory pressure, degrading caches", zap.String("reason", reason))
+	for _, fn := range relief {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), watchInterval)
+			defer cancel()
+			fn(ctx)
+		}()
+	}
+}
+
+// DialHook implements redis.Hook.
+func (m *MemoryMonitor) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements redis.Hook.
+func (m *MemoryMonitor) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if IsOOM(err) {
+			oomErrors.Inc()
+			m.signal("oom")
+		}
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook.
+func (m *MemoryMonitor) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			if IsOOM(cmd.Err()) {
+				oomErrors.Inc()
+				m.signal("oom")
+			}
+		}
+		return err
+	}
+}
+
+// watch polls INFO for evictions and memory use until ctx is done.
+func (m *MemoryMonitor) watch(ctx context.Context, rdb *redis.Client) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		info, err := rdb.Info(ctx, "memory", "stats").Result()
+		if err != nil {
+			m.logger.Debug("redis INFO failed", zap.Error(err))
+		} else {
+			m.observe(parseInfo(info))
+		}
+		if m.UnderPressure() {
+			memoryPressure.Set(1)
+		} else {
+			memoryPressure.Set(0)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// observe checks the INFO fields of one poll.
+func (m *MemoryMonitor) observe(info map[string]int64) {
+	evicted, ok := info["evicted_keys"]
+	if ok {
+		m.mu.Lock()
+		last := m.lastEvicted
+		m.lastEvicted = evicted
+		m.mu.Unlock()
+		if last >= 0 && evicted > last {
+			evictedKeys.Add(float64(evicted - last))
+			m.signal("eviction")
+		}
+	}
+	if limit := info["maxmemory"]; limit > 0 && float64(info["used_memory"]) >= nearMaxMemory*float64(limit) {
+		m.signal("near maxmemory")
+	}
+}
+
+// parseInfo reads the integer fields of an INFO reply.
+func parseInfo(info string) map[string]int64 {
+	out := map[string]int64{}
+	sc := bufio.NewScanner(strings.NewReader(info))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			out[key] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package redisclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// redisError mimics a server error reply.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+func (redisError) RedisError()     {}
+
+func TestIsOOM(t *testing.T) {
+	oom := redisError("OOM command not allowed when used memory > 'maxmemory'.")
+	if !IsOOM(oom) || !IsOOM(fmt.Errorf("set: %w", oom)) {
+		t.Error("an OOM reply was not recognised")
+	}
+	for _, err := range []error{nil, redis.Nil, redisError("ERR wrong type"), errors.New("OOM in a network error")} {
+		if IsOOM(err) {
+			t.Errorf("IsOOM(%v) = true", err)
+		}
+	}
+}
+
+func TestMemoryMonitor_Pressure(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	m := newMemoryMonitor(zap.NewNop())
+	m.now = func() time.Time { return now }
+	relieved := make(chan struct{}, 2)
+	m.OnPressure(func(context.Context) { relieved <- struct{}{} })
+
+	// the first poll only sets the baseline of evicted keys
+	m.observe(parseInfo("# Stats\r\nevicted_keys:40\r\n# Memory\r\nused_memory:100\r\nmaxmemory:1000\r\n"))
+	if m.UnderPressure() {
+		t.Fatal("pressure without any sign of it")
+	}
+
+	m.observe(parseInfo("evicted_keys:45\r\nused_memory:100\r\nmaxmemory:1000\r\n"))
+	if !m.UnderPressure() {
+		t.Fatal("evictions did not signal pressure")
+	}
+	m.signal("oom") // same episode: relief runs once
+	select {
+	case <-relieved:
+	case <-time.After(time.Second):
+		t.Fatal("relief did not run")
+	}
+
+	now = now.Add(pressureHold)
+	if m.UnderPressure() {
+		t.Error("pressure outlived its hold time")
+	}
+	m.observe(parseInfo("evicted_keys:45\r\nused_memory:960\r\nmaxmemory:1000\r\n"))
+	if !m.UnderPressure() {
+		t.Error("memory use near maxmemory did not signal pressure")
+	}
+
+	var none *MemoryMonitor
+	if none.UnderPressure() {
+		t.Error("a nil monitor reported pressure")
+	}
+}
@@ -0,0 +1,47 @@
+package redisclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+// Open creates a Redis client from config and verifies the connection with a PING.
+// The client is shared by the weather cache and any other Redis-backed component.
+func Open(cfg *config.Config) (*redis.Client, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       0,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("redis ping failed: %w", err)
+	}
+	return rdb, nil
+}
+
+// OpenMailQueue creates the client of the Redis holding the email queue (see package
+// mailqueue) and verifies that it does not evict keys, which would lose queued emails.
+// A Redis that does not tell its eviction policy (CONFIG is disabled on some managed
+// ones) is trusted to be set up right.
+func OpenMailQueue(cfg *config.Config) (*redis.Client, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.EmailQueueRedisAddr,
+		Password: cfg.EmailQueueRedisPassword,
+	})
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("mail queue redis ping failed: %w", err)
+	}
+	policy, err := rdb.ConfigGet(ctx, "maxmemory-policy").Result()
+	if err == nil && policy["maxmemory-policy"] != "" && policy["maxmemory-policy"] != "noeviction" {
+		rdb.Close()
+		return nil, fmt.Errorf("mail queue redis evicts keys (maxmemory-policy %s), want noeviction",
+			policy["maxmemory-policy"])
+	}
+	return rdb, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// AlertConditions are what an alert subscription watches; it trips when any of them
+// holds in one of its cities. Nil thresholds are not watched.
+type AlertConditions struct {
+	TempAbove    *float64 `db:"temp_above"    json:"temp_above,omitempty"` // °C
+	TempBelow    *float64 `db:"temp_below"    json:"temp_below,omitempty"` // °C
+	WindAbove    *float64 `db:"wind_above"    json:"wind_above,omitempty"` // km/h
+	RainExpected bool     `db:"rain_expected" json:"rain_expected,omitempty"`
+}
+
+// Empty reports whether no condition is set.
+func (c AlertConditions) Empty() bool {
+	return c.TempAbove == nil && c.TempBelow == nil && c.WindAbove == nil && !c.RainExpected
+}
+
+// AlertSubscription is an alert subscription due for evaluation, with its conditions.
+type AlertSubscription struct {
+	Subscription
+	AlertConditions
+	Tripped bool `db:"tripped"` // alerted already; not again until the conditions clear
+}
+
+// AlertRepository stores the conditions of alert subscriptions.
+type AlertRepository interface {
+	// SetConditions sets the conditions of subscription id, clearing its tripped state.
+	SetConditions(ctx context.Context, id int, c AlertConditions) error
+	// AlertBatch returns the active alert subscriptions evaluated at minute of every hour.
+	AlertBatch(ctx context.Context, minute int) ([]AlertSubscription, error)
+	// SetTripped records whether the conditions of the subscriptions ids hold.
+	SetTripped(ctx context.Context, ids []int, tripped bool) error
+}
+
+type pgAlertRepo struct {
+	db     *sqlx.DB
+	pii    *pii.Cipher
+	tokens *linktoken.Deriver
+	logger *zap.Logger
+}
+
+// NewAlertRepository reads emails encrypted with cipher (nil reads them as plaintext)
+// and derives the link tokens of subscriptions with tokens.
+func NewAlertRepository(db *sqlx.DB, cipher *pii.Cipher, tokens *linktoken.Deriver, logger *zap.Logger,
+) AlertRepository {
+	return &pgAlertRepo{db: db, pii: cipher, tokens: tokens, logger: logger}
+}
+
+func (r *pgAlertRepo) SetConditions(ctx context.Context, id int, c AlertConditions) error {
+	const q = `
+        INSERT INTO subscription_alerts (subscription_id, temp_above, temp_below, wind_above, rain_expected)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (subscription_id) DO UPDATE
+            SET temp_above    = EXCLUDED.temp_above,
+                temp_below    = EXCLUDED.temp_below,
+                wind_above    = EXCLUDED.wind_above,
+                rain_expected = EXCLUDED.rain_expected,
+                tripped       = FALSE;
+    `
+	if _, err := withOutboxEvent(ctx, r.db, r.logger, func(ext outboxDB) (int, error) {
+		_, err := ext.ExecContext(ctx, q, id, c.TempAbove, c.TempBelow, c.WindAbove, c.RainExpected)
+		return id, err
+	}); err != nil {
+		r.logger.Error("failed to set alert conditions", zap.Int("id", id), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *pgAlertRepo) AlertBatch(ctx context.Context, minute int) ([]AlertSubscription, error) {
+	const q = `
+        SELECT ` + batchColumns + `, temp_above, temp_below, wind_above, rain_expected, tripped
+        FROM subscriptions
+        JOIN subscription_alerts ON subscription_alerts.subscription_id = subscriptions.id
+        WHERE ` + activeCondition + `
+          AND frequency        = 'alert'
+          AND scheduled_minute = $1;
+    `
+	var rows []AlertSubscription
+	if err := r.db.SelectContext(ctx, &rows, q, minute); err != nil {
+		r.logger.Error("failed to fetch alert batch", zap.Int("minute", minute), zap.Error(err))
+		return nil, err
+	}
+	out := rows[:0]
+	for _, a := range rows {
+		if err := decryptSubscription(r.pii, r.tokens, &a.Subscription); err != nil {
+			r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+			continue
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func (r *pgAlertRepo) SetTripped(ctx context.Context, ids []int, tripped bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]int64, len(ids))
+	for i, id := range ids {
+		args[i] = int64(id)
+	}
+	const q = `UPDATE subscription_alerts SET tripped = $2 WHERE subscription_id = ANY($1);`
+	if _, err := r.db.ExecContext(ctx, q, args, tripped); err != nil {
+		r.logger.Error("failed to update tripped alerts", zap.Int("subscriptions", len(ids)), zap.Error(err))
+		return err
+	}
+	return nil
+}
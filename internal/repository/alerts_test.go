@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestAlertRepository_SetConditions(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewAlertRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	above := 30.0
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO subscription_alerts (subscription_id, temp_above, temp_below, wind_above, rain_expected)")).
+		WithArgs(5, &above, nil, nil, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.SetConditions(context.Background(), 5, AlertConditions{TempAbove: &above, RainExpected: true}); err != nil {
+		t.Fatalf("SetConditions() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestAlertRepository_AlertBatch(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewAlertRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectQuery(`JOIN subscription_alerts ON .* frequency = 'alert' AND scheduled_minute = \$1`).
+		WithArgs(15).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city", "frequency", "confirmed", "temp_above", "temp_below",
+			"wind_above", "rain_expected", "tripped"}).
+			AddRow(5, "a@b.com", "Kyiv", "alert", true, nil, -5.0, nil, false, true))
+
+	got, err := repo.AlertBatch(context.Background(), 15)
+	if err != nil {
+		t.Fatalf("AlertBatch() unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 5 || got[0].Frequency != FrequencyAlert || got[0].TempAbove != nil ||
+		got[0].TempBelow == nil || *got[0].TempBelow != -5 || !got[0].Tripped {
+		t.Errorf("AlertBatch() = %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestAlertRepository_SetTripped(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewAlertRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE subscription_alerts SET tripped = $2 WHERE subscription_id = ANY($1);")).
+		WithArgs([]int64{5, 6}, true).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := repo.SetTripped(context.Background(), []int{5, 6}, true); err != nil {
+		t.Fatalf("SetTripped() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// API change kinds stored in api_changes.kind.
+const (
+	APIChangeAdded      = "added"
+	APIChangeChanged    = "changed"
+	APIChangeDeprecated = "deprecated"
+	APIChangeRemoved    = "removed"
+)
+
+// APIChange is one entry of the public API changelog.
+type APIChange struct {
+	ID          int            `db:"id"`
+	Kind        string         `db:"kind"`
+	Method      sql.NullString `db:"method"` // NULL: every method of Path
+	Path        sql.NullString `db:"path"`   // gin route pattern, e.g. /api/manage/:token
+	Title       string         `db:"title"`
+	Description string         `db:"description"`
+	Link        sql.NullString `db:"link"`
+	AnnouncedAt time.Time      `db:"announced_at"`
+	SunsetAt    sql.NullTime   `db:"sunset_at"`
+}
+
+// ChangelogRepository stores the public API changelog.
+type ChangelogRepository interface {
+	// List returns the changes announced after since (all when zero), newest first.
+	List(ctx context.Context, since time.Time) ([]APIChange, error)
+	// Deprecations returns every route deprecation, for the Deprecation/Sunset headers.
+	Deprecations(ctx context.Context) ([]APIChange, error)
+	Create(ctx context.Context, c APIChange) (APIChange, error)
+	// Delete removes a change, returning sql.ErrNoRows if it does not exist.
+	Delete(ctx context.Context, id int) error
+}
+
+type pgChangelogRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+func NewChangelogRepository(db *sqlx.DB, logger *zap.Logger) ChangelogRepository {
+	return &pgChangelogRepo{db: db, logger: logger}
+}
+
+func (r *pgChangelogRepo) List(ctx context.Context, since time.Time) ([]APIChange, error) {
+	const q = `SELECT * FROM api_changes WHERE announced_at > $1 ORDER BY announced_at DESC, id DESC;`
+	var changes []APIChange
+	if err := r.db.SelectContext(ctx, &changes, q, since); err != nil {
+		r.logger.Error("failed to list api changes", zap.Error(err))
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *pgChangelogRepo) Deprecations(ctx context.Context) ([]APIChange, error) {
+	const q = `SELECT * FROM api_changes WHERE kind = 'deprecated' AND path IS NOT NULL;`
+	var changes []APIChange
+	if err := r.db.SelectContext(ctx, &changes, q); err != nil {
+		r.logger.Error("failed to list api deprecations", zap.Error(err))
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *pgChangelogRepo) Create(ctx context.Context, c APIChange) (APIChange, error) {
+	const q = `
+        INSERT INTO api_changes (kind, method, path, title, description, link, announced_at, sunset_at)
+        VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, now()), $8)
+        RETURNING *;
+    `
+	var announcedAt sql.NullTime
+	if !c.AnnouncedAt.IsZero() {
+		announcedAt = sql.NullTime{Time: c.AnnouncedAt, Valid: true}
+	}
+	var created APIChange
+	err := r.db.GetContext(ctx, &created, q,
+		c.Kind, c.Method, c.Path, c.Title, c.Description, c.Link, announcedAt, c.SunsetAt)
+	if err != nil {
+		r.logger.Error("failed to create api change", zap.String("title", c.Title), zap.Error(err))
+		return APIChange{}, err
+	}
+	r.logger.Info("api change announced", zap.Int("id", created.ID), zap.String("kind", created.Kind))
+	return created, nil
+}
+
+func (r *pgChangelogRepo) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM api_changes WHERE id = $1;`, id)
+	if err != nil {
+		r.logger.Error("failed to delete api change", zap.Int("id", id), zap.Error(err))
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestChangelogRepository_List_Since(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewChangelogRepository(sqlxDB, zap.NewNop())
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	announced := since.Add(24 * time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM api_changes WHERE announced_at > $1 ORDER BY announced_at DESC, id DESC")).
+		WithArgs(since).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "method", "path", "title", "description", "link", "announced_at", "sunset_at"}).
+			AddRow(2, "deprecated", "POST", "/api/manage/:token", "Use PATCH /api/subscriptions/:token", "", nil, announced, announced.AddDate(0, 6, 0)))
+
+	changes, err := repo.List(context.Background(), since)
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path.String != "/api/manage/:token" || !changes[0].SunsetAt.Valid {
+		t.Errorf("List() = %+v, want the deprecation with its sunset", changes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestChangelogRepository_Delete_NotFound(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewChangelogRepository(sqlxDB, zap.NewNop())
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM api_changes WHERE id = $1")).
+		WithArgs(42).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.Delete(context.Background(), 42); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Delete() error = %v, want sql.ErrNoRows", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// CityMergeResult counts the rows changed (or, on a dry run, that would change) when a
+// city spelling is merged into the canonical one.
+type CityMergeResult struct {
+	Subscriptions        int64 // subscription cities re-pointed to the canonical spelling
+	DuplicatesDropped    int64 // entries dropped because the address already had the canonical city
+	SubscriptionsRemoved int64 // subscriptions left without any city by the drop, deleted
+	Deliveries           int64 // delivery log rows re-pointed
+}
+
+// MergeCity replaces the city spelling from with to in subscriptions, their city lists
+// and the delivery log, in one transaction. Where an address already tracks to, in any
+// letter case, its from entry is dropped instead (one city per address, see
+// uq_subscription_cities_tenant_email_hash_city), and a subscription left with no city
+// is deleted. from is matched exactly, in every tenant: city names are shared.
+// With dryRun the transaction is rolled back, so the result previews the merge without
+// changing anything.
+func (r *pgRepo) MergeCity(ctx context.Context, from, to string, dryRun bool) (CityMergeResult, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.Error("failed to begin city merge transaction", zap.Error(err))
+		return CityMergeResult{}, err
+	}
+	defer tx.Rollback()
+
+	var res CityMergeResult
+	steps := []struct {
+		count *int64
+		q     string
+	}{
+		{&res.DuplicatesDropped, `
+        DELETE FROM subscription_cities sc
+        WHERE sc.city = $1
+          AND EXISTS (SELECT 1 FROM subscription_cities o
+                      WHERE lower(o.city) = lower($2) AND o.city <> $1
+                        AND (o.subscription_id = sc.subscription_id
+                             OR (o.tenant_id = sc.tenant_id AND o.email_hash = sc.email_hash)));
+    `},
+		{&res.SubscriptionsRemoved, `
+        DELETE FROM subscriptions s
+        WHERE s.city = $1
+          AND NOT EXISTS (SELECT 1 FROM subscription_cities sc WHERE sc.subscription_id = s.id);
+    `},
+		{&res.Subscriptions, `UPDATE subscription_cities SET city = $2 WHERE city = $1;`},
+		// the primary city follows the (re-pointed) first city of the list
+		{nil, `
+        UPDATE subscriptions s
+        SET city = (SELECT sc.city FROM subscription_cities sc
+                    WHERE sc.subscription_id = s.id ORDER BY sc.position LIMIT 1)
+        WHERE s.city = $1;
+    `},
+		{&res.Deliveries, `UPDATE deliveries SET city = $2 WHERE city = $1;`},
+	}
+	for _, step := range steps {
+		result, err := tx.ExecContext(ctx, step.q, from, to)
+		if err != nil {
+			r.logger.Error("failed to merge city", zap.String("from", from), zap.String("to", to), zap.Error(err))
+			return CityMergeResult{}, err
+		}
+		if step.count != nil {
+			if *step.count, err = result.RowsAffected(); err != nil {
+				return CityMergeResult{}, err
+			}
+		}
+	}
+
+	if dryRun {
+		return res, nil // rolled back by the deferred Rollback
+	}
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit city merge", zap.Error(err))
+		return CityMergeResult{}, err
+	}
+	r.logger.Info("cities merged", zap.String("from", from), zap.String("to", to),
+		zap.Int64("subscriptions", res.Subscriptions), zap.Int64("duplicates_dropped", res.DuplicatesDropped),
+		zap.Int64("subscriptions_removed", res.SubscriptionsRemoved), zap.Int64("deliveries", res.Deliveries))
+	return res, nil
+}
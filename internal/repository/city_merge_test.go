@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestSubscriptionRepository_MergeCity_DryRunRollsBack(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM subscription_cities sc WHERE sc.city = $1 AND EXISTS (SELECT 1 FROM subscription_cities o WHERE lower(o.city) = lower($2) AND o.city <> $1")).
+		WithArgs("NYC", "New York").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM subscriptions s WHERE s.city = $1")).
+		WithArgs("NYC", "New York").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE subscription_cities SET city = $2 WHERE city = $1")).
+		WithArgs("NYC", "New York").WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE subscriptions s SET city = (SELECT sc.city FROM subscription_cities sc")).
+		WithArgs("NYC", "New York").WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE deliveries SET city = $2 WHERE city = $1")).
+		WithArgs("NYC", "New York").WillReturnResult(sqlmock.NewResult(0, 40))
+	mock.ExpectRollback()
+
+	res, err := repo.MergeCity(context.Background(), "NYC", "New York", true)
+	if err != nil {
+		t.Fatalf("MergeCity() error: %v", err)
+	}
+	want := CityMergeResult{Subscriptions: 5, DuplicatesDropped: 2, SubscriptionsRemoved: 1, Deliveries: 40}
+	if res != want {
+		t.Errorf("MergeCity() = %+v, want %+v", res, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
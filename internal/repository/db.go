@@ -2,19 +2,147 @@ package repository
 
 import (
 	"context"
-	"github.com/jmoiron/sqlx"
+	"fmt"
+	"sync"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
 )
 
-func OpenDB(dsn string) (*sqlx.DB, error) {
-	db, err := sqlx.Open("pgx", dsn) // ← driver name is "pgx"
+// pools are the connection pools opened by OpenDB, summed up in the pool metrics.
+var pools struct {
+	mu   sync.Mutex
+	list []*pgxpool.Pool
+}
+
+func init() {
+	sum := func(of func(*pgxpool.Stat) float64) func() float64 {
+		return func() float64 {
+			pools.mu.Lock()
+			defer pools.mu.Unlock()
+			var total float64
+			for _, p := range pools.list {
+				total += of(p.Stat())
+			}
+			return total
+		}
+	}
+	metrics.NewGaugeFunc("weather_db_pool_conns", "Open Postgres connections, idle or in use.",
+		sum(func(s *pgxpool.Stat) float64 { return float64(s.TotalConns()) }))
+	metrics.NewGaugeFunc("weather_db_pool_acquired_conns", "Postgres connections in use.",
+		sum(func(s *pgxpool.Stat) float64 { return float64(s.AcquiredConns()) }))
+	metrics.NewGaugeFunc("weather_db_pool_max_conns", "Most Postgres connections the pools may open.",
+		sum(func(s *pgxpool.Stat) float64 { return float64(s.MaxConns()) }))
+	metrics.NewCounterFunc("weather_db_pool_acquires_total", "Postgres connections taken from the pools.",
+		sum(func(s *pgxpool.Stat) float64 { return float64(s.AcquireCount()) }))
+	metrics.NewCounterFunc("weather_db_pool_waits_total",
+		"Postgres connections taken from the pools after waiting for one to free up.",
+		sum(func(s *pgxpool.Stat) float64 { return float64(s.EmptyAcquireCount()) }))
+	metrics.NewCounterFunc("weather_db_pool_wait_seconds_total",
+		"Time spent waiting for a Postgres connection to free up.",
+		sum(func(s *pgxpool.Stat) float64 { return s.EmptyAcquireWaitTime().Seconds() }))
+}
+
+// Retry is how OpenDB waits for a database not accepting connections yet, e.g. one
+// starting alongside the services.
+type Retry struct {
+	For     time.Duration // keep trying this long; 0 tries once
+	Backoff time.Duration // first wait between attempts, doubling up to maxRetryBackoff
+}
+
+const (
+	// maxRetryBackoff caps the wait between two connection attempts.
+	maxRetryBackoff = 10 * time.Second
+	// pingTimeout bounds one connection attempt or health check.
+	pingTimeout = 5 * time.Second
+)
+
+// Pool sizes the connection pool of OpenDB. Zero fields keep pgxpool's defaults.
+//
+// It is not given as pool_* parameters of the DSN: the DSN is shared with the LISTEN
+// connection (see Listener), which plain pgx dials, and pgx passes unknown parameters
+// on to Postgres, which refuses to start a session with them.
+type Pool struct {
+	MaxConns        int           // DB_POOL_MAX_CONNS
+	MinConns        int           // DB_POOL_MIN_CONNS
+	MaxConnLifetime time.Duration // DB_POOL_MAX_CONN_LIFETIME
+}
+
+// OpenDB opens a pgxpool connection pool on dsn and returns it behind database/sql,
+// for the sqlx repositories. Statements are prepared once per connection and cached
+// (pgx's default exec mode), up to the statement_cache_capacity parameter of dsn. The
+// pool is sized by pool; it stays open for the life of the process, as closing the
+// returned DB does not close it. Until the database answers, connecting is retried as
+// retry says, or until ctx is done. Every query is timed, see queryTracer.
+func OpenDB(ctx context.Context, dsn string, pool Pool, retry Retry, logger *zap.Logger) (*sqlx.DB, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
-	db.SetConnMaxLifetime(time.Minute * 5)
-	db.SetMaxIdleConns(5)
-	db.SetMaxOpenConns(10)
-	return db, db.PingContext(context.Background())
+	if pool.MaxConns > 0 {
+		cfg.MaxConns = int32(pool.MaxConns)
+	}
+	if pool.MinConns > 0 {
+		cfg.MinConns = int32(pool.MinConns)
+	}
+	if pool.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = pool.MaxConnLifetime
+	}
+	cfg.ConnConfig.Tracer = queryTracer{logger: logger}
+	p, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := waitReady(ctx, p, retry, logger); err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	pools.mu.Lock()
+	pools.list = append(pools.list, p)
+	pools.mu.Unlock()
+	return sqlx.NewDb(stdlib.OpenDBFromPool(p), "pgx"), nil
+}
+
+// OpenReplica opens the read replica at dsn like OpenDB, or returns nil when dsn is
+// empty (no replica configured).
+func OpenReplica(ctx context.Context, dsn string, pool Pool, retry Retry, logger *zap.Logger) (*sqlx.DB, error) {
+	if dsn == "" {
+		return nil, nil
+	}
+	return OpenDB(ctx, dsn, pool, retry, logger)
+}
+
+// waitReady pings pool until it answers, backing off between attempts, for as long as
+// retry allows.
+func waitReady(ctx context.Context, pool *pgxpool.Pool, retry Retry, logger *zap.Logger) error {
+	deadline := time.Now().Add(retry.For)
+	wait := retry.Backoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := pool.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				logger.Info("database ready", zap.Int("attempts", attempt))
+			}
+			return nil
+		}
+		if retry.For <= 0 || time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("database not ready after %d attempts: %w", attempt, err)
+		}
+		logger.Warn("database not ready, retrying", zap.Int("attempt", attempt), zap.Duration("in", wait),
+			zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, maxRetryBackoff)
+	}
 }
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+var (
+	poolDegraded = metrics.NewGauge("weather_db_pool_degraded",
+		"1 while a Postgres connection pool is unreachable or saturated, see MonitorPools.")
+	pingFailures = metrics.NewCounter("weather_db_ping_failures_total",
+		"Failed health check pings of the Postgres connection pools.")
+)
+
+// MonitorPools checks the pools opened by OpenDB every interval until ctx is done. A
+// pool is degraded while it is unreachable (its ping fails) or saturated (every
+// connection in use and requests waiting for one); the start and the end of each such
+// episode are logged, and weather_db_pool_degraded is 1 while any pool is degraded.
+// pgxpool reconnects by itself: the monitor only reports.
+func MonitorPools(ctx context.Context, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	type state struct {
+		waits  int64  // EmptyAcquireCount at the last check
+		reason string // why the pool is degraded; "" when healthy
+	}
+	states := make(map[*pgxpool.Pool]*state)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pools.mu.Lock()
+		list := slices.Clone(pools.list)
+		pools.mu.Unlock()
+		degraded := 0.0
+		for _, p := range list {
+			st := states[p]
+			if st == nil {
+				st = &state{waits: p.Stat().EmptyAcquireCount()}
+				states[p] = st
+			}
+
+			// a saturated pool is not pinged: the ping would only queue behind the requests
+			stat := p.Stat()
+			saturated := stat.AcquiredConns() >= stat.MaxConns() && stat.EmptyAcquireCount() > st.waits
+			var err error
+			if !saturated {
+				pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+				err = p.Ping(pingCtx)
+				cancel()
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					pingFailures.Inc()
+				}
+			}
+			reason := degradation(err, saturated)
+			st.waits = p.Stat().EmptyAcquireCount()
+
+			host := p.Config().ConnConfig.Host
+			switch {
+			case reason != "" && st.reason == "":
+				logger.Warn("database pool degraded", zap.String("host", host), zap.String("reason", reason),
+					zap.Int32("acquired_conns", stat.AcquiredConns()), zap.Int32("max_conns", stat.MaxConns()),
+					zap.Error(err))
+			case reason == "" && st.reason != "":
+				logger.Info("database pool recovered", zap.String("host", host), zap.String("was", st.reason))
+			}
+			st.reason = reason
+			if reason != "" {
+				degraded = 1
+			}
+		}
+		poolDegraded.Set(degraded)
+	}
+}
+
+// degradation tells why a pool is degraded, "" when it is healthy: saturated when every
+// connection is in use and acquisitions waited since the previous check, unreachable
+// when its health check ping failed.
+func degradation(pingErr error, saturated bool) string {
+	switch {
+	case saturated:
+		return "saturated"
+	case pingErr != nil:
+		return "unreachable"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+var (
+	queryDuration = metrics.NewHistogramVec("weather_db_query_duration_seconds",
+		"Postgres query latency, by query id (the verb and a hash of the SQL, logged with slow queries).", "query",
+		[]float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10})
+	slowQueries = metrics.NewCounter("weather_db_slow_queries_total",
+		"Postgres queries slower than DB_SLOW_QUERY_THRESHOLD.")
+)
+
+// slowQueryThreshold is the duration above which queries are logged; 0 logs none.
+var slowQueryThreshold atomic.Int64
+
+// SetSlowQueryThreshold sets the duration above which queries are logged
+// (DB_SLOW_QUERY_THRESHOLD); 0 logs none.
+func SetSlowQueryThreshold(d time.Duration) {
+	slowQueryThreshold.Store(int64(d))
+}
+
+// queryTracer times every query of the connections of OpenDB: it records their latency
+// and logs the slow ones. Bound parameters are never logged, only their types, as they
+// hold subscriber data.
+type queryTracer struct {
+	logger *zap.Logger
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	sql  string
+	args []any
+	at   time.Time
+}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (t queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{sql: data.SQL, args: data.Args, at: time.Now()})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer.
+func (t queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start.at)
+	sql := compactSQL(start.sql)
+	id := queryID(sql)
+	queryDuration.Observe(id, elapsed.Seconds())
+
+	if threshold := time.Duration(slowQueryThreshold.Load()); threshold > 0 && elapsed >= threshold {
+		slowQueries.Inc()
+		t.logger.Warn("slow query", zap.String("query", id), zap.Duration("duration", elapsed),
+			zap.String("sql", sql), zap.Strings("args", redactArgs(start.args)), zap.Error(data.Err))
+	}
+}
+
+// compactSQL collapses the whitespace of a query onto one line.
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+// queryID names a query for the metrics: its verb and the first 8 hex digits of the
+// SHA-256 of its (compacted) SQL, e.g. "select_1f3a9c0e". Queries differing only in
+// their parameters share it.
+func queryID(sql string) string {
+	verb, _, _ := strings.Cut(sql, " ")
+	sum := sha256.Sum256([]byte(sql))
+	return strings.ToLower(verb) + "_" + hex.EncodeToString(sum[:4])
+}
+
+// redactArgs describes bound parameters by their type only, e.g. "$1 string".
+func redactArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = fmt.Sprintf("$%d %T", i+1, a)
+	}
+	return out
+}
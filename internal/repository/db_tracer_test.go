@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestQueryID_IgnoresWhitespace(t *testing.T) {
+	a := queryID(compactSQL("SELECT id\n\t FROM subscriptions\n WHERE email_hash = $1;"))
+	b := queryID(compactSQL("SELECT id FROM subscriptions WHERE email_hash = $1;"))
+	if a != b {
+		t.Errorf("queryID() = %q and %q, want equal", a, b)
+	}
+	if len(a) != len("select_")+8 || a[:len("select_")] != "select_" {
+		t.Errorf("queryID() = %q, want select_ and 8 hex digits", a)
+	}
+	if c := queryID("SELECT id FROM subscriptions WHERE token_hash = $1;"); c == a {
+		t.Errorf("queryID() of another query = %q, want different", c)
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	got := redactArgs([]any{"a@example.com", 42, nil})
+	want := []string{"$1 string", "$2 int", "$3 <nil>"}
+	if !slices.Equal(got, want) {
+		t.Errorf("redactArgs() = %v, want %v", got, want)
+	}
+}
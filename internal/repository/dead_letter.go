@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// DeadLetterRepository counts the consecutive failed sends of subscriptions and
+// dead-letters those that keep failing, so that they get nothing more.
+type DeadLetterRepository interface {
+	// TrackSends resets the failure count of the subscriptions sent to, and counts a
+	// failure against the failed ones. Those reaching after failures in a row are
+	// dead-lettered and returned.
+	TrackSends(ctx context.Context, sent, failed []int, after int) ([]int, error)
+	// Revive gives a dead-lettered subscription a fresh start, returning sql.ErrNoRows
+	// if it is missing or not dead-lettered.
+	Revive(ctx context.Context, id int) error
+}
+
+type pgDeadLetterRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+func NewDeadLetterRepository(db *sqlx.DB, logger *zap.Logger) DeadLetterRepository {
+	return &pgDeadLetterRepo{db: db, logger: logger}
+}
+
+func (r *pgDeadLetterRepo) TrackSends(ctx context.Context, sent, failed []int, after int) ([]int, error) {
+	if len(sent) > 0 {
+		const q = `UPDATE subscriptions SET consecutive_failures = 0 WHERE id = ANY($1) AND consecutive_failures > 0;`
+		if _, err := r.db.ExecContext(ctx, q, int64s(sent)); err != nil {
+			r.logger.Error("failed to reset send failures", zap.Int("count", len(sent)), zap.Error(err))
+			return nil, err
+		}
+	}
+	if len(failed) == 0 {
+		return nil, nil
+	}
+
+	const q = `
+        WITH bumped AS (
+            UPDATE subscriptions
+            SET consecutive_failures = consecutive_failures + 1,
+                dead_lettered_at = CASE WHEN consecutive_failures + 1 >= $2 THEN now() ELSE NULL END
+            WHERE id = ANY($1) AND dead_lettered_at IS NULL
+            RETURNING id, dead_lettered_at, consecutive_failures
+        ), e AS (
+            INSERT INTO subscription_events (subscription_id, kind, detail)
+            SELECT id, 'dead_lettered', consecutive_failures || ' failed sends in a row'
+            FROM bumped WHERE dead_lettered_at IS NOT NULL
+        )
+        SELECT id FROM bumped WHERE dead_lettered_at IS NOT NULL;
+    `
+	var dead []int
+	if err := r.db.SelectContext(ctx, &dead, q, int64s(failed), after); err != nil {
+		r.logger.Error("failed to count send failures", zap.Int("count", len(failed)), zap.Error(err))
+		return nil, err
+	}
+	return dead, nil
+}
+
+func (r *pgDeadLetterRepo) Revive(ctx context.Context, id int) error {
+	const q = `
+        WITH revived AS (
+            UPDATE subscriptions SET consecutive_failures = 0, dead_lettered_at = NULL
+            WHERE id = $1 AND dead_lettered_at IS NOT NULL AND ($2::text IS NULL OR tenant_id = $2)
+            RETURNING id
+        )
+        INSERT INTO subscription_events (subscription_id, kind) SELECT id, 'revived' FROM revived;
+    `
+	res, err := r.db.ExecContext(ctx, q, id, tenantScope(ctx))
+	if err != nil {
+		r.logger.Error("failed to revive subscription", zap.Int("id", id), zap.Error(err))
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("failed to get rows affected on revive", zap.Error(err))
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// int64s converts ids for an ANY($n) argument.
+func int64s(ids []int) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
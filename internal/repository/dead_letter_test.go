@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestDeadLetterRepository_TrackSends(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewDeadLetterRepository(sqlxDB, zap.NewNop())
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE subscriptions SET consecutive_failures = 0 WHERE id = ANY($1) AND consecutive_failures > 0;")).
+		WithArgs([]int64{1}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SET consecutive_failures = consecutive_failures + 1, dead_lettered_at = CASE WHEN consecutive_failures + 1 >= $2 THEN now() ELSE NULL END WHERE id = ANY($1) AND dead_lettered_at IS NULL")).
+		WithArgs([]int64{2, 3}, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	dead, err := repo.TrackSends(context.Background(), []int{1}, []int{2, 3}, 5)
+	if err != nil {
+		t.Fatalf("TrackSends() unexpected error: %v", err)
+	}
+	if len(dead) != 1 || dead[0] != 3 {
+		t.Errorf("TrackSends() = %v, want [3] dead-lettered", dead)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestDeadLetterRepository_Revive_NotDeadLettered(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewDeadLetterRepository(sqlxDB, zap.NewNop())
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE subscriptions SET consecutive_failures = 0, dead_lettered_at = NULL WHERE id = $1 AND dead_lettered_at IS NOT NULL AND ($2::text IS NULL OR tenant_id = $2) RETURNING id ) INSERT INTO subscription_events (subscription_id, kind) SELECT id, 'revived' FROM revived;")).
+		WithArgs(4, nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.Revive(context.Background(), 4); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Revive() = %v, want sql.ErrNoRows", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestDeliveryRepository_Record_BatchInsert(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewDeliveryRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	slot := time.Now().UTC().Truncate(time.Minute)
+	deliveries := []Delivery{
+		{SubscriptionID: sql.NullInt64{Int64: 1, Valid: true}, Email: "a@b.com", City: "Kyiv",
+			ScheduledFor: slot, SentAt: sql.NullTime{Time: slot, Valid: true}, Status: DeliveryStatusSent},
+		{SubscriptionID: sql.NullInt64{Int64: 2, Valid: true}, Email: "c@d.com", City: "Lviv",
+			ScheduledFor: slot, Status: DeliveryStatusFailed, Error: sql.NullString{String: "boom", Valid: true}},
+	}
+
+	// Both rows must go out in a single multi-row INSERT
+	mock.ExpectExec(regexp.QuoteMeta(
+		"INSERT INTO deliveries (subscription_id, email, city, scheduled_for, sent_at, status, error, open_token) VALUES ($1, $2, $3, $4, $5, $6, $7, $8),($9, $10, $11, $12, $13, $14, $15, $16)",
+	)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := repo.Record(context.Background(), deliveries); err != nil {
+		t.Fatalf("Record() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestDeliveryRepository_Settle(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewDeliveryRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	sent, failed := uuid.New(), uuid.New()
+	at := time.Now().UTC()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE deliveries SET status = 'sent', sent_at = $2 WHERE open_token = ANY($1) AND status = 'queued';")).
+		WithArgs([]string{sent.String()}, at).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE deliveries SET status = 'failed', error = x.error, open_token = NULL FROM unnest($1::uuid[], $2::text[]) AS x(token, error) WHERE open_token = x.token AND status = 'queued';")).
+		WithArgs([]string{failed.String()}, []string{"550 no such user"}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.Settle(context.Background(), []uuid.UUID{sent}, at, map[uuid.UUID]string{failed: "550 no such user"}); err != nil {
+		t.Fatalf("Settle() unexpected error: %v", err)
+	}
+	// nothing to settle, nothing to run
+	if err := repo.Settle(context.Background(), nil, at, nil); err != nil {
+		t.Fatalf("Settle() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestDeliveryRepository_OnTimeStats(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewDeliveryRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	since := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM deliveries WHERE scheduled_for >= $1")).
+		WithArgs(since, float64(300)).
+		WillReturnRows(sqlmock.NewRows([]string{"on_time", "total"}).AddRow(99, 100))
+
+	onTime, total, err := repo.OnTimeStats(context.Background(), since, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("OnTimeStats() unexpected error: %v", err)
+	}
+	if onTime != 99 || total != 100 {
+		t.Errorf("OnTimeStats() = (%d, %d), want (99, 100)", onTime, total)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestDeliveryRepository_ReplayTargets(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewDeliveryRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	from := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	to := from.Add(3 * time.Hour)
+	slot := from.Add(time.Hour)
+
+	// the latest failed slot per subscription, unless something was sent since
+	mock.ExpectQuery(`SELECT DISTINCT ON \(subscription_id\) subscription_id, scheduled_for FROM deliveries WHERE status = 'failed'.*`+
+		`failed.scheduled_for AS replay_slot.*NOT EXISTS .*sent.status IN \('sent', 'queued'\) AND sent.scheduled_for >= failed.scheduled_for`).
+		WithArgs(from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city", "frequency", "confirmed", "replay_slot"}).
+			AddRow(7, "a@b.com", "Kyiv", "daily", true, slot))
+
+	targets, err := repo.ReplayTargets(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("ReplayTargets() unexpected error: %v", err)
+	}
+	if len(targets) != 1 || targets[0].ID != 7 || targets[0].Email != "a@b.com" || !targets[0].Slot.Equal(slot) {
+		t.Errorf("ReplayTargets() = %+v", targets)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// Delivery statuses stored in deliveries.status. Emails handed to the mail queue are
+// queued until its worker records them sent or failed.
+const (
+	DeliveryStatusSent   = "sent"
+	DeliveryStatusFailed = "failed"
+	DeliveryStatusQueued = "queued"
+)
+
+type Delivery struct {
+	ID             int64          `db:"id"`
+	SubscriptionID sql.NullInt64  `db:"subscription_id"`
+	Email          string         `db:"email"` // plaintext; stored encrypted
+	City           string         `db:"city"`
+	ScheduledFor   time.Time      `db:"scheduled_for"`
+	SentAt         sql.NullTime   `db:"sent_at"`
+	Status         string         `db:"status"` // 'sent' | 'failed' | 'queued'
+	Error          sql.NullString `db:"error"`
+	OpenToken      uuid.NullUUID  `db:"open_token"` // tracking pixel of sent emails
+	OpenedAt       sql.NullTime   `db:"opened_at"`
+	CreatedAt      time.Time      `db:"created_at"`
+}
+
+// Succeeded reports whether the message of d was sent, or queued for the mail queue
+// worker to send.
+func (d Delivery) Succeeded() bool {
+	return d.Status == DeliveryStatusSent || d.Status == DeliveryStatusQueued
+}
+
+// DeliveryRepository records the outcome of scheduled emails.
+type DeliveryRepository interface {
+	Record(ctx context.Context, deliveries []Delivery) error
+	// Settle records the outcome of queued deliveries, by their open token: sent at
+	// sentAt, or failed with the error of each in failed. Entries no longer queued are
+	// left alone.
+	Settle(ctx context.Context, sent []uuid.UUID, sentAt time.Time, failed map[uuid.UUID]string) error
+	// OnTimeStats counts deliveries scheduled since `since`, and how many of them
+	// were sent no later than maxDelay after their slot.
+	OnTimeStats(ctx context.Context, since time.Time, maxDelay time.Duration) (onTime, total int64, err error)
+	// ReplayTargets selects the still-active subscriptions whose delivery failed in a slot
+	// within [from, to) and nothing was sent to them since.
+	ReplayTargets(ctx context.Context, from, to time.Time) ([]ReplayTarget, error)
+}
+
+// ReplayTarget is a subscription to send the update of a failed delivery to again.
+type ReplayTarget struct {
+	Subscription
+	Slot time.Time `db:"replay_slot"` // the latest failed slot; the replay is recorded against it
+}
+
+type pgDeliveryRepo struct {
+	db     *sqlx.DB
+	pii    *pii.Cipher
+	tokens *linktoken.Deriver
+	logger *zap.Logger
+}
+
+// NewDeliveryRepository stores emails encrypted with cipher (nil stores them as
+// plaintext) and derives the link tokens of subscriptions with tokens.
+func NewDeliveryRepository(db *sqlx.DB, cipher *pii.Cipher, tokens *linktoken.Deriver, logger *zap.Logger,
+) DeliveryRepository {
+	return &pgDeliveryRepo{db: db, pii: cipher, tokens: tokens, logger: logger}
+}
+
+func (r *pgDeliveryRepo) Record(ctx context.Context, deliveries []Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	rows := make([]Delivery, len(deliveries))
+	for i, d := range deliveries {
+		encrypted, err := r.pii.Encrypt(d.Email)
+		if err != nil {
+			return err
+		}
+		d.Email = encrypted
+		rows[i] = d
+	}
+	const q = `
+        INSERT INTO deliveries (subscription_id, email, city, scheduled_for, sent_at, status, error, open_token)
+        VALUES (:subscription_id, :email, :city, :scheduled_for, :sent_at, :status, :error, :open_token);
+    `
+	if _, err := r.db.NamedExecContext(ctx, q, rows); err != nil {
+		r.logger.Error("failed to record deliveries", zap.Int("count", len(deliveries)), zap.Error(err))
+		return err
+	}
+	r.logger.Debug("deliveries recorded", zap.Int("count", len(deliveries)))
+	return nil
+}
+
+func (r *pgDeliveryRepo) Settle(ctx context.Context, sent []uuid.UUID, sentAt time.Time,
+	failed map[uuid.UUID]string,
+) error {
+	if len(sent) > 0 {
+		const q = `
+            UPDATE deliveries SET status = 'sent', sent_at = $2
+            WHERE open_token = ANY($1) AND status = 'queued';
+        `
+		if _, err := r.db.ExecContext(ctx, q, uuidStrings(sent), sentAt); err != nil {
+			r.logger.Error("failed to record queued deliveries sent", zap.Int("count", len(sent)), zap.Error(err))
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		tokens := make([]string, 0, len(failed))
+		errs := make([]string, 0, len(failed))
+		for token, msg := range failed {
+			tokens = append(tokens, token.String())
+			errs = append(errs, msg)
+		}
+		// like failed sends, failed entries keep no tracking pixel
+		const q = `
+            UPDATE deliveries SET status = 'failed', error = x.error, open_token = NULL
+            FROM unnest($1::uuid[], $2::text[]) AS x(token, error)
+            WHERE open_token = x.token AND status = 'queued';
+        `
+		if _, err := r.db.ExecContext(ctx, q, tokens, errs); err != nil {
+			r.logger.Error("failed to record queued deliveries failed", zap.Int("count", len(failed)), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// uuidStrings converts tokens for an ANY($n) argument.
+func uuidStrings(tokens []uuid.UUID) []string {
+	out := make([]string, len(tokens))
+	for i, t := range tokens {
+		out[i] = t.String()
+	}
+	return out
+}
+
+func (r *pgDeliveryRepo) OnTimeStats(ctx context.Context, since time.Time, maxDelay time.Duration,
+) (onTime, total int64, err error) {
+	const q = `
+        SELECT count(*) FILTER (WHERE status = 'sent' AND sent_at <= scheduled_for + $2 * INTERVAL '1 second'),
+               count(*)
+        FROM deliveries
+        WHERE scheduled_for >= $1;
+    `
+	row := r.db.QueryRowContext(ctx, q, since, maxDelay.Seconds())
+	if err := row.Scan(&onTime, &total); err != nil {
+		r.logger.Error("failed to compute delivery stats", zap.Time("since", since), zap.Error(err))
+		return 0, 0, err
+	}
+	return onTime, total, nil
+}
+
+// ReplayTargets implements DeliveryRepository. Each subscription is replayed once, for
+// its latest failed slot: an update carries the current weather, so earlier missed
+// slots are covered by it. A subscription sent anything at or after that slot (a
+// replay included) is left out, so running a replay again does not send twice. Alerts
+// and warnings are not replayed: a missed one is sent again at the next check.
+func (r *pgDeliveryRepo) ReplayTargets(ctx context.Context, from, to time.Time) ([]ReplayTarget, error) {
+	const q = `
+        WITH failed AS (
+            SELECT DISTINCT ON (subscription_id) subscription_id, scheduled_for
+            FROM deliveries
+            WHERE status = 'failed'
+              AND subscription_id IS NOT NULL
+              AND scheduled_for >= $1 AND scheduled_for < $2
+            ORDER BY subscription_id, scheduled_for DESC
+        )
+        SELECT ` + batchColumns + `, failed.scheduled_for AS replay_slot
+        FROM failed
+        JOIN subscriptions ON subscriptions.id = failed.subscription_id
+        WHERE ` + activeCondition + `
+          AND frequency NOT IN ('alert', 'warnings')
+          AND NOT EXISTS (
+              SELECT 1 FROM deliveries sent
+              WHERE sent.subscription_id = failed.subscription_id
+                AND sent.status IN ('sent', 'queued')
+                AND sent.scheduled_for >= failed.scheduled_for
+          )
+        ORDER BY replay_slot, subscriptions.id;
+    `
+	var rows []ReplayTarget
+	if err := r.db.SelectContext(ctx, &rows, q, from, to); err != nil {
+		r.logger.Error("failed to select deliveries to replay", zap.Time("from", from), zap.Time("to", to), zap.Error(err))
+		return nil, err
+	}
+	out := rows[:0]
+	for _, t := range rows {
+		if err := decryptSubscription(r.pii, r.tokens, &t.Subscription); err != nil {
+			r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+			continue
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
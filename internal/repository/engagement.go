@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// Lifecycle email kinds stored in lifecycle_emails.kind.
+const (
+	LifecycleAnniversary  = "anniversary"
+	LifecycleReEngagement = "re_engagement"
+	LifecycleRenewal      = "renewal"
+)
+
+// EngagementRepository records email opens and subscriber engagement ("keep sending"
+// clicks), renews expiring subscriptions and selects the subscriptions due for
+// lifecycle emails.
+type EngagementRepository interface {
+	// RecordOpen marks the delivery behind an open-tracking token as opened. An open
+	// is not engagement: mail filters and image proxies fetch the pixel of emails
+	// nobody reads. It returns sql.ErrNoRows when the token is unknown.
+	RecordOpen(ctx context.Context, openToken uuid.UUID) error
+	// MarkEngaged records that the subscriber behind a manage token wants to keep
+	// receiving updates. It returns sql.ErrNoRows when the token is unknown.
+	MarkEngaged(ctx context.Context, manageToken uuid.UUID) error
+	// Renew moves the expiry of the subscription behind a manage token to ttl after
+	// now. Subscriptions without an expiry are left as they are.
+	Renew(ctx context.Context, manageToken uuid.UUID, ttl time.Duration) error
+	// ClaimAnniversaries returns the subscriptions whose first year ended within
+	// window before now, marking their anniversary email as sent. SMS subscriptions,
+	// whose email address is not verified, are left out.
+	ClaimAnniversaries(ctx context.Context, now time.Time, window time.Duration) ([]Subscription, error)
+	// ClaimReEngagements returns the subscriptions that showed no engagement during
+	// the inactivity period before now and were not asked within it already, marking
+	// their re-engagement email as sent.
+	ClaimReEngagements(ctx context.Context, now time.Time, inactivity time.Duration) ([]Subscription, error)
+	// ClaimRenewals returns the subscriptions that expired by now and were not sent a
+	// renewal email since, marking their renewal email as sent.
+	ClaimRenewals(ctx context.Context, now time.Time) ([]Subscription, error)
+}
+
+type pgEngagementRepo struct {
+	db     *sqlx.DB
+	pii    *pii.Cipher
+	tokens *linktoken.Deriver
+	logger *zap.Logger
+}
+
+// NewEngagementRepository decrypts subscriber emails with cipher (nil: stored as
+// plaintext), and derives and checks link tokens with tokens.
+func NewEngagementRepository(db *sqlx.DB, cipher *pii.Cipher, tokens *linktoken.Deriver, logger *zap.Logger,
+) EngagementRepository {
+	return &pgEngagementRepo{db: db, pii: cipher, tokens: tokens, logger: logger}
+}
+
+func (r *pgEngagementRepo) RecordOpen(ctx context.Context, openToken uuid.UUID) error {
+	const q = `
+        UPDATE deliveries
+        SET opened_at = COALESCE(opened_at, now())
+        WHERE open_token = $1
+          AND ($2::text IS NULL OR subscription_id IN (SELECT id FROM subscriptions WHERE tenant_id = $2));
+    `
+	res, err := r.db.ExecContext(ctx, q, openToken, tenantScope(ctx))
+	if err != nil {
+		r.logger.Error("failed to record open", zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		r.logger.Debug("open not recorded", zap.String("open_token", openToken.String()))
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *pgEngagementRepo) MarkEngaged(ctx context.Context, manageToken uuid.UUID) error {
+	const q = `
+        INSERT INTO subscription_engagement (subscription_id, last_engaged_at)
+        SELECT id, now() FROM subscriptions
+        WHERE (id = $3 OR manage_token_hash = $1) AND ($2::text IS NULL OR tenant_id = $2)
+        ON CONFLICT (subscription_id) DO UPDATE SET last_engaged_at = EXCLUDED.last_engaged_at
+        RETURNING subscription_id;
+    `
+	var id int
+	if err := r.db.QueryRowContext(ctx, q, hashToken(manageToken), tenantScope(ctx),
+		tokenID(r.tokens, linktoken.Manage, manageToken)).Scan(&id); err != nil {
+		r.logger.Debug("engagement not recorded", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *pgEngagementRepo) Renew(ctx context.Context, manageToken uuid.UUID, ttl time.Duration) error {
+	const q = `
+        UPDATE subscriptions
+        SET expires_at = now() + $2 * INTERVAL '1 second'
+        WHERE (id = $4 OR manage_token_hash = $1) AND expires_at IS NOT NULL AND ($3::text IS NULL OR tenant_id = $3);
+    `
+	if _, err := r.db.ExecContext(ctx, q, hashToken(manageToken), ttl.Seconds(), tenantScope(ctx),
+		tokenID(r.tokens, linktoken.Manage, manageToken)); err != nil {
+		r.logger.Error("failed to renew subscription", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// claimLifecycle records a lifecycle email of kind for every subscription selected by
+// dueQuery (which must select subscription ids FOR UPDATE SKIP LOCKED, so concurrent
+// scheduler replicas never claim the same one) and returns the claimed subscriptions.
+func (r *pgEngagementRepo) claimLifecycle(ctx context.Context, kind, dueQuery string, args ...any,
+) ([]Subscription, error) {
+	q := `
+        WITH due AS (` + dueQuery + `
+        ), claimed AS (
+            INSERT INTO lifecycle_emails (subscription_id, kind, sent_at)
+            SELECT id, '` + kind + `', $1 FROM due
+            RETURNING subscription_id
+        )
+        SELECT ` + batchColumns + `
+        FROM subscriptions
+        WHERE id IN (SELECT subscription_id FROM claimed);
+    `
+	var subs []Subscription
+	if err := r.db.SelectContext(ctx, &subs, q, args...); err != nil {
+		r.logger.Error("failed to claim lifecycle emails", zap.String("kind", kind), zap.Error(err))
+		return nil, err
+	}
+	return decryptSubscriptions(r.pii, r.tokens, r.logger, subs), nil
+}
+
+func (r *pgEngagementRepo) ClaimAnniversaries(ctx context.Context, now time.Time, window time.Duration,
+) ([]Subscription, error) {
+	const due = `
+            SELECT s.id FROM subscriptions s
+            WHERE s.confirmed = TRUE AND s.deleted_at IS NULL
+              AND s.confirmed_at <= $1 - INTERVAL '1 year'
+              AND s.confirmed_at > $1 - INTERVAL '1 year' - $2 * INTERVAL '1 second'
+              AND s.phone = ''
+              AND NOT EXISTS (SELECT 1 FROM lifecycle_emails l
+                              WHERE l.subscription_id = s.id AND l.kind = 'anniversary')
+            FOR UPDATE SKIP LOCKED`
+	return r.claimLifecycle(ctx, LifecycleAnniversary, due, now, window.Seconds())
+}
+
+// ClaimReEngagements only considers subscribers sent updates during the whole period
+// (a delivery sent before it started), so nobody is asked right after subscribing.
+func (r *pgEngagementRepo) ClaimReEngagements(ctx context.Context, now time.Time, inactivity time.Duration,
+) ([]Subscription, error) {
+	const due = `
+            SELECT s.id FROM subscriptions s
+            WHERE s.confirmed = TRUE AND s.deleted_at IS NULL
+              AND EXISTS (SELECT 1 FROM deliveries d
+                          WHERE d.subscription_id = s.id
+                            AND d.status = 'sent' AND d.sent_at <= $1 - $2 * INTERVAL '1 second')
+              AND NOT EXISTS (SELECT 1 FROM subscription_engagement e
+                              WHERE e.subscription_id = s.id
+                                AND e.last_engaged_at > $1 - $2 * INTERVAL '1 second')
+              AND NOT EXISTS (SELECT 1 FROM lifecycle_emails l
+                              WHERE l.subscription_id = s.id AND l.kind = 're_engagement'
+                                AND l.sent_at > $1 - $2 * INTERVAL '1 second')
+            FOR UPDATE SKIP LOCKED`
+	return r.claimLifecycle(ctx, LifecycleReEngagement, due, now, inactivity.Seconds())
+}
+
+// ClaimRenewals sends one renewal email per expiry: a renewal moves expires_at past
+// the previous email, so the next expiry is due again.
+func (r *pgEngagementRepo) ClaimRenewals(ctx context.Context, now time.Time) ([]Subscription, error) {
+	const due = `
+            SELECT s.id FROM subscriptions s
+            WHERE s.confirmed = TRUE AND s.deleted_at IS NULL
+              AND s.expires_at <= $1
+              AND NOT EXISTS (SELECT 1 FROM lifecycle_emails l
+                              WHERE l.subscription_id = s.id AND l.kind = 'renewal'
+                                AND l.sent_at >= s.expires_at)
+            FOR UPDATE SKIP LOCKED`
+	return r.claimLifecycle(ctx, LifecycleRenewal, due, now)
+}
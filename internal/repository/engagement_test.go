@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+)
+
+func TestEngagementRepository_RecordOpen_UnknownToken(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewEngagementRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	token := uuid.New()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE deliveries SET opened_at = COALESCE(opened_at, now()) WHERE open_token = $1")).
+		WithArgs(token, nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.RecordOpen(context.Background(), token); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RecordOpen() error = %v, want sql.ErrNoRows", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestEngagementRepository_ClaimReEngagements(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewEngagementRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "email", "city", "frequency", "confirmed", "units",
+		"scheduled_hour", "scheduled_minute", "cities"}).
+		AddRow(3, "quiet@example.com", "Kyiv", "daily", true, "metric", 8, 15, `["Kyiv"]`)
+	// The claim is recorded in the same statement that selects the subscriptions
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"INSERT INTO lifecycle_emails (subscription_id, kind, sent_at) SELECT id, 're_engagement', $1 FROM due",
+	)).
+		WithArgs(now, float64(90*24*60*60)).
+		WillReturnRows(rows)
+
+	subs, err := repo.ClaimReEngagements(context.Background(), now, 90*24*time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimReEngagements() unexpected error: %v", err)
+	}
+	if len(subs) != 1 || subs[0].Email != "quiet@example.com" {
+		t.Fatalf("ClaimReEngagements() = %+v, want the inactive subscriber", subs)
+	}
+	// the links of the email are derived from the id
+	if subs[0].ManageToken != testTokens.Token(linktoken.Manage, 3) ||
+		subs[0].UnsubscribeToken != testTokens.Token(linktoken.Unsubscribe, 3) {
+		t.Errorf("tokens = %s, %s; want the ones derived from id 3", subs[0].ManageToken, subs[0].UnsubscribeToken)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestEngagementRepository_ClaimRenewals(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewEngagementRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	rows := sqlmock.NewRows([]string{"id", "email", "city", "frequency", "confirmed", "units",
+		"scheduled_hour", "scheduled_minute", "expires_at", "cities"}).
+		AddRow(4, "gone@example.com", "Lviv", "daily", true, "metric", 8, 0, expired, `["Lviv"]`)
+	// Only one renewal email per expiry: earlier emails predate the current expires_at
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"AND s.expires_at <= $1 AND NOT EXISTS (SELECT 1 FROM lifecycle_emails l WHERE l.subscription_id = s.id AND l.kind = 'renewal' AND l.sent_at >= s.expires_at)",
+	)).
+		WithArgs(now).
+		WillReturnRows(rows)
+
+	subs, err := repo.ClaimRenewals(context.Background(), now)
+	if err != nil {
+		t.Fatalf("ClaimRenewals() unexpected error: %v", err)
+	}
+	if len(subs) != 1 || !subs[0].ExpiresAt.Valid || !subs[0].ExpiresAt.Time.Equal(expired) {
+		t.Errorf("ClaimRenewals() = %+v, want the expired subscription", subs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Frequency mirrors the subscription_frequency Postgres enum.
+type Frequency string
+
+const (
+	FrequencyHourly Frequency = "hourly"
+	FrequencyDaily  Frequency = "daily"
+	// FrequencyAlert subscriptions get no regular updates, only an email when their
+	// conditions trip, see AlertRepository.
+	FrequencyAlert Frequency = "alert"
+	// FrequencyWarnings subscriptions get no regular updates, only new government severe
+	// weather warnings, see WarningRepository.
+	FrequencyWarnings Frequency = "warnings"
+)
+
+// ParseFrequency converts user input into a Frequency.
+func ParseFrequency(s string) (Frequency, error) {
+	f := Frequency(s)
+	if !f.Valid() {
+		return "", fmt.Errorf("invalid frequency %q", s)
+	}
+	return f, nil
+}
+
+func (f Frequency) Valid() bool {
+	switch f {
+	case FrequencyHourly, FrequencyDaily, FrequencyAlert, FrequencyWarnings:
+		return true
+	}
+	return false
+}
+
+// Value implements driver.Valuer, refusing to write anything outside the enum.
+func (f Frequency) Value() (driver.Value, error) {
+	if !f.Valid() {
+		return nil, fmt.Errorf("invalid frequency %q", string(f))
+	}
+	return string(f), nil
+}
+
+// Scan implements sql.Scanner.
+func (f *Frequency) Scan(src any) error {
+	s, err := scanEnum(src)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseFrequency(s)
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+// Channel mirrors the subscription_channel Postgres enum: how a subscription gets its
+// updates.
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	// ChannelSlack subscriptions have their updates posted to SlackWebhookURL.
+	ChannelSlack Channel = "slack"
+	// ChannelSMS subscriptions have their updates texted to Phone.
+	ChannelSMS Channel = "sms"
+)
+
+func (c Channel) Valid() bool {
+	switch c {
+	case ChannelEmail, ChannelSlack, ChannelSMS:
+		return true
+	}
+	return false
+}
+
+// Value implements driver.Valuer, refusing to write anything outside the enum.
+func (c Channel) Value() (driver.Value, error) {
+	if !c.Valid() {
+		return nil, fmt.Errorf("invalid channel %q", string(c))
+	}
+	return string(c), nil
+}
+
+// Scan implements sql.Scanner.
+func (c *Channel) Scan(src any) error {
+	s, err := scanEnum(src)
+	if err != nil {
+		return err
+	}
+	if !Channel(s).Valid() {
+		return fmt.Errorf("invalid channel %q", s)
+	}
+	*c = Channel(s)
+	return nil
+}
+
+// Units mirrors the subscription_units Postgres enum.
+type Units string
+
+const (
+	UnitsMetric   Units = "metric"
+	UnitsImperial Units = "imperial"
+)
+
+// ParseUnits converts user input into Units.
+func ParseUnits(s string) (Units, error) {
+	u := Units(s)
+	if !u.Valid() {
+		return "", fmt.Errorf("invalid units %q", s)
+	}
+	return u, nil
+}
+
+func (u Units) Valid() bool {
+	return u == UnitsMetric || u == UnitsImperial
+}
+
+// Value implements driver.Valuer, refusing to write anything outside the enum.
+func (u Units) Value() (driver.Value, error) {
+	if !u.Valid() {
+		return nil, fmt.Errorf("invalid units %q", string(u))
+	}
+	return string(u), nil
+}
+
+// Scan implements sql.Scanner.
+func (u *Units) Scan(src any) error {
+	s, err := scanEnum(src)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseUnits(s)
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+func scanEnum(src any) (string, error) {
+	switch v := src.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		return "", fmt.Errorf("cannot scan %T into enum", src)
+	}
+}
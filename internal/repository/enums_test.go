@@ -0,0 +1,22 @@
+package repository
+
+import "testing"
+
+func TestFrequency_ValueRejectsUnknown(t *testing.T) {
+	if _, err := Frequency("weekly").Value(); err == nil {
+		t.Error("Value() of unknown frequency: expected error")
+	}
+	if v, err := FrequencyDaily.Value(); err != nil || v != "daily" {
+		t.Errorf("Value() = %v, %v; want daily", v, err)
+	}
+}
+
+func TestUnits_Scan(t *testing.T) {
+	var u Units
+	if err := u.Scan([]byte("imperial")); err != nil || u != UnitsImperial {
+		t.Errorf("Scan(imperial) = %q, %v", u, err)
+	}
+	if err := u.Scan("kelvin"); err == nil {
+		t.Error("Scan(kelvin): expected error")
+	}
+}
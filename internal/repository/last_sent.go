@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// SentWeather is the weather of one city in the last update sent to a subscription.
+type SentWeather struct {
+	SubscriptionID int       `db:"subscription_id"`
+	City           string    `db:"city"` // lower-cased
+	Temp           float64   `db:"temp"`
+	Humidity       int       `db:"humidity"`
+	Description    string    `db:"description"`
+	SentAt         time.Time `db:"sent_at"`
+}
+
+// LastSentRepository remembers the weather last sent to notify-on-change subscriptions,
+// so an update can be skipped when nothing changed since.
+type LastSentRepository interface {
+	// ForSubscriptions returns the last sent weather of the subscriptions ids, by
+	// subscription and lower-cased city. Subscriptions never sent an update are missing.
+	ForSubscriptions(ctx context.Context, ids []int) (map[int]map[string]SentWeather, error)
+	// Save replaces the last sent weather of the subscriptions in sent.
+	Save(ctx context.Context, sent []SentWeather) error
+}
+
+type pgLastSentRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+func NewLastSentRepository(db *sqlx.DB, logger *zap.Logger) LastSentRepository {
+	return &pgLastSentRepo{db: db, logger: logger}
+}
+
+func (r *pgLastSentRepo) ForSubscriptions(ctx context.Context, ids []int) (map[int]map[string]SentWeather, error) {
+	out := make(map[int]map[string]SentWeather)
+	if len(ids) == 0 {
+		return out, nil
+	}
+	args := make([]int64, len(ids))
+	for i, id := range ids {
+		args[i] = int64(id)
+	}
+
+	const q = `
+        SELECT subscription_id, city, temp, humidity, description, sent_at
+        FROM last_sent_weather
+        WHERE subscription_id = ANY($1);
+    `
+	var rows []SentWeather
+	if err := r.db.SelectContext(ctx, &rows, q, args); err != nil {
+		r.logger.Error("failed to read last sent weather", zap.Int("subscriptions", len(ids)), zap.Error(err))
+		return nil, err
+	}
+	for _, w := range rows {
+		if out[w.SubscriptionID] == nil {
+			out[w.SubscriptionID] = make(map[string]SentWeather)
+		}
+		out[w.SubscriptionID][w.City] = w
+	}
+	return out, nil
+}
+
+func (r *pgLastSentRepo) Save(ctx context.Context, sent []SentWeather) error {
+	if len(sent) == 0 {
+		return nil
+	}
+	var ids []int64
+	seen := make(map[int]bool)
+	rows := make([]SentWeather, len(sent))
+	for i, w := range sent {
+		if !seen[w.SubscriptionID] {
+			seen[w.SubscriptionID] = true
+			ids = append(ids, int64(w.SubscriptionID))
+		}
+		w.City = strings.ToLower(w.City)
+		rows[i] = w
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.Error("failed to begin last sent weather transaction", zap.Error(err))
+		return err
+	}
+	defer tx.Rollback()
+
+	// cities removed from a subscription since go with the old rows
+	if _, err := tx.ExecContext(ctx, `DELETE FROM last_sent_weather WHERE subscription_id = ANY($1);`, ids); err != nil {
+		r.logger.Error("failed to clear last sent weather", zap.Error(err))
+		return err
+	}
+	const q = `
+        INSERT INTO last_sent_weather (subscription_id, city, temp, humidity, description, sent_at)
+        VALUES (:subscription_id, :city, :temp, :humidity, :description, :sent_at)
+        ON CONFLICT (subscription_id, city) DO NOTHING;
+    `
+	for start := 0; start < len(rows); start += importChunkSize {
+		end := min(start+importChunkSize, len(rows))
+		if _, err := tx.NamedExecContext(ctx, q, rows[start:end]); err != nil {
+			r.logger.Error("failed to save last sent weather", zap.Error(err))
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit last sent weather", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestLastSentRepository_Save_ReplacesSubscriptionRows(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewLastSentRepository(sqlxDB, zap.NewNop())
+
+	at := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM last_sent_weather WHERE subscription_id = ANY($1);")).
+		WithArgs([]int64{3, 4}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO last_sent_weather (subscription_id, city, temp, humidity, description, sent_at) VALUES ($1, $2, $3, $4, $5, $6),($7, $8, $9, $10, $11, $12),($13, $14, $15, $16, $17, $18)")).
+		WithArgs(3, "kyiv", 12.5, 80, "Fog", at, 3, "lviv", 10.0, 90, "Rain", at, 4, "kyiv", 12.5, 80, "Fog", at).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	err := repo.Save(context.Background(), []SentWeather{
+		{SubscriptionID: 3, City: "Kyiv", Temp: 12.5, Humidity: 80, Description: "Fog", SentAt: at},
+		{SubscriptionID: 3, City: "Lviv", Temp: 10, Humidity: 90, Description: "Rain", SentAt: at},
+		{SubscriptionID: 4, City: "Kyiv", Temp: 12.5, Humidity: 80, Description: "Fog", SentAt: at},
+	})
+	if err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestLastSentRepository_ForSubscriptions(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewLastSentRepository(sqlxDB, zap.NewNop())
+
+	at := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM last_sent_weather WHERE subscription_id = ANY($1);")).
+		WithArgs([]int64{3, 4}).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_id", "city", "temp", "humidity", "description", "sent_at"}).
+			AddRow(3, "kyiv", 12.5, 80, "Fog", at))
+
+	got, err := repo.ForSubscriptions(context.Background(), []int{3, 4})
+	if err != nil {
+		t.Fatalf("ForSubscriptions() unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[3]["kyiv"].Description != "Fog" {
+		t.Errorf("ForSubscriptions() = %+v, want the Kyiv weather of subscription 3 only", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
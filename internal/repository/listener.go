@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Notification channels published by the subscriptions triggers.
+const (
+	ChannelSubscriptionsChanged  = "subscriptions_changed"
+	ChannelSubscriptionConfirmed = "subscription_confirmed"
+)
+
+// Notification is a single Postgres NOTIFY message.
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+// NotificationHandler receives the events of a Listener.
+type NotificationHandler interface {
+	// Connected is called after every (re)connection once LISTEN is active.
+	// Notifications are not delivered until it returns, so it is the place to reload state.
+	Connected(ctx context.Context)
+	// Disconnected is called when the connection is lost; notifications may have been missed.
+	Disconnected(err error)
+	// Notify is called for every received notification, sequentially.
+	Notify(ctx context.Context, n Notification)
+}
+
+// Listener holds a dedicated Postgres connection for LISTEN, reconnecting with backoff.
+type Listener struct {
+	dsn      string
+	logger   *zap.Logger
+	handlers map[string]NotificationHandler
+}
+
+func NewListener(dsn string, logger *zap.Logger) *Listener {
+	return &Listener{dsn: dsn, logger: logger, handlers: map[string]NotificationHandler{}}
+}
+
+// Handle registers h for channel. It must be called before Run.
+func (l *Listener) Handle(channel string, h NotificationHandler) {
+	l.handlers[channel] = h
+}
+
+// Run listens on all registered channels and dispatches until ctx is cancelled.
+func (l *Listener) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := l.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		l.logger.Warn("postgres listener disconnected", zap.Duration("retryIn", backoff), zap.Error(err))
+		for _, h := range l.handlers {
+			h.Disconnected(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (l *Listener) listenOnce(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	channels := make([]string, 0, len(l.handlers))
+	for ch := range l.handlers {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{ch}.Sanitize()); err != nil {
+			return err
+		}
+		channels = append(channels, ch)
+	}
+	l.logger.Info("postgres listener connected", zap.Strings("channels", channels))
+	for _, h := range l.handlers {
+		h.Connected(ctx)
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if h, ok := l.handlers[n.Channel]; ok {
+			h.Notify(ctx, Notification{Channel: n.Channel, Payload: n.Payload})
+		}
+	}
+}
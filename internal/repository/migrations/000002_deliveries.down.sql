@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_deliveries_scheduled_for;
+DROP TABLE IF EXISTS deliveries;
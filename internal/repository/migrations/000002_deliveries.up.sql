@@ -0,0 +1,16 @@
+-- Delivery log of scheduled emails, used for SLO tracking and troubleshooting
+CREATE TABLE deliveries
+(
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER      REFERENCES subscriptions (id) ON DELETE SET NULL,
+    email           VARCHAR(255) NOT NULL,
+    city            VARCHAR(100) NOT NULL,
+    scheduled_for   TIMESTAMPTZ  NOT NULL,
+    sent_at         TIMESTAMPTZ,
+    status          VARCHAR(10)  NOT NULL
+        CHECK (status IN ('sent', 'failed')),
+    error           TEXT,
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_deliveries_scheduled_for ON deliveries (scheduled_for);
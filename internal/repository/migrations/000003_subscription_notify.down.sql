@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS subscriptions_changed ON subscriptions;
+DROP FUNCTION IF EXISTS notify_subscription_change();
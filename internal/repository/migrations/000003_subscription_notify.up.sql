@@ -0,0 +1,15 @@
+-- Publish every subscription change so the scheduler can keep its in-memory schedule fresh
+CREATE OR REPLACE FUNCTION notify_subscription_change() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_notify('subscriptions_changed',
+                      json_build_object('op', TG_OP, 'id', COALESCE(NEW.id, OLD.id))::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscriptions_changed
+    AFTER INSERT OR UPDATE OR DELETE
+    ON subscriptions
+    FOR EACH ROW
+EXECUTE FUNCTION notify_subscription_change();
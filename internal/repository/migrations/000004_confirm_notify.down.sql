@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS subscription_confirmed ON subscriptions;
+DROP FUNCTION IF EXISTS notify_subscription_confirmed();
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS welcome_sent_at,
+    DROP COLUMN IF EXISTS confirmed_at;
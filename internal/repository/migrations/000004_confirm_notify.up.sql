@@ -0,0 +1,20 @@
+-- Track when a subscription was confirmed and when its welcome email went out
+ALTER TABLE subscriptions
+    ADD COLUMN confirmed_at    TIMESTAMPTZ,
+    ADD COLUMN welcome_sent_at TIMESTAMPTZ;
+
+-- Notify the scheduler on confirmation so the first email goes out within seconds
+CREATE OR REPLACE FUNCTION notify_subscription_confirmed() RETURNS trigger AS
+$$
+BEGIN
+    PERFORM pg_notify('subscription_confirmed', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscription_confirmed
+    AFTER UPDATE OF confirmed
+    ON subscriptions
+    FOR EACH ROW
+    WHEN (OLD.confirmed = FALSE AND NEW.confirmed = TRUE)
+EXECUTE FUNCTION notify_subscription_confirmed();
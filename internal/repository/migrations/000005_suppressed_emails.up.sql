@@ -0,0 +1,9 @@
+-- Addresses that must never be emailed (hard bounces, complaints, imported lists)
+CREATE TABLE suppressed_emails
+(
+    email      VARCHAR(255) PRIMARY KEY, -- stored lower-cased
+    reason     VARCHAR(20)  NOT NULL
+        CHECK (reason IN ('bounce', 'complaint', 'manual')),
+    source     VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
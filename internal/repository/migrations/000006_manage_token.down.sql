@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS units,
+    DROP COLUMN IF EXISTS manage_token;
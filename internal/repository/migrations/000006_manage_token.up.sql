@@ -0,0 +1,5 @@
+-- Self-service management link and display units
+ALTER TABLE subscriptions
+    ADD COLUMN manage_token UUID UNIQUE NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN units        VARCHAR(10) NOT NULL DEFAULT 'metric'
+        CHECK (units IN ('metric', 'imperial'));
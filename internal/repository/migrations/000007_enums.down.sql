@@ -0,0 +1,21 @@
+DROP INDEX IF EXISTS idx_subs_hourly;
+DROP INDEX IF EXISTS idx_subs_daily;
+
+ALTER TABLE subscriptions
+    ALTER COLUMN frequency TYPE VARCHAR(10) USING frequency::text,
+    ALTER COLUMN units DROP DEFAULT,
+    ALTER COLUMN units TYPE VARCHAR(10) USING units::text,
+    ALTER COLUMN units SET DEFAULT 'metric';
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_frequency_check CHECK (frequency IN ('hourly', 'daily')),
+    ADD CONSTRAINT subscriptions_units_check CHECK (units IN ('metric', 'imperial'));
+
+DROP TYPE IF EXISTS subscription_units;
+DROP TYPE IF EXISTS subscription_frequency;
+
+CREATE INDEX idx_subs_hourly
+    ON subscriptions (scheduled_minute) WHERE confirmed = TRUE AND frequency = 'hourly';
+
+CREATE INDEX idx_subs_daily
+    ON subscriptions (scheduled_hour, scheduled_minute) WHERE confirmed = TRUE AND frequency = 'daily';
@@ -0,0 +1,23 @@
+-- Typed enums instead of VARCHAR + CHECK, so invalid values cannot be written by any code path
+CREATE TYPE subscription_frequency AS ENUM ('hourly', 'daily');
+CREATE TYPE subscription_units AS ENUM ('metric', 'imperial');
+
+-- the partial indexes' predicates reference frequency; recreate them around the type change
+DROP INDEX IF EXISTS idx_subs_hourly;
+DROP INDEX IF EXISTS idx_subs_daily;
+
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_frequency_check,
+    DROP CONSTRAINT IF EXISTS subscriptions_units_check;
+
+ALTER TABLE subscriptions
+    ALTER COLUMN frequency TYPE subscription_frequency USING frequency::subscription_frequency,
+    ALTER COLUMN units DROP DEFAULT,
+    ALTER COLUMN units TYPE subscription_units USING units::subscription_units,
+    ALTER COLUMN units SET DEFAULT 'metric';
+
+CREATE INDEX idx_subs_hourly
+    ON subscriptions (scheduled_minute) WHERE confirmed = TRUE AND frequency = 'hourly';
+
+CREATE INDEX idx_subs_daily
+    ON subscriptions (scheduled_hour, scheduled_minute) WHERE confirmed = TRUE AND frequency = 'daily';
@@ -0,0 +1,7 @@
+CREATE INDEX idx_subs_hourly
+    ON subscriptions (scheduled_minute) WHERE confirmed = TRUE AND frequency = 'hourly';
+
+CREATE INDEX idx_subs_daily
+    ON subscriptions (scheduled_hour, scheduled_minute) WHERE confirmed = TRUE AND frequency = 'daily';
+
+DROP INDEX IF EXISTS idx_subs_schedule;
@@ -0,0 +1,10 @@
+-- One covering index for both batch queries: hourly batches match the
+-- (confirmed, frequency, scheduled_minute) prefix, daily batches all four key columns.
+-- INCLUDE holds the remaining selected columns, so lookups are index-only scans.
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token);
+
+-- superseded by idx_subs_schedule
+DROP INDEX IF EXISTS idx_subs_hourly;
+DROP INDEX IF EXISTS idx_subs_daily;
@@ -0,0 +1,12 @@
+-- A subscription can track several cities; subscriptions.city stays the first (primary) one
+CREATE TABLE subscription_cities
+(
+    subscription_id INT          NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    city            VARCHAR(100) NOT NULL,
+    position        SMALLINT     NOT NULL DEFAULT 0,
+    PRIMARY KEY (subscription_id, city)
+);
+
+INSERT INTO subscription_cities (subscription_id, city, position)
+SELECT id, city, 0
+FROM subscriptions;
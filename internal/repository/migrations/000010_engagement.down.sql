@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS lifecycle_emails;
+DROP TABLE IF EXISTS subscription_engagement;
+
+DROP INDEX IF EXISTS idx_deliveries_subscription_sent;
+
+ALTER TABLE deliveries
+    DROP COLUMN IF EXISTS opened_at,
+    DROP COLUMN IF EXISTS open_token;
@@ -0,0 +1,25 @@
+-- Open tracking of scheduled emails (one tracking pixel token per delivery)
+ALTER TABLE deliveries
+    ADD COLUMN open_token UUID UNIQUE,
+    ADD COLUMN opened_at  TIMESTAMPTZ;
+
+CREATE INDEX idx_deliveries_subscription_sent ON deliveries (subscription_id, sent_at);
+
+-- Last sign of life of a subscriber: an opened email or a "keep sending" click
+CREATE TABLE subscription_engagement
+(
+    subscription_id INT PRIMARY KEY REFERENCES subscriptions (id) ON DELETE CASCADE,
+    last_engaged_at TIMESTAMPTZ NOT NULL
+);
+
+-- Lifecycle emails already sent, so each one goes out once
+CREATE TABLE lifecycle_emails
+(
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id INT         NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    kind            VARCHAR(20) NOT NULL
+        CHECK (kind IN ('anniversary', 're_engagement')),
+    sent_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_lifecycle_emails_subscription ON lifecycle_emails (subscription_id, kind, sent_at);
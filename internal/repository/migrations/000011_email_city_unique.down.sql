@@ -0,0 +1,10 @@
+-- Fails if an email holds more than one subscription
+DROP INDEX IF EXISTS idx_subs_email;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_email_key UNIQUE (email);
+
+DROP INDEX IF EXISTS uq_subscription_cities_email_city;
+
+ALTER TABLE subscription_cities
+    DROP COLUMN IF EXISTS email;
@@ -0,0 +1,20 @@
+-- One email may hold several subscriptions, but each city only once, in any letter
+-- case: uniqueness moves from subscriptions.email to (email, lower(city)) over all
+-- subscribed cities
+ALTER TABLE subscription_cities
+    ADD COLUMN email VARCHAR(255);
+
+UPDATE subscription_cities sc
+SET email = s.email
+FROM subscriptions s
+WHERE s.id = sc.subscription_id;
+
+ALTER TABLE subscription_cities
+    ALTER COLUMN email SET NOT NULL;
+
+CREATE UNIQUE INDEX uq_subscription_cities_email_city ON subscription_cities (email, lower(city));
+
+ALTER TABLE subscriptions
+    DROP CONSTRAINT subscriptions_email_key;
+
+CREATE INDEX idx_subs_email ON subscriptions (lower(email));
@@ -0,0 +1,18 @@
+-- Public changelog of the API (GET /api/changelog); deprecations also drive the
+-- Deprecation/Sunset response headers of the affected routes
+CREATE TABLE api_changes
+(
+    id           SERIAL PRIMARY KEY,
+    kind         VARCHAR(20)  NOT NULL
+        CHECK (kind IN ('added', 'changed', 'deprecated', 'removed')),
+    method       VARCHAR(10),           -- NULL: every method of path
+    path         VARCHAR(255),          -- gin route, e.g. /api/manage/:token; NULL: not route-specific
+    title        VARCHAR(200) NOT NULL,
+    description  TEXT         NOT NULL DEFAULT '',
+    link         TEXT,
+    announced_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    sunset_at    TIMESTAMPTZ,
+    CHECK (kind <> 'deprecated' OR path IS NOT NULL)
+);
+
+CREATE INDEX idx_api_changes_announced_at ON api_changes (announced_at DESC);
@@ -0,0 +1,22 @@
+-- Only works while the stored emails are plaintext (encryption never enabled)
+ALTER TABLE deliveries
+    ALTER COLUMN email TYPE VARCHAR(255);
+
+DROP INDEX IF EXISTS uq_subscription_cities_email_hash_city;
+ALTER TABLE subscription_cities
+    DROP COLUMN email_hash,
+    ADD COLUMN email VARCHAR(255);
+UPDATE subscription_cities sc
+SET email = s.email
+FROM subscriptions s
+WHERE s.id = sc.subscription_id;
+ALTER TABLE subscription_cities
+    ALTER COLUMN email SET NOT NULL;
+CREATE UNIQUE INDEX uq_subscription_cities_email_city ON subscription_cities (email, lower(city));
+
+DROP INDEX IF EXISTS idx_subs_email_hash;
+CREATE INDEX idx_subs_email ON subscriptions (lower(email));
+
+ALTER TABLE subscriptions
+    DROP COLUMN email_hash,
+    ALTER COLUMN email TYPE VARCHAR(255);
@@ -0,0 +1,30 @@
+-- Subscriber emails are encrypted by the application (see internal/pii); lookups and
+-- (email, city) uniqueness use email_hash, a keyed blind index of the address.
+-- Existing rows get the unkeyed index of encryption being disabled (pii.Cipher's
+-- SHA-256 of the trimmed, lower-cased address); cmd/pii-rekey encrypts them and
+-- replaces it with the keyed index once keys are configured.
+ALTER TABLE subscriptions
+    ALTER COLUMN email TYPE TEXT,
+    ADD COLUMN email_hash VARCHAR(64);
+UPDATE subscriptions
+SET email_hash = encode(sha256(convert_to(lower(trim(email)), 'UTF8')), 'hex');
+ALTER TABLE subscriptions
+    ALTER COLUMN email_hash SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_subs_email;
+CREATE INDEX idx_subs_email_hash ON subscriptions (email_hash);
+
+DROP INDEX IF EXISTS uq_subscription_cities_email_city;
+ALTER TABLE subscription_cities
+    ADD COLUMN email_hash VARCHAR(64);
+UPDATE subscription_cities sc
+SET email_hash = s.email_hash
+FROM subscriptions s
+WHERE s.id = sc.subscription_id;
+ALTER TABLE subscription_cities
+    DROP COLUMN email,
+    ALTER COLUMN email_hash SET NOT NULL;
+CREATE UNIQUE INDEX uq_subscription_cities_email_hash_city ON subscription_cities (email_hash, lower(city));
+
+ALTER TABLE deliveries
+    ALTER COLUMN email TYPE TEXT;
@@ -0,0 +1,4 @@
+-- Daily send hour (UTC) chosen by the subscriber on subscribe; copied into scheduled_hour
+-- on confirmation. NULL keeps scheduling at the confirmation time.
+ALTER TABLE subscriptions
+    ADD COLUMN send_hour SMALLINT CHECK (send_hour BETWEEN 0 AND 23);
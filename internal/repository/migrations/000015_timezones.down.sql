@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,10 @@
+-- Daily schedules are kept in the subscriber's IANA timezone: scheduled_hour and
+-- scheduled_minute (and send_hour) are local wall-clock times in it. Existing
+-- subscriptions were scheduled in UTC and keep exactly that schedule.
+ALTER TABLE subscriptions
+    ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+-- Daily batches match (timezone, local hour, local minute) for each timezone in use.
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token);
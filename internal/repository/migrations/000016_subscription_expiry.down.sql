@@ -0,0 +1,20 @@
+DELETE FROM lifecycle_emails WHERE kind = 'renewal';
+ALTER TABLE lifecycle_emails
+    DROP CONSTRAINT IF EXISTS lifecycle_emails_kind_check,
+    ADD CONSTRAINT lifecycle_emails_kind_check
+        CHECK (kind IN ('anniversary', 're_engagement'));
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token);
+
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token);
+
+DROP INDEX IF EXISTS idx_subs_expires_at;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS expires_at;
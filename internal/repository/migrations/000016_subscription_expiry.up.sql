@@ -0,0 +1,24 @@
+-- Optional subscription expiry (SUBSCRIPTION_TTL): no updates are sent after
+-- expires_at until the subscriber renews. NULL never expires, which keeps every
+-- existing subscription running as before.
+ALTER TABLE subscriptions
+    ADD COLUMN expires_at TIMESTAMPTZ;
+
+CREATE INDEX idx_subs_expires_at ON subscriptions (expires_at) WHERE expires_at IS NOT NULL;
+
+-- Batch queries now check expires_at; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at);
+
+-- Renewal emails are lifecycle emails, sent once per expiry
+ALTER TABLE lifecycle_emails
+    DROP CONSTRAINT IF EXISTS lifecycle_emails_kind_check,
+    ADD CONSTRAINT lifecycle_emails_kind_check
+        CHECK (kind IN ('anniversary', 're_engagement', 'renewal'));
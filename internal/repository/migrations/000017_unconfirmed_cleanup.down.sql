@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_subs_unconfirmed_created;
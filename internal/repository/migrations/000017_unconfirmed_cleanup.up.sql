@@ -0,0 +1,2 @@
+-- The cleanup job deletes subscriptions left unconfirmed since before a cutoff
+CREATE INDEX idx_subs_unconfirmed_created ON subscriptions (created_at) WHERE confirmed = FALSE;
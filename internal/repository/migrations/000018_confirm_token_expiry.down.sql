@@ -0,0 +1 @@
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS confirm_token_expires_at;
@@ -0,0 +1,6 @@
+-- Confirmation links stop working after CONFIRM_TOKEN_TTL; NULL never expires.
+-- Pending links sent before this column existed get the default 48 hours from sign-up.
+ALTER TABLE subscriptions ADD COLUMN confirm_token_expires_at TIMESTAMPTZ;
+UPDATE subscriptions
+SET confirm_token_expires_at = created_at + INTERVAL '48 hours'
+WHERE confirmed = FALSE;
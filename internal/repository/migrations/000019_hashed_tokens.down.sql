@@ -0,0 +1,11 @@
+-- Pending confirmation links stop working, their tokens were never stored. Rolling back
+-- fails once cmd/pii-rekey has encrypted the unsubscribe tokens.
+ALTER TABLE subscriptions
+    ALTER COLUMN unsubscribe_token TYPE UUID USING unsubscribe_token::uuid,
+    ALTER COLUMN unsubscribe_token SET DEFAULT gen_random_uuid(),
+    ADD CONSTRAINT subscriptions_unsubscribe_token_key UNIQUE (unsubscribe_token),
+    ADD COLUMN confirm_token UUID UNIQUE DEFAULT gen_random_uuid(),
+    DROP COLUMN IF EXISTS unsubscribe_token_hash,
+    DROP COLUMN IF EXISTS confirm_token_hash;
+
+UPDATE subscriptions SET confirm_token = NULL WHERE confirmed = TRUE;
@@ -0,0 +1,19 @@
+-- Confirm and unsubscribe links are looked up by the SHA-256 (hex) of their token, so a
+-- leaked table does not hand out working links. The confirmation token is only needed
+-- in the confirmation email and is no longer stored at all. The unsubscribe token goes
+-- into every email, so it is kept encrypted like the email (see internal/pii); existing
+-- tokens are encrypted by cmd/pii-rekey.
+ALTER TABLE subscriptions
+    ADD COLUMN confirm_token_hash     VARCHAR(64) UNIQUE,
+    ADD COLUMN unsubscribe_token_hash VARCHAR(64) UNIQUE;
+
+UPDATE subscriptions
+SET confirm_token_hash     = encode(sha256(confirm_token::text::bytea), 'hex'),
+    unsubscribe_token_hash = encode(sha256(unsubscribe_token::text::bytea), 'hex');
+
+ALTER TABLE subscriptions
+    DROP COLUMN confirm_token,
+    DROP CONSTRAINT subscriptions_unsubscribe_token_key,
+    ALTER COLUMN unsubscribe_token DROP DEFAULT,
+    ALTER COLUMN unsubscribe_token TYPE TEXT,
+    ALTER COLUMN unsubscribe_token_hash SET NOT NULL;
@@ -0,0 +1,15 @@
+-- Per-subscription opt-in to experimental email content (features in the "beta"
+-- stage of FEATURE_FLAGS). Nobody is opted in by default.
+ALTER TABLE subscriptions
+    ADD COLUMN beta_features BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Batch queries now select beta_features; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features);
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features);
+
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features);
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS locale;
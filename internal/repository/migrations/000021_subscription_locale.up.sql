@@ -0,0 +1,15 @@
+-- Language of the emails of a subscription, one of the catalogs of internal/i18n.
+-- Existing subscriptions keep getting English.
+ALTER TABLE subscriptions
+    ADD COLUMN locale TEXT NOT NULL DEFAULT 'en';
+
+-- Batch queries now select locale; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale);
@@ -0,0 +1,9 @@
+DELETE FROM suppressed_emails WHERE reason = 'not_me';
+
+ALTER TABLE suppressed_emails
+    DROP CONSTRAINT suppressed_emails_reason_check,
+    ADD CONSTRAINT suppressed_emails_reason_check
+        CHECK (reason IN ('bounce', 'complaint', 'manual'));
+
+ALTER TABLE suppressed_emails
+    DROP COLUMN IF EXISTS expires_at;
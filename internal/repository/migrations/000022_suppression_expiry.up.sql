@@ -0,0 +1,9 @@
+-- Temporary suppressions: someone whose address was signed up by a stranger can ask us
+-- ("this wasn't me") not to email it for a while. NULL expires_at suppresses forever.
+ALTER TABLE suppressed_emails
+    ADD COLUMN expires_at TIMESTAMPTZ;
+
+ALTER TABLE suppressed_emails
+    DROP CONSTRAINT suppressed_emails_reason_check,
+    ADD CONSTRAINT suppressed_emails_reason_check
+        CHECK (reason IN ('bounce', 'complaint', 'manual', 'not_me'));
@@ -0,0 +1,17 @@
+-- Optional first name given on subscribe, for personalized greetings. Encrypted like
+-- email; '' when not given.
+ALTER TABLE subscriptions
+    ADD COLUMN first_name TEXT NOT NULL DEFAULT '';
+
+-- Batch queries now select first_name and confirmed_at; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at);
@@ -0,0 +1,10 @@
+-- Hourly weather observations per city, for the temperature charts of daily emails
+CREATE TABLE weather_snapshots
+(
+    city        VARCHAR(100)     NOT NULL, -- stored lower-cased
+    observed_at TIMESTAMPTZ      NOT NULL, -- truncated to the hour
+    temp        DOUBLE PRECISION NOT NULL,
+    humidity    INT              NOT NULL,
+    description VARCHAR(255)     NOT NULL DEFAULT '',
+    PRIMARY KEY (city, observed_at)
+);
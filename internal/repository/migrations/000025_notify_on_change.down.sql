@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at);
+
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at);
+
+DROP TABLE IF EXISTS last_sent_weather;
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS notify_on_change;
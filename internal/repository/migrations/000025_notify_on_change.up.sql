@@ -0,0 +1,29 @@
+-- Per-subscription "only notify on change" mode: a weather update is skipped when the
+-- weather has not meaningfully changed since the last one sent. Off by default.
+ALTER TABLE subscriptions
+    ADD COLUMN notify_on_change BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The weather of every city of the last update sent to a notify-on-change subscription
+CREATE TABLE last_sent_weather
+(
+    subscription_id INT              NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    city            VARCHAR(100)     NOT NULL, -- stored lower-cased
+    temp            DOUBLE PRECISION NOT NULL,
+    humidity        INT              NOT NULL,
+    description     VARCHAR(255)     NOT NULL DEFAULT '',
+    sent_at         TIMESTAMPTZ      NOT NULL,
+    PRIMARY KEY (subscription_id, city)
+);
+
+-- Batch queries now select notify_on_change; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS subscription_alerts;
+
+-- an enum value cannot be dropped: rebuild the type without it
+DELETE FROM subscriptions WHERE frequency = 'alert';
+
+ALTER TYPE subscription_frequency RENAME TO subscription_frequency_old;
+CREATE TYPE subscription_frequency AS ENUM ('hourly', 'daily');
+ALTER TABLE subscriptions
+    ALTER COLUMN frequency TYPE subscription_frequency USING frequency::text::subscription_frequency;
+DROP TYPE subscription_frequency_old;
@@ -0,0 +1,15 @@
+-- Alert subscriptions get no regular updates: the scheduler evaluates their conditions
+-- every hour and emails only when one trips
+ALTER TYPE subscription_frequency ADD VALUE IF NOT EXISTS 'alert';
+
+-- The conditions of an alert subscription; at least one is set
+CREATE TABLE subscription_alerts
+(
+    subscription_id INT PRIMARY KEY REFERENCES subscriptions (id) ON DELETE CASCADE,
+    temp_above      DOUBLE PRECISION,                -- °C, NULL when not watched
+    temp_below      DOUBLE PRECISION,                -- °C
+    wind_above      DOUBLE PRECISION,                -- km/h
+    rain_expected   BOOLEAN NOT NULL DEFAULT FALSE,  -- precipitation now or likely tomorrow
+    tripped         BOOLEAN NOT NULL DEFAULT FALSE,  -- alerted, not again until the conditions clear
+    CHECK (temp_above IS NOT NULL OR temp_below IS NOT NULL OR wind_above IS NOT NULL OR rain_expected)
+);
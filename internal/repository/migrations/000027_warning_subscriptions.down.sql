@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS notified_warnings;
+
+-- an enum value cannot be dropped: rebuild the type without it
+DELETE FROM subscriptions WHERE frequency = 'warnings';
+
+ALTER TYPE subscription_frequency RENAME TO subscription_frequency_old;
+CREATE TYPE subscription_frequency AS ENUM ('hourly', 'daily', 'alert');
+ALTER TABLE subscriptions
+    ALTER COLUMN frequency TYPE subscription_frequency USING frequency::text::subscription_frequency;
+DROP TYPE subscription_frequency_old;
@@ -0,0 +1,16 @@
+-- Warning subscriptions get no regular updates, only an email for every new government
+-- severe weather warning issued for one of their cities
+ALTER TYPE subscription_frequency ADD VALUE IF NOT EXISTS 'warnings';
+
+-- The warnings each subscription was emailed, so a warning still in effect at the next
+-- check is not sent again; rows are deleted once the warning expires
+CREATE TABLE notified_warnings
+(
+    subscription_id INT         NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    warning_id      VARCHAR(64) NOT NULL,
+    notified_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (subscription_id, warning_id)
+);
+
+CREATE INDEX idx_notified_warnings_expires ON notified_warnings (expires_at);
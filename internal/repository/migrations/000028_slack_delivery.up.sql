@@ -0,0 +1,17 @@
+-- Optional Slack incoming-webhook URL: updates of the subscription are posted to the
+-- channel instead of emailed. Encrypted like email (it is a credential); '' emails them.
+ALTER TABLE subscriptions
+    ADD COLUMN slack_webhook_url TEXT NOT NULL DEFAULT '';
+
+-- Batch queries now select slack_webhook_url; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url);
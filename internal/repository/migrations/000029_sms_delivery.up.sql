@@ -0,0 +1,29 @@
+-- Optional phone number (E.164) of daily subscriptions that get their updates as SMS
+-- instead of emails. Encrypted like email; '' emails them.
+ALTER TABLE subscriptions
+    ADD COLUMN phone TEXT NOT NULL DEFAULT '';
+
+-- SMS subscriptions are confirmed with a one-time code texted to the phone, instead of
+-- the email link. Only hashes are stored; phone_hash is the blind index of the phone.
+CREATE TABLE phone_verifications (
+    subscription_id INTEGER PRIMARY KEY REFERENCES subscriptions (id) ON DELETE CASCADE,
+    phone_hash      TEXT        NOT NULL,
+    code_hash       TEXT        NOT NULL,
+    expires_at      TIMESTAMPTZ NOT NULL,
+    attempts        SMALLINT    NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_phone_verifications_phone ON phone_verifications (phone_hash);
+
+-- Batch queries now select phone; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone);
@@ -0,0 +1,23 @@
+-- The channel each subscription gets its updates through; the Notifier of the channel
+-- renders and delivers them (see internal/notify).
+CREATE TYPE subscription_channel AS ENUM ('email', 'slack', 'sms');
+
+ALTER TABLE subscriptions
+    ADD COLUMN channel subscription_channel NOT NULL DEFAULT 'email';
+
+-- Until now the channel followed from the optional webhook and phone
+UPDATE subscriptions SET channel = 'slack' WHERE slack_webhook_url <> '';
+UPDATE subscriptions SET channel = 'sms' WHERE phone <> '';
+
+-- Batch queries now select channel; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel);
@@ -0,0 +1,18 @@
+-- Durable queue of weather update sends: the scheduler tick enqueues one job per
+-- subscription of its batches, and workers in every scheduler replica claim due jobs
+-- (FOR UPDATE SKIP LOCKED) for locked_until. A worker that dies mid-batch leaves its
+-- jobs to be claimed again once the lease is over; failed sends are retried at run_at.
+CREATE TABLE send_jobs (
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER     NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    slot            TIMESTAMPTZ NOT NULL,
+    attempts        SMALLINT    NOT NULL DEFAULT 0,
+    run_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_by       TEXT,
+    locked_until    TIMESTAMPTZ,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (subscription_id, slot)
+);
+
+CREATE INDEX idx_send_jobs_run_at ON send_jobs (run_at);
@@ -0,0 +1,21 @@
+-- Consecutive failed sends of each subscription (reset by a successful one). After
+-- DEAD_LETTER_AFTER of them the subscription is dead-lettered: it gets nothing more
+-- until an operator revives it through the admin API.
+ALTER TABLE subscriptions
+    ADD COLUMN consecutive_failures SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN dead_lettered_at     TIMESTAMPTZ;
+
+-- Batch queries now filter on dead_lettered_at; keep them index-only scans
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, scheduled_hour)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, dead_lettered_at);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute)
+    INCLUDE (id, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, dead_lettered_at);
+
+CREATE INDEX idx_subs_dead_lettered ON subscriptions (dead_lettered_at) WHERE dead_lettered_at IS NOT NULL;
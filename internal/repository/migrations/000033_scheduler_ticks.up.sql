@@ -0,0 +1,7 @@
+-- The last tick each scheduler job ran, so that ticks missed while no scheduler was
+-- running are run late by the next one. Only ever moves forward.
+CREATE TABLE scheduler_ticks (
+    job        TEXT        PRIMARY KEY,
+    tick       TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS subscriptions_updated ON subscriptions;
+DROP TRIGGER IF EXISTS subscriptions_changed ON subscriptions;
+CREATE TRIGGER subscriptions_changed
+    AFTER INSERT OR UPDATE OR DELETE
+    ON subscriptions
+    FOR EACH ROW
+EXECUTE FUNCTION notify_subscription_change();
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS last_attempted_at,
+    DROP COLUMN IF EXISTS last_sent_at;
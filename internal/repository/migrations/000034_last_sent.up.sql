@@ -0,0 +1,24 @@
+-- When each subscription was last sent an update, and last tried to be. The batch
+-- queries skip subscriptions sent within the current window, so that a rerun or
+-- caught-up tick, or a restart mid-batch, does not send them again. last_sent_at is
+-- left out of the batch indexes: updated at every send, it would rule out HOT updates.
+ALTER TABLE subscriptions
+    ADD COLUMN last_sent_at      TIMESTAMPTZ,
+    ADD COLUMN last_attempted_at TIMESTAMPTZ;
+
+-- Send bookkeeping changes nothing in the schedule: do not publish it to the
+-- scheduler's in-memory schedule, a re-read per row sent
+DROP TRIGGER IF EXISTS subscriptions_changed ON subscriptions;
+CREATE TRIGGER subscriptions_changed
+    AFTER INSERT OR DELETE
+    ON subscriptions
+    FOR EACH ROW
+EXECUTE FUNCTION notify_subscription_change();
+
+CREATE TRIGGER subscriptions_updated
+    AFTER UPDATE
+    ON subscriptions
+    FOR EACH ROW
+    WHEN ((to_jsonb(OLD) - ARRAY ['last_sent_at', 'last_attempted_at', 'consecutive_failures'])
+        IS DISTINCT FROM (to_jsonb(NEW) - ARRAY ['last_sent_at', 'last_attempted_at', 'consecutive_failures']))
+EXECUTE FUNCTION notify_subscription_change();
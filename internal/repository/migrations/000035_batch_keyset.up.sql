@@ -0,0 +1,14 @@
+-- Batches are read a page at a time, keyset on id (id > $n ORDER BY id LIMIT $m): make
+-- id the last key of the batch indexes, so every page is a range scan of its slot
+-- instead of a sort of the whole slot.
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, id)
+    INCLUDE (scheduled_hour, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, dead_lettered_at);
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute, id)
+    INCLUDE (email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, dead_lettered_at);
@@ -0,0 +1,25 @@
+-- Unsubscribing no longer deletes the row: it is marked deleted_at and kept, for
+-- analytics and dispute resolution, until the scheduler purges it after
+-- DELETED_RETENTION_DAYS. Its cities are dropped on unsubscribe, so the address can
+-- subscribe to them again.
+ALTER TABLE subscriptions
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Purge lookups
+CREATE INDEX idx_subs_deleted_at ON subscriptions (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Batches only read live rows (deleted_at IS NULL is part of their active condition):
+-- leave deleted ones out of the batch indexes
+DROP INDEX IF EXISTS idx_subs_schedule;
+CREATE INDEX idx_subs_schedule
+    ON subscriptions (confirmed, frequency, scheduled_minute, id)
+    INCLUDE (scheduled_hour, email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, dead_lettered_at)
+    WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_subs_daily_tz;
+CREATE INDEX idx_subs_daily_tz
+    ON subscriptions (confirmed, frequency, timezone, scheduled_hour, scheduled_minute, id)
+    INCLUDE (email, city, units, unsubscribe_token, manage_token, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, dead_lettered_at)
+    WHERE deleted_at IS NULL;
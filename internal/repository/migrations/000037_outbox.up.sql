@@ -0,0 +1,20 @@
+-- Transactional outbox: messages (domain events) are written here by the process
+-- that produced them and relayed to their consumer by workers in every API replica,
+-- which claim pending rows (FOR UPDATE SKIP LOCKED) for locked_until. A relay that dies
+-- mid-batch leaves its messages to be claimed again once the lease is over; failed
+-- deliveries are retried at run_at. Sent messages are kept a while for tracing.
+CREATE TABLE outbox (
+    id           BIGSERIAL PRIMARY KEY,
+    topic        TEXT        NOT NULL,
+    payload      JSONB       NOT NULL,
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    run_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_by    TEXT,
+    locked_until TIMESTAMPTZ,
+    last_error   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at      TIMESTAMPTZ
+);
+
+CREATE INDEX idx_outbox_pending ON outbox (run_at, id) WHERE sent_at IS NULL;
+CREATE INDEX idx_outbox_sent_at ON outbox (sent_at) WHERE sent_at IS NOT NULL;
@@ -0,0 +1,13 @@
+-- History of the state transitions of each subscription (created, confirmed,
+-- unsubscribed, dead-lettered, revived, bounced), written by the statements making
+-- them, so that support can tell why someone stopped getting emails. It goes with
+-- the subscription when that is purged.
+CREATE TABLE subscription_events (
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER     NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    kind            TEXT        NOT NULL,
+    detail          TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_subscription_events_subscription ON subscription_events (subscription_id, id);
@@ -0,0 +1,11 @@
+-- Pending GDPR requests: the token emailed to an address proves the requester owns it,
+-- and lets them export or erase the data of the address until expires_at. Only hashes
+-- are stored, like the other tokens.
+CREATE TABLE privacy_requests (
+    token_hash TEXT PRIMARY KEY,
+    email_hash TEXT        NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_privacy_requests_expires_at ON privacy_requests (expires_at);
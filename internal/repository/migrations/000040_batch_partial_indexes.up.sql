@@ -0,0 +1,7 @@
+-- A batch only reads the subscriptions of one frequency that may be emailed, yet the
+-- batch indexes held every live row, unconfirmed and dead-lettered ones included, led
+-- by (confirmed, frequency). They are replaced with one partial index per frequency
+-- holding only the subscriptions the batches read, built concurrently by the following
+-- migrations (a concurrent build cannot share a migration with other statements).
+DROP INDEX IF EXISTS idx_subs_schedule;
+DROP INDEX IF EXISTS idx_subs_daily_tz;
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS subscriptions_updated ON subscriptions;
+CREATE TRIGGER subscriptions_updated
+    AFTER UPDATE
+    ON subscriptions
+    FOR EACH ROW
+    WHEN ((to_jsonb(OLD) - ARRAY ['last_sent_at', 'last_attempted_at', 'consecutive_failures'])
+        IS DISTINCT FROM (to_jsonb(NEW) - ARRAY ['last_sent_at', 'last_attempted_at', 'consecutive_failures']))
+EXECUTE FUNCTION notify_subscription_change();
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS send_claimed_until;
@@ -0,0 +1,17 @@
+-- Overlapping runs (a rerun or caught-up tick, a replay, two replicas across a leader
+-- handover) could select the same subscription before either recorded its send. A
+-- batch now claims its subscriptions before sending (UPDATE ... RETURNING): the claim
+-- holds until send_claimed_until, is released when the send is recorded, and a claimed
+-- subscription cannot be claimed by another batch meanwhile.
+ALTER TABLE subscriptions
+    ADD COLUMN send_claimed_until TIMESTAMPTZ;
+
+-- Claims are send bookkeeping: do not publish them to the in-memory schedule either
+DROP TRIGGER IF EXISTS subscriptions_updated ON subscriptions;
+CREATE TRIGGER subscriptions_updated
+    AFTER UPDATE
+    ON subscriptions
+    FOR EACH ROW
+    WHEN ((to_jsonb(OLD) - ARRAY ['last_sent_at', 'last_attempted_at', 'consecutive_failures', 'send_claimed_until'])
+        IS DISTINCT FROM (to_jsonb(NEW) - ARRAY ['last_sent_at', 'last_attempted_at', 'consecutive_failures', 'send_claimed_until']))
+EXECUTE FUNCTION notify_subscription_change();
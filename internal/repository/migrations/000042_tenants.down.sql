@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_subs_tenant_email_hash;
+
+-- fails while an address is subscribed to a city in several tenants
+DROP INDEX IF EXISTS uq_subscription_cities_tenant_email_hash_city;
+CREATE UNIQUE INDEX uq_subscription_cities_email_hash_city ON subscription_cities (email_hash, lower(city));
+
+ALTER TABLE privacy_requests
+    DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE subscription_cities
+    DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,17 @@
+-- One deployment may serve several white-label newsletters (tenants) whose data is
+-- isolated: every subscription belongs to one, existing ones to the default tenant.
+-- An address may subscribe to the same city once per tenant, and a privacy request
+-- only reaches the data of the tenant it was made on.
+ALTER TABLE subscriptions
+    ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE subscription_cities
+    ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE privacy_requests
+    ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+
+DROP INDEX IF EXISTS uq_subscription_cities_email_hash_city;
+CREATE UNIQUE INDEX uq_subscription_cities_tenant_email_hash_city
+    ON subscription_cities (tenant_id, email_hash, lower(city));
+
+-- Lookups by address (manage, privacy, admin) are scoped to a tenant
+CREATE INDEX idx_subs_tenant_email_hash ON subscriptions (tenant_id, email_hash);
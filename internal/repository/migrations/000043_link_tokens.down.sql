@@ -0,0 +1,13 @@
+-- Every subscription gets new random tokens, so every link emailed so far stops working.
+-- The unsubscribe tokens are stored as plaintext until cmd/pii-rekey encrypts them.
+ALTER TABLE subscriptions
+    ADD COLUMN unsubscribe_token TEXT NOT NULL DEFAULT gen_random_uuid()::text,
+    ADD COLUMN manage_token UUID UNIQUE NOT NULL DEFAULT gen_random_uuid();
+
+UPDATE subscriptions
+SET unsubscribe_token_hash = encode(sha256(convert_to(unsubscribe_token, 'UTF8')), 'hex');
+
+ALTER TABLE subscriptions
+    ALTER COLUMN unsubscribe_token DROP DEFAULT,
+    ALTER COLUMN unsubscribe_token_hash SET NOT NULL,
+    DROP COLUMN IF EXISTS manage_token_hash;
@@ -0,0 +1,17 @@
+-- Unsubscribe and manage links are derived from the subscription id with a keyed HMAC
+-- (LINK_TOKEN_KEY, see internal/linktoken), so no token anyone could rebuild a link
+-- from is stored any more: neither the unsubscribe token, kept as plaintext unless
+-- PII_ENCRYPTION_KEYS was set, nor the manage token, always kept as plaintext. Links
+-- already emailed carry random tokens: they keep working through the SHA-256 of their
+-- token, like confirmation links. Subscriptions created from now on have no such hash.
+ALTER TABLE subscriptions
+    ADD COLUMN manage_token_hash VARCHAR(64);
+
+UPDATE subscriptions
+SET manage_token_hash = encode(sha256(convert_to(manage_token::text, 'UTF8')), 'hex');
+
+-- drops the unique index of manage_token with it; 000044 indexes manage_token_hash instead
+ALTER TABLE subscriptions
+    DROP COLUMN unsubscribe_token,
+    DROP COLUMN manage_token,
+    ALTER COLUMN unsubscribe_token_hash DROP NOT NULL;
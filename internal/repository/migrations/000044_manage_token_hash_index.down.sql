@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_subs_manage_token_hash;
@@ -0,0 +1,2 @@
+-- Legacy manage links are looked up by the hash of their token
+CREATE UNIQUE INDEX CONCURRENTLY idx_subs_manage_token_hash ON subscriptions (manage_token_hash);
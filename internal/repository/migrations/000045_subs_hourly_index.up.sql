@@ -0,0 +1,9 @@
+-- The hourly batch: subscriptions that may be emailed, keyed by the slot and id and
+-- covering the columns the batch reads, but last_sent_at: it changes with every send,
+-- and an indexed column would make each of those updates rewrite the index entries.
+-- Built without blocking writes; a failed build leaves an invalid index to drop by hand.
+CREATE INDEX CONCURRENTLY idx_subs_hourly
+    ON subscriptions (scheduled_minute, id)
+    INCLUDE (frequency, confirmed, scheduled_hour, timezone, email, city, units, expires_at, beta_features, locale,
+             first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, tenant_id)
+    WHERE confirmed = TRUE AND frequency = 'hourly' AND dead_lettered_at IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- The daily batch: like idx_subs_hourly, keyed by the local delivery time
+CREATE INDEX CONCURRENTLY idx_subs_daily
+    ON subscriptions (timezone, scheduled_hour, scheduled_minute, id)
+    INCLUDE (frequency, confirmed, email, city, units, expires_at, beta_features, locale, first_name, confirmed_at,
+             notify_on_change, slack_webhook_url, phone, channel, tenant_id)
+    WHERE confirmed = TRUE AND frequency = 'daily' AND dead_lettered_at IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,4 @@
+-- Alert checks join subscription_alerts: the slot lookup needs no covering columns
+CREATE INDEX CONCURRENTLY idx_subs_alert
+    ON subscriptions (scheduled_minute, id)
+    WHERE confirmed = TRUE AND frequency = 'alert' AND dead_lettered_at IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- Entries still queued are most likely sent by now
+UPDATE deliveries SET status = 'sent', sent_at = created_at WHERE status = 'queued';
+
+ALTER TABLE deliveries
+    DROP CONSTRAINT deliveries_status_check,
+    ADD CONSTRAINT deliveries_status_check CHECK (status IN ('sent', 'failed'));
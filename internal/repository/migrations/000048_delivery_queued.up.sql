@@ -0,0 +1,4 @@
+-- Emails handed to the mail queue are logged as queued until its worker sends them
+ALTER TABLE deliveries
+    DROP CONSTRAINT deliveries_status_check,
+    ADD CONSTRAINT deliveries_status_check CHECK (status IN ('sent', 'failed', 'queued'));
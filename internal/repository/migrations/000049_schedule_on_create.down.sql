@@ -0,0 +1,2 @@
+-- Confirm sets the slot again: unconfirmed subscriptions are back to the defaults
+UPDATE subscriptions SET scheduled_hour = 0, scheduled_minute = 0 WHERE confirmed = FALSE;
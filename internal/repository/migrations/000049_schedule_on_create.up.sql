@@ -0,0 +1,7 @@
+-- The schedule slot is fixed when a subscription is created instead of on confirmation:
+-- give the subscriptions awaiting confirmation the slot of their creation time.
+UPDATE subscriptions
+SET scheduled_hour   = COALESCE(send_hour, EXTRACT(HOUR FROM created_at AT TIME ZONE timezone)::smallint),
+    scheduled_minute = CASE WHEN frequency = 'hourly' THEN EXTRACT(MINUTE FROM created_at)::smallint
+                            ELSE EXTRACT(MINUTE FROM created_at AT TIME ZONE timezone)::smallint END
+WHERE confirmed = FALSE;
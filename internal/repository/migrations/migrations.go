@@ -0,0 +1,203 @@
+// Package migrations holds the database schema migrations, embedded in the binaries,
+// and applies them with golang-migrate. Migrations are numbered NNNNNN_name.up.sql /
+// .down.sql pairs; the version applied is kept in schema_migrations, so a database
+// migrated by the golang-migrate CLI carries on from where it is.
+//
+// A migration file runs as one multi-statement query, so in one implicit transaction:
+// statements that cannot run in a transaction (CREATE INDEX CONCURRENTLY) go alone in
+// their own migration.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v5/stdlib" // the "pgx" database/sql driver
+	"go.uber.org/zap"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockTimeout bounds the wait for another process migrating the same database (the
+// advisory lock of golang-migrate), e.g. API replicas starting together while one
+// builds an index.
+const lockTimeout = 30 * time.Minute
+
+// Source returns the embedded migrations as a golang-migrate source.
+func Source() (source.Driver, error) {
+	return iofs.New(files, ".")
+}
+
+// Versions returns the versions of the embedded migrations, oldest first.
+func Versions() ([]uint, error) {
+	src, err := Source()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	var versions []uint
+	v, err := src.First()
+	for err == nil {
+		versions = append(versions, v)
+		v, err = src.Next(v)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Migrator applies and reverts the embedded migrations on a database.
+type Migrator struct {
+	m        *migrate.Migrate
+	versions []uint
+}
+
+// New returns a migrator of the database at dsn, on a connection of its own, which
+// Close closes.
+func New(dsn string, logger *zap.Logger) (*Migrator, error) {
+	versions, err := Versions()
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+	src, err := Source()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{})
+	if err != nil {
+		src.Close()
+		db.Close()
+		return nil, err
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "pgx5", driver)
+	if err != nil {
+		src.Close()
+		driver.Close()
+		return nil, err
+	}
+	m.Log = zapLogger{logger}
+	m.LockTimeout = lockTimeout
+	return &Migrator{m: m, versions: versions}, nil
+}
+
+// Close closes the connection of the migrator.
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// Latest is the version of the newest embedded migration.
+func (m *Migrator) Latest() uint {
+	if len(m.versions) == 0 {
+		return 0
+	}
+	return m.versions[len(m.versions)-1]
+}
+
+// Version returns the version the database is at (0: none applied), and whether the
+// migration to it failed halfway.
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Up applies every migration above the version of the database, oldest first, and
+// returns how many it applied. It refuses to run on a dirty database.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	before, _, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer m.stopOn(ctx)()
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return m.between(ctx, before), err
+	}
+	return m.between(ctx, before), nil
+}
+
+// Down reverts the steps newest migrations applied, newest first, and returns how many
+// it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	before, _, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer m.stopOn(ctx)()
+	err = m.m.Steps(-steps)
+	var short migrate.ErrShortLimit
+	if errors.As(err, &short) || errors.Is(err, migrate.ErrNoChange) {
+		err = nil // fewer migrations were applied than asked to revert
+	}
+	return m.between(ctx, before), err
+}
+
+// Force records version as applied, and clean: for after a failed migration was fixed
+// (or reverted) by hand.
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version == 0 {
+		return m.m.Force(-1) // none applied
+	}
+	return m.m.Force(int(version))
+}
+
+// between counts the embedded migrations between version and the version the database
+// is at now, either way.
+func (m *Migrator) between(ctx context.Context, version uint) int {
+	now, _, err := m.Version(ctx)
+	if err != nil {
+		return 0
+	}
+	lo, hi := min(version, now), max(version, now)
+	n := 0
+	for _, v := range m.versions {
+		if v > lo && v <= hi {
+			n++
+		}
+	}
+	return n
+}
+
+// stopOn asks golang-migrate to stop after the migration it is running once ctx is
+// done; the returned func ends the watch.
+func (m *Migrator) stopOn(ctx context.Context) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.m.GracefulStop <- true
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// zapLogger logs the migrations golang-migrate runs.
+type zapLogger struct {
+	logger *zap.Logger
+}
+
+func (l zapLogger) Printf(format string, v ...any) {
+	l.logger.Info("migration", zap.String("migrate", strings.TrimSpace(fmt.Sprintf(format, v...))))
+}
+
+func (l zapLogger) Verbose() bool { return false }
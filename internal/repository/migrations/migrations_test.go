@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestVersions_Consecutive(t *testing.T) {
+	versions, err := Versions()
+	if err != nil {
+		t.Fatalf("Versions() error: %v", err)
+	}
+	if len(versions) == 0 {
+		t.Fatal("Versions() returned no migrations")
+	}
+	for i, v := range versions {
+		if v != uint(i+1) {
+			t.Errorf("migration %d is version %d, want %d", i, v, i+1)
+		}
+	}
+}
+
+func TestSource_UpAndDownOfEveryVersion(t *testing.T) {
+	versions, err := Versions()
+	if err != nil {
+		t.Fatalf("Versions() error: %v", err)
+	}
+	src, err := Source()
+	if err != nil {
+		t.Fatalf("Source() error: %v", err)
+	}
+	defer src.Close()
+	for _, v := range versions {
+		for _, dir := range []string{"up", "down"} {
+			read := src.ReadUp
+			if dir == "down" {
+				read = src.ReadDown
+			}
+			r, name, err := read(v)
+			if err != nil {
+				t.Errorf("version %d has no %s migration: %v", v, dir, err)
+				continue
+			}
+			body, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || strings.TrimSpace(string(body)) == "" {
+				t.Errorf("%s migration %d (%s) is empty: %v", dir, v, name, err)
+			}
+			// CONCURRENTLY cannot run inside the implicit transaction of a multi-statement file
+			if sql := withoutComments(string(body)); strings.Contains(sql, "CONCURRENTLY") && strings.Count(sql, ";") > 1 {
+				t.Errorf("%s migration %d (%s) builds an index concurrently next to other statements", dir, v, name)
+			}
+		}
+	}
+}
+
+// withoutComments returns sql without its -- comment lines.
+func withoutComments(sql string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository/queries"
+)
+
+// OutboxMessage is a message waiting in the outbox to be relayed to its consumer.
+type OutboxMessage struct {
+	ID        int64          `db:"id"`
+	Topic     string         `db:"topic"`
+	Payload   []byte         `db:"payload"`  // JSON
+	Attempts  int            `db:"attempts"` // including the one claimed
+	CreatedAt time.Time      `db:"created_at"`
+	LastError sql.NullString `db:"last_error"`
+}
+
+// OutboxRepository is the durable queue of outgoing messages. Relays in every replica
+// share it: messages are claimed for a lease, so each is delivered by one relay at a
+// time, and again only when its delivery failed or its relay died before MarkSent
+// (delivery is at least once, exactly once unless a relay dies mid-delivery).
+type OutboxRepository interface {
+	// EnqueueMessage adds a message, a JSON document, for topic and returns its id.
+	EnqueueMessage(ctx context.Context, topic string, payload []byte) (int64, error)
+	// ClaimPending locks up to limit due messages, oldest first, for worker until lease
+	// is over, counting an attempt on each. Messages locked by another worker are
+	// skipped; messages whose lease ran out are due again.
+	ClaimPending(ctx context.Context, worker string, limit int, lease time.Duration) ([]OutboxMessage, error)
+	// MarkSent records that messages were delivered: they are never claimed again.
+	MarkSent(ctx context.Context, ids []int64) error
+	// Retry unlocks a message to be claimed again at runAt, remembering why it failed.
+	Retry(ctx context.Context, id int64, runAt time.Time, reason string) error
+	// PurgeSent deletes the messages sent before cutoff and returns how many it deleted.
+	PurgeSent(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type pgOutboxRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+func NewOutboxRepository(db *sqlx.DB, logger *zap.Logger) OutboxRepository {
+	return &pgOutboxRepo{db: db, logger: logger}
+}
+
+const enqueueMessageQuery = `INSERT INTO outbox (topic, payload) VALUES ($1, $2) RETURNING id;`
+
+func (r *pgOutboxRepo) EnqueueMessage(ctx context.Context, topic string, payload []byte) (int64, error) {
+	var id int64
+	if err := r.db.GetContext(ctx, &id, enqueueMessageQuery, topic, string(payload)); err != nil {
+		r.logger.Error("failed to enqueue outbox message", zap.String("topic", topic), zap.Error(err))
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *pgOutboxRepo) ClaimPending(ctx context.Context, worker string, limit int, lease time.Duration,
+) ([]OutboxMessage, error) {
+	const q = `
+        UPDATE outbox
+        SET locked_by = $1, locked_until = now() + $2 * interval '1 second', attempts = attempts + 1
+        WHERE id IN (
+            SELECT id FROM outbox
+            WHERE sent_at IS NULL AND run_at <= now() AND (locked_until IS NULL OR locked_until < now())
+            ORDER BY run_at, id
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, topic, payload, attempts, created_at, last_error;
+    `
+	var msgs []OutboxMessage
+	if err := r.db.SelectContext(ctx, &msgs, q, worker, int64(lease.Seconds()), limit); err != nil {
+		r.logger.Error("failed to claim outbox messages", zap.String("worker", worker), zap.Error(err))
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *pgOutboxRepo) MarkSent(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	const q = `
+        UPDATE outbox
+        SET sent_at = now(), locked_by = NULL, locked_until = NULL, last_error = NULL
+        WHERE id = ANY($1);
+    `
+	if _, err := r.db.ExecContext(ctx, q, ids); err != nil {
+		r.logger.Error("failed to mark outbox messages sent", zap.Int("count", len(ids)), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *pgOutboxRepo) Retry(ctx context.Context, id int64, runAt time.Time, reason string) error {
+	const q = `
+        UPDATE outbox
+        SET run_at = $2, locked_by = NULL, locked_until = NULL, last_error = $3
+        WHERE id = $1;
+    `
+	if _, err := r.db.ExecContext(ctx, q, id, runAt, reason); err != nil {
+		r.logger.Error("failed to reschedule outbox message", zap.Int64("id", id), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *pgOutboxRepo) PurgeSent(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at < $1;`, cutoff)
+	if err != nil {
+		r.logger.Error("failed to purge sent outbox messages", zap.Time("cutoff", cutoff), zap.Error(err))
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("failed to get rows affected on outbox purge", zap.Error(err))
+		return 0, err
+	}
+	return n, nil
+}
+
+// OutboxEvent is the outbox message reporting a change of a subscription. Attached to
+// the context of the change (WithOutboxEvent), it is written in the transaction of the
+// change, so that the event is stored if and only if the change is.
+type OutboxEvent struct {
+	// Build returns the topic and payload reporting the change of subscription id. An
+	// error rolls the change back.
+	Build func(id int) (topic string, payload []byte, err error)
+
+	written bool
+}
+
+// Written reports whether the event was committed with its change.
+func (e *OutboxEvent) Written() bool {
+	return e.written
+}
+
+type outboxEventKey struct{}
+
+// WithOutboxEvent returns ctx carrying e, written by the first change of a subscription
+// made with ctx that reports events: Create, Confirm, DeleteByUnsubToken,
+// DeleteUnconfirmed, the alert conditions set by SetConditions and the phone Verify.
+func WithOutboxEvent(ctx context.Context, e *OutboxEvent) context.Context {
+	return context.WithValue(ctx, outboxEventKey{}, e)
+}
+
+// outboxEvent returns the event of ctx still to be written, or nil.
+func outboxEvent(ctx context.Context) *OutboxEvent {
+	if e, _ := ctx.Value(outboxEventKey{}).(*OutboxEvent); e != nil && !e.written {
+		return e
+	}
+	return nil
+}
+
+// write writes e in tx, the transaction changing subscription id; e may be nil.
+func (e *OutboxEvent) write(ctx context.Context, tx *sqlx.Tx, id int) error {
+	if e == nil {
+		return nil
+	}
+	topic, payload, err := e.Build(id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, enqueueMessageQuery, topic, string(payload))
+	return err
+}
+
+// committed records that the transaction writing e committed; e may be nil.
+func (e *OutboxEvent) committed() {
+	if e != nil {
+		e.written = true
+	}
+}
+
+// outboxDB is what withOutboxEvent runs a change on: db, or the transaction writing
+// the outbox event. Queries built in Go run on it as well as the generated ones.
+type outboxDB interface {
+	sqlx.ExtContext
+	queries.DBTX
+}
+
+// withOutboxEvent runs change, which returns the id of the subscription it changed,
+// and writes the outbox event of ctx in the same transaction. Without an event change
+// runs on db, outside a transaction.
+func withOutboxEvent(ctx context.Context, db *sqlx.DB, logger *zap.Logger,
+	change func(db outboxDB) (int, error),
+) (int, error) {
+	e := outboxEvent(ctx)
+	if e == nil {
+		return change(db)
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to begin transaction", zap.Error(err))
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := change(tx)
+	if err != nil {
+		return id, err
+	}
+	if err := e.write(ctx, tx, id); err != nil {
+		logger.Error("failed to write outbox event", zap.Int("id", id), zap.Error(err))
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("failed to commit change with its outbox event", zap.Int("id", id), zap.Error(err))
+		return 0, err
+	}
+	e.committed()
+	return id, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestOutboxRepository_EnqueueMessage(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewOutboxRepository(sqlxDB, zap.NewNop())
+
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO outbox (topic, payload) VALUES ($1, $2) RETURNING id;")).
+		WithArgs("subscription.confirmed", `{"id":"e1"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+
+	id, err := repo.EnqueueMessage(context.Background(), "subscription.confirmed", []byte(`{"id":"e1"}`))
+	if err != nil || id != 11 {
+		t.Errorf("EnqueueMessage() = %d, %v; want 11, nil", id, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestOutboxRepository_ClaimPending(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewOutboxRepository(sqlxDB, zap.NewNop())
+
+	created := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`UPDATE outbox SET locked_by = \$1, locked_until = now\(\) \+ \$2 \* interval '1 second', attempts = attempts \+ 1 WHERE id IN \( SELECT id FROM outbox WHERE sent_at IS NULL .* FOR UPDATE SKIP LOCKED \)`).
+		WithArgs("api-1", int64(60), 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "payload", "attempts", "created_at", "last_error"}).
+			AddRow(11, "subscription.confirmed", []byte(`{"id":"e1"}`), 2, created, "timeout"))
+
+	msgs, err := repo.ClaimPending(context.Background(), "api-1", 50, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimPending() unexpected error: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].ID != 11 || string(msgs[0].Payload) != `{"id":"e1"}` || msgs[0].Attempts != 2 ||
+		msgs[0].LastError.String != "timeout" {
+		t.Errorf("ClaimPending() = %+v", msgs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestOutboxRepository_MarkSent(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewOutboxRepository(sqlxDB, zap.NewNop())
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE outbox SET sent_at = now(), locked_by = NULL, locked_until = NULL, last_error = NULL WHERE id = ANY($1);")).
+		WithArgs([]int64{11, 12}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := repo.MarkSent(context.Background(), []int64{11, 12}); err != nil {
+		t.Errorf("MarkSent() unexpected error: %v", err)
+	}
+	if err := repo.MarkSent(context.Background(), nil); err != nil {
+		t.Errorf("MarkSent(nil) unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestOutboxEvent_WrittenWithItsChange(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		enqueue  error
+		wantErr  bool
+		wantSent bool
+	}{
+		{"committed", nil, false, true},
+		{"outbox insert fails", errors.New("disk full"), true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlxDB, mock, cleanup := setupMockDB(t)
+			defer cleanup()
+			repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+			event := &OutboxEvent{Build: func(id int) (string, []byte, error) {
+				return "subscription.confirmed", []byte(fmt.Sprintf(`{"subscription_id":%d}`, id)), nil
+			}}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO subscription_events (subscription_id, kind, detail) SELECT id, 'confirmed', $5 FROM confirmed")).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			insert := mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox (topic, payload) VALUES ($1, $2)")).
+				WithArgs("subscription.confirmed", `{"subscription_id":7}`)
+			if tc.enqueue != nil {
+				insert.WillReturnError(tc.enqueue)
+				mock.ExpectRollback()
+			} else {
+				insert.WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			}
+
+			_, err := repo.Confirm(WithOutboxEvent(context.Background(), event), uuid.New(), 0)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Confirm() error = %v, want error %t", err, tc.wantErr)
+			}
+			if event.Written() != tc.wantSent {
+				t.Errorf("Written() = %t, want %t", event.Written(), tc.wantSent)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
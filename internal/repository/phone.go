@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository/queries"
+)
+
+// MaxCodeAttempts is how many wrong codes a pending phone verification takes before it
+// is locked; the subscription is then left to the cleanup of unconfirmed ones.
+const MaxCodeAttempts = 5
+
+// ErrInvalidCode is returned by Verify when no pending verification of the phone has the
+// code, or it expired or was locked.
+var ErrInvalidCode = errors.New("invalid or expired code")
+
+// PhoneRepository confirms SMS subscriptions with a one-time code texted to their phone.
+type PhoneRepository interface {
+	// StartVerification sets the phone of the unconfirmed subscription id, moving it to
+	// ChannelSMS, and stores the code that confirms it, valid until expiresAt.
+	StartVerification(ctx context.Context, id int, phone, code string, expiresAt time.Time) error
+	// Verify confirms the unconfirmed subscription of phone that code was texted for and
+	// returns its id. A wrong code counts as an attempt against each pending
+	// verification of the phone on its own, so one subscription locks after
+	// MaxCodeAttempts without touching the counts of the others.
+	Verify(ctx context.Context, phone, code string) (int, error)
+}
+
+type pgPhoneRepo struct {
+	db     *sqlx.DB
+	pii    *pii.Cipher
+	logger *zap.Logger
+}
+
+// NewPhoneRepository stores phones encrypted with cipher (nil stores them as plaintext).
+func NewPhoneRepository(db *sqlx.DB, cipher *pii.Cipher, logger *zap.Logger) PhoneRepository {
+	return &pgPhoneRepo{db: db, pii: cipher, logger: logger}
+}
+
+func (r *pgPhoneRepo) StartVerification(ctx context.Context, id int, phone, code string, expiresAt time.Time) error {
+	const q = `
+        WITH s AS (
+            UPDATE subscriptions SET phone = $2, channel = 'sms' WHERE id = $1 AND confirmed = FALSE AND deleted_at IS NULL RETURNING id
+        )
+        INSERT INTO phone_verifications (subscription_id, phone_hash, code_hash, expires_at)
+        SELECT id, $3, $4, $5 FROM s
+        ON CONFLICT (subscription_id) DO UPDATE
+            SET code_hash = EXCLUDED.code_hash, expires_at = EXCLUDED.expires_at, attempts = 0;
+    `
+	stored, err := r.pii.Encrypt(phone)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, q, id, stored, r.pii.BlindIndex(phone), hashCode(id, code), expiresAt)
+	if err != nil {
+		r.logger.Error("failed to store phone verification", zap.Int("id", id), zap.Error(err))
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type pendingCode struct {
+	SubscriptionID int    `db:"subscription_id"`
+	CodeHash       string `db:"code_hash"`
+}
+
+// Verify confirms the subscription like Confirm, except that SMS subscriptions never
+// expire: the renewal link is emailed, and their email address is not verified.
+func (r *pgPhoneRepo) Verify(ctx context.Context, phone, code string) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.Error("failed to begin phone verification transaction", zap.Error(err))
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	const pendingQ = `
+        SELECT subscription_id, code_hash
+        FROM phone_verifications
+        WHERE phone_hash = $1 AND expires_at > now() AND attempts < $2
+        FOR UPDATE;
+    `
+	phoneHash := r.pii.BlindIndex(phone)
+	var pending []pendingCode
+	if err := tx.SelectContext(ctx, &pending, pendingQ, phoneHash, MaxCodeAttempts); err != nil {
+		r.logger.Error("failed to read phone verifications", zap.Error(err))
+		return 0, err
+	}
+	id := 0
+	for _, p := range pending {
+		if p.CodeHash == hashCode(p.SubscriptionID, code) {
+			id = p.SubscriptionID
+			break
+		}
+	}
+	if id == 0 {
+		if len(pending) > 0 {
+			ids := make([]int, len(pending))
+			for i, p := range pending {
+				ids[i] = p.SubscriptionID
+			}
+			const attemptQ = `UPDATE phone_verifications SET attempts = attempts + 1 WHERE subscription_id = ANY($1);`
+			if _, err := tx.ExecContext(ctx, attemptQ, int64s(ids)); err != nil {
+				r.logger.Error("failed to count phone code attempt", zap.Error(err))
+				return 0, err
+			}
+			if err := tx.Commit(); err != nil {
+				return 0, err
+			}
+		}
+		return 0, ErrInvalidCode
+	}
+
+	// a subscription confirmed meanwhile stays as it is
+	_, err = queries.New(tx).ConfirmSubscription(ctx, queries.ConfirmSubscriptionParams{
+		ID:     sql.NullInt32{Int32: int32(id), Valid: true},
+		Detail: "sms",
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		r.logger.Error("failed to confirm sms subscription", zap.Int("id", id), zap.Error(err))
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM phone_verifications WHERE subscription_id = $1;`, id); err != nil {
+		r.logger.Error("failed to delete phone verification", zap.Int("id", id), zap.Error(err))
+		return 0, err
+	}
+	event := outboxEvent(ctx)
+	if err := event.write(ctx, tx, id); err != nil {
+		r.logger.Error("failed to write outbox event", zap.Int("id", id), zap.Error(err))
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit phone verification", zap.Error(err))
+		return 0, err
+	}
+	event.committed()
+	r.logger.Info("sms subscription confirmed", zap.Int("id", id))
+	return id, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestPhoneRepository_StartVerification(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewPhoneRepository(sqlxDB, nil, zap.NewNop())
+
+	expires := time.Date(2026, 10, 16, 7, 10, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO phone_verifications (subscription_id, phone_hash, code_hash, expires_at) SELECT id, $3, $4, $5 FROM s")).
+		WithArgs(7, "+380501234567", sqlmock.AnyArg(), hashCode(7, "123456"), expires).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.StartVerification(context.Background(), 7, "+380501234567", "123456", expires); err != nil {
+		t.Fatalf("StartVerification() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPhoneRepository_Verify(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewPhoneRepository(sqlxDB, nil, zap.NewNop())
+
+	pending := sqlmock.NewRows([]string{"subscription_id", "code_hash"}).
+		AddRow(7, hashCode(7, "123456")).
+		AddRow(8, hashCode(8, "654321"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM phone_verifications WHERE phone_hash = $1 AND expires_at > now() AND attempts < $2 FOR UPDATE")).
+		WithArgs(sqlmock.AnyArg(), MaxCodeAttempts).
+		WillReturnRows(pending)
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE (subscriptions.id = $2::integer OR (confirm_token_hash = $3::text")).
+		WithArgs(float64(0), 8, nil, nil, "sms").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM phone_verifications WHERE subscription_id = $1;")).
+		WithArgs(8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	id, err := repo.Verify(context.Background(), "+380501234567", "654321")
+	if err != nil || id != 8 {
+		t.Fatalf("Verify() = %d, %v; want 8, nil", id, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPhoneRepository_Verify_WrongCodeCountsAttempt(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewPhoneRepository(sqlxDB, nil, zap.NewNop())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM phone_verifications")).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_id", "code_hash"}).
+			AddRow(7, hashCode(7, "123456")).
+			AddRow(9, hashCode(9, "654321")))
+	// only the pending verifications count it, each against its own subscription
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE phone_verifications SET attempts = attempts + 1 WHERE subscription_id = ANY($1);")).
+		WithArgs([]int64{7, 9}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if _, err := repo.Verify(context.Background(), "+380501234567", "000000"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Verify() error = %v, want ErrInvalidCode", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
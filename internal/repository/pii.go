@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// decryptSubscription replaces the stored (encrypted) email, first name, Slack webhook
+// URL and phone of sub with their plaintext, and derives its link tokens with tokens.
+func decryptSubscription(c *pii.Cipher, tokens *linktoken.Deriver, sub *Subscription) error {
+	email, err := c.Decrypt(sub.Email)
+	if err != nil {
+		return fmt.Errorf("subscription %d: %w", sub.ID, err)
+	}
+	sub.Email = email
+	if sub.FirstName, err = c.Decrypt(sub.FirstName); err != nil {
+		return fmt.Errorf("subscription %d first name: %w", sub.ID, err)
+	}
+	if sub.SlackWebhookURL, err = c.Decrypt(sub.SlackWebhookURL); err != nil {
+		return fmt.Errorf("subscription %d slack webhook: %w", sub.ID, err)
+	}
+	if sub.Phone, err = c.Decrypt(sub.Phone); err != nil {
+		return fmt.Errorf("subscription %d phone: %w", sub.ID, err)
+	}
+	sub.UnsubscribeToken = tokens.Token(linktoken.Unsubscribe, sub.ID)
+	sub.ManageToken = tokens.Token(linktoken.Manage, sub.ID)
+	return nil
+}
+
+// encryptOptional encrypts value, leaving an empty one empty so that "not given" stays
+// recognizable in the database.
+func encryptOptional(c *pii.Cipher, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	return c.Encrypt(value)
+}
+
+// decryptSubscriptions decrypts the emails of subs in place. Rows that cannot be
+// decrypted (e.g. a key was removed too early) are logged and left out, so one bad
+// row does not block a whole batch.
+func decryptSubscriptions(c *pii.Cipher, tokens *linktoken.Deriver, logger *zap.Logger, subs []Subscription,
+) []Subscription {
+	out := subs[:0]
+	for _, sub := range subs {
+		if err := decryptSubscription(c, tokens, &sub); err != nil {
+			logger.Error("failed to decrypt subscription email", zap.Error(err))
+			continue
+		}
+		out = append(out, sub)
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// RekeyStats counts the rows rewritten by RekeyEmails.
+type RekeyStats struct {
+	Subscriptions int
+	FirstNames    int
+	SlackWebhooks int
+	Phones        int
+	Deliveries    int
+}
+
+type rekeyRow struct {
+	ID        int64  `db:"id"`
+	Email     string `db:"email"`
+	EmailHash string `db:"email_hash"`
+}
+
+// RekeyEmails brings stored emails in line with the configured cipher: legacy plaintext
+// is encrypted, values under a retired key are re-encrypted with the active one, and
+// missing blind indexes are filled in. First names, Slack webhook URLs and phones are
+// brought in line the same way. It walks the tables in id order, batchSize rows per
+// transaction, so it is safe to run against a live database and to re-run after an
+// interruption.
+//
+// A retired key may be removed from PII_ENCRYPTION_KEYS only after a run completes.
+func RekeyEmails(ctx context.Context, db *sqlx.DB, c *pii.Cipher, batchSize int, logger *zap.Logger,
+) (RekeyStats, error) {
+	var stats RekeyStats
+	var err error
+
+	stats.Subscriptions, err = rekeyTable(ctx, db, c, batchSize, true, `
+        SELECT id, email, COALESCE(email_hash, '') AS email_hash
+        FROM subscriptions
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2;
+    `, `UPDATE subscriptions SET email = $2, email_hash = $3 WHERE id = $1;`)
+	if err != nil {
+		logger.Error("failed to rekey subscription emails", zap.Error(err))
+		return stats, err
+	}
+
+	// subscription_cities carries a copy of the blind index for its unique constraint.
+	const syncCities = `
+        UPDATE subscription_cities sc
+        SET email_hash = s.email_hash
+        FROM subscriptions s
+        WHERE s.id = sc.subscription_id AND sc.email_hash IS DISTINCT FROM s.email_hash;
+    `
+	if _, err := db.ExecContext(ctx, syncCities); err != nil {
+		logger.Error("failed to sync subscription city email hashes", zap.Error(err))
+		return stats, err
+	}
+
+	stats.FirstNames, err = rekeyTable(ctx, db, c, batchSize, false, `
+        SELECT id, first_name AS email, '' AS email_hash
+        FROM subscriptions
+        WHERE id > $1 AND first_name <> ''
+        ORDER BY id
+        LIMIT $2;
+    `, `UPDATE subscriptions SET first_name = $2 WHERE id = $1;`)
+	if err != nil {
+		logger.Error("failed to rekey first names", zap.Error(err))
+		return stats, err
+	}
+
+	stats.SlackWebhooks, err = rekeyTable(ctx, db, c, batchSize, false, `
+        SELECT id, slack_webhook_url AS email, '' AS email_hash
+        FROM subscriptions
+        WHERE id > $1 AND slack_webhook_url <> ''
+        ORDER BY id
+        LIMIT $2;
+    `, `UPDATE subscriptions SET slack_webhook_url = $2 WHERE id = $1;`)
+	if err != nil {
+		logger.Error("failed to rekey slack webhook urls", zap.Error(err))
+		return stats, err
+	}
+
+	stats.Phones, err = rekeyTable(ctx, db, c, batchSize, false, `
+        SELECT id, phone AS email, '' AS email_hash
+        FROM subscriptions
+        WHERE id > $1 AND phone <> ''
+        ORDER BY id
+        LIMIT $2;
+    `, `UPDATE subscriptions SET phone = $2 WHERE id = $1;`)
+	if err != nil {
+		logger.Error("failed to rekey phones", zap.Error(err))
+		return stats, err
+	}
+
+	stats.Deliveries, err = rekeyTable(ctx, db, c, batchSize, false, `
+        SELECT id, email, '' AS email_hash
+        FROM deliveries
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2;
+    `, `UPDATE deliveries SET email = $2 WHERE id = $1;`)
+	if err != nil {
+		logger.Error("failed to rekey delivery emails", zap.Error(err))
+		return stats, err
+	}
+	return stats, nil
+}
+
+// rekeyTable pages through selectQ (id > $1 LIMIT $2) and runs updateQ (id, email and,
+// withHash, email_hash) for every row whose email or blind index is out of date.
+func rekeyTable(ctx context.Context, db *sqlx.DB, c *pii.Cipher, batchSize int, withHash bool,
+	selectQ, updateQ string,
+) (int, error) {
+	var lastID int64
+	rewritten := 0
+	for {
+		var rows []rekeyRow
+		if err := db.SelectContext(ctx, &rows, selectQ, lastID, batchSize); err != nil {
+			return rewritten, err
+		}
+		if len(rows) == 0 {
+			return rewritten, nil
+		}
+
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			return rewritten, err
+		}
+		for _, row := range rows {
+			lastID = row.ID
+			plain, err := c.Decrypt(row.Email)
+			if err != nil {
+				_ = tx.Rollback()
+				return rewritten, err
+			}
+			hash := c.BlindIndex(plain)
+			if !c.NeedsRekey(row.Email) && (!withHash || row.EmailHash == hash) {
+				continue
+			}
+			stored := row.Email
+			if c.NeedsRekey(row.Email) {
+				if stored, err = c.Encrypt(plain); err != nil {
+					_ = tx.Rollback()
+					return rewritten, err
+				}
+			}
+			args := []any{row.ID, stored}
+			if withHash {
+				args = append(args, hash)
+			}
+			if _, err := tx.ExecContext(ctx, updateQ, args...); err != nil {
+				_ = tx.Rollback()
+				return rewritten, err
+			}
+			rewritten++
+		}
+		if err := tx.Commit(); err != nil {
+			return rewritten, err
+		}
+	}
+}
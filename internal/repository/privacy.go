@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+)
+
+// PrivacyExport is the data tied to an address: its subscriptions (unsubscribed ones
+// not yet purged included), their history and their delivery log.
+type PrivacyExport struct {
+	Subscriptions []Subscription
+	Events        []SubscriptionEvent
+	Deliveries    []Delivery
+}
+
+// PrivacyErasure counts the rows erased for an address.
+type PrivacyErasure struct {
+	Subscriptions int64 // with their cities, alerts, engagement and pending jobs
+	Events        int64
+	Deliveries    int64
+}
+
+// PrivacyRepository serves the GDPR requests of subscribers: the data of an address is
+// exported or erased with a token emailed to it, which proves the requester owns it.
+type PrivacyRepository interface {
+	// CreateRequest returns a new token of email valid for ttl, dropping the expired
+	// ones of every address. The token reaches the data of the tenant of ctx only.
+	CreateRequest(ctx context.Context, email string, ttl time.Duration) (uuid.UUID, error)
+	// Export returns the data of the address of token, read in one transaction. It
+	// returns sql.ErrNoRows when the token is unknown or expired.
+	Export(ctx context.Context, token uuid.UUID) (PrivacyExport, error)
+	// Erase deletes the data of email, and its tokens, in one transaction. It returns
+	// sql.ErrNoRows when token is unknown, expired or not one of email.
+	Erase(ctx context.Context, email string, token uuid.UUID) (PrivacyErasure, error)
+}
+
+type pgPrivacyRepo struct {
+	db     *sqlx.DB
+	pii    *pii.Cipher
+	tokens *linktoken.Deriver
+	logger *zap.Logger
+}
+
+// NewPrivacyRepository matches emails through the blind index of cipher, and decrypts
+// the exported rows with it, deriving their link tokens with tokens.
+func NewPrivacyRepository(db *sqlx.DB, cipher *pii.Cipher, tokens *linktoken.Deriver, logger *zap.Logger,
+) PrivacyRepository {
+	return &pgPrivacyRepo{db: db, pii: cipher, tokens: tokens, logger: logger}
+}
+
+func (r *pgPrivacyRepo) CreateRequest(ctx context.Context, email string, ttl time.Duration) (uuid.UUID, error) {
+	const q = `
+        WITH expired AS (DELETE FROM privacy_requests WHERE expires_at <= now())
+        INSERT INTO privacy_requests (token_hash, email_hash, expires_at, tenant_id)
+        VALUES ($1, $2, now() + $3 * interval '1 second', $4);
+    `
+	token := uuid.New()
+	if _, err := r.db.ExecContext(ctx, q, hashToken(token), r.pii.BlindIndex(email), ttl.Seconds(),
+		tenant.OrDefault(ctx)); err != nil {
+		r.logger.Error("failed to create privacy request", zap.Error(err))
+		return uuid.Nil, err
+	}
+	return token, nil
+}
+
+// Deliveries are found through their subscription: those left behind by a purged
+// subscription (subscription_id NULL) hold the address encrypted only, and cannot be
+// matched. They go with the delivery log retention.
+func (r *pgPrivacyRepo) Export(ctx context.Context, token uuid.UUID) (PrivacyExport, error) {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		r.logger.Error("failed to begin privacy export transaction", zap.Error(err))
+		return PrivacyExport{}, err
+	}
+	defer tx.Rollback()
+
+	var req struct {
+		EmailHash string `db:"email_hash"`
+		TenantID  string `db:"tenant_id"`
+	}
+	err = tx.GetContext(ctx, &req, `
+        SELECT email_hash, tenant_id FROM privacy_requests
+        WHERE token_hash = $1 AND expires_at > now() AND ($2::text IS NULL OR tenant_id = $2);
+    `, hashToken(token), tenantScope(ctx))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to look up privacy request", zap.Error(err))
+		}
+		return PrivacyExport{}, err
+	}
+
+	out := PrivacyExport{Events: []SubscriptionEvent{}, Deliveries: []Delivery{}}
+	var subs []Subscription
+	if err := tx.SelectContext(ctx, &subs,
+		`SELECT *, `+citiesColumn+` FROM subscriptions WHERE email_hash = $1 AND tenant_id = $2 ORDER BY id;`,
+		req.EmailHash, req.TenantID); err != nil {
+		r.logger.Error("failed to export subscriptions", zap.Error(err))
+		return PrivacyExport{}, err
+	}
+	out.Subscriptions = decryptSubscriptions(r.pii, r.tokens, r.logger, subs)
+	if err := tx.SelectContext(ctx, &out.Events, `
+        SELECT e.id, e.subscription_id, e.kind, e.detail, e.created_at
+        FROM subscription_events e
+        JOIN subscriptions s ON s.id = e.subscription_id
+        WHERE s.email_hash = $1 AND s.tenant_id = $2
+        ORDER BY e.subscription_id, e.id;
+    `, req.EmailHash, req.TenantID); err != nil {
+		r.logger.Error("failed to export subscription events", zap.Error(err))
+		return PrivacyExport{}, err
+	}
+	if err := tx.SelectContext(ctx, &out.Deliveries, `
+        SELECT d.*
+        FROM deliveries d
+        JOIN subscriptions s ON s.id = d.subscription_id
+        WHERE s.email_hash = $1 AND s.tenant_id = $2
+        ORDER BY d.id;
+    `, req.EmailHash, req.TenantID); err != nil {
+		r.logger.Error("failed to export deliveries", zap.Error(err))
+		return PrivacyExport{}, err
+	}
+	for i := range out.Deliveries {
+		if out.Deliveries[i].Email, err = r.pii.Decrypt(out.Deliveries[i].Email); err != nil {
+			r.logger.Error("failed to decrypt delivery email", zap.Int64("id", out.Deliveries[i].ID), zap.Error(err))
+			return PrivacyExport{}, err
+		}
+	}
+	return out, nil
+}
+
+func (r *pgPrivacyRepo) Erase(ctx context.Context, email string, token uuid.UUID) (PrivacyErasure, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.Error("failed to begin privacy erasure transaction", zap.Error(err))
+		return PrivacyErasure{}, err
+	}
+	defer tx.Rollback()
+
+	emailHash := r.pii.BlindIndex(email)
+	var tenantID string
+	err = tx.GetContext(ctx, &tenantID, `
+        SELECT tenant_id FROM privacy_requests
+        WHERE token_hash = $1 AND email_hash = $2 AND expires_at > now() AND ($3::text IS NULL OR tenant_id = $3)
+        FOR UPDATE;
+    `, hashToken(token), emailHash, tenantScope(ctx))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to look up privacy request", zap.Error(err))
+		}
+		return PrivacyErasure{}, err
+	}
+
+	var res PrivacyErasure
+	steps := []struct {
+		count *int64
+		q     string
+	}{
+		{&res.Deliveries, `DELETE FROM deliveries WHERE subscription_id IN (SELECT id FROM subscriptions WHERE email_hash = $1 AND tenant_id = $2);`},
+		{&res.Events, `DELETE FROM subscription_events WHERE subscription_id IN (SELECT id FROM subscriptions WHERE email_hash = $1 AND tenant_id = $2);`},
+		// the other tables of a subscription go with it (ON DELETE CASCADE)
+		{&res.Subscriptions, `DELETE FROM subscriptions WHERE email_hash = $1 AND tenant_id = $2;`},
+		{nil, `DELETE FROM privacy_requests WHERE email_hash = $1 AND tenant_id = $2;`},
+	}
+	for _, step := range steps {
+		result, err := tx.ExecContext(ctx, step.q, emailHash, tenantID)
+		if err != nil {
+			r.logger.Error("failed to erase address data", zap.Error(err))
+			return PrivacyErasure{}, err
+		}
+		if step.count != nil {
+			if *step.count, err = result.RowsAffected(); err != nil {
+				return PrivacyErasure{}, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit privacy erasure", zap.Error(err))
+		return PrivacyErasure{}, err
+	}
+	r.logger.Info("address data erased", zap.Int64("subscriptions", res.Subscriptions),
+		zap.Int64("events", res.Events), zap.Int64("deliveries", res.Deliveries))
+	return res, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+)
+
+func TestPrivacyRepository_Erase(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewPrivacyRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	token := uuid.New()
+	emailHash := (*pii.Cipher)(nil).BlindIndex("a@example.com")
+	mock.ExpectBegin()
+	// only the data of the tenant of the request goes
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT tenant_id FROM privacy_requests WHERE token_hash = $1 AND email_hash = $2 AND expires_at > now() AND ($3::text IS NULL OR tenant_id = $3) FOR UPDATE;")).
+		WithArgs(hashToken(token), emailHash, "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("acme"))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM deliveries WHERE subscription_id IN")).
+		WithArgs(emailHash, "acme").WillReturnResult(sqlmock.NewResult(0, 30))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM subscription_events WHERE subscription_id IN")).
+		WithArgs(emailHash, "acme").WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM subscriptions WHERE email_hash = $1 AND tenant_id = $2;")).
+		WithArgs(emailHash, "acme").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM privacy_requests WHERE email_hash = $1 AND tenant_id = $2;")).
+		WithArgs(emailHash, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	res, err := repo.Erase(tenant.WithID(context.Background(), "acme"), "a@example.com", token)
+	if err != nil {
+		t.Fatalf("Erase() error: %v", err)
+	}
+	want := PrivacyErasure{Subscriptions: 2, Events: 5, Deliveries: 30}
+	if res != want {
+		t.Errorf("Erase() = %+v, want %+v", res, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPrivacyRepository_Erase_UnknownTokenErasesNothing(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewPrivacyRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT tenant_id FROM privacy_requests")).
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}))
+	mock.ExpectRollback()
+
+	if _, err := repo.Erase(context.Background(), "a@example.com", uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Erase() error = %v, want sql.ErrNoRows", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestPrivacyRepository_Export(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewPrivacyRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	token := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT email_hash, tenant_id FROM privacy_requests WHERE token_hash = $1 AND expires_at > now() AND ($2::text IS NULL OR tenant_id = $2);")).
+		WithArgs(hashToken(token), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"email_hash", "tenant_id"}).AddRow("hash", "default"))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM subscriptions WHERE email_hash = $1 AND tenant_id = $2 ORDER BY id;")).
+		WithArgs("hash", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city", "unsubscribe_token_hash"}).
+			AddRow(3, "a@example.com", "Kyiv", nil))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM subscription_events e JOIN subscriptions s ON s.id = e.subscription_id")).
+		WithArgs("hash", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_id", "kind", "detail", "created_at"}))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM deliveries d JOIN subscriptions s ON s.id = d.subscription_id")).
+		WithArgs("hash", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_id", "email", "city", "status"}).
+			AddRow(9, 3, "a@example.com", "Kyiv", "sent"))
+	mock.ExpectRollback()
+
+	out, err := repo.Export(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Export() error: %v", err)
+	}
+	if len(out.Subscriptions) != 1 || len(out.Events) != 0 || len(out.Deliveries) != 1 || out.Deliveries[0].Email != "a@example.com" {
+		t.Errorf("Export() = %+v, want 1 subscription, no event and 1 delivery", out)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
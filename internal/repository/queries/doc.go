@@ -0,0 +1,8 @@
+// Package queries holds the static queries of SubscriptionRepository in sqlc's
+// annotated format (subscriptions.sql) and the Queries that `sqlc generate` writes from
+// them (see sqlc.yaml), checked against the schema the migrations build. The
+// repository maps their rows to its own types; the filters of the admin listing are
+// built at runtime and stay in subscription_admin.go.
+//
+// Edit the .sql files, never the generated .go files, and regenerate.
+package queries
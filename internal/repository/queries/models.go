@@ -0,0 +1,177 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+
+package queries
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+type SubscriptionChannel string
+
+const (
+	SubscriptionChannelEmail SubscriptionChannel = "email"
+	SubscriptionChannelSlack SubscriptionChannel = "slack"
+	SubscriptionChannelSms   SubscriptionChannel = "sms"
+)
+
+func (e *SubscriptionChannel) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = SubscriptionChannel(s)
+	case string:
+		*e = SubscriptionChannel(s)
+	default:
+		return fmt.Errorf("unsupported scan type for SubscriptionChannel: %T", src)
+	}
+	return nil
+}
+
+type NullSubscriptionChannel struct {
+	SubscriptionChannel SubscriptionChannel
+	Valid               bool // Valid is true if SubscriptionChannel is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullSubscriptionChannel) Scan(value interface{}) error {
+	if value == nil {
+		ns.SubscriptionChannel, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.SubscriptionChannel.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullSubscriptionChannel) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.SubscriptionChannel), nil
+}
+
+type SubscriptionFrequency string
+
+const (
+	SubscriptionFrequencyHourly   SubscriptionFrequency = "hourly"
+	SubscriptionFrequencyDaily    SubscriptionFrequency = "daily"
+	SubscriptionFrequencyAlert    SubscriptionFrequency = "alert"
+	SubscriptionFrequencyWarnings SubscriptionFrequency = "warnings"
+)
+
+func (e *SubscriptionFrequency) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = SubscriptionFrequency(s)
+	case string:
+		*e = SubscriptionFrequency(s)
+	default:
+		return fmt.Errorf("unsupported scan type for SubscriptionFrequency: %T", src)
+	}
+	return nil
+}
+
+type NullSubscriptionFrequency struct {
+	SubscriptionFrequency SubscriptionFrequency
+	Valid                 bool // Valid is true if SubscriptionFrequency is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullSubscriptionFrequency) Scan(value interface{}) error {
+	if value == nil {
+		ns.SubscriptionFrequency, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.SubscriptionFrequency.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullSubscriptionFrequency) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.SubscriptionFrequency), nil
+}
+
+type SubscriptionUnits string
+
+const (
+	SubscriptionUnitsMetric   SubscriptionUnits = "metric"
+	SubscriptionUnitsImperial SubscriptionUnits = "imperial"
+)
+
+func (e *SubscriptionUnits) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = SubscriptionUnits(s)
+	case string:
+		*e = SubscriptionUnits(s)
+	default:
+		return fmt.Errorf("unsupported scan type for SubscriptionUnits: %T", src)
+	}
+	return nil
+}
+
+type NullSubscriptionUnits struct {
+	SubscriptionUnits SubscriptionUnits
+	Valid             bool // Valid is true if SubscriptionUnits is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullSubscriptionUnits) Scan(value interface{}) error {
+	if value == nil {
+		ns.SubscriptionUnits, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.SubscriptionUnits.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullSubscriptionUnits) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.SubscriptionUnits), nil
+}
+
+type Subscription struct {
+	ID                    int32
+	Email                 string
+	City                  string
+	Frequency             SubscriptionFrequency
+	Confirmed             bool
+	ScheduledMinute       int16
+	ScheduledHour         int16
+	CreatedAt             time.Time
+	ConfirmedAt           sql.NullTime
+	WelcomeSentAt         sql.NullTime
+	Units                 SubscriptionUnits
+	EmailHash             string
+	SendHour              sql.NullInt16
+	Timezone              string
+	ExpiresAt             sql.NullTime
+	ConfirmTokenExpiresAt sql.NullTime
+	ConfirmTokenHash      sql.NullString
+	UnsubscribeTokenHash  sql.NullString
+	BetaFeatures          bool
+	Locale                string
+	FirstName             string
+	NotifyOnChange        bool
+	SlackWebhookUrl       string
+	Phone                 string
+	Channel               SubscriptionChannel
+	ConsecutiveFailures   int16
+	DeadLetteredAt        sql.NullTime
+	LastSentAt            sql.NullTime
+	LastAttemptedAt       sql.NullTime
+	DeletedAt             sql.NullTime
+	SendClaimedUntil      sql.NullTime
+	TenantID              string
+	ManageTokenHash       sql.NullString
+}
@@ -0,0 +1,289 @@
+-- name: CreateSubscription :one
+-- Cities are all the cities, in order; confirm_ttl is the lifetime of the confirmation
+-- token in seconds. The schedule slot is the send hour, or else the current hour, in
+-- the timezone; the minute is the current one (UTC for hourly updates), spreading the
+-- subscribers of an hour over it.
+WITH s AS (
+    INSERT INTO subscriptions (email, email_hash, city, frequency, send_hour, timezone, confirm_token_expires_at,
+                               confirm_token_hash, locale, first_name, slack_webhook_url, channel, tenant_id,
+                               scheduled_hour, scheduled_minute)
+    VALUES (sqlc.arg(email), sqlc.arg(email_hash), sqlc.arg(city), sqlc.arg(frequency)::subscription_frequency,
+            sqlc.narg(send_hour)::smallint, sqlc.arg(timezone)::text,
+            CASE WHEN sqlc.arg(confirm_ttl)::float8 > 0 THEN now() + sqlc.arg(confirm_ttl)::float8 * INTERVAL '1 second' END,
+            sqlc.arg(confirm_token_hash)::text, sqlc.arg(locale), sqlc.arg(first_name), sqlc.arg(slack_webhook_url),
+            sqlc.arg(channel), sqlc.arg(tenant_id),
+            COALESCE(sqlc.narg(send_hour)::smallint, EXTRACT(HOUR FROM now() AT TIME ZONE sqlc.arg(timezone)::text)::smallint),
+            CASE WHEN sqlc.arg(frequency)::subscription_frequency = 'hourly' THEN EXTRACT(MINUTE FROM now())::smallint
+                 ELSE EXTRACT(MINUTE FROM now() AT TIME ZONE sqlc.arg(timezone)::text)::smallint END)
+    RETURNING id
+), c AS (
+    INSERT INTO subscription_cities (subscription_id, email_hash, city, position, tenant_id)
+    SELECT s.id, sqlc.arg(email_hash), x.city, x.ord - 1, sqlc.arg(tenant_id)
+    FROM s, unnest(sqlc.arg(cities)::text[]) WITH ORDINALITY AS x(city, ord)
+), e AS (
+    INSERT INTO subscription_events (subscription_id, kind) SELECT id, 'created' FROM s
+)
+SELECT id FROM s;
+
+-- name: ConfirmSubscription :one
+-- Confirms the subscription of an unexpired confirmation token, or of a verified phone
+-- (by id, token_hash NULL), recording detail in its event. ttl is the lifetime of the
+-- subscription in seconds (0: never expires). Its schedule slot was fixed by
+-- CreateSubscription and stays.
+WITH confirmed AS (
+    UPDATE subscriptions
+    SET confirmed        = TRUE,
+        confirm_token_hash       = NULL,
+        confirm_token_expires_at = NULL,
+        confirmed_at     = now(),
+        expires_at       = CASE WHEN sqlc.arg(ttl)::float8 > 0 THEN now() + sqlc.arg(ttl)::float8 * INTERVAL '1 second' END
+    WHERE (subscriptions.id = sqlc.narg(id)::integer
+           OR (confirm_token_hash = sqlc.narg(token_hash)::text
+               AND (confirm_token_expires_at IS NULL OR confirm_token_expires_at > now())))
+      AND confirmed = FALSE AND deleted_at IS NULL
+      AND (sqlc.narg(tenant)::text IS NULL OR tenant_id = sqlc.narg(tenant)::text)
+    RETURNING subscriptions.id
+), e AS (
+    INSERT INTO subscription_events (subscription_id, kind, detail) SELECT id, 'confirmed', sqlc.arg(detail) FROM confirmed
+)
+SELECT id FROM confirmed;
+
+-- name: ConfirmTokenExpired :one
+SELECT EXISTS (SELECT 1 FROM subscriptions
+               WHERE confirm_token_hash = sqlc.arg(token_hash)::text AND confirmed = FALSE AND deleted_at IS NULL
+                 AND (sqlc.narg(tenant)::text IS NULL OR tenant_id = sqlc.narg(tenant)::text));
+
+-- name: RefreshConfirmToken :one
+UPDATE subscriptions
+SET confirm_token_hash       = sqlc.arg(fresh_token_hash)::text,
+    confirm_token_expires_at = CASE WHEN sqlc.arg(confirm_ttl)::float8 > 0 THEN now() + sqlc.arg(confirm_ttl)::float8 * INTERVAL '1 second' END
+WHERE confirm_token_hash = sqlc.arg(token_hash)::text AND confirmed = FALSE AND deleted_at IS NULL
+  AND (sqlc.narg(tenant)::text IS NULL OR tenant_id = sqlc.narg(tenant)::text)
+RETURNING sqlc.embed(subscriptions), COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                       FROM subscription_cities sc
+                       WHERE sc.subscription_id = subscriptions.id), '[]') AS cities;
+
+-- name: DeleteByUnsubToken :one
+-- token_hash is the hash of a legacy unsubscribe token, id that of a derived one.
+WITH deleted AS (
+    UPDATE subscriptions SET deleted_at = now()
+    WHERE (subscriptions.id = sqlc.narg(id)::integer OR unsubscribe_token_hash = sqlc.narg(token_hash)::text)
+      AND deleted_at IS NULL AND (sqlc.narg(tenant)::text IS NULL OR tenant_id = sqlc.narg(tenant)::text)
+    RETURNING subscriptions.id
+), freed AS (
+    DELETE FROM subscription_cities WHERE subscription_id IN (SELECT id FROM deleted)
+), e AS (
+    INSERT INTO subscription_events (subscription_id, kind) SELECT id, 'unsubscribed' FROM deleted
+)
+SELECT id FROM deleted;
+
+-- name: DeleteUnconfirmed :one
+DELETE FROM subscriptions
+WHERE confirm_token_hash = sqlc.arg(token_hash)::text AND confirmed = FALSE AND deleted_at IS NULL
+  AND (sqlc.narg(tenant)::text IS NULL OR tenant_id = sqlc.narg(tenant)::text)
+RETURNING *;
+
+-- name: DeleteUnconfirmedOlderThan :execrows
+DELETE FROM subscriptions
+WHERE id IN (SELECT s.id FROM subscriptions s
+             WHERE s.confirmed = FALSE AND s.created_at < sqlc.arg(cutoff)
+             LIMIT sqlc.arg(max_rows)
+             FOR UPDATE SKIP LOCKED);
+
+-- name: PurgeDeletedOlderThan :execrows
+DELETE FROM subscriptions
+WHERE id IN (SELECT s.id FROM subscriptions s
+             WHERE s.deleted_at < sqlc.arg(cutoff)::timestamptz
+             LIMIT sqlc.arg(max_rows)
+             FOR UPDATE SKIP LOCKED);
+
+-- name: HourlyBatch :many
+-- The selected columns are batchColumns, the conditions activeCondition and
+-- unsentCondition, as in HourlyBatchPage.
+SELECT id, tenant_id, email, city, frequency, confirmed, units,
+       scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale,
+       first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel,
+       COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                 FROM subscription_cities sc
+                 WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions
+WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL
+  AND frequency        = 'hourly'
+  AND scheduled_minute = sqlc.arg(minute)
+  AND (last_sent_at IS NULL OR last_sent_at < now() -
+      CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END);
+
+-- name: HourlyBatchPage :many
+-- HourlyBatch of minute, a page of max_rows at a time after id after_id.
+SELECT id, tenant_id, email, city, frequency, confirmed, units,
+       scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale,
+       first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel,
+       COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                 FROM subscription_cities sc
+                 WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions
+WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL
+  AND frequency        = 'hourly'
+  AND scheduled_minute = sqlc.arg(minute)
+  AND (last_sent_at IS NULL OR last_sent_at < now() -
+      CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END)
+  AND subscriptions.id > sqlc.arg(after_id) ORDER BY subscriptions.id LIMIT sqlc.arg(max_rows);
+
+-- name: DailyBatch :many
+-- The daily subscriptions whose local slot is the time at in their timezone, one
+-- (timezone, hour, minute) index lookup per timezone in use. A wall-clock time repeated
+-- when DST ends is served on its first occurrence only; one skipped when DST starts
+-- has no slot that day. The timezones in use are read from idx_subs_daily too: their
+-- condition repeats its predicate, so the planner can use it.
+WITH slots AS (
+    SELECT tz.timezone AS slot_timezone,
+           EXTRACT(HOUR   FROM sqlc.arg(at)::timestamptz AT TIME ZONE tz.timezone)::smallint AS slot_hour,
+           EXTRACT(MINUTE FROM sqlc.arg(at)::timestamptz AT TIME ZONE tz.timezone)::smallint AS slot_minute
+    FROM (SELECT DISTINCT timezone FROM subscriptions
+          WHERE confirmed = TRUE AND frequency = 'daily'
+            AND dead_lettered_at IS NULL AND deleted_at IS NULL) AS tz
+    WHERE (sqlc.arg(at)::timestamptz - INTERVAL '1 hour') AT TIME ZONE tz.timezone
+       <= sqlc.arg(at)::timestamptz AT TIME ZONE tz.timezone - INTERVAL '1 hour'
+)
+SELECT id, tenant_id, email, city, frequency, confirmed, units,
+       scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale,
+       first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel,
+       COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                 FROM subscription_cities sc
+                 WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions
+JOIN slots ON timezone         = slot_timezone
+          AND scheduled_hour   = slot_hour
+          AND scheduled_minute = slot_minute
+WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL
+  AND frequency = 'daily'
+  AND (last_sent_at IS NULL OR last_sent_at < now() -
+      CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END);
+
+-- name: DailyBatchPage :many
+-- DailyBatch of time at, a page of max_rows at a time after id after_id.
+WITH slots AS (
+    SELECT tz.timezone AS slot_timezone,
+           EXTRACT(HOUR   FROM sqlc.arg(at)::timestamptz AT TIME ZONE tz.timezone)::smallint AS slot_hour,
+           EXTRACT(MINUTE FROM sqlc.arg(at)::timestamptz AT TIME ZONE tz.timezone)::smallint AS slot_minute
+    FROM (SELECT DISTINCT timezone FROM subscriptions
+          WHERE confirmed = TRUE AND frequency = 'daily'
+            AND dead_lettered_at IS NULL AND deleted_at IS NULL) AS tz
+    WHERE (sqlc.arg(at)::timestamptz - INTERVAL '1 hour') AT TIME ZONE tz.timezone
+       <= sqlc.arg(at)::timestamptz AT TIME ZONE tz.timezone - INTERVAL '1 hour'
+)
+SELECT id, tenant_id, email, city, frequency, confirmed, units,
+       scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale,
+       first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel,
+       COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                 FROM subscription_cities sc
+                 WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions
+JOIN slots ON timezone         = slot_timezone
+          AND scheduled_hour   = slot_hour
+          AND scheduled_minute = slot_minute
+WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL
+  AND frequency = 'daily'
+  AND (last_sent_at IS NULL OR last_sent_at < now() -
+      CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END)
+  AND subscriptions.id > sqlc.arg(after_id) ORDER BY subscriptions.id LIMIT sqlc.arg(max_rows);
+
+-- name: GetByID :one
+SELECT sqlc.embed(subscriptions), COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                    FROM subscription_cities sc
+                    WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions
+WHERE subscriptions.id = sqlc.arg(id) AND deleted_at IS NULL
+  AND (sqlc.narg(tenant)::text IS NULL OR tenant_id = sqlc.narg(tenant)::text);
+
+-- name: GetByEmail :one
+SELECT sqlc.embed(subscriptions), COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                    FROM subscription_cities sc
+                    WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions
+WHERE subscriptions.email_hash = sqlc.arg(email_hash) AND deleted_at IS NULL
+  AND (sqlc.narg(tenant)::text IS NULL OR subscriptions.tenant_id = sqlc.narg(tenant)::text)
+ORDER BY subscriptions.id DESC LIMIT 1;
+
+-- name: ListByEmail :many
+SELECT sqlc.embed(subscriptions), COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                    FROM subscription_cities sc
+                    WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions
+WHERE subscriptions.email_hash = sqlc.arg(email_hash)
+  AND (sqlc.narg(tenant)::text IS NULL OR subscriptions.tenant_id = sqlc.narg(tenant)::text)
+ORDER BY subscriptions.id DESC;
+
+-- name: ListConfirmed :many
+SELECT id, tenant_id, email, city, frequency, confirmed, units,
+       scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale,
+       first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel,
+       COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                 FROM subscription_cities sc
+                 WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions WHERE confirmed = TRUE AND deleted_at IS NULL;
+
+-- name: ClaimWelcome :one
+UPDATE subscriptions
+SET welcome_sent_at = now()
+WHERE subscriptions.id = sqlc.arg(id) AND confirmed = TRUE AND welcome_sent_at IS NULL AND deleted_at IS NULL
+RETURNING sqlc.embed(subscriptions), COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                       FROM subscription_cities sc
+                       WHERE sc.subscription_id = subscriptions.id), '[]') AS cities;
+
+-- name: PendingWelcomes :many
+SELECT id FROM subscriptions
+WHERE confirmed = TRUE
+  AND welcome_sent_at IS NULL
+  AND deleted_at IS NULL
+  AND confirmed_at >= sqlc.arg(since)::timestamptz;
+
+-- name: ActiveIDs :many
+SELECT id FROM subscriptions
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+  AND confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL;
+
+-- name: ActiveByIDs :many
+SELECT id, tenant_id, email, city, frequency, confirmed, units,
+       scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale,
+       first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel,
+       COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                 FROM subscription_cities sc
+                 WHERE sc.subscription_id = subscriptions.id), '[]') AS cities
+FROM subscriptions
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+  AND confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL
+  AND (last_sent_at IS NULL OR last_sent_at < now() -
+      CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END);
+
+-- name: ClaimSends :many
+-- lease is in seconds.
+UPDATE subscriptions
+SET send_claimed_until = now() + sqlc.arg(lease)::float8 * INTERVAL '1 second'
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+  AND confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL
+  AND (last_sent_at IS NULL OR last_sent_at < now() -
+      CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END)
+  AND (send_claimed_until IS NULL OR send_claimed_until <= now())
+RETURNING id;
+
+-- name: RecordSends :exec
+-- attempted are the subscriptions attempted, sent those of them sent.
+UPDATE subscriptions
+SET last_attempted_at  = now(),
+    last_sent_at       = CASE WHEN id = ANY(sqlc.arg(sent)::bigint[]) THEN now() ELSE last_sent_at END,
+    send_claimed_until = NULL
+WHERE id = ANY(sqlc.arg(attempted)::bigint[]);
+
+-- name: SubscribedCities :many
+-- The cities of every active subscription, one spelling per city whatever its case.
+WITH active AS (
+    SELECT id, city FROM subscriptions
+    WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL
+)
+SELECT min(c.city)::text AS city
+FROM (SELECT city FROM active
+      UNION ALL
+      SELECT sc.city FROM subscription_cities sc JOIN active a ON a.id = sc.subscription_id) c
+GROUP BY lower(c.city)
+ORDER BY 1;
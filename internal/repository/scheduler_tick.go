@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// TickRepository remembers the last tick each scheduler job ran.
+type TickRepository interface {
+	// LastTick returns the last tick recorded for job, zero if none ever was.
+	LastTick(ctx context.Context, job string) (time.Time, error)
+	// RecordTick records tick as run by job, unless a later one already was.
+	RecordTick(ctx context.Context, job string, tick time.Time) error
+}
+
+type pgTickRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+func NewTickRepository(db *sqlx.DB, logger *zap.Logger) TickRepository {
+	return &pgTickRepo{db: db, logger: logger}
+}
+
+func (r *pgTickRepo) LastTick(ctx context.Context, job string) (time.Time, error) {
+	var tick time.Time
+	err := r.db.GetContext(ctx, &tick, `SELECT tick FROM scheduler_ticks WHERE job = $1;`, job)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		r.logger.Error("failed to read last scheduler tick", zap.String("job", job), zap.Error(err))
+		return time.Time{}, err
+	}
+	return tick, nil
+}
+
+func (r *pgTickRepo) RecordTick(ctx context.Context, job string, tick time.Time) error {
+	const q = `
+        INSERT INTO scheduler_ticks (job, tick) VALUES ($1, $2)
+        ON CONFLICT (job) DO UPDATE
+           SET tick = GREATEST(scheduler_ticks.tick, EXCLUDED.tick), updated_at = now();
+    `
+	if _, err := r.db.ExecContext(ctx, q, job, tick); err != nil {
+		r.logger.Error("failed to record scheduler tick", zap.String("job", job), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestTickRepository_LastTick(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewTickRepository(sqlxDB, zap.NewNop())
+
+	tick := time.Date(2026, 10, 16, 7, 42, 0, 0, time.UTC)
+	q := regexp.QuoteMeta("SELECT tick FROM scheduler_ticks WHERE job = $1;")
+	mock.ExpectQuery(q).WithArgs("updates").
+		WillReturnRows(sqlmock.NewRows([]string{"tick"}).AddRow(tick))
+	mock.ExpectQuery(q).WithArgs("updates").WillReturnError(sql.ErrNoRows)
+
+	if got, err := repo.LastTick(context.Background(), "updates"); err != nil || !got.Equal(tick) {
+		t.Errorf("LastTick() = %v, %v; want %v", got, err, tick)
+	}
+	if got, err := repo.LastTick(context.Background(), "updates"); err != nil || !got.IsZero() {
+		t.Errorf("LastTick() of a job never run = %v, %v; want zero", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestTickRepository_RecordTick(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewTickRepository(sqlxDB, zap.NewNop())
+
+	tick := time.Date(2026, 10, 16, 7, 42, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("GREATEST(scheduler_ticks.tick, EXCLUDED.tick)")).
+		WithArgs("updates", tick).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.RecordTick(context.Background(), "updates", tick); err != nil {
+		t.Fatalf("RecordTick() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// SendJob is the pending weather update of a subscription for a slot.
+type SendJob struct {
+	ID             int64          `db:"id"`
+	SubscriptionID int            `db:"subscription_id"`
+	Slot           time.Time      `db:"slot"`
+	Attempts       int            `db:"attempts"` // including the one claimed
+	RunAt          time.Time      `db:"run_at"`
+	LastError      sql.NullString `db:"last_error"`
+}
+
+// JobRepository is the durable queue of weather update sends.
+type JobRepository interface {
+	// Enqueue adds a job per subscription of ids for slot, once: jobs already queued
+	// for the slot are kept. It returns how many were added.
+	Enqueue(ctx context.Context, slot time.Time, ids []int) (int64, error)
+	// Claim locks up to limit due jobs for worker until lease is over, counting an
+	// attempt on each. Jobs locked by another worker are skipped; jobs whose lease ran
+	// out (their worker died) are due again.
+	Claim(ctx context.Context, worker string, limit int, lease time.Duration) ([]SendJob, error)
+	// Extend renews the lease of those of ids still locked by worker and returns them;
+	// a job missing was claimed by another worker after the lease of worker ran out.
+	Extend(ctx context.Context, worker string, ids []int64, lease time.Duration) ([]int64, error)
+	// Complete removes the finished jobs of ids still locked by worker.
+	Complete(ctx context.Context, worker string, ids []int64) error
+	// Retry unlocks a job still locked by worker to be claimed again at runAt,
+	// remembering why it failed.
+	Retry(ctx context.Context, worker string, id int64, runAt time.Time, reason string) error
+}
+
+type pgJobRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+func NewJobRepository(db *sqlx.DB, logger *zap.Logger) JobRepository {
+	return &pgJobRepo{db: db, logger: logger}
+}
+
+func (r *pgJobRepo) Enqueue(ctx context.Context, slot time.Time, ids []int) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]int64, len(ids))
+	for i, id := range ids {
+		args[i] = int64(id)
+	}
+
+	const q = `
+        INSERT INTO send_jobs (subscription_id, slot)
+        SELECT unnest($1::int[]), $2
+        ON CONFLICT (subscription_id, slot) DO NOTHING;
+    `
+	res, err := r.db.ExecContext(ctx, q, args, slot)
+	if err != nil {
+		r.logger.Error("failed to enqueue send jobs", zap.Time("slot", slot), zap.Int("count", len(ids)), zap.Error(err))
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("failed to get rows affected on enqueue", zap.Error(err))
+		return 0, err
+	}
+	return n, nil
+}
+
+func (r *pgJobRepo) Claim(ctx context.Context, worker string, limit int, lease time.Duration) ([]SendJob, error) {
+	const q = `
+        UPDATE send_jobs
+        SET locked_by = $1, locked_until = now() + $2 * interval '1 second', attempts = attempts + 1
+        WHERE id IN (
+            SELECT id FROM send_jobs
+            WHERE run_at <= now() AND (locked_until IS NULL OR locked_until < now())
+            ORDER BY run_at, id
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, subscription_id, slot, attempts, run_at, last_error;
+    `
+	var jobs []SendJob
+	if err := r.db.SelectContext(ctx, &jobs, q, worker, int64(lease.Seconds()), limit); err != nil {
+		r.logger.Error("failed to claim send jobs", zap.String("worker", worker), zap.Error(err))
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *pgJobRepo) Extend(ctx context.Context, worker string, ids []int64, lease time.Duration) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	const q = `
+        UPDATE send_jobs
+        SET locked_until = now() + $3 * interval '1 second'
+        WHERE id = ANY($1) AND locked_by = $2
+        RETURNING id;
+    `
+	var held []int64
+	if err := r.db.SelectContext(ctx, &held, q, ids, worker, int64(lease.Seconds())); err != nil {
+		r.logger.Error("failed to extend send job leases", zap.String("worker", worker), zap.Error(err))
+		return nil, err
+	}
+	return held, nil
+}
+
+func (r *pgJobRepo) Complete(ctx context.Context, worker string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	const q = `DELETE FROM send_jobs WHERE id = ANY($1) AND locked_by = $2;`
+	if _, err := r.db.ExecContext(ctx, q, ids, worker); err != nil {
+		r.logger.Error("failed to complete send jobs", zap.Int("count", len(ids)), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *pgJobRepo) Retry(ctx context.Context, worker string, id int64, runAt time.Time, reason string) error {
+	const q = `
+        UPDATE send_jobs
+        SET run_at = $3, locked_by = NULL, locked_until = NULL, last_error = $4
+        WHERE id = $1 AND locked_by = $2;
+    `
+	if _, err := r.db.ExecContext(ctx, q, id, worker, runAt, reason); err != nil {
+		r.logger.Error("failed to reschedule send job", zap.Int64("id", id), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestJobRepository_Enqueue(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewJobRepository(sqlxDB, zap.NewNop())
+
+	slot := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO send_jobs (subscription_id, slot) SELECT unnest($1::int[]), $2 ON CONFLICT (subscription_id, slot) DO NOTHING;")).
+		WithArgs([]int64{1, 2, 3}, slot).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.Enqueue(context.Background(), slot, []int{1, 2, 3})
+	if err != nil || n != 2 {
+		t.Errorf("Enqueue() = %d, %v; want 2, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestJobRepository_Claim(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewJobRepository(sqlxDB, zap.NewNop())
+
+	slot := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`UPDATE send_jobs SET locked_by = \$1, locked_until = now\(\) \+ \$2 \* interval '1 second', attempts = attempts \+ 1 WHERE id IN \(.* FOR UPDATE SKIP LOCKED \)`).
+		WithArgs("scheduler-1", int64(300), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_id", "slot", "attempts", "run_at", "last_error"}).
+			AddRow(7, 1, slot, 2, slot, "timeout"))
+
+	jobs, err := repo.Claim(context.Background(), "scheduler-1", 100, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Claim() unexpected error: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != 7 || jobs[0].SubscriptionID != 1 || jobs[0].Attempts != 2 ||
+		jobs[0].LastError.String != "timeout" {
+		t.Errorf("Claim() = %+v", jobs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestJobRepository_Retry(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewJobRepository(sqlxDB, zap.NewNop())
+
+	runAt := time.Date(2026, 10, 16, 8, 2, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE send_jobs SET run_at = $3, locked_by = NULL, locked_until = NULL, last_error = $4 WHERE id = $1 AND locked_by = $2;")).
+		WithArgs(int64(7), "scheduler-1", runAt, "550 no such user").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.Retry(context.Background(), "scheduler-1", 7, runAt, "550 no such user"); err != nil {
+		t.Fatalf("Retry() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestJobRepository_Extend(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewJobRepository(sqlxDB, zap.NewNop())
+
+	// job 8 was claimed by another worker after the lease ran out
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE send_jobs SET locked_until = now() + $3 * interval '1 second' WHERE id = ANY($1) AND locked_by = $2 RETURNING id;")).
+		WithArgs([]int64{7, 8}, "scheduler-1", int64(300)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	held, err := repo.Extend(context.Background(), "scheduler-1", []int64{7, 8}, 5*time.Minute)
+	if err != nil || len(held) != 1 || held[0] != 7 {
+		t.Errorf("Extend() = %v, %v; want [7], nil", held, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestJobRepository_Complete(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewJobRepository(sqlxDB, zap.NewNop())
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM send_jobs WHERE id = ANY($1) AND locked_by = $2;")).
+		WithArgs([]int64{7, 8}, "scheduler-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.Complete(context.Background(), "scheduler-1", []int64{7, 8}); err != nil {
+		t.Fatalf("Complete() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Snapshot is the weather of a city observed within one hour.
+type Snapshot struct {
+	City        string    `db:"city"`        // lower-cased
+	ObservedAt  time.Time `db:"observed_at"` // truncated to the hour
+	Temp        float64   `db:"temp"`
+	Humidity    int       `db:"humidity"`
+	Description string    `db:"description"`
+}
+
+// SnapshotRepository stores hourly weather observations, the history behind the
+// temperature charts of daily emails.
+type SnapshotRepository interface {
+	// Record stores s, replacing an earlier observation of the same city and hour.
+	Record(ctx context.Context, s Snapshot) error
+	// Since returns the snapshots of city observed at or after since, oldest first.
+	Since(ctx context.Context, city string, since time.Time) ([]Snapshot, error)
+	// DeleteOlderThan deletes the snapshots observed before cutoff and returns how many.
+	DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type pgSnapshotRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+func NewSnapshotRepository(db *sqlx.DB, logger *zap.Logger) SnapshotRepository {
+	return &pgSnapshotRepo{db: db, logger: logger}
+}
+
+func (r *pgSnapshotRepo) Record(ctx context.Context, s Snapshot) error {
+	const q = `
+        INSERT INTO weather_snapshots (city, observed_at, temp, humidity, description)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (city, observed_at) DO UPDATE
+            SET temp = EXCLUDED.temp, humidity = EXCLUDED.humidity, description = EXCLUDED.description;
+    `
+	city := strings.ToLower(s.City)
+	if _, err := r.db.ExecContext(ctx, q, city, s.ObservedAt.Truncate(time.Hour), s.Temp, s.Humidity,
+		s.Description); err != nil {
+		r.logger.Error("failed to record weather snapshot", zap.String("city", city), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *pgSnapshotRepo) Since(ctx context.Context, city string, since time.Time) ([]Snapshot, error) {
+	const q = `
+        SELECT city, observed_at, temp, humidity, description
+        FROM weather_snapshots
+        WHERE city = $1 AND observed_at >= $2
+        ORDER BY observed_at;
+    `
+	var out []Snapshot
+	if err := r.db.SelectContext(ctx, &out, q, strings.ToLower(city), since); err != nil {
+		r.logger.Error("failed to read weather snapshots", zap.String("city", city), zap.Error(err))
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *pgSnapshotRepo) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	const q = `DELETE FROM weather_snapshots WHERE observed_at < $1;`
+	res, err := r.db.ExecContext(ctx, q, cutoff)
+	if err != nil {
+		r.logger.Error("failed to delete old weather snapshots", zap.Error(err))
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("failed to get rows affected on snapshot cleanup", zap.Error(err))
+		return 0, err
+	}
+	return n, nil
+}
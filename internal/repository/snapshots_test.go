@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestSnapshotRepository_Record_LowerCasesAndTruncates(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSnapshotRepository(sqlxDB, zap.NewNop())
+
+	at := time.Date(2026, 10, 16, 7, 42, 10, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO weather_snapshots (city, observed_at, temp, humidity, description)")).
+		WithArgs("kyiv", time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC), 12.5, 80, "Fog").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.Record(context.Background(), Snapshot{City: "Kyiv", ObservedAt: at, Temp: 12.5, Humidity: 80, Description: "Fog"})
+	if err != nil {
+		t.Fatalf("Record() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSnapshotRepository_Since(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSnapshotRepository(sqlxDB, zap.NewNop())
+
+	since := time.Date(2026, 10, 15, 7, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM weather_snapshots WHERE city = $1 AND observed_at >= $2 ORDER BY observed_at")).
+		WithArgs("kyiv", since).
+		WillReturnRows(sqlmock.NewRows([]string{"city", "observed_at", "temp", "humidity", "description"}).
+			AddRow("kyiv", since, 9.0, 85, "Clear").
+			AddRow("kyiv", since.Add(time.Hour), 10.5, 82, "Clear"))
+
+	got, err := repo.Since(context.Background(), "Kyiv", since)
+	if err != nil {
+		t.Fatalf("Since() unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[1].Temp != 10.5 {
+		t.Errorf("Since() = %+v, want two snapshots oldest first", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// CityCount is the number of active subscriptions including a city.
+type CityCount struct {
+	City        string `db:"city"        json:"city"`
+	Subscribers int    `db:"subscribers" json:"subscribers"`
+}
+
+// FrequencyCount is the number of active subscriptions of a frequency.
+type FrequencyCount struct {
+	Frequency   Frequency `db:"frequency"   json:"frequency"`
+	Subscribers int       `db:"subscribers" json:"subscribers"`
+}
+
+// DailyCount is the number of subscriptions confirmed and of confirmed subscriptions
+// unsubscribed on a day (UTC).
+type DailyCount struct {
+	Day           time.Time `db:"day"           json:"day"`
+	Confirmations int       `db:"confirmations" json:"confirmations"`
+	Unsubscribes  int       `db:"unsubscribes"  json:"unsubscribes"`
+}
+
+// Churn tells how many of the subscriptions active at the start of a period were
+// unsubscribed by its end.
+type Churn struct {
+	ActiveAtStart int     `db:"active_at_start" json:"active_at_start"`
+	Churned       int     `db:"churned"         json:"churned"`
+	Rate          float64 `json:"rate"` // Churned / ActiveAtStart, 0 without active subscriptions
+}
+
+// SubscriptionStats are the aggregate numbers on subscriptions since a day. Active
+// subscriptions are confirmed and not unsubscribed, expired and dead-lettered included.
+type SubscriptionStats struct {
+	Since       time.Time        `json:"since"`
+	ByCity      []CityCount      `json:"by_city"` // the most subscribed cities first
+	ByFrequency []FrequencyCount `json:"by_frequency"`
+	Daily       []DailyCount     `json:"daily"` // every day since Since, today included
+	Churn       Churn            `json:"churn"`
+}
+
+// StatsRepository computes the aggregate numbers on subscriptions asked by product.
+type StatsRepository interface {
+	// Stats returns the statistics since the start of the day of since (UTC), with the
+	// topCities most subscribed cities. Subscriptions purged after their retention
+	// (DELETED_RETENTION_DAYS) are no longer counted in past days. Within a request,
+	// only the subscriptions of its tenant are counted.
+	Stats(ctx context.Context, since time.Time, topCities int) (SubscriptionStats, error)
+}
+
+type pgStatsRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+// NewStatsRepository reads from db, a read replica when there is one: the statistics
+// tolerate replication lag.
+func NewStatsRepository(db *sqlx.DB, logger *zap.Logger) StatsRepository {
+	return &pgStatsRepo{db: db, logger: logger}
+}
+
+func (r *pgStatsRepo) Stats(ctx context.Context, since time.Time, topCities int) (SubscriptionStats, error) {
+	const byCityQ = `
+        SELECT sc.city, count(*) AS subscribers
+        FROM subscription_cities sc
+        JOIN subscriptions s ON s.id = sc.subscription_id
+        WHERE s.confirmed = TRUE AND s.deleted_at IS NULL AND ($2::text IS NULL OR s.tenant_id = $2)
+        GROUP BY sc.city
+        ORDER BY subscribers DESC, sc.city
+        LIMIT $1;
+    `
+	const byFrequencyQ = `
+        SELECT frequency, count(*) AS subscribers
+        FROM subscriptions
+        WHERE confirmed = TRUE AND deleted_at IS NULL AND ($1::text IS NULL OR tenant_id = $1)
+        GROUP BY frequency
+        ORDER BY frequency;
+    `
+	const dailyQ = `
+        WITH days AS (
+            SELECT generate_series($1::timestamptz, date_trunc('day', now() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+                                   INTERVAL '1 day') AS day
+        ),
+        confirmations AS (
+            SELECT date_trunc('day', confirmed_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS day, count(*) AS n
+            FROM subscriptions
+            WHERE confirmed_at >= $1 AND ($2::text IS NULL OR tenant_id = $2)
+            GROUP BY 1
+        ),
+        unsubscribes AS (
+            SELECT date_trunc('day', deleted_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS day, count(*) AS n
+            FROM subscriptions
+            WHERE confirmed_at IS NOT NULL AND deleted_at >= $1 AND ($2::text IS NULL OR tenant_id = $2)
+            GROUP BY 1
+        )
+        SELECT days.day, COALESCE(c.n, 0) AS confirmations, COALESCE(u.n, 0) AS unsubscribes
+        FROM days
+        LEFT JOIN confirmations c USING (day)
+        LEFT JOIN unsubscribes u USING (day)
+        ORDER BY days.day;
+    `
+	const churnQ = `
+        SELECT count(*) FILTER (WHERE deleted_at IS NULL OR deleted_at >= $1) AS active_at_start,
+               count(*) FILTER (WHERE deleted_at >= $1)                     AS churned
+        FROM subscriptions
+        WHERE confirmed_at < $1 AND ($2::text IS NULL OR tenant_id = $2);
+    `
+	since = since.UTC().Truncate(24 * time.Hour)
+	scope := tenantScope(ctx)
+	out := SubscriptionStats{Since: since, ByCity: []CityCount{}, ByFrequency: []FrequencyCount{}}
+	if err := r.db.SelectContext(ctx, &out.ByCity, byCityQ, topCities, scope); err != nil {
+		r.logger.Error("failed to count subscribers per city", zap.Error(err))
+		return SubscriptionStats{}, err
+	}
+	if err := r.db.SelectContext(ctx, &out.ByFrequency, byFrequencyQ, scope); err != nil {
+		r.logger.Error("failed to count subscribers per frequency", zap.Error(err))
+		return SubscriptionStats{}, err
+	}
+	if err := r.db.SelectContext(ctx, &out.Daily, dailyQ, since, scope); err != nil {
+		r.logger.Error("failed to count confirmations per day", zap.Error(err))
+		return SubscriptionStats{}, err
+	}
+	if err := r.db.GetContext(ctx, &out.Churn, churnQ, since, scope); err != nil {
+		r.logger.Error("failed to compute churn", zap.Error(err))
+		return SubscriptionStats{}, err
+	}
+	if out.Churn.ActiveAtStart > 0 {
+		out.Churn.Rate = float64(out.Churn.Churned) / float64(out.Churn.ActiveAtStart)
+	}
+	return out, nil
+}
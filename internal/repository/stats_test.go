@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestStatsRepository_Stats(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewStatsRepository(sqlxDB, zap.NewNop())
+
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY sc.city ORDER BY subscribers DESC, sc.city LIMIT $1;")).
+		WithArgs(10, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"city", "subscribers"}).AddRow("Kyiv", 7).AddRow("Lviv", 3))
+	mock.ExpectQuery(regexp.QuoteMeta("GROUP BY frequency ORDER BY frequency;")).
+		WithArgs(nil).
+		WillReturnRows(sqlmock.NewRows([]string{"frequency", "subscribers"}).AddRow("daily", 6).AddRow("hourly", 4))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM days LEFT JOIN confirmations c USING (day)")).
+		WithArgs(since, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "confirmations", "unsubscribes"}).
+			AddRow(since, 2, 0).AddRow(since.AddDate(0, 0, 1), 1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("AS churned FROM subscriptions WHERE confirmed_at < $1 AND ($2::text IS NULL OR tenant_id = $2);")).
+		WithArgs(since, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"active_at_start", "churned"}).AddRow(8, 2))
+
+	// any time of the day counts from its start
+	stats, err := repo.Stats(context.Background(), since.Add(15*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("Stats() error: %v", err)
+	}
+	if !stats.Since.Equal(since) {
+		t.Errorf("Since = %v, want %v", stats.Since, since)
+	}
+	if len(stats.ByCity) != 2 || stats.ByCity[0] != (CityCount{City: "Kyiv", Subscribers: 7}) {
+		t.Errorf("ByCity = %+v, want Kyiv first", stats.ByCity)
+	}
+	if len(stats.ByFrequency) != 2 || len(stats.Daily) != 2 || stats.Daily[1].Unsubscribes != 1 {
+		t.Errorf("ByFrequency = %+v, Daily = %+v", stats.ByFrequency, stats.Daily)
+	}
+	if stats.Churn.Rate != 0.25 {
+		t.Errorf("Churn.Rate = %v, want 0.25", stats.Churn.Rate)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -9,158 +9,722 @@ import (
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository/queries"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
 )
 
 type Subscription struct {
-	ID               int       `db:"id"`
-	Email            string    `db:"email"`
-	City             string    `db:"city"`
-	Frequency        string    `db:"frequency"` // 'hourly' | 'daily'
-	Confirmed        bool      `db:"confirmed"`
-	ConfirmToken     uuid.UUID `db:"confirm_token"`
-	UnsubscribeToken uuid.UUID `db:"unsubscribe_token"`
-	ScheduledMinute  int16     `db:"scheduled_minute"`
-	ScheduledHour    int16     `db:"scheduled_hour"`
-	CreatedAt        time.Time `db:"created_at"`
-}
-
-// SubscriptionRepository defines the five interactions you listed.
+	ID                    int            `db:"id"`
+	TenantID              string         `db:"tenant_id"`  // white-label newsletter it belongs to, see package tenant
+	Email                 string         `db:"email"`      // plaintext; stored encrypted
+	EmailHash             string         `db:"email_hash"` // blind index of Email, see pii.Cipher.BlindIndex
+	City                  string         `db:"city"`       // primary (first) city
+	Cities                CityList       `db:"cities"`     // all cities, in order; empty when not selected
+	Frequency             Frequency      `db:"frequency"`
+	Confirmed             bool           `db:"confirmed"`
+	ConfirmTokenHash      sql.NullString `db:"confirm_token_hash"`       // see hashToken; NULL once confirmed
+	ConfirmTokenExpiresAt sql.NullTime   `db:"confirm_token_expires_at"` // NULL never expires
+	UnsubscribeToken      uuid.UUID      `db:"-"`                        // derived from ID, see linktoken
+	UnsubscribeTokenHash  sql.NullString `db:"unsubscribe_token_hash"`   // see hashToken; of a legacy random token, NULL for newer subscriptions
+	ManageToken           uuid.UUID      `db:"-"`                        // derived from ID, see linktoken
+	ManageTokenHash       sql.NullString `db:"manage_token_hash"`        // like UnsubscribeTokenHash
+	Units                 Units          `db:"units"`
+	ScheduledMinute       int16          `db:"scheduled_minute"`
+	ScheduledHour         int16          `db:"scheduled_hour"`
+	SendHour              sql.NullInt16  `db:"send_hour"` // hour chosen on subscribe; derived from confirm time when NULL
+	Timezone              string         `db:"timezone"`  // IANA name; scheduled and send hours are local to it
+	CreatedAt             time.Time      `db:"created_at"`
+	ConfirmedAt           sql.NullTime   `db:"confirmed_at"`
+	WelcomeSentAt         sql.NullTime   `db:"welcome_sent_at"`
+	ExpiresAt             sql.NullTime   `db:"expires_at"`           // no updates after it until renewed; NULL never expires
+	BetaFeatures          bool           `db:"beta_features"`        // opted in to features in beta, see features.Flags
+	Locale                string         `db:"locale"`               // language of the emails, see i18n
+	FirstName             string         `db:"first_name"`           // optional, for greetings; plaintext, stored encrypted like Email
+	NotifyOnChange        bool           `db:"notify_on_change"`     // skip updates whose weather has not changed, see LastSentRepository
+	SlackWebhookURL       string         `db:"slack_webhook_url"`    // optional; updates are posted there instead of emailed; stored encrypted like Email
+	Phone                 string         `db:"phone"`                // optional, E.164; updates are texted there instead of emailed; stored encrypted like Email
+	Channel               Channel        `db:"channel"`              // how updates are delivered; empty (not selected) means email
+	ConsecutiveFailures   int16          `db:"consecutive_failures"` // failed sends since the last successful one
+	DeadLetteredAt        sql.NullTime   `db:"dead_lettered_at"`     // gets nothing once set, until revived
+	LastSentAt            sql.NullTime   `db:"last_sent_at"`         // last update delivered
+	LastAttemptedAt       sql.NullTime   `db:"last_attempted_at"`    // last update tried, delivered or not
+	SendClaimedUntil      sql.NullTime   `db:"send_claimed_until"`   // claimed by a batch being sent until then, see ClaimSends
+	DeletedAt             sql.NullTime   `db:"deleted_at"`           // unsubscribed; kept until purged, see PurgeDeletedOlderThan
+}
+
+// NewSubscription is what Create stores of a new, unconfirmed subscription.
+type NewSubscription struct {
+	Email           string
+	FirstName       string   // optional
+	Cities          []string // at least one; the first is the primary city
+	Frequency       Frequency
+	SendHour        *int16 // local hour of daily updates; nil lets the database pick one
+	Timezone        string
+	Locale          string
+	SlackWebhookURL string        // optional; updates are posted there instead of emailed
+	ConfirmTTL      time.Duration // how long the confirmation link is valid
+}
+
+// SubscriptionRepository defines every subscription query of the API, scheduler and admin tools.
 type SubscriptionRepository interface {
-	Create(ctx context.Context, email, city, freq string) (confirmToken uuid.UUID, unsubscribeToken uuid.UUID, err error)
-	Confirm(ctx context.Context, token uuid.UUID) error
-	DeleteByUnsubToken(ctx context.Context, token uuid.UUID) error
+	Create(ctx context.Context, n NewSubscription) (id int, confirmToken uuid.UUID, unsubscribeToken uuid.UUID, err error)
+	Confirm(ctx context.Context, token uuid.UUID, ttl time.Duration) (int, error)
+	RefreshConfirmToken(ctx context.Context, token uuid.UUID, confirmTTL time.Duration) (Subscription, uuid.UUID, error)
+	DeleteByUnsubToken(ctx context.Context, token uuid.UUID) (int, error)
+	DeleteUnconfirmed(ctx context.Context, confirmToken uuid.UUID) (Subscription, error)
+	DeleteUnconfirmedOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	PurgeDeletedOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error)
 	HourlyBatch(ctx context.Context, minute int) ([]Subscription, error)
-	DailyBatch(ctx context.Context, hour, minute int) ([]Subscription, error)
+	DailyBatch(ctx context.Context, at time.Time) ([]Subscription, error)
+	HourlyBatchPage(ctx context.Context, minute, afterID, limit int) ([]Subscription, error)
+	DailyBatchPage(ctx context.Context, at time.Time, afterID, limit int) ([]Subscription, error)
+	StreamHourlyBatch(ctx context.Context, minute int, fn func(Subscription) error) error
+	StreamDailyBatch(ctx context.Context, at time.Time, fn func(Subscription) error) error
+	GetByID(ctx context.Context, id int) (Subscription, error)
+	GetByEmail(ctx context.Context, email string) (Subscription, error)
+	ListByEmail(ctx context.Context, email string) ([]Subscription, error)
+	ListConfirmed(ctx context.Context) ([]Subscription, error)
+	ClaimWelcome(ctx context.Context, id int) (Subscription, error)
+	PendingWelcomes(ctx context.Context, since time.Time) ([]int, error)
+	List(ctx context.Context, filter SubscriptionFilter, limit, offset int) ([]Subscription, int, error)
+	ListAfter(ctx context.Context, filter SubscriptionFilter, afterID, limit int) ([]Subscription, int, error)
+	DeleteByID(ctx context.Context, id int) error
+	ActiveIDs(ctx context.Context, ids []int) (map[int]bool, error)
+	ActiveByIDs(ctx context.Context, ids []int) ([]Subscription, error)
+	ClaimSends(ctx context.Context, ids []int, lease time.Duration) ([]int, error)
+	RecordSends(ctx context.Context, attempted, sent []int) error
+	GetByManageToken(ctx context.Context, token uuid.UUID) (Subscription, error)
+	Update(ctx context.Context, manageToken uuid.UUID, u SubscriptionUpdate) (Subscription, error)
+	MergeCity(ctx context.Context, from, to string, dryRun bool) (CityMergeResult, error)
+	Counts(ctx context.Context) (SubscriptionCounts, error)
+	SubscribedCities(ctx context.Context) ([]string, error)
 }
 
 type pgRepo struct {
-	db     *sqlx.DB
-	logger *zap.Logger
+	db             *sqlx.DB
+	replica        *sqlx.DB // the batch queries and the admin listing, which tolerate replication lag
+	queries        *queries.Queries
+	replicaQueries *queries.Queries
+	pii            *pii.Cipher
+	tokens         *linktoken.Deriver
+	logger         *zap.Logger
 }
 
-func NewSubscriptionRepository(db *sqlx.DB, logger *zap.Logger) SubscriptionRepository {
-	return &pgRepo{db: db, logger: logger}
+// NewSubscriptionRepository stores emails encrypted with cipher (nil stores them as
+// plaintext) and derives the link tokens of subscriptions with tokens.
+func NewSubscriptionRepository(db *sqlx.DB, cipher *pii.Cipher, tokens *linktoken.Deriver, logger *zap.Logger,
+) SubscriptionRepository {
+	return NewReplicatedSubscriptionRepository(db, nil, cipher, tokens, logger)
 }
 
-// ErrEmailAlreadyExists is returned when attempting to subscribe an email that already exists.
-var ErrEmailAlreadyExists = errors.New("email already subscribed")
+// NewReplicatedSubscriptionRepository is NewSubscriptionRepository reading the batches
+// (HourlyBatch, DailyBatch and their pages and streams) and the admin listing and
+// counts from replica, a read replica of db; nil reads them from db. A lagging replica
+// may select a subscription just sent again: its send job is not queued twice, and the
+// send workers check it against db before sending.
+func NewReplicatedSubscriptionRepository(db, replica *sqlx.DB, cipher *pii.Cipher, tokens *linktoken.Deriver,
+	logger *zap.Logger,
+) SubscriptionRepository {
+	if replica == nil {
+		replica = db
+	}
+	return &pgRepo{db: db, replica: replica, queries: queries.New(db), replicaQueries: queries.New(replica), pii: cipher,
+		tokens: tokens, logger: logger}
+}
+
+// ErrEmailAlreadyExists is returned when the email is already subscribed for one of the
+// cities (in any of its subscriptions).
+var ErrEmailAlreadyExists = errors.New("email already subscribed for this city")
 
-func (r *pgRepo) Create(ctx context.Context, email, city, freq string,
-) (confirmToken uuid.UUID, unsubscribeToken uuid.UUID, err error) {
-	const q = `
-        INSERT INTO subscriptions (email, city, frequency)
-        VALUES ($1, $2, $3)
-        RETURNING confirm_token, unsubscribe_token;
-    `
+// ErrTokenExpired is returned by Confirm when the confirmation token exists but its
+// link has expired; RefreshConfirmToken issues a new one.
+var ErrTokenExpired = errors.New("confirmation link has expired")
 
-	// Scan both tokens in one go
-	row := r.db.QueryRowContext(ctx, q, email, city, freq)
-	if err := row.Scan(&confirmToken, &unsubscribeToken); err != nil {
-		// Check for Postgres unique‐violation on the email column (SQLSTATE 23505)
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+// isUniqueViolation reports a Postgres unique-violation (SQLSTATE 23505).
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// Create inserts an unconfirmed subscription for one or more cities; the first city is
+// the primary one. The schedule is kept in timezone (an IANA name): sendHour (daily
+// subscriptions only) is the local hour chosen by the subscriber, nil schedules the
+// subscription at the local time of its creation; the minute is that of the creation
+// either way. The slot is fixed here, Confirm keeps it. A positive confirmTTL makes the
+// confirmation token expire confirmTTL after now. firstName and slackWebhookURL are
+// optional and stored encrypted like email. The subscription belongs to the tenant of
+// ctx, the default one outside requests. It returns the id of the new subscription
+// with its tokens.
+//
+// The confirmation token is generated here and returned only once, it is stored as its
+// hash alone. The unsubscribe token is derived from the id (see linktoken) and not
+// stored at all.
+func (r *pgRepo) Create(ctx context.Context, n NewSubscription,
+) (id int, confirmToken uuid.UUID, unsubscribeToken uuid.UUID, err error) {
+	email, cities, freq := n.Email, n.Cities, n.Frequency
+	if len(cities) == 0 {
+		return 0, uuid.Nil, uuid.Nil, errors.New("at least one city is required")
+	}
+	encrypted, err := r.pii.Encrypt(email)
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, err
+	}
+	confirmToken = uuid.New()
+	storedName, err := encryptOptional(r.pii, n.FirstName)
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, err
+	}
+	storedWebhook, err := encryptOptional(r.pii, n.SlackWebhookURL)
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, err
+	}
+	channel := ChannelEmail
+	if n.SlackWebhookURL != "" {
+		channel = ChannelSlack
+	}
+
+	emailIndex := r.pii.BlindIndex(email)
+	var sendHour sql.NullInt16
+	if n.SendHour != nil {
+		sendHour = sql.NullInt16{Int16: *n.SendHour, Valid: true}
+	}
+	id, err = withOutboxEvent(ctx, r.db, r.logger, func(db outboxDB) (int, error) {
+		id, err := queries.New(db).CreateSubscription(ctx, queries.CreateSubscriptionParams{
+			Email:            encrypted,
+			EmailHash:        emailIndex,
+			City:             cities[0],
+			Frequency:        queries.SubscriptionFrequency(freq),
+			SendHour:         sendHour,
+			Timezone:         n.Timezone,
+			ConfirmTtl:       n.ConfirmTTL.Seconds(),
+			ConfirmTokenHash: hashToken(confirmToken),
+			Locale:           n.Locale,
+			FirstName:        storedName,
+			SlackWebhookUrl:  storedWebhook,
+			Channel:          queries.SubscriptionChannel(channel),
+			TenantID:         tenant.OrDefault(ctx),
+			Cities:           cities,
+		})
+		return int(id), err
+	})
+	if err != nil {
+		// Unique violation on (tenant, email, city): one of the cities is already subscribed
+		if isUniqueViolation(err) {
 			r.logger.Warn("duplicate email subscription attempt",
-				zap.String("email", email),
+				zap.String("email_index", emailIndex),
+				zap.Strings("cities", cities),
 			)
-			return uuid.Nil, uuid.Nil, ErrEmailAlreadyExists
+			return 0, uuid.Nil, uuid.Nil, ErrEmailAlreadyExists
 		}
 
 		r.logger.Error("failed to create subscription",
-			zap.String("email", email),
-			zap.String("city", city),
-			zap.String("frequency", freq),
+			zap.String("email_index", emailIndex),
+			zap.Strings("cities", cities),
+			zap.String("frequency", string(freq)),
 			zap.Error(err),
 		)
-		return uuid.Nil, uuid.Nil, err
+		return 0, uuid.Nil, uuid.Nil, err
 	}
+	unsubscribeToken = r.tokens.Token(linktoken.Unsubscribe, id)
 
 	r.logger.Debug("subscription created",
-		zap.String("email", email),
-		zap.String("city", city),
-		zap.String("frequency", freq),
+		zap.Int("id", id),
+		zap.Strings("cities", cities),
+		zap.String("frequency", string(freq)),
 		zap.String("confirm_token", confirmToken.String()),
 		zap.String("unsubscribe_token", unsubscribeToken.String()),
 	)
-	return confirmToken, unsubscribeToken, nil
+	return id, confirmToken, unsubscribeToken, nil
 }
 
-func (r *pgRepo) Confirm(ctx context.Context, token uuid.UUID) error {
-	// We are advancing scheduled_hour, scheduled_minute one minute ahead to receive first email in ~30 seconds
-	const q = `
-        UPDATE subscriptions
-        SET confirmed        = TRUE,
-            confirm_token    = NULL,
-            scheduled_hour   = EXTRACT(HOUR   FROM now() + INTERVAL '1 minute')::smallint,
-            scheduled_minute = EXTRACT(MINUTE FROM now() + INTERVAL '1 minute')::smallint
-        WHERE confirm_token = $1 AND confirmed = FALSE;
-    `
-	res, err := r.db.ExecContext(ctx, q, token)
+// Confirm activates a subscription and returns its id. A positive ttl makes it expire
+// ttl after now. It returns ErrTokenExpired when the token matches an unconfirmed
+// subscription whose confirmation link has expired, and sql.ErrNoRows when it matches
+// nothing.
+func (r *pgRepo) Confirm(ctx context.Context, token uuid.UUID, ttl time.Duration) (int, error) {
+	// The schedule slot was fixed by Create. The first email does not wait for it: the
+	// subscription_confirmed trigger notifies the scheduler, which sends it right away.
+	id, err := withOutboxEvent(ctx, r.db, r.logger, func(db outboxDB) (int, error) {
+		id, err := queries.New(db).ConfirmSubscription(ctx, queries.ConfirmSubscriptionParams{
+			Ttl:       ttl.Seconds(),
+			TokenHash: sql.NullString{String: hashToken(token), Valid: true},
+			Tenant:    tenantArg(ctx),
+		})
+		return int(id), err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Tell an expired link apart from an unknown one, so the subscriber can be
+		// offered a fresh link instead of a dead end.
+		expired, err := r.queries.ConfirmTokenExpired(ctx, queries.ConfirmTokenExpiredParams{
+			TokenHash: hashToken(token),
+			Tenant:    tenantArg(ctx),
+		})
+		if err != nil {
+			r.logger.Error("failed to check confirm token expiry", zap.String("token", token.String()), zap.Error(err))
+			return 0, err
+		}
+		if expired {
+			r.logger.Info("confirm token expired", zap.String("token", token.String()))
+			return 0, ErrTokenExpired
+		}
+		r.logger.Warn("confirm token not found or already confirmed", zap.String("token", token.String()))
+		return 0, sql.ErrNoRows
+	}
 	if err != nil {
 		r.logger.Error("failed to confirm subscription", zap.String("token", token.String()), zap.Error(err))
-		return err
+		return 0, err
+	}
+	r.logger.Info("subscription confirmed", zap.Int("id", id), zap.String("token", token.String()))
+	return id, nil
+}
+
+// RefreshConfirmToken replaces the confirmation token of an unconfirmed subscription,
+// expired or not, with a new one valid for confirmTTL (0: never expires) and returns
+// the subscription and the new token, which is not stored and cannot be read back.
+// It returns sql.ErrNoRows when token matches nothing.
+func (r *pgRepo) RefreshConfirmToken(ctx context.Context, token uuid.UUID, confirmTTL time.Duration,
+) (Subscription, uuid.UUID, error) {
+	fresh := uuid.New()
+	row, err := r.queries.RefreshConfirmToken(ctx, queries.RefreshConfirmTokenParams{
+		FreshTokenHash: hashToken(fresh),
+		ConfirmTtl:     confirmTTL.Seconds(),
+		TokenHash:      hashToken(token),
+		Tenant:         tenantArg(ctx),
+	})
+	var sub Subscription
+	if err == nil {
+		sub, err = subscriptionOf(row.Subscription, row.Cities)
 	}
-	n, err := res.RowsAffected()
 	if err != nil {
-		r.logger.Error("failed to get rows affected on confirm", zap.Error(err))
-		return err
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to refresh confirm token", zap.String("token", token.String()), zap.Error(err))
+		}
+		return Subscription{}, uuid.Nil, err
 	}
-	if n == 0 {
-		r.logger.Warn("confirm token not found or already confirmed", zap.String("token", token.String()))
-		return sql.ErrNoRows
+	if err := decryptSubscription(r.pii, r.tokens, &sub); err != nil {
+		r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+		return Subscription{}, uuid.Nil, err
 	}
-	r.logger.Info("subscription confirmed", zap.String("token", token.String()))
-	return nil
+	r.logger.Info("confirm token refreshed", zap.Int("id", sub.ID))
+	return sub, fresh, nil
 }
 
-func (r *pgRepo) DeleteByUnsubToken(ctx context.Context, token uuid.UUID) error {
-	const q = `DELETE FROM subscriptions WHERE unsubscribe_token = $1;`
-	res, err := r.db.ExecContext(ctx, q, token)
+// DeleteByUnsubToken soft-deletes the subscription of an unsubscribe token and returns
+// its id. The row is kept, marked deleted_at, for analytics and dispute resolution until
+// PurgeDeletedOlderThan removes it; every other query leaves it out. Its cities are
+// dropped right away, so the address can subscribe to them again.
+func (r *pgRepo) DeleteByUnsubToken(ctx context.Context, token uuid.UUID) (int, error) {
+	var derived sql.NullInt32
+	if id := tokenID(r.tokens, linktoken.Unsubscribe, token); id != nil {
+		derived = sql.NullInt32{Int32: int32(*id), Valid: true}
+	}
+	id, err := withOutboxEvent(ctx, r.db, r.logger, func(db outboxDB) (int, error) {
+		id, err := queries.New(db).DeleteByUnsubToken(ctx, queries.DeleteByUnsubTokenParams{
+			ID:        derived,
+			TokenHash: sql.NullString{String: hashToken(token), Valid: true},
+			Tenant:    tenantArg(ctx),
+		})
+		return int(id), err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Warn("unsubscribe token not found", zap.String("unsubscribe_token", token.String()))
+		return 0, err
+	}
 	if err != nil {
 		r.logger.Error("failed to delete subscription", zap.String("unsubscribe_token", token.String()), zap.Error(err))
-		return err
+		return 0, err
 	}
-	n, err := res.RowsAffected()
+	r.logger.Info("subscription deleted", zap.Int("id", id), zap.String("unsubscribe_token", token.String()))
+	return id, nil
+}
+
+// DeleteUnconfirmed deletes the unconfirmed subscription of a confirmation token, expired
+// or not, and returns it. It returns sql.ErrNoRows when token matches nothing, including
+// a subscription already confirmed.
+func (r *pgRepo) DeleteUnconfirmed(ctx context.Context, confirmToken uuid.UUID) (Subscription, error) {
+	var sub Subscription
+	if _, err := withOutboxEvent(ctx, r.db, r.logger, func(db outboxDB) (int, error) {
+		deleted, err := queries.New(db).DeleteUnconfirmed(ctx, queries.DeleteUnconfirmedParams{
+			TokenHash: hashToken(confirmToken),
+			Tenant:    tenantArg(ctx),
+		})
+		if err != nil {
+			return 0, err
+		}
+		sub, err = subscriptionOf(deleted, nil)
+		return sub.ID, err
+	}); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to delete unconfirmed subscription", zap.String("token", confirmToken.String()), zap.Error(err))
+		}
+		return Subscription{}, err
+	}
+	if err := decryptSubscription(r.pii, r.tokens, &sub); err != nil {
+		r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+		return Subscription{}, err
+	}
+	r.logger.Info("unconfirmed subscription deleted", zap.Int("id", sub.ID))
+	return sub, nil
+}
+
+// DeleteUnconfirmedOlderThan deletes up to limit subscriptions created before cutoff
+// and never confirmed, returning how many were deleted. Callers repeat it until fewer
+// than limit rows go, so no single statement holds locks for long.
+func (r *pgRepo) DeleteUnconfirmedOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	n, err := r.queries.DeleteUnconfirmedOlderThan(ctx, queries.DeleteUnconfirmedOlderThanParams{
+		Cutoff:  cutoff,
+		MaxRows: int32(limit),
+	})
 	if err != nil {
-		r.logger.Error("failed to get rows affected on delete", zap.Error(err))
-		return err
+		r.logger.Error("failed to delete unconfirmed subscriptions", zap.Time("cutoff", cutoff), zap.Error(err))
+		return 0, err
 	}
-	if n == 0 {
-		r.logger.Warn("unsubscribe token not found", zap.String("unsubscribe_token", token.String()))
-		return sql.ErrNoRows
+	return n, nil
+}
+
+// PurgeDeletedOlderThan hard-deletes up to limit subscriptions unsubscribed before
+// cutoff, returning how many were deleted. Like DeleteUnconfirmedOlderThan, callers
+// repeat it until fewer than limit rows go.
+func (r *pgRepo) PurgeDeletedOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	n, err := r.queries.PurgeDeletedOlderThan(ctx, queries.PurgeDeletedOlderThanParams{
+		Cutoff:  cutoff,
+		MaxRows: int32(limit),
+	})
+	if err != nil {
+		r.logger.Error("failed to purge deleted subscriptions", zap.Time("cutoff", cutoff), zap.Error(err))
+		return 0, err
 	}
-	r.logger.Info("subscription deleted", zap.String("unsubscribe_token", token.String()))
-	return nil
+	return n, nil
 }
 
+// batchColumns are the columns the scheduler needs to render and send an update; the
+// link tokens are derived from the id. Together with the WHERE columns but last_sent_at
+// they are covered by the partial batch indexes of each frequency (idx_subs_hourly,
+// idx_subs_daily); cities come from the subscription_cities primary key.
+// Expired subscriptions are left out of batches (activeCondition).
+const batchColumns = `id, tenant_id, email, city, frequency, confirmed, units,
+               scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale,
+               first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, ` + citiesColumn
+
+// activeCondition holds for subscriptions that receive updates: confirmed, not expired,
+// not dead-lettered and not unsubscribed.
+const activeCondition = `confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL`
+
+// unsentCondition leaves out subscriptions sent an update within the current window of
+// their frequency, so that a rerun or caught-up tick does not send it again. A window
+// is half the period: an update sent late after retries does not cost the next one.
+const unsentCondition = `(last_sent_at IS NULL OR last_sent_at < now() -
+              CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END)`
+
+// The batch queries (HourlyBatch, DailyBatch, their pages, ListConfirmed, ActiveByIDs)
+// select batchColumns; their conditions repeat activeCondition and unsentCondition.
+
 func (r *pgRepo) HourlyBatch(ctx context.Context, minute int) ([]Subscription, error) {
-	const q = `
-        SELECT * FROM subscriptions
-        WHERE confirmed       = TRUE
-          AND frequency       = 'hourly'
-          AND scheduled_minute= $1;
-    `
+	rows, err := r.replicaQueries.HourlyBatch(ctx, int16(minute))
 	var subs []Subscription
-	if err := r.db.SelectContext(ctx, &subs, q, minute); err != nil {
+	if err == nil {
+		subs, err = batchSubscriptions(rows)
+	}
+	if err != nil {
 		r.logger.Error("failed to fetch hourly batch", zap.Int("minute", minute), zap.Error(err))
 		return nil, err
 	}
+	subs = decryptSubscriptions(r.pii, r.tokens, r.logger, subs)
 	r.logger.Debug("fetched hourly batch", zap.Int("minute", minute), zap.Int("count", len(subs)))
 	return subs, nil
 }
 
-func (r *pgRepo) DailyBatch(ctx context.Context, hour, minute int) ([]Subscription, error) {
-	const q = `
-        SELECT * FROM subscriptions
-        WHERE confirmed        = TRUE
-          AND frequency        = 'daily'
-          AND scheduled_hour   = $1
-          AND scheduled_minute = $2;
-    `
+func (r *pgRepo) DailyBatch(ctx context.Context, at time.Time) ([]Subscription, error) {
+	rows, err := r.replicaQueries.DailyBatch(ctx, at)
 	var subs []Subscription
-	if err := r.db.SelectContext(ctx, &subs, q, hour, minute); err != nil {
-		r.logger.Error("failed to fetch daily batch", zap.Int("hour", hour), zap.Int("minute", minute), zap.Error(err))
+	if err == nil {
+		subs, err = batchSubscriptions(rows)
+	}
+	if err != nil {
+		r.logger.Error("failed to fetch daily batch", zap.Time("at", at), zap.Error(err))
 		return nil, err
 	}
-	r.logger.Debug("fetched daily batch", zap.Int("hour", hour), zap.Int("minute", minute), zap.Int("count", len(subs)))
+	subs = decryptSubscriptions(r.pii, r.tokens, r.logger, subs)
+	r.logger.Debug("fetched daily batch", zap.Time("at", at), zap.Int("count", len(subs)))
 	return subs, nil
 }
+
+// HourlyBatchPage returns up to limit subscriptions of the hourly batch of minute with
+// an id above afterID, in id order: pass the last id of a page to read the next one.
+func (r *pgRepo) HourlyBatchPage(ctx context.Context, minute, afterID, limit int) ([]Subscription, error) {
+	rows, err := r.replicaQueries.HourlyBatchPage(ctx, queries.HourlyBatchPageParams{
+		Minute:  int16(minute),
+		AfterID: int32(afterID),
+		MaxRows: int32(limit),
+	})
+	var subs []Subscription
+	if err == nil {
+		subs, err = batchSubscriptions(rows)
+	}
+	if err != nil {
+		r.logger.Error("failed to fetch hourly batch page", zap.Int("minute", minute), zap.Int("after", afterID),
+			zap.Error(err))
+		return nil, err
+	}
+	return decryptSubscriptions(r.pii, r.tokens, r.logger, subs), nil
+}
+
+// DailyBatchPage returns up to limit subscriptions of the daily batch at with an id
+// above afterID, in id order: pass the last id of a page to read the next one.
+func (r *pgRepo) DailyBatchPage(ctx context.Context, at time.Time, afterID, limit int) ([]Subscription, error) {
+	rows, err := r.replicaQueries.DailyBatchPage(ctx, queries.DailyBatchPageParams{
+		At:      at,
+		AfterID: int32(afterID),
+		MaxRows: int32(limit),
+	})
+	var subs []Subscription
+	if err == nil {
+		subs, err = batchSubscriptions(rows)
+	}
+	if err != nil {
+		r.logger.Error("failed to fetch daily batch page", zap.Time("at", at), zap.Int("after", afterID),
+			zap.Error(err))
+		return nil, err
+	}
+	return decryptSubscriptions(r.pii, r.tokens, r.logger, subs), nil
+}
+
+// StreamHourlyBatch reads the hourly batch of minute in one query and calls fn with
+// each subscription as it is read, so the batch is never held in memory whole. It
+// stops at the first error of fn and returns it.
+func (r *pgRepo) StreamHourlyBatch(ctx context.Context, minute int, fn func(Subscription) error) error {
+	if err := r.streamBatch(ctx, fn, queries.HourlyBatch, minute); err != nil {
+		r.logger.Error("failed to stream hourly batch", zap.Int("minute", minute), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// StreamDailyBatch reads the daily batch at in one query and calls fn with each
+// subscription as it is read, so the batch is never held in memory whole. It stops at
+// the first error of fn and returns it.
+func (r *pgRepo) StreamDailyBatch(ctx context.Context, at time.Time, fn func(Subscription) error) error {
+	if err := r.streamBatch(ctx, fn, queries.DailyBatch, at); err != nil {
+		r.logger.Error("failed to stream daily batch", zap.Time("at", at), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// streamBatch runs the batch query q, the SQL of a generated one, and hands each row to
+// fn as it is read, decrypted (the generated Queries collect every row first); rows that
+// fail to decrypt are logged and skipped, as by decryptSubscriptions.
+func (r *pgRepo) streamBatch(ctx context.Context, fn func(Subscription) error, q string, args ...any) error {
+	rows, err := r.replica.QueryxContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.StructScan(&sub); err != nil {
+			return err
+		}
+		if err := decryptSubscription(r.pii, r.tokens, &sub); err != nil {
+			r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+			continue
+		}
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetByID returns a single subscription, or sql.ErrNoRows if it does not exist or was
+// unsubscribed.
+func (r *pgRepo) GetByID(ctx context.Context, id int) (Subscription, error) {
+	row, err := r.queries.GetByID(ctx, queries.GetByIDParams{ID: int32(id), Tenant: tenantArg(ctx)})
+	var sub Subscription
+	if err == nil {
+		sub, err = subscriptionOf(row.Subscription, row.Cities)
+	}
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to get subscription", zap.Int("id", id), zap.Error(err))
+		}
+		return Subscription{}, err
+	}
+	if err := decryptSubscription(r.pii, r.tokens, &sub); err != nil {
+		r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// GetByEmail returns the newest subscription of an address (case-insensitive), or
+// sql.ErrNoRows if it has none, unsubscribed ones aside.
+func (r *pgRepo) GetByEmail(ctx context.Context, email string) (Subscription, error) {
+	row, err := r.queries.GetByEmail(ctx, queries.GetByEmailParams{
+		EmailHash: r.pii.BlindIndex(email),
+		Tenant:    tenantArg(ctx),
+	})
+	var sub Subscription
+	if err == nil {
+		sub, err = subscriptionOf(row.Subscription, row.Cities)
+	}
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to get subscription by email", zap.Error(err))
+		}
+		return Subscription{}, err
+	}
+	if err := decryptSubscription(r.pii, r.tokens, &sub); err != nil {
+		r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// ListByEmail returns every subscription of an address (case-insensitive), newest
+// first; none is an empty list. Emails are matched through their blind index.
+// Unsubscribed subscriptions not yet purged are listed too (DeletedAt set), for
+// dispute resolution.
+func (r *pgRepo) ListByEmail(ctx context.Context, email string) ([]Subscription, error) {
+	rows, err := r.queries.ListByEmail(ctx, queries.ListByEmailParams{
+		EmailHash: r.pii.BlindIndex(email),
+		Tenant:    tenantArg(ctx),
+	})
+	var subs []Subscription
+	for _, row := range rows {
+		var sub Subscription
+		if sub, err = subscriptionOf(row.Subscription, row.Cities); err != nil {
+			break
+		}
+		subs = append(subs, sub)
+	}
+	if err != nil {
+		r.logger.Error("failed to list subscriptions by email", zap.Error(err))
+		return nil, err
+	}
+	return decryptSubscriptions(r.pii, r.tokens, r.logger, subs), nil
+}
+
+// ListConfirmed returns every confirmed subscription, used to build the scheduler's in-memory index.
+func (r *pgRepo) ListConfirmed(ctx context.Context) ([]Subscription, error) {
+	rows, err := r.queries.ListConfirmed(ctx)
+	var subs []Subscription
+	if err == nil {
+		subs, err = batchSubscriptions(rows)
+	}
+	if err != nil {
+		r.logger.Error("failed to list confirmed subscriptions", zap.Error(err))
+		return nil, err
+	}
+	subs = decryptSubscriptions(r.pii, r.tokens, r.logger, subs)
+	r.logger.Debug("listed confirmed subscriptions", zap.Int("count", len(subs)))
+	return subs, nil
+}
+
+// ClaimWelcome atomically marks the welcome email of a confirmed subscription as sent
+// and returns the subscription. It returns sql.ErrNoRows when the subscription is gone,
+// unconfirmed, or already claimed (e.g. by another scheduler replica), so the welcome
+// email is sent at most once.
+func (r *pgRepo) ClaimWelcome(ctx context.Context, id int) (Subscription, error) {
+	row, err := r.queries.ClaimWelcome(ctx, int32(id))
+	var sub Subscription
+	if err == nil {
+		sub, err = subscriptionOf(row.Subscription, row.Cities)
+	}
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to claim welcome email", zap.Int("id", id), zap.Error(err))
+		}
+		return Subscription{}, err
+	}
+	if err := decryptSubscription(r.pii, r.tokens, &sub); err != nil {
+		r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// PendingWelcomes returns ids of subscriptions confirmed since `since` whose welcome
+// email has not been sent, e.g. because the scheduler missed the notification.
+func (r *pgRepo) PendingWelcomes(ctx context.Context, since time.Time) ([]int, error) {
+	ids, err := r.queries.PendingWelcomes(ctx, since)
+	if err != nil {
+		r.logger.Error("failed to list pending welcome emails", zap.Error(err))
+		return nil, err
+	}
+	return ints(ids), nil
+}
+
+// ActiveIDs returns which of ids still belong to existing, confirmed, unexpired subscriptions.
+// The scheduler calls it right before sending, so rows unsubscribed after batch
+// selection are not emailed. Only committed rows are visible (READ COMMITTED).
+func (r *pgRepo) ActiveIDs(ctx context.Context, ids []int) (map[int]bool, error) {
+	active := make(map[int]bool, len(ids))
+	if len(ids) == 0 {
+		return active, nil
+	}
+	found, err := r.queries.ActiveIDs(ctx, int64s(ids))
+	if err != nil {
+		r.logger.Error("failed to verify active subscriptions", zap.Int("count", len(ids)), zap.Error(err))
+		return nil, err
+	}
+	for _, id := range found {
+		active[int(id)] = true
+	}
+	return active, nil
+}
+
+// ActiveByIDs returns the subscriptions of ids that may still be emailed and were not
+// sent an update in the current window, as selected by the batch queries; the others
+// are missing.
+func (r *pgRepo) ActiveByIDs(ctx context.Context, ids []int) ([]Subscription, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := r.queries.ActiveByIDs(ctx, int64s(ids))
+	var subs []Subscription
+	if err == nil {
+		subs, err = batchSubscriptions(rows)
+	}
+	if err != nil {
+		r.logger.Error("failed to fetch subscriptions by id", zap.Int("count", len(ids)), zap.Error(err))
+		return nil, err
+	}
+	return decryptSubscriptions(r.pii, r.tokens, r.logger, subs), nil
+}
+
+// ClaimSends claims the subscriptions of ids for the batch about to send them their
+// update, for lease, and returns the ids claimed: those that may still be emailed, were
+// not sent an update in the current window and are not claimed by another batch. The
+// claim is one UPDATE ... RETURNING, so of two overlapping runs only one gets each
+// subscription; RecordSends releases it, a run that dies leaves it to expire.
+func (r *pgRepo) ClaimSends(ctx context.Context, ids []int, lease time.Duration) ([]int, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	claimed, err := r.queries.ClaimSends(ctx, queries.ClaimSendsParams{Lease: lease.Seconds(), Ids: int64s(ids)})
+	if err != nil {
+		r.logger.Error("failed to claim subscriptions for sending", zap.Int("count", len(ids)), zap.Error(err))
+		return nil, err
+	}
+	return ints(claimed), nil
+}
+
+// RecordSends records that the subscriptions attempted were tried an update now, and
+// that those sent were delivered one, releasing their claims (see ClaimSends).
+func (r *pgRepo) RecordSends(ctx context.Context, attempted, sent []int) error {
+	if len(attempted) == 0 {
+		return nil
+	}
+	if err := r.queries.RecordSends(ctx, queries.RecordSendsParams{
+		Attempted: int64s(attempted),
+		Sent:      int64s(sent),
+	}); err != nil {
+		r.logger.Error("failed to record sends", zap.Int("count", len(attempted)), zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// SubscriptionFilter narrows List results; zero values mean "any".
+type SubscriptionFilter struct {
+	City      string
+	Frequency Frequency
+	Confirmed *bool
+	Email     string
+	// DeadLettered keeps only dead-lettered subscriptions
+	DeadLettered bool
+	// Deleted keeps only unsubscribed subscriptions not yet purged; they are left out
+	// otherwise
+	Deleted bool
+	// CreatedFrom and CreatedTo keep subscriptions created in [CreatedFrom, CreatedTo)
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// where renders the filter as a WHERE clause with positional args; emails are matched
+// through their blind index. A non-nil tenantID (see tenantScope) keeps the
+// subscriptions of that tenant only.
+func (f SubscriptionFilter) where(c *pii.Cipher, tenantID any) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if tenantID != nil {
+		add("tenant_id = $%d", tenantID)
+	}
+	if f.City != "" {
+		add("id IN (SELECT subscription_id FROM subscription_cities WHERE city = $%d)", f.City)
+	}
+	if f.Frequency != "" {
+		add("frequency = $%d", f.Frequency)
+	}
+	if f.Confirmed != nil {
+		add("confirmed = $%d", *f.Confirmed)
+	}
+	if f.Email != "" {
+		add("email_hash = $%d", c.BlindIndex(f.Email))
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("created_at >= $%d", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("created_at < $%d", f.CreatedTo)
+	}
+	if f.DeadLettered {
+		conds = append(conds, "dead_lettered_at IS NOT NULL")
+	}
+	if f.Deleted {
+		conds = append(conds, "deleted_at IS NOT NULL")
+	} else {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// List returns one page of subscriptions matching filter, newest first,
+// together with the total number of matches.
+func (r *pgRepo) List(ctx context.Context, filter SubscriptionFilter, limit, offset int) ([]Subscription, int, error) {
+	where, args := filter.where(r.pii, tenantScope(ctx))
+
+	var total int
+	if err := r.replica.GetContext(ctx, &total, "SELECT count(*) FROM subscriptions"+where+";", args...); err != nil {
+		r.logger.Error("failed to count subscriptions", zap.Error(err))
+		return nil, 0, err
+	}
+
+	q := fmt.Sprintf("SELECT *, "+citiesColumn+" FROM subscriptions%s ORDER BY id DESC LIMIT $%d OFFSET $%d;",
+		where, len(args)+1, len(args)+2)
+	var subs []Subscription
+	if err := r.replica.SelectContext(ctx, &subs, q, append(args, limit, offset)...); err != nil {
+		r.logger.Error("failed to list subscriptions", zap.Error(err))
+		return nil, 0, err
+	}
+	return decryptSubscriptions(r.pii, r.tokens, r.logger, subs), total, nil
+}
+
+// ListAfter returns up to limit subscriptions matching filter whose id is above afterID,
+// by id, and the cursor to pass as afterID for the next ones (0 once none is left): a
+// keyset walk over every match, e.g. for exports, without the cost of OFFSET on deep
+// pages. The cursor moves past rows that failed to decrypt, which are left out.
+func (r *pgRepo) ListAfter(ctx context.Context, filter SubscriptionFilter, afterID, limit int) ([]Subscription, int, error) {
+	where, args := filter.where(r.pii, tenantScope(ctx))
+	q := fmt.Sprintf("SELECT *, "+citiesColumn+" FROM subscriptions%s AND id > $%d ORDER BY id LIMIT $%d;",
+		where, len(args)+1, len(args)+2)
+	var subs []Subscription
+	if err := r.replica.SelectContext(ctx, &subs, q, append(args, afterID, limit)...); err != nil {
+		r.logger.Error("failed to list subscriptions after cursor", zap.Int("after_id", afterID), zap.Error(err))
+		return nil, 0, err
+	}
+	next := 0
+	if len(subs) == limit {
+		next = subs[len(subs)-1].ID
+	}
+	return decryptSubscriptions(r.pii, r.tokens, r.logger, subs), next, nil
+}
+
+// DeleteByID removes a subscription, returning sql.ErrNoRows if it does not exist.
+func (r *pgRepo) DeleteByID(ctx context.Context, id int) error {
+	const q = `DELETE FROM subscriptions WHERE id = $1 AND ($2::text IS NULL OR tenant_id = $2);`
+	res, err := r.db.ExecContext(ctx, q, id, tenantScope(ctx))
+	if err != nil {
+		r.logger.Error("failed to delete subscription", zap.Int("id", id), zap.Error(err))
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("failed to get rows affected on delete", zap.Error(err))
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SubscriptionCounts is the number of subscriptions by state.
+type SubscriptionCounts struct {
+	Confirmed   int `db:"confirmed"`
+	Unconfirmed int `db:"unconfirmed"`
+}
+
+// Counts counts the subscriptions that are and are not confirmed, unsubscribed ones aside.
+func (r *pgRepo) Counts(ctx context.Context) (SubscriptionCounts, error) {
+	const q = `
+		SELECT count(*) FILTER (WHERE confirmed)     AS confirmed,
+		       count(*) FILTER (WHERE NOT confirmed) AS unconfirmed
+		FROM subscriptions
+		WHERE deleted_at IS NULL AND ($1::text IS NULL OR tenant_id = $1);
+	`
+	var c SubscriptionCounts
+	if err := r.replica.GetContext(ctx, &c, q, tenantScope(ctx)); err != nil {
+		r.logger.Error("failed to count subscriptions", zap.Error(err))
+		return SubscriptionCounts{}, err
+	}
+	return c, nil
+}
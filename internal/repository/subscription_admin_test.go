@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestSubscriptionRepository_List_WithFilters(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	confirmed := true
+	filter := SubscriptionFilter{City: "Kyiv", Frequency: "daily", Confirmed: &confirmed}
+
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT count(*) FROM subscriptions WHERE id IN (SELECT subscription_id FROM subscription_cities WHERE city = $1) AND frequency = $2 AND confirmed = $3",
+	)).
+		WithArgs("Kyiv", "daily", true).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"SELECT *, "+citiesColumn+" FROM subscriptions WHERE id IN (SELECT subscription_id FROM subscription_cities WHERE city = $1) AND frequency = $2 AND confirmed = $3 AND deleted_at IS NULL ORDER BY id DESC LIMIT $4 OFFSET $5",
+	)).
+		WithArgs("Kyiv", "daily", true, 20, 40).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city"}).AddRow(7, "a@b.com", "Kyiv"))
+
+	subs, total, err := repo.List(context.Background(), filter, 20, 40)
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if total != 42 || len(subs) != 1 || subs[0].ID != 7 {
+		t.Errorf("List() = %+v, total %d; want one row with id 7, total 42", subs, total)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_List_NoFilters(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM subscriptions WHERE deleted_at IS NULL;")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT *, "+citiesColumn+" FROM subscriptions WHERE deleted_at IS NULL ORDER BY id DESC LIMIT $1 OFFSET $2")).
+		WithArgs(10, 0).
+		WillReturnRows(sqlmock.NewRows(nil))
+
+	subs, total, err := repo.List(context.Background(), SubscriptionFilter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("List() unexpected error: %v", err)
+	}
+	if total != 0 || len(subs) != 0 {
+		t.Errorf("List() = %d rows, total %d; want none", len(subs), total)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_ListAfter_KeysetCursor(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	filter := SubscriptionFilter{Frequency: "hourly", CreatedFrom: from, CreatedTo: to}
+	query := regexp.QuoteMeta("SELECT *, " + citiesColumn + " FROM subscriptions WHERE frequency = $1 AND created_at >= $2 AND created_at < $3 AND deleted_at IS NULL AND id > $4 ORDER BY id LIMIT $5;")
+
+	mock.ExpectQuery(query).WithArgs("hourly", from, to, 0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city"}).AddRow(3, "a@b.com", "Kyiv").AddRow(8, "c@d.com", "Lviv"))
+	mock.ExpectQuery(query).WithArgs("hourly", from, to, 8, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city"}).AddRow(11, "e@f.com", "Odesa"))
+
+	subs, next, err := repo.ListAfter(context.Background(), filter, 0, 2)
+	if err != nil || len(subs) != 2 || next != 8 {
+		t.Fatalf("ListAfter(0) = %d rows, next %d, %v; want 2 rows, next 8", len(subs), next, err)
+	}
+	subs, next, err = repo.ListAfter(context.Background(), filter, next, 2)
+	if err != nil || len(subs) != 1 || subs[0].ID != 11 || next != 0 {
+		t.Errorf("ListAfter(8) = %+v, next %d, %v; want the row with id 11, then done", subs, next, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_Counts(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectQuery(regexp.QuoteMeta("count(*) FILTER (WHERE confirmed) AS confirmed")).
+		WillReturnRows(sqlmock.NewRows([]string{"confirmed", "unconfirmed"}).AddRow(120, 7))
+
+	got, err := repo.Counts(context.Background())
+	if err != nil {
+		t.Fatalf("Counts() unexpected error: %v", err)
+	}
+	if got != (SubscriptionCounts{Confirmed: 120, Unconfirmed: 7}) {
+		t.Errorf("Counts() = %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
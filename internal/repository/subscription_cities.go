@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// MaxCitiesPerSubscription bounds how many cities one subscription can track.
+const MaxCitiesPerSubscription = 10
+
+// citiesColumn aggregates the cities of each selected subscription, in order.
+const citiesColumn = `COALESCE((SELECT json_agg(sc.city ORDER BY sc.position)
+                 FROM subscription_cities sc
+                 WHERE sc.subscription_id = subscriptions.id), '[]') AS cities`
+
+// CityList is the ordered list of cities of a subscription, scanned from citiesColumn.
+type CityList []string
+
+// Scan implements sql.Scanner for the JSON array produced by citiesColumn.
+func (l *CityList) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into CityList", src)
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// AllCities returns the cities of sub, falling back to the primary city when the
+// list was not selected.
+func (s Subscription) AllCities() []string {
+	if len(s.Cities) > 0 {
+		return s.Cities
+	}
+	return []string{s.City}
+}
+
+// replaceCities stores cities as the ordered city list of subscription id.
+func replaceCities(ctx context.Context, tx *sqlx.Tx, id int, cities []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subscription_cities WHERE subscription_id = $1;`, id); err != nil {
+		return err
+	}
+	const q = `
+        INSERT INTO subscription_cities (subscription_id, email_hash, city, position, tenant_id)
+        SELECT s.id, s.email_hash, x.city, x.ord - 1, s.tenant_id
+        FROM subscriptions s, unnest($2::text[]) WITH ORDINALITY AS x(city, ord)
+        WHERE s.id = $1;
+    `
+	_, err := tx.ExecContext(ctx, q, id, cities)
+	return err
+}
+
+// SubscribedCities returns the cities of every active subscription, primary or not,
+// once each whatever their letter case.
+func (r *pgRepo) SubscribedCities(ctx context.Context) ([]string, error) {
+	cities, err := r.replicaQueries.SubscribedCities(ctx)
+	if err != nil {
+		r.logger.Error("failed to list subscribed cities", zap.Error(err))
+		return nil, err
+	}
+	return cities, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// Kinds of subscription_events. Each is recorded by the statement making the
+// transition, so the history cannot miss one that happened.
+const (
+	SubscriptionEventCreated      = "created"
+	SubscriptionEventConfirmed    = "confirmed"
+	SubscriptionEventUnsubscribed = "unsubscribed"
+	SubscriptionEventDeadLettered = "dead_lettered" // sends kept failing: paused until revived
+	SubscriptionEventRevived      = "revived"
+	SubscriptionEventBounced      = "bounced" // a bounce or complaint about its address
+)
+
+// SubscriptionEvent is one state transition of a subscription.
+type SubscriptionEvent struct {
+	ID             int64     `db:"id"`
+	SubscriptionID int       `db:"subscription_id"`
+	Kind           string    `db:"kind"`
+	Detail         string    `db:"detail"` // free text, e.g. the bounce type; may be empty
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// SubscriptionEventRepository reads the history of subscriptions, and records the
+// transitions made outside the subscription queries.
+type SubscriptionEventRepository interface {
+	// RecordByEmail records an event of kind for every subscription of email not
+	// unsubscribed, returning how many it recorded.
+	RecordByEmail(ctx context.Context, email, kind, detail string) (int64, error)
+	// ListEvents returns the history of a subscription, oldest first; none is an
+	// empty list.
+	ListEvents(ctx context.Context, subscriptionID int) ([]SubscriptionEvent, error)
+}
+
+type pgSubscriptionEventRepo struct {
+	db     *sqlx.DB
+	pii    *pii.Cipher
+	logger *zap.Logger
+}
+
+// NewSubscriptionEventRepository matches emails through the blind index of cipher.
+func NewSubscriptionEventRepository(db *sqlx.DB, cipher *pii.Cipher, logger *zap.Logger) SubscriptionEventRepository {
+	return &pgSubscriptionEventRepo{db: db, pii: cipher, logger: logger}
+}
+
+func (r *pgSubscriptionEventRepo) RecordByEmail(ctx context.Context, email, kind, detail string) (int64, error) {
+	const q = `
+        INSERT INTO subscription_events (subscription_id, kind, detail)
+        SELECT id, $2, $3 FROM subscriptions WHERE email_hash = $1 AND deleted_at IS NULL;
+    `
+	res, err := r.db.ExecContext(ctx, q, r.pii.BlindIndex(email), kind, detail)
+	if err != nil {
+		r.logger.Error("failed to record subscription event", zap.String("kind", kind), zap.Error(err))
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("failed to get rows affected on subscription event", zap.Error(err))
+		return 0, err
+	}
+	return n, nil
+}
+
+func (r *pgSubscriptionEventRepo) ListEvents(ctx context.Context, subscriptionID int) ([]SubscriptionEvent, error) {
+	const q = `
+        SELECT id, subscription_id, kind, detail, created_at
+        FROM subscription_events
+        WHERE subscription_id = $1
+          AND ($2::text IS NULL OR EXISTS (SELECT 1 FROM subscriptions WHERE id = $1 AND tenant_id = $2))
+        ORDER BY id;
+    `
+	events := []SubscriptionEvent{}
+	if err := r.db.SelectContext(ctx, &events, q, subscriptionID, tenantScope(ctx)); err != nil {
+		r.logger.Error("failed to list subscription events", zap.Int("subscription_id", subscriptionID), zap.Error(err))
+		return nil, err
+	}
+	return events, nil
+}
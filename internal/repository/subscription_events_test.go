@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+func TestSubscriptionEventRepository_RecordByEmail(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionEventRepository(sqlxDB, nil, zap.NewNop())
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO subscription_events (subscription_id, kind, detail) SELECT id, $2, $3 FROM subscriptions WHERE email_hash = $1 AND deleted_at IS NULL;")).
+		WithArgs((*pii.Cipher)(nil).BlindIndex("A@Example.com"), SubscriptionEventBounced, "permanent bounce").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.RecordByEmail(context.Background(), "A@Example.com", SubscriptionEventBounced, "permanent bounce")
+	if err != nil || n != 2 {
+		t.Errorf("RecordByEmail() = %d, %v; want 2, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionEventRepository_ListEvents(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionEventRepository(sqlxDB, nil, zap.NewNop())
+
+	at := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM subscription_events WHERE subscription_id = $1 AND ($2::text IS NULL OR EXISTS (SELECT 1 FROM subscriptions WHERE id = $1 AND tenant_id = $2)) ORDER BY id;")).
+		WithArgs(7, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subscription_id", "kind", "detail", "created_at"}).
+			AddRow(1, 7, SubscriptionEventCreated, "", at).
+			AddRow(2, 7, SubscriptionEventDeadLettered, "5 failed sends in a row", at.Add(time.Hour)))
+
+	events, err := repo.ListEvents(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("ListEvents() unexpected error: %v", err)
+	}
+	if len(events) != 2 || events[0].Kind != SubscriptionEventCreated || events[1].Detail != "5 failed sends in a row" {
+		t.Errorf("ListEvents() = %+v", events)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
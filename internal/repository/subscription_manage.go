@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+)
+
+// SubscriptionUpdate holds subscriber-editable preferences; nil fields are left unchanged.
+// A non-nil Cities replaces the whole city list, its first entry becoming the primary city.
+type SubscriptionUpdate struct {
+	Cities          []string
+	Frequency       *Frequency
+	Units           *Units
+	ScheduledHour   *int16
+	ScheduledMinute *int16
+	Timezone        *string // IANA name; the scheduled time is kept as local wall-clock time
+	BetaFeatures    *bool
+	Locale          *string // one of i18n's locales
+	NotifyOnChange  *bool
+}
+
+// GetByManageToken returns the subscription owning a manage link, or sql.ErrNoRows.
+func (r *pgRepo) GetByManageToken(ctx context.Context, token uuid.UUID) (Subscription, error) {
+	const q = `
+        SELECT *, ` + citiesColumn + ` FROM subscriptions
+        WHERE (id = $3 OR manage_token_hash = $1) AND deleted_at IS NULL AND ($2::text IS NULL OR tenant_id = $2);
+    `
+	var sub Subscription
+	if err := r.db.GetContext(ctx, &sub, q, hashToken(token), tenantScope(ctx),
+		tokenID(r.tokens, linktoken.Manage, token)); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to get subscription by manage token", zap.Error(err))
+		}
+		return Subscription{}, err
+	}
+	if err := decryptSubscription(r.pii, r.tokens, &sub); err != nil {
+		r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Update applies u to the subscription owning manageToken and returns the updated row,
+// or sql.ErrNoRows if the token matches nothing. ErrEmailAlreadyExists is returned when
+// a new city is already subscribed by the same email in another subscription.
+func (r *pgRepo) Update(ctx context.Context, manageToken uuid.UUID, u SubscriptionUpdate) (Subscription, error) {
+	var primary *string
+	if len(u.Cities) > 0 {
+		primary = &u.Cities[0]
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.Error("failed to begin update transaction", zap.Error(err))
+		return Subscription{}, err
+	}
+	defer tx.Rollback()
+
+	const q = `
+        UPDATE subscriptions
+        SET city             = COALESCE($2, city),
+            frequency        = COALESCE($3, frequency),
+            units            = COALESCE($4, units),
+            scheduled_hour   = COALESCE($5, scheduled_hour),
+            scheduled_minute = COALESCE($6, scheduled_minute),
+            timezone         = COALESCE($7, timezone),
+            beta_features    = COALESCE($8, beta_features),
+            locale           = COALESCE($9, locale),
+            notify_on_change = COALESCE($10, notify_on_change)
+        WHERE (id = $12 OR manage_token_hash = $1) AND deleted_at IS NULL AND ($11::text IS NULL OR tenant_id = $11)
+        RETURNING *;
+    `
+	var sub Subscription
+	err = tx.GetContext(ctx, &sub, q, hashToken(manageToken), primary, u.Frequency, u.Units, u.ScheduledHour,
+		u.ScheduledMinute, u.Timezone, u.BetaFeatures, u.Locale, u.NotifyOnChange, tenantScope(ctx),
+		tokenID(r.tokens, linktoken.Manage, manageToken))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.logger.Error("failed to update subscription", zap.Error(err))
+		}
+		return Subscription{}, err
+	}
+
+	if len(u.Cities) > 0 {
+		if err := replaceCities(ctx, tx, sub.ID, u.Cities); err != nil {
+			if isUniqueViolation(err) {
+				return Subscription{}, ErrEmailAlreadyExists
+			}
+			r.logger.Error("failed to replace subscription cities", zap.Int("id", sub.ID), zap.Error(err))
+			return Subscription{}, err
+		}
+		sub.Cities = u.Cities
+	} else if err := tx.GetContext(ctx, &sub.Cities,
+		`SELECT `+citiesColumn+` FROM subscriptions WHERE id = $1;`, sub.ID); err != nil {
+		r.logger.Error("failed to load subscription cities", zap.Int("id", sub.ID), zap.Error(err))
+		return Subscription{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.Error("failed to commit subscription update", zap.Error(err))
+		return Subscription{}, err
+	}
+	if err := decryptSubscription(r.pii, r.tokens, &sub); err != nil {
+		r.logger.Error("failed to decrypt subscription email", zap.Error(err))
+		return Subscription{}, err
+	}
+	r.logger.Info("subscription updated",
+		zap.Int("id", sub.ID),
+		zap.Strings("cities", sub.Cities),
+		zap.String("frequency", string(sub.Frequency)),
+		zap.String("units", string(sub.Units)),
+	)
+	return sub, nil
+}
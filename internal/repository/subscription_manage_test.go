@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+)
+
+func TestSubscriptionRepository_Update_Partial(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	token := testTokens.Token(linktoken.Manage, 3)
+	freq := FrequencyDaily
+	hour := int16(7)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(
+		"UPDATE subscriptions SET city = COALESCE($2, city), frequency = COALESCE($3, frequency), "+
+			"units = COALESCE($4, units), scheduled_hour = COALESCE($5, scheduled_hour), "+
+			"scheduled_minute = COALESCE($6, scheduled_minute), timezone = COALESCE($7, timezone), beta_features = COALESCE($8, beta_features), locale = COALESCE($9, locale), notify_on_change = COALESCE($10, notify_on_change) WHERE (id = $12 OR manage_token_hash = $1) AND deleted_at IS NULL AND ($11::text IS NULL OR tenant_id = $11) RETURNING *",
+	)).
+		WithArgs(hashToken(token), nil, "daily", nil, int64(7), nil, nil, nil, nil, nil, nil, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "city", "frequency", "units", "scheduled_hour"}).
+			AddRow(3, "Kyiv", "daily", "metric", 7))
+	mock.ExpectQuery(regexp.QuoteMeta("AS cities FROM subscriptions WHERE id = $1;")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"cities"}).AddRow(`["Kyiv","Lviv"]`))
+	mock.ExpectCommit()
+
+	sub, err := repo.Update(context.Background(), token, SubscriptionUpdate{Frequency: &freq, ScheduledHour: &hour})
+	if err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if sub.ID != 3 || sub.Frequency != "daily" || sub.ScheduledHour != 7 || len(sub.Cities) != 2 || sub.ManageToken != token {
+		t.Errorf("Update() = %+v, want id 3, daily at hour 7, two cities", sub)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_GetByManageToken(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		token uuid.UUID
+		id    any // argument matching a derived token
+	}{
+		{"derived token", testTokens.Token(linktoken.Manage, 7), 7},
+		{"legacy token, by its hash", uuid.New(), nil},
+		{"unsubscribe token", testTokens.Token(linktoken.Unsubscribe, 7), nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlxDB, mock, cleanup := setupMockDB(t)
+			defer cleanup()
+			repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+			mock.ExpectQuery(regexp.QuoteMeta("FROM subscriptions WHERE (id = $3 OR manage_token_hash = $1) AND deleted_at IS NULL AND ($2::text IS NULL OR tenant_id = $2);")).
+				WithArgs(hashToken(tc.token), nil, tc.id).
+				WillReturnRows(sqlmock.NewRows(nil))
+
+			if _, err := repo.GetByManageToken(context.Background(), tc.token); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetByManageToken() error = %v, want sql.ErrNoRows", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet sqlmock expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionRepository_Update_ReplacesCities(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	token := uuid.New()
+	cities := []string{"Odesa", "Dnipro"}
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE subscriptions SET city = COALESCE($2, city)")).
+		WithArgs(hashToken(token), "Odesa", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city"}).AddRow(5, "a@b.com", "Odesa"))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM subscription_cities WHERE subscription_id = $1;")).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO subscription_cities (subscription_id, email_hash, city, position, tenant_id) SELECT s.id, s.email_hash, x.city, x.ord - 1, s.tenant_id")).
+		WithArgs(5, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	sub, err := repo.Update(context.Background(), token, SubscriptionUpdate{Cities: cities})
+	if err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if sub.City != "Odesa" || len(sub.Cities) != 2 || sub.Cities[1] != "Dnipro" {
+		t.Errorf("Update() = %+v, want Odesa then Dnipro", sub)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_Update_CityTakenByOtherSubscription(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	token := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE subscriptions SET city = COALESCE($2, city)")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city"}).AddRow(5, "a@b.com", "Lviv"))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM subscription_cities WHERE subscription_id = $1;")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Lviv is already subscribed by a@b.com in another subscription
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO subscription_cities")).
+		WillReturnError(&pgconn.PgError{Code: "23505"})
+	mock.ExpectRollback()
+
+	_, err := repo.Update(context.Background(), token, SubscriptionUpdate{Cities: []string{"Lviv"}})
+	if !errors.Is(err, ErrEmailAlreadyExists) {
+		t.Errorf("Update() error = %v, want ErrEmailAlreadyExists", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository/queries"
+)
+
+// subscriptionOf maps a subscription of the generated queries to a Subscription, with
+// the cities it was selected with (see citiesColumn); nil leaves them out.
+func subscriptionOf(m queries.Subscription, cities any) (Subscription, error) {
+	sub := Subscription{
+		ID:                    int(m.ID),
+		TenantID:              m.TenantID,
+		Email:                 m.Email,
+		EmailHash:             m.EmailHash,
+		City:                  m.City,
+		Frequency:             Frequency(m.Frequency),
+		Confirmed:             m.Confirmed,
+		ConfirmTokenHash:      m.ConfirmTokenHash,
+		ConfirmTokenExpiresAt: m.ConfirmTokenExpiresAt,
+		UnsubscribeTokenHash:  m.UnsubscribeTokenHash,
+		ManageTokenHash:       m.ManageTokenHash,
+		Units:                 Units(m.Units),
+		ScheduledMinute:       m.ScheduledMinute,
+		ScheduledHour:         m.ScheduledHour,
+		SendHour:              m.SendHour,
+		Timezone:              m.Timezone,
+		CreatedAt:             m.CreatedAt,
+		ConfirmedAt:           m.ConfirmedAt,
+		WelcomeSentAt:         m.WelcomeSentAt,
+		ExpiresAt:             m.ExpiresAt,
+		BetaFeatures:          m.BetaFeatures,
+		Locale:                m.Locale,
+		FirstName:             m.FirstName,
+		NotifyOnChange:        m.NotifyOnChange,
+		SlackWebhookURL:       m.SlackWebhookUrl,
+		Phone:                 m.Phone,
+		Channel:               Channel(m.Channel),
+		ConsecutiveFailures:   m.ConsecutiveFailures,
+		DeadLetteredAt:        m.DeadLetteredAt,
+		LastSentAt:            m.LastSentAt,
+		LastAttemptedAt:       m.LastAttemptedAt,
+		SendClaimedUntil:      m.SendClaimedUntil,
+		DeletedAt:             m.DeletedAt,
+	}
+	if err := sub.Cities.Scan(cities); err != nil {
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// batchRow is a row of the batch queries, which all select batchColumns.
+type batchRow interface {
+	queries.HourlyBatchRow | queries.HourlyBatchPageRow | queries.DailyBatchRow | queries.DailyBatchPageRow |
+		queries.ListConfirmedRow | queries.ActiveByIDsRow
+}
+
+// batchSubscriptions maps the rows of a batch query to subscriptions; the columns left
+// out of batchColumns stay zero.
+func batchSubscriptions[R batchRow](rows []R) ([]Subscription, error) {
+	var subs []Subscription
+	for _, row := range rows {
+		b := queries.HourlyBatchRow(row)
+		sub := Subscription{
+			ID:              int(b.ID),
+			TenantID:        b.TenantID,
+			Email:           b.Email,
+			City:            b.City,
+			Frequency:       Frequency(b.Frequency),
+			Confirmed:       b.Confirmed,
+			Units:           Units(b.Units),
+			ScheduledHour:   b.ScheduledHour,
+			ScheduledMinute: b.ScheduledMinute,
+			Timezone:        b.Timezone,
+			ExpiresAt:       b.ExpiresAt,
+			BetaFeatures:    b.BetaFeatures,
+			Locale:          b.Locale,
+			FirstName:       b.FirstName,
+			ConfirmedAt:     b.ConfirmedAt,
+			NotifyOnChange:  b.NotifyOnChange,
+			SlackWebhookURL: b.SlackWebhookUrl,
+			Phone:           b.Phone,
+			Channel:         Channel(b.Channel),
+		}
+		if err := sub.Cities.Scan(b.Cities); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// ints converts the ids returned by the generated queries; none stays nil.
+func ints(ids []int32) []int {
+	if ids == nil {
+		return nil
+	}
+	out := make([]int, len(ids))
+	for i, id := range ids {
+		out[i] = int(id)
+	}
+	return out
+}
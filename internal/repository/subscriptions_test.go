@@ -3,19 +3,49 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"go.uber.org/zap"
 	"regexp"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository/queries"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
 )
 
+// arrayConverter lets slice arguments (Postgres arrays, encoded by pgx in production)
+// through to sqlmock as slices, also those the generated queries wrap in pq.Array.
+type arrayConverter struct{}
+
+func (arrayConverter) ConvertValue(v any) (driver.Value, error) {
+	switch v := v.(type) {
+	case []string, []int64:
+		return v, nil
+	case *pq.StringArray:
+		return []string(*v), nil
+	case *pq.Int64Array:
+		return []int64(*v), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+// testTokens derives the link tokens of the subscriptions in the tests.
+var testTokens = linktoken.New([]byte("0123456789abcdef0123456789abcdef"))
+
 func setupMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock, func()) {
-	db, mock, err := sqlmock.New()
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(arrayConverter{}))
 	if err != nil {
 		t.Fatalf("failed to open sqlmock: %v", err)
 	}
@@ -26,36 +56,108 @@ func setupMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock, func()) {
 	return sqlxDB, mock, cleanup
 }
 
+// capture is a sqlmock argument matcher that records the value it is matched against.
+type capture struct {
+	value driver.Value
+}
+
+func (c *capture) Match(v driver.Value) bool {
+	c.value = v
+	return true
+}
+
+// nullable is the column value of v, nil when it is NULL.
+func nullable(v driver.Valuer) driver.Value {
+	value, _ := v.Value()
+	return value
+}
+
+// citiesValue is the cities column of s, as citiesColumn selects it.
+func citiesValue(s Subscription) driver.Value {
+	if s.Cities == nil {
+		return "[]"
+	}
+	data, _ := json.Marshal(s.Cities)
+	return string(data)
+}
+
+// batchRows are rows of the batch queries, which select batchColumns, holding subs.
+func batchRows(subs ...Subscription) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "tenant_id", "email", "city", "frequency", "confirmed", "units",
+		"scheduled_hour", "scheduled_minute", "timezone", "expires_at", "beta_features", "locale", "first_name",
+		"confirmed_at", "notify_on_change", "slack_webhook_url", "phone", "channel", "cities"})
+	for _, s := range subs {
+		rows.AddRow(s.ID, s.TenantID, s.Email, s.City, string(s.Frequency), s.Confirmed, string(s.Units),
+			s.ScheduledHour, s.ScheduledMinute, s.Timezone, nullable(s.ExpiresAt), s.BetaFeatures, s.Locale,
+			s.FirstName, nullable(s.ConfirmedAt), s.NotifyOnChange, s.SlackWebhookURL, s.Phone, string(s.Channel),
+			citiesValue(s))
+	}
+	return rows
+}
+
+// subscriptionColumns are the columns of the subscriptions table, in the order the
+// generated queries select them.
+var subscriptionColumns = []string{"id", "email", "city", "frequency", "confirmed", "scheduled_minute",
+	"scheduled_hour", "created_at", "confirmed_at", "welcome_sent_at", "units", "email_hash", "send_hour", "timezone",
+	"expires_at", "confirm_token_expires_at", "confirm_token_hash", "unsubscribe_token_hash", "beta_features", "locale",
+	"first_name", "notify_on_change", "slack_webhook_url", "phone", "channel", "consecutive_failures",
+	"dead_lettered_at", "last_sent_at", "last_attempted_at", "deleted_at", "send_claimed_until", "tenant_id",
+	"manage_token_hash"}
+
+// subscriptionValues is the row of s in subscriptionColumns.
+func subscriptionValues(s Subscription) []driver.Value {
+	return []driver.Value{s.ID, s.Email, s.City, string(s.Frequency), s.Confirmed, s.ScheduledMinute,
+		s.ScheduledHour, s.CreatedAt, nullable(s.ConfirmedAt), nullable(s.WelcomeSentAt), string(s.Units), s.EmailHash,
+		nullable(s.SendHour), s.Timezone, nullable(s.ExpiresAt), nullable(s.ConfirmTokenExpiresAt),
+		nullable(s.ConfirmTokenHash), nullable(s.UnsubscribeTokenHash), s.BetaFeatures, s.Locale, s.FirstName,
+		s.NotifyOnChange, s.SlackWebhookURL, s.Phone, string(s.Channel), s.ConsecutiveFailures,
+		nullable(s.DeadLetteredAt), nullable(s.LastSentAt), nullable(s.LastAttemptedAt), nullable(s.DeletedAt),
+		nullable(s.SendClaimedUntil), s.TenantID, nullable(s.ManageTokenHash)}
+}
+
+// subscriptionRows are rows of whole subscriptions with their cities, as the queries
+// reading one or more subscriptions by token, id or email select them.
+func subscriptionRows(subs ...Subscription) *sqlmock.Rows {
+	rows := sqlmock.NewRows(append(slices.Clip(subscriptionColumns), "cities"))
+	for _, s := range subs {
+		rows.AddRow(append(subscriptionValues(s), citiesValue(s))...)
+	}
+	return rows
+}
+
 func TestSubscriptionRepository_Create_Success(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
-
-	// Prepare expected tokens
-	wantConfirm := uuid.New()
-	wantUnsub := uuid.New()
-	rows := sqlmock.NewRows([]string{"confirm_token", "unsubscribe_token"}).
-		AddRow(wantConfirm, wantUnsub)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
-	// Expect the INSERT ... RETURNING both tokens
-	mock.ExpectQuery(regexp.QuoteMeta(
-		"INSERT INTO subscriptions (email, city, frequency) VALUES ($1, $2, $3) RETURNING confirm_token, unsubscribe_token",
-	)).
-		WithArgs("foo@bar.com", "Paris", "daily").
-		WillReturnRows(rows)
+	// Expect the INSERT with the hash of the confirmation token
+	var confirmHash capture
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO subscriptions (email, email_hash, city, frequency, send_hour, timezone, confirm_token_expires_at, confirm_token_hash, locale, first_name, slack_webhook_url, channel, tenant_id, scheduled_hour, scheduled_minute) VALUES ($1, $2, $3, $4::subscription_frequency, $5::smallint, $6::text,")).
+		WithArgs("foo@bar.com", sqlmock.AnyArg(), "Paris", "daily", nil, "Europe/Paris", float64(0), &confirmHash, "en",
+			"Anna", "", "email", "default", []string{"Paris"}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
 
 	// Call Create
-	gotConfirm, gotUnsub, err := repo.Create(context.Background(), "foo@bar.com", "Paris", "daily")
+	gotID, gotConfirm, gotUnsub, err := repo.Create(context.Background(), NewSubscription{Email: "foo@bar.com", FirstName: "Anna",
+		Cities: []string{"Paris"}, Frequency: "daily", Timezone: "Europe/Paris", Locale: "en"})
 	if err != nil {
 		t.Fatalf("Create() unexpected error: %v", err)
 	}
-	if gotConfirm != wantConfirm {
-		t.Errorf("Create() confirmToken = %v, want %v", gotConfirm, wantConfirm)
+	if gotID != 7 {
+		t.Errorf("Create() id = %d, want 7", gotID)
 	}
-	if gotUnsub != wantUnsub {
-		t.Errorf("Create() unsubscribeToken = %v, want %v", gotUnsub, wantUnsub)
+	if gotConfirm == uuid.Nil || gotConfirm == gotUnsub {
+		t.Fatalf("Create() confirmToken = %v, want a random token", gotConfirm)
+	}
+	// the confirmation token itself is never stored, only its hash
+	if confirmHash.value != hashToken(gotConfirm) {
+		t.Errorf("stored confirm token hash = %v, want %s", confirmHash.value, hashToken(gotConfirm))
+	}
+	// the unsubscribe token is not stored at all, but derived from the id
+	if want := testTokens.Token(linktoken.Unsubscribe, 7); gotUnsub != want {
+		t.Errorf("Create() unsubscribeToken = %v, want %v", gotUnsub, want)
 	}
 
 	// Ensure all expectations met
@@ -69,17 +171,17 @@ func TestSubscriptionRepository_Create_DBError(t *testing.T) {
 	defer cleanup()
 
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
-	// Simulate a DB error on the RETURNING query
-	mock.ExpectQuery(regexp.QuoteMeta(
-		"INSERT INTO subscriptions (email, city, frequency) VALUES ($1, $2, $3) RETURNING confirm_token, unsubscribe_token",
-	)).
-		WithArgs("foo@bar.com", "Paris", "daily").
+	// Simulate a DB error on the INSERT
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO subscriptions (email, email_hash, city, frequency, send_hour, timezone, confirm_token_expires_at, confirm_token_hash, locale, first_name, slack_webhook_url, channel, tenant_id, scheduled_hour, scheduled_minute) VALUES ($1, $2, $3, $4::subscription_frequency, $5::smallint, $6::text,")).
+		WithArgs("foo@bar.com", sqlmock.AnyArg(), "Paris", "daily", nil, "Europe/Paris", float64(0), sqlmock.AnyArg(),
+			"en", "", "", "email", "default", []string{"Paris"}).
 		WillReturnError(sql.ErrConnDone)
 
 	// Call Create
-	gotConfirm, gotUnsub, err := repo.Create(context.Background(), "foo@bar.com", "Paris", "daily")
+	_, gotConfirm, gotUnsub, err := repo.Create(context.Background(), NewSubscription{Email: "foo@bar.com", Cities: []string{"Paris"},
+		Frequency: "daily", Timezone: "Europe/Paris", Locale: "en"})
 	if err == nil {
 		t.Fatalf("Create() expected error, got nil")
 	}
@@ -100,27 +202,44 @@ func TestSubscriptionRepository_Create_DBError(t *testing.T) {
 	}
 }
 
+func TestSubscriptionRepository_Create_DuplicateEmailCity(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	// (tenant, email, city) is unique; the same email with another city is a separate subscription
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO subscriptions (email, email_hash, city, frequency, send_hour, timezone, confirm_token_expires_at, confirm_token_hash, locale, first_name, slack_webhook_url, channel, tenant_id, scheduled_hour, scheduled_minute) VALUES ($1, $2, $3, $4::subscription_frequency, $5::smallint, $6::text,")).
+		WithArgs("foo@bar.com", sqlmock.AnyArg(), "Paris", "daily", nil, "Europe/Paris", float64(0), sqlmock.AnyArg(),
+			"en", "", "", "email", "acme", []string{"Paris"}).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "uq_subscription_cities_tenant_email_hash_city"})
+
+	_, _, _, err := repo.Create(tenant.WithID(context.Background(), "acme"), NewSubscription{Email: "foo@bar.com",
+		Cities: []string{"Paris"}, Frequency: "daily", Timezone: "Europe/Paris", Locale: "en"})
+	if !errors.Is(err, ErrEmailAlreadyExists) {
+		t.Errorf("Create() error = %v, want ErrEmailAlreadyExists", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+// confirmSQL is where ConfirmSubscription confirms the subscription of a token.
+const confirmSQL = "WHERE (subscriptions.id = $2::integer OR (confirm_token_hash = $3::text AND (confirm_token_expires_at IS NULL OR confirm_token_expires_at > now()))) AND confirmed = FALSE AND deleted_at IS NULL AND ($4::text IS NULL OR tenant_id = $4::text)"
+
 func TestSubscriptionRepository_Confirm_Success(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
-
-	// Expect Exec to update 1 row
-	mock.ExpectExec(regexp.QuoteMeta(`
-        UPDATE subscriptions
-        SET confirmed        = TRUE,
-            confirm_token    = NULL,
-            scheduled_hour   = EXTRACT(HOUR   FROM now() + INTERVAL '1 minute')::smallint,
-            scheduled_minute = EXTRACT(MINUTE FROM now() + INTERVAL '1 minute')::smallint
-        WHERE confirm_token = $1 AND confirmed = FALSE;
-    `)).
-		WithArgs(sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
-	err := repo.Confirm(context.Background(), uuid.New())
-	if err != nil {
-		t.Fatalf("Confirm() unexpected error: %v", err)
+	// Expect the UPDATE to return the confirmed row
+	mock.ExpectQuery(regexp.QuoteMeta(confirmSQL)).
+		WithArgs(float64(0), nil, sqlmock.AnyArg(), nil, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	id, err := repo.Confirm(context.Background(), uuid.New(), 0)
+	if err != nil || id != 7 {
+		t.Fatalf("Confirm() = %d, %v; want 7", id, err)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -128,25 +247,36 @@ func TestSubscriptionRepository_Confirm_Success(t *testing.T) {
 	}
 }
 
+func TestSubscriptionRepository_ScheduleFixedOnCreate(t *testing.T) {
+	for _, tc := range []struct {
+		name, query string
+		sets        bool
+	}{
+		{"CreateSubscription", queries.CreateSubscription, true},
+		{"ConfirmSubscription", queries.ConfirmSubscription, false},
+	} {
+		for _, column := range []string{"scheduled_hour", "scheduled_minute"} {
+			if got := strings.Contains(tc.query, column); got != tc.sets {
+				t.Errorf("%s mentions %s: %v, want %v", tc.name, column, got, tc.sets)
+			}
+		}
+	}
+}
+
 func TestSubscriptionRepository_Confirm_NotFound(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
-
-	// Expect Exec to affect 0 rows
-	mock.ExpectExec(regexp.QuoteMeta(`
-        UPDATE subscriptions
-        SET confirmed        = TRUE,
-            confirm_token    = NULL,
-            scheduled_hour   = EXTRACT(HOUR   FROM now() + INTERVAL '1 minute')::smallint,
-            scheduled_minute = EXTRACT(MINUTE FROM now() + INTERVAL '1 minute')::smallint
-        WHERE confirm_token = $1 AND confirmed = FALSE;
-    `)).
-		WithArgs(sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(0, 0))
-
-	err := repo.Confirm(context.Background(), uuid.New())
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
+
+	// Expect the UPDATE to match no rows
+	mock.ExpectQuery(regexp.QuoteMeta(confirmSQL)).
+		WithArgs(float64(0), nil, sqlmock.AnyArg(), nil, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM subscriptions WHERE confirm_token_hash = $1::text AND confirmed = FALSE AND deleted_at IS NULL")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := repo.Confirm(context.Background(), uuid.New(), 0)
 	if !errors.Is(err, sql.ErrNoRows) {
 		t.Fatalf("Confirm() error = %v, want sql.ErrNoRows", err)
 	}
@@ -156,25 +286,39 @@ func TestSubscriptionRepository_Confirm_NotFound(t *testing.T) {
 	}
 }
 
+func TestSubscriptionRepository_Confirm_Expired(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	// The token exists but is past confirm_token_expires_at, so the update matches nothing
+	mock.ExpectQuery(regexp.QuoteMeta(confirmSQL)).
+		WithArgs(float64(0), nil, sqlmock.AnyArg(), nil, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM subscriptions WHERE confirm_token_hash = $1::text AND confirmed = FALSE AND deleted_at IS NULL")).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	_, err := repo.Confirm(context.Background(), uuid.New(), 0)
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("Confirm() error = %v, want ErrTokenExpired", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
 func TestSubscriptionRepository_Confirm_DBError(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
 	// Simulate a database error
-	mock.ExpectExec(regexp.QuoteMeta(`
-        UPDATE subscriptions
-        SET confirmed        = TRUE,
-            confirm_token    = NULL,
-            scheduled_hour   = EXTRACT(HOUR   FROM now() + INTERVAL '1 minute')::smallint,
-            scheduled_minute = EXTRACT(MINUTE FROM now() + INTERVAL '1 minute')::smallint
-        WHERE confirm_token = $1 AND confirmed = FALSE;
-    `)).
-		WithArgs(sqlmock.AnyArg()).
+	mock.ExpectQuery(regexp.QuoteMeta(confirmSQL)).
+		WithArgs(float64(0), nil, sqlmock.AnyArg(), nil, "").
 		WillReturnError(sql.ErrConnDone)
 
-	err := repo.Confirm(context.Background(), uuid.New())
+	_, err := repo.Confirm(context.Background(), uuid.New(), 0)
 	if err == nil {
 		t.Fatal("Confirm() expected an error, got nil")
 	}
@@ -191,18 +335,16 @@ func TestSubscriptionRepository_DeleteByUnsubToken_Success(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
-	// Expect the DELETE to affect 1 row
-	mock.ExpectExec(regexp.QuoteMeta(
-		"DELETE FROM subscriptions WHERE unsubscribe_token = $1",
-	)).
-		WithArgs(sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Expect the soft delete to return the unsubscribed row
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE subscriptions SET deleted_at = now() WHERE (subscriptions.id = $1::integer OR unsubscribe_token_hash = $2::text)")).
+		WithArgs(7, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
 
-	err := repo.DeleteByUnsubToken(context.Background(), uuid.New())
-	if err != nil {
-		t.Fatalf("DeleteByUnsubToken() unexpected error: %v", err)
+	id, err := repo.DeleteByUnsubToken(context.Background(), testTokens.Token(linktoken.Unsubscribe, 7))
+	if err != nil || id != 7 {
+		t.Fatalf("DeleteByUnsubToken() = %d, %v; want 7", id, err)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -210,20 +352,39 @@ func TestSubscriptionRepository_DeleteByUnsubToken_Success(t *testing.T) {
 	}
 }
 
+func TestSubscriptionRepository_DeleteUnconfirmed(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	token := uuid.New()
+	// confirmed subscriptions are never deleted through their (cleared) confirm token
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM subscriptions WHERE confirm_token_hash = $1::text AND confirmed = FALSE AND deleted_at IS NULL AND ($2::text IS NULL OR tenant_id = $2::text) RETURNING")).
+		WithArgs(hashToken(token), nil).
+		WillReturnRows(sqlmock.NewRows(subscriptionColumns).
+			AddRow(subscriptionValues(Subscription{ID: 7, Email: "victim@example.com"})...))
+
+	sub, err := repo.DeleteUnconfirmed(context.Background(), token)
+	if err != nil || sub.ID != 7 || sub.Email != "victim@example.com" {
+		t.Fatalf("DeleteUnconfirmed() = %+v, %v; want id 7", sub, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
 func TestSubscriptionRepository_DeleteByUnsubToken_NotFound(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
-	// Expect the DELETE to affect 0 rows
-	mock.ExpectExec(regexp.QuoteMeta(
-		"DELETE FROM subscriptions WHERE unsubscribe_token = $1",
-	)).
-		WithArgs(sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Expect the soft delete to match no rows
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE subscriptions SET deleted_at = now() WHERE (subscriptions.id = $1::integer OR unsubscribe_token_hash = $2::text)")).
+		WithArgs(nil, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
-	err := repo.DeleteByUnsubToken(context.Background(), uuid.New())
+	_, err := repo.DeleteByUnsubToken(context.Background(), uuid.New())
 	if !errors.Is(err, sql.ErrNoRows) {
 		t.Fatalf("DeleteByUnsubToken() error = %v, want sql.ErrNoRows", err)
 	}
@@ -237,16 +398,14 @@ func TestSubscriptionRepository_DeleteByUnsubToken_DBError(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
-	// Simulate a DB error on Exec
-	mock.ExpectExec(regexp.QuoteMeta(
-		"DELETE FROM subscriptions WHERE unsubscribe_token = $1",
-	)).
-		WithArgs(sqlmock.AnyArg()).
+	// Simulate a DB error on the soft delete
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE subscriptions SET deleted_at = now() WHERE (subscriptions.id = $1::integer OR unsubscribe_token_hash = $2::text)")).
+		WithArgs(nil, sqlmock.AnyArg(), nil).
 		WillReturnError(sql.ErrConnDone)
 
-	err := repo.DeleteByUnsubToken(context.Background(), uuid.New())
+	_, err := repo.DeleteByUnsubToken(context.Background(), uuid.New())
 	if err == nil {
 		t.Fatal("DeleteByUnsubToken() expected an error, got nil")
 	}
@@ -262,7 +421,7 @@ func TestSubscriptionRepository_HourlyBatch_ReturnsRows(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
 	// Prepare a fake subscription row
 	id := 1
@@ -270,25 +429,15 @@ func TestSubscriptionRepository_HourlyBatch_ReturnsRows(t *testing.T) {
 	city := "TestCity"
 	frequency := "hourly"
 	confirmed := true
-	confirmToken := uuid.New()
-	unsubToken := uuid.New()
 	scheduledMinute := 15
 	scheduledHour := 0
-	createdAt := time.Now().UTC().Truncate(time.Second)
-
-	rows := sqlmock.NewRows([]string{
-		"id", "email", "city", "frequency", "confirmed",
-		"confirm_token", "unsubscribe_token",
-		"scheduled_minute", "scheduled_hour", "created_at",
-	}).AddRow(
-		id, email, city, frequency, confirmed,
-		confirmToken, unsubToken,
-		scheduledMinute, scheduledHour, createdAt,
-	)
+
+	rows := batchRows(Subscription{ID: id, Email: email, City: city, Frequency: Frequency(frequency),
+		Confirmed: confirmed, ScheduledMinute: int16(scheduledMinute), ScheduledHour: int16(scheduledHour)})
 
 	// Expect the SELECT ... WHERE ... hourly query
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM subscriptions WHERE confirmed       = TRUE AND frequency       = 'hourly' AND scheduled_minute= $1",
+		"SELECT id, tenant_id, email, city, frequency, confirmed, units, scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale, first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, COALESCE((SELECT json_agg(sc.city ORDER BY sc.position) FROM subscription_cities sc WHERE sc.subscription_id = subscriptions.id), '[]') AS cities FROM subscriptions WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL AND frequency = 'hourly' AND scheduled_minute = $1 AND (last_sent_at IS NULL OR last_sent_at < now() - CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END)",
 	)).
 		WithArgs(scheduledMinute).
 		WillReturnRows(rows)
@@ -303,8 +452,8 @@ func TestSubscriptionRepository_HourlyBatch_ReturnsRows(t *testing.T) {
 	}
 	s := subs[0]
 	if s.ID != id || s.Email != email || s.City != city ||
-		s.Frequency != frequency || !s.Confirmed ||
-		s.ConfirmToken != confirmToken || s.UnsubscribeToken != unsubToken ||
+		s.Frequency != Frequency(frequency) || !s.Confirmed ||
+		s.UnsubscribeToken != testTokens.Token(linktoken.Unsubscribe, id) ||
 		int(s.ScheduledMinute) != scheduledMinute {
 		t.Errorf("HourlyBatch() returned row %+v, want matching test data", s)
 	}
@@ -314,15 +463,96 @@ func TestSubscriptionRepository_HourlyBatch_ReturnsRows(t *testing.T) {
 	}
 }
 
+func TestSubscriptionRepository_HourlyBatchPage(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectQuery(`AND scheduled_minute = \$1 AND .* AND subscriptions\.id > \$2 ORDER BY subscriptions\.id LIMIT \$3$`).
+		WithArgs(15, 40, 2).
+		WillReturnRows(batchRows(
+			Subscription{ID: 41, Email: "a@example.com", City: "Kyiv", Frequency: FrequencyHourly},
+			Subscription{ID: 43, Email: "b@example.com", City: "Lviv", Frequency: FrequencyHourly}))
+
+	subs, err := repo.HourlyBatchPage(context.Background(), 15, 40, 2)
+	if err != nil {
+		t.Fatalf("HourlyBatchPage() unexpected error: %v", err)
+	}
+	if len(subs) != 2 || subs[0].ID != 41 || subs[1].ID != 43 {
+		t.Errorf("HourlyBatchPage() = %+v, want subscriptions 41 and 43", subs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_StreamDailyBatch(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	at := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`AND frequency = 'daily' AND .*END\)$`).
+		WithArgs(at).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "city", "frequency"}).
+			AddRow(1, "a@example.com", "Kyiv", "daily").
+			AddRow(2, "b@example.com", "Lviv", "daily").
+			AddRow(3, "c@example.com", "Odesa", "daily"))
+
+	// fn stops the stream at the second row
+	stop := errors.New("stop")
+	var got []int
+	err := repo.StreamDailyBatch(context.Background(), at, func(sub Subscription) error {
+		got = append(got, sub.ID)
+		if len(got) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("StreamDailyBatch() streamed %v, %v; want [1 2], stop", got, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestReplicatedSubscriptionRepository_BatchFromReplica(t *testing.T) {
+	primary, primaryMock, cleanupPrimary := setupMockDB(t)
+	defer cleanupPrimary()
+	replica, replicaMock, cleanupReplica := setupMockDB(t)
+	defer cleanupReplica()
+	repo := NewReplicatedSubscriptionRepository(primary, replica, nil, testTokens, zap.NewNop())
+
+	replicaMock.ExpectQuery(`AND scheduled_minute = \$1`).
+		WithArgs(15).
+		WillReturnRows(batchRows(Subscription{ID: 1}))
+	primaryMock.ExpectExec(`UPDATE subscriptions SET last_attempted_at`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if subs, err := repo.HourlyBatch(context.Background(), 15); err != nil || len(subs) != 1 {
+		t.Errorf("HourlyBatch() = %v, %v; want one subscription from the replica", subs, err)
+	}
+	if err := repo.RecordSends(context.Background(), []int{1}, []int{1}); err != nil {
+		t.Errorf("RecordSends() error: %v", err)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet replica expectations: %v", err)
+	}
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet primary expectations: %v", err)
+	}
+}
+
 func TestSubscriptionRepository_HourlyBatch_Empty(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
 	// Expect an empty result set
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM subscriptions WHERE confirmed       = TRUE AND frequency       = 'hourly' AND scheduled_minute= $1",
+		"SELECT id, tenant_id, email, city, frequency, confirmed, units, scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale, first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, COALESCE((SELECT json_agg(sc.city ORDER BY sc.position) FROM subscription_cities sc WHERE sc.subscription_id = subscriptions.id), '[]') AS cities FROM subscriptions WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL AND frequency = 'hourly' AND scheduled_minute = $1 AND (last_sent_at IS NULL OR last_sent_at < now() - CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END)",
 	)).
 		WithArgs(42).
 		WillReturnRows(sqlmock.NewRows(nil))
@@ -344,11 +574,11 @@ func TestSubscriptionRepository_HourlyBatch_DBError(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
 	// Simulate a DB error on query
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM subscriptions WHERE confirmed       = TRUE AND frequency       = 'hourly' AND scheduled_minute= $1",
+		"SELECT id, tenant_id, email, city, frequency, confirmed, units, scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale, first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, COALESCE((SELECT json_agg(sc.city ORDER BY sc.position) FROM subscription_cities sc WHERE sc.subscription_id = subscriptions.id), '[]') AS cities FROM subscriptions WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL AND frequency = 'hourly' AND scheduled_minute = $1 AND (last_sent_at IS NULL OR last_sent_at < now() - CASE frequency WHEN 'hourly' THEN INTERVAL '30 minutes' ELSE INTERVAL '12 hours' END)",
 	)).
 		WithArgs(30).
 		WillReturnError(sql.ErrConnDone)
@@ -370,7 +600,7 @@ func TestSubscriptionRepository_DailyBatch_ReturnsRows(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
 	// Prepare a fake subscription row
 	id := 1
@@ -378,31 +608,22 @@ func TestSubscriptionRepository_DailyBatch_ReturnsRows(t *testing.T) {
 	city := "TestDaily"
 	frequency := "daily"
 	confirmed := true
-	confirmToken := uuid.New()
-	unsubToken := uuid.New()
 	scheduledMinute := 30
 	scheduledHour := 9
-	createdAt := time.Now().UTC().Truncate(time.Second)
-
-	rows := sqlmock.NewRows([]string{
-		"id", "email", "city", "frequency", "confirmed",
-		"confirm_token", "unsubscribe_token",
-		"scheduled_minute", "scheduled_hour", "created_at",
-	}).AddRow(
-		id, email, city, frequency, confirmed,
-		confirmToken, unsubToken,
-		scheduledMinute, scheduledHour, createdAt,
-	)
+	at := time.Date(2026, 1, 15, scheduledHour, scheduledMinute, 0, 0, time.UTC)
+
+	rows := batchRows(Subscription{ID: id, Email: email, City: city, Frequency: Frequency(frequency),
+		Confirmed: confirmed, ScheduledMinute: int16(scheduledMinute), ScheduledHour: int16(scheduledHour)})
 
 	// Expect the SELECT ... WHERE ... daily query
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM subscriptions WHERE confirmed        = TRUE AND frequency        = 'daily' AND scheduled_hour   = $1 AND scheduled_minute = $2",
+		"SELECT id, tenant_id, email, city, frequency, confirmed, units, scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale, first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, COALESCE((SELECT json_agg(sc.city ORDER BY sc.position) FROM subscription_cities sc WHERE sc.subscription_id = subscriptions.id), '[]') AS cities FROM subscriptions JOIN slots ON timezone = slot_timezone AND scheduled_hour = slot_hour AND scheduled_minute = slot_minute WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL AND frequency = 'daily'",
 	)).
-		WithArgs(scheduledHour, scheduledMinute).
+		WithArgs(at).
 		WillReturnRows(rows)
 
 	// Call DailyBatch
-	subs, err := repo.DailyBatch(context.Background(), scheduledHour, scheduledMinute)
+	subs, err := repo.DailyBatch(context.Background(), at)
 	if err != nil {
 		t.Fatalf("DailyBatch() unexpected error: %v", err)
 	}
@@ -411,8 +632,8 @@ func TestSubscriptionRepository_DailyBatch_ReturnsRows(t *testing.T) {
 	}
 	s := subs[0]
 	if s.ID != id || s.Email != email || s.City != city ||
-		s.Frequency != frequency || !s.Confirmed ||
-		s.ConfirmToken != confirmToken || s.UnsubscribeToken != unsubToken ||
+		s.Frequency != Frequency(frequency) || !s.Confirmed ||
+		s.UnsubscribeToken != testTokens.Token(linktoken.Unsubscribe, id) ||
 		int(s.ScheduledHour) != scheduledHour || int(s.ScheduledMinute) != scheduledMinute {
 		t.Errorf("DailyBatch() returned row %+v, want matching test data", s)
 	}
@@ -426,16 +647,16 @@ func TestSubscriptionRepository_DailyBatch_Empty(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
 	// Expect an empty result set
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM subscriptions WHERE confirmed        = TRUE AND frequency        = 'daily' AND scheduled_hour   = $1 AND scheduled_minute = $2",
+		"SELECT id, tenant_id, email, city, frequency, confirmed, units, scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale, first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, COALESCE((SELECT json_agg(sc.city ORDER BY sc.position) FROM subscription_cities sc WHERE sc.subscription_id = subscriptions.id), '[]') AS cities FROM subscriptions JOIN slots ON timezone = slot_timezone AND scheduled_hour = slot_hour AND scheduled_minute = slot_minute WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL AND frequency = 'daily'",
 	)).
-		WithArgs(23, 59).
+		WithArgs(time.Date(2026, 1, 15, 23, 59, 0, 0, time.UTC)).
 		WillReturnRows(sqlmock.NewRows(nil))
 
-	subs, err := repo.DailyBatch(context.Background(), 23, 59)
+	subs, err := repo.DailyBatch(context.Background(), time.Date(2026, 1, 15, 23, 59, 0, 0, time.UTC))
 	if err != nil {
 		t.Fatalf("DailyBatch() unexpected error: %v", err)
 	}
@@ -452,16 +673,16 @@ func TestSubscriptionRepository_DailyBatch_DBError(t *testing.T) {
 	sqlxDB, mock, cleanup := setupMockDB(t)
 	defer cleanup()
 	logger := zap.NewNop()
-	repo := NewSubscriptionRepository(sqlxDB, logger)
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, logger)
 
 	// Simulate a DB error on query
 	mock.ExpectQuery(regexp.QuoteMeta(
-		"SELECT * FROM subscriptions WHERE confirmed        = TRUE AND frequency        = 'daily' AND scheduled_hour   = $1 AND scheduled_minute = $2",
+		"SELECT id, tenant_id, email, city, frequency, confirmed, units, scheduled_hour, scheduled_minute, timezone, expires_at, beta_features, locale, first_name, confirmed_at, notify_on_change, slack_webhook_url, phone, channel, COALESCE((SELECT json_agg(sc.city ORDER BY sc.position) FROM subscription_cities sc WHERE sc.subscription_id = subscriptions.id), '[]') AS cities FROM subscriptions JOIN slots ON timezone = slot_timezone AND scheduled_hour = slot_hour AND scheduled_minute = slot_minute WHERE confirmed = TRUE AND (expires_at IS NULL OR expires_at > now()) AND dead_lettered_at IS NULL AND deleted_at IS NULL AND frequency = 'daily'",
 	)).
-		WithArgs(12, 0).
+		WithArgs(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)).
 		WillReturnError(sql.ErrConnDone)
 
-	_, err := repo.DailyBatch(context.Background(), 12, 0)
+	_, err := repo.DailyBatch(context.Background(), time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC))
 	if err == nil {
 		t.Fatal("DailyBatch() expected error, got nil")
 	}
@@ -473,3 +694,193 @@ func TestSubscriptionRepository_DailyBatch_DBError(t *testing.T) {
 		t.Errorf("unmet sqlmock expectations: %v", err)
 	}
 }
+
+func TestSubscriptionRepository_DeleteUnconfirmedOlderThan(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	cutoff := time.Now().Add(-7 * 24 * time.Hour)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM subscriptions WHERE id IN (SELECT s.id FROM subscriptions s WHERE s.confirmed = FALSE AND s.created_at < $1 LIMIT $2 FOR UPDATE SKIP LOCKED)")).
+		WithArgs(cutoff, 500).
+		WillReturnResult(sqlmock.NewResult(0, 12))
+
+	n, err := repo.DeleteUnconfirmedOlderThan(context.Background(), cutoff, 500)
+	if err != nil || n != 12 {
+		t.Errorf("DeleteUnconfirmedOlderThan() = %d, %v; want 12, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_PurgeDeletedOlderThan(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	cutoff := time.Now().Add(-90 * 24 * time.Hour)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM subscriptions WHERE id IN (SELECT s.id FROM subscriptions s WHERE s.deleted_at < $1::timestamptz LIMIT $2 FOR UPDATE SKIP LOCKED)")).
+		WithArgs(cutoff, 500).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := repo.PurgeDeletedOlderThan(context.Background(), cutoff, 500)
+	if err != nil || n != 3 {
+		t.Errorf("PurgeDeletedOlderThan() = %d, %v; want 3, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_RecordSends(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE subscriptions SET last_attempted_at = now(), last_sent_at = CASE WHEN id = ANY($1::bigint[]) THEN now() ELSE last_sent_at END, send_claimed_until = NULL WHERE id = ANY($2::bigint[])")).
+		WithArgs([]int64{2}, []int64{1, 2}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := repo.RecordSends(context.Background(), []int{1, 2}, []int{2}); err != nil {
+		t.Fatalf("RecordSends() unexpected error: %v", err)
+	}
+	if err := repo.RecordSends(context.Background(), nil, nil); err != nil {
+		t.Errorf("RecordSends() of nothing: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_ClaimSends(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	// 2 is claimed by another batch (or was sent meanwhile): the UPDATE leaves it out
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE subscriptions SET send_claimed_until = now() + $1::float8 * INTERVAL '1 second' WHERE id = ANY($2::bigint[])")).
+		WithArgs(float64(600), []int64{1, 2}).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	claimed, err := repo.ClaimSends(context.Background(), []int{1, 2}, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimSends() unexpected error: %v", err)
+	}
+	if len(claimed) != 1 || claimed[0] != 1 {
+		t.Errorf("ClaimSends() = %v, want [1]", claimed)
+	}
+	if claimed, err := repo.ClaimSends(context.Background(), nil, time.Minute); err != nil || claimed != nil {
+		t.Errorf("ClaimSends() of nothing = %v, %v", claimed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_ListByEmail(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	// matched through the blind index, whatever the case of the address
+	var c *pii.Cipher
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE subscriptions.email_hash = $1 AND ($2::text IS NULL OR subscriptions.tenant_id = $2::text) ORDER BY subscriptions.id DESC")).
+		WithArgs(c.BlindIndex("a@example.com"), nil).
+		WillReturnRows(subscriptionRows(
+			Subscription{ID: 7, Email: "a@example.com", City: "Lviv"},
+			Subscription{ID: 3, Email: "a@example.com", City: "Kyiv"}))
+
+	subs, err := repo.ListByEmail(context.Background(), "A@Example.com")
+	if err != nil || len(subs) != 2 || subs[0].ID != 7 {
+		t.Errorf("ListByEmail() = %+v, %v; want subscriptions 7 and 3", subs, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_GetByEmail_NotFound(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE subscriptions.email_hash = $1 AND deleted_at IS NULL")).
+		WillReturnRows(subscriptionRows())
+
+	if _, err := repo.GetByEmail(context.Background(), "nobody@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetByEmail() error = %v, want sql.ErrNoRows", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSubscriptionRepository_GetByEmail_ScopedToTenant(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSubscriptionRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	// within a request, only the subscriptions of its tenant are seen
+	var c *pii.Cipher
+	mock.ExpectQuery(regexp.QuoteMeta("AND ($2::text IS NULL OR subscriptions.tenant_id = $2::text)")).
+		WithArgs(c.BlindIndex("a@example.com"), "acme").
+		WillReturnRows(subscriptionRows(Subscription{ID: 7, TenantID: "acme", Email: "a@example.com"}))
+
+	sub, err := repo.GetByEmail(tenant.WithID(context.Background(), "acme"), "a@example.com")
+	if err != nil || sub.ID != 7 || sub.TenantID != "acme" {
+		t.Errorf("GetByEmail() = %+v, %v; want subscription 7 of acme", sub, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+// generatedQueries are the queries of package queries by name.
+var generatedQueries = map[string]string{
+	"CreateSubscription":         queries.CreateSubscription,
+	"ConfirmSubscription":        queries.ConfirmSubscription,
+	"ConfirmTokenExpired":        queries.ConfirmTokenExpired,
+	"RefreshConfirmToken":        queries.RefreshConfirmToken,
+	"DeleteByUnsubToken":         queries.DeleteByUnsubToken,
+	"DeleteUnconfirmed":          queries.DeleteUnconfirmed,
+	"DeleteUnconfirmedOlderThan": queries.DeleteUnconfirmedOlderThan,
+	"PurgeDeletedOlderThan":      queries.PurgeDeletedOlderThan,
+	"HourlyBatch":                queries.HourlyBatch,
+	"HourlyBatchPage":            queries.HourlyBatchPage,
+	"DailyBatch":                 queries.DailyBatch,
+	"DailyBatchPage":             queries.DailyBatchPage,
+	"GetByID":                    queries.GetByID,
+	"GetByEmail":                 queries.GetByEmail,
+	"ListByEmail":                queries.ListByEmail,
+	"ListConfirmed":              queries.ListConfirmed,
+	"ClaimWelcome":               queries.ClaimWelcome,
+	"PendingWelcomes":            queries.PendingWelcomes,
+	"ActiveIDs":                  queries.ActiveIDs,
+	"ActiveByIDs":                queries.ActiveByIDs,
+	"ClaimSends":                 queries.ClaimSends,
+	"RecordSends":                queries.RecordSends,
+	"SubscribedCities":           queries.SubscribedCities,
+}
+
+// TestQueries_RepeatSharedFragments checks that the generated queries repeat the
+// fragments the queries still built in Go use, word for word.
+func TestQueries_RepeatSharedFragments(t *testing.T) {
+	collapse := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	for _, tc := range []struct {
+		fragment string
+		queries  []string
+	}{
+		{batchColumns, []string{"HourlyBatch", "HourlyBatchPage", "DailyBatch", "DailyBatchPage", "ListConfirmed", "ActiveByIDs"}},
+		{activeCondition, []string{"HourlyBatch", "HourlyBatchPage", "DailyBatch", "DailyBatchPage", "ActiveIDs", "ActiveByIDs", "ClaimSends",
+			"SubscribedCities"}},
+		{unsentCondition, []string{"HourlyBatch", "HourlyBatchPage", "DailyBatch", "DailyBatchPage", "ActiveByIDs", "ClaimSends"}},
+		{citiesColumn, []string{"RefreshConfirmToken", "GetByID", "GetByEmail", "ListByEmail", "ClaimWelcome"}},
+	} {
+		for _, name := range tc.queries {
+			if !strings.Contains(collapse(generatedQueries[name]), collapse(tc.fragment)) {
+				t.Errorf("query %s does not repeat %q", name, collapse(tc.fragment))
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Suppression reasons stored in suppressed_emails.reason.
+const (
+	SuppressionReasonBounce    = "bounce"
+	SuppressionReasonComplaint = "complaint"
+	SuppressionReasonManual    = "manual"
+	SuppressionReasonNotMe     = "not_me" // "this wasn't me" on a confirmation email; temporary
+)
+
+// importChunkSize bounds the rows of a single multi-row INSERT.
+const importChunkSize = 1000
+
+type Suppression struct {
+	Email     string    `db:"email"` // lower-cased
+	Reason    string    `db:"reason"`
+	Source    string    `db:"source"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// SuppressionRepository stores addresses that must never be emailed, or not until their
+// suppression expires.
+type SuppressionRepository interface {
+	// Import inserts permanent suppressions, ignoring addresses already suppressed for
+	// good (temporary suppressions become permanent), and returns how many were added.
+	Import(ctx context.Context, suppressions []Suppression) (int64, error)
+	// SuppressFor suppresses email for ttl, unless it is already suppressed for longer.
+	SuppressFor(ctx context.Context, email, reason, source string, ttl time.Duration) error
+	IsSuppressed(ctx context.Context, email string) (bool, error)
+	// FilterSuppressed returns the subset of emails (lower-cased) that are suppressed.
+	FilterSuppressed(ctx context.Context, emails []string) (map[string]bool, error)
+}
+
+type pgSuppressionRepo struct {
+	db     *sqlx.DB
+	logger *zap.Logger
+}
+
+func NewSuppressionRepository(db *sqlx.DB, logger *zap.Logger) SuppressionRepository {
+	return &pgSuppressionRepo{db: db, logger: logger}
+}
+
+func (r *pgSuppressionRepo) Import(ctx context.Context, suppressions []Suppression) (int64, error) {
+	const q = `
+        INSERT INTO suppressed_emails (email, reason, source)
+        VALUES (:email, :reason, :source)
+        ON CONFLICT (email) DO UPDATE
+            SET reason = EXCLUDED.reason, source = EXCLUDED.source, expires_at = NULL
+            WHERE suppressed_emails.expires_at IS NOT NULL;
+    `
+	var added int64
+	for start := 0; start < len(suppressions); start += importChunkSize {
+		end := min(start+importChunkSize, len(suppressions))
+		res, err := r.db.NamedExecContext(ctx, q, suppressions[start:end])
+		if err != nil {
+			r.logger.Error("failed to import suppressions", zap.Int("offset", start), zap.Error(err))
+			return added, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			r.logger.Error("failed to get rows affected on suppression import", zap.Error(err))
+			return added, err
+		}
+		added += n
+	}
+	r.logger.Info("suppressions imported", zap.Int("received", len(suppressions)), zap.Int64("added", added))
+	return added, nil
+}
+
+func (r *pgSuppressionRepo) SuppressFor(ctx context.Context, email, reason, source string, ttl time.Duration) error {
+	const q = `
+        INSERT INTO suppressed_emails (email, reason, source, expires_at)
+        VALUES ($1, $2, $3, now() + $4::float8 * INTERVAL '1 second')
+        ON CONFLICT (email) DO UPDATE
+            SET reason = EXCLUDED.reason, source = EXCLUDED.source,
+                expires_at = GREATEST(suppressed_emails.expires_at, EXCLUDED.expires_at)
+            WHERE suppressed_emails.expires_at IS NOT NULL;
+    `
+	if _, err := r.db.ExecContext(ctx, q, strings.ToLower(email), reason, source, ttl.Seconds()); err != nil {
+		r.logger.Error("failed to add temporary suppression", zap.String("reason", reason), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *pgSuppressionRepo) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	const q = `
+        SELECT EXISTS (SELECT 1 FROM suppressed_emails
+                       WHERE email = $1 AND (expires_at IS NULL OR expires_at > now()));
+    `
+	var suppressed bool
+	if err := r.db.GetContext(ctx, &suppressed, q, strings.ToLower(email)); err != nil {
+		r.logger.Error("failed to check suppression", zap.Error(err))
+		return false, err
+	}
+	return suppressed, nil
+}
+
+func (r *pgSuppressionRepo) FilterSuppressed(ctx context.Context, emails []string) (map[string]bool, error) {
+	suppressed := make(map[string]bool)
+	if len(emails) == 0 {
+		return suppressed, nil
+	}
+	lowered := make([]string, len(emails))
+	for i, e := range emails {
+		lowered[i] = strings.ToLower(e)
+	}
+
+	const q = `
+        SELECT email FROM suppressed_emails
+        WHERE email = ANY($1) AND (expires_at IS NULL OR expires_at > now());
+    `
+	var found []string
+	if err := r.db.SelectContext(ctx, &found, q, lowered); err != nil {
+		r.logger.Error("failed to filter suppressed emails", zap.Int("count", len(emails)), zap.Error(err))
+		return nil, err
+	}
+	for _, e := range found {
+		suppressed[e] = true
+	}
+	return suppressed, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestSuppressionRepository_Import_IgnoresDuplicates(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSuppressionRepository(sqlxDB, zap.NewNop())
+
+	// Two rows in one statement, one of them already suppressed
+	mock.ExpectExec(regexp.QuoteMeta(
+		"INSERT INTO suppressed_emails (email, reason, source) VALUES ($1, $2, $3),($4, $5, $6) ON CONFLICT (email) DO UPDATE",
+	)).
+		WithArgs("a@b.com", "bounce", "esp", "c@d.com", "bounce", "esp").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	added, err := repo.Import(context.Background(), []Suppression{
+		{Email: "a@b.com", Reason: SuppressionReasonBounce, Source: "esp"},
+		{Email: "c@d.com", Reason: SuppressionReasonBounce, Source: "esp"},
+	})
+	if err != nil {
+		t.Fatalf("Import() unexpected error: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("Import() added = %d, want 1", added)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSuppressionRepository_IsSuppressed_CaseInsensitive(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSuppressionRepository(sqlxDB, zap.NewNop())
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM suppressed_emails WHERE email = $1 AND (expires_at IS NULL OR expires_at > now()))")).
+		WithArgs("john@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	suppressed, err := repo.IsSuppressed(context.Background(), "John@Example.com")
+	if err != nil {
+		t.Fatalf("IsSuppressed() unexpected error: %v", err)
+	}
+	if !suppressed {
+		t.Error("IsSuppressed() = false, want true")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestSuppressionRepository_SuppressFor(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewSuppressionRepository(sqlxDB, zap.NewNop())
+
+	// only temporary suppressions are extended; permanent ones are left alone
+	mock.ExpectExec(regexp.QuoteMeta(
+		"expires_at = GREATEST(suppressed_emails.expires_at, EXCLUDED.expires_at) WHERE suppressed_emails.expires_at IS NOT NULL",
+	)).
+		WithArgs("john@example.com", SuppressionReasonNotMe, "confirmation email", float64(30*24*3600)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.SuppressFor(context.Background(), "John@Example.com", SuppressionReasonNotMe, "confirmation email",
+		30*24*time.Hour); err != nil {
+		t.Fatalf("SuppressFor() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
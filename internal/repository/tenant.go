@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+)
+
+// tenantScope returns the tenant of ctx as the argument of a tenant condition,
+// ($n::text IS NULL OR tenant_id = $n): the tenant of an API request, nil outside
+// requests (the scheduler, the command-line tools), which see every tenant.
+func tenantScope(ctx context.Context) any {
+	if id, ok := tenant.FromContext(ctx); ok {
+		return id
+	}
+	return nil
+}
+
+// tenantArg is tenantScope as an argument of the generated queries.
+func tenantArg(ctx context.Context) sql.NullString {
+	id, ok := tenant.FromContext(ctx)
+	return sql.NullString{String: id, Valid: ok}
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+)
+
+// hashToken is how confirm tokens, and the unsubscribe and manage tokens of links
+// emailed before they were derived (see tokenID), are stored and looked up: the hex
+// SHA-256 of their canonical string form. The tokens are random UUIDs, so an unsalted
+// hash cannot be reversed, and a leaked table does not yield working links.
+func hashToken(t uuid.UUID) string {
+	sum := sha256.Sum256([]byte(t.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenID is the query argument matching the subscription a link token of purpose
+// was derived for: its id, or NULL, matching nothing, for a legacy random token (found
+// by hashToken instead) or a forged one.
+func tokenID(tokens *linktoken.Deriver, purpose string, t uuid.UUID) *int {
+	if id, ok := tokens.ID(purpose, t); ok {
+		return &id
+	}
+	return nil
+}
+
+// hashCode is how one-time phone codes are stored: the hex SHA-256 of the code salted
+// with the subscription id. Codes are short, so they are only safe together with the
+// attempt limit of PhoneRepository.Verify.
+func hashCode(subscriptionID int, code string) string {
+	sum := sha256.Sum256([]byte(strconv.Itoa(subscriptionID) + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+)
+
+// NotifiedWarning is a severe weather warning emailed to a subscription.
+type NotifiedWarning struct {
+	SubscriptionID int       `db:"subscription_id"`
+	WarningID      string    `db:"warning_id"`
+	ExpiresAt      time.Time `db:"expires_at"` // the row is kept until then
+}
+
+// WarningRepository selects warning subscriptions and remembers the warnings they were
+// emailed, so each warning is sent once.
+type WarningRepository interface {
+	// WarningSubscriptions returns every active warning subscription.
+	WarningSubscriptions(ctx context.Context) ([]Subscription, error)
+	// Notified returns the IDs of the warnings emailed to the subscriptions ids, by
+	// subscription. Subscriptions never emailed a warning are missing.
+	Notified(ctx context.Context, ids []int) (map[int]map[string]bool, error)
+	// MarkNotified remembers warnings as emailed.
+	MarkNotified(ctx context.Context, warnings []NotifiedWarning) error
+	// DeleteExpired forgets the warnings that expired before cutoff.
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type pgWarningRepo struct {
+	db     *sqlx.DB
+	pii    *pii.Cipher
+	tokens *linktoken.Deriver
+	logger *zap.Logger
+}
+
+// NewWarningRepository reads emails encrypted with cipher (nil reads them as plaintext)
+// and derives the link tokens of subscriptions with tokens.
+func NewWarningRepository(db *sqlx.DB, cipher *pii.Cipher, tokens *linktoken.Deriver, logger *zap.Logger,
+) WarningRepository {
+	return &pgWarningRepo{db: db, pii: cipher, tokens: tokens, logger: logger}
+}
+
+func (r *pgWarningRepo) WarningSubscriptions(ctx context.Context) ([]Subscription, error) {
+	const q = `
+        SELECT ` + batchColumns + `
+        FROM subscriptions
+        WHERE ` + activeCondition + `
+          AND frequency = 'warnings';
+    `
+	var subs []Subscription
+	if err := r.db.SelectContext(ctx, &subs, q); err != nil {
+		r.logger.Error("failed to fetch warning subscriptions", zap.Error(err))
+		return nil, err
+	}
+	return decryptSubscriptions(r.pii, r.tokens, r.logger, subs), nil
+}
+
+func (r *pgWarningRepo) Notified(ctx context.Context, ids []int) (map[int]map[string]bool, error) {
+	out := make(map[int]map[string]bool)
+	if len(ids) == 0 {
+		return out, nil
+	}
+	args := make([]int64, len(ids))
+	for i, id := range ids {
+		args[i] = int64(id)
+	}
+
+	const q = `SELECT subscription_id, warning_id FROM notified_warnings WHERE subscription_id = ANY($1);`
+	var rows []NotifiedWarning
+	if err := r.db.SelectContext(ctx, &rows, q, args); err != nil {
+		r.logger.Error("failed to read notified warnings", zap.Int("subscriptions", len(ids)), zap.Error(err))
+		return nil, err
+	}
+	for _, w := range rows {
+		if out[w.SubscriptionID] == nil {
+			out[w.SubscriptionID] = make(map[string]bool)
+		}
+		out[w.SubscriptionID][w.WarningID] = true
+	}
+	return out, nil
+}
+
+func (r *pgWarningRepo) MarkNotified(ctx context.Context, warnings []NotifiedWarning) error {
+	const q = `
+        INSERT INTO notified_warnings (subscription_id, warning_id, expires_at)
+        VALUES (:subscription_id, :warning_id, :expires_at)
+        ON CONFLICT (subscription_id, warning_id) DO NOTHING;
+    `
+	for start := 0; start < len(warnings); start += importChunkSize {
+		end := min(start+importChunkSize, len(warnings))
+		if _, err := r.db.NamedExecContext(ctx, q, warnings[start:end]); err != nil {
+			r.logger.Error("failed to record notified warnings", zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *pgWarningRepo) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM notified_warnings WHERE expires_at < $1;`, cutoff)
+	if err != nil {
+		r.logger.Error("failed to delete expired notified warnings", zap.Error(err))
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		r.logger.Error("failed to get rows affected on notified warnings cleanup", zap.Error(err))
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.uber.org/zap"
+)
+
+func TestWarningRepository_Notified(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewWarningRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT subscription_id, warning_id FROM notified_warnings WHERE subscription_id = ANY($1);")).
+		WithArgs([]int64{3, 4}).
+		WillReturnRows(sqlmock.NewRows([]string{"subscription_id", "warning_id"}).
+			AddRow(3, "a1").
+			AddRow(3, "b2"))
+
+	got, err := repo.Notified(context.Background(), []int{3, 4})
+	if err != nil {
+		t.Fatalf("Notified() unexpected error: %v", err)
+	}
+	if len(got) != 1 || !got[3]["a1"] || !got[3]["b2"] {
+		t.Errorf("Notified() = %v, want a1 and b2 of subscription 3", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestWarningRepository_MarkNotified(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewWarningRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	expires := time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO notified_warnings (subscription_id, warning_id, expires_at) VALUES ($1, $2, $3),($4, $5, $6)")).
+		WithArgs(3, "a1", expires, 4, "a1", expires).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := repo.MarkNotified(context.Background(), []NotifiedWarning{
+		{SubscriptionID: 3, WarningID: "a1", ExpiresAt: expires},
+		{SubscriptionID: 4, WarningID: "a1", ExpiresAt: expires},
+	})
+	if err != nil {
+		t.Fatalf("MarkNotified() unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
+
+func TestWarningRepository_DeleteExpired(t *testing.T) {
+	sqlxDB, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+	repo := NewWarningRepository(sqlxDB, nil, testTokens, zap.NewNop())
+
+	cutoff := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM notified_warnings WHERE expires_at < $1;")).
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+
+	n, err := repo.DeleteExpired(context.Background(), cutoff)
+	if err != nil || n != 5 {
+		t.Errorf("DeleteExpired() = %d, %v; want 5, nil", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+var alertsTriggered = metrics.NewCounter("weather_alerts_triggered_total",
+	"Alert subscriptions whose conditions started to hold, emailed or not.")
+
+// rainLikely is the forecast chance of precipitation (%) that counts as rain expected.
+const rainLikely = 50
+
+// precipitationWords mark a current description as precipitation, in the providers'
+// English condition texts.
+var precipitationWords = []string{"rain", "drizzle", "shower", "snow", "sleet", "thunder"}
+
+// AlertTripper records whether the conditions of alert subscriptions hold.
+type AlertTripper interface {
+	SetTripped(ctx context.Context, ids []int, tripped bool) error
+}
+
+// SendAlerts evaluates the conditions of alert subscriptions against the current
+// weather of their cities and tomorrow's forecast, and emails those whose conditions
+// started to hold since the last evaluation. A subscription is alerted once: not again
+// until its conditions clear. Alerts that fail to send are retried at the next evaluation.
+func (d *Dispatcher) SendAlerts(ctx context.Context, alerts []repository.AlertSubscription, slot time.Time) {
+	if len(alerts) == 0 || d.alerts == nil {
+		return
+	}
+
+	var messages []email.EmailMessage
+	var records []repository.Delivery
+	var cleared []int
+	forecasts := make(map[string]*types.Forecast)
+	subs := make([]repository.Subscription, len(alerts))
+	for i, a := range alerts {
+		subs[i] = a.Subscription
+	}
+	prefetched := weather.PrefetchCities(ctx, d.fetcher, d.prefetch, citiesOf(subs))
+	for _, a := range alerts {
+		cities, err := d.fetchCities(ctx, a.Subscription, prefetched)
+		if err != nil {
+			// nothing is owed to an alert subscription: evaluate it again next time
+			continue
+		}
+		d.addForecasts(ctx, cities, forecasts)
+		triggers := alertTriggers(a.AlertConditions, cities)
+		if len(triggers) == 0 {
+			if a.Tripped {
+				cleared = append(cleared, a.ID)
+			}
+			continue
+		}
+		if a.Tripped {
+			continue
+		}
+		alertsTriggered.Inc()
+
+		rec := newDelivery(a.Subscription, slot, nil)
+		unsubURL := unsubscribeURL(d.baseURL, d.links, a.Subscription)
+		body, err := email.Render(email.TemplateAlert, email.AlertData{
+			Locale:         a.Locale,
+			Subscriber:     subscriberOf(a.Subscription, slot),
+			Triggers:       triggers,
+			Units:          string(a.Units),
+			ManageURL:      manageURL(d.baseURL, a.Subscription),
+			UnsubscribeURL: unsubURL,
+			OpenPixelURL:   d.openURL(a.Subscription, rec),
+			Attributions:   d.attributionsFor(cities),
+		})
+		if err != nil {
+			d.logger.Error("failed to render weather alert", zap.Error(err))
+			markFailed(&rec, err)
+			records = append(records, rec)
+			continue
+		}
+		messages = append(messages, email.EmailMessage{
+			To:             []string{a.Email},
+			Subject:        i18n.T(a.Locale, "alert.subject", email.DescribeCities(cities, a.Locale)),
+			Body:           body,
+			UnsubscribeURL: unsubURL,
+		})
+		records = append(records, rec)
+	}
+
+	var tripped []int
+	for _, r := range d.send(ctx, messages, records, true) {
+		if r.Succeeded() {
+			tripped = append(tripped, int(r.SubscriptionID.Int64))
+		}
+	}
+	if err := d.alerts.SetTripped(ctx, tripped, true); err != nil {
+		d.logger.Warn("failed to record sent alerts", zap.Error(err))
+	}
+	if err := d.alerts.SetTripped(ctx, cleared, false); err != nil {
+		d.logger.Warn("failed to record cleared alerts", zap.Error(err))
+	}
+}
+
+// alertTriggers returns the conditions of c that hold in cities, now or else in
+// tomorrow's forecast.
+func alertTriggers(c repository.AlertConditions, cities []email.CityWeather) []email.AlertTrigger {
+	var out []email.AlertTrigger
+	add := func(city, condition string, value, threshold float64) {
+		out = append(out, email.AlertTrigger{City: city, Condition: condition, Value: value, Threshold: threshold})
+	}
+	for _, cw := range cities {
+		w, f := cw.Weather, cw.Tomorrow
+		if x := c.TempAbove; x != nil {
+			switch {
+			case w.Temp > *x:
+				add(cw.City, email.AlertTempAbove, w.Temp, *x)
+			case f != nil && f.MaxTemp > *x:
+				add(cw.City, email.AlertTempAboveTomorrow, f.MaxTemp, *x)
+			}
+		}
+		if x := c.TempBelow; x != nil {
+			switch {
+			case w.Temp < *x:
+				add(cw.City, email.AlertTempBelow, w.Temp, *x)
+			case f != nil && f.MinTemp < *x:
+				add(cw.City, email.AlertTempBelowTomorrow, f.MinTemp, *x)
+			}
+		}
+		if x := c.WindAbove; x != nil {
+			switch {
+			case w.WindKph > *x:
+				add(cw.City, email.AlertWindAbove, w.WindKph, *x)
+			case f != nil && f.MaxWindKph > *x:
+				add(cw.City, email.AlertWindAboveTomorrow, f.MaxWindKph, *x)
+			}
+		}
+		if c.RainExpected {
+			switch {
+			case precipitating(w.Description):
+				out = append(out, email.AlertTrigger{City: cw.City, Condition: email.AlertRain, Value: 100,
+					Description: w.Description})
+			case f != nil && f.ChanceOfRain >= rainLikely:
+				add(cw.City, email.AlertRainTomorrow, float64(f.ChanceOfRain), 0)
+			}
+		}
+	}
+	return out
+}
+
+// precipitating reports whether a current weather description is precipitation.
+func precipitating(description string) bool {
+	description = strings.ToLower(description)
+	for _, w := range precipitationWords {
+		if strings.Contains(description, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linktoken"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/notify"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/pii"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slack"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/sms"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/summary"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+)
+
+// BuildDispatcher returns the dispatcher of weather updates configured by cfg, the same
+// in every process sending them (the scheduler and the email worker): every channel
+// configured, dead-lettering, send tracking and claims, forecasts, notify-on-change, temperature
+// charts while rolled out, the recipient guard with MIN_EMAIL_INTERVAL, and
+// WEATHER_PREFETCH_CONCURRENCY cities fetched at once across its batches. Alerts and
+// warnings are left to the caller.
+func BuildDispatcher(cfg *config.Config, db *sqlx.DB, rdb *redis.Client, cipher *pii.Cipher,
+	fetcher weather.Fetcher, sender email.EmailSender, logger *zap.Logger,
+) (*Dispatcher, error) {
+	linkSigner, err := linksign.NewSigner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	linkTokens, err := linktoken.NewDeriver(cfg)
+	if err != nil {
+		return nil, err
+	}
+	featureFlags, err := features.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	subRepo := repository.NewSubscriptionRepository(db, cipher, linkTokens, logger)
+	d := NewDispatcher(subRepo, repository.NewSuppressionRepository(db, logger), fetcher, sender,
+		repository.NewDeliveryRepository(db, cipher, linkTokens, logger), cfg.BaseURL, logger).
+		WithPrefetchLimiter(weather.NewPrefetchLimiter(cfg.WeatherPrefetchConcurrency)).
+		WithAttributions(weather.ProviderAttributions(cfg)).
+		WithLinkSigner(linkSigner).
+		WithFeatures(featureFlags).
+		WithSummarizer(summary.New(cfg, rdb, logger)).
+		WithNotifier(notify.NewSlack(slack.NewClient(cfg.SlackPostTimeout))).
+		WithDeadLetter(repository.NewDeadLetterRepository(db, logger), cfg.DeadLetterAfter).
+		WithSendRecorder(subRepo)
+	if cfg.SendClaimLease > 0 {
+		d.WithSendClaims(subRepo, cfg.SendClaimLease)
+	}
+	if texts := sms.New(cfg); texts != nil {
+		d.WithNotifier(notify.NewSMS(texts))
+	}
+	if forecasts := weather.BuildForecastFetcher(cfg, rdb, logger); forecasts != nil {
+		d.WithForecasts(forecasts)
+	}
+	d.WithChangeFilter(repository.NewLastSentRepository(db, logger), ChangeThresholds{
+		Temp:       cfg.NotifyChangeTempDelta,
+		Humidity:   cfg.NotifyChangeHumidityDelta,
+		MaxSilence: cfg.NotifyChangeMaxSilence,
+	})
+	// weather history for the temperature charts, only kept while the charts are rolled out
+	if featureFlags.Stage(features.TemperatureCharts) != features.StageOff {
+		d.WithSnapshots(repository.NewSnapshotRepository(db, logger))
+	}
+	// minimum interval between emails to the same address (shared through Redis)
+	if cfg.MinEmailInterval > 0 {
+		d.WithRecipientGuard(NewSendGuard(rdb, cfg.MinEmailInterval, logger))
+	}
+	return d, nil
+}
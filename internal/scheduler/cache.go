@@ -0,0 +1,265 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// BatchSource selects the subscriptions due in a given minute slot. Hourly slots are
+// UTC minutes; daily batches are due when at is their local time in their timezone.
+// repository.SubscriptionRepository and ScheduleCache both implement it.
+type BatchSource interface {
+	HourlyBatch(ctx context.Context, minute int) ([]repository.Subscription, error)
+	DailyBatch(ctx context.Context, at time.Time) ([]repository.Subscription, error)
+}
+
+// reloadRetryDelay spaces the reloads RunRefresh retries after a notification the
+// cache could not apply.
+const reloadRetryDelay = 5 * time.Second
+
+// ScheduleCache keeps every confirmed subscription in memory, indexed by slot,
+// and refreshes it incrementally from subscriptions_changed notifications.
+// While it is stale (listener down, never loaded, a notification it could not
+// apply, or older than maxAge without a full reload) batch lookups fall back to
+// the repository queries.
+type ScheduleCache struct {
+	repo   repository.SubscriptionRepository
+	maxAge time.Duration
+	logger *zap.Logger
+	reload chan struct{} // asks RunRefresh for a full reload
+
+	mu       sync.RWMutex
+	subs     map[int]repository.Subscription
+	hourly   map[int]map[int]struct{}            // minute -> ids
+	daily    map[string]map[int]map[int]struct{} // timezone -> local hour*60+minute -> ids
+	live     bool                                // listener connected since the last reload
+	missed   int                                 // notifications that could not be applied
+	applied  int                                 // missed as of the start of the last reload
+	loadedAt time.Time
+}
+
+// NewScheduleCache returns an empty (stale) cache; run it with a repository.Listener.
+func NewScheduleCache(repo repository.SubscriptionRepository, maxAge time.Duration, logger *zap.Logger) *ScheduleCache {
+	return &ScheduleCache{
+		repo:   repo,
+		maxAge: maxAge,
+		logger: logger,
+		subs:   map[int]repository.Subscription{},
+		hourly: map[int]map[int]struct{}{},
+		daily:  map[string]map[int]map[int]struct{}{},
+		reload: make(chan struct{}, 1),
+	}
+}
+
+// Connected implements repository.NotificationHandler with a full reload.
+func (c *ScheduleCache) Connected(ctx context.Context) {
+	if err := c.Reload(ctx); err != nil {
+		c.logger.Error("schedule cache reload failed", zap.Error(err))
+		return
+	}
+	c.mu.Lock()
+	c.live = true
+	c.mu.Unlock()
+}
+
+// Disconnected implements repository.NotificationHandler; the cache is stale until reconnect.
+func (c *ScheduleCache) Disconnected(error) {
+	c.mu.Lock()
+	c.live = false
+	c.mu.Unlock()
+}
+
+// Notify implements repository.NotificationHandler by re-reading the changed row.
+func (c *ScheduleCache) Notify(ctx context.Context, n repository.Notification) {
+	var payload struct {
+		Op string `json:"op"`
+		ID int    `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(n.Payload), &payload); err != nil {
+		c.logger.Warn("invalid subscription notification", zap.String("payload", n.Payload), zap.Error(err))
+		return
+	}
+
+	sub, err := c.repo.GetByID(ctx, payload.ID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.remove(payload.ID)
+	case err != nil:
+		// We cannot tell what changed: fall back to queries until RunRefresh reloads;
+		// the listener is fine, so waiting for a reconnect could take forever
+		c.logger.Warn("schedule cache refresh failed, reloading", zap.Int("id", payload.ID), zap.Error(err))
+		c.mu.Lock()
+		c.missed++
+		c.mu.Unlock()
+		c.requestReload()
+	default:
+		c.upsert(sub)
+	}
+}
+
+// Reload replaces the cache content with all confirmed subscriptions.
+func (c *ScheduleCache) Reload(ctx context.Context) error {
+	c.mu.RLock()
+	missed := c.missed
+	c.mu.RUnlock()
+	subs, err := c.repo.ListConfirmed(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applied = missed // a notification missed during the query needs another reload
+	c.subs = make(map[int]repository.Subscription, len(subs))
+	c.hourly = map[int]map[int]struct{}{}
+	c.daily = map[string]map[int]map[int]struct{}{}
+	for _, sub := range subs {
+		c.indexLocked(sub)
+	}
+	c.loadedAt = time.Now()
+	c.logger.Info("schedule cache reloaded", zap.Int("count", len(subs)))
+	return nil
+}
+
+// RunRefresh periodically reloads the cache while connected, bounding the damage of a
+// lost notification to maxAge, and reloads it as soon as a notification could not be
+// applied, retrying every reloadRetryDelay. It blocks until ctx is cancelled.
+func (c *ScheduleCache) RunRefresh(ctx context.Context) {
+	ticker := time.NewTicker(c.maxAge / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.reload:
+			if err := c.Reload(ctx); err != nil {
+				c.logger.Warn("schedule cache reload failed, retrying", zap.Duration("in", reloadRetryDelay), zap.Error(err))
+				time.AfterFunc(reloadRetryDelay, c.requestReload)
+			}
+		case <-ticker.C:
+			c.mu.RLock()
+			live := c.live
+			c.mu.RUnlock()
+			if !live {
+				continue
+			}
+			if err := c.Reload(ctx); err != nil {
+				c.logger.Warn("periodic schedule cache reload failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// HourlyBatch implements BatchSource.
+func (c *ScheduleCache) HourlyBatch(ctx context.Context, minute int) ([]repository.Subscription, error) {
+	if subs, ok := c.lookup(c.hourly, minute); ok {
+		return subs, nil
+	}
+	return c.repo.HourlyBatch(ctx, minute)
+}
+
+// DailyBatch implements BatchSource.
+func (c *ScheduleCache) DailyBatch(ctx context.Context, at time.Time) ([]repository.Subscription, error) {
+	c.mu.RLock()
+	if !c.freshLocked() {
+		c.mu.RUnlock()
+		return c.repo.DailyBatch(ctx, at)
+	}
+	defer c.mu.RUnlock()
+	var subs []repository.Subscription
+	for tz, index := range c.daily {
+		slot, ok := localSlot(at, loadLocation(tz, c.logger))
+		if !ok {
+			continue
+		}
+		for id := range index[slot] {
+			subs = append(subs, c.subs[id])
+		}
+	}
+	return subs, nil
+}
+
+// lookup returns the slot content, or ok=false when the cache is stale.
+func (c *ScheduleCache) lookup(index map[int]map[int]struct{}, slot int) ([]repository.Subscription, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.freshLocked() {
+		return nil, false
+	}
+	subs := make([]repository.Subscription, 0, len(index[slot]))
+	for id := range index[slot] {
+		subs = append(subs, c.subs[id])
+	}
+	return subs, true
+}
+
+// freshLocked reports whether the cache may answer lookups; c.mu must be held.
+func (c *ScheduleCache) freshLocked() bool {
+	if !c.live || c.missed != c.applied || time.Since(c.loadedAt) > c.maxAge {
+		c.logger.Debug("schedule cache stale, falling back to database")
+		return false
+	}
+	return true
+}
+
+// requestReload asks RunRefresh for a reload, unless one is already asked for.
+func (c *ScheduleCache) requestReload() {
+	select {
+	case c.reload <- struct{}{}:
+	default:
+	}
+}
+
+func (c *ScheduleCache) upsert(sub repository.Subscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(sub.ID)
+	if sub.Confirmed {
+		c.indexLocked(sub)
+	}
+}
+
+func (c *ScheduleCache) remove(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(id)
+}
+
+func (c *ScheduleCache) indexLocked(sub repository.Subscription) {
+	c.subs[sub.ID] = sub
+	var index map[int]map[int]struct{}
+	var slot int
+	switch sub.Frequency {
+	case repository.FrequencyHourly:
+		index, slot = c.hourly, int(sub.ScheduledMinute)
+	case repository.FrequencyDaily:
+		if c.daily[sub.Timezone] == nil {
+			c.daily[sub.Timezone] = map[int]map[int]struct{}{}
+		}
+		index, slot = c.daily[sub.Timezone], int(sub.ScheduledHour)*60+int(sub.ScheduledMinute)
+	default:
+		return
+	}
+	if index[slot] == nil {
+		index[slot] = map[int]struct{}{}
+	}
+	index[slot][sub.ID] = struct{}{}
+}
+
+func (c *ScheduleCache) removeLocked(id int) {
+	old, ok := c.subs[id]
+	if !ok {
+		return
+	}
+	delete(c.subs, id)
+	delete(c.hourly[int(old.ScheduledMinute)], id)
+	delete(c.daily[old.Timezone][int(old.ScheduledHour)*60+int(old.ScheduledMinute)], id)
+}
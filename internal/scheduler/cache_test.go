@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// flakyRepo serves subs, failing GetByID while down; batch queries are counted.
+type flakyRepo struct {
+	repository.SubscriptionRepository
+
+	mu      sync.Mutex
+	subs    []repository.Subscription
+	down    bool
+	queries int
+}
+
+func (r *flakyRepo) GetByID(_ context.Context, id int) (repository.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		return repository.Subscription{}, errors.New("connection reset")
+	}
+	for _, sub := range r.subs {
+		if sub.ID == id {
+			return sub, nil
+		}
+	}
+	return repository.Subscription{}, errors.New("not found")
+}
+
+func (r *flakyRepo) ListConfirmed(context.Context) ([]repository.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]repository.Subscription(nil), r.subs...), nil
+}
+
+func (r *flakyRepo) HourlyBatch(_ context.Context, minute int) ([]repository.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+	var out []repository.Subscription
+	for _, sub := range r.subs {
+		if int(sub.ScheduledMinute) == minute {
+			out = append(out, sub)
+		}
+	}
+	return out, nil
+}
+
+func TestScheduleCache_ReloadsAfterAFailedRefresh(t *testing.T) {
+	repo := &flakyRepo{subs: []repository.Subscription{
+		{ID: 1, Frequency: repository.FrequencyHourly, ScheduledMinute: 5, Confirmed: true},
+	}}
+	c := NewScheduleCache(repo, time.Hour, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Connected(ctx)
+
+	// subscription 2 is created while its row cannot be read
+	repo.mu.Lock()
+	repo.down = true
+	repo.subs = append(repo.subs, repository.Subscription{ID: 2, Frequency: repository.FrequencyHourly,
+		ScheduledMinute: 5, Confirmed: true})
+	repo.mu.Unlock()
+	c.Notify(ctx, repository.Notification{Payload: `{"op":"INSERT","id":2}`})
+
+	if subs, _ := c.HourlyBatch(ctx, 5); len(subs) != 2 || repo.queries != 1 {
+		t.Fatalf("stale cache served %d subscriptions with %d queries, want 2 from the database", len(subs), repo.queries)
+	}
+
+	go c.RunRefresh(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.RLock()
+		fresh := c.live && c.missed == c.applied
+		c.mu.RUnlock()
+		if fresh {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cache not reloaded after the failed refresh")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if subs, _ := c.HourlyBatch(ctx, 5); len(subs) != 2 || repo.queries != 1 {
+		t.Errorf("reloaded cache served %d subscriptions with %d queries, want 2 from memory", len(subs), repo.queries)
+	}
+}
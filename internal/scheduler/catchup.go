@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+var ticksCaughtUp = metrics.NewCounter("weather_scheduler_ticks_caught_up_total",
+	"Minute ticks missed while no scheduler ran, run late.")
+
+// TickStore remembers the last tick a scheduler job ran.
+type TickStore interface {
+	LastTick(ctx context.Context, job string) (time.Time, error)
+	RecordTick(ctx context.Context, job string, tick time.Time) error
+}
+
+// CatchUp finds the minute ticks of a job missed while no scheduler was running (a
+// deploy, a crash), so that the next tick runs them late instead of never. Ticks older
+// than horizon are given up. A nil CatchUp never catches up.
+type CatchUp struct {
+	store   TickStore
+	job     string
+	horizon time.Duration
+	logger  *zap.Logger
+}
+
+// NewCatchUp returns nil, catching up nothing, when horizon is not positive.
+func NewCatchUp(store TickStore, job string, horizon time.Duration, logger *zap.Logger) *CatchUp {
+	if horizon <= 0 {
+		return nil
+	}
+	return &CatchUp{store: store, job: job, horizon: horizon, logger: logger}
+}
+
+// Missed returns the minute ticks after the last one recorded and before tick, oldest
+// first, back to horizon before tick at most. Nothing is missed before the first tick
+// ever recorded, nor when the last tick cannot be read.
+func (c *CatchUp) Missed(ctx context.Context, tick time.Time) []time.Time {
+	if c == nil {
+		return nil
+	}
+	last, err := c.store.LastTick(ctx, c.job)
+	if err != nil || last.IsZero() {
+		return nil
+	}
+	missed := missedTicks(last, tick, c.horizon)
+	if len(missed) > 0 {
+		c.logger.Info("catching up missed scheduler ticks", zap.String("job", c.job),
+			zap.Time("from", missed[0]), zap.Int("ticks", len(missed)),
+			zap.Duration("gap", tick.Sub(last)))
+		ticksCaughtUp.Add(float64(len(missed)))
+	}
+	return missed
+}
+
+// Record remembers tick as run.
+func (c *CatchUp) Record(ctx context.Context, tick time.Time) {
+	if c == nil {
+		return
+	}
+	// a tick not recorded is only caught up again by the next one: nothing to do
+	_ = c.store.RecordTick(ctx, c.job, tick)
+}
+
+// missedTicks returns the minutes strictly between last and tick, no earlier than
+// horizon before tick.
+func missedTicks(last, tick time.Time, horizon time.Duration) []time.Time {
+	tick = tick.Truncate(time.Minute)
+	from := last.Truncate(time.Minute).Add(time.Minute)
+	if oldest := tick.Add(-horizon.Truncate(time.Minute)); from.Before(oldest) {
+		from = oldest
+	}
+	var out []time.Time
+	for t := from; t.Before(tick); t = t.Add(time.Minute) {
+		out = append(out, t)
+	}
+	return out
+}
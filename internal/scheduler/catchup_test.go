@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// memoryTicks is an in-memory TickStore.
+type memoryTicks map[string]time.Time
+
+func (m memoryTicks) LastTick(_ context.Context, job string) (time.Time, error) { return m[job], nil }
+
+func (m memoryTicks) RecordTick(_ context.Context, job string, tick time.Time) error {
+	if tick.After(m[job]) {
+		m[job] = tick
+	}
+	return nil
+}
+
+func TestMissedTicks(t *testing.T) {
+	tick := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		last      time.Time
+		horizon   time.Duration
+		wantFirst time.Time
+		wantLen   int
+	}{
+		{"no gap", tick.Add(-time.Minute), time.Hour, time.Time{}, 0},
+		{"same tick", tick, time.Hour, time.Time{}, 0},
+		{"ten minutes down", tick.Add(-11 * time.Minute), time.Hour, tick.Add(-10 * time.Minute), 10},
+		{"beyond the horizon", tick.Add(-3 * time.Hour), 30 * time.Minute, tick.Add(-30 * time.Minute), 30},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := missedTicks(c.last, tick, c.horizon)
+			if len(got) != c.wantLen {
+				t.Fatalf("missedTicks() = %v, want %d ticks", got, c.wantLen)
+			}
+			if c.wantLen > 0 && (!got[0].Equal(c.wantFirst) || !got[len(got)-1].Equal(tick.Add(-time.Minute))) {
+				t.Errorf("missedTicks() = %v..%v, want %v..%v", got[0], got[len(got)-1], c.wantFirst, tick.Add(-time.Minute))
+			}
+		})
+	}
+}
+
+func TestCatchUp_MissedSinceRecorded(t *testing.T) {
+	store := memoryTicks{}
+	c := NewCatchUp(store, "updates", time.Hour, zap.NewNop())
+	tick := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+	if got := c.Missed(context.Background(), tick); len(got) != 0 {
+		t.Errorf("Missed() before any tick recorded = %v, want none", got)
+	}
+	c.Record(context.Background(), tick)
+	if got := c.Missed(context.Background(), tick.Add(5*time.Minute)); len(got) != 4 {
+		t.Errorf("Missed() after 5 minutes = %v, want 4 ticks", got)
+	}
+	c.Record(context.Background(), tick.Add(-time.Hour))
+	if !store["updates"].Equal(tick) {
+		t.Errorf("recorded tick moved back to %v", store["updates"])
+	}
+
+	none := NewCatchUp(store, "updates", 0, zap.NewNop())
+	if got := none.Missed(context.Background(), tick.Add(5*time.Minute)); got != nil {
+		t.Errorf("Missed() with no horizon = %v, want none", got)
+	}
+}
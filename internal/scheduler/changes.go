@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+var unchangedSkipped = metrics.NewCounter("weather_updates_unchanged_skipped_total",
+	"Weather updates of notify-on-change subscriptions skipped because the weather had not changed.")
+
+// LastSentStore remembers the weather last sent to notify-on-change subscriptions.
+type LastSentStore interface {
+	ForSubscriptions(ctx context.Context, ids []int) (map[int]map[string]repository.SentWeather, error)
+	Save(ctx context.Context, sent []repository.SentWeather) error
+}
+
+// ChangeThresholds decide whether the weather changed enough since the last update to
+// send the next one to a notify-on-change subscription.
+type ChangeThresholds struct {
+	Temp       float64       // °C
+	Humidity   int           // percentage points
+	MaxSilence time.Duration // an update is sent at least this often
+}
+
+// changed reports whether cities, the weather of an update due at now, differ from the
+// last sent weather enough to send it. Any city without a last sent weather (a new city,
+// or the first update) is a change.
+func (t ChangeThresholds) changed(last map[string]repository.SentWeather, cities []email.CityWeather, now time.Time,
+) bool {
+	if len(last) != len(cities) {
+		return true
+	}
+	for _, c := range cities {
+		prev, ok := last[strings.ToLower(c.City)]
+		if !ok || now.Sub(prev.SentAt) >= t.MaxSilence {
+			return true
+		}
+		w := c.Weather
+		if math.Abs(w.Temp-prev.Temp) >= t.Temp || abs(w.Humidity-prev.Humidity) >= t.Humidity ||
+			!strings.EqualFold(w.Description, prev.Description) {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// sentWeather is what to remember of the update sent to subscription id at.
+func sentWeather(id int, cities []email.CityWeather, at time.Time) []repository.SentWeather {
+	out := make([]repository.SentWeather, len(cities))
+	for i, c := range cities {
+		out[i] = repository.SentWeather{SubscriptionID: id, City: c.City, Temp: c.Weather.Temp,
+			Humidity: c.Weather.Humidity, Description: c.Weather.Description, SentAt: at}
+	}
+	return out
+}
@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/chart"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+// SnapshotPruneSpec deletes old weather snapshots once a day, at 03:45 UTC.
+const SnapshotPruneSpec = "45 3 * * *"
+
+// SnapshotSpec records the weather of every subscribed city once an hour, at half past.
+const SnapshotSpec = "30 * * * *"
+
+const (
+	// chartWindow is the history a temperature chart shows.
+	chartWindow = 24 * time.Hour
+	// snapshotRetention keeps a day of margin over chartWindow.
+	snapshotRetention = 2 * chartWindow
+
+	chartWidth, chartHeight = 480, 120
+)
+
+// SnapshotStore keeps the hourly weather history of cities.
+type SnapshotStore interface {
+	Record(ctx context.Context, s repository.Snapshot) error
+	Since(ctx context.Context, city string, since time.Time) ([]repository.Snapshot, error)
+}
+
+// snapshotRecorder records the weather fetched for emails, at most once per city and
+// hour from this process.
+type snapshotRecorder struct {
+	store  SnapshotStore
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	recorded map[string]time.Time // lower-cased city -> hour of the last snapshot
+}
+
+func (r *snapshotRecorder) record(ctx context.Context, city string, w types.Weather, now time.Time) {
+	key, hour := strings.ToLower(city), now.Truncate(time.Hour)
+	r.mu.Lock()
+	if r.recorded[key].Equal(hour) {
+		r.mu.Unlock()
+		return
+	}
+	r.recorded[key] = hour
+	r.mu.Unlock()
+
+	err := r.store.Record(ctx, repository.Snapshot{City: city, ObservedAt: hour, Temp: w.Temp, Humidity: w.Humidity,
+		Description: w.Description})
+	if err != nil {
+		r.logger.Warn("failed to record weather snapshot", zap.String("city", city), zap.Error(err))
+	}
+}
+
+// chartsFor attaches the temperature chart of the 24 hours before now to every city
+// with enough history, and returns the inline parts to send with the email. Charts are
+// drawn once per city and cached in drawn, shared by the emails of a batch.
+func (d *Dispatcher) chartsFor(ctx context.Context, cities []email.CityWeather, now time.Time,
+	drawn map[string][]byte,
+) []email.InlinePart {
+	var parts []email.InlinePart
+	for i := range cities {
+		key := strings.ToLower(cities[i].City)
+		png, ok := drawn[key]
+		if !ok {
+			png = d.drawChart(ctx, cities[i].City, now)
+			drawn[key] = png
+		}
+		if png == nil {
+			continue
+		}
+		id := fmt.Sprintf("chart-%d@weather", i)
+		cities[i].Chart = id
+		parts = append(parts, email.InlinePart{ContentID: id, ContentType: "image/png",
+			Filename: fmt.Sprintf("temperature-%d.png", i), Data: png})
+	}
+	return parts
+}
+
+// drawChart returns the chart of city, nil when there is not enough history or it
+// cannot be read: the email then goes out without it.
+func (d *Dispatcher) drawChart(ctx context.Context, city string, now time.Time) []byte {
+	snapshots, err := d.snapshots.store.Since(ctx, city, now.Add(-chartWindow))
+	if err != nil || len(snapshots) < 2 {
+		return nil
+	}
+	points := make([]chart.Point, len(snapshots))
+	for i, s := range snapshots {
+		points[i] = chart.Point{At: s.ObservedAt, Value: s.Temp}
+	}
+	png, err := chart.TemperaturePNG(points, chartWidth, chartHeight)
+	if err != nil {
+		d.logger.Warn("failed to draw temperature chart", zap.String("city", city), zap.Error(err))
+		return nil
+	}
+	return png
+}
+
+// CityLister lists the cities of the active subscriptions.
+type CityLister interface {
+	SubscribedCities(ctx context.Context) ([]string, error)
+}
+
+// RecordSnapshots stores a snapshot of the current weather of every subscribed city for
+// the hour of now, so that the cities of daily subscribers have a chart too: the
+// updates alone only fetch a city hourly when it has hourly subscribers. A city that
+// cannot be fetched misses a point of its chart.
+func RecordSnapshots(ctx context.Context, cities CityLister, fetcher weather.Fetcher, store SnapshotStore,
+	now time.Time, logger *zap.Logger,
+) {
+	list, err := cities.SubscribedCities(ctx)
+	if err != nil {
+		logger.Error("weather snapshots skipped: cannot list the subscribed cities", zap.Error(err))
+		return
+	}
+	hour := now.Truncate(time.Hour)
+	var recorded, failed int
+	for _, city := range list {
+		w, err := fetcher.FetchCurrent(ctx, city)
+		if err == nil {
+			err = store.Record(ctx, repository.Snapshot{City: city, ObservedAt: hour, Temp: w.Temp,
+				Humidity: w.Humidity, Description: w.Description})
+		}
+		if err != nil {
+			logger.Warn("failed to record weather snapshot", zap.String("city", city), zap.Error(err))
+			failed++
+			continue
+		}
+		recorded++
+	}
+	logger.Info("recorded weather snapshots", zap.Int("recorded", recorded), zap.Int("failed", failed))
+}
+
+// SnapshotDeleter deletes old weather snapshots.
+type SnapshotDeleter interface {
+	DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// PruneSnapshots deletes the snapshots too old to be charted.
+func PruneSnapshots(ctx context.Context, repo SnapshotDeleter, now time.Time, logger *zap.Logger) {
+	n, err := repo.DeleteOlderThan(ctx, now.Add(-snapshotRetention))
+	if err != nil {
+		logger.Error("weather snapshot cleanup failed", zap.Error(err))
+		return
+	}
+	logger.Info("deleted old weather snapshots", zap.Int64("deleted", n))
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+// CleanupSpec runs the unconfirmed subscription cleanup and the purge of unsubscribed
+// ones once a day, at 03:30 UTC.
+const CleanupSpec = "30 3 * * *"
+
+// cleanupBatchSize bounds the rows deleted per statement.
+const cleanupBatchSize = 1000
+
+var (
+	unconfirmedPurged = metrics.NewCounter("weather_unconfirmed_subscriptions_purged_total",
+		"Subscriptions deleted because they were never confirmed.")
+	unconfirmedCleanupLastSuccess = metrics.NewGauge("weather_unconfirmed_cleanup_last_success_timestamp_seconds",
+		"Time of the last completed unconfirmed subscription cleanup.")
+	deletedPurged = metrics.NewCounter("weather_deleted_subscriptions_purged_total",
+		"Unsubscribed subscriptions deleted for good after the retention period.")
+	deletedPurgeLastSuccess = metrics.NewGauge("weather_deleted_purge_last_success_timestamp_seconds",
+		"Time of the last completed purge of unsubscribed subscriptions.")
+)
+
+// UnconfirmedDeleter deletes stale unconfirmed subscriptions.
+type UnconfirmedDeleter interface {
+	DeleteUnconfirmedOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+// UnconfirmedCleaner deletes subscriptions whose confirmation link was never used
+// within the retention period, so abandoned sign-ups do not pile up.
+type UnconfirmedCleaner struct {
+	repo      UnconfirmedDeleter
+	retention time.Duration
+	logger    *zap.Logger
+}
+
+func NewUnconfirmedCleaner(repo UnconfirmedDeleter, retention time.Duration, logger *zap.Logger) *UnconfirmedCleaner {
+	return &UnconfirmedCleaner{repo: repo, retention: retention, logger: logger}
+}
+
+// Run deletes the subscriptions created more than the retention period before now and
+// still unconfirmed, in batches.
+func (c *UnconfirmedCleaner) Run(ctx context.Context, now time.Time) {
+	cutoff := now.Add(-c.retention)
+	total, err := deleteInBatches(ctx, c.repo.DeleteUnconfirmedOlderThan, cutoff, unconfirmedPurged)
+	if err != nil {
+		c.logger.Error("unconfirmed subscription cleanup failed",
+			zap.Int64("deleted", total), zap.Error(err))
+		return
+	}
+	unconfirmedCleanupLastSuccess.SetToTime(now)
+	c.logger.Info("deleted stale unconfirmed subscriptions",
+		zap.Int64("deleted", total), zap.Time("created_before", cutoff))
+}
+
+// DeletedPurger hard-deletes subscriptions unsubscribed long ago.
+type DeletedPurger interface {
+	PurgeDeletedOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+// DeletedCleaner deletes for good the subscriptions unsubscribed more than the
+// retention period ago; until then they are kept, soft-deleted, for analytics and
+// dispute resolution.
+type DeletedCleaner struct {
+	repo      DeletedPurger
+	retention time.Duration
+	logger    *zap.Logger
+}
+
+func NewDeletedCleaner(repo DeletedPurger, retention time.Duration, logger *zap.Logger) *DeletedCleaner {
+	return &DeletedCleaner{repo: repo, retention: retention, logger: logger}
+}
+
+// Run purges the subscriptions unsubscribed more than the retention period before
+// now, in batches.
+func (c *DeletedCleaner) Run(ctx context.Context, now time.Time) {
+	cutoff := now.Add(-c.retention)
+	total, err := deleteInBatches(ctx, c.repo.PurgeDeletedOlderThan, cutoff, deletedPurged)
+	if err != nil {
+		c.logger.Error("deleted subscription purge failed",
+			zap.Int64("deleted", total), zap.Error(err))
+		return
+	}
+	deletedPurgeLastSuccess.SetToTime(now)
+	c.logger.Info("purged unsubscribed subscriptions",
+		zap.Int64("deleted", total), zap.Time("unsubscribed_before", cutoff))
+}
+
+// deleteInBatches calls del until it deletes fewer than cleanupBatchSize rows, counting
+// them in purged, and returns how many it deleted.
+func deleteInBatches(ctx context.Context, del func(context.Context, time.Time, int) (int64, error),
+	cutoff time.Time, purged *metrics.Counter) (int64, error) {
+	var total int64
+	for {
+		n, err := del(ctx, cutoff, cleanupBatchSize)
+		total += n
+		purged.Add(float64(n))
+		if err != nil || n < cleanupBatchSize {
+			return total, err
+		}
+	}
+}
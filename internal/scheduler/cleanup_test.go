@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// batchDeleter has remaining stale rows and deletes up to limit per call.
+type batchDeleter struct {
+	remaining int64
+	calls     int
+	cutoff    time.Time
+}
+
+func (d *batchDeleter) DeleteUnconfirmedOlderThan(_ context.Context, cutoff time.Time, limit int) (int64, error) {
+	d.calls++
+	d.cutoff = cutoff
+	n := min(d.remaining, int64(limit))
+	d.remaining -= n
+	return n, nil
+}
+
+func TestUnconfirmedCleaner_DeletesInBatchesUntilDone(t *testing.T) {
+	repo := &batchDeleter{remaining: 2*cleanupBatchSize + 7}
+	now := time.Date(2026, 10, 16, 3, 30, 0, 0, time.UTC)
+	before := unconfirmedPurged.Value()
+
+	NewUnconfirmedCleaner(repo, 7*24*time.Hour, zap.NewNop()).Run(context.Background(), now)
+
+	if repo.remaining != 0 || repo.calls != 3 {
+		t.Errorf("remaining = %d after %d calls, want 0 after 3", repo.remaining, repo.calls)
+	}
+	if want := now.Add(-7 * 24 * time.Hour); !repo.cutoff.Equal(want) {
+		t.Errorf("cutoff = %v, want %v", repo.cutoff, want)
+	}
+	if got := unconfirmedPurged.Value() - before; got != 2*cleanupBatchSize+7 {
+		t.Errorf("purged counter grew by %v, want %d", got, 2*cleanupBatchSize+7)
+	}
+}
+
+// batchPurger has remaining unsubscribed rows and purges up to limit per call.
+type batchPurger struct{ batchDeleter }
+
+func (p *batchPurger) PurgeDeletedOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return p.DeleteUnconfirmedOlderThan(ctx, cutoff, limit)
+}
+
+func TestDeletedCleaner_PurgesInBatchesUntilDone(t *testing.T) {
+	repo := &batchPurger{batchDeleter{remaining: cleanupBatchSize + 1}}
+	now := time.Date(2026, 10, 16, 3, 30, 0, 0, time.UTC)
+	before := deletedPurged.Value()
+
+	NewDeletedCleaner(repo, 90*24*time.Hour, zap.NewNop()).Run(context.Background(), now)
+
+	if repo.remaining != 0 || repo.calls != 2 {
+		t.Errorf("remaining = %d after %d calls, want 0 after 2", repo.remaining, repo.calls)
+	}
+	if want := now.Add(-90 * 24 * time.Hour); !repo.cutoff.Equal(want) {
+		t.Errorf("cutoff = %v, want %v", repo.cutoff, want)
+	}
+	if got := deletedPurged.Value() - before; got != cleanupBatchSize+1 {
+		t.Errorf("purged counter grew by %v, want %d", got, cleanupBatchSize+1)
+	}
+}
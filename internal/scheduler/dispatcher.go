@@ -0,0 +1,985 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/notify"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/summary"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+var (
+	deadLettered = metrics.NewCounter("weather_subscriptions_dead_lettered_total",
+		"Subscriptions dead-lettered after failed sends in a row.")
+	deliveriesSent = metrics.NewCounter("weather_deliveries_sent_total",
+		"Updates, alerts, warnings and welcomes delivered, on every channel.")
+	deliveriesFailed = metrics.NewCounter("weather_deliveries_failed_total",
+		"Updates, alerts, warnings and welcomes recorded as failed: not delivered, not rendered or no weather.")
+	fetchFailures = metrics.NewCounter("weather_fetch_failures_total",
+		"Current weather fetches of a subscription's city that failed.")
+	claimSkipped = metrics.NewCounter("weather_updates_claim_skipped_total",
+		"Weather updates skipped as another run claimed or sent them (see WithSendClaims).")
+)
+
+// ActivityChecker re-reads which subscriptions may still be emailed.
+type ActivityChecker interface {
+	ActiveIDs(ctx context.Context, ids []int) (map[int]bool, error)
+}
+
+// SendTracker counts the consecutive failed sends of subscriptions, dead-lettering
+// those reaching after failures in a row.
+type SendTracker interface {
+	TrackSends(ctx context.Context, sent, failed []int, after int) ([]int, error)
+}
+
+// SendRecorder records when subscriptions were last tried and sent an update, which the
+// batch queries read to skip those already sent in the current window.
+type SendRecorder interface {
+	RecordSends(ctx context.Context, attempted, sent []int) error
+}
+
+// SendClaimer claims subscriptions for the batch about to send them an update, so that
+// overlapping runs do not both send it; RecordSends of SendRecorder releases the claims.
+type SendClaimer interface {
+	ClaimSends(ctx context.Context, ids []int, lease time.Duration) ([]int, error)
+}
+
+// SuppressionChecker reports which addresses are on the suppression list.
+type SuppressionChecker interface {
+	FilterSuppressed(ctx context.Context, emails []string) (map[string]bool, error)
+}
+
+// Dispatcher builds weather updates for subscriptions, delivers them through the
+// notifier of each subscription's channel, and records every outcome in the delivery log.
+//
+// Batches are selected up to a minute before they are sent (weather fetches can be
+// slow), so right before handing messages to SMTP the dispatcher re-verifies that
+// every subscription is still confirmed and present. An unsubscribe committed
+// before that check is never emailed; an unconfirmed or uncommitted confirmation
+// is never visible to it in the first place. Suppressed addresses are dropped at
+// the same point, so a suppression imported mid-batch is honoured too.
+type Dispatcher struct {
+	checker      ActivityChecker
+	suppressions SuppressionChecker
+	fetcher      weather.Fetcher
+	prefetch     *weather.PrefetchLimiter // shared by the batches of the dispatcher
+	notifiers    map[repository.Channel]notify.Notifier
+	deliveries   repository.DeliveryRepository
+	baseURL      string
+	guard        RecipientGuard // optional, limits weather updates per address
+	attributions weather.Attributions
+	links        *linksign.Signer        // optional, signs unsubscribe links
+	features     *features.Flags         // optional, every experimental block off when nil
+	summarizer   summary.Summarizer      // optional, writes the summary of daily emails
+	snapshots    *snapshotRecorder       // optional, weather history behind the temperature charts
+	forecasts    weather.ForecastFetcher // optional, adds tomorrow's forecast to daily emails
+	lastSent     LastSentStore           // optional, enables notify-on-change subscriptions
+	changes      ChangeThresholds
+	alerts       AlertTripper            // optional, enables alert subscriptions
+	warningFeed  weather.WarningsFetcher // optional, enables warning subscriptions
+	warningStore WarningStore
+	sendTracker  SendTracker // optional, dead-letters subscriptions whose sends keep failing
+	deadAfter    int
+	sendLog      SendRecorder // optional, records the last update sent to each subscription
+	claims       SendClaimer  // optional, claims the subscriptions of each batch before sending
+	claimLease   time.Duration
+	logger       *zap.Logger
+}
+
+func NewDispatcher(
+	checker ActivityChecker,
+	suppressions SuppressionChecker,
+	fetcher weather.Fetcher,
+	sender email.EmailSender,
+	deliveries repository.DeliveryRepository,
+	baseURL string,
+	logger *zap.Logger,
+) *Dispatcher {
+	d := &Dispatcher{checker: checker, suppressions: suppressions, fetcher: fetcher,
+		prefetch:  weather.NewPrefetchLimiter(weather.DefaultPrefetchConcurrency),
+		notifiers: make(map[repository.Channel]notify.Notifier), deliveries: deliveries, baseURL: baseURL, logger: logger}
+	return d.WithNotifier(notify.NewEmail(sender))
+}
+
+// WithNotifier delivers the weather updates of subscriptions on the channel of n through
+// it, replacing the notifier registered for that channel. Emails are sent by sender of
+// NewDispatcher; updates of a channel without a notifier are recorded as failed.
+func (d *Dispatcher) WithNotifier(n notify.Notifier) *Dispatcher {
+	d.notifiers[n.Channel()] = n
+	return d
+}
+
+// WithPrefetchLimiter caps the cities fetched at once by the batches of the dispatcher
+// with l, in place of weather.DefaultPrefetchConcurrency.
+func (d *Dispatcher) WithPrefetchLimiter(l *weather.PrefetchLimiter) *Dispatcher {
+	d.prefetch = l
+	return d
+}
+
+// WithRecipientGuard drops weather updates to addresses emailed within the guard window.
+func (d *Dispatcher) WithRecipientGuard(g RecipientGuard) *Dispatcher {
+	d.guard = g
+	return d
+}
+
+// WithAttributions adds the attribution line of every provider that supplied an
+// observation of an email to its footer.
+func (d *Dispatcher) WithAttributions(a weather.Attributions) *Dispatcher {
+	d.attributions = a
+	return d
+}
+
+// WithLinkSigner signs the unsubscribe links of the emails.
+func (d *Dispatcher) WithLinkSigner(s *linksign.Signer) *Dispatcher {
+	d.links = s
+	return d
+}
+
+// WithFeatures enables experimental content blocks per their rollout stage and each
+// subscription's beta opt-in.
+func (d *Dispatcher) WithFeatures(f *features.Flags) *Dispatcher {
+	d.features = f
+	return d
+}
+
+// WithSummarizer opens daily emails of subscriptions with AI summaries enabled with a
+// summary of their weather.
+func (d *Dispatcher) WithSummarizer(s summary.Summarizer) *Dispatcher {
+	d.summarizer = s
+	return d
+}
+
+// WithSnapshots records the weather fetched for every email in store, and adds the
+// temperature chart of the last 24 hours of each city to the daily emails of
+// subscriptions with temperature charts enabled.
+func (d *Dispatcher) WithSnapshots(store SnapshotStore) *Dispatcher {
+	d.snapshots = &snapshotRecorder{store: store, logger: d.logger, recorded: make(map[string]time.Time)}
+	return d
+}
+
+// WithForecasts adds tomorrow's forecast of every city to daily emails.
+func (d *Dispatcher) WithForecasts(f weather.ForecastFetcher) *Dispatcher {
+	d.forecasts = f
+	return d
+}
+
+// WithChangeFilter skips the updates of notify-on-change subscriptions whose weather
+// did not change past thresholds since the last update sent, remembered in store.
+// Without it, notify-on-change subscriptions get every update.
+func (d *Dispatcher) WithChangeFilter(store LastSentStore, thresholds ChangeThresholds) *Dispatcher {
+	d.lastSent = store
+	d.changes = thresholds
+	return d
+}
+
+// WithAlerts evaluates alert subscriptions in SendAlerts, recording in store which ones
+// were alerted.
+func (d *Dispatcher) WithAlerts(store AlertTripper) *Dispatcher {
+	d.alerts = store
+	return d
+}
+
+// WithWarnings emails warning subscriptions the warnings of feed in SendWarnings,
+// remembering in store which ones were sent.
+func (d *Dispatcher) WithWarnings(feed weather.WarningsFetcher, store WarningStore) *Dispatcher {
+	d.warningFeed = feed
+	d.warningStore = store
+	return d
+}
+
+// WithSendRecorder records in log the subscriptions tried and sent each update.
+func (d *Dispatcher) WithSendRecorder(log SendRecorder) *Dispatcher {
+	d.sendLog = log
+	return d
+}
+
+// WithSendClaims claims the subscriptions of every batch of SendUpdates in claimer, for
+// lease, before sending: those another run claimed or sent meanwhile are skipped. The
+// claims are released when the sends are recorded (see WithSendRecorder), those of the
+// subscriptions left out unsent included, so the lease must outlast a batch and only
+// matters when a run dies before recording its sends.
+func (d *Dispatcher) WithSendClaims(claimer SendClaimer, lease time.Duration) *Dispatcher {
+	d.claims = claimer
+	d.claimLease = lease
+	return d
+}
+
+// WithDeadLetter counts the consecutive failed sends of every subscription in tracker,
+// and dead-letters it after that many: it gets nothing more until revived. Only sends
+// refused or lost by the channel count; weather fetch failures do not.
+func (d *Dispatcher) WithDeadLetter(tracker SendTracker, after int) *Dispatcher {
+	d.sendTracker = tracker
+	d.deadAfter = after
+	return d
+}
+
+// SendUpdates fetches the weather of the cities of subs, each once and concurrently
+// (see weather.PrefetchCities), leaving out the subscriptions it would not send to
+// anyway (suppressed addresses and those the recipient guard holds), and delivers the
+// updates through
+// the notifier of its channel (see WithNotifier), one batch per channel: emails go in
+// one SMTP session, each with an unsubscribe link.
+// The outcome of every subscription is recorded in the delivery log against slot, and
+// the recorded entries are returned; skipped subscriptions, claimed by another run
+// included (see WithSendClaims), have none. Those it claimed but left out (suppressed,
+// held, weather unchanged) are recorded as attempted but not sent, releasing their
+// claims.
+func (d *Dispatcher) SendUpdates(ctx context.Context, subs []repository.Subscription, slot time.Time,
+) []repository.Delivery {
+	subs, err := d.claim(ctx, subs)
+	if err != nil {
+		// nothing is sent rather than risking a duplicate: recorded as failed, to be retried
+		d.logger.Error("failed to claim subscriptions before sending, skipping batch", zap.Error(err))
+		records := make([]repository.Delivery, len(subs))
+		for i, sub := range subs {
+			records[i] = newDelivery(sub, slot, err)
+		}
+		return d.send(ctx, nil, records, false)
+	}
+	claimed := subs
+	if subs = d.sendable(ctx, subs); len(subs) == 0 {
+		d.recordSends(ctx, nil, idsOf(claimed))
+		return nil
+	}
+	skipped := idsLeftOut(claimed, subs)
+
+	batches := make(map[repository.Channel]*channelBatch)
+	batchOf := func(ch repository.Channel) *channelBatch {
+		if batches[ch] == nil {
+			batches[ch] = &channelBatch{}
+		}
+		return batches[ch]
+	}
+	charts := make(map[string][]byte)
+	forecasts := make(map[string]*types.Forecast)
+	lastSent := d.lastSentOf(ctx, subs)
+	prefetched := weather.PrefetchCities(ctx, d.fetcher, d.prefetch, citiesOf(subs))
+	sentCities := make(map[int][]email.CityWeather) // of the notify-on-change subscriptions
+	for _, sub := range subs {
+		rec := newDelivery(sub, slot, nil)
+		channel := notify.ChannelOf(sub)
+		n := d.notifiers[channel]
+		if n == nil {
+			// recorded with the emails: there is no batch to deliver on this channel
+			markFailed(&rec, fmt.Errorf("no notifier for channel %q", channel))
+			batchOf(repository.ChannelEmail).add(nil, rec)
+			continue
+		}
+		batch := batchOf(channel)
+		cities, err := d.fetchCities(ctx, sub, prefetched)
+		if err != nil {
+			markFailed(&rec, err)
+			batch.add(nil, rec)
+			continue
+		}
+		if lastSent != nil && sub.NotifyOnChange {
+			if !d.changes.changed(lastSent[sub.ID], cities, slot) {
+				unchangedSkipped.Inc()
+				d.logger.Debug("weather unchanged, skipping update", zap.Int("subscription_id", sub.ID))
+				skipped = append(skipped, sub.ID)
+				continue
+			}
+			sentCities[sub.ID] = cities
+		}
+
+		if sub.Frequency == repository.FrequencyDaily {
+			d.addForecasts(ctx, cities, forecasts)
+		}
+		u := notify.Update{
+			Subscription: sub,
+			Subscriber:   subscriberOf(sub, slot),
+			Cities:       cities,
+			ManageURL:    manageURL(d.baseURL, sub),
+			Timezone:     timezoneOf(sub),
+			Attributions: d.attributionsFor(cities),
+		}
+		if channel == repository.ChannelEmail {
+			u.Features = d.features.For(sub.BetaFeatures)
+			if d.snapshots != nil && sub.Frequency == repository.FrequencyDaily && u.Features.Has(features.TemperatureCharts) {
+				u.Inline = d.chartsFor(ctx, cities, slot, charts)
+			}
+			u.Summary = d.summaryFor(ctx, sub, cities, u.Features)
+			u.UnsubscribeURL = unsubscribeURL(d.baseURL, d.links, sub)
+			u.OpenPixelURL = d.openURL(sub, rec)
+		}
+		msg, err := n.Render(u)
+		if err != nil {
+			d.logger.Error("failed to render weather update", zap.String("channel", string(channel)), zap.Error(err))
+			markFailed(&rec, err)
+			batch.add(nil, rec)
+			continue
+		}
+		batch.add(msg, rec)
+	}
+
+	var records []repository.Delivery
+	for _, channel := range []repository.Channel{repository.ChannelEmail, repository.ChannelSlack, repository.ChannelSMS} {
+		if b := batches[channel]; b != nil {
+			records = append(records, d.deliver(ctx, d.notifiers[channel], b.messages, b.records, true)...)
+		}
+	}
+	d.saveLastSent(ctx, records, sentCities, slot)
+	d.recordSends(ctx, records, skipped)
+	return records
+}
+
+// sendable returns subs without the email subscriptions of suppressed addresses and of
+// those the recipient guard holds, whose weather is then not fetched. They are checked
+// again right before sending (see deliver); when they cannot be checked here, every
+// subscription is kept.
+func (d *Dispatcher) sendable(ctx context.Context, subs []repository.Subscription) []repository.Subscription {
+	var emails []string
+	for _, sub := range subs {
+		if notify.ChannelOf(sub) == repository.ChannelEmail {
+			emails = append(emails, sub.Email)
+		}
+	}
+	if len(emails) == 0 {
+		return subs
+	}
+	suppressed, err := d.suppressions.FilterSuppressed(ctx, emails)
+	if err != nil {
+		d.logger.Warn("failed to check suppressions before fetching weather", zap.Error(err))
+		suppressed = nil
+	}
+	var held map[string]bool
+	if d.guard != nil {
+		held = d.guard.Held(ctx, emails)
+	}
+	if len(suppressed) == 0 && len(held) == 0 {
+		return subs
+	}
+	kept := make([]repository.Subscription, 0, len(subs))
+	for _, sub := range subs {
+		addr := strings.ToLower(sub.Email)
+		if notify.ChannelOf(sub) == repository.ChannelEmail && (suppressed[addr] || held[addr]) {
+			d.logger.Info("email suppressed or emailed within the minimum interval, not sending",
+				zap.Int("subscription_id", sub.ID))
+			continue
+		}
+		kept = append(kept, sub)
+	}
+	return kept
+}
+
+// claim returns the subscriptions of subs claimed for this batch, all of them without
+// send claims; on error subs is returned with it.
+func (d *Dispatcher) claim(ctx context.Context, subs []repository.Subscription) ([]repository.Subscription, error) {
+	if d.claims == nil || len(subs) == 0 {
+		return subs, nil
+	}
+	claimed, err := d.claims.ClaimSends(ctx, idsOf(subs), d.claimLease)
+	if err != nil {
+		return subs, err
+	}
+	ours := make(map[int]bool, len(claimed))
+	for _, id := range claimed {
+		ours[id] = true
+	}
+	kept := make([]repository.Subscription, 0, len(claimed))
+	for _, sub := range subs {
+		if ours[sub.ID] {
+			kept = append(kept, sub)
+			continue
+		}
+		claimSkipped.Inc()
+		d.logger.Debug("subscription claimed or sent by another run, skipping update", zap.Int("subscription_id", sub.ID))
+	}
+	return kept, nil
+}
+
+// idsOf returns the ids of subs.
+func idsOf(subs []repository.Subscription) []int {
+	ids := make([]int, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.ID
+	}
+	return ids
+}
+
+// idsLeftOut returns the ids of the subscriptions of all missing from kept, a
+// subsequence of it.
+func idsLeftOut(all, kept []repository.Subscription) []int {
+	var ids []int
+	for _, sub := range all {
+		if len(kept) > 0 && kept[0].ID == sub.ID {
+			kept = kept[1:]
+			continue
+		}
+		ids = append(ids, sub.ID)
+	}
+	return ids
+}
+
+// recordSends records the updates tried and sent in records, and the subscriptions of
+// skipped as tried but not sent, which releases the send claims of all of them.
+func (d *Dispatcher) recordSends(ctx context.Context, records []repository.Delivery, skipped []int) {
+	if d.sendLog == nil || len(records)+len(skipped) == 0 {
+		return
+	}
+	attempted := slices.Clone(skipped)
+	var sent []int
+	for _, r := range records {
+		id := int(r.SubscriptionID.Int64)
+		attempted = append(attempted, id)
+		if r.Succeeded() {
+			sent = append(sent, id)
+		}
+	}
+	if err := d.sendLog.RecordSends(ctx, attempted, sent); err != nil {
+		d.logger.Warn("failed to record the last updates sent", zap.Error(err))
+	}
+}
+
+// channelBatch holds the messages of one channel and the delivery log entries of its
+// subscriptions; messages correspond, in order, to the entries marked sent.
+type channelBatch struct {
+	messages []notify.Message
+	records  []repository.Delivery
+}
+
+// add appends rec, with its message when not nil.
+func (b *channelBatch) add(msg notify.Message, rec repository.Delivery) {
+	if msg != nil {
+		b.messages = append(b.messages, msg)
+	}
+	b.records = append(b.records, rec)
+}
+
+// lastSentOf loads the last sent weather of the notify-on-change subscriptions among
+// subs; nil when there are none, or it cannot be read and every update is sent.
+func (d *Dispatcher) lastSentOf(ctx context.Context, subs []repository.Subscription,
+) map[int]map[string]repository.SentWeather {
+	if d.lastSent == nil {
+		return nil
+	}
+	var ids []int
+	for _, sub := range subs {
+		if sub.NotifyOnChange {
+			ids = append(ids, sub.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	last, err := d.lastSent.ForSubscriptions(ctx, ids)
+	if err != nil {
+		d.logger.Warn("failed to read last sent weather, sending every update", zap.Error(err))
+		return nil
+	}
+	return last
+}
+
+// saveLastSent remembers the weather of the updates sent to notify-on-change
+// subscriptions, per cities by subscription.
+func (d *Dispatcher) saveLastSent(ctx context.Context, records []repository.Delivery,
+	cities map[int][]email.CityWeather, at time.Time,
+) {
+	var sent []repository.SentWeather
+	for _, r := range records {
+		id := int(r.SubscriptionID.Int64)
+		if r.Succeeded() && cities[id] != nil {
+			sent = append(sent, sentWeather(id, cities[id], at)...)
+		}
+	}
+	if len(sent) == 0 {
+		return
+	}
+	if err := d.lastSent.Save(ctx, sent); err != nil {
+		d.logger.Warn("failed to save last sent weather", zap.Error(err))
+	}
+}
+
+// SendWelcome sends the welcome email of a freshly confirmed subscription: the current
+// weather plus what to expect from the schedule. SMS subscriptions get it as a text
+// opening with smsWelcome, when an SMS notifier is registered.
+// It is recorded in the delivery log against the confirmation time.
+func (d *Dispatcher) SendWelcome(ctx context.Context, sub repository.Subscription) {
+	rec := newDelivery(sub, sub.ConfirmedAt.Time, nil)
+
+	cities, err := d.fetchCities(ctx, sub, nil)
+	if err != nil {
+		markFailed(&rec, err)
+		d.send(ctx, nil, []repository.Delivery{rec}, false)
+		return
+	}
+	if n := d.notifiers[repository.ChannelSMS]; n != nil && notify.ChannelOf(sub) == repository.ChannelSMS {
+		msg, err := n.Render(notify.Update{Subscription: sub, Cities: cities, ManageURL: manageURL(d.baseURL, sub),
+			Intro: smsWelcome(sub)})
+		if err != nil {
+			markFailed(&rec, err)
+			d.deliver(ctx, n, nil, []repository.Delivery{rec}, false)
+			return
+		}
+		d.deliver(ctx, n, []notify.Message{msg}, []repository.Delivery{rec}, false)
+		return
+	}
+
+	unsubURL := unsubscribeURL(d.baseURL, d.links, sub)
+	body, err := email.Render(email.TemplateWelcome, email.WelcomeData{
+		Subscriber:     subscriberOf(sub, sub.ConfirmedAt.Time),
+		Cities:         cities,
+		Timezone:       sub.Timezone,
+		Frequency:      string(sub.Frequency),
+		Schedule:       describeSchedule(sub),
+		Units:          string(sub.Units),
+		ManageURL:      manageURL(d.baseURL, sub),
+		UnsubscribeURL: unsubURL,
+		OpenPixelURL:   d.openURL(sub, rec),
+		Attributions:   d.attributionsFor(cities),
+		Features:       d.features.For(sub.BetaFeatures),
+	})
+	if err != nil {
+		d.logger.Error("failed to render welcome email", zap.Error(err))
+		markFailed(&rec, err)
+		d.send(ctx, nil, []repository.Delivery{rec}, false)
+		return
+	}
+
+	msg := email.EmailMessage{
+		To:             []string{sub.Email},
+		Subject:        fmt.Sprintf("Welcome to weather updates for %s", email.DescribeCities(cities, i18n.Default)),
+		Body:           body,
+		UnsubscribeURL: unsubURL,
+	}
+	d.send(ctx, []email.EmailMessage{msg}, []repository.Delivery{rec}, false)
+}
+
+// smsWelcome opens the first text of a freshly confirmed SMS subscription.
+func smsWelcome(sub repository.Subscription) string {
+	return i18n.T(sub.Locale, "sms.welcome", fmt.Sprintf("%02d:%02d", sub.ScheduledHour, sub.ScheduledMinute),
+		timezoneOf(sub))
+}
+
+// fetchCities fetches the current weather of every city of sub, with the sunrise in
+// the subscription's timezone when the provider knows it; cities in prefetched (see
+// weather.PrefetchCities) are taken from there. Cities that fail are left out of the
+// email; an error is returned only when none could be fetched.
+func (d *Dispatcher) fetchCities(ctx context.Context, sub repository.Subscription,
+	prefetched map[string]weather.Prefetched,
+) ([]email.CityWeather, error) {
+	loc := loadLocation(timezoneOf(sub), d.logger)
+	var out []email.CityWeather
+	var lastErr error
+	for _, city := range sub.AllCities() {
+		p, ok := prefetched[strings.ToLower(city)]
+		if !ok {
+			p.Weather, p.Err = d.fetcher.FetchCurrent(ctx, city)
+		}
+		w, err := p.Weather, p.Err
+		if err != nil {
+			d.logger.Error("weather fetch failed",
+				zap.Int("subscription_id", sub.ID),
+				zap.String("city", city),
+				zap.Error(err))
+			fetchFailures.Inc()
+			lastErr = err
+			continue
+		}
+		if d.snapshots != nil {
+			d.snapshots.record(ctx, city, w, time.Now())
+		}
+		cw := email.CityWeather{City: city, Weather: w}
+		if !w.Sunrise.IsZero() {
+			cw.Sunrise = w.Sunrise.In(loc).Format("15:04")
+		}
+		out = append(out, cw)
+	}
+	if len(out) == 0 {
+		return nil, lastErr
+	}
+	return out, nil
+}
+
+// citiesOf returns the cities of every subscription in subs, repeats included.
+func citiesOf(subs []repository.Subscription) []string {
+	var out []string
+	for _, sub := range subs {
+		out = append(out, sub.AllCities()...)
+	}
+	return out
+}
+
+// addForecasts sets tomorrow's forecast of cities, fetched once per city and cached in
+// fetched, shared by the emails of a batch. A city whose forecast fails goes without.
+func (d *Dispatcher) addForecasts(ctx context.Context, cities []email.CityWeather, fetched map[string]*types.Forecast) {
+	if d.forecasts == nil {
+		return
+	}
+	for i := range cities {
+		key := strings.ToLower(cities[i].City)
+		f, ok := fetched[key]
+		if !ok {
+			forecast, err := d.forecasts.FetchTomorrow(ctx, cities[i].City)
+			if err != nil {
+				d.logger.Warn("forecast fetch failed", zap.String("city", cities[i].City), zap.Error(err))
+			} else {
+				f = &forecast
+			}
+			fetched[key] = f
+		}
+		cities[i].Tomorrow = f
+	}
+}
+
+// summaryFor returns the summary opening the email of sub, empty unless sub gets daily
+// emails with AI summaries enabled. A failing summarizer only loses the summary.
+func (d *Dispatcher) summaryFor(ctx context.Context, sub repository.Subscription, cities []email.CityWeather,
+	enabled features.Set,
+) string {
+	if d.summarizer == nil || sub.Frequency != repository.FrequencyDaily || !enabled.Has(features.AISummaries) {
+		return ""
+	}
+	s, err := d.summarizer.Summarize(ctx, cities, string(sub.Units))
+	if err != nil {
+		d.logger.Warn("failed to summarize weather", zap.Int("subscription_id", sub.ID), zap.Error(err))
+		return ""
+	}
+	return s
+}
+
+// attributionsFor returns the attribution lines of the providers behind cities.
+func (d *Dispatcher) attributionsFor(cities []email.CityWeather) []string {
+	providers := make([]string, 0, len(cities))
+	for _, c := range cities {
+		providers = append(providers, c.Weather.Provider)
+	}
+	return d.attributions.For(providers...)
+}
+
+// unsubscribeURL, manageURL and openURL start with the base URL of the tenant of sub,
+// baseURL for the default tenant (see tenant.BaseURL).
+func unsubscribeURL(baseURL string, links *linksign.Signer, sub repository.Subscription) string {
+	return links.URL(tenant.BaseURL(sub.TenantID, baseURL), linksign.PurposeUnsubscribe, sub.UnsubscribeToken.String())
+}
+
+func manageURL(baseURL string, sub repository.Subscription) string {
+	return fmt.Sprintf("%s/api/manage/%s", tenant.BaseURL(sub.TenantID, baseURL), sub.ManageToken.String())
+}
+
+// openURL is the tracking pixel of the email to sub recorded by rec.
+func (d *Dispatcher) openURL(sub repository.Subscription, rec repository.Delivery) string {
+	return fmt.Sprintf("%s/api/open/%s", tenant.BaseURL(sub.TenantID, d.baseURL), rec.OpenToken.UUID.String())
+}
+
+// describeSchedule explains when regular updates of sub are sent.
+func describeSchedule(sub repository.Subscription) string {
+	switch sub.Frequency {
+	case repository.FrequencyHourly:
+		return fmt.Sprintf("every hour at minute %02d", sub.ScheduledMinute)
+	case repository.FrequencyAlert:
+		return "only when one of your alert conditions is met"
+	case repository.FrequencyWarnings:
+		return "whenever a severe weather warning is issued for one of your cities"
+	}
+	if sub.Timezone == "" {
+		return fmt.Sprintf("every day at %02d:%02d", sub.ScheduledHour, sub.ScheduledMinute)
+	}
+	return fmt.Sprintf("every day at %02d:%02d (%s time)", sub.ScheduledHour, sub.ScheduledMinute, sub.Timezone)
+}
+
+// timezoneOf returns the timezone the times of sub are computed in, UTC for
+// subscriptions that predate timezones. Labels use sub.Timezone, and are left out
+// when it is unset.
+func timezoneOf(sub repository.Subscription) string {
+	if sub.Timezone == "" {
+		return "UTC"
+	}
+	return sub.Timezone
+}
+
+// subscriberOf returns the personalization tokens of sub for an email sent at.
+func subscriberOf(sub repository.Subscription, at time.Time) email.Subscriber {
+	s := email.Subscriber{FirstName: sub.FirstName}
+	if sub.ConfirmedAt.Valid && at.After(sub.ConfirmedAt.Time) {
+		s.Days = int(at.Sub(sub.ConfirmedAt.Time) / (24 * time.Hour))
+	}
+	return s
+}
+
+// send emails messages in one batch, see deliver.
+func (d *Dispatcher) send(ctx context.Context, messages []email.EmailMessage, records []repository.Delivery,
+	guarded bool,
+) []repository.Delivery {
+	batch := make([]notify.Message, len(messages))
+	for i, m := range messages {
+		batch[i] = m
+	}
+	return d.deliver(ctx, d.notifiers[repository.ChannelEmail], batch, records, guarded)
+}
+
+// deliver delivers messages through n and records the outcome of every entry in records.
+// messages correspond, in order, to the records still marked sent; entries already
+// marked failed (e.g. by a weather fetch error) are recorded as they are. Guarded email
+// batches also go through the recipient guard; messages of other channels carry no
+// open-tracking pixel. The recorded entries are returned.
+//
+// Messages of a queuing notifier (notify.Queuing) are recorded as queued before they
+// are handed over, for the queue worker to record them sent or failed.
+func (d *Dispatcher) deliver(ctx context.Context, n notify.Notifier, messages []notify.Message,
+	records []repository.Delivery, guarded bool,
+) []repository.Delivery {
+	channel := n.Channel()
+	isEmail := channel == repository.ChannelEmail
+	if !isEmail {
+		for i := range records {
+			records[i].OpenToken = uuid.NullUUID{}
+		}
+	}
+	messages, records = d.dropUndeliverable(ctx, messages, records, isEmail)
+	var claimed []string
+	if guarded && isEmail && d.guard != nil {
+		messages, records, claimed = d.dropGuarded(ctx, messages, records)
+	}
+
+	q, queues := n.(notify.Queuing)
+	queued := queues && q.Queues() && len(messages) > 0
+	if queued {
+		d.recordQueued(ctx, messages, records)
+	}
+	var unqueued map[uuid.UUID]string // queued entries whose message the queue refused
+	if len(messages) > 0 {
+		errs := n.Deliver(ctx, messages)
+		// Messages fail on their own: only those not sent are marked failed, and only
+		// their recipients are released from the guard
+		sentAt := sql.NullTime{Time: time.Now(), Valid: true}
+		var unsent []string
+		var sentIDs, failedIDs []int
+		m := 0
+		for i := range records {
+			if records[i].Status != repository.DeliveryStatusSent {
+				continue
+			}
+			id := int(records[i].SubscriptionID.Int64)
+			switch msgErr := errs[m]; {
+			case msgErr != nil:
+				if queued {
+					if unqueued == nil {
+						unqueued = make(map[uuid.UUID]string)
+					}
+					unqueued[records[i].OpenToken.UUID] = msgErr.Error()
+				}
+				markFailed(&records[i], msgErr)
+				failedIDs = append(failedIDs, id)
+				if claimed != nil {
+					unsent = append(unsent, claimed[m])
+				}
+			case queued:
+				// whether it is sent is up to the worker: not counted towards dead-lettering
+				records[i].Status = repository.DeliveryStatusQueued
+			default:
+				records[i].SentAt = sentAt
+				sentIDs = append(sentIDs, id)
+			}
+			m++
+		}
+		failed := len(failedIDs)
+		if failed > 0 {
+			d.logger.Error("failed to deliver weather updates", zap.String("channel", string(channel)),
+				zap.Int("count", len(messages)), zap.Int("failed", failed))
+		} else {
+			d.logger.Info("delivered weather updates", zap.String("channel", string(channel)),
+				zap.Int("count", len(messages)))
+		}
+		if d.guard != nil && len(unsent) > 0 {
+			d.guard.Release(ctx, unsent)
+		}
+		d.trackSends(ctx, sentIDs, failedIDs)
+	}
+
+	for _, r := range records {
+		switch r.Status {
+		case repository.DeliveryStatusSent:
+			deliveriesSent.Inc()
+		case repository.DeliveryStatusFailed:
+			deliveriesFailed.Inc()
+		}
+	}
+	if queued {
+		if err := d.deliveries.Settle(ctx, nil, time.Time{}, unqueued); err != nil {
+			d.logger.Error("failed to record deliveries the queue refused", zap.Error(err))
+		}
+		return records
+	}
+	if err := d.deliveries.Record(ctx, records); err != nil {
+		d.logger.Error("failed to record deliveries", zap.Error(err))
+	}
+	return records
+}
+
+// recordQueued records records as they are before their messages are queued, those
+// still marked sent as queued, and tags the email messages with the open token of
+// their entry, by which the queue worker records their outcome.
+func (d *Dispatcher) recordQueued(ctx context.Context, messages []notify.Message, records []repository.Delivery) {
+	queued := make([]repository.Delivery, len(records))
+	m := 0
+	for i, r := range records {
+		if r.Status == repository.DeliveryStatusSent {
+			r.Status = repository.DeliveryStatusQueued
+			if msg, ok := messages[m].(email.EmailMessage); ok {
+				msg.DeliveryToken = r.OpenToken.UUID.String()
+				messages[m] = msg
+			}
+			m++
+		}
+		queued[i] = r
+	}
+	if err := d.deliveries.Record(ctx, queued); err != nil {
+		d.logger.Error("failed to record queued deliveries", zap.Error(err))
+	}
+}
+
+// trackSends counts the outcome of sends towards dead-lettering.
+func (d *Dispatcher) trackSends(ctx context.Context, sent, failed []int) {
+	if d.sendTracker == nil || d.deadAfter <= 0 {
+		return
+	}
+	dead, err := d.sendTracker.TrackSends(ctx, sent, failed, d.deadAfter)
+	if err != nil {
+		d.logger.Warn("failed to track send failures", zap.Error(err))
+		return
+	}
+	for _, id := range dead {
+		deadLettered.Inc()
+		d.logger.Warn("subscription dead-lettered after failed sends in a row", zap.Int("subscription_id", id),
+			zap.Int("failures", d.deadAfter))
+	}
+}
+
+// dropUndeliverable removes messages (and their records) of subscriptions that stopped
+// being active since batch selection, or, for emails, whose address is suppressed. If a
+// check itself fails nothing is sent: the pending entries are recorded as failed rather
+// than risking an update after an unsubscribe.
+func (d *Dispatcher) dropUndeliverable(ctx context.Context, messages []notify.Message, records []repository.Delivery,
+	isEmail bool,
+) ([]notify.Message, []repository.Delivery) {
+	var ids []int
+	var emails []string
+	for _, r := range records {
+		if r.Status == repository.DeliveryStatusSent {
+			ids = append(ids, int(r.SubscriptionID.Int64))
+			emails = append(emails, r.Email)
+		}
+	}
+	if len(ids) == 0 {
+		return messages, records
+	}
+
+	active, err := d.checker.ActiveIDs(ctx, ids)
+	var suppressed map[string]bool
+	if err == nil && isEmail {
+		suppressed, err = d.suppressions.FilterSuppressed(ctx, emails)
+	}
+	if err != nil {
+		d.logger.Error("failed to verify subscriptions before sending, skipping batch", zap.Error(err))
+		for i := range records {
+			if records[i].Status == repository.DeliveryStatusSent {
+				markFailed(&records[i], err)
+			}
+		}
+		return nil, records
+	}
+
+	keptMessages := messages[:0]
+	keptRecords := records[:0]
+	m := 0
+	for _, r := range records {
+		if r.Status != repository.DeliveryStatusSent {
+			keptRecords = append(keptRecords, r)
+			continue
+		}
+		msg := messages[m]
+		m++
+		if !active[int(r.SubscriptionID.Int64)] {
+			d.logger.Info("subscription no longer active, not sending",
+				zap.Int64("subscription_id", r.SubscriptionID.Int64))
+			continue
+		}
+		if suppressed[strings.ToLower(r.Email)] {
+			d.logger.Info("email suppressed, not sending",
+				zap.Int64("subscription_id", r.SubscriptionID.Int64))
+			continue
+		}
+		keptMessages = append(keptMessages, msg)
+		keptRecords = append(keptRecords, r)
+	}
+	return keptMessages, keptRecords
+}
+
+// dropGuarded removes messages (and their records) to addresses the recipient guard
+// refuses, returning the claimed addresses.
+func (d *Dispatcher) dropGuarded(ctx context.Context, messages []notify.Message, records []repository.Delivery,
+) ([]notify.Message, []repository.Delivery, []string) {
+	var emails []string
+	for _, r := range records {
+		if r.Status == repository.DeliveryStatusSent {
+			emails = append(emails, r.Email)
+		}
+	}
+	if len(emails) == 0 {
+		return messages, records, nil
+	}
+	allowed := d.guard.Claim(ctx, emails)
+
+	keptMessages := messages[:0]
+	keptRecords := records[:0]
+	var claimed []string
+	m := 0
+	for _, r := range records {
+		if r.Status != repository.DeliveryStatusSent {
+			keptRecords = append(keptRecords, r)
+			continue
+		}
+		msg := messages[m]
+		m++
+		if !allowed[strings.ToLower(r.Email)] {
+			d.logger.Warn("address emailed within the minimum interval, not sending",
+				zap.Int64("subscription_id", r.SubscriptionID.Int64))
+			continue
+		}
+		claimed = append(claimed, r.Email)
+		keptMessages = append(keptMessages, msg)
+		keptRecords = append(keptRecords, r)
+	}
+	return keptMessages, keptRecords, claimed
+}
+
+// newDelivery builds the delivery log entry of sub for slot; err marks it failed.
+// SentAt of successful entries is filled in once the batch has been sent, and their
+// open-tracking token is dropped again if sending fails.
+func newDelivery(sub repository.Subscription, slot time.Time, err error) repository.Delivery {
+	d := repository.Delivery{
+		SubscriptionID: sql.NullInt64{Int64: int64(sub.ID), Valid: true},
+		Email:          sub.Email,
+		City:           sub.City,
+		ScheduledFor:   slot,
+		Status:         repository.DeliveryStatusSent,
+		OpenToken:      uuid.NullUUID{UUID: uuid.New(), Valid: true},
+	}
+	if err != nil {
+		markFailed(&d, err)
+	}
+	return d
+}
+
+func markFailed(d *repository.Delivery, err error) {
+	d.Status = repository.DeliveryStatusFailed
+	d.Error = sql.NullString{String: err.Error(), Valid: true}
+	d.OpenToken = uuid.NullUUID{}
+}
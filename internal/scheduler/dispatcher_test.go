@@ -0,0 +1,1015 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/notify"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slack"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+// fakeStore is an in-memory view of which subscriptions are active.
+type fakeStore struct {
+	mu         sync.Mutex
+	active     map[int]bool
+	suppressed map[string]bool
+	err        error
+}
+
+func (s *fakeStore) ActiveIDs(_ context.Context, ids []int) (map[int]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	out := map[int]bool{}
+	for _, id := range ids {
+		if s.active[id] {
+			out[id] = true
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) FilterSuppressed(_ context.Context, emails []string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string]bool{}
+	for _, e := range emails {
+		if s.suppressed[e] {
+			out[e] = true
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) unsubscribe(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, id)
+}
+
+// slowFetcher injects latency into weather fetches and runs a hook meanwhile.
+type slowFetcher struct {
+	delay  time.Duration
+	during func(city string)
+}
+
+func (f *slowFetcher) FetchCurrent(_ context.Context, city string) (types.Weather, error) {
+	if f.during != nil {
+		f.during(city)
+	}
+	time.Sleep(f.delay)
+	if city == "Atlantis" {
+		return types.Weather{}, errors.New("city not found")
+	}
+	provider := "weatherapi.com"
+	if city == "Odesa" {
+		provider = "openweathermap.org"
+	}
+	return types.Weather{Temp: 20, Humidity: 50, Description: "Sunny", Provider: provider}, nil
+}
+
+type recordingSender struct {
+	sent   []email.EmailMessage
+	reject map[string]bool // recipients the server refuses
+}
+
+func (s *recordingSender) SendBatch(messages []email.EmailMessage) error {
+	failed := map[int]error{}
+	for i, m := range messages {
+		if s.reject[m.To[0]] {
+			failed[i] = errors.New("550 no such user")
+			continue
+		}
+		s.sent = append(s.sent, m)
+	}
+	if len(failed) > 0 {
+		return &email.BatchError{Total: len(messages), Failed: failed}
+	}
+	return nil
+}
+
+type recordingDeliveries struct {
+	recorded []repository.Delivery
+	failed   map[uuid.UUID]string // settled as failed
+}
+
+func (r *recordingDeliveries) Record(_ context.Context, deliveries []repository.Delivery) error {
+	r.recorded = append(r.recorded, deliveries...)
+	return nil
+}
+
+func (r *recordingDeliveries) Settle(_ context.Context, _ []uuid.UUID, _ time.Time, failed map[uuid.UUID]string) error {
+	for token, msg := range failed {
+		if r.failed == nil {
+			r.failed = make(map[uuid.UUID]string)
+		}
+		r.failed[token] = msg
+	}
+	return nil
+}
+
+func (r *recordingDeliveries) OnTimeStats(context.Context, time.Time, time.Duration) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (r *recordingDeliveries) ReplayTargets(context.Context, time.Time, time.Time) ([]repository.ReplayTarget, error) {
+	return nil, nil
+}
+
+func testSubs() []repository.Subscription {
+	return []repository.Subscription{
+		{ID: 1, Email: "stays@example.com", City: "Kyiv", Frequency: "hourly", Confirmed: true, UnsubscribeToken: uuid.New()},
+		{ID: 2, Email: "leaves@example.com", City: "Lviv", Frequency: "hourly", Confirmed: true, UnsubscribeToken: uuid.New()},
+	}
+}
+
+func TestDispatcher_SendUpdates_SkipsUnsubscribedDuringFetch(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	// The second subscriber unsubscribes while weather is being fetched
+	fetcher := &slowFetcher{delay: 20 * time.Millisecond, during: func(city string) {
+		if city == "Lviv" {
+			store.unsubscribe(2)
+		}
+	}}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	d := NewDispatcher(store, store, fetcher, sender, deliveries, "https://example.com", zap.NewNop())
+
+	d.SendUpdates(context.Background(), testSubs(), time.Now().Truncate(time.Minute))
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "stays@example.com" {
+		t.Fatalf("sent %+v, want only the still-active subscriber", sender.sent)
+	}
+	if len(deliveries.recorded) != 1 || deliveries.recorded[0].SubscriptionID.Int64 != 1 {
+		t.Errorf("recorded %+v, want only subscription 1", deliveries.recorded)
+	}
+}
+
+func TestDispatcher_SendUpdates_UnconfirmedAtSendTimeIsNotEmailed(t *testing.T) {
+	// Selected from a stale snapshot, but the row is not (or no longer) confirmed in the database
+	store := &fakeStore{active: map[int]bool{1: true}}
+	sender := &recordingSender{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop())
+
+	d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "stays@example.com" {
+		t.Fatalf("sent %+v, want only the confirmed subscriber", sender.sent)
+	}
+}
+
+func TestDispatcher_SendUpdates_VerificationErrorSendsNothing(t *testing.T) {
+	store := &fakeStore{err: errors.New("connection reset")}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop())
+
+	d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	if len(sender.sent) != 0 {
+		t.Fatalf("sent %d emails, want none when verification fails", len(sender.sent))
+	}
+	if len(deliveries.recorded) != 2 {
+		t.Fatalf("recorded %d deliveries, want 2", len(deliveries.recorded))
+	}
+	for _, r := range deliveries.recorded {
+		if r.Status != repository.DeliveryStatusFailed {
+			t.Errorf("delivery %+v status = %q, want failed", r, r.Status)
+		}
+	}
+}
+
+func TestDispatcher_SendUpdates_SkipsSuppressedAddresses(t *testing.T) {
+	store := &fakeStore{
+		active:     map[int]bool{1: true, 2: true},
+		suppressed: map[string]bool{"leaves@example.com": true},
+	}
+	sender := &recordingSender{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop())
+
+	d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "stays@example.com" {
+		t.Fatalf("sent %+v, want only the non-suppressed subscriber", sender.sent)
+	}
+}
+
+func TestDispatcher_SendUpdates_RejectedRecipientFailsAlone(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{reject: map[string]bool{"stays@example.com": true}}
+	deliveries := &recordingDeliveries{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop())
+
+	d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "leaves@example.com" {
+		t.Fatalf("sent %+v, want the batch to go on past the rejected recipient", sender.sent)
+	}
+	status := map[string]string{}
+	for _, r := range deliveries.recorded {
+		status[r.Email] = r.Status
+	}
+	if status["stays@example.com"] != repository.DeliveryStatusFailed || status["leaves@example.com"] != repository.DeliveryStatusSent {
+		t.Errorf("recorded statuses %v, want only the rejected recipient failed", status)
+	}
+}
+
+// queuingSender is a recordingSender standing for the mail queue: refused recipients
+// are messages it failed to queue.
+type queuingSender struct {
+	recordingSender
+}
+
+func (*queuingSender) Queues() bool { return true }
+
+func TestDispatcher_SendUpdates_QueuedRecordedBeforeHandOver(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &queuingSender{recordingSender{reject: map[string]bool{"stays@example.com": true}}}
+	deliveries := &recordingDeliveries{}
+	tracker := &recordingTracker{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop()).
+		WithDeadLetter(tracker, 5)
+
+	records := d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	// both are logged as queued before the queue sees them, the one refused is then settled failed
+	token := map[string]uuid.UUID{}
+	for _, r := range deliveries.recorded {
+		if r.Status != repository.DeliveryStatusQueued {
+			t.Errorf("recorded %s as %q, want queued", r.Email, r.Status)
+		}
+		token[r.Email] = r.OpenToken.UUID
+	}
+	if len(deliveries.recorded) != 2 {
+		t.Fatalf("recorded %+v, want both deliveries", deliveries.recorded)
+	}
+	if msg, ok := deliveries.failed[token["stays@example.com"]]; !ok || len(deliveries.failed) != 1 {
+		t.Errorf("settled failed %v, want only stays@example.com", deliveries.failed)
+	} else if msg != "550 no such user" {
+		t.Errorf("settled error %q", msg)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].DeliveryToken != token["leaves@example.com"].String() {
+		t.Errorf("queued %+v, want the message tagged with its delivery's open token", sender.sent)
+	}
+
+	status := map[string]string{}
+	for _, r := range records {
+		status[r.Email] = r.Status
+	}
+	if status["stays@example.com"] != repository.DeliveryStatusFailed || status["leaves@example.com"] != repository.DeliveryStatusQueued {
+		t.Errorf("returned statuses %v", status)
+	}
+	// the worker knows whether the queued one is sent
+	if len(tracker.sent) != 0 || len(tracker.failed) != 1 {
+		t.Errorf("tracked sent %v, failed %v; want failed [1] only", tracker.sent, tracker.failed)
+	}
+}
+
+// recordingTracker is a SendTracker remembering the outcomes it was told about.
+type recordingTracker struct {
+	sent, failed []int
+	after        int
+}
+
+func (t *recordingTracker) TrackSends(_ context.Context, sent, failed []int, after int) ([]int, error) {
+	t.sent, t.failed, t.after = append(t.sent, sent...), append(t.failed, failed...), after
+	return failed, nil
+}
+
+func TestDispatcher_SendUpdates_TracksFailuresForDeadLetter(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{reject: map[string]bool{"stays@example.com": true}}
+	tracker := &recordingTracker{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithDeadLetter(tracker, 5)
+
+	d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	if len(tracker.sent) != 1 || tracker.sent[0] != 2 || len(tracker.failed) != 1 || tracker.failed[0] != 1 {
+		t.Errorf("tracked sent %v, failed %v; want sent [2], failed [1]", tracker.sent, tracker.failed)
+	}
+	if tracker.after != 5 {
+		t.Errorf("tracked with after = %d, want 5", tracker.after)
+	}
+}
+
+// recordingSends is a SendRecorder remembering what it was told.
+type recordingSends struct {
+	attempted, sent []int
+}
+
+func (r *recordingSends) RecordSends(_ context.Context, attempted, sent []int) error {
+	r.attempted, r.sent = append(r.attempted, attempted...), append(r.sent, sent...)
+	return nil
+}
+
+func TestDispatcher_SendUpdates_RecordsLastSent(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{reject: map[string]bool{"stays@example.com": true}}
+	sends := &recordingSends{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithSendRecorder(sends)
+
+	d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	if len(sends.attempted) != 2 || len(sends.sent) != 1 || sends.sent[0] != 2 {
+		t.Errorf("recorded attempted %v, sent %v; want both attempted, 2 sent", sends.attempted, sends.sent)
+	}
+}
+
+// memoryClaims is an in-memory SendClaimer: a subscription is claimed once, claims
+// are never released.
+type memoryClaims struct {
+	mu      sync.Mutex
+	claimed map[int]bool
+	err     error
+}
+
+func (c *memoryClaims) ClaimSends(_ context.Context, ids []int, _ time.Duration) ([]int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	var out []int
+	for _, id := range ids {
+		if !c.claimed[id] {
+			c.claimed[id] = true
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+func TestDispatcher_SendUpdates_OverlappingRunsSendOnce(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	claims := &memoryClaims{claimed: map[int]bool{}}
+	d := NewDispatcher(store, store, &slowFetcher{delay: 10 * time.Millisecond}, sender, deliveries,
+		"https://example.com", zap.NewNop()).
+		WithSendClaims(claims, time.Minute)
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.SendUpdates(context.Background(), testSubs(), time.Now())
+		}()
+	}
+	wg.Wait()
+
+	if len(deliveries.recorded) != 2 {
+		t.Errorf("recorded %d deliveries, want 2: one per subscription", len(deliveries.recorded))
+	}
+}
+
+func TestDispatcher_SendUpdates_ClaimFailureSendsNothing(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop()).
+		WithSendClaims(&memoryClaims{err: errors.New("connection refused")}, time.Minute)
+
+	records := d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	if len(sender.sent) != 0 {
+		t.Errorf("sent %d emails, want none", len(sender.sent))
+	}
+	if len(records) != 2 || records[0].Status != repository.DeliveryStatusFailed || records[1].Status != repository.DeliveryStatusFailed {
+		t.Errorf("records = %+v, want both failed", records)
+	}
+}
+
+// releasingClaims is a SendClaimer and SendRecorder: a subscription is claimed once,
+// until its send is recorded.
+type releasingClaims struct {
+	memoryClaims
+	recordingSends
+}
+
+func (c *releasingClaims) RecordSends(ctx context.Context, attempted, sent []int) error {
+	c.mu.Lock()
+	for _, id := range attempted {
+		delete(c.claimed, id)
+	}
+	c.mu.Unlock()
+	return c.recordingSends.RecordSends(ctx, attempted, sent)
+}
+
+func TestDispatcher_SendUpdates_ReleasesTheClaimsOfSkippedSubscriptions(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true, 3: true}, suppressed: map[string]bool{"bounced@example.com": true}}
+	claims := &releasingClaims{memoryClaims: memoryClaims{claimed: map[int]bool{}}}
+	slot := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	// Kyiv's weather was sent an hour ago and has not changed since
+	lastSent := &memoryLastSent{bySub: map[int]map[string]repository.SentWeather{1: {"kyiv": {SubscriptionID: 1,
+		City: "kyiv", Temp: 20, Humidity: 50, Description: "Sunny", SentAt: slot.Add(-time.Hour)}}}}
+	d := NewDispatcher(store, store, &slowFetcher{}, &recordingSender{}, &recordingDeliveries{}, "https://example.com",
+		zap.NewNop()).WithSendRecorder(claims).WithSendClaims(claims, time.Minute).
+		WithChangeFilter(lastSent, ChangeThresholds{Temp: 2, Humidity: 10, MaxSilence: 24 * time.Hour})
+
+	subs := append(testSubs(), repository.Subscription{ID: 3, Email: "bounced@example.com", City: "Odesa",
+		Frequency: "hourly", Confirmed: true})
+	subs[0].NotifyOnChange = true
+	d.SendUpdates(context.Background(), subs, slot)
+	if len(claims.claimed) != 0 {
+		t.Errorf("claims held after the run: %v, want every one released", claims.claimed)
+	}
+	for _, id := range []int{1, 3} {
+		if !slices.Contains(claims.attempted, id) || slices.Contains(claims.sent, id) {
+			t.Errorf("recorded attempted %v, sent %v; want %d attempted, not sent", claims.attempted, claims.sent, id)
+		}
+	}
+	if !slices.Equal(claims.sent, []int{2}) {
+		t.Errorf("recorded sent %v, want [2]", claims.sent)
+	}
+
+	// a batch left out whole is released too
+	d.SendUpdates(context.Background(), subs[2:], slot.Add(time.Hour))
+	if len(claims.claimed) != 0 {
+		t.Errorf("claims held after a run sending nothing: %v, want every one released", claims.claimed)
+	}
+}
+
+// memoryGuard is an in-memory RecipientGuard: an address is claimed once until released.
+type memoryGuard struct {
+	claimed map[string]bool
+}
+
+func (g *memoryGuard) Claim(_ context.Context, emails []string) map[string]bool {
+	allowed := make(map[string]bool, len(emails))
+	for _, e := range emails {
+		e = strings.ToLower(e)
+		if _, seen := allowed[e]; !seen {
+			allowed[e] = !g.claimed[e]
+			g.claimed[e] = true
+		}
+	}
+	return allowed
+}
+
+func (g *memoryGuard) Release(_ context.Context, emails []string) {
+	for _, e := range emails {
+		delete(g.claimed, strings.ToLower(e))
+	}
+}
+
+func (g *memoryGuard) Held(_ context.Context, emails []string) map[string]bool {
+	held := make(map[string]bool)
+	for _, e := range emails {
+		if e = strings.ToLower(e); g.claimed[e] {
+			held[e] = true
+		}
+	}
+	return held
+}
+
+func TestDispatcher_SendUpdates_FetchesOnlyWhatItSends(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true, 3: true}, suppressed: map[string]bool{"bounced@example.com": true}}
+	var fetched []string
+	fetcher := &slowFetcher{during: func(city string) { fetched = append(fetched, city) }}
+	guard := &memoryGuard{claimed: map[string]bool{"leaves@example.com": true}}
+	d := NewDispatcher(store, store, fetcher, &recordingSender{}, &recordingDeliveries{}, "https://example.com",
+		zap.NewNop()).WithRecipientGuard(guard).WithPrefetchLimiter(weather.NewPrefetchLimiter(1))
+
+	subs := append(testSubs(), repository.Subscription{ID: 3, Email: "bounced@example.com", City: "Odesa",
+		Frequency: "hourly", Confirmed: true})
+	d.SendUpdates(context.Background(), subs, time.Now())
+
+	if !slices.Equal(fetched, []string{"Kyiv"}) {
+		t.Errorf("fetched %q, want only Kyiv: Lviv's subscriber is held by the guard, Odesa's suppressed", fetched)
+	}
+}
+
+func TestDispatcher_SendUpdates_RecipientGuardDropsRepeatEmails(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	guard := &memoryGuard{claimed: map[string]bool{"leaves@example.com": true}}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop()).
+		WithRecipientGuard(guard)
+
+	d.SendUpdates(context.Background(), testSubs(), time.Now())
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "stays@example.com" {
+		t.Fatalf("sent %+v, want only the address not emailed recently", sender.sent)
+	}
+	if len(deliveries.recorded) != 1 || deliveries.recorded[0].SubscriptionID.Int64 != 1 {
+		t.Errorf("recorded %+v, want only subscription 1", deliveries.recorded)
+	}
+
+	// a second run within the window sends nothing
+	d.SendUpdates(context.Background(), testSubs(), time.Now())
+	if len(sender.sent) != 1 {
+		t.Errorf("sent %d emails after the second run, want still 1", len(sender.sent))
+	}
+
+	// welcome emails are not limited
+	d.SendWelcome(context.Background(), testSubs()[0])
+	if len(sender.sent) != 2 {
+		t.Errorf("sent %d emails after the welcome email, want 2", len(sender.sent))
+	}
+}
+
+func TestDispatcher_SendWelcome_LabelsTheTimezoneSet(t *testing.T) {
+	for _, tc := range []struct {
+		timezone, want, unwanted string
+	}{
+		{"Europe/Kyiv", "every day at 08:00 (Europe/Kyiv time)", ""},
+		{"", "every day at 08:00,", "UTC"}, // scheduled in UTC, but not labelled so
+	} {
+		store := &fakeStore{active: map[int]bool{1: true}}
+		sender := &recordingSender{}
+		d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop())
+
+		d.SendWelcome(context.Background(), repository.Subscription{ID: 1, Email: "anna@example.com", City: "Kyiv",
+			Frequency: repository.FrequencyDaily, ScheduledHour: 8, Timezone: tc.timezone, Confirmed: true,
+			UnsubscribeToken: uuid.New()})
+		if len(sender.sent) != 1 {
+			t.Fatalf("timezone %q: sent %d emails, want the welcome email", tc.timezone, len(sender.sent))
+		}
+		body := sender.sent[0].Body
+		if !strings.Contains(body, tc.want) || (tc.unwanted != "" && strings.Contains(body, tc.unwanted)) {
+			t.Errorf("timezone %q: welcome email does not say %q (or says %q):\n%s", tc.timezone, tc.want, tc.unwanted, body)
+		}
+	}
+}
+
+func TestDispatcher_SendUpdates_OneEmailForAllCities(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true}}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop())
+
+	sub := testSubs()[0]
+	sub.Cities = repository.CityList{"Kyiv", "Atlantis", "Odesa"}
+	d.SendUpdates(context.Background(), []repository.Subscription{sub}, time.Now())
+
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(sender.sent))
+	}
+	msg := sender.sent[0]
+	if msg.Subject != "Weather update for Kyiv and 1 more" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	// the city that failed to fetch is left out, the others are included
+	if !strings.Contains(msg.Body, "Odesa") || strings.Contains(msg.Body, "Atlantis") {
+		t.Errorf("body does not list exactly the fetched cities:\n%s", msg.Body)
+	}
+	if len(deliveries.recorded) != 1 || deliveries.recorded[0].Status != repository.DeliveryStatusSent {
+		t.Fatalf("recorded %+v, want one sent delivery", deliveries.recorded)
+	}
+	// the tracking pixel points at the recorded delivery
+	if pixel := "/api/open/" + deliveries.recorded[0].OpenToken.UUID.String(); !strings.Contains(msg.Body, pixel) {
+		t.Errorf("body does not contain the open-tracking pixel %s", pixel)
+	}
+	// the one-click unsubscribe header points at the subscription's unsubscribe link
+	if want := "https://example.com/api/unsubscribe/" + sub.UnsubscribeToken.String(); msg.UnsubscribeURL != want {
+		t.Errorf("UnsubscribeURL = %q, want %q", msg.UnsubscribeURL, want)
+	}
+}
+
+func TestDispatcher_SendUpdates_AttributesEachProviderOnce(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true}}
+	sender := &recordingSender{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithAttributions(weather.Attributions{
+			"weatherapi.com":     "Powered by WeatherAPI.com",
+			"openweathermap.org": "Weather data provided by OpenWeather",
+		})
+
+	sub := testSubs()[0]
+	sub.Cities = repository.CityList{"Kyiv", "Lviv", "Odesa"}
+	d.SendUpdates(context.Background(), []repository.Subscription{sub}, time.Now())
+
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(sender.sent))
+	}
+	body := sender.sent[0].Body
+	if n := strings.Count(body, "Powered by WeatherAPI.com"); n != 1 {
+		t.Errorf("WeatherAPI.com attribution appears %d times, want once", n)
+	}
+	if !strings.Contains(body, "Weather data provided by OpenWeather") {
+		t.Errorf("body lacks the OpenWeather attribution:\n%s", body)
+	}
+}
+
+// memorySnapshots is an in-memory SnapshotStore.
+type memorySnapshots struct {
+	byCity   map[string][]repository.Snapshot
+	recorded []repository.Snapshot
+}
+
+func (m *memorySnapshots) Record(_ context.Context, s repository.Snapshot) error {
+	m.recorded = append(m.recorded, s)
+	return nil
+}
+
+func (m *memorySnapshots) Since(_ context.Context, city string, _ time.Time) ([]repository.Snapshot, error) {
+	return m.byCity[strings.ToLower(city)], nil
+}
+
+func TestDispatcher_SendUpdates_AttachesTemperatureCharts(t *testing.T) {
+	slot := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	snapshots := &memorySnapshots{byCity: map[string][]repository.Snapshot{
+		"kyiv": {{City: "kyiv", ObservedAt: slot.Add(-3 * time.Hour), Temp: 8}, {City: "kyiv", ObservedAt: slot.Add(-time.Hour), Temp: 11}},
+		"lviv": {{City: "lviv", ObservedAt: slot.Add(-time.Hour), Temp: 9}}, // not enough for a chart
+	}}
+	flags, err := features.Parse([]string{"temperature_charts=on"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithFeatures(flags).
+		WithSnapshots(snapshots)
+
+	subs := testSubs()
+	subs[0].Frequency, subs[0].Cities = repository.FrequencyDaily, repository.CityList{"Lviv", "Kyiv"}
+	subs[1].City = "Kyiv" // hourly: no chart
+	d.SendUpdates(context.Background(), subs, slot)
+
+	if len(sender.sent) != 2 {
+		t.Fatalf("sent %d emails, want 2", len(sender.sent))
+	}
+	daily := sender.sent[0]
+	if len(daily.Inline) != 1 || daily.Inline[0].ContentID != "chart-1@weather" || daily.Inline[0].ContentType != "image/png" {
+		t.Fatalf("daily email inline parts = %+v, want the Kyiv chart only", daily.Inline)
+	}
+	if !strings.Contains(daily.Body, `src="cid:chart-1@weather"`) {
+		t.Errorf("daily email body does not show the chart:\n%s", daily.Body)
+	}
+	if len(sender.sent[1].Inline) != 0 {
+		t.Errorf("hourly email has inline parts %+v", sender.sent[1].Inline)
+	}
+	// Kyiv is fetched for both emails but recorded once for the hour
+	if len(snapshots.recorded) != 2 {
+		t.Errorf("recorded %+v, want one snapshot each of Lviv and Kyiv", snapshots.recorded)
+	}
+}
+
+// cityList is a CityLister of fixed cities.
+type cityList []string
+
+func (l cityList) SubscribedCities(context.Context) ([]string, error) { return l, nil }
+
+func TestRecordSnapshots_EveryCity(t *testing.T) {
+	snapshots := &memorySnapshots{}
+	now := time.Date(2026, 10, 16, 7, 30, 0, 0, time.UTC)
+	RecordSnapshots(context.Background(), cityList{"Kyiv", "Atlantis", "Lviv"}, &slowFetcher{}, snapshots, now,
+		zap.NewNop())
+
+	if len(snapshots.recorded) != 2 {
+		t.Fatalf("recorded %+v, want Kyiv and Lviv: Atlantis cannot be fetched", snapshots.recorded)
+	}
+	for _, s := range snapshots.recorded {
+		if !s.ObservedAt.Equal(now.Truncate(time.Hour)) {
+			t.Errorf("snapshot of %s observed at %s, want the hour %s", s.City, s.ObservedAt, now.Truncate(time.Hour))
+		}
+	}
+}
+
+// countingForecasts forecasts the same weather for every city, counting the calls.
+type countingForecasts struct {
+	calls map[string]int
+}
+
+func (f *countingForecasts) FetchTomorrow(_ context.Context, city string) (types.Forecast, error) {
+	f.calls[city]++
+	if city == "Lviv" {
+		return types.Forecast{}, errors.New("no forecast")
+	}
+	return types.Forecast{Date: "2026-10-17", MinTemp: 9, MaxTemp: 17, ChanceOfRain: 40, Description: "Showers"}, nil
+}
+
+func TestDispatcher_SendUpdates_AddsTomorrowToDailyEmails(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true, 3: true}}
+	sender := &recordingSender{}
+	forecasts := &countingForecasts{calls: map[string]int{}}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithForecasts(forecasts)
+
+	subs := append(testSubs(), repository.Subscription{ID: 3, Email: "third@example.com", City: "Kyiv",
+		Frequency: repository.FrequencyDaily, Confirmed: true, UnsubscribeToken: uuid.New()})
+	subs[0].Frequency, subs[0].Cities = repository.FrequencyDaily, repository.CityList{"Kyiv", "Lviv"}
+	d.SendUpdates(context.Background(), subs, time.Now())
+
+	if len(sender.sent) != 3 {
+		t.Fatalf("sent %d emails, want 3", len(sender.sent))
+	}
+	want := "Tomorrow: Showers, from 9.00°C to 17.00°C, 40% chance of precipitation"
+	if body := sender.sent[0].Body; strings.Count(body, want) != 1 {
+		t.Errorf("daily email should forecast Kyiv only (Lviv failed):\n%s", body)
+	}
+	if strings.Contains(sender.sent[1].Body, "Tomorrow:") {
+		t.Errorf("hourly email has a forecast:\n%s", sender.sent[1].Body)
+	}
+	if forecasts.calls["Kyiv"] != 1 || forecasts.calls["Lviv"] != 1 {
+		t.Errorf("forecast calls = %v, want one per city", forecasts.calls)
+	}
+}
+
+// memoryLastSent is an in-memory LastSentStore.
+type memoryLastSent struct {
+	bySub map[int]map[string]repository.SentWeather
+}
+
+func (m *memoryLastSent) ForSubscriptions(_ context.Context, ids []int) (map[int]map[string]repository.SentWeather, error) {
+	out := map[int]map[string]repository.SentWeather{}
+	for _, id := range ids {
+		if last, ok := m.bySub[id]; ok {
+			out[id] = last
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryLastSent) Save(_ context.Context, sent []repository.SentWeather) error {
+	for _, w := range sent {
+		if m.bySub[w.SubscriptionID] == nil {
+			m.bySub[w.SubscriptionID] = map[string]repository.SentWeather{}
+		}
+		m.bySub[w.SubscriptionID][strings.ToLower(w.City)] = w
+	}
+	return nil
+}
+
+func TestDispatcher_SendUpdates_NotifyOnChange(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	lastSent := &memoryLastSent{bySub: map[int]map[string]repository.SentWeather{}}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithChangeFilter(lastSent, ChangeThresholds{Temp: 2, Humidity: 10, MaxSilence: 24 * time.Hour})
+
+	subs := testSubs()
+	subs[0].NotifyOnChange = true
+	slot := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+
+	// the first update always goes out, and is remembered
+	d.SendUpdates(context.Background(), subs, slot)
+	if len(sender.sent) != 2 || lastSent.bySub[1]["kyiv"].Temp != 20 {
+		t.Fatalf("first round sent %d emails, remembered %+v", len(sender.sent), lastSent.bySub)
+	}
+	if _, ok := lastSent.bySub[2]; ok {
+		t.Errorf("remembered the weather of a subscription without notify-on-change")
+	}
+
+	// same weather an hour later: only the subscription without notify-on-change gets it
+	d.SendUpdates(context.Background(), subs, slot.Add(time.Hour))
+	if len(sender.sent) != 3 || sender.sent[2].To[0] != "leaves@example.com" {
+		t.Fatalf("second round sent %v, want only leaves@example.com", sender.sent[2:])
+	}
+
+	// a temperature change past the threshold, or a day of silence, sends it again
+	lastSent.bySub[1]["kyiv"] = repository.SentWeather{SubscriptionID: 1, City: "kyiv", Temp: 17.5, Humidity: 50,
+		Description: "Sunny", SentAt: slot}
+	d.SendUpdates(context.Background(), subs[:1], slot.Add(2*time.Hour))
+	lastSent.bySub[1]["kyiv"] = repository.SentWeather{SubscriptionID: 1, City: "kyiv", Temp: 20, Humidity: 50,
+		Description: "Sunny", SentAt: slot.Add(-24 * time.Hour)}
+	d.SendUpdates(context.Background(), subs[:1], slot)
+	if len(sender.sent) != 5 {
+		t.Errorf("sent %d emails in total, want 5", len(sender.sent))
+	}
+}
+
+// memoryAlerts is an in-memory AlertTripper.
+type memoryAlerts struct {
+	tripped map[int]bool
+}
+
+func (m *memoryAlerts) SetTripped(_ context.Context, ids []int, tripped bool) error {
+	for _, id := range ids {
+		m.tripped[id] = tripped
+	}
+	return nil
+}
+
+func TestDispatcher_SendAlerts_OnlyWhenConditionsStartToHold(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true, 3: true}}
+	sender := &recordingSender{}
+	alerts := &memoryAlerts{tripped: map[int]bool{}}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithForecasts(&countingForecasts{calls: map[string]int{}}).
+		WithAlerts(alerts)
+
+	alert := func(id int, city string, c repository.AlertConditions, tripped bool) repository.AlertSubscription {
+		return repository.AlertSubscription{Subscription: repository.Subscription{ID: id, Email: city + "@example.com",
+			City: city, Frequency: repository.FrequencyAlert, Confirmed: true, UnsubscribeToken: uuid.New()},
+			AlertConditions: c, Tripped: tripped}
+	}
+	above, below := 18.0, 10.0
+	d.SendAlerts(context.Background(), []repository.AlertSubscription{
+		alert(1, "Kyiv", repository.AlertConditions{TempAbove: &above}, false), // 20°C now: alerted
+		alert(2, "Lviv", repository.AlertConditions{TempBelow: &below}, true),  // cleared
+		alert(3, "Kyiv", repository.AlertConditions{TempBelow: &below}, true),  // 9°C tomorrow, alerted already
+	}, time.Now())
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "Kyiv@example.com" {
+		t.Fatalf("sent %+v, want one alert to subscription 1", sender.sent)
+	}
+	if want := "<b>Kyiv</b>: 20.00°C now, above your 18.00°C"; !strings.Contains(sender.sent[0].Body, want) {
+		t.Errorf("alert body misses %q:\n%s", want, sender.sent[0].Body)
+	}
+	if len(alerts.tripped) != 2 || !alerts.tripped[1] || alerts.tripped[2] {
+		t.Errorf("tripped = %v, want 1 tripped and 2 cleared", alerts.tripped)
+	}
+}
+
+// fixedWarnings is a warnings feed with the same warnings on every call, counting them.
+type fixedWarnings struct {
+	byCity map[string][]types.Warning
+	calls  int
+}
+
+func (f *fixedWarnings) FetchWarnings(_ context.Context, city string) ([]types.Warning, error) {
+	f.calls++
+	return f.byCity[city], nil
+}
+
+// memoryWarnings is an in-memory WarningStore.
+type memoryWarnings struct {
+	notified map[int]map[string]bool
+}
+
+func (m *memoryWarnings) Notified(_ context.Context, ids []int) (map[int]map[string]bool, error) {
+	return m.notified, nil
+}
+
+func (m *memoryWarnings) MarkNotified(_ context.Context, warnings []repository.NotifiedWarning) error {
+	for _, w := range warnings {
+		if m.notified[w.SubscriptionID] == nil {
+			m.notified[w.SubscriptionID] = map[string]bool{}
+		}
+		m.notified[w.SubscriptionID][w.WarningID] = true
+	}
+	return nil
+}
+
+func TestDispatcher_SendWarnings_SendsEachWarningOnce(t *testing.T) {
+	now := time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)
+	storm := types.Warning{ID: "storm", Headline: "Storm warning for the region", Expires: now.Add(6 * time.Hour)}
+	expired := types.Warning{ID: "fog", Headline: "Fog warning", Expires: now.Add(-time.Hour)}
+	feed := &fixedWarnings{byCity: map[string][]types.Warning{"Kyiv": {storm, expired}, "Lviv": {storm}}}
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	notified := &memoryWarnings{notified: map[int]map[string]bool{}}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithWarnings(feed, notified)
+
+	subs := testSubs()
+	subs[0].Frequency = repository.FrequencyWarnings
+	subs[1].Frequency, subs[1].Cities = repository.FrequencyWarnings, repository.CityList{"Lviv", "Kyiv"}
+	d.SendWarnings(context.Background(), subs, now)
+
+	if len(sender.sent) != 2 {
+		t.Fatalf("sent %d emails, want 2", len(sender.sent))
+	}
+	for _, m := range sender.sent {
+		if strings.Count(m.Body, "Storm warning") != 1 || strings.Contains(m.Body, "Fog warning") {
+			t.Errorf("email to %s should list the storm warning once, and not the expired one:\n%s", m.To[0], m.Body)
+		}
+	}
+	if feed.calls != 2 {
+		t.Errorf("feed read %d times, want once per city", feed.calls)
+	}
+
+	// still in effect at the next check, but already sent
+	d.SendWarnings(context.Background(), subs, now.Add(15*time.Minute))
+	if len(sender.sent) != 2 {
+		t.Errorf("sent %d emails in total, want the warning sent once", len(sender.sent))
+	}
+}
+
+type recordingSlack struct {
+	posted map[string]slack.Message
+	err    error
+}
+
+func (s *recordingSlack) Post(_ context.Context, webhookURL string, msg slack.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.posted[webhookURL] = msg
+	return nil
+}
+
+func TestDispatcher_SendUpdates_PostsToSlack(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true, 3: true}}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	poster := &recordingSlack{posted: map[string]slack.Message{}}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop()).
+		WithNotifier(notify.NewSlack(poster))
+
+	subs := append(testSubs(), repository.Subscription{ID: 3, Email: "gone@example.com", City: "Odesa",
+		Frequency: "hourly", Confirmed: true, Channel: repository.ChannelSlack,
+		SlackWebhookURL: "https://hooks.slack.com/services/T1/B3/z"})
+	subs[1].Channel, subs[1].SlackWebhookURL = repository.ChannelSlack, "https://hooks.slack.com/services/T1/B2/x"
+	store.unsubscribe(3)
+	d.SendUpdates(context.Background(), subs, time.Now())
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "stays@example.com" {
+		t.Errorf("emailed %v, want only the subscription without a webhook", sender.sent)
+	}
+	msg, ok := poster.posted["https://hooks.slack.com/services/T1/B2/x"]
+	if !ok || len(poster.posted) != 1 {
+		t.Fatalf("posted to %v, want the webhook of the active subscription only", poster.posted)
+	}
+	if msg.Text != "Weather update for Lviv" {
+		t.Errorf("posted %q", msg.Text)
+	}
+	if len(deliveries.recorded) != 2 {
+		t.Fatalf("recorded %d deliveries, want 2", len(deliveries.recorded))
+	}
+	for _, r := range deliveries.recorded {
+		if r.Status != repository.DeliveryStatusSent || !r.SentAt.Valid {
+			t.Errorf("delivery of subscription %d = %+v, want sent", r.SubscriptionID.Int64, r)
+		}
+		if r.SubscriptionID.Int64 == 2 && r.OpenToken.Valid {
+			t.Errorf("slack delivery has an open-tracking token")
+		}
+	}
+
+	// a failed post is recorded as failed
+	poster.err = &slack.Error{Status: 404, Body: "no_service"}
+	d.SendUpdates(context.Background(), subs[1:2], time.Now())
+	if last := deliveries.recorded[len(deliveries.recorded)-1]; last.Status != repository.DeliveryStatusFailed {
+		t.Errorf("failed post recorded as %q", last.Status)
+	}
+}
+
+type recordingSMS struct {
+	sent map[string]string
+}
+
+func (s *recordingSMS) Send(_ context.Context, to, body string) error {
+	s.sent[to] = body
+	return nil
+}
+
+func TestDispatcher_TextsPhones(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	texts := &recordingSMS{sent: map[string]string{}}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop()).
+		WithNotifier(notify.NewSMS(texts))
+
+	subs := testSubs()
+	subs[1].Frequency, subs[1].Channel, subs[1].Phone = repository.FrequencyDaily, repository.ChannelSMS, "+380501234567"
+	d.SendUpdates(context.Background(), subs, time.Now())
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "stays@example.com" {
+		t.Errorf("emailed %v, want only the subscription without a phone", sender.sent)
+	}
+	want := "Lviv: 20.00°C, Sunny, humidity 50%.\nManage or stop: https://example.com/api/manage/"
+	if got := texts.sent["+380501234567"]; !strings.HasPrefix(got, want) {
+		t.Errorf("texted %q, want %q...", got, want)
+	}
+
+	// the welcome of an SMS subscription is a text too
+	subs[1].ScheduledHour, subs[1].Timezone = 8, "Europe/Kyiv"
+	d.SendWelcome(context.Background(), subs[1])
+	if got := texts.sent["+380501234567"]; !strings.HasPrefix(got, "Subscribed! Weather texts will come every day at 08:00 (Europe/Kyiv time).") {
+		t.Errorf("welcome text = %q", got)
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("welcome of an SMS subscription was emailed")
+	}
+}
+
+func TestDispatcher_SendUpdates_ChannelWithoutNotifier(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop())
+
+	subs := testSubs()
+	subs[1].Channel, subs[1].Phone = repository.ChannelSMS, "+380501234567"
+	d.SendUpdates(context.Background(), subs, time.Now())
+
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "stays@example.com" {
+		t.Errorf("emailed %v, want the SMS subscription left alone", sender.sent)
+	}
+	for _, r := range deliveries.recorded {
+		if r.SubscriptionID.Int64 == 2 && r.Status != repository.DeliveryStatusFailed {
+			t.Errorf("update without a notifier recorded as %q, want failed", r.Status)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// RecipientGuard decides which addresses may get a non-transactional email now.
+type RecipientGuard interface {
+	// Claim returns the addresses (lower-cased) that may be emailed, reserving them
+	// for the guard window.
+	Claim(ctx context.Context, emails []string) map[string]bool
+	// Release gives back the reservations of addresses whose email was not sent.
+	Release(ctx context.Context, emails []string)
+	// Held returns the addresses (lower-cased) of emails reserved now, without
+	// reserving any.
+	Held(ctx context.Context, emails []string) map[string]bool
+}
+
+// SendGuard enforces a minimum interval between non-transactional emails (weather
+// updates, lifecycle emails) to the same address, in every scheduler replica. It is a
+// safety net against batching or scheduling bugs, not part of the schedule: a second
+// email within the window is dropped and logged. Confirmation and welcome emails are
+// not guarded.
+//
+// Reservations are Redis keys named after a hash of the address, so no address is
+// stored in Redis. When Redis is unavailable the guard lets every email through.
+type SendGuard struct {
+	rdb    *redis.Client
+	window time.Duration
+	logger *zap.Logger
+}
+
+func NewSendGuard(rdb *redis.Client, window time.Duration, logger *zap.Logger) *SendGuard {
+	return &SendGuard{rdb: rdb, window: window, logger: logger}
+}
+
+func guardKey(email string) string {
+	sum := sha256.Sum256([]byte(email))
+	return "send_guard:" + hex.EncodeToString(sum[:])
+}
+
+// Claim implements RecipientGuard.
+func (g *SendGuard) Claim(ctx context.Context, emails []string) map[string]bool {
+	allowed := make(map[string]bool, len(emails))
+	pipe := g.rdb.Pipeline()
+	cmds := make(map[string]*redis.BoolCmd, len(emails))
+	for _, e := range emails {
+		e = strings.ToLower(e)
+		if _, dup := cmds[e]; !dup {
+			cmds[e] = pipe.SetNX(ctx, guardKey(e), 1, g.window)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		g.logger.Warn("send guard unavailable, not limiting emails", zap.Error(err))
+		for e := range cmds {
+			allowed[e] = true
+		}
+		return allowed
+	}
+	for e, cmd := range cmds {
+		allowed[e] = cmd.Val()
+	}
+	return allowed
+}
+
+// Release implements RecipientGuard.
+func (g *SendGuard) Release(ctx context.Context, emails []string) {
+	if len(emails) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(emails))
+	for _, e := range emails {
+		keys = append(keys, guardKey(strings.ToLower(e)))
+	}
+	if err := g.rdb.Del(ctx, keys...).Err(); err != nil {
+		g.logger.Warn("failed to release send guard", zap.Error(err))
+	}
+}
+
+// Held implements RecipientGuard; when Redis is unavailable no address is held.
+func (g *SendGuard) Held(ctx context.Context, emails []string) map[string]bool {
+	held := make(map[string]bool)
+	pipe := g.rdb.Pipeline()
+	cmds := make(map[string]*redis.IntCmd, len(emails))
+	for _, e := range emails {
+		e = strings.ToLower(e)
+		if _, dup := cmds[e]; !dup {
+			cmds[e] = pipe.Exists(ctx, guardKey(e))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		g.logger.Warn("send guard unavailable, not limiting emails", zap.Error(err))
+		return held
+	}
+	for e, cmd := range cmds {
+		if cmd.Val() > 0 {
+			held[e] = true
+		}
+	}
+	return held
+}
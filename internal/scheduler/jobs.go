@@ -0,0 +1,300 @@
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// jobPollInterval is how long a worker waits for jobs once the queue is drained.
+const jobPollInterval = time.Second
+
+// jobClaimLimit bounds the jobs a worker takes at once, sent as one batch.
+const jobClaimLimit = 100
+
+var (
+	jobsEnqueued  = metrics.NewCounter("weather_send_jobs_enqueued_total", "Weather update sends queued by the scheduler tick.")
+	jobsRetried   = metrics.NewCounter("weather_send_jobs_retried_total", "Weather update sends that failed and were rescheduled.")
+	jobsAbandoned = metrics.NewCounter("weather_send_jobs_abandoned_total",
+		"Weather update sends given up after the last attempt failed.")
+)
+
+// JobQueue is the durable queue of weather update sends.
+type JobQueue interface {
+	Enqueue(ctx context.Context, slot time.Time, ids []int) (int64, error)
+	Claim(ctx context.Context, worker string, limit int, lease time.Duration) ([]repository.SendJob, error)
+	Extend(ctx context.Context, worker string, ids []int64, lease time.Duration) ([]int64, error)
+	Complete(ctx context.Context, worker string, ids []int64) error
+	Retry(ctx context.Context, worker string, id int64, runAt time.Time, reason string) error
+}
+
+// SubscriptionLoader reads the subscriptions of queued jobs, when still active.
+type SubscriptionLoader interface {
+	ActiveByIDs(ctx context.Context, ids []int) ([]repository.Subscription, error)
+}
+
+// EnqueueUpdates queues a send of the update of every subscription in subs for slot and
+// returns how many were queued, those already queued aside.
+func EnqueueUpdates(ctx context.Context, queue JobQueue, subs []repository.Subscription, slot time.Time,
+	logger *zap.Logger,
+) (int64, error) {
+	if len(subs) == 0 {
+		return 0, nil
+	}
+	ids := make([]int, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.ID
+	}
+	n, err := queue.Enqueue(ctx, slot, ids)
+	if err != nil {
+		logger.Error("failed to enqueue weather updates", zap.Time("slot", slot), zap.Error(err))
+		return 0, err
+	}
+	jobsEnqueued.Add(float64(n))
+	return n, nil
+}
+
+// BatchPager reads the batches of a slot a page at a time, in subscription id order,
+// so that a large slot is neither held in memory nor read by one long query.
+// repository.SubscriptionRepository implements it.
+type BatchPager interface {
+	HourlyBatchPage(ctx context.Context, minute, afterID, limit int) ([]repository.Subscription, error)
+	DailyBatchPage(ctx context.Context, at time.Time, afterID, limit int) ([]repository.Subscription, error)
+}
+
+// BatchStreamer reads the batches of a slot in one query each, handing over every
+// subscription as it is read. repository.SubscriptionRepository implements it.
+type BatchStreamer interface {
+	StreamHourlyBatch(ctx context.Context, minute int, fn func(repository.Subscription) error) error
+	StreamDailyBatch(ctx context.Context, at time.Time, fn func(repository.Subscription) error) error
+}
+
+// BatchReading is how QueueSlot reads a batch from a source that supports it: streamed
+// when Stream, else in keyset pages, and queued PageSize subscriptions at a time either
+// way. A PageSize of 0 reads and queues each batch whole.
+type BatchReading struct {
+	PageSize int
+	Stream   bool
+}
+
+// QueueSlot queues the updates of the subscriptions of batches due at slot: daily ones,
+// and hourly ones too when hourly, each batch read as reading says when batches is a
+// BatchStreamer or BatchPager. What it did is added to report.
+func QueueSlot(ctx context.Context, batches BatchSource, queue JobQueue, slot time.Time, hourly bool,
+	reading BatchReading, report *TickReport, logger *zap.Logger,
+) {
+	pager, _ := batches.(BatchPager)
+	streamer, _ := batches.(BatchStreamer)
+	size := reading.PageSize
+
+	// queueBatch reads the batch whole by all, in pages by page or streamed by stream,
+	// queues it as it goes, and counts the subscriptions read in selected. It stops at
+	// the first failure.
+	queueBatch := func(batch string, selected *int, all func() ([]repository.Subscription, error),
+		page func(afterID int) ([]repository.Subscription, error),
+		stream func(fn func(repository.Subscription) error) error,
+	) {
+		enqueue := func(subs []repository.Subscription) error {
+			*selected += len(subs)
+			n, err := EnqueueUpdates(ctx, queue, subs, slot, logger)
+			report.Enqueued += n
+			return err
+		}
+
+		var err error
+		switch {
+		case size > 0 && reading.Stream && streamer != nil:
+			chunk := make([]repository.Subscription, 0, size)
+			err = stream(func(sub repository.Subscription) error {
+				if chunk = append(chunk, sub); len(chunk) < size {
+					return nil
+				}
+				err := enqueue(chunk)
+				chunk = chunk[:0]
+				return err
+			})
+			if err == nil {
+				err = enqueue(chunk)
+			}
+		case size > 0 && pager != nil:
+			for afterID := 0; ; {
+				var subs []repository.Subscription
+				if subs, err = page(afterID); err == nil {
+					err = enqueue(subs)
+				}
+				if err != nil || len(subs) < size {
+					break
+				}
+				afterID = subs[len(subs)-1].ID
+			}
+		default:
+			var subs []repository.Subscription
+			if subs, err = all(); err == nil {
+				err = enqueue(subs)
+			}
+		}
+		if err != nil {
+			logger.Error("failed to queue "+batch+" subscriptions", zap.Time("slot", slot), zap.Error(err))
+			report.Fail(batch)
+		}
+	}
+
+	// Hourly subscribers, at their minute of every hour
+	if hourly {
+		minute := slot.Minute()
+		queueBatch("hourly", &report.Hourly,
+			func() ([]repository.Subscription, error) { return batches.HourlyBatch(ctx, minute) },
+			func(afterID int) ([]repository.Subscription, error) {
+				return pager.HourlyBatchPage(ctx, minute, afterID, size)
+			},
+			func(fn func(repository.Subscription) error) error { return streamer.StreamHourlyBatch(ctx, minute, fn) })
+	}
+
+	// Daily subscribers whose local time (in their timezone) is the slot
+	queueBatch("daily", &report.Daily,
+		func() ([]repository.Subscription, error) { return batches.DailyBatch(ctx, slot) },
+		func(afterID int) ([]repository.Subscription, error) {
+			return pager.DailyBatchPage(ctx, slot, afterID, size)
+		},
+		func(fn func(repository.Subscription) error) error { return streamer.StreamDailyBatch(ctx, slot, fn) })
+}
+
+// JobWorker sends the weather updates queued by the scheduler tick. Workers in every
+// replica share the queue: each claims due jobs for a lease, sends them through the
+// dispatcher, and completes them. A job whose send failed is tried again after backoff,
+// doubling, up to maxAttempts in all; a worker that dies leaves its jobs to be claimed
+// again when their lease is over, so a crash mid-batch delays the rest of the batch
+// instead of losing it. The lease is renewed before each slot is sent, and a job is
+// completed or rescheduled only by the worker still holding it. Sends are at least
+// once: a worker dying between sending and completing sends again, which the recipient
+// guard catches.
+type JobWorker struct {
+	queue       JobQueue
+	subs        SubscriptionLoader
+	dispatcher  *Dispatcher
+	id          string
+	lease       time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	logger      *zap.Logger
+}
+
+// NewJobWorker returns a worker named id, unique among the running workers.
+func NewJobWorker(queue JobQueue, subs SubscriptionLoader, dispatcher *Dispatcher, id string, lease time.Duration,
+	maxAttempts int, backoff time.Duration, logger *zap.Logger,
+) *JobWorker {
+	return &JobWorker{queue: queue, subs: subs, dispatcher: dispatcher, id: id, lease: lease,
+		maxAttempts: maxAttempts, backoff: backoff, logger: logger}
+}
+
+// Run works the queue until ctx is done, finishing the jobs in hand.
+func (w *JobWorker) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobs, err := w.queue.Claim(ctx, w.id, jobClaimLimit, w.lease)
+		if err != nil || len(jobs) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(jobPollInterval):
+			}
+			continue
+		}
+		// the jobs are finished even if the worker is being stopped meanwhile
+		w.process(context.WithoutCancel(ctx), jobs, time.Now())
+	}
+}
+
+// process sends the updates of jobs, one batch per slot, and completes or reschedules
+// each job by the outcome recorded for its subscription. The jobs of a slot are leased
+// again before they are sent, and those taken over by another worker meanwhile are left
+// to it.
+func (w *JobWorker) process(ctx context.Context, jobs []repository.SendJob, now time.Time) {
+	bySlot := make(map[time.Time][]repository.SendJob)
+	var slots []time.Time
+	for _, j := range jobs {
+		if bySlot[j.Slot] == nil {
+			slots = append(slots, j.Slot)
+		}
+		bySlot[j.Slot] = append(bySlot[j.Slot], j)
+	}
+
+	for _, slot := range slots {
+		slotJobs, err := w.renew(ctx, bySlot[slot])
+		if err != nil || len(slotJobs) == 0 {
+			// the lease runs out and the jobs are claimed again
+			continue
+		}
+		ids := make([]int, len(slotJobs))
+		for i, j := range slotJobs {
+			ids[i] = j.SubscriptionID
+		}
+		subs, err := w.subs.ActiveByIDs(ctx, ids)
+		if err != nil {
+			// the lease runs out and the jobs are claimed again
+			continue
+		}
+
+		outcome := make(map[int]repository.Delivery)
+		for _, r := range w.dispatcher.SendUpdates(ctx, subs, slot) {
+			outcome[int(r.SubscriptionID.Int64)] = r
+		}
+		var done []int64
+		var sent, skipped, retried, abandoned int
+		for _, j := range slotJobs {
+			r, ok := outcome[j.SubscriptionID]
+			switch {
+			case !ok:
+				// no outcome: unsubscribed, suppressed or skipped, nothing more to do
+				skipped++
+				done = append(done, j.ID)
+				continue
+			case r.Succeeded():
+				sent++
+				done = append(done, j.ID)
+				continue
+			}
+			if j.Attempts >= w.maxAttempts {
+				jobsAbandoned.Inc()
+				abandoned++
+				w.logger.Warn("giving up on weather update", zap.Int("subscription_id", j.SubscriptionID),
+					zap.Time("slot", slot), zap.Int("attempts", j.Attempts), zap.String("error", r.Error.String))
+				done = append(done, j.ID)
+				continue
+			}
+			jobsRetried.Inc()
+			retried++
+			runAt := now.Add(w.backoff << (j.Attempts - 1))
+			if err := w.queue.Retry(ctx, w.id, j.ID, runAt, r.Error.String); err != nil {
+				w.logger.Warn("failed to reschedule weather update, retrying after the lease",
+					zap.Int64("job", j.ID), zap.Error(err))
+			}
+		}
+		w.logger.Info("sent queued weather updates", zap.Time("slot", slot), zap.Int("jobs", len(slotJobs)),
+			zap.Int("sent", sent), zap.Int("skipped", skipped), zap.Int("retried", retried),
+			zap.Int("abandoned", abandoned))
+		if err := w.queue.Complete(ctx, w.id, done); err != nil {
+			w.logger.Error("failed to complete send jobs, they will be sent again", zap.Error(err))
+		}
+	}
+}
+
+// renew extends the lease of jobs and returns those the worker still holds.
+func (w *JobWorker) renew(ctx context.Context, jobs []repository.SendJob) ([]repository.SendJob, error) {
+	ids := make([]int64, len(jobs))
+	for i, j := range jobs {
+		ids[i] = j.ID
+	}
+	held, err := w.queue.Extend(ctx, w.id, ids, w.lease)
+	if err != nil {
+		w.logger.Warn("failed to renew send job leases", zap.Error(err))
+		return nil, err
+	}
+	if len(held) < len(jobs) {
+		w.logger.Warn("send jobs taken over by another worker", zap.Int("jobs", len(jobs)-len(held)))
+	}
+	return slices.DeleteFunc(jobs, func(j repository.SendJob) bool { return !slices.Contains(held, j.ID) }), nil
+}
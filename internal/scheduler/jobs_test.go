@@ -0,0 +1,222 @@
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+type memoryQueue struct {
+	enqueued  map[time.Time][]int
+	completed []int64
+	retried   map[int64]time.Time
+	lost      map[int64]bool // taken over by another worker
+}
+
+func (q *memoryQueue) Enqueue(_ context.Context, slot time.Time, ids []int) (int64, error) {
+	if q.enqueued == nil {
+		q.enqueued = make(map[time.Time][]int)
+	}
+	q.enqueued[slot] = append(q.enqueued[slot], ids...)
+	return int64(len(ids)), nil
+}
+
+func (q *memoryQueue) Claim(context.Context, string, int, time.Duration) ([]repository.SendJob, error) {
+	return nil, nil
+}
+
+// Extend holds every job but those lost.
+func (q *memoryQueue) Extend(_ context.Context, _ string, ids []int64, _ time.Duration) ([]int64, error) {
+	return slices.DeleteFunc(slices.Clone(ids), func(id int64) bool { return q.lost[id] }), nil
+}
+
+func (q *memoryQueue) Complete(_ context.Context, _ string, ids []int64) error {
+	q.completed = append(q.completed, ids...)
+	return nil
+}
+
+func (q *memoryQueue) Retry(_ context.Context, _ string, id int64, runAt time.Time, _ string) error {
+	q.retried[id] = runAt
+	return nil
+}
+
+type subsByID []repository.Subscription
+
+func (s subsByID) ActiveByIDs(_ context.Context, ids []int) ([]repository.Subscription, error) {
+	var out []repository.Subscription
+	for _, sub := range s {
+		if slices.Contains(ids, sub.ID) {
+			out = append(out, sub)
+		}
+	}
+	return out, nil
+}
+
+func TestJobWorker_CompletesOrRetries(t *testing.T) {
+	subs := append(testSubs(), repository.Subscription{ID: 3, Email: "bounces@example.com", City: "Odesa",
+		Frequency: "hourly", Confirmed: true})
+	store := &fakeStore{active: map[int]bool{1: true, 2: true, 3: true}}
+	sender := &recordingSender{reject: map[string]bool{"leaves@example.com": true, "bounces@example.com": true}}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop())
+	queue := &memoryQueue{retried: map[int64]time.Time{}}
+	w := NewJobWorker(queue, subsByID(subs), d, "test-0", time.Minute, 3, time.Minute, zap.NewNop())
+
+	slot := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	now := slot.Add(time.Minute)
+	w.process(context.Background(), []repository.SendJob{
+		{ID: 11, SubscriptionID: 1, Slot: slot, Attempts: 1},
+		{ID: 12, SubscriptionID: 2, Slot: slot, Attempts: 2},
+		{ID: 13, SubscriptionID: 3, Slot: slot, Attempts: 3},
+		{ID: 14, SubscriptionID: 4, Slot: slot, Attempts: 1}, // unsubscribed since
+	}, now)
+
+	slices.Sort(queue.completed)
+	if want := []int64{11, 13, 14}; !slices.Equal(queue.completed, want) {
+		t.Errorf("completed %v, want %v (sent, out of attempts, gone)", queue.completed, want)
+	}
+	if len(queue.retried) != 1 || !queue.retried[12].Equal(now.Add(2*time.Minute)) {
+		t.Errorf("retried %v, want job 12 after 2m", queue.retried)
+	}
+}
+
+func TestJobWorker_LeavesJobsTakenOver(t *testing.T) {
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}}
+	sender := &recordingSender{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, &recordingDeliveries{}, "https://example.com", zap.NewNop())
+	queue := &memoryQueue{retried: map[int64]time.Time{}, lost: map[int64]bool{12: true}}
+	w := NewJobWorker(queue, subsByID(testSubs()), d, "test-0", time.Minute, 3, time.Minute, zap.NewNop())
+
+	slot := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	w.process(context.Background(), []repository.SendJob{
+		{ID: 11, SubscriptionID: 1, Slot: slot, Attempts: 1},
+		{ID: 12, SubscriptionID: 2, Slot: slot, Attempts: 1},
+	}, slot.Add(time.Minute))
+
+	if !slices.Equal(queue.completed, []int64{11}) {
+		t.Errorf("completed %v, want [11]: job 12 belongs to another worker now", queue.completed)
+	}
+	if got := len(sender.sent); got != 1 {
+		t.Errorf("sent %d updates, want 1", got)
+	}
+}
+
+// fixedBatches is a BatchSource returning the same hourly and daily batches at every slot.
+type fixedBatches struct {
+	hourly, daily []repository.Subscription
+}
+
+func (b fixedBatches) HourlyBatch(context.Context, int) ([]repository.Subscription, error) {
+	return b.hourly, nil
+}
+
+func (b fixedBatches) DailyBatch(context.Context, time.Time) ([]repository.Subscription, error) {
+	return b.daily, nil
+}
+
+func TestQueueSlot(t *testing.T) {
+	batches := fixedBatches{hourly: []repository.Subscription{{ID: 1}}, daily: []repository.Subscription{{ID: 2}, {ID: 3}}}
+	queue := &memoryQueue{}
+	slot := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+	report := StartTick(slot)
+	QueueSlot(context.Background(), batches, queue, slot, true, BatchReading{}, report, zap.NewNop())
+	QueueSlot(context.Background(), batches, queue, slot.Add(-2*time.Hour), false, BatchReading{}, report, zap.NewNop())
+
+	if report.Hourly != 1 || report.Daily != 4 || report.Enqueued != 5 {
+		t.Errorf("report = %+v, want 1 hourly, 4 daily, 5 enqueued", report)
+	}
+	if got := queue.enqueued[slot.Add(-2*time.Hour)]; !slices.Equal(got, []int{2, 3}) {
+		t.Errorf("queued %v at the daily-only slot, want [2 3]", got)
+	}
+}
+
+// pagedBatches is a BatchSource, BatchPager and BatchStreamer whose daily batch is ids 1 to daily and
+// whose hourly batch is empty; it records the afterID of every page read.
+type pagedBatches struct {
+	daily int
+	pages []int
+}
+
+func (b *pagedBatches) HourlyBatch(context.Context, int) ([]repository.Subscription, error) {
+	return nil, nil
+}
+
+func (b *pagedBatches) DailyBatch(context.Context, time.Time) ([]repository.Subscription, error) {
+	return b.DailyBatchPage(context.Background(), time.Time{}, 0, b.daily)
+}
+
+func (b *pagedBatches) HourlyBatchPage(context.Context, int, int, int) ([]repository.Subscription, error) {
+	return nil, nil
+}
+
+func (b *pagedBatches) DailyBatchPage(_ context.Context, _ time.Time, afterID, limit int) ([]repository.Subscription, error) {
+	b.pages = append(b.pages, afterID)
+	var out []repository.Subscription
+	for id := afterID + 1; id <= b.daily && len(out) < limit; id++ {
+		out = append(out, repository.Subscription{ID: id})
+	}
+	return out, nil
+}
+
+func (b *pagedBatches) StreamHourlyBatch(context.Context, int, func(repository.Subscription) error) error {
+	return nil
+}
+
+func (b *pagedBatches) StreamDailyBatch(_ context.Context, _ time.Time, fn func(repository.Subscription) error) error {
+	for id := 1; id <= b.daily; id++ {
+		if err := fn(repository.Subscription{ID: id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestQueueSlot_Paged(t *testing.T) {
+	batches := &pagedBatches{daily: 5}
+	queue := &memoryQueue{}
+	slot := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+	report := StartTick(slot)
+	QueueSlot(context.Background(), batches, queue, slot, false, BatchReading{PageSize: 2}, report, zap.NewNop())
+
+	if !slices.Equal(batches.pages, []int{0, 2, 4}) {
+		t.Errorf("read pages after %v, want [0 2 4]", batches.pages)
+	}
+	if got := queue.enqueued[slot]; !slices.Equal(got, []int{1, 2, 3, 4, 5}) || report.Daily != 5 {
+		t.Errorf("queued %v, %d daily; want [1 2 3 4 5], 5", got, report.Daily)
+	}
+}
+
+func TestQueueSlot_Streamed(t *testing.T) {
+	batches := &pagedBatches{daily: 5}
+	queue := &countingQueue{}
+	slot := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+	report := StartTick(slot)
+	QueueSlot(context.Background(), batches, queue, slot, false, BatchReading{PageSize: 2, Stream: true}, report,
+		zap.NewNop())
+
+	if len(batches.pages) != 0 {
+		t.Errorf("read pages after %v, want the batch streamed", batches.pages)
+	}
+	if !slices.Equal(queue.calls, []int{2, 2, 1}) || report.Daily != 5 || report.Enqueued != 5 {
+		t.Errorf("queued %v at a time, %d daily, %d enqueued; want [2 2 1], 5, 5", queue.calls, report.Daily,
+			report.Enqueued)
+	}
+}
+
+// countingQueue is a memoryQueue that records the size of every Enqueue call.
+type countingQueue struct {
+	memoryQueue
+	calls []int
+}
+
+func (q *countingQueue) Enqueue(ctx context.Context, slot time.Time, ids []int) (int64, error) {
+	q.calls = append(q.calls, len(ids))
+	return q.memoryQueue.Enqueue(ctx, slot, ids)
+}
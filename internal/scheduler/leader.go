@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+// leaderKey holds the name of the leading scheduler replica, with the lease as TTL.
+const leaderKey = "scheduler_leader"
+
+var isLeader = metrics.NewGauge("weather_scheduler_leader",
+	"1 while this scheduler replica holds the leader lease, 0 on standby.")
+
+// renewScript extends the lease if it is still held by ARGV[1]; 1 when extended.
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript drops the lease if it is still held by ARGV[1].
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+// Leader elects one scheduler replica to run the jobs, the others standing by. The
+// leader holds a lease in Redis that it renews every third of its length; when it dies
+// the lease runs out and a standby, trying as often, takes over within about a lease.
+// A leader stopping cleanly gives the lease back for an immediate takeover.
+//
+// A leader only leads until its lease would run out, counted from before its last
+// successful take or renewal: while Redis is unavailable it keeps leading for the rest
+// of the lease, then steps down, since a standby may take the lease once Redis is back.
+// A leader that finds the lease taken by another replica (e.g. Redis lost it) steps
+// down at once; tick claims (TickLock) cover the moment both believe they lead.
+type Leader struct {
+	rdb     *redis.Client
+	id      string
+	lease   time.Duration
+	leading atomic.Bool
+	logger  *zap.Logger
+
+	mu    sync.Mutex
+	until time.Time // when the lease held runs out at the latest
+}
+
+// NewLeader competes for the lease as the replica named id (e.g. the host name).
+func NewLeader(rdb *redis.Client, id string, lease time.Duration, logger *zap.Logger) *Leader {
+	return &Leader{rdb: rdb, id: id, lease: lease, logger: logger}
+}
+
+// IsLeader reports whether this replica leads, and its lease has not run out; a nil
+// Leader always leads.
+func (l *Leader) IsLeader() bool {
+	if l == nil {
+		return true
+	}
+	return l.leading.Load() && time.Now().Before(l.leaseEnd())
+}
+
+func (l *Leader) leaseEnd() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.until
+}
+
+// extend records that the lease, taken or renewed by a call started at start, holds
+// for a lease from then.
+func (l *Leader) extend(start time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.until = start.Add(l.lease)
+}
+
+// Run competes for and renews the lease until ctx is done, then gives it back.
+func (l *Leader) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.lease / 3)
+	defer ticker.Stop()
+	for {
+		l.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			l.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign renews the lease of a leader, or tries to take it on standby.
+func (l *Leader) campaign(ctx context.Context) {
+	start := time.Now()
+	if l.leading.Load() {
+		held, err := renewScript.Run(ctx, l.rdb, []string{leaderKey}, l.id, l.lease.Milliseconds()).Int()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if !start.Before(l.leaseEnd()) {
+				l.logger.Warn("failed to renew the scheduler leader lease before it ran out, stepping down", zap.Error(err))
+				l.setLeading(false)
+				return
+			}
+			l.logger.Warn("failed to renew the scheduler leader lease, still leading", zap.Error(err))
+			return
+		}
+		if held == 0 {
+			l.setLeading(false)
+			return
+		}
+		l.extend(start)
+		return
+	}
+	ok, err := l.rdb.SetNX(ctx, leaderKey, l.id, l.lease).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			l.logger.Warn("failed to compete for the scheduler leader lease", zap.Error(err))
+		}
+		return
+	}
+	if ok {
+		l.extend(start)
+		l.setLeading(true)
+	}
+}
+
+// resign gives the lease back, if still held, so that a standby takes over now.
+func (l *Leader) resign() {
+	if !l.leading.Load() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := releaseScript.Run(ctx, l.rdb, []string{leaderKey}, l.id).Err(); err != nil {
+		l.logger.Warn("failed to release the scheduler leader lease", zap.Error(err))
+	}
+	l.setLeading(false)
+}
+
+func (l *Leader) setLeading(leading bool) {
+	l.leading.Store(leading)
+	if leading {
+		isLeader.Set(1)
+		l.logger.Info("leading the scheduler", zap.String("id", l.id))
+	} else {
+		isLeader.Set(0)
+		l.logger.Info("standing by, another scheduler replica leads", zap.String("id", l.id))
+	}
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// downRedis returns a client of a Redis that refuses every connection.
+func downRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1, DialTimeout: time.Second})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func TestLeader_StepsDownWhenTheLeaseRunsOut(t *testing.T) {
+	const lease = 200 * time.Millisecond
+	l := NewLeader(downRedis(t), "scheduler-1", lease, zap.NewNop())
+	l.extend(time.Now())
+	l.setLeading(true)
+
+	// renewals failing within the lease keep the leader leading
+	l.campaign(context.Background())
+	if !l.IsLeader() {
+		t.Fatal("IsLeader() = false before the lease ran out")
+	}
+
+	// without a renewal it stops leading at the end of the lease, even before campaigning
+	time.Sleep(lease)
+	if l.IsLeader() {
+		t.Error("IsLeader() = true after the lease ran out")
+	}
+	l.campaign(context.Background())
+	if l.leading.Load() {
+		t.Error("campaign() kept leading after the lease ran out")
+	}
+}
+
+func TestTickLock_RedisDown(t *testing.T) {
+	rdb := downRedis(t)
+	tick := time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC)
+
+	// a single replica, or replicas without election, run the tick
+	if !NewTickLock(rdb, "scheduler-1", time.Minute, zap.NewNop()).Acquire(context.Background(), "hourly", tick) {
+		t.Error("Acquire() without a leader = false, want true")
+	}
+
+	// a leader does not run ticks it cannot claim
+	leader := NewLeader(rdb, "scheduler-1", time.Minute, zap.NewNop())
+	leader.extend(time.Now())
+	leader.setLeading(true)
+	lock := NewTickLock(rdb, "scheduler-1", time.Minute, zap.NewNop()).WithLeader(leader)
+	if lock.Acquire(context.Background(), "hourly", tick) {
+		t.Error("Acquire() of a leader = true, want false")
+	}
+}
@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// LifecycleSpec runs the lifecycle emails once a day, at 10:00 UTC.
+const LifecycleSpec = "0 10 * * *"
+
+// anniversaryWindow bounds how late an anniversary email may still go out, e.g. after
+// the scheduler was down on the day itself; older anniversaries are skipped.
+const anniversaryWindow = 7 * 24 * time.Hour
+
+// LifecycleMailer sends the emails that follow a subscriber's lifecycle rather than the
+// weather schedule: a note after one year of updates, a prompt to confirm continued
+// interest after a long period without opens, and a renewal link once a subscription
+// expired.
+//
+// Every email is claimed in the database before sending, so it goes out at most once
+// even with several scheduler replicas.
+type LifecycleMailer struct {
+	repo          repository.EngagementRepository
+	suppressions  SuppressionChecker
+	sender        email.EmailSender
+	baseURL       string
+	reEngageAfter time.Duration
+	guard         RecipientGuard   // optional
+	links         *linksign.Signer // optional, signs unsubscribe links
+	logger        *zap.Logger
+}
+
+func NewLifecycleMailer(
+	repo repository.EngagementRepository,
+	suppressions SuppressionChecker,
+	sender email.EmailSender,
+	baseURL string,
+	reEngageAfter time.Duration,
+	logger *zap.Logger,
+) *LifecycleMailer {
+	return &LifecycleMailer{repo: repo, suppressions: suppressions, sender: sender, baseURL: baseURL,
+		reEngageAfter: reEngageAfter, logger: logger}
+}
+
+// WithRecipientGuard drops lifecycle emails to addresses emailed within the guard window.
+func (m *LifecycleMailer) WithRecipientGuard(g RecipientGuard) *LifecycleMailer {
+	m.guard = g
+	return m
+}
+
+// WithLinkSigner signs the unsubscribe links of the emails.
+func (m *LifecycleMailer) WithLinkSigner(s *linksign.Signer) *LifecycleMailer {
+	m.links = s
+	return m
+}
+
+// Run sends the lifecycle emails due at now.
+func (m *LifecycleMailer) Run(ctx context.Context, now time.Time) {
+	anniversaries, err := m.repo.ClaimAnniversaries(ctx, now, anniversaryWindow)
+	if err != nil {
+		m.logger.Error("failed to select anniversary emails", zap.Error(err))
+	} else {
+		m.send(ctx, repository.LifecycleAnniversary, anniversaries, m.anniversary)
+	}
+
+	inactive, err := m.repo.ClaimReEngagements(ctx, now, m.reEngageAfter)
+	if err != nil {
+		m.logger.Error("failed to select re-engagement emails", zap.Error(err))
+	} else {
+		m.send(ctx, repository.LifecycleReEngagement, inactive, m.reEngagement)
+	}
+
+	expired, err := m.repo.ClaimRenewals(ctx, now)
+	if err != nil {
+		m.logger.Error("failed to select renewal emails", zap.Error(err))
+	} else {
+		m.send(ctx, repository.LifecycleRenewal, expired, m.renewal)
+	}
+}
+
+func (m *LifecycleMailer) anniversary(sub repository.Subscription) (email.EmailMessage, error) {
+	unsubURL := unsubscribeURL(m.baseURL, m.links, sub)
+	body, err := email.Render(email.TemplateAnniversary, email.AnniversaryData{
+		Cities:         sub.AllCities(),
+		Since:          sub.ConfirmedAt.Time.UTC().Format("2 January 2006"),
+		ManageURL:      manageURL(m.baseURL, sub),
+		UnsubscribeURL: unsubURL,
+	})
+	return email.EmailMessage{
+		To:             []string{sub.Email},
+		Subject:        "One year of weather updates",
+		Body:           body,
+		UnsubscribeURL: unsubURL,
+	}, err
+}
+
+func (m *LifecycleMailer) reEngagement(sub repository.Subscription) (email.EmailMessage, error) {
+	unsubURL := unsubscribeURL(m.baseURL, m.links, sub)
+	body, err := email.Render(email.TemplateReEngagement, email.ReEngagementData{
+		Cities:         sub.AllCities(),
+		KeepURL:        manageURL(m.baseURL, sub) + "/keep",
+		ManageURL:      manageURL(m.baseURL, sub),
+		UnsubscribeURL: unsubURL,
+	})
+	return email.EmailMessage{
+		To:             []string{sub.Email},
+		Subject:        "Do you still want weather updates?",
+		Body:           body,
+		UnsubscribeURL: unsubURL,
+	}, err
+}
+
+func (m *LifecycleMailer) renewal(sub repository.Subscription) (email.EmailMessage, error) {
+	unsubURL := unsubscribeURL(m.baseURL, m.links, sub)
+	body, err := email.Render(email.TemplateRenewal, email.RenewalData{
+		Cities:         sub.AllCities(),
+		ExpiredOn:      sub.ExpiresAt.Time.UTC().Format("2 January 2006"),
+		KeepURL:        manageURL(m.baseURL, sub) + "/keep",
+		ManageURL:      manageURL(m.baseURL, sub),
+		UnsubscribeURL: unsubURL,
+	})
+	return email.EmailMessage{
+		To:             []string{sub.Email},
+		Subject:        "Keep receiving weather updates?",
+		Body:           body,
+		UnsubscribeURL: unsubURL,
+	}, err
+}
+
+// send renders one email per subscription and sends them in one batch, skipping
+// suppressed addresses and those refused by the recipient guard. Nothing is retried: a
+// lifecycle email that failed is not worth a second attempt.
+func (m *LifecycleMailer) send(ctx context.Context, kind string, subs []repository.Subscription,
+	build func(repository.Subscription) (email.EmailMessage, error),
+) {
+	if len(subs) == 0 {
+		return
+	}
+
+	emails := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		emails = append(emails, sub.Email)
+	}
+	suppressed, err := m.suppressions.FilterSuppressed(ctx, emails)
+	if err != nil {
+		m.logger.Error("failed to check suppressions, skipping lifecycle emails",
+			zap.String("kind", kind), zap.Error(err))
+		return
+	}
+
+	allowed := map[string]bool{}
+	if m.guard != nil {
+		var candidates []string
+		for _, sub := range subs {
+			if !suppressed[strings.ToLower(sub.Email)] {
+				candidates = append(candidates, sub.Email)
+			}
+		}
+		allowed = m.guard.Claim(ctx, candidates)
+	}
+
+	var messages []email.EmailMessage
+	var claimed []string
+	for _, sub := range subs {
+		if suppressed[strings.ToLower(sub.Email)] {
+			continue
+		}
+		if m.guard != nil && !allowed[strings.ToLower(sub.Email)] {
+			m.logger.Warn("address emailed within the minimum interval, not sending lifecycle email",
+				zap.String("kind", kind), zap.Int("id", sub.ID))
+			continue
+		}
+		msg, err := build(sub)
+		if err != nil {
+			m.logger.Error("failed to render lifecycle email",
+				zap.String("kind", kind), zap.Int("id", sub.ID), zap.Error(err))
+			continue
+		}
+		messages = append(messages, msg)
+		claimed = append(claimed, sub.Email)
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	if err := m.sender.SendBatch(messages); err != nil {
+		m.logger.Error("failed to send lifecycle emails", zap.String("kind", kind), zap.Error(err))
+		if m.guard != nil {
+			var unsent []string
+			for i, addr := range claimed {
+				if email.MessageErr(err, i) != nil {
+					unsent = append(unsent, addr)
+				}
+			}
+			m.guard.Release(ctx, unsent)
+		}
+		return
+	}
+	m.logger.Info("sent lifecycle emails", zap.String("kind", kind), zap.Int("count", len(messages)))
+}
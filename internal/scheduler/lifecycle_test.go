@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// dueLifecycle serves the subscriptions due for each lifecycle email.
+type dueLifecycle struct {
+	repository.EngagementRepository
+
+	anniversaries, inactive, expired []repository.Subscription
+}
+
+func (r *dueLifecycle) ClaimAnniversaries(context.Context, time.Time, time.Duration) ([]repository.Subscription, error) {
+	return r.anniversaries, nil
+}
+
+func (r *dueLifecycle) ClaimReEngagements(context.Context, time.Time, time.Duration) ([]repository.Subscription, error) {
+	return r.inactive, nil
+}
+
+func (r *dueLifecycle) ClaimRenewals(context.Context, time.Time) ([]repository.Subscription, error) {
+	return r.expired, nil
+}
+
+func lifecycleSub(id int, addr string) repository.Subscription {
+	return repository.Subscription{ID: id, Email: addr, City: "Kyiv", Confirmed: true,
+		ManageToken: uuid.New(), UnsubscribeToken: uuid.New(),
+		ConfirmedAt: sql.NullTime{Time: time.Date(2025, 10, 16, 8, 0, 0, 0, time.UTC), Valid: true},
+		ExpiresAt:   sql.NullTime{Time: time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC), Valid: true}}
+}
+
+func TestLifecycleMailer_Run(t *testing.T) {
+	quiet, gone := lifecycleSub(2, "quiet@example.com"), lifecycleSub(3, "gone@example.com")
+	repo := &dueLifecycle{
+		anniversaries: []repository.Subscription{lifecycleSub(1, "year@example.com"), lifecycleSub(4, "bounced@example.com")},
+		inactive:      []repository.Subscription{quiet},
+		expired:       []repository.Subscription{gone},
+	}
+	store := &fakeStore{suppressed: map[string]bool{"bounced@example.com": true}}
+	sender := &recordingSender{}
+	m := NewLifecycleMailer(repo, store, sender, "https://weather.example.com", 90*24*time.Hour, zap.NewNop())
+
+	m.Run(context.Background(), time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC))
+
+	for _, tc := range []struct {
+		to, subject, keepURL string
+	}{
+		{"year@example.com", "One year of weather updates", ""},
+		{"quiet@example.com", "Do you still want weather updates?",
+			"https://weather.example.com/api/manage/" + quiet.ManageToken.String() + "/keep"},
+		{"gone@example.com", "Keep receiving weather updates?",
+			"https://weather.example.com/api/manage/" + gone.ManageToken.String() + "/keep"},
+	} {
+		var found bool
+		for _, msg := range sender.sent {
+			if msg.To[0] != tc.to {
+				continue
+			}
+			found = true
+			if msg.Subject != tc.subject {
+				t.Errorf("email to %s: subject %q, want %q", tc.to, msg.Subject, tc.subject)
+			}
+			if tc.keepURL != "" && !strings.Contains(msg.Body, `href="`+tc.keepURL+`"`) {
+				t.Errorf("email to %s does not link to %s:\n%s", tc.to, tc.keepURL, msg.Body)
+			}
+			if msg.UnsubscribeURL == "" {
+				t.Errorf("email to %s has no unsubscribe link", tc.to)
+			}
+		}
+		if !found {
+			t.Errorf("no email to %s", tc.to)
+		}
+	}
+	if len(sender.sent) != 3 {
+		t.Errorf("%d emails sent, want 3: the suppressed address is skipped", len(sender.sent))
+	}
+}
+
+func TestLifecycleMailer_RecipientGuard(t *testing.T) {
+	repo := &dueLifecycle{inactive: []repository.Subscription{lifecycleSub(1, "Quiet@example.com")}}
+	sender := &recordingSender{reject: map[string]bool{"Quiet@example.com": true}}
+	guard := &memoryGuard{claimed: map[string]bool{}}
+	m := NewLifecycleMailer(repo, &fakeStore{}, sender, "https://weather.example.com", time.Hour, zap.NewNop()).
+		WithRecipientGuard(guard)
+
+	// refused by the server: the address is released for the next email
+	m.Run(context.Background(), time.Now())
+	if guard.claimed["quiet@example.com"] {
+		t.Fatal("address of an unsent email still claimed")
+	}
+
+	sender.reject = nil
+	guard.claimed["quiet@example.com"] = true // emailed by the dispatcher meanwhile
+	m.Run(context.Background(), time.Now())
+	if len(sender.sent) != 0 {
+		t.Errorf("%d emails sent to an address emailed within the guard window", len(sender.sent))
+	}
+}
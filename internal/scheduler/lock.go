@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// TickLock makes every scheduler replica agree on which one runs a job at a given
+// tick, so that running several replicas does not send the same batch several times.
+//
+// The first replica to claim a tick runs it; the claim is a Redis key named after the
+// job and the tick, set if absent, owned by the replica and never released: a replica
+// lagging behind must not run a tick another one already ran. Keys expire after ttl,
+// which must exceed the clock skew between replicas and the time a tick takes to
+// start, and are never in the way of later ticks. When Redis is unavailable every
+// replica runs the tick, like with a single replica, and the recipient guard remains
+// the safety net against duplicates; with a leader, though, the tick is skipped: the
+// leader cannot tell whether its lease still holds, and standbys exist to take over.
+type TickLock struct {
+	rdb    *redis.Client
+	owner  string
+	ttl    time.Duration
+	leader *Leader // optional, standby replicas claim nothing
+	logger *zap.Logger
+}
+
+// NewTickLock claims ticks for the replica named owner (e.g. the host name).
+func NewTickLock(rdb *redis.Client, owner string, ttl time.Duration, logger *zap.Logger) *TickLock {
+	return &TickLock{rdb: rdb, owner: owner, ttl: ttl, logger: logger}
+}
+
+// WithLeader leaves every tick to the leader elected by l.
+func (l *TickLock) WithLeader(leader *Leader) *TickLock {
+	l.leader = leader
+	return l
+}
+
+func tickLockKey(job string, tick time.Time) string {
+	return "scheduler_lock:" + job + ":" + tick.UTC().Format(time.RFC3339)
+}
+
+// Acquire reports whether this replica runs job at tick; false when another replica
+// claimed it first or this replica is on standby. A nil lock runs every tick.
+func (l *TickLock) Acquire(ctx context.Context, job string, tick time.Time) bool {
+	if l == nil {
+		return true
+	}
+	if !l.leader.IsLeader() {
+		return false
+	}
+	key := tickLockKey(job, tick)
+	ok, err := l.rdb.SetNX(ctx, key, l.owner, l.ttl).Result()
+	if err != nil {
+		if l.leader != nil {
+			l.logger.Warn("scheduler lock unavailable, skipping the tick", zap.String("job", job), zap.Error(err))
+			return false
+		}
+		l.logger.Warn("scheduler lock unavailable, running the tick", zap.String("job", job), zap.Error(err))
+		return true
+	}
+	if !ok {
+		owner, _ := l.rdb.Get(ctx, key).Result()
+		l.logger.Debug("tick run by another replica", zap.String("job", job), zap.Time("tick", tick),
+			zap.String("owner", owner))
+		return false
+	}
+	return true
+}
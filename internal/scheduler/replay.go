@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// ReplaySource selects the subscriptions whose failed deliveries are to be sent again.
+type ReplaySource interface {
+	ReplayTargets(ctx context.Context, from, to time.Time) ([]repository.ReplayTarget, error)
+}
+
+// Replayer sends weather updates again to subscriptions whose delivery failed, e.g.
+// during an SMTP outage. Updates are rendered afresh and go through the dispatcher, so
+// unsubscribed and suppressed addresses are skipped just like in a scheduled batch, and
+// every replay is recorded in the delivery log against the failed slot.
+type Replayer struct {
+	source     ReplaySource
+	dispatcher *Dispatcher
+	logger     *zap.Logger
+}
+
+func NewReplayer(source ReplaySource, dispatcher *Dispatcher, logger *zap.Logger) *Replayer {
+	return &Replayer{source: source, dispatcher: dispatcher, logger: logger}
+}
+
+// Run replays the failed deliveries of slots within [from, to) and returns how many
+// subscriptions it sent to (or, with dryRun, would send to).
+func (r *Replayer) Run(ctx context.Context, from, to time.Time, dryRun bool) (int, error) {
+	targets, err := r.source.ReplayTargets(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return len(targets), nil
+	}
+
+	// one batch per slot, targets come ordered by slot
+	for start := 0; start < len(targets); {
+		if err := ctx.Err(); err != nil {
+			return start, err
+		}
+		slot := targets[start].Slot
+		end := start
+		var subs []repository.Subscription
+		for ; end < len(targets) && targets[end].Slot.Equal(slot); end++ {
+			subs = append(subs, targets[end].Subscription)
+		}
+		r.logger.Info("replaying failed deliveries", zap.Time("slot", slot), zap.Int("count", len(subs)))
+		r.dispatcher.SendUpdates(ctx, subs, slot)
+		start = end
+	}
+	return len(targets), nil
+}
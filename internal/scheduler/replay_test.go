@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+type fixedReplaySource []repository.ReplayTarget
+
+func (s fixedReplaySource) ReplayTargets(context.Context, time.Time, time.Time) ([]repository.ReplayTarget, error) {
+	return s, nil
+}
+
+func TestReplayer_Run(t *testing.T) {
+	subs := testSubs()
+	early := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	source := fixedReplaySource{{Subscription: subs[0], Slot: early}, {Subscription: subs[1], Slot: late}}
+
+	store := &fakeStore{active: map[int]bool{1: true, 2: true}, suppressed: map[string]bool{"leaves@example.com": true}}
+	sender := &recordingSender{}
+	deliveries := &recordingDeliveries{}
+	d := NewDispatcher(store, store, &slowFetcher{}, sender, deliveries, "https://example.com", zap.NewNop())
+	r := NewReplayer(source, d, zap.NewNop())
+
+	if n, err := r.Run(context.Background(), early, late.Add(time.Hour), true); err != nil || n != 2 || len(sender.sent) != 0 {
+		t.Fatalf("dry run: Run() = %d, %v and sent %d emails", n, err, len(sender.sent))
+	}
+
+	if _, err := r.Run(context.Background(), early, late.Add(time.Hour), false); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	// the suppressed address is skipped like in a scheduled batch
+	if len(sender.sent) != 1 || sender.sent[0].To[0] != "stays@example.com" {
+		t.Fatalf("sent %+v, want only the unsuppressed subscriber", sender.sent)
+	}
+	if len(deliveries.recorded) != 1 || !deliveries.recorded[0].ScheduledFor.Equal(early) {
+		t.Errorf("recorded %+v, want the replay against its failed slot", deliveries.recorded)
+	}
+}
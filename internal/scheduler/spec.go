@@ -0,0 +1,22 @@
+package scheduler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// CronParser parses the scheduler's cron specs: the standard 5 fields, or 6 with
+// seconds first, and descriptors such as @every 15m.
+var CronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month |
+	cron.Dow | cron.Descriptor)
+
+// TickResolution returns the length of the slots of the ticks of spec: a second for
+// specs with seconds, a minute otherwise.
+func TickResolution(spec string) time.Duration {
+	if !strings.HasPrefix(spec, "@") && len(strings.Fields(spec)) == 6 {
+		return time.Second
+	}
+	return time.Minute
+}
@@ -0,0 +1,22 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickResolution(t *testing.T) {
+	cases := map[string]time.Duration{
+		"* * * * *":      time.Minute,
+		"*/10 * * * * *": time.Second,
+		"@every 15m":     time.Minute,
+	}
+	for spec, want := range cases {
+		if _, err := CronParser.Parse(spec); err != nil {
+			t.Errorf("CronParser.Parse(%q) error: %v", spec, err)
+		}
+		if got := TickResolution(spec); got != want {
+			t.Errorf("TickResolution(%q) = %v, want %v", spec, got, want)
+		}
+	}
+}
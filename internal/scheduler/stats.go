@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// StatsSpec refreshes the subscription counts every five minutes.
+const StatsSpec = "*/5 * * * *"
+
+var (
+	subscriptionsConfirmed = metrics.NewGauge("weather_subscriptions_confirmed",
+		"Confirmed subscriptions.")
+	subscriptionsUnconfirmed = metrics.NewGauge("weather_subscriptions_unconfirmed",
+		"Subscriptions waiting for their confirmation link to be used.")
+)
+
+// SubscriptionCounter counts subscriptions by state.
+type SubscriptionCounter interface {
+	Counts(ctx context.Context) (repository.SubscriptionCounts, error)
+}
+
+// SubscriptionStats keeps the subscription count gauges up to date.
+type SubscriptionStats struct {
+	repo   SubscriptionCounter
+	logger *zap.Logger
+}
+
+func NewSubscriptionStats(repo SubscriptionCounter, logger *zap.Logger) *SubscriptionStats {
+	return &SubscriptionStats{repo: repo, logger: logger}
+}
+
+// Run counts the subscriptions; the gauges keep their last values when it fails.
+func (s *SubscriptionStats) Run(ctx context.Context) {
+	counts, err := s.repo.Counts(ctx)
+	if err != nil {
+		s.logger.Warn("failed to refresh subscription counts", zap.Error(err))
+		return
+	}
+	subscriptionsConfirmed.Set(float64(counts.Confirmed))
+	subscriptionsUnconfirmed.Set(float64(counts.Unconfirmed))
+}
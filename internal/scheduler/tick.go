@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+)
+
+var (
+	ticksRun = metrics.NewCounter("weather_scheduler_ticks_total",
+		"Minute ticks run by this replica, caught-up ones included.")
+	ticksFailed = metrics.NewCounter("weather_scheduler_ticks_failed_total",
+		"Minute ticks with a batch that could not be read or queued: partly or not run.")
+	tickSelected = metrics.NewCounter("weather_scheduler_subscriptions_selected_total",
+		"Subscriptions due at the minute ticks run: hourly and daily updates, and alerts.")
+	tickDuration = metrics.NewGauge("weather_scheduler_tick_duration_seconds",
+		"How long the last minute tick took, its caught-up ticks included.")
+	tickLastSuccess = metrics.NewGauge("weather_scheduler_tick_last_success_timestamp_seconds",
+		"When the last minute tick finished with every batch read and queued.")
+)
+
+// TickReport counts what a minute tick did, for its metrics and the summary logged when
+// it finishes. Weather fetches and sends happen later, in the send workers, and are
+// counted there. A tick with a failed batch is reported as partly failed.
+type TickReport struct {
+	Slot     time.Time
+	Hourly   int   // hourly subscriptions due
+	Daily    int   // daily subscriptions due
+	Alerts   int   // alert subscriptions evaluated
+	Enqueued int64 // updates queued, those already queued aside
+	CaughtUp int   // missed ticks run before this one
+	failed   []string
+	start    time.Time
+}
+
+// StartTick starts the report of the tick at slot.
+func StartTick(slot time.Time) *TickReport {
+	return &TickReport{Slot: slot, start: time.Now()}
+}
+
+// Fail records that batch (e.g. "hourly") could not be read or queued; its error is
+// logged where it happened.
+func (r *TickReport) Fail(batch string) {
+	r.failed = append(r.failed, batch)
+}
+
+// Finish updates the metrics and logs the summary of the tick.
+func (r *TickReport) Finish(logger *zap.Logger) {
+	took := time.Since(r.start)
+	ticksRun.Add(float64(1 + r.CaughtUp))
+	tickSelected.Add(float64(r.Hourly + r.Daily + r.Alerts))
+	tickDuration.Set(took.Seconds())
+
+	fields := []zap.Field{zap.Time("slot", r.Slot), zap.Int("hourly", r.Hourly), zap.Int("daily", r.Daily),
+		zap.Int("alerts", r.Alerts), zap.Int64("enqueued", r.Enqueued), zap.Int("caught_up", r.CaughtUp),
+		zap.Duration("took", took)}
+	if len(r.failed) > 0 {
+		ticksFailed.Inc()
+		logger.Warn("scheduler tick partly failed", append(fields, zap.String("failed", strings.Join(r.failed, ",")))...)
+		return
+	}
+	tickLastSuccess.SetToTime(time.Now())
+	logger.Info("scheduler tick finished", fields...)
+}
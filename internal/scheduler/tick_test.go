@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTickReport_Finish(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	runs, failures := ticksRun.Value(), ticksFailed.Value()
+
+	ok := StartTick(time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC))
+	ok.Hourly, ok.Daily, ok.Enqueued = 3, 2, 5
+	ok.Finish(logger)
+
+	partial := StartTick(time.Date(2026, 10, 16, 8, 1, 0, 0, time.UTC))
+	partial.CaughtUp = 2
+	partial.Fail("daily")
+	partial.Finish(logger)
+
+	if got := ticksRun.Value() - runs; got != 4 {
+		t.Errorf("ticks run = %v, want 4 (two ticks, two caught up)", got)
+	}
+	if got := ticksFailed.Value() - failures; got != 1 {
+		t.Errorf("ticks failed = %v, want 1", got)
+	}
+	entries := logs.AllUntimed()
+	if len(entries) != 2 || entries[0].Level != zap.InfoLevel || entries[1].Level != zap.WarnLevel {
+		t.Fatalf("logged %v, want an info then a warning summary", entries)
+	}
+	if got := entries[1].ContextMap()["failed"]; got != "daily" {
+		t.Errorf("partial tick summary failed = %v, want daily", got)
+	}
+}
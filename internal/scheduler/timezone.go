@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var locations sync.Map // IANA name -> *time.Location
+
+// loadLocation returns the location of an IANA timezone name, loading it once.
+// Names are validated when subscriptions are saved; an unknown one falls back to UTC.
+func loadLocation(name string, logger *zap.Logger) *time.Location {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn("unknown subscription timezone, using UTC", zap.String("timezone", name), zap.Error(err))
+		loc = time.UTC
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+// localSlot returns the local wall-clock slot (hour*60+minute) of at in loc. It
+// returns false for the second occurrence of a wall-clock time repeated when DST ends,
+// so a daily subscription is not sent twice that day; a time skipped when DST starts
+// simply never comes up.
+func localSlot(at time.Time, loc *time.Location) (int, bool) {
+	local := at.In(loc)
+	hourAgo := at.Add(-time.Hour).In(loc)
+	if wallClock(hourAgo).After(wallClock(local).Add(-time.Hour)) {
+		return 0, false
+	}
+	return local.Hour()*60 + local.Minute(), true
+}
+
+// wallClock drops the zone of t, keeping its local date and time.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocalSlot(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Skipf("tz database unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		want   int
+		wantOK bool
+	}{
+		{"winter, UTC+2", time.Date(2026, 1, 15, 5, 0, 0, 0, time.UTC), 7 * 60, true},
+		{"summer, UTC+3", time.Date(2026, 7, 15, 4, 30, 0, 0, time.UTC), 7*60 + 30, true},
+		// 2026-10-25: 04:00 local goes back to 03:00, so 03:30 happens twice
+		{"repeated time, first", time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC), 3*60 + 30, true},
+		{"repeated time, second", time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC), 0, false},
+		{"after the repeat", time.Date(2026, 10, 25, 2, 30, 0, 0, time.UTC), 4*60 + 30, true},
+		// 2026-03-29: 03:00 local jumps to 04:00
+		{"right after the jump", time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC), 4 * 60, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := localSlot(tt.at, kyiv)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("localSlot(%s) = %d, %v; want %d, %v", tt.at, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
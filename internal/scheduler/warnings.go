@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+var warningsSent = metrics.NewCounter("weather_warnings_sent_total",
+	"Severe weather warnings emailed to warning subscriptions, one per warning and subscription.")
+
+// openEndedWarningTTL is how long a warning without an expiry is remembered as sent.
+const openEndedWarningTTL = 7 * 24 * time.Hour
+
+// WarningStore remembers the warnings emailed to warning subscriptions.
+type WarningStore interface {
+	Notified(ctx context.Context, ids []int) (map[int]map[string]bool, error)
+	MarkNotified(ctx context.Context, warnings []repository.NotifiedWarning) error
+}
+
+// WarningSource selects warning subscriptions and forgets expired warnings.
+type WarningSource interface {
+	WarningSubscriptions(ctx context.Context) ([]repository.Subscription, error)
+	DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// CheckWarnings emails the warning subscriptions of source their new warnings, then
+// forgets the warnings expired by now.
+func CheckWarnings(ctx context.Context, source WarningSource, d *Dispatcher, now time.Time, logger *zap.Logger) {
+	subs, err := source.WarningSubscriptions(ctx)
+	if err != nil {
+		logger.Error("failed to fetch warning subscriptions", zap.Error(err))
+		return
+	}
+	d.SendWarnings(ctx, subs, now)
+	if _, err := source.DeleteExpired(ctx, now); err != nil {
+		logger.Warn("failed to delete expired warnings", zap.Error(err))
+	}
+}
+
+// SendWarnings emails every subscription in subs the warnings in effect for its cities
+// that it was not sent yet, all of them in one email. The warnings feed is read once per
+// city. A warning that fails to send is sent again at the next check.
+func (d *Dispatcher) SendWarnings(ctx context.Context, subs []repository.Subscription, now time.Time) {
+	if len(subs) == 0 || d.warningFeed == nil {
+		return
+	}
+	ids := make([]int, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.ID
+	}
+	// without knowing what was sent, every warning in effect would be sent again
+	notified, err := d.warningStore.Notified(ctx, ids)
+	if err != nil {
+		d.logger.Error("failed to read notified warnings, skipping check", zap.Error(err))
+		return
+	}
+
+	var messages []email.EmailMessage
+	var records []repository.Delivery
+	pending := make(map[int][]repository.NotifiedWarning)
+	fetched := make(map[string][]types.Warning)
+	for _, sub := range subs {
+		warnings := d.newWarnings(ctx, sub, notified[sub.ID], now, fetched)
+		if len(warnings) == 0 {
+			continue
+		}
+		for _, w := range warnings {
+			expires := w.Warning.Expires
+			if expires.IsZero() {
+				expires = now.Add(openEndedWarningTTL)
+			}
+			pending[sub.ID] = append(pending[sub.ID], repository.NotifiedWarning{SubscriptionID: sub.ID,
+				WarningID: w.Warning.ID, ExpiresAt: expires})
+		}
+
+		rec := newDelivery(sub, now, nil)
+		unsubURL := unsubscribeURL(d.baseURL, d.links, sub)
+		body, err := email.Render(email.TemplateWarning, email.WarningData{
+			Locale:         sub.Locale,
+			Subscriber:     subscriberOf(sub, now),
+			Warnings:       warnings,
+			Timezone:       timezoneOf(sub),
+			ManageURL:      manageURL(d.baseURL, sub),
+			UnsubscribeURL: unsubURL,
+			OpenPixelURL:   d.openURL(sub, rec),
+			Attributions:   d.attributions.For(warnings[0].Warning.Provider),
+		})
+		if err != nil {
+			d.logger.Error("failed to render weather warning", zap.Error(err))
+			markFailed(&rec, err)
+			records = append(records, rec)
+			continue
+		}
+		messages = append(messages, email.EmailMessage{
+			To:             []string{sub.Email},
+			Subject:        i18n.T(sub.Locale, "warning.subject", warnings[0].City),
+			Body:           body,
+			UnsubscribeURL: unsubURL,
+		})
+		records = append(records, rec)
+	}
+
+	var sent []repository.NotifiedWarning
+	for _, r := range d.send(ctx, messages, records, true) {
+		if r.Succeeded() {
+			sent = append(sent, pending[int(r.SubscriptionID.Int64)]...)
+		}
+	}
+	if len(sent) == 0 {
+		return
+	}
+	warningsSent.Add(float64(len(sent)))
+	if err := d.warningStore.MarkNotified(ctx, sent); err != nil {
+		d.logger.Error("failed to record notified warnings", zap.Error(err))
+	}
+}
+
+// newWarnings returns the warnings in effect at now for the cities of sub that are not
+// in notified, each once. Warnings are fetched once per city and cached in fetched; a
+// city whose feed fails is checked again next time.
+func (d *Dispatcher) newWarnings(ctx context.Context, sub repository.Subscription, notified map[string]bool,
+	now time.Time, fetched map[string][]types.Warning,
+) []email.CityWarning {
+	loc := loadLocation(timezoneOf(sub), d.logger)
+	seen := make(map[string]bool)
+	var out []email.CityWarning
+	for _, city := range sub.AllCities() {
+		key := strings.ToLower(city)
+		warnings, ok := fetched[key]
+		if !ok {
+			var err error
+			warnings, err = d.warningFeed.FetchWarnings(ctx, city)
+			if err != nil {
+				d.logger.Warn("warnings fetch failed", zap.String("city", city), zap.Error(err))
+			}
+			fetched[key] = warnings
+		}
+		for _, w := range warnings {
+			if notified[w.ID] || seen[w.ID] || (!w.Expires.IsZero() && !w.Expires.After(now)) {
+				continue
+			}
+			seen[w.ID] = true
+			cw := email.CityWarning{City: city, Warning: w}
+			if !w.Expires.IsZero() {
+				cw.Until = w.Expires.In(loc).Format("2 Jan 15:04")
+			}
+			out = append(out, cw)
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// welcomeCatchUp bounds how far back missed confirmations are picked up after a reconnect.
+const welcomeCatchUp = time.Hour
+
+// WelcomeSender sends the welcome email as soon as a subscription is confirmed,
+// driven by subscription_confirmed notifications. The regular schedule is left untouched.
+type WelcomeSender struct {
+	repo       repository.SubscriptionRepository
+	dispatcher *Dispatcher
+	logger     *zap.Logger
+}
+
+func NewWelcomeSender(repo repository.SubscriptionRepository, dispatcher *Dispatcher, logger *zap.Logger) *WelcomeSender {
+	return &WelcomeSender{repo: repo, dispatcher: dispatcher, logger: logger}
+}
+
+// Connected implements repository.NotificationHandler: confirmations that happened
+// while we were not listening are sent now.
+func (w *WelcomeSender) Connected(ctx context.Context) {
+	ids, err := w.repo.PendingWelcomes(ctx, time.Now().Add(-welcomeCatchUp))
+	if err != nil {
+		w.logger.Error("failed to list pending welcome emails", zap.Error(err))
+		return
+	}
+	for _, id := range ids {
+		w.send(ctx, id)
+	}
+}
+
+// Disconnected implements repository.NotificationHandler.
+func (w *WelcomeSender) Disconnected(error) {}
+
+// Notify implements repository.NotificationHandler; the payload is the subscription id.
+func (w *WelcomeSender) Notify(ctx context.Context, n repository.Notification) {
+	id, err := strconv.Atoi(n.Payload)
+	if err != nil {
+		w.logger.Warn("invalid confirmation notification", zap.String("payload", n.Payload), zap.Error(err))
+		return
+	}
+	w.send(ctx, id)
+}
+
+func (w *WelcomeSender) send(ctx context.Context, id int) {
+	sub, err := w.repo.ClaimWelcome(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already sent (another replica), unsubscribed meanwhile, or unknown
+		return
+	}
+	if err != nil {
+		w.logger.Error("failed to claim welcome email", zap.Int("id", id), zap.Error(err))
+		return
+	}
+	w.dispatcher.SendWelcome(ctx, sub)
+}
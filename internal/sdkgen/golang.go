@@ -0,0 +1,294 @@
+package sdkgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// GenerateGo returns the source of a Go client package named pkg.
+func GenerateGo(s *Spec, pkg string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by sdkgen from api/openapi.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "// Package %s is a typed client for the %s, version %s.\n", pkg, s.Info.Title, s.Info.Version)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+
+	b.WriteString("import (\n\"bytes\"\n\"context\"\n\"encoding/json\"\n\"fmt\"\n\"io\"\n\"net/http\"\n\"net/url\"\n")
+	if s.UsesDateTime() {
+		b.WriteString("\"time\"\n")
+	}
+	b.WriteString(")\n\n")
+
+	for _, name := range s.SchemaNames() {
+		writeGoStruct(&b, name, s.Components.Schemas[name])
+	}
+	for _, e := range s.Endpoints() {
+		if params := e.QueryParams(); len(params) > 0 {
+			writeGoParams(&b, e, params)
+		}
+	}
+
+	fmt.Fprintf(&b, goClientPrelude, s.BasePath())
+	for _, e := range s.Endpoints() {
+		writeGoMethod(&b, e)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("sdkgen: generated Go does not compile: %w", err)
+	}
+	return src, nil
+}
+
+func writeGoStruct(b *bytes.Buffer, name string, sc *Schema) {
+	if sc.Description != "" {
+		fmt.Fprintf(b, "// %s is %s\n", name, lowerFirst(sc.Description))
+	}
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	for _, prop := range sc.PropertyNames() {
+		p := sc.Properties[prop]
+		required := sc.IsRequired(prop)
+		writeGoFieldComment(b, p)
+		tag := prop
+		if !required {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(b, "%s %s `json:%q`\n", exportedName(prop), goType(p, required), tag)
+	}
+	b.WriteString("}\n\n")
+}
+
+func writeGoParams(b *bytes.Buffer, e Endpoint, params []Parameter) {
+	name := exportedName(e.OperationID) + "Params"
+	fmt.Fprintf(b, "// %s holds the query parameters of %s.\n", name, exportedName(e.OperationID))
+	fmt.Fprintf(b, "type %s struct {\n", name)
+	for _, p := range params {
+		if p.Description != "" {
+			fmt.Fprintf(b, "// %s\n", p.Description)
+		}
+		fmt.Fprintf(b, "%s %s\n", exportedName(p.Name), goType(p.Schema, p.Required))
+	}
+	b.WriteString("}\n\n")
+}
+
+func writeGoMethod(b *bytes.Buffer, e Endpoint) {
+	name := exportedName(e.OperationID)
+	if e.Summary != "" {
+		fmt.Fprintf(b, "// %s %s\n//\n", name, lowerFirst(e.Summary))
+	}
+	fmt.Fprintf(b, "// %s %s\n", e.Method, e.Path)
+
+	args := []string{"ctx context.Context"}
+	for _, p := range e.PathParams() {
+		args = append(args, camelName(p.Name)+" string")
+	}
+	query := e.QueryParams()
+	if len(query) > 0 {
+		args = append(args, "params "+name+"Params")
+	}
+	body := e.BodySchema()
+	if body != nil {
+		args = append(args, "body "+goType(body, true))
+	}
+	result := e.ResultSchema()
+	if result != nil {
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), goType(result, false))
+	} else {
+		fmt.Fprintf(b, "func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+	}
+
+	queryArg := "nil"
+	if len(query) > 0 {
+		queryArg = "query"
+		b.WriteString("query := url.Values{}\n")
+		for _, p := range query {
+			field := "params." + exportedName(p.Name)
+			if !p.Required {
+				fmt.Fprintf(b, "if %s != nil {\n", field)
+			}
+			fmt.Fprintf(b, "query.Set(%q, %s)\n", p.Name, goQueryValue(p.Schema, field, !p.Required))
+			if !p.Required {
+				b.WriteString("}\n")
+			}
+		}
+	}
+	bodyArg := "nil"
+	if body != nil {
+		bodyArg = "body"
+	}
+
+	if result == nil {
+		fmt.Fprintf(b, "return c.do(ctx, %q, %s, %s, %s, nil)\n}\n\n", e.Method, goPathExpr(e.Path), queryArg, bodyArg)
+		return
+	}
+	fmt.Fprintf(b, "var out %s\n", goType(result, true))
+	fmt.Fprintf(b, "if err := c.do(ctx, %q, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\n",
+		e.Method, goPathExpr(e.Path), queryArg, bodyArg)
+	b.WriteString("return &out, nil\n}\n\n")
+}
+
+// goType returns the Go type of sc; optional scalars and objects become pointers.
+func goType(sc *Schema, required bool) string {
+	var t string
+	switch {
+	case sc.Ref != "":
+		t = sc.RefName()
+	case sc.Type == "array":
+		return "[]" + goType(sc.Items, true)
+	case sc.Type == "object":
+		return "map[string]any"
+	case sc.Type == "string" && sc.Format == "date-time":
+		t = "time.Time"
+	case sc.Type == "string":
+		t = "string"
+	case sc.Type == "integer":
+		t = "int"
+	case sc.Type == "number":
+		t = "float64"
+	case sc.Type == "boolean":
+		t = "bool"
+	}
+	if !required {
+		return "*" + t
+	}
+	return t
+}
+
+// goQueryValue formats field as a query string value; pointer fields are dereferenced.
+func goQueryValue(sc *Schema, field string, pointer bool) string {
+	if sc.Type == "string" && sc.Format == "date-time" {
+		return field + ".Format(time.RFC3339)"
+	}
+	value := field
+	if pointer {
+		value = "*" + field
+	}
+	switch {
+	case sc.Type == "string":
+		return value
+	default:
+		return "fmt.Sprint(" + value + ")"
+	}
+}
+
+// goPathExpr turns /confirm/{token} into "/confirm/" + url.PathEscape(token).
+func goPathExpr(path string) string {
+	var parts []string
+	literal := ""
+	for i, seg := range strings.Split(path, "/") {
+		if i > 0 {
+			literal += "/"
+		}
+		if name, ok := pathParamName(seg); ok {
+			parts = append(parts, fmt.Sprintf("%q", literal), "url.PathEscape("+camelName(name)+")")
+			literal = ""
+			continue
+		}
+		literal += seg
+	}
+	if literal != "" {
+		parts = append(parts, fmt.Sprintf("%q", literal))
+	}
+	return strings.Join(parts, " + ")
+}
+
+func writeGoFieldComment(b *bytes.Buffer, sc *Schema) {
+	var notes []string
+	if sc.Description != "" {
+		notes = append(notes, sc.Description)
+	}
+	if len(sc.Enum) > 0 {
+		notes = append(notes, "One of: "+strings.Join(sc.Enum, ", ")+".")
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(b, "// %s\n", strings.Join(notes, " "))
+	}
+}
+
+// lowerFirst makes a sentence follow an identifier: "Returns x." -> "returns x.".
+// Sentences starting with an acronym or a quote are left alone.
+func lowerFirst(s string) string {
+	if len(s) > 1 && s[0] >= 'A' && s[0] <= 'Z' && !(s[1] >= 'A' && s[1] <= 'Z') {
+		return strings.ToLower(s[:1]) + s[1:]
+	}
+	return s
+}
+
+const goClientPrelude = `// basePath is the path of the API on the server.
+const basePath = %q
+
+// Client calls the API. The zero value is not usable; create one with NewClient.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the server at baseURL, e.g. "https://weather.example.com".
+// A nil httpClient means http.DefaultClient.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: baseURL, httpClient: httpClient}
+}
+
+// APIError is returned for responses with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Message    string // the "error" field of the response, when present
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("weather api: %%s", http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("weather api: %%s: %%s", http.StatusText(e.StatusCode), e.Message)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := c.baseURL + basePath + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var e struct {
+			Error string ` + "`json:\"error\"`" + `
+		}
+		if data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10)); err == nil && json.Unmarshal(data, &e) == nil {
+			apiErr.Message = e.Error
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+`
@@ -0,0 +1,78 @@
+package sdkgen
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The clients under clients/ are checked in; they must match the OpenAPI document.
+func TestGeneratedClientsAreUpToDate(t *testing.T) {
+	spec, err := Load("../../api/openapi.json")
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	goSrc, err := GenerateGo(spec, "weatherclient")
+	if err != nil {
+		t.Fatalf("GenerateGo() error: %v", err)
+	}
+	checkGenerated(t, "../../clients/weatherclient/client.gen.go", goSrc)
+	checkGenerated(t, "../../clients/typescript/src/client.gen.ts", GenerateTypeScript(spec))
+}
+
+func checkGenerated(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date with api/openapi.json; run go generate ./clients", path)
+	}
+}
+
+func TestParse_RejectsUnsupportedDocuments(t *testing.T) {
+	cases := map[string]string{
+		"unresolved ref": `{"paths": {"/x": {"get": {"operationId": "x", "responses": {"200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Missing"}}}}}}}}}`,
+		"no operationId": `{"paths": {"/x": {"get": {"responses": {}}}}}`,
+		"unbound path":   `{"paths": {"/x/{id}": {"get": {"operationId": "x", "responses": {}}}}}`,
+		"header param":   `{"paths": {"/x": {"get": {"operationId": "x", "parameters": [{"name": "h", "in": "header", "schema": {"type": "string"}}]}}}}`,
+	}
+	for name, doc := range cases {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: Parse() accepted the document", name)
+		}
+	}
+}
+
+func TestGenerateGo_PathAndQueryParameters(t *testing.T) {
+	spec, err := Parse([]byte(`{
+		"servers": [{"url": "/api"}],
+		"paths": {"/items/{item_id}": {"get": {
+			"operationId": "getItem",
+			"parameters": [
+				{"name": "item_id", "in": "path", "required": true, "schema": {"type": "string"}},
+				{"name": "limit", "in": "query", "schema": {"type": "integer"}}
+			],
+			"responses": {"204": {}}
+		}}}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	src, err := GenerateGo(spec, "client")
+	if err != nil {
+		t.Fatalf("GenerateGo() error: %v", err)
+	}
+	for _, want := range []string{
+		"func (c *Client) GetItem(ctx context.Context, itemID string, params GetItemParams) error",
+		`"/items/"+url.PathEscape(itemID)`,
+		`query.Set("limit", fmt.Sprint(*params.Limit))`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated client lacks %q:\n%s", want, src)
+		}
+	}
+}
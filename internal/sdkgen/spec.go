@@ -0,0 +1,368 @@
+// Package sdkgen generates the Go and TypeScript API clients under clients/ from the
+// OpenAPI document in api/. It understands the subset of OpenAPI 3 the document uses:
+// JSON request and response bodies, path and query parameters, and object schemas
+// built from scalars, arrays and references to other schemas.
+package sdkgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Spec is the part of an OpenAPI 3 document the generators use.
+type Spec struct {
+	Info struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Operation is one method of a path.
+type Operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []Parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema: a reference, a scalar, an array or an object.
+type Schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Format      string             `json:"format"`
+	Description string             `json:"description"`
+	Enum        []string           `json:"enum"`
+	Items       *Schema            `json:"items"`
+	Properties  map[string]*Schema `json:"properties"`
+	Required    []string           `json:"required"`
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// RefName returns the schema name a reference points to.
+func (s *Schema) RefName() string {
+	return strings.TrimPrefix(s.Ref, schemaRefPrefix)
+}
+
+// IsRequired reports whether property name of object s is required.
+func (s *Schema) IsRequired(name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PropertyNames returns the property names of s in alphabetical order.
+func (s *Schema) PropertyNames() []string {
+	return sortedKeys(s.Properties)
+}
+
+// Endpoint is an operation together with its method and path.
+type Endpoint struct {
+	Method string // upper case
+	Path   string // relative to the server URL, e.g. /confirm/{token}
+	*Operation
+}
+
+// PathParams returns the path parameters in the order they appear in the path.
+func (e Endpoint) PathParams() []Parameter {
+	var params []Parameter
+	for _, seg := range strings.Split(e.Path, "/") {
+		if name, ok := pathParamName(seg); ok {
+			for _, p := range e.Parameters {
+				if p.In == "path" && p.Name == name {
+					params = append(params, p)
+				}
+			}
+		}
+	}
+	return params
+}
+
+// QueryParams returns the query parameters in the order they are declared.
+func (e Endpoint) QueryParams() []Parameter {
+	var params []Parameter
+	for _, p := range e.Parameters {
+		if p.In == "query" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// BodySchema returns the JSON request body schema, or nil when there is no body.
+func (e Endpoint) BodySchema() *Schema {
+	if e.RequestBody == nil {
+		return nil
+	}
+	return e.RequestBody.Content["application/json"].Schema
+}
+
+// ResultSchema returns the JSON schema of the first 2xx response, or nil when the
+// operation returns no body.
+func (e Endpoint) ResultSchema() *Schema {
+	for _, code := range sortedKeys(e.Responses) {
+		if strings.HasPrefix(code, "2") {
+			return e.Responses[code].Content["application/json"].Schema
+		}
+	}
+	return nil
+}
+
+// Load reads and checks an OpenAPI document.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes and checks an OpenAPI document.
+func Parse(data []byte) (*Spec, error) {
+	var s Spec
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("sdkgen: invalid OpenAPI document: %w", err)
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// BasePath is the path of the first server, e.g. /api.
+func (s *Spec) BasePath() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(s.Servers[0].URL, "/")
+}
+
+// SchemaNames returns the component schema names in alphabetical order.
+func (s *Spec) SchemaNames() []string {
+	return sortedKeys(s.Components.Schemas)
+}
+
+// Endpoints returns every operation, ordered by operation id.
+func (s *Spec) Endpoints() []Endpoint {
+	var endpoints []Endpoint
+	for path, item := range s.Paths {
+		for method, op := range item {
+			endpoints = append(endpoints, Endpoint{Method: strings.ToUpper(method), Path: path, Operation: op})
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].OperationID < endpoints[j].OperationID })
+	return endpoints
+}
+
+// UsesDateTime reports whether any schema or parameter is a date-time.
+func (s *Spec) UsesDateTime() bool {
+	var walk func(*Schema) bool
+	walk = func(sc *Schema) bool {
+		if sc == nil {
+			return false
+		}
+		if sc.Format == "date-time" || walk(sc.Items) {
+			return true
+		}
+		for _, p := range sc.Properties {
+			if walk(p) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, sc := range s.Components.Schemas {
+		if walk(sc) {
+			return true
+		}
+	}
+	for _, e := range s.Endpoints() {
+		for _, p := range e.Parameters {
+			if walk(p.Schema) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// check rejects documents the generators cannot represent faithfully.
+func (s *Spec) check() error {
+	seen := map[string]bool{}
+	for _, e := range s.Endpoints() {
+		where := e.Method + " " + e.Path
+		switch e.Method {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return fmt.Errorf("sdkgen: %s: unsupported method", where)
+		}
+		if e.OperationID == "" {
+			return fmt.Errorf("sdkgen: %s: missing operationId", where)
+		}
+		if seen[e.OperationID] {
+			return fmt.Errorf("sdkgen: duplicate operationId %q", e.OperationID)
+		}
+		seen[e.OperationID] = true
+
+		for _, p := range e.Parameters {
+			if p.In != "path" && p.In != "query" {
+				return fmt.Errorf("sdkgen: %s: parameter %q: unsupported location %q", where, p.Name, p.In)
+			}
+			if err := s.checkSchema(p.Schema); err != nil {
+				return fmt.Errorf("sdkgen: %s: parameter %q: %w", where, p.Name, err)
+			}
+			if p.Schema.Ref != "" || p.Schema.Type == "object" || p.Schema.Type == "array" {
+				return fmt.Errorf("sdkgen: %s: parameter %q must be a scalar", where, p.Name)
+			}
+		}
+		if len(e.PathParams()) != strings.Count(e.Path, "{") {
+			return fmt.Errorf("sdkgen: %s: every path segment in braces needs a path parameter", where)
+		}
+		if e.RequestBody != nil {
+			if err := s.checkSchema(e.BodySchema()); err != nil {
+				return fmt.Errorf("sdkgen: %s: request body: %w", where, err)
+			}
+		}
+		if res := e.ResultSchema(); res != nil {
+			if err := s.checkSchema(res); err != nil {
+				return fmt.Errorf("sdkgen: %s: response: %w", where, err)
+			}
+		}
+	}
+	for _, name := range s.SchemaNames() {
+		if err := s.checkSchema(s.Components.Schemas[name]); err != nil {
+			return fmt.Errorf("sdkgen: schema %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Spec) checkSchema(sc *Schema) error {
+	switch {
+	case sc == nil:
+		return fmt.Errorf("missing schema")
+	case sc.Ref != "":
+		if _, ok := s.Components.Schemas[sc.RefName()]; !strings.HasPrefix(sc.Ref, schemaRefPrefix) || !ok {
+			return fmt.Errorf("unresolved reference %q", sc.Ref)
+		}
+		return nil
+	}
+	switch sc.Type {
+	case "string", "integer", "number", "boolean":
+		return nil
+	case "array":
+		return s.checkSchema(sc.Items)
+	case "object":
+		for _, name := range sc.PropertyNames() {
+			if err := s.checkSchema(sc.Properties[name]); err != nil {
+				return fmt.Errorf("property %q: %w", name, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %q", sc.Type)
+	}
+}
+
+func pathParamName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// commonInitialisms are kept upper case in Go identifiers.
+var commonInitialisms = map[string]bool{"api": true, "id": true, "url": true, "http": true}
+
+// exportedName turns a JSON or operation name ("send_hour", "getWeather") into an
+// exported Go identifier ("SendHour", "GetWeather").
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, word := range splitWords(name) {
+		if commonInitialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+// camelName turns a JSON name ("item_id") into a lower camel case identifier ("itemID").
+func camelName(name string) string {
+	words := splitWords(name)
+	if len(words) == 0 {
+		return name
+	}
+	r := []rune(words[0])
+	r[0] = unicode.ToLower(r[0])
+	return string(r) + exportedName(strings.Join(words[1:], "_"))
+}
+
+// splitWords splits snake_case, kebab-case and camelCase names into words.
+func splitWords(name string) []string {
+	var words []string
+	var cur []rune
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			if len(cur) > 0 {
+				words = append(words, string(cur))
+			}
+			cur = nil
+		case unicode.IsUpper(r) && len(cur) > 0:
+			words = append(words, string(cur))
+			cur = []rune{r}
+		default:
+			cur = append(cur, r)
+		}
+	}
+	if len(cur) > 0 {
+		words = append(words, string(cur))
+	}
+	return words
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,229 @@
+package sdkgen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// GenerateTypeScript returns the source of a TypeScript client module built on fetch.
+func GenerateTypeScript(s *Spec) []byte {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by sdkgen from api/openapi.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "/** Typed client for the %s, version %s. */\n\n", s.Info.Title, s.Info.Version)
+
+	for _, name := range s.SchemaNames() {
+		writeTSInterface(&b, name, s.Components.Schemas[name])
+	}
+	for _, e := range s.Endpoints() {
+		if params := e.QueryParams(); len(params) > 0 {
+			fmt.Fprintf(&b, "/** Query parameters of %s. */\n", e.OperationID)
+			fmt.Fprintf(&b, "export interface %sParams {\n", exportedName(e.OperationID))
+			for _, p := range params {
+				writeTSDoc(&b, "  ", p.Description)
+				fmt.Fprintf(&b, "  %s%s: %s;\n", p.Name, tsOptional(p.Required), tsType(p.Schema))
+			}
+			b.WriteString("}\n\n")
+		}
+	}
+
+	fmt.Fprintf(&b, tsClientPrelude, s.BasePath())
+	for _, e := range s.Endpoints() {
+		writeTSMethod(&b, e)
+	}
+	b.WriteString(tsClientRequest)
+	return b.Bytes()
+}
+
+func writeTSInterface(b *bytes.Buffer, name string, sc *Schema) {
+	writeTSDoc(b, "", sc.Description)
+	fmt.Fprintf(b, "export interface %s {\n", name)
+	for _, prop := range sc.PropertyNames() {
+		p := sc.Properties[prop]
+		writeTSDoc(b, "  ", p.Description)
+		fmt.Fprintf(b, "  %s%s: %s;\n", prop, tsOptional(sc.IsRequired(prop)), tsType(p))
+	}
+	b.WriteString("}\n\n")
+}
+
+func writeTSMethod(b *bytes.Buffer, e Endpoint) {
+	writeTSDoc(b, "  ", strings.TrimSpace(e.Summary+" "+e.Method+" "+e.Path))
+
+	var args []string
+	for _, p := range e.PathParams() {
+		args = append(args, camelName(p.Name)+": string")
+	}
+	query := e.QueryParams()
+	if len(query) > 0 {
+		optional := ""
+		if !anyRequired(query) {
+			optional = "?"
+		}
+		args = append(args, fmt.Sprintf("params%s: %sParams", optional, exportedName(e.OperationID)))
+	}
+	body := e.BodySchema()
+	if body != nil {
+		args = append(args, "body: "+tsType(body))
+	}
+	args = append(args, "init?: RequestInit")
+
+	result := "void"
+	if res := e.ResultSchema(); res != nil {
+		result = tsType(res)
+	}
+	fmt.Fprintf(b, "  %s(%s): Promise<%s> {\n", e.OperationID, strings.Join(args, ", "), result)
+
+	queryArg := "undefined"
+	if len(query) > 0 {
+		var fields []string
+		for _, p := range query {
+			fields = append(fields, fmt.Sprintf("%s: params?.%s", p.Name, p.Name))
+		}
+		queryArg = "{ " + strings.Join(fields, ", ") + " }"
+	}
+	bodyArg := "undefined"
+	if body != nil {
+		bodyArg = "body"
+	}
+	fmt.Fprintf(b, "    return this.request<%s>(%q, %s, %s, %s, init);\n  }\n\n", result, e.Method, tsPathExpr(e.Path), queryArg, bodyArg)
+}
+
+func tsType(sc *Schema) string {
+	switch {
+	case sc.Ref != "":
+		return sc.RefName()
+	case sc.Type == "array":
+		return tsType(sc.Items) + "[]"
+	case sc.Type == "object":
+		return "Record<string, unknown>"
+	case sc.Type == "string" && len(sc.Enum) > 0:
+		quoted := make([]string, len(sc.Enum))
+		for i, v := range sc.Enum {
+			quoted[i] = fmt.Sprintf("%q", v)
+		}
+		return strings.Join(quoted, " | ")
+	case sc.Type == "integer", sc.Type == "number":
+		return "number"
+	default:
+		// date-times stay RFC 3339 strings, as they are in JSON
+		return sc.Type
+	}
+}
+
+func tsOptional(required bool) string {
+	if required {
+		return ""
+	}
+	return "?"
+}
+
+// tsPathExpr turns /confirm/{token} into `/confirm/${encodeURIComponent(token)}`.
+func tsPathExpr(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if name, ok := pathParamName(seg); ok {
+			segs[i] = "${encodeURIComponent(" + camelName(name) + ")}"
+		}
+	}
+	return "`" + strings.Join(segs, "/") + "`"
+}
+
+func writeTSDoc(b *bytes.Buffer, indent, text string) {
+	if text != "" {
+		fmt.Fprintf(b, "%s/** %s */\n", indent, text)
+	}
+}
+
+func anyRequired(params []Parameter) bool {
+	for _, p := range params {
+		if p.Required {
+			return true
+		}
+	}
+	return false
+}
+
+const tsClientPrelude = `/** Thrown for responses with a non-2xx status. */
+export class APIError extends Error {
+  constructor(
+    readonly status: number,
+    message: string,
+  ) {
+    super(message);
+    this.name = "APIError";
+  }
+}
+
+export interface ClientOptions {
+  /** Server URL, e.g. "https://weather.example.com"; empty for the same origin. */
+  baseUrl?: string;
+  /** fetch implementation; defaults to the global fetch. */
+  fetch?: typeof fetch;
+}
+
+/** Base path of the API on the server. */
+const basePath = %q;
+
+export class Client {
+  private readonly baseUrl: string;
+  private readonly fetchImpl: typeof fetch;
+
+  constructor(options: ClientOptions = {}) {
+    this.baseUrl = options.baseUrl ?? "";
+    this.fetchImpl = options.fetch ?? ((input, init) => fetch(input, init));
+  }
+
+`
+
+const tsClientRequest = `  private async request<T>(
+    method: string,
+    path: string,
+    query: Record<string, string | number | boolean | undefined> | undefined,
+    body: unknown,
+    init: RequestInit | undefined,
+  ): Promise<T> {
+    let url = this.baseUrl + basePath + path;
+    if (query) {
+      const search = new URLSearchParams();
+      for (const [key, value] of Object.entries(query)) {
+        if (value !== undefined) {
+          search.set(key, String(value));
+        }
+      }
+      const qs = search.toString();
+      if (qs) {
+        url += "?" + qs;
+      }
+    }
+
+    const headers = new Headers(init?.headers);
+    headers.set("Accept", "application/json");
+    if (body !== undefined) {
+      headers.set("Content-Type", "application/json");
+    }
+    const resp = await this.fetchImpl(url, {
+      ...init,
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+
+    if (!resp.ok) {
+      let message = resp.statusText;
+      try {
+        const data = await resp.json();
+        if (data && typeof data.error === "string") {
+          message = data.error;
+        }
+      } catch {
+        // not a JSON error body
+      }
+      throw new APIError(resp.status, message);
+    }
+    if (resp.status === 204) {
+      return undefined as T;
+    }
+    return (await resp.json()) as T;
+  }
+}
+`
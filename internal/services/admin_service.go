@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// MaxPageSize caps admin listing pages.
+const MaxPageSize = 200
+
+// exportBatchSize is how many subscriptions ExportSubscriptions reads at a time.
+const exportBatchSize = 1000
+
+// returned when an admin operation targets a missing subscription
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// returned when a city merge names an empty spelling or the same spelling twice
+var ErrInvalidCityMerge = errors.New("from and to must be two different, non-empty city spellings")
+
+// Page is one page of a paginated listing.
+type Page struct {
+	Items    []repository.Subscription
+	Total    int
+	Page     int
+	PageSize int
+}
+
+// AdminService defines the operator operations on subscriptions.
+type AdminService interface {
+	ListSubscriptions(ctx context.Context, filter repository.SubscriptionFilter, page, pageSize int) (Page, error)
+	ExportSubscriptions(ctx context.Context, filter repository.SubscriptionFilter, fn func([]repository.Subscription) error) error
+	FindByEmail(ctx context.Context, emailAddr string) ([]repository.Subscription, error)
+	DeleteSubscription(ctx context.Context, id int) error
+	ReviveSubscription(ctx context.Context, id int) error
+	SubscriptionHistory(ctx context.Context, id int) ([]repository.SubscriptionEvent, error)
+	ImportSuppressions(ctx context.Context, emails []string, reason, source string) (ImportResult, error)
+	MergeCities(ctx context.Context, from, to string, dryRun bool) (repository.CityMergeResult, error)
+}
+
+// CityCache forgets cached data of a merged city spelling.
+type CityCache interface {
+	MoveCity(ctx context.Context, from, to string) error
+}
+
+// ImportResult summarizes a suppression list import.
+type ImportResult struct {
+	Received int
+	Added    int64
+	Invalid  []string
+}
+
+type adminService struct {
+	repo         repository.SubscriptionRepository
+	suppressions repository.SuppressionRepository
+	deadLetters  repository.DeadLetterRepository
+	history      repository.SubscriptionEventRepository
+	cityCache    CityCache // nil: nothing cached per city
+	logger       *zap.Logger
+}
+
+// NewAdminService wires up admin service dependencies.
+func NewAdminService(
+	repo repository.SubscriptionRepository,
+	suppressions repository.SuppressionRepository,
+	deadLetters repository.DeadLetterRepository,
+	history repository.SubscriptionEventRepository,
+	cityCache CityCache,
+	logger *zap.Logger,
+) AdminService {
+	return &adminService{repo: repo, suppressions: suppressions, deadLetters: deadLetters, history: history,
+		cityCache: cityCache, logger: logger}
+}
+
+// ListSubscriptions returns the requested 1-based page, clamping page and pageSize to sane bounds.
+func (s *adminService) ListSubscriptions(ctx context.Context, filter repository.SubscriptionFilter, page, pageSize int) (Page, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	subs, total, err := s.repo.List(ctx, filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return Page{}, fmt.Errorf("repo.List: %w", err)
+	}
+	return Page{Items: subs, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// ExportSubscriptions calls fn with every subscription matching filter, by id, one
+// batch at a time, so an export of any size holds a single batch in memory. It stops
+// at the first error of fn.
+func (s *adminService) ExportSubscriptions(ctx context.Context, filter repository.SubscriptionFilter,
+	fn func([]repository.Subscription) error,
+) error {
+	for cursor := 0; ; {
+		subs, next, err := s.repo.ListAfter(ctx, filter, cursor, exportBatchSize)
+		if err != nil {
+			return fmt.Errorf("repo.ListAfter: %w", err)
+		}
+		if len(subs) > 0 {
+			if err := fn(subs); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// FindByEmail returns every subscription of an address (case-insensitive).
+func (s *adminService) FindByEmail(ctx context.Context, emailAddr string) ([]repository.Subscription, error) {
+	subs, err := s.repo.ListByEmail(ctx, emailAddr)
+	if err != nil {
+		return nil, fmt.Errorf("repo.ListByEmail: %w", err)
+	}
+	if len(subs) == 0 {
+		return nil, ErrSubscriptionNotFound
+	}
+	return subs, nil
+}
+
+// DeleteSubscription removes a subscription by id.
+func (s *adminService) DeleteSubscription(ctx context.Context, id int) error {
+	if err := s.repo.DeleteByID(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSubscriptionNotFound
+		}
+		return fmt.Errorf("repo.DeleteByID: %w", err)
+	}
+	s.logger.Info("subscription deleted by admin", zap.Int("id", id))
+	return nil
+}
+
+// ReviveSubscription resumes the sends of a dead-lettered subscription, e.g. once its
+// address or webhook works again.
+func (s *adminService) ReviveSubscription(ctx context.Context, id int) error {
+	if err := s.deadLetters.Revive(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSubscriptionNotFound
+		}
+		return fmt.Errorf("deadLetters.Revive: %w", err)
+	}
+	s.logger.Info("dead-lettered subscription revived by admin", zap.Int("id", id))
+	return nil
+}
+
+// SubscriptionHistory returns the state transitions of a subscription, oldest first:
+// why it stopped getting emails, for instance. It returns ErrSubscriptionNotFound when
+// there are none, the subscription being gone or never existing.
+func (s *adminService) SubscriptionHistory(ctx context.Context, id int) ([]repository.SubscriptionEvent, error) {
+	events, err := s.history.ListEvents(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("history.ListEvents: %w", err)
+	}
+	if len(events) == 0 {
+		return nil, ErrSubscriptionNotFound
+	}
+	return events, nil
+}
+
+// ImportSuppressions adds addresses to the suppression list. Addresses are trimmed and
+// lower-cased; unparsable ones are skipped and reported back instead of failing the import.
+func (s *adminService) ImportSuppressions(ctx context.Context, emails []string, reason, source string) (ImportResult, error) {
+	result := ImportResult{Received: len(emails)}
+	seen := make(map[string]bool, len(emails))
+	var rows []repository.Suppression
+	for _, raw := range emails {
+		addr := strings.ToLower(strings.TrimSpace(raw))
+		if addr == "" {
+			continue
+		}
+		if parsed, err := mail.ParseAddress(addr); err != nil || parsed.Address != addr {
+			result.Invalid = append(result.Invalid, raw)
+			continue
+		}
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		rows = append(rows, repository.Suppression{Email: addr, Reason: reason, Source: source})
+	}
+
+	added, err := s.suppressions.Import(ctx, rows)
+	result.Added = added
+	if err != nil {
+		return result, fmt.Errorf("suppressions.Import: %w", err)
+	}
+	s.logger.Info("suppression list imported",
+		zap.Int("received", result.Received), zap.Int64("added", added), zap.Int("invalid", len(result.Invalid)))
+	return result, nil
+}
+
+// MergeCities merges the city spelling from into the canonical spelling to, e.g. "NYC"
+// into "New York", and forgets the cache of from. With dryRun nothing changes and the
+// result previews what the merge would do.
+func (s *adminService) MergeCities(ctx context.Context, from, to string, dryRun bool,
+) (repository.CityMergeResult, error) {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" || to == "" || from == to {
+		return repository.CityMergeResult{}, ErrInvalidCityMerge
+	}
+
+	res, err := s.repo.MergeCity(ctx, from, to, dryRun)
+	if err != nil {
+		return repository.CityMergeResult{}, fmt.Errorf("repo.MergeCity: %w", err)
+	}
+	if dryRun || s.cityCache == nil {
+		return res, nil
+	}
+	if err := s.cityCache.MoveCity(ctx, from, to); err != nil {
+		// the merge is committed; stale entries of from expire with their TTL
+		s.logger.Warn("failed to forget cached weather of merged city", zap.String("city", from), zap.Error(err))
+	}
+	return res, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/bounces"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// BounceService suppresses the addresses behind the bounce and complaint notifications
+// of email providers, protecting the sender reputation.
+type BounceService interface {
+	Record(ctx context.Context, provider string, events []bounces.Event) (BounceResult, error)
+}
+
+// BounceResult summarizes a notification.
+type BounceResult struct {
+	Suppressed int64 // permanently, by hard bounces and complaints
+	Deferred   int   // temporarily, by soft bounces
+}
+
+type bounceService struct {
+	suppressions repository.SuppressionRepository
+	history      repository.SubscriptionEventRepository
+	softFor      time.Duration
+	logger       *zap.Logger
+}
+
+// NewBounceService wires up bounce service dependencies. Soft bounces suppress the
+// address for softFor (BOUNCE_SOFT_SUPPRESSION).
+func NewBounceService(suppressions repository.SuppressionRepository, history repository.SubscriptionEventRepository,
+	softFor time.Duration, logger *zap.Logger,
+) BounceService {
+	return &bounceService{suppressions: suppressions, history: history, softFor: softFor, logger: logger}
+}
+
+// Record suppresses hard-bounced and complaining addresses for good, and soft-bounced
+// ones for a while. Each event goes into the history of the subscriptions of its address.
+func (s *bounceService) Record(ctx context.Context, provider string, events []bounces.Event) (BounceResult, error) {
+	var result BounceResult
+	source := "webhook:" + provider
+	seen := make(map[string]bool, len(events))
+	var permanent []repository.Suppression
+	for _, e := range events {
+		s.recordHistory(ctx, source, e)
+		if !e.Permanent {
+			if err := s.suppressions.SuppressFor(ctx, e.Email, repository.SuppressionReasonBounce, source, s.softFor); err != nil {
+				return result, fmt.Errorf("suppressions.SuppressFor: %w", err)
+			}
+			result.Deferred++
+			continue
+		}
+		if seen[e.Email] {
+			continue
+		}
+		seen[e.Email] = true
+		reason := repository.SuppressionReasonBounce
+		if e.Kind == bounces.KindComplaint {
+			reason = repository.SuppressionReasonComplaint
+		}
+		permanent = append(permanent, repository.Suppression{Email: e.Email, Reason: reason, Source: source})
+	}
+
+	if len(permanent) > 0 {
+		added, err := s.suppressions.Import(ctx, permanent)
+		result.Suppressed = added
+		if err != nil {
+			return result, fmt.Errorf("suppressions.Import: %w", err)
+		}
+	}
+	s.logger.Info("bounce notification processed", zap.String("provider", provider),
+		zap.Int("events", len(events)), zap.Int64("suppressed", result.Suppressed), zap.Int("deferred", result.Deferred))
+	return result, nil
+}
+
+// recordHistory adds e to the history of the subscriptions of its address. The history
+// is for support only: a failure is logged rather than returned.
+func (s *bounceService) recordHistory(ctx context.Context, source string, e bounces.Event) {
+	detail := "soft bounce"
+	switch {
+	case e.Kind == bounces.KindComplaint:
+		detail = "complaint"
+	case e.Permanent:
+		detail = "hard bounce"
+	}
+	if _, err := s.history.RecordByEmail(ctx, e.Email, repository.SubscriptionEventBounced, detail+" ("+source+")"); err != nil {
+		s.logger.Warn("failed to record bounce in subscription history", zap.Error(err))
+	}
+}
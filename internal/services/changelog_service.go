@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+var (
+	// returned when an announced API change is incomplete or inconsistent
+	ErrInvalidAPIChange = errors.New("invalid api change")
+
+	// returned when an admin operation targets a missing changelog entry
+	ErrAPIChangeNotFound = errors.New("api change not found")
+)
+
+// ChangelogService publishes notices of API changes and deprecations.
+type ChangelogService interface {
+	List(ctx context.Context, since time.Time) ([]repository.APIChange, error)
+	Announce(ctx context.Context, c repository.APIChange) (repository.APIChange, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type changelogService struct {
+	repo   repository.ChangelogRepository
+	logger *zap.Logger
+}
+
+// NewChangelogService wires up changelog service dependencies.
+func NewChangelogService(repo repository.ChangelogRepository, logger *zap.Logger) ChangelogService {
+	return &changelogService{repo: repo, logger: logger}
+}
+
+// List returns the changes announced after since (all when zero), newest first.
+func (s *changelogService) List(ctx context.Context, since time.Time) ([]repository.APIChange, error) {
+	changes, err := s.repo.List(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("repo.List: %w", err)
+	}
+	return changes, nil
+}
+
+// Announce validates and stores a changelog entry. Deprecations must name the route
+// (as registered, e.g. /api/manage/:token), and a sunset must follow the announcement.
+func (s *changelogService) Announce(ctx context.Context, c repository.APIChange) (repository.APIChange, error) {
+	switch c.Kind {
+	case repository.APIChangeAdded, repository.APIChangeChanged, repository.APIChangeDeprecated, repository.APIChangeRemoved:
+	default:
+		return repository.APIChange{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidAPIChange, c.Kind)
+	}
+	if strings.TrimSpace(c.Title) == "" {
+		return repository.APIChange{}, fmt.Errorf("%w: title is required", ErrInvalidAPIChange)
+	}
+	if c.Path.Valid && !strings.HasPrefix(c.Path.String, "/api/") {
+		return repository.APIChange{}, fmt.Errorf("%w: path must be a route under /api/", ErrInvalidAPIChange)
+	}
+	if c.Kind == repository.APIChangeDeprecated && !c.Path.Valid {
+		return repository.APIChange{}, fmt.Errorf("%w: a deprecation needs the route path", ErrInvalidAPIChange)
+	}
+	c.Method.String = strings.ToUpper(c.Method.String)
+
+	announcedAt := c.AnnouncedAt
+	if announcedAt.IsZero() {
+		announcedAt = time.Now()
+	}
+	if c.SunsetAt.Valid && !c.SunsetAt.Time.After(announcedAt) {
+		return repository.APIChange{}, fmt.Errorf("%w: sunset must be after the announcement", ErrInvalidAPIChange)
+	}
+
+	created, err := s.repo.Create(ctx, c)
+	if err != nil {
+		return repository.APIChange{}, fmt.Errorf("repo.Create: %w", err)
+	}
+	return created, nil
+}
+
+// Delete removes a changelog entry by id.
+func (s *changelogService) Delete(ctx context.Context, id int) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAPIChangeNotFound
+		}
+		return fmt.Errorf("repo.Delete: %w", err)
+	}
+	s.logger.Info("api change deleted by admin", zap.Int("id", id))
+	return nil
+}
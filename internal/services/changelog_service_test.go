@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// changelogRepo stores the changes created.
+type changelogRepo struct {
+	repository.ChangelogRepository
+
+	created []repository.APIChange
+}
+
+func (r *changelogRepo) Create(_ context.Context, c repository.APIChange) (repository.APIChange, error) {
+	c.ID = len(r.created) + 1
+	r.created = append(r.created, c)
+	return c, nil
+}
+
+func TestChangelogService_Announce(t *testing.T) {
+	announced := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	route := sql.NullString{String: "/api/manage/:token", Valid: true}
+	for _, tc := range []struct {
+		name    string
+		change  repository.APIChange
+		wantErr bool
+	}{
+		{"addition", repository.APIChange{Kind: repository.APIChangeAdded, Title: "Multi-city subscriptions"}, false},
+		{"deprecation with a sunset", repository.APIChange{Kind: repository.APIChangeDeprecated, Title: "Manage page",
+			Method: sql.NullString{String: "get", Valid: true}, Path: route, AnnouncedAt: announced,
+			SunsetAt: sql.NullTime{Time: announced.AddDate(0, 6, 0), Valid: true}}, false},
+		{"unknown kind", repository.APIChange{Kind: "renamed", Title: "Manage page"}, true},
+		{"no title", repository.APIChange{Kind: repository.APIChangeAdded, Title: " "}, true},
+		{"path outside the API", repository.APIChange{Kind: repository.APIChangeChanged, Title: "Home",
+			Path: sql.NullString{String: "/", Valid: true}}, true},
+		{"deprecation without a route", repository.APIChange{Kind: repository.APIChangeDeprecated, Title: "Manage page"},
+			true},
+		{"sunset before the announcement", repository.APIChange{Kind: repository.APIChangeDeprecated, Title: "Manage page",
+			Path: route, AnnouncedAt: announced, SunsetAt: sql.NullTime{Time: announced.AddDate(0, -1, 0), Valid: true}},
+			true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &changelogRepo{}
+			svc := NewChangelogService(repo, zap.NewNop())
+
+			created, err := svc.Announce(context.Background(), tc.change)
+			if (err != nil) != tc.wantErr || (err != nil && !errors.Is(err, ErrInvalidAPIChange)) {
+				t.Fatalf("Announce() error = %v, want error %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if len(repo.created) != 0 {
+					t.Errorf("Announce() stored an invalid change: %+v", repo.created)
+				}
+				return
+			}
+			if created.ID == 0 || (created.Method.Valid && created.Method.String != "GET") {
+				t.Errorf("Announce() = %+v, want it stored with an upper-case method", created)
+			}
+		})
+	}
+}
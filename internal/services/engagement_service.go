@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// EngagementService records email opens and the engagement of subscribers: "keep
+// sending" confirmations from re-engagement and renewal emails.
+type EngagementService interface {
+	RecordOpen(ctx context.Context, openToken string) error
+	KeepSubscription(ctx context.Context, manageToken string) error
+}
+
+type engagementService struct {
+	repo     repository.EngagementRepository
+	renewFor time.Duration
+	logger   *zap.Logger
+}
+
+// NewEngagementService wires up engagement service dependencies. "Keep sending" clicks
+// renew expiring subscriptions for renewFor (SUBSCRIPTION_TTL; 0 disables renewal).
+func NewEngagementService(repo repository.EngagementRepository, renewFor time.Duration, logger *zap.Logger,
+) EngagementService {
+	return &engagementService{repo: repo, renewFor: renewFor, logger: logger}
+}
+
+// RecordOpen marks the email behind an open-tracking token as opened. The subscriber is
+// not taken as engaged: the pixel is fetched by image proxies and link scanners too.
+func (s *engagementService) RecordOpen(ctx context.Context, tokenStr string) error {
+	t, err := uuid.Parse(tokenStr)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if err := s.repo.RecordOpen(ctx, t); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("repo.RecordOpen: %w", err)
+	}
+	return nil
+}
+
+// KeepSubscription records that the subscriber behind a manage link still wants updates,
+// renewing the subscription when it expires.
+func (s *engagementService) KeepSubscription(ctx context.Context, tokenStr string) error {
+	t, err := uuid.Parse(tokenStr)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	if err := s.repo.MarkEngaged(ctx, t); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("repo.MarkEngaged: %w", err)
+	}
+	if s.renewFor > 0 {
+		if err := s.repo.Renew(ctx, t, s.renewFor); err != nil {
+			return fmt.Errorf("repo.Renew: %w", err)
+		}
+	}
+
+	s.logger.Info("subscriber confirmed continued interest", zap.String("token", tokenStr))
+	return nil
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/features"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
+)
+
+// Email templates that can be previewed.
+const (
+	PreviewConfirmation  = "confirmation"
+	PreviewWeatherUpdate = "weather_update"
+)
+
+// returned when the previewed template is not one of PreviewConfirmation, PreviewWeatherUpdate
+var ErrInvalidTemplate = errors.New("template must be confirmation or weather_update")
+
+// PreviewRequest describes the subscription an email is previewed for.
+type PreviewRequest struct {
+	Template  string
+	Cities    []string
+	Frequency string
+	SendHour  *int // daily only; nil schedules at confirmation time
+	Timezone  string
+	Locale    string
+	Units     repository.Units // metric when empty
+	FirstName string
+}
+
+// Preview is a rendered email.
+type Preview struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+}
+
+// PreviewService renders emails as they would be sent, without sending anything, for
+// iterating on email content.
+type PreviewService interface {
+	Preview(ctx context.Context, req PreviewRequest) (Preview, error)
+}
+
+type previewService struct {
+	weatherFetcher weather.Fetcher
+	links          *linksign.Signer
+	flags          *features.Flags
+	attributions   weather.Attributions
+	cfg            *config.Config
+	logger         *zap.Logger
+}
+
+// NewPreviewService wires up preview service dependencies.
+func NewPreviewService(weatherFetcher weather.Fetcher, links *linksign.Signer, flags *features.Flags,
+	cfg *config.Config, logger *zap.Logger,
+) PreviewService {
+	return &previewService{weatherFetcher: weatherFetcher, links: links, flags: flags,
+		attributions: weather.ProviderAttributions(cfg), cfg: cfg, logger: logger}
+}
+
+// Preview renders the requested email with the same validation as a subscription and
+// the current weather of its cities. Links carry a nil token and lead nowhere.
+func (s *previewService) Preview(ctx context.Context, req PreviewRequest) (Preview, error) {
+	cities, err := normalizeCities(req.Cities)
+	if err != nil {
+		return Preview{}, err
+	}
+	freq, err := repository.ParseFrequency(req.Frequency)
+	if err != nil {
+		return Preview{}, ErrInvalidFrequency
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if !validTimezone(req.Timezone) {
+		return Preview{}, ErrInvalidTimezone
+	}
+	locale := i18n.Match(req.Locale)
+	if locale == "" {
+		locale = i18n.Default
+	}
+	units := repository.UnitsMetric
+	if req.Units != "" {
+		units = req.Units
+	}
+	firstName, err := normalizeFirstName(req.FirstName)
+	if err != nil {
+		return Preview{}, err
+	}
+	var hour *int16
+	if req.SendHour != nil {
+		if freq != repository.FrequencyDaily || *req.SendHour < 0 || *req.SendHour > 23 {
+			return Preview{}, ErrInvalidSendHour
+		}
+		h := int16(*req.SendHour)
+		hour = &h
+	}
+
+	token := uuid.Nil.String()
+	unsubscribeURL := s.links.URL(s.cfg.BaseURL, linksign.PurposeUnsubscribe, token)
+	switch req.Template {
+	case PreviewConfirmation:
+		html, err := email.Render(email.TemplateConfirmation, email.ConfirmationData{
+			Locale:         locale,
+			Subscriber:     email.Subscriber{FirstName: firstName},
+			Cities:         cities,
+			Schedule:       describeSchedule(freq, hour, req.Timezone, locale),
+			ConfirmURL:     s.links.URL(s.cfg.BaseURL, linksign.PurposeConfirm, token),
+			UnsubscribeURL: unsubscribeURL,
+			NotMeURL:       s.links.ActionURL(s.cfg.BaseURL, linksign.PurposeConfirm, token, "not-me"),
+			NotMeDays:      int((s.cfg.NotMeSuppressionTTL + 24*time.Hour - 1) / (24 * time.Hour)),
+		})
+		if err != nil {
+			return Preview{}, fmt.Errorf("email.Render: %w", err)
+		}
+		return Preview{Subject: i18n.T(locale, "confirm.subject"), HTML: html}, nil
+
+	case PreviewWeatherUpdate:
+		loc, _ := time.LoadLocation(req.Timezone)
+		var current []email.CityWeather
+		var providers []string
+		for _, city := range cities {
+			w, err := s.weatherFetcher.FetchCurrent(ctx, city)
+			if err != nil {
+				s.logger.Info("preview for a city without weather", zap.String("city", city), zap.Error(err))
+				return Preview{}, ErrInvalidCity
+			}
+			cw := email.CityWeather{City: city, Weather: w}
+			if !w.Sunrise.IsZero() {
+				cw.Sunrise = w.Sunrise.In(loc).Format("15:04")
+			}
+			current = append(current, cw)
+			providers = append(providers, w.Provider)
+		}
+		html, err := email.Render(email.TemplateWeatherUpdate, email.WeatherUpdateData{
+			Locale:         locale,
+			Subscriber:     email.Subscriber{FirstName: firstName},
+			Cities:         current,
+			Timezone:       req.Timezone,
+			Units:          string(units),
+			ManageURL:      s.cfg.BaseURL + "/api/manage/" + token,
+			UnsubscribeURL: unsubscribeURL,
+			Attributions:   s.attributions.For(providers...),
+			Features:       s.flags.For(false),
+		})
+		if err != nil {
+			return Preview{}, fmt.Errorf("email.Render: %w", err)
+		}
+		return Preview{Subject: i18n.T(locale, "update.subject", email.DescribeCities(current, locale)), HTML: html}, nil
+
+	default:
+		return Preview{}, ErrInvalidTemplate
+	}
+}
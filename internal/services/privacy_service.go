@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+)
+
+// ErrInvalidEmail is returned for a privacy request of a malformed address.
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// PrivacyService serves the GDPR requests of subscribers. A request emails the address
+// a token (the challenge proving the requester owns it) with which its data is exported
+// or erased.
+type PrivacyService interface {
+	// RequestChallenge emails the export and erase links of emailAddr. Suppressed
+	// addresses are not emailed, silently: the caller cannot tell them apart.
+	RequestChallenge(ctx context.Context, emailAddr string) error
+	Export(ctx context.Context, token string) (repository.PrivacyExport, error)
+	Erase(ctx context.Context, emailAddr, token string) (repository.PrivacyErasure, error)
+}
+
+type privacyService struct {
+	repo         repository.PrivacyRepository
+	suppressions repository.SuppressionRepository
+	emailSender  email.EmailSender
+	baseURL      string
+	ttl          time.Duration
+	logger       *zap.Logger
+}
+
+// NewPrivacyService wires up privacy service dependencies. Links are built on baseURL
+// (BASE_URL), or the base URL of the tenant of the request, and work for ttl
+// (PRIVACY_REQUEST_TTL).
+func NewPrivacyService(repo repository.PrivacyRepository, suppressions repository.SuppressionRepository,
+	emailSender email.EmailSender, baseURL string, ttl time.Duration, logger *zap.Logger,
+) PrivacyService {
+	return &privacyService{repo: repo, suppressions: suppressions, emailSender: emailSender, baseURL: baseURL,
+		ttl: ttl, logger: logger}
+}
+
+func (s *privacyService) RequestChallenge(ctx context.Context, emailAddr string) error {
+	if parsed, err := mail.ParseAddress(emailAddr); err != nil || parsed.Address != emailAddr {
+		return ErrInvalidEmail
+	}
+	suppressed, err := s.suppressions.IsSuppressed(ctx, emailAddr)
+	if err != nil {
+		return fmt.Errorf("suppressions.IsSuppressed: %w", err)
+	}
+	if suppressed {
+		s.logger.Info("privacy request of a suppressed address not emailed")
+		return nil
+	}
+
+	token, err := s.repo.CreateRequest(ctx, emailAddr, s.ttl)
+	if err != nil {
+		return fmt.Errorf("repo.CreateRequest: %w", err)
+	}
+	baseURL := tenant.BaseURL(tenant.OrDefault(ctx), s.baseURL)
+	body, err := email.Render(email.TemplatePrivacy, email.PrivacyData{
+		ExportURL: fmt.Sprintf("%s/api/privacy/export/%s", baseURL, token),
+		EraseURL:  fmt.Sprintf("%s/api/privacy/erase/%s?email=%s", baseURL, token, url.QueryEscape(emailAddr)),
+		ValidFor:  describeTTL(s.ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("email.Render: %w", err)
+	}
+	msg := email.EmailMessage{
+		To:      []string{emailAddr},
+		Subject: "Your weather subscription data",
+		Body:    body,
+	}
+	if err := s.emailSender.SendBatch([]email.EmailMessage{msg}); err != nil {
+		return fmt.Errorf("email.SendBatch: %w", err)
+	}
+	s.logger.Info("privacy challenge sent")
+	return nil
+}
+
+func (s *privacyService) Export(ctx context.Context, tokenStr string) (repository.PrivacyExport, error) {
+	t, err := uuid.Parse(tokenStr)
+	if err != nil {
+		return repository.PrivacyExport{}, ErrInvalidToken
+	}
+
+	out, err := s.repo.Export(ctx, t)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.PrivacyExport{}, ErrTokenNotFound
+		}
+		return repository.PrivacyExport{}, fmt.Errorf("repo.Export: %w", err)
+	}
+	return out, nil
+}
+
+func (s *privacyService) Erase(ctx context.Context, emailAddr, tokenStr string) (repository.PrivacyErasure, error) {
+	t, err := uuid.Parse(tokenStr)
+	if err != nil {
+		return repository.PrivacyErasure{}, ErrInvalidToken
+	}
+
+	res, err := s.repo.Erase(ctx, emailAddr, t)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.PrivacyErasure{}, ErrTokenNotFound
+		}
+		return repository.PrivacyErasure{}, fmt.Errorf("repo.Erase: %w", err)
+	}
+	s.logger.Info("privacy erasure done", zap.Int64("subscriptions", res.Subscriptions))
+	return res, nil
+}
+
+// describeTTL renders a link lifetime for emails, e.g. "24 hours" or "30 minutes".
+func describeTTL(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int(d/time.Hour), "hour")
+	case d >= time.Minute:
+		return plural(int(d/time.Minute), "minute")
+	default:
+		return d.String()
+	}
+}
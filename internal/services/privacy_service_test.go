@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// fakePrivacyRepo holds one token, of addr.
+type fakePrivacyRepo struct {
+	repository.PrivacyRepository
+
+	addr   string
+	token  uuid.UUID
+	erased bool
+}
+
+func (r *fakePrivacyRepo) CreateRequest(_ context.Context, addr string, _ time.Duration) (uuid.UUID, error) {
+	r.addr, r.token = addr, uuid.New()
+	return r.token, nil
+}
+
+func (r *fakePrivacyRepo) Erase(_ context.Context, addr string, token uuid.UUID) (repository.PrivacyErasure, error) {
+	if addr != r.addr || token != r.token {
+		return repository.PrivacyErasure{}, sql.ErrNoRows
+	}
+	r.erased = true
+	return repository.PrivacyErasure{Subscriptions: 1}, nil
+}
+
+type noSuppressions struct {
+	repository.SuppressionRepository
+}
+
+func (noSuppressions) IsSuppressed(context.Context, string) (bool, error) { return false, nil }
+
+type recordingSender struct{ sent []email.EmailMessage }
+
+func (s *recordingSender) SendBatch(messages []email.EmailMessage) error {
+	s.sent = append(s.sent, messages...)
+	return nil
+}
+
+func TestPrivacyService_EraseLinkAsksFirst(t *testing.T) {
+	repo := &fakePrivacyRepo{}
+	sender := &recordingSender{}
+	svc := NewPrivacyService(repo, noSuppressions{}, sender, "https://weather.example.com", 24*time.Hour, zap.NewNop())
+
+	if err := svc.RequestChallenge(context.Background(), "anna+w@example.com"); err != nil {
+		t.Fatalf("RequestChallenge() error: %v", err)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("%d emails sent, want 1", len(sender.sent))
+	}
+	want := "https://weather.example.com/api/privacy/erase/" + repo.token.String() + "?email=anna%2Bw%40example.com"
+	if body := sender.sent[0].Body; !strings.Contains(body, `href="`+want+`"`) || strings.Contains(body, "DELETE") {
+		t.Errorf("email does not link to the erase page %s:\n%s", want, body)
+	}
+	if repo.erased {
+		t.Fatal("RequestChallenge() erased the data")
+	}
+
+	for _, tc := range []struct {
+		addr, token string
+		want        error
+	}{
+		{"anna+w@example.com", "not-a-uuid", ErrInvalidToken},
+		{"bob@example.com", repo.token.String(), ErrTokenNotFound},
+		{"anna+w@example.com", repo.token.String(), nil},
+	} {
+		if _, err := svc.Erase(context.Background(), tc.addr, tc.token); !errors.Is(err, tc.want) {
+			t.Errorf("Erase(%q, %q) error = %v, want %v", tc.addr, tc.token, err, tc.want)
+		}
+	}
+	if !repo.erased {
+		t.Error("Erase() with the emailed token erased nothing")
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
+)
+
+// MaxStatsDays bounds the period of the subscription statistics.
+const MaxStatsDays = 366
+
+// statsTopCities is how many cities the statistics list, the most subscribed first.
+const statsTopCities = 100
+
+// ErrInvalidStatsPeriod is returned for a statistics period out of 1..MaxStatsDays days.
+var ErrInvalidStatsPeriod = fmt.Errorf("days must be between 1 and %d", MaxStatsDays)
+
+// StatsService serves the aggregate numbers on subscriptions to operators.
+type StatsService interface {
+	// Stats returns the statistics of the last days days, today included.
+	Stats(ctx context.Context, days int) (repository.SubscriptionStats, error)
+}
+
+type statsService struct {
+	repo   repository.StatsRepository
+	redis  *redis.Client // nil: not cached
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+// NewStatsService wires up stats service dependencies. The statistics of each period
+// are cached in rdb for ttl (ADMIN_STATS_CACHE_TTL), as their queries scan the whole
+// table; a nil rdb or a ttl of 0 computes them on every call.
+func NewStatsService(repo repository.StatsRepository, rdb *redis.Client, ttl time.Duration, logger *zap.Logger,
+) StatsService {
+	if ttl <= 0 {
+		rdb = nil
+	}
+	return &statsService{repo: repo, redis: rdb, ttl: ttl, logger: logger}
+}
+
+func (s *statsService) Stats(ctx context.Context, days int) (repository.SubscriptionStats, error) {
+	if days < 1 || days > MaxStatsDays {
+		return repository.SubscriptionStats{}, ErrInvalidStatsPeriod
+	}
+	// keyed by tenant, period and day, so a cached answer never spans midnight (UTC)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	key := "stats:subscriptions:" + tenant.OrDefault(ctx) + ":" + today.Format(time.DateOnly) + ":" + strconv.Itoa(days)
+	if stats, ok := s.cached(ctx, key); ok {
+		return stats, nil
+	}
+
+	stats, err := s.repo.Stats(ctx, today.AddDate(0, 0, 1-days), statsTopCities)
+	if err != nil {
+		return repository.SubscriptionStats{}, fmt.Errorf("repo.Stats: %w", err)
+	}
+	if s.redis != nil {
+		if raw, err := json.Marshal(stats); err != nil {
+			s.logger.Warn("failed to encode subscription stats", zap.Error(err))
+		} else if err := s.redis.Set(ctx, key, raw, s.ttl).Err(); err != nil {
+			s.logger.Warn("redis SET failed", zap.Error(err))
+		}
+	}
+	return stats, nil
+}
+
+// cached returns the statistics cached under key, if any.
+func (s *statsService) cached(ctx context.Context, key string) (repository.SubscriptionStats, bool) {
+	if s.redis == nil {
+		return repository.SubscriptionStats{}, false
+	}
+	raw, err := s.redis.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			s.logger.Warn("redis GET failed", zap.Error(err))
+		}
+		return repository.SubscriptionStats{}, false
+	}
+	var stats repository.SubscriptionStats
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		s.logger.Warn("failed to decode cached subscription stats", zap.Error(err))
+		return repository.SubscriptionStats{}, false
+	}
+	return stats, true
+}
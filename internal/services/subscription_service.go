@@ -2,12 +2,25 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"errors"
 	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/events"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/linksign"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/slack"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/sms"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tenant"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather"
 
 	"github.com/google/uuid"
@@ -20,6 +33,7 @@ var (
 	ErrInvalidCity = errors.New("invalid city")
 
 	// returned when someone tries to subscribe the same email+city twice
+	// (the same email may subscribe to different cities)
 	ErrAlreadySubscribed = errors.New("email already subscribed for this city")
 
 	// returned when the token string is malformed (not a UUID)
@@ -27,19 +41,92 @@ var (
 
 	// returned when no subscription matches the given token
 	ErrTokenNotFound = errors.New("subscription not found for this token")
+
+	// returned when a confirmation link has expired; ResendConfirmation sends a new one
+	ErrTokenExpired = errors.New("confirmation link has expired")
+
+	// returned when a change is requested for a subscription that is not confirmed yet
+	ErrNotConfirmed = errors.New("subscription is not confirmed")
+
+	// returned when the frequency is not one of the supported values
+	ErrInvalidFrequency = errors.New("invalid frequency")
+
+	// returned when the address is on the suppression list (bounced, complained, ...)
+	ErrEmailSuppressed = errors.New("this email address cannot receive emails")
+
+	// returned when a send hour is outside 0-23 or given for a non-daily subscription
+	ErrInvalidSendHour = errors.New("send_hour must be between 0 and 23 and is only allowed for daily subscriptions")
+
+	// returned when the timezone is not a known IANA name (e.g. "Europe/Kyiv")
+	ErrInvalidTimezone = errors.New("invalid timezone")
+
+	// returned when a locale has no message catalog (see i18n.Languages)
+	ErrInvalidLocale = errors.New("unsupported locale")
+
+	// returned when no city, or more than repository.MaxCitiesPerSubscription, is given
+	ErrInvalidCityCount = fmt.Errorf("a subscription needs between 1 and %d cities", repository.MaxCitiesPerSubscription)
+
+	// returned when a first name is too long or contains control characters
+	ErrInvalidFirstName = fmt.Errorf("first_name must be at most %d characters, without control characters", maxFirstNameLen)
+
+	// returned when an alert subscription has no condition or one out of range, or
+	// conditions are given for another frequency
+	ErrInvalidAlert = errors.New("alert subscriptions need at least one valid condition, and only they take conditions")
+
+	// returned when a Slack webhook URL is not https://hooks.slack.com/services/..., or is
+	// given for a subscription that is not hourly or daily
+	ErrInvalidSlackWebhook = errors.New("slack_webhook_url must be a Slack incoming webhook, for hourly or daily updates")
+
+	// returned when a phone is not in E.164 format, is given for a subscription that is
+	// not daily, or together with a Slack webhook
+	ErrInvalidPhone = errors.New("phone must be an E.164 number (e.g. +380501234567), for daily updates only")
+
+	// returned when a phone is given but SMS is not configured
+	ErrSMSUnavailable = errors.New("sms subscriptions are not available")
+
+	// returned when a phone code is wrong, expired, or was guessed too often
+	ErrInvalidCode = errors.New("invalid or expired code")
 )
 
+// SubscribeRequest is a subscription asked for, as the subscriber gave it.
+type SubscribeRequest struct {
+	Email     string
+	FirstName string   // optional, only used to greet the subscriber
+	Cities    []string // the first is the primary city
+	Frequency string
+	SendHour  *int   // optional local hour of daily updates
+	Timezone  string // empty: derived from the first city
+	Locale    string
+	Alert     repository.AlertConditions // of alert subscriptions
+	// SlackWebhookURL, optional, has the updates posted to a channel instead of emailed
+	SlackWebhookURL string
+	// Phone, optional, has daily updates texted instead of emailed
+	Phone string
+}
+
 // SubscriptionService defines your business operations.
 type SubscriptionService interface {
-	Subscribe(ctx context.Context, emailAddr, city, frequency string) error
+	Subscribe(ctx context.Context, req SubscribeRequest) error
 	Confirm(ctx context.Context, token string) error
+	ConfirmPhone(ctx context.Context, phone, code string) error
+	ResendConfirmation(ctx context.Context, token string) error
+	DisownSignup(ctx context.Context, token string) error
 	Unsubscribe(ctx context.Context, token string) error
+	GetManaged(ctx context.Context, token string) (repository.Subscription, error)
+	UpdateManaged(ctx context.Context, token string, u repository.SubscriptionUpdate) (repository.Subscription, error)
 }
 
 type subscriptionService struct {
 	repo           repository.SubscriptionRepository
+	alerts         repository.AlertRepository
+	phones         repository.PhoneRepository
+	suppressions   repository.SuppressionRepository
 	emailSender    email.EmailSender
+	smsSender      sms.Sender // nil: SMS subscriptions are refused
 	weatherFetcher weather.Fetcher
+	timezones      weather.TimezoneResolver // nil: timezone defaults to UTC unless given
+	links          *linksign.Signer         // nil: links are unsigned
+	events         *events.Publisher
 	cfg            *config.Config
 	logger         *zap.Logger
 }
@@ -47,12 +134,20 @@ type subscriptionService struct {
 // NewSubscriptionService wires up service dependencies.
 func NewSubscriptionService(
 	repo repository.SubscriptionRepository,
+	alerts repository.AlertRepository,
+	phones repository.PhoneRepository,
+	suppressions repository.SuppressionRepository,
 	emailSender email.EmailSender,
+	smsSender sms.Sender,
 	weatherFetcher weather.Fetcher,
+	timezones weather.TimezoneResolver,
+	links *linksign.Signer,
+	publisher *events.Publisher,
 	cfg *config.Config,
 	logger *zap.Logger,
 ) SubscriptionService {
-	return &subscriptionService{repo, emailSender, weatherFetcher, cfg, logger}
+	return &subscriptionService{repo, alerts, phones, suppressions, emailSender, smsSender, weatherFetcher, timezones, links,
+		publisher, cfg, logger}
 }
 
 // validateCity actually tries to fetch once and returns ErrInvalidCity on failure
@@ -63,35 +158,211 @@ func (s *subscriptionService) validateCity(ctx context.Context, city string) err
 	return nil
 }
 
+// normalizeCities trims and de-duplicates cities (case-insensitively, keeping the first
+// spelling and the order) and checks their count.
+func normalizeCities(cities []string) ([]string, error) {
+	seen := make(map[string]bool, len(cities))
+	var out []string
+	for _, c := range cities {
+		c = strings.TrimSpace(c)
+		key := strings.ToLower(c)
+		if c == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, c)
+	}
+	if len(out) == 0 || len(out) > repository.MaxCitiesPerSubscription {
+		return nil, ErrInvalidCityCount
+	}
+	return out, nil
+}
+
+// maxFirstNameLen is the longest first name accepted on subscribe, in characters.
+const maxFirstNameLen = 50
+
+// normalizeFirstName trims a first name and checks it fits a greeting line.
+func normalizeFirstName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if utf8.RuneCountInString(name) > maxFirstNameLen {
+		return "", ErrInvalidFirstName
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", ErrInvalidFirstName
+		}
+	}
+	return name, nil
+}
+
 // Subscribe creates a new unconfirmed subscription and sends a confirmation email.
-func (s *subscriptionService) Subscribe(ctx context.Context, emailAddr, city, frequency string) error {
-	// validate the city name by doing a single FetchCurrent first
-	if err := s.validateCity(ctx, city); err != nil {
-		return ErrInvalidCity
+// Confirmation and other emails go to the email of req even when updates are posted
+// to Slack. A subscription with a phone is confirmed with a code texted to the phone
+// (see ConfirmPhone) rather than a link emailed.
+func (s *subscriptionService) Subscribe(ctx context.Context, req SubscribeRequest) error {
+	emailAddr, firstName, cities, timezone, locale := req.Email, req.FirstName, req.Cities, req.Timezone, req.Locale
+	alert, slackWebhookURL, phone := req.Alert, req.SlackWebhookURL, req.Phone
+
+	// never send anything, not even the confirmation, to a suppressed address
+	suppressed, err := s.suppressions.IsSuppressed(ctx, emailAddr)
+	if err != nil {
+		return fmt.Errorf("suppressions.IsSuppressed: %w", err)
+	}
+	if suppressed {
+		s.logger.Info("subscribe attempt for suppressed email", zap.Strings("cities", cities))
+		return ErrEmailSuppressed
 	}
 
-	confirmToken, unsubscribeToken, err := s.repo.Create(ctx, emailAddr, city, frequency)
+	freq, err := repository.ParseFrequency(req.Frequency)
+	if err != nil {
+		return ErrInvalidFrequency
+	}
+	if !validAlert(freq, alert) {
+		return ErrInvalidAlert
+	}
+	if slackWebhookURL != "" && (!slack.ValidWebhookURL(slackWebhookURL) ||
+		(freq != repository.FrequencyHourly && freq != repository.FrequencyDaily)) {
+		return ErrInvalidSlackWebhook
+	}
+	if phone != "" {
+		if !sms.ValidPhone(phone) || freq != repository.FrequencyDaily || slackWebhookURL != "" {
+			return ErrInvalidPhone
+		}
+		if s.smsSender == nil {
+			return ErrSMSUnavailable
+		}
+	}
+
+	if timezone != "" && !validTimezone(timezone) {
+		return ErrInvalidTimezone
+	}
+	if locale = i18n.Match(locale); locale == "" {
+		locale = i18n.Default
+	}
+	if firstName, err = normalizeFirstName(firstName); err != nil {
+		return err
+	}
+
+	var hour *int16
+	if req.SendHour != nil {
+		if freq != repository.FrequencyDaily || *req.SendHour < 0 || *req.SendHour > 23 {
+			return ErrInvalidSendHour
+		}
+		h := int16(*req.SendHour)
+		hour = &h
+	}
+
+	cities, err = normalizeCities(cities)
+	if err != nil {
+		return err
+	}
+
+	// validate every city name by doing a single FetchCurrent first
+	for _, city := range cities {
+		if err := s.validateCity(ctx, city); err != nil {
+			return ErrInvalidCity
+		}
+	}
+
+	if timezone == "" {
+		timezone = s.cityTimezone(ctx, cities[0])
+	}
+
+	// an alert subscription is complete, and reported, once its conditions are stored
+	staged, published := s.events.Stage(ctx, func(id int) events.Payload {
+		return events.SubscriptionCreated{SubscriptionID: id, Cities: cities, Frequency: string(freq), Timezone: timezone,
+			SendHour: hour}
+	})
+	createCtx := staged
+	if freq == repository.FrequencyAlert {
+		createCtx = ctx
+	}
+	id, confirmToken, unsubscribeToken, err := s.repo.Create(createCtx, repository.NewSubscription{
+		Email: emailAddr, FirstName: firstName, Cities: cities, Frequency: freq, SendHour: hour, Timezone: timezone,
+		Locale: locale, SlackWebhookURL: slackWebhookURL, ConfirmTTL: s.cfg.ConfirmTokenTTL,
+	})
 	if err != nil {
 		if errors.Is(err, repository.ErrEmailAlreadyExists) {
 			return ErrAlreadySubscribed
 		}
 		return fmt.Errorf("repo.Create: %w", err)
 	}
+	if freq == repository.FrequencyAlert {
+		if err := s.alerts.SetConditions(staged, id, alert); err != nil {
+			// an alert subscription without conditions would never trip: drop it
+			if delErr := s.repo.DeleteByID(ctx, id); delErr != nil {
+				s.logger.Error("failed to delete alert subscription without conditions", zap.Int("id", id), zap.Error(delErr))
+			}
+			return fmt.Errorf("alerts.SetConditions: %w", err)
+		}
+	}
+	s.publish(ctx, events.TypeSubscriptionCreated, published, id)
 
-	// Build the confirmation link (swagger basePath is /api)
-	confirmURL := fmt.Sprintf("%s/api/confirm/%s", s.cfg.BaseURL, confirmToken.String())
-	unsubscribeURL := fmt.Sprintf("%s/api/unsubscribe/%s", s.cfg.BaseURL, unsubscribeToken.String())
+	if phone != "" {
+		return s.sendCode(ctx, id, phone, locale)
+	}
+	return s.sendConfirmation(ctx, id, emailAddr, firstName, cities, freq, hour, timezone, locale, confirmToken,
+		unsubscribeToken)
+}
 
-	body := fmt.Sprintf(
-		`<p>Please confirm your subscription for <b>%s</b> weather updates:</p>
-         <p><a href="%s">Confirm Subscription</a></p>
-         <p><a href="%s">Unsubscribe</a></p>`,
-		city, confirmURL, unsubscribeURL,
-	)
+// sendCode texts the one-time code confirming the SMS subscription id to phone. When
+// it cannot be sent the subscription is deleted, so that subscribing again is possible.
+func (s *subscriptionService) sendCode(ctx context.Context, id int, phone, locale string) error {
+	code, err := newCode()
+	if err != nil {
+		return err
+	}
+	err = s.phones.StartVerification(ctx, id, phone, code, time.Now().Add(s.cfg.SMSCodeTTL))
+	if err == nil {
+		ttl := int(s.cfg.SMSCodeTTL.Round(time.Minute) / time.Minute)
+		err = s.smsSender.Send(ctx, phone, i18n.T(locale, "sms.code", code, ttl))
+	}
+	if err != nil {
+		if delErr := s.repo.DeleteByID(ctx, id); delErr != nil {
+			s.logger.Error("failed to delete sms subscription without a code", zap.Int("id", id), zap.Error(delErr))
+		}
+		return fmt.Errorf("send phone code: %w", err)
+	}
+	s.logger.Info("phone code sent", zap.Int("id", id))
+	return nil
+}
+
+// newCode returns a random 6-digit one-time code.
+func newCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// sendConfirmation emails the confirmation link of a subscription, in its locale. Links
+// start with the base URL of the tenant of ctx.
+func (s *subscriptionService) sendConfirmation(ctx context.Context, id int, emailAddr, firstName string, cities []string,
+	freq repository.Frequency, sendHour *int16, timezone, locale string, confirmToken, unsubscribeToken uuid.UUID,
+) error {
+	// Build the signed confirmation link (swagger basePath is /api)
+	baseURL := tenant.BaseURL(tenant.OrDefault(ctx), s.cfg.BaseURL)
+	confirmURL := s.links.URL(baseURL, linksign.PurposeConfirm, confirmToken.String())
+	unsubscribeURL := s.links.URL(baseURL, linksign.PurposeUnsubscribe, unsubscribeToken.String())
+
+	body, err := email.Render(email.TemplateConfirmation, email.ConfirmationData{
+		Locale:         locale,
+		Subscriber:     email.Subscriber{FirstName: firstName},
+		Cities:         cities,
+		Schedule:       describeSchedule(freq, sendHour, timezone, locale),
+		ConfirmURL:     confirmURL,
+		UnsubscribeURL: unsubscribeURL,
+		NotMeURL:       s.links.ActionURL(baseURL, linksign.PurposeConfirm, confirmToken.String(), "not-me"),
+		NotMeDays:      int((s.cfg.NotMeSuppressionTTL + 24*time.Hour - 1) / (24 * time.Hour)),
+	})
+	if err != nil {
+		return fmt.Errorf("email.Render: %w", err)
+	}
 
 	msg := email.EmailMessage{
 		To:      []string{emailAddr},
-		Subject: "Confirm your weather subscription",
+		Subject: i18n.T(locale, "confirm.subject"),
 		Body:    body,
 	}
 	if err := s.emailSender.SendBatch([]email.EmailMessage{msg}); err != nil {
@@ -99,13 +370,83 @@ func (s *subscriptionService) Subscribe(ctx context.Context, emailAddr, city, fr
 	}
 
 	s.logger.Info("confirmation email sent",
-		zap.String("email", emailAddr),
+		zap.Int("subscription_id", id),
 		zap.String("confirmToken", confirmToken.String()),
 		zap.String("unsubscribeToken", unsubscribeToken.String()),
 	)
 	return nil
 }
 
+// Alert thresholds outside these ranges could never, or would always, trip.
+const (
+	minAlertTemp, maxAlertTemp = -90.0, 60.0 // °C
+	maxAlertWind               = 400.0       // km/h
+)
+
+// validAlert checks the alert conditions of a subscription of frequency freq: alert
+// subscriptions need at least one condition within range, others none.
+func validAlert(freq repository.Frequency, c repository.AlertConditions) bool {
+	if freq != repository.FrequencyAlert {
+		return c.Empty()
+	}
+	if c.Empty() {
+		return false
+	}
+	for _, t := range []*float64{c.TempAbove, c.TempBelow} {
+		if t != nil && (*t < minAlertTemp || *t > maxAlertTemp) {
+			return false
+		}
+	}
+	// below a lower bound or above a higher one would hold whatever the temperature
+	if c.TempAbove != nil && c.TempBelow != nil && *c.TempBelow >= *c.TempAbove {
+		return false
+	}
+	return c.WindAbove == nil || (*c.WindAbove > 0 && *c.WindAbove <= maxAlertWind)
+}
+
+// validTimezone reports whether name is an IANA timezone known to the tz database.
+func validTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// cityTimezone geocodes the timezone of city, falling back to UTC when it cannot be
+// resolved (the subscriber can still change it on the manage page).
+func (s *subscriptionService) cityTimezone(ctx context.Context, city string) string {
+	if s.timezones == nil {
+		return "UTC"
+	}
+	tz, err := s.timezones.ResolveTimezone(ctx, city)
+	if err == nil && !validTimezone(tz) {
+		err = fmt.Errorf("unknown timezone %q", tz)
+	}
+	if err != nil {
+		s.logger.Warn("cannot resolve city timezone, using UTC", zap.String("city", city), zap.Error(err))
+		return "UTC"
+	}
+	return tz
+}
+
+// describeSchedule tells the subscriber when updates will arrive.
+func describeSchedule(freq repository.Frequency, sendHour *int16, timezone, locale string) string {
+	switch {
+	case freq == repository.FrequencyHourly:
+		return i18n.T(locale, "schedule.hourly")
+	case freq == repository.FrequencyAlert:
+		return i18n.T(locale, "schedule.alert")
+	case freq == repository.FrequencyWarnings:
+		return i18n.T(locale, "schedule.warnings")
+	case sendHour != nil:
+		// the minute within the hour is that of the subscription, see repository.Create
+		return i18n.T(locale, "schedule.daily_at", *sendHour, (*sendHour+1)%24, timezone)
+	default:
+		return i18n.T(locale, "schedule.daily", timezone)
+	}
+}
+
 // Confirm parses and validates the token, then marks the subscription confirmed.
 func (s *subscriptionService) Confirm(ctx context.Context, tokenStr string) error {
 	t, err := uuid.Parse(tokenStr)
@@ -113,14 +454,104 @@ func (s *subscriptionService) Confirm(ctx context.Context, tokenStr string) erro
 		return ErrInvalidToken
 	}
 
-	if err := s.repo.Confirm(ctx, t); err != nil {
+	staged, published := s.events.Stage(ctx, func(id int) events.Payload {
+		return events.SubscriptionConfirmed{SubscriptionID: id}
+	})
+	id, err := s.repo.Confirm(staged, t, s.cfg.SubscriptionTTL)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ErrTokenNotFound
 		}
+		if errors.Is(err, repository.ErrTokenExpired) {
+			return ErrTokenExpired
+		}
 		return fmt.Errorf("repo.Confirm: %w", err)
 	}
 
 	s.logger.Info("subscription confirmed", zap.String("token", tokenStr))
+	s.publish(ctx, events.TypeSubscriptionConfirmed, published, id)
+	return nil
+}
+
+// ConfirmPhone confirms the SMS subscription of phone with the code texted to it.
+func (s *subscriptionService) ConfirmPhone(ctx context.Context, phone, code string) error {
+	if !sms.ValidPhone(phone) {
+		return ErrInvalidPhone
+	}
+	staged, published := s.events.Stage(ctx, func(id int) events.Payload {
+		return events.SubscriptionConfirmed{SubscriptionID: id}
+	})
+	id, err := s.phones.Verify(staged, phone, code)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCode) {
+			return ErrInvalidCode
+		}
+		return fmt.Errorf("phones.Verify: %w", err)
+	}
+	s.publish(ctx, events.TypeSubscriptionConfirmed, published, id)
+	return nil
+}
+
+// ResendConfirmation replaces the (usually expired) confirmation token of an unconfirmed
+// subscription and emails the new link to its address.
+func (s *subscriptionService) ResendConfirmation(ctx context.Context, tokenStr string) error {
+	t, err := uuid.Parse(tokenStr)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	sub, confirmToken, err := s.repo.RefreshConfirmToken(ctx, t, s.cfg.ConfirmTokenTTL)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("repo.RefreshConfirmToken: %w", err)
+	}
+
+	// the address may have bounced or complained since it signed up
+	suppressed, err := s.suppressions.IsSuppressed(ctx, sub.Email)
+	if err != nil {
+		return fmt.Errorf("suppressions.IsSuppressed: %w", err)
+	}
+	if suppressed {
+		return ErrEmailSuppressed
+	}
+
+	var hour *int16
+	if sub.SendHour.Valid {
+		hour = &sub.SendHour.Int16
+	}
+	return s.sendConfirmation(ctx, sub.ID, sub.Email, sub.FirstName, sub.AllCities(), sub.Frequency, hour, sub.Timezone,
+		sub.Locale, confirmToken, sub.UnsubscribeToken)
+}
+
+// DisownSignup handles "this wasn't me" on a confirmation email: it deletes the
+// unconfirmed subscription of the confirmation token and keeps its address from being
+// emailed again (confirmations included) for NotMeSuppressionTTL, so a stranger cannot
+// keep signing it up.
+func (s *subscriptionService) DisownSignup(ctx context.Context, tokenStr string) error {
+	t, err := uuid.Parse(tokenStr)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	staged, published := s.events.Stage(ctx, func(id int) events.Payload {
+		return events.SubscriptionUnsubscribed{SubscriptionID: id}
+	})
+	sub, err := s.repo.DeleteUnconfirmed(staged, t)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTokenNotFound
+		}
+		return fmt.Errorf("repo.DeleteUnconfirmed: %w", err)
+	}
+	s.publish(ctx, events.TypeSubscriptionUnsubscribed, published, sub.ID)
+
+	if err := s.suppressions.SuppressFor(ctx, sub.Email, repository.SuppressionReasonNotMe, "confirmation email",
+		s.cfg.NotMeSuppressionTTL); err != nil {
+		return fmt.Errorf("suppressions.SuppressFor: %w", err)
+	}
+	s.logger.Info("sign-up disowned by the address owner", zap.Int("id", sub.ID))
 	return nil
 }
 
@@ -131,7 +562,11 @@ func (s *subscriptionService) Unsubscribe(ctx context.Context, tokenStr string)
 		return ErrInvalidToken
 	}
 
-	if err := s.repo.DeleteByUnsubToken(ctx, t); err != nil {
+	staged, published := s.events.Stage(ctx, func(id int) events.Payload {
+		return events.SubscriptionUnsubscribed{SubscriptionID: id}
+	})
+	id, err := s.repo.DeleteByUnsubToken(staged, t)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ErrTokenNotFound
 		}
@@ -139,5 +574,99 @@ func (s *subscriptionService) Unsubscribe(ctx context.Context, tokenStr string)
 	}
 
 	s.logger.Info("subscription unsubscribed", zap.String("token", tokenStr))
+	s.publish(ctx, events.TypeSubscriptionUnsubscribed, published, id)
 	return nil
 }
+
+// publish emits the domain event of type staged for the change of subscription id, see
+// events.Publisher.Stage. The change is already committed, so a failure is logged
+// rather than returned.
+func (s *subscriptionService) publish(ctx context.Context, eventType string,
+	published func(ctx context.Context, id int) error, id int,
+) {
+	if err := published(ctx, id); err != nil {
+		s.logger.Error("failed to publish event", zap.String("type", eventType), zap.Int("id", id), zap.Error(err))
+	}
+}
+
+// GetManaged returns the subscription behind a manage link.
+func (s *subscriptionService) GetManaged(ctx context.Context, tokenStr string) (repository.Subscription, error) {
+	t, err := uuid.Parse(tokenStr)
+	if err != nil {
+		return repository.Subscription{}, ErrInvalidToken
+	}
+
+	sub, err := s.repo.GetByManageToken(ctx, t)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.Subscription{}, ErrTokenNotFound
+		}
+		return repository.Subscription{}, fmt.Errorf("repo.GetByManageToken: %w", err)
+	}
+	return sub, nil
+}
+
+// UpdateManaged applies subscriber-chosen preferences through a manage link.
+// Only confirmed subscriptions can be changed; newly added cities are validated the
+// same way as on subscribe.
+func (s *subscriptionService) UpdateManaged(ctx context.Context, tokenStr string, u repository.SubscriptionUpdate,
+) (repository.Subscription, error) {
+	t, err := uuid.Parse(tokenStr)
+	if err != nil {
+		return repository.Subscription{}, ErrInvalidToken
+	}
+
+	current, err := s.repo.GetByManageToken(ctx, t)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.Subscription{}, ErrTokenNotFound
+		}
+		return repository.Subscription{}, fmt.Errorf("repo.GetByManageToken: %w", err)
+	}
+	if !current.Confirmed {
+		return repository.Subscription{}, ErrNotConfirmed
+	}
+
+	if u.Timezone != nil && !validTimezone(*u.Timezone) {
+		return repository.Subscription{}, ErrInvalidTimezone
+	}
+	if u.Locale != nil {
+		locale := i18n.Match(*u.Locale)
+		if locale == "" {
+			return repository.Subscription{}, ErrInvalidLocale
+		}
+		u.Locale = &locale
+	}
+
+	if u.Cities != nil {
+		if u.Cities, err = normalizeCities(u.Cities); err != nil {
+			return repository.Subscription{}, err
+		}
+		known := make(map[string]bool)
+		for _, c := range current.AllCities() {
+			known[strings.ToLower(c)] = true
+		}
+		for _, c := range u.Cities {
+			if known[strings.ToLower(c)] {
+				continue
+			}
+			if err := s.validateCity(ctx, c); err != nil {
+				return repository.Subscription{}, ErrInvalidCity
+			}
+		}
+	}
+
+	sub, err := s.repo.Update(ctx, t, u)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.Subscription{}, ErrTokenNotFound
+		}
+		if errors.Is(err, repository.ErrEmailAlreadyExists) {
+			return repository.Subscription{}, ErrAlreadySubscribed
+		}
+		return repository.Subscription{}, fmt.Errorf("repo.Update: %w", err)
+	}
+
+	s.logger.Info("subscription preferences updated", zap.Int("id", sub.ID))
+	return sub, nil
+}
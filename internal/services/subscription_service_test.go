@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+// manageRepo holds one subscription, managed by token; cities in taken are subscribed by
+// the same email already.
+type manageRepo struct {
+	repository.SubscriptionRepository
+
+	token   uuid.UUID
+	sub     repository.Subscription
+	taken   []string
+	updated *repository.SubscriptionUpdate
+}
+
+func (r *manageRepo) GetByManageToken(_ context.Context, token uuid.UUID) (repository.Subscription, error) {
+	if token != r.token {
+		return repository.Subscription{}, sql.ErrNoRows
+	}
+	return r.sub, nil
+}
+
+func (r *manageRepo) Update(_ context.Context, token uuid.UUID, u repository.SubscriptionUpdate) (repository.Subscription, error) {
+	if token != r.token {
+		return repository.Subscription{}, sql.ErrNoRows
+	}
+	for _, c := range u.Cities {
+		if slices.Contains(r.taken, c) {
+			return repository.Subscription{}, repository.ErrEmailAlreadyExists
+		}
+	}
+	r.updated = &u
+	sub := r.sub
+	if u.Cities != nil {
+		sub.City = u.Cities[0]
+	}
+	if u.Frequency != nil {
+		sub.Frequency = *u.Frequency
+	}
+	return sub, nil
+}
+
+// knownCities fetches the weather of its cities only.
+type knownCities []string
+
+func (k knownCities) FetchCurrent(_ context.Context, city string) (types.Weather, error) {
+	if !slices.Contains(k, city) {
+		return types.Weather{}, errors.New("no matching location found")
+	}
+	return types.Weather{Temp: 12}, nil
+}
+
+func TestSubscriptionService_UpdateManaged(t *testing.T) {
+	token := uuid.New()
+	hourly := repository.FrequencyHourly
+	badZone, locale := "Mars/Olympus", "uk"
+	for _, tc := range []struct {
+		name      string
+		token     string
+		confirmed bool
+		update    repository.SubscriptionUpdate
+		want      error
+	}{
+		{"frequency", token.String(), true, repository.SubscriptionUpdate{Frequency: &hourly}, nil},
+		{"known city", token.String(), true, repository.SubscriptionUpdate{Cities: []string{"Lviv"}}, nil},
+		{"cities kept in another case", token.String(), true, repository.SubscriptionUpdate{Cities: []string{"kyiv"}}, nil},
+		{"locale", token.String(), true, repository.SubscriptionUpdate{Locale: &locale}, nil},
+		{"not confirmed", token.String(), false, repository.SubscriptionUpdate{Frequency: &hourly}, ErrNotConfirmed},
+		{"malformed token", "not-a-token", true, repository.SubscriptionUpdate{Frequency: &hourly}, ErrInvalidToken},
+		{"unknown token", uuid.NewString(), true, repository.SubscriptionUpdate{Frequency: &hourly}, ErrTokenNotFound},
+		{"unknown city", token.String(), true, repository.SubscriptionUpdate{Cities: []string{"Atlantis"}}, ErrInvalidCity},
+		{"no cities", token.String(), true, repository.SubscriptionUpdate{Cities: []string{" "}}, ErrInvalidCityCount},
+		{"city subscribed already", token.String(), true, repository.SubscriptionUpdate{Cities: []string{"Odesa"}},
+			ErrAlreadySubscribed},
+		{"unknown timezone", token.String(), true, repository.SubscriptionUpdate{Timezone: &badZone}, ErrInvalidTimezone},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &manageRepo{token: token, taken: []string{"Odesa"}, sub: repository.Subscription{ID: 1, City: "Kyiv",
+				Frequency: repository.FrequencyDaily, Confirmed: tc.confirmed}}
+			svc := NewSubscriptionService(repo, nil, nil, nil, nil, nil, knownCities{"Kyiv", "Lviv", "Odesa"}, nil, nil, nil,
+				&config.Config{}, zap.NewNop())
+
+			_, err := svc.UpdateManaged(context.Background(), tc.token, tc.update)
+			if !errors.Is(err, tc.want) || (tc.want == nil) != (err == nil) {
+				t.Fatalf("UpdateManaged() error = %v, want %v", err, tc.want)
+			}
+			if tc.want != nil && tc.want != ErrAlreadySubscribed && repo.updated != nil {
+				t.Errorf("UpdateManaged() updated the subscription despite %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Package signing signs outbound webhook and event payloads with HMAC-SHA256.
+//
+// Several keys can be active at once, each with an id, so keys rotate without a hard
+// cut-over: a new key is added next to the old one, consumers pick it up, and the old
+// key is given an expiry after which it no longer signs. Every payload carries one
+// signature per active key, so a consumer holding any of them can verify it.
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header carries the signatures of a payload: "t=<unix seconds>,<key id>=<hex HMAC>,...".
+// Each HMAC covers "<unix seconds>.<payload>", so a captured request cannot be replayed
+// with a new timestamp.
+const Header = "X-Weather-Signature"
+
+// Algorithm names the MAC used for the signatures.
+const Algorithm = "HMAC-SHA256"
+
+var (
+	// ErrNoActiveKey is returned when every configured key has expired.
+	ErrNoActiveKey = errors.New("signing: no active key")
+	// ErrInvalidSignature is returned when no signature of a header matches the payload.
+	ErrInvalidSignature = errors.New("signing: invalid signature")
+)
+
+// Key is one signing key. A zero ExpiresAt never expires.
+type Key struct {
+	ID        string
+	Secret    []byte
+	ExpiresAt time.Time
+}
+
+// Active reports whether k still signs at now.
+func (k Key) Active(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// Fingerprint identifies the secret without revealing it: the first 16 hex digits of
+// its SHA-256. Consumers compare it with the fingerprint of the secret they hold.
+func (k Key) Fingerprint() string {
+	sum := sha256.Sum256(k.Secret)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Keyring holds the configured signing keys. A nil *Keyring is valid and means
+// signing is disabled.
+type Keyring struct {
+	keys []Key
+	now  func() time.Time
+}
+
+// ParseKeys parses "id:base64secret[:expiry]" entries, expiry being an RFC 3339 time.
+func ParseKeys(entries []string) ([]Key, error) {
+	keys := make([]Key, 0, len(entries))
+	for _, e := range entries {
+		parts := strings.SplitN(e, ":", 3)
+		if len(parts) < 2 || parts[0] == "" {
+			return nil, errors.New("signing: key entry must be id:base64secret[:expiry]")
+		}
+		secret, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("signing: key %q is not valid base64: %w", parts[0], err)
+		}
+		k := Key{ID: parts[0], Secret: secret}
+		if len(parts) == 3 {
+			if k.ExpiresAt, err = time.Parse(time.RFC3339, parts[2]); err != nil {
+				return nil, fmt.Errorf("signing: key %q expiry: %w", parts[0], err)
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// NewKeyring builds a keyring from the EVENT_SIGNING_KEYS entries, or returns nil when
+// there are none.
+func NewKeyring(entries []string) (*Keyring, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	keys, err := ParseKeys(entries)
+	if err != nil {
+		return nil, err
+	}
+	return New(keys)
+}
+
+// New builds a keyring from keys.
+func New(keys []Key) (*Keyring, error) {
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if strings.ContainsAny(k.ID, ",=") {
+			return nil, fmt.Errorf("signing: key id %q must not contain ',' or '='", k.ID)
+		}
+		if k.ID == "t" {
+			return nil, errors.New(`signing: key id "t" is reserved for the timestamp`)
+		}
+		if len(k.Secret) < 32 {
+			return nil, fmt.Errorf("signing: key %q must be at least 32 bytes", k.ID)
+		}
+		if seen[k.ID] {
+			return nil, fmt.Errorf("signing: duplicate key id %q", k.ID)
+		}
+		seen[k.ID] = true
+	}
+	return &Keyring{keys: keys, now: time.Now}, nil
+}
+
+// Enabled reports whether payloads are signed.
+func (r *Keyring) Enabled() bool {
+	return r != nil
+}
+
+// Keys returns the configured keys, expired ones included, in configuration order.
+func (r *Keyring) Keys() []Key {
+	if r == nil {
+		return nil
+	}
+	return append([]Key(nil), r.keys...)
+}
+
+// Sign returns the Header value for payload, with one signature per active key.
+func (r *Keyring) Sign(payload []byte) (string, error) {
+	if r == nil {
+		return "", ErrNoActiveKey
+	}
+	now := r.now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	parts := []string{"t=" + ts}
+	for _, k := range r.keys {
+		if k.Active(now) {
+			parts = append(parts, k.ID+"="+mac(k.Secret, ts, payload))
+		}
+	}
+	if len(parts) == 1 {
+		return "", ErrNoActiveKey
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// SignRequest sets Header on an outbound request carrying body. It does nothing when
+// signing is disabled.
+func (r *Keyring) SignRequest(req *http.Request, body []byte) error {
+	if r == nil {
+		return nil
+	}
+	sig, err := r.Sign(body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(Header, sig)
+	return nil
+}
+
+// Verify checks a Header value against payload with the secret of key id, rejecting
+// timestamps more than tolerance away from now. It is what a consumer runs, and is
+// kept here as the reference implementation.
+func Verify(header string, payload []byte, id string, secret []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			ts = value
+		case id:
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func mac(secret []byte, ts string, payload []byte) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,66 @@
+package signing
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyring_SignAndVerify(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	oldSecret := bytes.Repeat([]byte{1}, 32)
+	newSecret := bytes.Repeat([]byte{2}, 32)
+	ring, err := New([]Key{
+		{ID: "old", Secret: oldSecret, ExpiresAt: now.Add(time.Hour)},
+		{ID: "new", Secret: newSecret},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ring.now = func() time.Time { return now }
+	payload := []byte(`{"event":"subscription.confirmed"}`)
+
+	header, err := ring.Sign(payload)
+	if err != nil {
+		t.Fatalf("Sign() error: %v", err)
+	}
+	// both keys sign during the overlap
+	for _, k := range []Key{{ID: "old", Secret: oldSecret}, {ID: "new", Secret: newSecret}} {
+		if err := Verify(header, payload, k.ID, k.Secret, now, 5*time.Minute); err != nil {
+			t.Errorf("Verify(%s) error: %v", k.ID, err)
+		}
+	}
+	if err := Verify(header, []byte(`{}`), "new", newSecret, now, 5*time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify(tampered) = %v, want ErrInvalidSignature", err)
+	}
+	if err := Verify(header, payload, "new", newSecret, now.Add(time.Hour), 5*time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify(stale) = %v, want ErrInvalidSignature", err)
+	}
+
+	// once expired, the old key no longer signs
+	ring.now = func() time.Time { return now.Add(2 * time.Hour) }
+	header, _ = ring.Sign(payload)
+	if strings.Contains(header, "old=") {
+		t.Errorf("Sign() = %q, expired key still signs", header)
+	}
+}
+
+func TestKeyring_NoActiveKey(t *testing.T) {
+	ring, _ := New([]Key{{ID: "k", Secret: bytes.Repeat([]byte{1}, 32), ExpiresAt: time.Unix(1, 0)}})
+	if _, err := ring.Sign([]byte("x")); !errors.Is(err, ErrNoActiveKey) {
+		t.Errorf("Sign() = %v, want ErrNoActiveKey", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := strings.Repeat("A", 43) + "="
+	keys, err := ParseKeys([]string{"k1:" + secret + ":2026-12-01T00:00:00Z", "k2:" + secret})
+	if err != nil || len(keys) != 2 || keys[0].ExpiresAt.IsZero() || !keys[1].ExpiresAt.IsZero() {
+		t.Errorf("ParseKeys() = %+v, %v", keys, err)
+	}
+	if _, err := ParseKeys([]string{"k1:" + secret + ":tomorrow"}); err == nil {
+		t.Error("ParseKeys() accepted an invalid expiry")
+	}
+}
@@ -0,0 +1,58 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/i18n"
+)
+
+// Update is the content of a weather update posted to Slack.
+type Update struct {
+	Locale       string // selects the message catalog, see i18n
+	Cities       []email.CityWeather
+	Units        string // email.UnitsMetric or email.UnitsImperial
+	ManageURL    string
+	Attributions []string // one line per provider that supplied an observation
+}
+
+// UpdateMessage formats u with Block Kit: a header naming the cities, a section per
+// city with its current weather (and tomorrow's forecast when known) and a footer with
+// the attributions and the manage link.
+func UpdateMessage(u Update) Message {
+	title := i18n.T(u.Locale, "update.subject", email.DescribeCities(u.Cities, u.Locale))
+	blocks := []Block{{Type: "header", Text: &Text{Type: "plain_text", Text: title}}}
+	for _, c := range u.Cities {
+		w := c.Weather
+		blocks = append(blocks, Block{
+			Type: "section",
+			Text: &Text{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", escape(c.City), escape(w.Description))},
+			Fields: []Text{
+				{Type: "mrkdwn", Text: escape(i18n.T(u.Locale, "update.temperature", email.FormatTemperature(w.Temp, u.Units)))},
+				{Type: "mrkdwn", Text: escape(i18n.T(u.Locale, "update.humidity", w.Humidity))},
+			},
+		})
+		if f := c.Tomorrow; f != nil {
+			blocks = append(blocks, Block{Type: "context", Elements: []Text{{Type: "mrkdwn",
+				Text: escape(i18n.T(u.Locale, "update.tomorrow", f.Description, email.FormatTemperature(f.MinTemp, u.Units),
+					email.FormatTemperature(f.MaxTemp, u.Units), f.ChanceOfRain))}}})
+		}
+	}
+
+	footer := make([]Text, 0, len(u.Attributions)+1)
+	for _, a := range u.Attributions {
+		footer = append(footer, Text{Type: "mrkdwn", Text: escape(a)})
+	}
+	footer = append(footer, Text{Type: "mrkdwn",
+		Text: fmt.Sprintf("<%s|%s>", u.ManageURL, escape(i18n.T(u.Locale, "slack.manage")))})
+	blocks = append(blocks, Block{Type: "divider"}, Block{Type: "context", Elements: footer})
+	return Message{Text: title, Blocks: blocks}
+}
+
+// mrkdwnEscaper escapes the characters Slack reserves for links and mentions.
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escape(s string) string {
+	return mrkdwnEscaper.Replace(s)
+}
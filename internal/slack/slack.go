@@ -0,0 +1,98 @@
+// Package slack posts weather updates to Slack incoming webhooks, formatted with
+// Block Kit.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webhookHost serves every Slack incoming webhook. Posting only there keeps webhook
+// URLs given by subscribers from pointing the scheduler at other hosts.
+const webhookHost = "hooks.slack.com"
+
+// ValidWebhookURL reports whether raw is a Slack incoming-webhook URL,
+// https://hooks.slack.com/services/...
+func ValidWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "https" && u.Host == webhookHost && u.User == nil &&
+		strings.HasPrefix(u.Path, "/services/") && len(u.Path) > len("/services/")
+}
+
+// Text is a Block Kit text object.
+type Text struct {
+	Type string `json:"type"` // "plain_text" or "mrkdwn"
+	Text string `json:"text"`
+}
+
+// Block is a Block Kit layout block; only the fields of its type are set.
+type Block struct {
+	Type     string `json:"type"` // "header", "section", "context" or "divider"
+	Text     *Text  `json:"text,omitempty"`
+	Fields   []Text `json:"fields,omitempty"`
+	Elements []Text `json:"elements,omitempty"`
+}
+
+// Message is the payload of an incoming webhook. Text is the fallback shown in
+// notifications; Blocks are shown in the channel.
+type Message struct {
+	Text   string  `json:"text"`
+	Blocks []Block `json:"blocks,omitempty"`
+}
+
+// Error is a webhook request Slack answered with an error status. Body names the
+// reason, e.g. "no_service" for a removed webhook or "channel_is_archived".
+type Error struct {
+	Status int
+	Body   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("slack answered %d: %s", e.Status, e.Body)
+}
+
+// Client posts messages to incoming webhooks.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient gives every webhook request timeout to complete.
+func NewClient(timeout time.Duration) *Client {
+	return &Client{http: &http.Client{Timeout: timeout}}
+}
+
+// Post sends msg to the incoming webhook at webhookURL.
+func (c *Client) Post(ctx context.Context, webhookURL string, msg Message) error {
+	if !ValidWebhookURL(webhookURL) {
+		return fmt.Errorf("not a slack webhook url")
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode != http.StatusOK {
+		return &Error{Status: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package slack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+func TestValidWebhookURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://hooks.slack.com/services/T000/B000/XXXX", true},
+		{"http://hooks.slack.com/services/T000/B000/XXXX", false},
+		{"https://hooks.slack.com.evil.test/services/T000/B000/XXXX", false},
+		{"https://user@hooks.slack.com/services/T000/B000/XXXX", false},
+		{"https://hooks.slack.com:8443/services/T000/B000/XXXX", false},
+		{"https://hooks.slack.com/services/", false},
+		{"https://hooks.slack.com/workflows/T000/XXXX", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		if got := ValidWebhookURL(tt.url); got != tt.want {
+			t.Errorf("ValidWebhookURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateMessage(t *testing.T) {
+	msg := UpdateMessage(Update{
+		Locale: "en",
+		Cities: []email.CityWeather{
+			{City: "Kyiv", Weather: types.Weather{Temp: 21.5, Humidity: 64, Description: "Sun & clouds"},
+				Tomorrow: &types.Forecast{MinTemp: 12, MaxTemp: 19, ChanceOfRain: 70, Description: "Light rain"}},
+			{City: "Lviv", Weather: types.Weather{Temp: 18, Humidity: 80, Description: "Overcast"}},
+		},
+		Units:        email.UnitsMetric,
+		ManageURL:    "https://weather.example/api/manage/abc",
+		Attributions: []string{"Powered by WeatherAPI.com"},
+	})
+
+	if msg.Text != "Weather update for Kyiv and 1 more" {
+		t.Errorf("Text = %q", msg.Text)
+	}
+	// header, Kyiv and its forecast, Lviv, divider, footer
+	if len(msg.Blocks) != 6 {
+		t.Fatalf("got %d blocks, want 6: %+v", len(msg.Blocks), msg.Blocks)
+	}
+	kyiv := msg.Blocks[1]
+	if kyiv.Text.Text != "*Kyiv*\nSun &amp; clouds" || kyiv.Fields[0].Text != "Temperature: 21.50°C" {
+		t.Errorf("Kyiv section = %+v", kyiv)
+	}
+	if !strings.Contains(msg.Blocks[2].Elements[0].Text, "70% chance of precipitation") {
+		t.Errorf("forecast = %+v", msg.Blocks[2])
+	}
+	footer := msg.Blocks[5].Elements
+	if footer[len(footer)-1].Text != "<https://weather.example/api/manage/abc|Manage your subscription>" {
+		t.Errorf("footer = %+v", footer)
+	}
+}
@@ -0,0 +1,25 @@
+package slo
+
+import (
+	"context"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// DeliverySource counts scheduled emails delivered within maxDelay of their slot,
+// using the deliveries table written by the scheduler.
+type DeliverySource struct {
+	repo     repository.DeliveryRepository
+	maxDelay time.Duration
+}
+
+// NewDeliverySource returns a Source backed by the delivery log.
+func NewDeliverySource(repo repository.DeliveryRepository, maxDelay time.Duration) *DeliverySource {
+	return &DeliverySource{repo: repo, maxDelay: maxDelay}
+}
+
+// Counts implements Source.
+func (s *DeliverySource) Counts(ctx context.Context, since time.Time) (good, total int64, err error) {
+	return s.repo.OnTimeStats(ctx, since, s.maxDelay)
+}
@@ -0,0 +1,78 @@
+package slo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LatencyRecorder keeps per-minute counts of requests served within a
+// threshold, for the last `retention` minutes. It is an in-process source,
+// suitable for objectives on requests handled by the same process.
+type LatencyRecorder struct {
+	threshold time.Duration
+
+	mu      sync.Mutex
+	buckets []latencyBucket
+	now     func() time.Time
+}
+
+type latencyBucket struct {
+	minute int64 // unix minute this bucket currently holds
+	good   int64
+	total  int64
+}
+
+// NewLatencyRecorder counts requests as good when they take at most threshold.
+func NewLatencyRecorder(threshold time.Duration, retention time.Duration) *LatencyRecorder {
+	n := int(retention / time.Minute)
+	if n < 1 {
+		n = 1
+	}
+	return &LatencyRecorder{
+		threshold: threshold,
+		buckets:   make([]latencyBucket, n),
+		now:       time.Now,
+	}
+}
+
+// Observe records one request that took d.
+func (r *LatencyRecorder) Observe(d time.Duration) {
+	r.add(d <= r.threshold)
+}
+
+// ObserveFailure records one request that failed, regardless of its latency.
+func (r *LatencyRecorder) ObserveFailure() {
+	r.add(false)
+}
+
+func (r *LatencyRecorder) add(good bool) {
+	minute := r.now().Unix() / 60
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := &r.buckets[minute%int64(len(r.buckets))]
+	if b.minute != minute {
+		*b = latencyBucket{minute: minute}
+	}
+	b.total++
+	if good {
+		b.good++
+	}
+}
+
+// Counts implements Source.
+func (r *LatencyRecorder) Counts(_ context.Context, since time.Time) (good, total int64, err error) {
+	from := since.Unix() / 60
+	to := r.now().Unix() / 60
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, b := range r.buckets {
+		if b.minute >= from && b.minute <= to {
+			good += b.good
+			total += b.total
+		}
+	}
+	return good, total, nil
+}
@@ -0,0 +1,145 @@
+package slo
+
+import (
+	"context"
+	"time"
+)
+
+// Objective is a service level objective: the fraction of events that must be good.
+type Objective struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Target      float64 `json:"target"` // e.g. 0.99
+}
+
+// Source counts good and total events of an objective since a point in time.
+type Source interface {
+	Counts(ctx context.Context, since time.Time) (good, total int64, err error)
+}
+
+// Window is a look-back period over which the burn rate is evaluated.
+type Window struct {
+	Name     string
+	Duration time.Duration
+	// Short is the look-back confirming an alert of the window: it alerts while the burn
+	// rates over both Duration and Short reach AlertBurnRate, so an alert stops soon
+	// after the burn does. A window without one is reported for context only.
+	Short time.Duration
+	// AlertBurnRate is the burn rate at which the window alerts.
+	AlertBurnRate float64
+}
+
+// DefaultWindows follow the multi-window burn rate alerting recipe: a fast burn over
+// 1h confirmed over 5m (2% of a 30-day budget) and a slow burn over 6h confirmed over
+// 30m (5%), plus a 24h window for context.
+var DefaultWindows = []Window{
+	{Name: "1h", Duration: time.Hour, Short: 5 * time.Minute, AlertBurnRate: 14.4},
+	{Name: "6h", Duration: 6 * time.Hour, Short: 30 * time.Minute, AlertBurnRate: 6},
+	{Name: "24h", Duration: 24 * time.Hour},
+}
+
+// WindowStatus is the evaluation of one objective over one window.
+type WindowStatus struct {
+	Window        string  `json:"window"`
+	Good          int64   `json:"good"`
+	Total         int64   `json:"total"`
+	SLI           float64 `json:"sli"`
+	BurnRate      float64 `json:"burn_rate"`
+	ShortBurnRate float64 `json:"short_burn_rate,omitempty"` // over the Short look-back
+	Alerting      bool    `json:"alerting"`
+}
+
+// Status is the evaluation of one objective over all windows.
+type Status struct {
+	Objective
+	Windows  []WindowStatus `json:"windows"`
+	Alerting bool           `json:"alerting"`
+	Error    string         `json:"error,omitempty"`
+}
+
+type trackedObjective struct {
+	objective Objective
+	source    Source
+}
+
+// Tracker evaluates burn rates of registered objectives.
+type Tracker struct {
+	objectives []trackedObjective
+	windows    []Window
+	now        func() time.Time
+}
+
+// NewTracker returns a Tracker evaluating DefaultWindows.
+func NewTracker() *Tracker {
+	return &Tracker{windows: DefaultWindows, now: time.Now}
+}
+
+// Register adds an objective backed by source.
+func (t *Tracker) Register(o Objective, source Source) {
+	t.objectives = append(t.objectives, trackedObjective{objective: o, source: source})
+}
+
+// Report evaluates every objective over every window. A failing source is
+// reported in its Status instead of failing the whole report.
+func (t *Tracker) Report(ctx context.Context) []Status {
+	now := t.now()
+	statuses := make([]Status, 0, len(t.objectives))
+	for _, tracked := range t.objectives {
+		st := Status{Objective: tracked.objective}
+		for _, w := range t.windows {
+			ws, err := t.evaluate(ctx, tracked, w, now)
+			if err != nil {
+				st.Error = err.Error()
+				break
+			}
+			st.Windows = append(st.Windows, ws)
+			st.Alerting = st.Alerting || ws.Alerting
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// evaluate evaluates the objective over the window w ending at now. The window alerts
+// when it and its short look-back both burn too fast, which filters out short spikes
+// while still catching sustained burns.
+func (t *Tracker) evaluate(ctx context.Context, tracked trackedObjective, w Window, now time.Time) (WindowStatus, error) {
+	target := tracked.objective.Target
+	good, total, err := tracked.source.Counts(ctx, now.Add(-w.Duration))
+	if err != nil {
+		return WindowStatus{}, err
+	}
+	ws := WindowStatus{Window: w.Name, Good: good, Total: total, SLI: sli(good, total)}
+	ws.BurnRate = burnRate(target, ws.SLI)
+	if w.Short <= 0 {
+		return ws, nil
+	}
+	good, total, err = tracked.source.Counts(ctx, now.Add(-w.Short))
+	if err != nil {
+		return WindowStatus{}, err
+	}
+	ws.ShortBurnRate = burnRate(target, sli(good, total))
+	ws.Alerting = ws.BurnRate >= w.AlertBurnRate && ws.ShortBurnRate >= w.AlertBurnRate
+	return ws, nil
+}
+
+// sli is the fraction of good events; 1 without events.
+func sli(good, total int64) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(good) / float64(total)
+}
+
+// burnRate is the observed error rate divided by the error budget (1 - target). A burn
+// rate of 1 spends the budget exactly.
+func burnRate(target, sli float64) float64 {
+	budget := 1 - target
+	if budget <= 0 {
+		if sli < 1 {
+			return 1
+		}
+		return 0
+	}
+	return (1 - sli) / budget
+}
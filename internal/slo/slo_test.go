@@ -0,0 +1,106 @@
+package slo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBurnRate(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		target, sli float64
+		want        float64
+	}{
+		{"no errors", 0.99, 1, 0},
+		{"spending the budget exactly", 0.99, 0.99, 1},
+		{"fast burn", 0.99, 0.856, 14.4},
+		{"everything failing", 0.999, 0, 1000},
+		{"target of 1 with errors", 1, 0.5, 1},
+		{"target of 1 without errors", 1, 1, 0},
+	} {
+		if got := burnRate(tc.target, tc.sli); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%s: burnRate(%v, %v) = %v, want %v", tc.name, tc.target, tc.sli, got, tc.want)
+		}
+	}
+}
+
+// spanSource counts the events given by the length of the look-back asked for.
+type spanSource struct {
+	now    time.Time
+	counts map[time.Duration][2]int64 // good, total
+	err    error
+}
+
+func (s spanSource) Counts(_ context.Context, since time.Time) (good, total int64, err error) {
+	c := s.counts[s.now.Sub(since)]
+	return c[0], c[1], s.err
+}
+
+func TestTracker_Report(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	// 1000 events, failed of them failing, at a target of 0.99
+	errs := func(failed int64) [2]int64 { return [2]int64{1000 - failed, 1000} }
+	for _, tc := range []struct {
+		name       string
+		counts     map[time.Duration][2]int64
+		want       bool
+		alertingIn string
+	}{
+		{"healthy", map[time.Duration][2]int64{
+			time.Hour: errs(1), 5 * time.Minute: errs(1), 6 * time.Hour: errs(1), 30 * time.Minute: errs(1),
+			24 * time.Hour: errs(1),
+		}, false, ""},
+		{"fast burn going on", map[time.Duration][2]int64{
+			time.Hour: errs(150), 5 * time.Minute: errs(200),
+		}, true, "1h"},
+		{"fast burn over", map[time.Duration][2]int64{
+			time.Hour: errs(150), 5 * time.Minute: errs(0),
+		}, false, ""},
+		{"spike too short for the hour", map[time.Duration][2]int64{
+			time.Hour: errs(100), 5 * time.Minute: errs(900),
+		}, false, ""},
+		{"slow burn going on", map[time.Duration][2]int64{
+			time.Hour: errs(70), 5 * time.Minute: errs(70), 6 * time.Hour: errs(60), 30 * time.Minute: errs(70),
+		}, true, "6h"},
+		{"slow burn over", map[time.Duration][2]int64{
+			6 * time.Hour: errs(60), 30 * time.Minute: errs(10),
+		}, false, ""},
+		{"a day of errors is context only", map[time.Duration][2]int64{
+			24 * time.Hour: errs(1000),
+		}, false, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := NewTracker()
+			tracker.now = func() time.Time { return now }
+			tracker.Register(Objective{Name: "latency", Target: 0.99}, spanSource{now: now, counts: tc.counts})
+
+			st := tracker.Report(context.Background())[0]
+			if st.Alerting != tc.want || len(st.Windows) != len(DefaultWindows) {
+				t.Fatalf("Report() = %+v, want alerting %t", st, tc.want)
+			}
+			for _, ws := range st.Windows {
+				if ws.Alerting != (ws.Window == tc.alertingIn) {
+					t.Errorf("window %s alerting = %t, burn rates %v and %v", ws.Window, ws.Alerting, ws.BurnRate,
+						ws.ShortBurnRate)
+				}
+			}
+		})
+	}
+}
+
+func TestTracker_Report_SourceDown(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Register(Objective{Name: "delivery", Target: 0.99}, spanSource{err: errors.New("connection refused")})
+	tracker.Register(Objective{Name: "latency", Target: 0.99}, spanSource{})
+
+	statuses := tracker.Report(context.Background())
+	if statuses[0].Error != "connection refused" || statuses[0].Alerting {
+		t.Errorf("failing source: %+v", statuses[0])
+	}
+	if statuses[1].Error != "" || len(statuses[1].Windows) != len(DefaultWindows) || statuses[1].Windows[0].SLI != 1 {
+		t.Errorf("source without events: %+v", statuses[1])
+	}
+}
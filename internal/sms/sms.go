@@ -0,0 +1,95 @@
+// Package sms sends text messages through Twilio Programmable Messaging.
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+// Sender sends a text message to a phone number.
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+// e164 matches international phone numbers: "+", a country code and up to 15 digits.
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// ValidPhone reports whether phone is an E.164 number, e.g. "+380501234567".
+func ValidPhone(phone string) bool {
+	return e164.MatchString(phone)
+}
+
+// New returns the Twilio sender configured by TWILIO_*, nil when SMS is off.
+func New(cfg *config.Config) Sender {
+	if cfg.TwilioAccountSID == "" {
+		return nil
+	}
+	return &Twilio{
+		url: fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json",
+			strings.TrimSuffix(cfg.TwilioAPIURL, "/"), url.PathEscape(cfg.TwilioAccountSID)),
+		accountSID: cfg.TwilioAccountSID,
+		authToken:  cfg.TwilioAuthToken,
+		from:       cfg.TwilioFrom,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Twilio is a Sender using the Twilio Messages API.
+type Twilio struct {
+	url        string
+	accountSID string
+	authToken  string
+	from       string // phone number, or messaging service SID ("MG...")
+	client     *http.Client
+}
+
+// Error is a message Twilio refused. Code is the Twilio error code, e.g. 21610 for a
+// recipient that replied STOP, or 21211 for an invalid number.
+type Error struct {
+	Status  int
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("twilio answered %d: %d %s", e.Status, e.Code, e.Message)
+}
+
+// Send queues body for delivery to the phone number to (E.164).
+func (t *Twilio) Send(ctx context.Context, to, body string) error {
+	form := url.Values{"To": {to}, "Body": {body}}
+	if strings.HasPrefix(t.from, "MG") {
+		form.Set("MessagingServiceSid", t.from)
+	} else {
+		form.Set("From", t.from)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	twErr := &Error{Status: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(data, twErr) != nil || twErr.Message == "" {
+		twErr.Message = strings.TrimSpace(string(data))
+	}
+	return twErr
+}
@@ -0,0 +1,69 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+func TestTwilio_Send(t *testing.T) {
+	var got http.Header
+	var form map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		got = r.Header
+		_ = r.ParseForm()
+		form = r.PostForm
+		if r.PostForm.Get("To") == "+15005550009" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code": 21610, "message": "Attempt to send to unsubscribed recipient", "status": 400}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	s := New(&config.Config{TwilioAccountSID: "AC123", TwilioAuthToken: "secret", TwilioFrom: "+15005550006",
+		TwilioAPIURL: srv.URL})
+	if err := s.Send(context.Background(), "+380501234567", "Kyiv: 21.5°C"); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if user, pass, ok := (&http.Request{Header: got}).BasicAuth(); !ok || user != "AC123" || pass != "secret" {
+		t.Errorf("basic auth = %q, %q", user, pass)
+	}
+	if form["From"][0] != "+15005550006" || form["To"][0] != "+380501234567" || form["Body"][0] != "Kyiv: 21.5°C" {
+		t.Errorf("form = %v", form)
+	}
+
+	var twErr *Error
+	if err := s.Send(context.Background(), "+15005550009", "hi"); !errors.As(err, &twErr) || twErr.Code != 21610 {
+		t.Errorf("Send(opted out) error = %v, want Twilio error 21610", err)
+	}
+}
+
+func TestNew_OffWithoutAccount(t *testing.T) {
+	if s := New(&config.Config{}); s != nil {
+		t.Errorf("New() = %v, want nil without TWILIO_ACCOUNT_SID", s)
+	}
+}
+
+func TestValidPhone(t *testing.T) {
+	for phone, want := range map[string]bool{
+		"+380501234567": true,
+		"+15005550006":  true,
+		"380501234567":  false,
+		"+0501234567":   false,
+		"+38 050 123":   false,
+		"+1234":         false,
+	} {
+		if got := ValidPhone(phone); got != want {
+			t.Errorf("ValidPhone(%q) = %v, want %v", phone, got, want)
+		}
+	}
+}
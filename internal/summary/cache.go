@@ -0,0 +1,49 @@
+package summary
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+)
+
+// Cached decorates a Summarizer with a Redis cache keyed by the facts it is given, so
+// subscribers of the same cities share one summary while the weather stays the same.
+type Cached struct {
+	inner  Summarizer
+	redis  *redis.Client
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+func NewCached(inner Summarizer, rdb *redis.Client, ttl time.Duration, logger *zap.Logger) *Cached {
+	return &Cached{inner: inner, redis: rdb, ttl: ttl, logger: logger}
+}
+
+func (c *Cached) Summarize(ctx context.Context, cities []email.CityWeather, units string) (string, error) {
+	sum := sha256.Sum256([]byte(facts(cities, units)))
+	key := "summary:" + hex.EncodeToString(sum[:])
+
+	s, err := c.redis.Get(ctx, key).Result()
+	if err == nil {
+		return s, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		c.logger.Warn("redis GET failed", zap.Error(err))
+	}
+
+	s, err = c.inner.Summarize(ctx, cities, units)
+	if err != nil {
+		return "", err
+	}
+	if err := c.redis.Set(ctx, key, s, c.ttl).Err(); err != nil {
+		c.logger.Warn("redis SET failed", zap.Error(err))
+	}
+	return s, nil
+}
@@ -0,0 +1,100 @@
+package summary
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+)
+
+const systemPrompt = "You write the opening of a daily weather email. Given the current weather of " +
+	"one or more cities, answer with exactly two short, friendly sentences in plain text: what the " +
+	"weather is like, then one practical tip. Use only the figures given and keep their units."
+
+// maxSummaryLen bounds an answer; anything longer is treated as a failure.
+const maxSummaryLen = 400
+
+// LLM summarizes with a chat completions endpoint (the OpenAI API or a compatible one).
+// Every call is bounded by timeout, whatever the deadline of the caller.
+type LLM struct {
+	url     string
+	apiKey  string
+	model   string
+	timeout time.Duration
+	client  *http.Client
+}
+
+func NewLLM(url, apiKey, model string, timeout time.Duration) *LLM {
+	return &LLM{url: url, apiKey: apiKey, model: model, timeout: timeout, client: &http.Client{}}
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (l *LLM) Summarize(ctx context.Context, cities []email.CityWeather, units string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	payload, err := json.Marshal(map[string]any{
+		"model":       l.model,
+		"messages":    []chatMessage{{"system", systemPrompt}, {"user", facts(cities, units)}},
+		"max_tokens":  120,
+		"temperature": 0.3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("summary llm: marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("summary llm: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+l.apiKey)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("summary llm: HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return "", fmt.Errorf("summary llm: unexpected status %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	var body struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("summary llm: JSON decode error: %w", err)
+	}
+	if len(body.Choices) == 0 {
+		return "", fmt.Errorf("summary llm: no choices in the response")
+	}
+	s := strings.Join(strings.Fields(body.Choices[0].Message.Content), " ")
+	if s == "" || len(s) > maxSummaryLen {
+		return "", fmt.Errorf("summary llm: unusable answer of %d bytes", len(s))
+	}
+	return s, nil
+}
+
+// facts is the user prompt: one line per city, with temperatures as the email shows them.
+func facts(cities []email.CityWeather, units string) string {
+	var b strings.Builder
+	for _, c := range cities {
+		fmt.Fprintf(&b, "%s: %s, %s, humidity %d%%\n",
+			c.City, describe(c.Weather.Description), email.FormatTemperature(c.Weather.Temp, units), c.Weather.Humidity)
+	}
+	return b.String()
+}
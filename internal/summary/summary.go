@@ -0,0 +1,133 @@
+// Package summary turns the current weather of an email's cities into a two-sentence
+// summary shown at the top of daily emails, behind the ai_summaries feature flag.
+//
+// The built-in Template summarizer needs no external service. An LLM backend can be
+// configured with SUMMARY_LLM_URL: it runs under a strict timeout, its answers are
+// cached in Redis, and whenever it fails the template is used instead.
+package summary
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	redis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+)
+
+// Summarizer writes a short human summary of the weather of cities, in units.
+type Summarizer interface {
+	Summarize(ctx context.Context, cities []email.CityWeather, units string) (string, error)
+}
+
+// New returns the summarizer configured by SUMMARY_LLM_URL: the template alone, or the
+// cached LLM backend falling back to the template.
+func New(cfg *config.Config, rdb *redis.Client, logger *zap.Logger) Summarizer {
+	if cfg.SummaryLLMURL == "" {
+		return Template{}
+	}
+	llm := NewLLM(cfg.SummaryLLMURL, cfg.SummaryLLMAPIKey, cfg.SummaryLLMModel, cfg.SummaryLLMTimeout)
+	return NewFallback(NewCached(llm, rdb, cfg.SummaryCacheTTL, logger), Template{}, logger)
+}
+
+// Template summarizes from fixed phrases: the temperatures, then the one piece of advice
+// that matters most.
+type Template struct{}
+
+func (Template) Summarize(_ context.Context, cities []email.CityWeather, units string) (string, error) {
+	if len(cities) == 0 {
+		return "", fmt.Errorf("summary: no cities")
+	}
+	return overview(cities, units) + " " + advice(cities), nil
+}
+
+// overview is the first sentence: the weather of a single city, or the temperature range
+// of several.
+func overview(cities []email.CityWeather, units string) string {
+	if len(cities) == 1 {
+		c := cities[0]
+		return fmt.Sprintf("Today in %s: %s at %s, with %d%% humidity.",
+			c.City, describe(c.Weather.Description), email.FormatTemperature(c.Weather.Temp, units), c.Weather.Humidity)
+	}
+	coldest, warmest := cities[0], cities[0]
+	for _, c := range cities[1:] {
+		if c.Weather.Temp < coldest.Weather.Temp {
+			coldest = c
+		}
+		if c.Weather.Temp > warmest.Weather.Temp {
+			warmest = c
+		}
+	}
+	if coldest.Weather.Temp == warmest.Weather.Temp {
+		return fmt.Sprintf("It is %s in all %d of your cities today.",
+			email.FormatTemperature(coldest.Weather.Temp, units), len(cities))
+	}
+	return fmt.Sprintf("Temperatures today range from %s in %s to %s in %s.",
+		email.FormatTemperature(coldest.Weather.Temp, units), coldest.City,
+		email.FormatTemperature(warmest.Weather.Temp, units), warmest.City)
+}
+
+// conditions, most important first; the first one met by any city gives the advice.
+var conditions = []struct {
+	met    func(c email.CityWeather) bool
+	advice string // %s is the city
+}{
+	{func(c email.CityWeather) bool { return mentions(c, "thunder", "storm") }, "Storms are possible in %s, so keep an eye on the sky."},
+	{func(c email.CityWeather) bool { return mentions(c, "snow", "sleet", "blizzard") }, "Dress warmly and watch for snow on the roads in %s."},
+	{func(c email.CityWeather) bool { return mentions(c, "rain", "drizzle", "shower") }, "Take an umbrella if you are heading out in %s."},
+	{func(c email.CityWeather) bool { return c.Weather.Temp <= 0 }, "It is freezing in %s, so dress warmly."},
+	{func(c email.CityWeather) bool { return c.Weather.Temp >= 28 }, "It is hot in %s, so stay in the shade and drink plenty of water."},
+	{func(c email.CityWeather) bool { return mentions(c, "fog", "mist", "haze") }, "Visibility may be poor in %s, so take care on the roads."},
+}
+
+// advice is the second sentence.
+func advice(cities []email.CityWeather) string {
+	for _, cond := range conditions {
+		for _, c := range cities {
+			if cond.met(c) {
+				return fmt.Sprintf(cond.advice, c.City)
+			}
+		}
+	}
+	return "It looks like a good day to spend some time outside."
+}
+
+func mentions(c email.CityWeather, words ...string) bool {
+	desc := strings.ToLower(c.Weather.Description)
+	for _, w := range words {
+		if strings.Contains(desc, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(desc string) string {
+	if desc = strings.ToLower(strings.TrimSpace(desc)); desc == "" {
+		return "changeable weather"
+	}
+	return desc
+}
+
+// Fallback uses primary, and fallback whenever primary fails.
+type Fallback struct {
+	primary  Summarizer
+	fallback Summarizer
+	logger   *zap.Logger
+}
+
+func NewFallback(primary, fallback Summarizer, logger *zap.Logger) *Fallback {
+	return &Fallback{primary: primary, fallback: fallback, logger: logger}
+}
+
+func (f *Fallback) Summarize(ctx context.Context, cities []email.CityWeather, units string) (string, error) {
+	s, err := f.primary.Summarize(ctx, cities, units)
+	if err == nil {
+		return s, nil
+	}
+	f.logger.Warn("summarizer failed, using the fallback", zap.Error(err))
+	return f.fallback.Summarize(ctx, cities, units)
+}
@@ -0,0 +1,78 @@
+package summary
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/email"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+func city(name string, temp float64, desc string) email.CityWeather {
+	return email.CityWeather{City: name, Weather: types.Weather{Temp: temp, Humidity: 70, Description: desc}}
+}
+
+func TestTemplate_Summarize(t *testing.T) {
+	cases := []struct {
+		name   string
+		cities []email.CityWeather
+		want   string
+	}{
+		{"one city", []email.CityWeather{city("Kyiv", 12.5, "Light rain")},
+			"Today in Kyiv: light rain at 12.50°C, with 70% humidity. Take an umbrella if you are heading out in Kyiv."},
+		{"range", []email.CityWeather{city("Lviv", 8, "Clear"), city("Odesa", 30, "Sunny"), city("Kyiv", 9, "Snow")},
+			"Temperatures today range from 8.00°C in Lviv to 30.00°C in Odesa. Dress warmly and watch for snow on the roads in Kyiv."},
+		{"fine", []email.CityWeather{city("Lviv", 18, "Clear"), city("Kyiv", 18, "Partly cloudy")},
+			"It is 18.00°C in all 2 of your cities today. It looks like a good day to spend some time outside."},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Template{}.Summarize(context.Background(), tc.cities, email.UnitsMetric)
+			if err != nil {
+				t.Fatalf("Summarize() error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Summarize() = %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLLM_FallsBackOnTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "secret") {
+			t.Errorf("missing API key")
+		}
+		if strings.Contains(r.URL.RawQuery, "slow") {
+			select {
+			case <-time.After(300 * time.Millisecond):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":" Mild and dry in Kyiv.\n Enjoy it. "}}]}`))
+	}))
+	defer srv.Close()
+	cities := []email.CityWeather{city("Kyiv", 15, "Clear")}
+
+	s := NewFallback(NewLLM(srv.URL, "secret", "m", 50*time.Millisecond), Template{}, zap.NewNop())
+	if got, _ := s.Summarize(context.Background(), cities, email.UnitsMetric); got != "Mild and dry in Kyiv. Enjoy it." {
+		t.Errorf("Summarize() = %q, want the model's answer", got)
+	}
+
+	s = NewFallback(NewLLM(srv.URL+"?slow", "secret", "m", 50*time.Millisecond), Template{}, zap.NewNop())
+	start := time.Now()
+	got, err := s.Summarize(context.Background(), cities, email.UnitsMetric)
+	if err != nil || !strings.HasPrefix(got, "Today in Kyiv: clear") {
+		t.Errorf("Summarize() = %q, %v; want the template summary", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("a slow model held the email for %v", elapsed)
+	}
+}
@@ -0,0 +1,69 @@
+package synthetic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// AlertName names the alert of a missed synthetic email.
+const AlertName = "synthetic_delivery_missing"
+
+// Alert statuses.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Alert is a change of the alerting state, sent to the operator.
+type Alert struct {
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	Summary      string    `json:"summary"`
+	At           time.Time `json:"at"`
+	LastObserved time.Time `json:"last_observed,omitzero"`
+}
+
+// Alerter notifies the operator.
+type Alerter interface {
+	Alert(ctx context.Context, a Alert) error
+}
+
+// WebhookAlerter POSTs alerts as JSON, e.g. to an incident management or chat webhook.
+type WebhookAlerter struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookAlerter returns an alerter posting to url, or nil when url is empty.
+func NewWebhookAlerter(url string) Alerter {
+	if url == "" {
+		return nil
+	}
+	return &WebhookAlerter{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Alert implements Alerter. Any status but 2xx is an error.
+func (w *WebhookAlerter) Alert(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("synthetic: marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("synthetic: failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("synthetic: HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("synthetic: unexpected status %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
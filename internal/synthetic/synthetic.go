@@ -0,0 +1,167 @@
+// Package synthetic monitors the whole delivery pipeline end to end with a built-in
+// subscriber.
+//
+// The synthetic subscriber is a real, confirmed hourly subscription of an operator-owned
+// mailbox: its weather updates are selected, rendered and sent by the scheduler like
+// any other. The mailbox reports every email it receives to the observation webhook
+// (an inbound parse or forwarding rule of the mail provider), and the scheduler checks
+// that one arrived in every hour. An email not observed within an hour plus the SLO
+// means something between the database and the recipient's inbox is broken, whatever
+// the component, and the operator is alerted.
+package synthetic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/repository"
+)
+
+// Interval is how often the synthetic subscriber gets an email.
+const Interval = time.Hour
+
+// CheckSpec runs the monitor every five minutes.
+const CheckSpec = "*/5 * * * *"
+
+var (
+	lastObserved = metrics.NewGauge("weather_synthetic_last_observed_timestamp_seconds",
+		"Time the synthetic subscriber last received an email.")
+	alerting = metrics.NewGauge("weather_synthetic_alerting",
+		"1 while the synthetic subscriber has not received its hourly email within the SLO.")
+	alertsFired = metrics.NewCounter("weather_synthetic_alerts_total",
+		"Alerts raised because the synthetic subscriber did not receive its email.")
+)
+
+// observedKey holds the Unix time of the last email the synthetic mailbox received.
+const observedKey = "synthetic:last_observed"
+
+// Store keeps the time of the last observed delivery in Redis, shared by the API (which
+// receives the webhook) and the scheduler (which checks it).
+type Store struct {
+	rdb *redis.Client
+}
+
+func NewStore(rdb *redis.Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+// Observe records that the synthetic mailbox received an email at.
+func (s *Store) Observe(ctx context.Context, at time.Time) error {
+	return s.rdb.Set(ctx, observedKey, at.Unix(), 0).Err()
+}
+
+// LastObserved returns the time of the last observed email, zero if none ever was.
+func (s *Store) LastObserved(ctx context.Context) (time.Time, error) {
+	raw, err := s.rdb.Get(ctx, observedKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	unix, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: %w", observedKey, raw, err)
+	}
+	return time.Unix(unix, 0), nil
+}
+
+// SubscriptionCreator creates and confirms subscriptions.
+type SubscriptionCreator interface {
+	Create(ctx context.Context, n repository.NewSubscription) (int, uuid.UUID, uuid.UUID, error)
+	Confirm(ctx context.Context, token uuid.UUID, ttl time.Duration) (int, error)
+}
+
+// EnsureSubscription subscribes email to hourly updates for city and confirms it, unless
+// it is already subscribed.
+func EnsureSubscription(ctx context.Context, repo SubscriptionCreator, email, city string, logger *zap.Logger) error {
+	_, confirmToken, _, err := repo.Create(ctx, repository.NewSubscription{Email: email, Cities: []string{city},
+		Frequency: repository.FrequencyHourly, Timezone: "UTC", Locale: "en"})
+	if errors.Is(err, repository.ErrEmailAlreadyExists) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("synthetic: create subscription: %w", err)
+	}
+	id, err := repo.Confirm(ctx, confirmToken, 0)
+	if err != nil {
+		return fmt.Errorf("synthetic: confirm subscription: %w", err)
+	}
+	logger.Info("created the synthetic subscription", zap.Int("id", id), zap.String("city", city))
+	return nil
+}
+
+// Observations reports when the synthetic mailbox last received an email.
+type Observations interface {
+	LastObserved(ctx context.Context) (time.Time, error)
+}
+
+// Monitor alerts when the synthetic subscriber misses its hourly email.
+type Monitor struct {
+	observations Observations
+	slo          time.Duration
+	alerter      Alerter // nil only logs and exports the alert
+	logger       *zap.Logger
+	started      time.Time
+	alerting     bool
+}
+
+// NewMonitor returns a monitor allowing slo past the hour for every email. alerter may
+// be nil.
+func NewMonitor(observations Observations, slo time.Duration, alerter Alerter, logger *zap.Logger) *Monitor {
+	return &Monitor{observations: observations, slo: slo, alerter: alerter, logger: logger, started: time.Now()}
+}
+
+// Check fires the alert when no email was observed for an hour plus the SLO, and
+// resolves it once one is. The first email is awaited from the start of the monitor, so
+// a fresh deploy does not alert on an old observation.
+func (m *Monitor) Check(ctx context.Context, now time.Time) {
+	last, err := m.observations.LastObserved(ctx)
+	if err != nil {
+		m.logger.Error("failed to read synthetic observations", zap.Error(err))
+		return
+	}
+	if !last.IsZero() {
+		lastObserved.SetToTime(last)
+	}
+
+	since := last
+	if since.Before(m.started) {
+		since = m.started
+	}
+	late := now.Sub(since) > Interval+m.slo
+
+	switch {
+	case late && !m.alerting:
+		m.alerting = true
+		alerting.Set(1)
+		alertsFired.Inc()
+		m.logger.Error("synthetic subscriber did not receive its email within the SLO",
+			zap.Time("last_observed", last), zap.Duration("slo", m.slo))
+		m.notify(ctx, Alert{Name: AlertName, Status: StatusFiring, At: now, LastObserved: last,
+			Summary: fmt.Sprintf("No weather email reached the synthetic subscriber within %s of its hourly slot.", m.slo)})
+	case !late && m.alerting:
+		m.alerting = false
+		alerting.Set(0)
+		m.logger.Info("synthetic subscriber received its email again", zap.Time("last_observed", last))
+		m.notify(ctx, Alert{Name: AlertName, Status: StatusResolved, At: now, LastObserved: last,
+			Summary: "The synthetic subscriber receives its weather emails again."})
+	}
+}
+
+func (m *Monitor) notify(ctx context.Context, a Alert) {
+	if m.alerter == nil {
+		return
+	}
+	if err := m.alerter.Alert(ctx, a); err != nil {
+		m.logger.Error("failed to send synthetic monitoring alert", zap.String("status", a.Status), zap.Error(err))
+	}
+}
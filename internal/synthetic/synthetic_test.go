@@ -0,0 +1,45 @@
+package synthetic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type fixedObservations struct{ last time.Time }
+
+func (o *fixedObservations) LastObserved(context.Context) (time.Time, error) { return o.last, nil }
+
+type recordingAlerter struct{ alerts []Alert }
+
+func (a *recordingAlerter) Alert(_ context.Context, alert Alert) error {
+	a.alerts = append(a.alerts, alert)
+	return nil
+}
+
+func TestMonitor_Check(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	obs := &fixedObservations{last: start.Add(-3 * time.Hour)} // before the deploy
+	alerter := &recordingAlerter{}
+	m := NewMonitor(obs, 15*time.Minute, alerter, zap.NewNop())
+	m.started = start
+
+	m.Check(context.Background(), start.Add(70*time.Minute))
+	if len(alerter.alerts) != 0 {
+		t.Fatalf("alerted %+v within an hour plus the SLO of the start", alerter.alerts)
+	}
+
+	m.Check(context.Background(), start.Add(80*time.Minute))
+	m.Check(context.Background(), start.Add(85*time.Minute))
+	if len(alerter.alerts) != 1 || alerter.alerts[0].Status != StatusFiring {
+		t.Fatalf("alerts = %+v, want one firing alert", alerter.alerts)
+	}
+
+	obs.last = start.Add(86 * time.Minute)
+	m.Check(context.Background(), start.Add(90*time.Minute))
+	if len(alerter.alerts) != 2 || alerter.alerts[1].Status != StatusResolved {
+		t.Fatalf("alerts = %+v, want the alert resolved once an email is observed", alerter.alerts)
+	}
+}
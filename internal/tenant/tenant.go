@@ -0,0 +1,166 @@
+// Package tenant tells apart the white-label newsletters served by one deployment.
+//
+// Every tenant has its own subscribers, isolated from the others': an API request
+// belongs to one tenant, resolved from its API key or else its Host header, and the
+// repository scopes the queries of the request to it. Tenants are set with TENANTS,
+// "id=host" (host is the domain its subscribers reach the API on, which the links of
+// its emails start with), and their API keys with TENANT_API_KEYS, "id=key". Requests
+// of no configured tenant, and everything before tenants were configured, belong to
+// the default tenant.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+)
+
+// Default is the tenant of the requests of no configured tenant.
+const Default = "default"
+
+// HeaderAPIKey carries the API key of a tenant; it takes precedence over the Host.
+const HeaderAPIKey = "X-API-Key"
+
+// validID restricts ids to what is safe in logs, cache keys and URLs.
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Registry holds the configured tenants. A nil *Registry has the default tenant only.
+type Registry struct {
+	hosts    map[string]string // lower-case host, without port → tenant
+	keys     map[string]string // API key → tenant
+	baseURLs map[string]string // tenant → base URL of its links
+}
+
+// New parses TENANTS and TENANT_API_KEYS. The links of a tenant take the scheme and
+// path prefix of BASE_URL on its host.
+func New(cfg *config.Config) (*Registry, error) {
+	return Parse(cfg.Tenants, cfg.TenantAPIKeys, cfg.BaseURL)
+}
+
+// Parse parses "id=host" tenant entries and "id=key" API key entries; the base URL
+// of a tenant is baseURL with its host.
+func Parse(tenants, apiKeys []string, baseURL string) (*Registry, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("base URL: %w", err)
+	}
+	r := &Registry{
+		hosts:    make(map[string]string, len(tenants)),
+		keys:     make(map[string]string, len(apiKeys)),
+		baseURLs: make(map[string]string, len(tenants)),
+	}
+	for _, e := range tenants {
+		id, host, ok := strings.Cut(e, "=")
+		id, host = strings.TrimSpace(id), strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" || !validID.MatchString(id) || id == Default {
+			return nil, fmt.Errorf("invalid tenant %q: want id=host, id of lower-case letters, digits, - and _", e)
+		}
+		if _, dup := r.baseURLs[id]; dup {
+			return nil, fmt.Errorf("tenant %s is listed twice", id)
+		}
+		if other, dup := r.hosts[stripPort(host)]; dup {
+			return nil, fmt.Errorf("tenants %s and %s share host %s", other, id, host)
+		}
+		r.hosts[stripPort(host)] = id
+		u := *base
+		u.Host = host
+		r.baseURLs[id] = u.String()
+	}
+	for _, e := range apiKeys {
+		id, key, ok := strings.Cut(e, "=")
+		id, key = strings.TrimSpace(id), strings.TrimSpace(key)
+		if _, known := r.baseURLs[id]; !ok || !known {
+			return nil, fmt.Errorf("API key of unknown tenant %q", id)
+		}
+		if len(key) < 16 {
+			return nil, fmt.Errorf("API key of tenant %s must be at least 16 characters", id)
+		}
+		if _, dup := r.keys[key]; dup {
+			return nil, fmt.Errorf("API key of tenant %s is not unique", id)
+		}
+		r.keys[key] = id
+	}
+	return r, nil
+}
+
+// stripPort drops the port of a Host header, if any.
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// Resolve returns the tenant of req: the one of its API key, else the one of its
+// Host, else Default. An unknown API key is ignored, so a client rotating keys falls
+// back to its host.
+func (r *Registry) Resolve(req *http.Request) string {
+	if r == nil {
+		return Default
+	}
+	if key := req.Header.Get(HeaderAPIKey); key != "" {
+		if id, ok := r.keys[key]; ok {
+			return id
+		}
+	}
+	if id, ok := r.hosts[strings.ToLower(stripPort(req.Host))]; ok {
+		return id
+	}
+	return Default
+}
+
+// BaseURL returns the base URL of the links of tenant id, def for the default tenant
+// and unknown ones.
+func (r *Registry) BaseURL(id, def string) string {
+	if r == nil {
+		return def
+	}
+	if u, ok := r.baseURLs[id]; ok {
+		return u
+	}
+	return def
+}
+
+// registry serves BaseURL to the code building links outside requests, e.g. the
+// scheduler's emails; see Use.
+var registry atomic.Pointer[Registry]
+
+// Use makes r the registry of BaseURL.
+func Use(r *Registry) {
+	registry.Store(r)
+}
+
+// BaseURL returns the base URL of the links of tenant id in the registry of Use, def
+// for the default tenant, unknown ones and before Use.
+func BaseURL(id, def string) string {
+	return registry.Load().BaseURL(id, def)
+}
+
+type ctxKey struct{}
+
+// WithID returns a copy of ctx scoped to tenant id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant ctx is scoped to; ok is false outside requests (the
+// scheduler, the command-line tools), which see every tenant.
+func FromContext(ctx context.Context) (id string, ok bool) {
+	id, ok = ctx.Value(ctxKey{}).(string)
+	return id, ok
+}
+
+// OrDefault returns the tenant ctx is scoped to, Default when it is not.
+func OrDefault(ctx context.Context) string {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return Default
+}
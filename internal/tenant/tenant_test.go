@@ -0,0 +1,76 @@
+package tenant
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry_Resolve(t *testing.T) {
+	r, err := Parse([]string{"acme=weather.acme.com", "globex=news.globex.io:8443"},
+		[]string{"globex=0123456789abcdef-globex"}, "https://api.example.com/weather-api")
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		host, key, want string
+	}{
+		{"weather.acme.com", "", "acme"},
+		{"Weather.Acme.com:443", "", "acme"},
+		{"news.globex.io", "", "globex"},
+		{"weather.acme.com", "0123456789abcdef-globex", "globex"}, // the API key wins
+		{"weather.acme.com", "unknown-key-0000000", "acme"},
+		{"api.example.com", "", Default},
+	} {
+		req := httptest.NewRequest("GET", "/api/weather", nil)
+		req.Host = tc.host
+		if tc.key != "" {
+			req.Header.Set(HeaderAPIKey, tc.key)
+		}
+		if got := r.Resolve(req); got != tc.want {
+			t.Errorf("Resolve(host %q, key %q) = %q, want %q", tc.host, tc.key, got, tc.want)
+		}
+	}
+
+	if got := r.BaseURL("acme", "https://api.example.com"); got != "https://weather.acme.com/weather-api" {
+		t.Errorf("BaseURL(acme) = %q", got)
+	}
+	if got := r.BaseURL(Default, "https://api.example.com"); got != "https://api.example.com" {
+		t.Errorf("BaseURL(default) = %q", got)
+	}
+
+	var none *Registry
+	if got := none.Resolve(httptest.NewRequest("GET", "/", nil)); got != Default {
+		t.Errorf("nil registry resolved %q", got)
+	}
+}
+
+func TestParse_Rejects(t *testing.T) {
+	for _, tc := range []struct {
+		tenants, keys []string
+	}{
+		{[]string{"acme"}, nil},
+		{[]string{"Acme=weather.acme.com"}, nil},
+		{[]string{"default=weather.acme.com"}, nil},
+		{[]string{"acme=weather.acme.com", "globex=weather.acme.com"}, nil},
+		{[]string{"acme=weather.acme.com"}, []string{"globex=0123456789abcdef"}},
+		{[]string{"acme=weather.acme.com"}, []string{"acme=short"}},
+	} {
+		if _, err := Parse(tc.tenants, tc.keys, "https://api.example.com"); err == nil {
+			t.Errorf("Parse(%q, %q) accepted an invalid entry", tc.tenants, tc.keys)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("a background context must not be scoped")
+	}
+	if got := OrDefault(context.Background()); got != Default {
+		t.Errorf("OrDefault() = %q", got)
+	}
+	if got, ok := FromContext(WithID(context.Background(), "acme")); !ok || got != "acme" {
+		t.Errorf("FromContext() = %q, %v", got, ok)
+	}
+}
@@ -0,0 +1,117 @@
+// Package tracing carries a request ID and a W3C trace ID through contexts and onto
+// outbound provider calls, so our logs can be matched against provider dashboards.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent"
+)
+
+// providerRequestIDHeaders are response headers providers (or their CDNs) use for
+// their own request identifiers, in order of preference.
+var providerRequestIDHeaders = []string{"X-Request-Id", "X-Amzn-Requestid", "X-Amz-Cf-Id", "Cf-Ray"}
+
+var (
+	requestIDPattern   = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// Trace identifies one unit of work: an API request or a scheduler run.
+type Trace struct {
+	RequestID string
+	TraceID   string // 32 hex chars, W3C trace-context
+}
+
+type ctxKey struct{}
+
+// New starts a fresh trace.
+func New() Trace {
+	return Trace{RequestID: uuid.NewString(), TraceID: randomHex(16)}
+}
+
+// FromHeaders continues a trace from incoming request headers, filling in whatever is
+// missing or malformed.
+func FromHeaders(h http.Header) Trace {
+	t := New()
+	if id := h.Get(HeaderRequestID); requestIDPattern.MatchString(id) {
+		t.RequestID = id
+	}
+	if m := traceparentPattern.FindStringSubmatch(strings.ToLower(h.Get(HeaderTraceparent))); m != nil &&
+		m[1] != strings.Repeat("0", 32) {
+		t.TraceID = m[1]
+	}
+	return t
+}
+
+// NewContext returns a copy of ctx carrying t.
+func NewContext(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromContext returns the trace carried by ctx, if any.
+func FromContext(ctx context.Context) (Trace, bool) {
+	t, ok := ctx.Value(ctxKey{}).(Trace)
+	return t, ok
+}
+
+// FromContextOrNew returns the trace carried by ctx, or a fresh one.
+func FromContextOrNew(ctx context.Context) Trace {
+	if t, ok := FromContext(ctx); ok {
+		return t
+	}
+	return New()
+}
+
+// RequestID returns the request ID carried by ctx, or "" when there is none.
+func RequestID(ctx context.Context) string {
+	t, _ := FromContext(ctx)
+	return t.RequestID
+}
+
+// Inject sets X-Request-ID and traceparent on an outbound request. Each call gets its
+// own span ID so the provider sees distinct calls within one trace.
+func (t Trace) Inject(h http.Header) {
+	h.Set(HeaderRequestID, t.RequestID)
+	h.Set(HeaderTraceparent, fmt.Sprintf("00-%s-%s-01", t.TraceID, randomHex(8)))
+}
+
+// Describe formats our IDs and the provider's request ID (when resp has one) for
+// error messages, e.g. "request_id=… trace_id=… provider_request_id=…".
+func (t Trace) Describe(resp *http.Response) string {
+	s := fmt.Sprintf("request_id=%s trace_id=%s", t.RequestID, t.TraceID)
+	if id := ProviderRequestID(resp); id != "" {
+		s += " provider_request_id=" + id
+	}
+	return s
+}
+
+// ProviderRequestID returns the provider's own request identifier from response headers.
+func ProviderRequestID(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	for _, h := range providerRequestIDHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFromHeaders(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	for _, tc := range []struct {
+		name                   string
+		requestID, traceparent string
+		keepRequestID          bool
+		keepTraceID            bool
+	}{
+		{"both well-formed", "req-42", "00-" + traceID + "-00f067aa0ba902b7-01", true, true},
+		{"upper-case traceparent", "req-42", strings.ToUpper("00-" + traceID + "-00f067aa0ba902b7-01"), true, true},
+		{"none", "", "", false, false},
+		{"request ID with a header injection", "req\r\nX-Admin: 1", "", false, false},
+		{"request ID too long", strings.Repeat("a", 129), "", false, false},
+		{"all-zero trace ID", "req-42", "00-" + strings.Repeat("0", 32) + "-00f067aa0ba902b7-01", true, false},
+		{"malformed traceparent", "req-42", "00-" + traceID, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			h.Set(HeaderRequestID, tc.requestID)
+			h.Set(HeaderTraceparent, tc.traceparent)
+			got := FromHeaders(h)
+			if (got.RequestID == tc.requestID) != tc.keepRequestID || got.RequestID == "" {
+				t.Errorf("RequestID = %q, want kept %t", got.RequestID, tc.keepRequestID)
+			}
+			if (got.TraceID == traceID) != tc.keepTraceID || len(got.TraceID) != 32 {
+				t.Errorf("TraceID = %q, want kept %t", got.TraceID, tc.keepTraceID)
+			}
+		})
+	}
+}
+
+func TestTrace_Inject(t *testing.T) {
+	trace := Trace{RequestID: "req-42", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"}
+	first, second := http.Header{}, http.Header{}
+	trace.Inject(first)
+	trace.Inject(second)
+
+	if first.Get(HeaderRequestID) != "req-42" {
+		t.Errorf("X-Request-ID = %q", first.Get(HeaderRequestID))
+	}
+	// the provider continues our trace, each call as a span of its own
+	if got := FromHeaders(first); got.TraceID != trace.TraceID {
+		t.Errorf("traceparent %q does not carry the trace ID", first.Get(HeaderTraceparent))
+	}
+	if first.Get(HeaderTraceparent) == second.Get(HeaderTraceparent) {
+		t.Error("two calls share a span ID")
+	}
+}
+
+func TestTrace_Describe(t *testing.T) {
+	trace := Trace{RequestID: "req-42", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"}
+	for _, tc := range []struct {
+		name string
+		resp *http.Response
+		want string
+	}{
+		{"no response", nil, "request_id=req-42 trace_id=4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"provider request ID", &http.Response{Header: http.Header{"Cf-Ray": {"8c1f"}, "X-Request-Id": {"p-7"}}},
+			"request_id=req-42 trace_id=4bf92f3577b34da6a3ce929d0e0e4736 provider_request_id=p-7"},
+		{"CDN ray only", &http.Response{Header: http.Header{"Cf-Ray": {"8c1f"}}},
+			"request_id=req-42 trace_id=4bf92f3577b34da6a3ce929d0e0e4736 provider_request_id=8c1f"},
+	} {
+		if got := trace.Describe(tc.resp); got != tc.want {
+			t.Errorf("%s: Describe() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFromContextOrNew(t *testing.T) {
+	if RequestID(context.Background()) != "" {
+		t.Error("RequestID() of a context without a trace is not empty")
+	}
+	fresh := FromContextOrNew(context.Background())
+	if fresh.RequestID == "" || len(fresh.TraceID) != 32 {
+		t.Errorf("FromContextOrNew() without a trace = %+v", fresh)
+	}
+	ctx := NewContext(context.Background(), fresh)
+	if got := FromContextOrNew(ctx); got != fresh || RequestID(ctx) != fresh.RequestID {
+		t.Errorf("FromContextOrNew() = %+v, want %+v", got, fresh)
+	}
+}
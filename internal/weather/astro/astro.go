@@ -0,0 +1,51 @@
+// Package astro computes sun times for providers that report a location but not its
+// sunrise.
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	j2000     = 2451545.0 // Julian day of 2000-01-01 12:00 UTC
+	unixEpoch = 2440587.5 // Julian day of 1970-01-01 00:00 UTC
+	rad       = math.Pi / 180
+)
+
+// Sunrise returns the sunrise at latitude lat and longitude lon (degrees, east
+// positive) on the local day containing t, accurate to a couple of minutes. ok is false
+// during polar day and polar night, when the sun does not rise.
+//
+// It uses the sunrise equation with the standard -0.833° altitude for refraction and
+// the solar disc.
+func Sunrise(lat, lon float64, t time.Time) (sunrise time.Time, ok bool) {
+	// days since J2000 of the local solar noon of the day, with the day taken by solar
+	// time so that cities far from Greenwich get their own date
+	local := t.UTC().Add(time.Duration(lon / 15 * float64(time.Hour)))
+	noon := time.Date(local.Year(), local.Month(), local.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(julianDay(noon) - j2000)
+
+	meanNoon := n - lon/360
+	m := math.Mod(357.5291+0.98560028*meanNoon, 360) * rad
+	center := 1.9148*math.Sin(m) + 0.02*math.Sin(2*m) + 0.0003*math.Sin(3*m)
+	lambda := math.Mod(m/rad+center+180+102.9372, 360) * rad
+	transit := j2000 + meanNoon + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*lambda)
+
+	sinDecl := math.Sin(lambda) * math.Sin(23.4397*rad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	cosHour := (math.Sin(-0.833*rad) - math.Sin(lat*rad)*sinDecl) / (math.Cos(lat*rad) * cosDecl)
+	if cosHour < -1 || cosHour > 1 {
+		return time.Time{}, false
+	}
+	rise := transit - math.Acos(cosHour)/rad/360
+	return fromJulianDay(rise), true
+}
+
+func julianDay(t time.Time) float64 {
+	return float64(t.Unix())/86400 + unixEpoch
+}
+
+func fromJulianDay(jd float64) time.Time {
+	return time.Unix(int64(math.Round((jd-unixEpoch)*86400)), 0).UTC()
+}
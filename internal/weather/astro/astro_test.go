@@ -0,0 +1,33 @@
+package astro
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunrise(t *testing.T) {
+	cases := []struct {
+		name     string
+		lat, lon float64
+		day      time.Time
+		want     time.Time // UTC, from published almanacs
+	}{
+		{"Kyiv midsummer", 50.45, 30.52, time.Date(2026, 6, 21, 9, 0, 0, 0, time.UTC), time.Date(2026, 6, 21, 1, 47, 0, 0, time.UTC)},
+		{"London equinox", 51.51, -0.13, time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 20, 6, 3, 0, 0, time.UTC)},
+		{"Sydney", -33.87, 151.21, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 12, 31, 18, 47, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		got, ok := Sunrise(c.lat, c.lon, c.day)
+		if !ok {
+			t.Errorf("%s: no sunrise", c.name)
+			continue
+		}
+		if d := got.Sub(c.want); d < -3*time.Minute || d > 3*time.Minute {
+			t.Errorf("%s: Sunrise() = %s, want %s", c.name, got.Format(time.RFC3339), c.want.Format(time.RFC3339))
+		}
+	}
+
+	if _, ok := Sunrise(69.65, 18.96, time.Date(2026, 6, 21, 12, 0, 0, 0, time.UTC)); ok {
+		t.Error("Tromsø has midnight sun in June, want no sunrise")
+	}
+}
@@ -3,23 +3,44 @@ package weather
 import (
 	"context"
 	"fmt"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tracing"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 type Fetcher interface {
 	FetchCurrent(ctx context.Context, city string) (types.Weather, error)
 }
 
+// ForecastFetcher fetches the forecast of a city for its next local day.
+type ForecastFetcher interface {
+	FetchTomorrow(ctx context.Context, city string) (types.Forecast, error)
+}
+
+// WarningsFetcher fetches the severe weather warnings in effect for a city.
+type WarningsFetcher interface {
+	FetchWarnings(ctx context.Context, city string) ([]types.Warning, error)
+}
+
+// TimezoneResolver finds the IANA timezone of a city.
+type TimezoneResolver interface {
+	ResolveTimezone(ctx context.Context, city string) (string, error)
+}
+
 // MainConcurrentFetcher will try all its Fetchers in parallel and return the first success.
+// Fetchers are ranked by preference, most preferred first; see RaceFetch.
 type MainConcurrentFetcher struct {
 	fetchers []Fetcher
+	grace    time.Duration
 	logger   *zap.Logger
 }
 
-// NewMainConcurrentFetcher constructs a MainConcurrentFetcher.
+// NewMainConcurrentFetcher constructs a MainConcurrentFetcher; fetchers are given in
+// order of preference.
 func NewMainConcurrentFetcher(logger *zap.Logger, fetchers ...Fetcher) *MainConcurrentFetcher {
 	return &MainConcurrentFetcher{
 		fetchers: fetchers,
@@ -27,60 +48,124 @@ func NewMainConcurrentFetcher(logger *zap.Logger, fetchers ...Fetcher) *MainConc
 	}
 }
 
+// WithPreferenceGrace makes a result of a less preferred provider wait up to grace for
+// the more preferred ones before it is used. Zero (the default) uses the first success.
+func (m *MainConcurrentFetcher) WithPreferenceGrace(grace time.Duration) *MainConcurrentFetcher {
+	m.grace = grace
+	return m
+}
+
 func (m *MainConcurrentFetcher) FetchCurrent(ctx context.Context, city string) (types.Weather, error) {
-	return RaceFetch(ctx, city, m.fetchers, m.logger)
+	return RaceFetch(ctx, city, m.fetchers, m.grace, m.logger)
 }
 
-// RaceFetch runs all fetchers in parallel and returns the first successful result.
-// It logs each fetcher’s error or success, and aggregates errors if all fail.
-func RaceFetch(ctx context.Context, city string, fetchers []Fetcher, logger *zap.Logger) (types.Weather, error) {
+// RaceFetch runs all fetchers in parallel and returns a successful result, preferring
+// fetchers earlier in the list. The most preferred success among the results in hand is
+// used as soon as every more preferred fetcher has failed; a less preferred one waits
+// up to grace for them, so when several providers answer within grace the choice does
+// not depend on which was fastest. Slower fetchers are cancelled and waited for before
+// returning. It logs each fetcher's error or success, and aggregates errors if all fail.
+func RaceFetch(ctx context.Context, city string, fetchers []Fetcher, grace time.Duration, logger *zap.Logger,
+) (types.Weather, error) {
 	if len(fetchers) == 0 {
 		err := fmt.Errorf("no weather providers configured")
 		logger.Error("no fetchers", zap.Error(err))
 		return types.Weather{}, err
 	}
 
+	// Share one trace between all providers so their calls can be correlated.
+	trace := tracing.FromContextOrNew(ctx)
+	ctx = tracing.NewContext(ctx, trace)
+	logger = logger.With(zap.String("request_id", trace.RequestID), zap.String("trace_id", trace.TraceID))
+
 	// Create a cancelable context to stop slow fetchers once we have a winner.
 	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	var g errgroup.Group
+	defer func() {
+		cancel()
+		_ = g.Wait() // no goroutine outlives the call
+	}()
 
 	type result struct {
-		w   types.Weather
-		err error
+		rank int
+		w    types.Weather
+		err  error
 	}
 	ch := make(chan result, len(fetchers))
 
-	// Fire off one goroutine per provider.
-	for _, f := range fetchers {
-		go func(f Fetcher) {
+	// Fire off one goroutine per provider. Provider errors are results, not group
+	// errors, so one failing provider never cancels the others.
+	for rank, f := range fetchers {
+		g.Go(func() error {
 			w, err := f.FetchCurrent(ctx, city)
 			if err != nil {
-				logger.Debug("weather fetcher failed or cancelled", zap.Error(err))
+				logger.Debug("weather fetcher failed or cancelled", zap.Int("rank", rank), zap.Error(err))
 			} else {
 				logger.Debug("weather fetcher succeeded",
+					zap.Int("rank", rank),
 					zap.Float64("temp", w.Temp),
 					zap.Int("humidity", w.Humidity),
 					zap.String("desc", w.Description),
 				)
 			}
-			ch <- result{w, err}
-		}(f)
+			ch <- result{rank, w, err}
+			return nil
+		})
+	}
+
+	use := func(r result) (types.Weather, error) {
+		cancel() // stop other fetchers
+		logger.Info("using weather result",
+			zap.Int("rank", r.rank),
+			zap.Float64("temp", r.w.Temp),
+			zap.Int("humidity", r.w.Humidity),
+			zap.String("desc", r.w.Description),
+		)
+		return r.w, nil
+	}
+
+	failed := make([]bool, len(fetchers))
+	// preferredFailed reports whether every fetcher ranked before rank has failed.
+	preferredFailed := func(rank int) bool {
+		for _, f := range failed[:rank] {
+			if !f {
+				return false
+			}
+		}
+		return true
 	}
 
-	var errs []string
-	// Collect the first nil-error result, or aggregate all errors.
-	for i := 0; i < len(fetchers); i++ {
-		r := <-ch
-		if r.err == nil {
-			cancel() // stop other fetchers
-			logger.Info("using weather result",
-				zap.Float64("temp", r.w.Temp),
-				zap.Int("humidity", r.w.Humidity),
-				zap.String("desc", r.w.Description),
-			)
-			return r.w, nil
+	var best *result
+	var graceC <-chan time.Time
+	errs := make([]string, 0, len(fetchers))
+	for received := 0; received < len(fetchers); {
+		select {
+		case r := <-ch:
+			received++
+			if r.err != nil {
+				failed[r.rank] = true
+				errs = append(errs, r.err.Error())
+			} else if best == nil || r.rank < best.rank {
+				best = &r
+			}
+			if best == nil {
+				continue
+			}
+			if grace <= 0 || preferredFailed(best.rank) {
+				return use(*best)
+			}
+			if graceC == nil {
+				timer := time.NewTimer(grace)
+				defer timer.Stop()
+				graceC = timer.C
+			}
+		case <-graceC:
+			logger.Debug("preferred weather providers missed the grace window", zap.Int("rank", best.rank))
+			return use(*best)
 		}
-		errs = append(errs, r.err.Error())
+	}
+	if best != nil {
+		return use(*best)
 	}
 
 	// All providers failed:
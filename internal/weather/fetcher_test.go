@@ -0,0 +1,76 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+type delayedFetcher struct {
+	delay time.Duration
+	desc  string
+	err   error
+}
+
+func (f delayedFetcher) FetchCurrent(ctx context.Context, _ string) (types.Weather, error) {
+	select {
+	case <-time.After(f.delay):
+		return types.Weather{Description: f.desc}, f.err
+	case <-ctx.Done():
+		return types.Weather{}, ctx.Err()
+	}
+}
+
+func TestRaceFetch_Preference(t *testing.T) {
+	preferred := delayedFetcher{delay: 20 * time.Millisecond, desc: "preferred"}
+	fallback := delayedFetcher{delay: 0, desc: "fallback"}
+	broken := delayedFetcher{delay: 10 * time.Millisecond, err: errors.New("down")}
+
+	tests := []struct {
+		name     string
+		fetchers []Fetcher
+		grace    time.Duration
+		want     string
+	}{
+		{"no grace: first success wins", []Fetcher{preferred, fallback}, 0, "fallback"},
+		{"grace waits for the preferred provider", []Fetcher{preferred, fallback}, time.Second, "preferred"},
+		{"grace too short", []Fetcher{preferred, fallback}, time.Millisecond, "fallback"},
+		{"preferred provider failed", []Fetcher{broken, fallback}, time.Second, "fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			w, err := RaceFetch(context.Background(), "Kyiv", tt.fetchers, tt.grace, zap.NewNop())
+			if err != nil {
+				t.Fatalf("RaceFetch() error: %v", err)
+			}
+			if w.Description != tt.want {
+				t.Errorf("RaceFetch() = %q, want %q", w.Description, tt.want)
+			}
+			if time.Since(start) > 500*time.Millisecond {
+				t.Errorf("RaceFetch() took %s, should not wait out the grace window", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestCacheNamespace(t *testing.T) {
+	ns := CacheNamespace(&config.Config{CacheVersion: 3})
+	if want := fmt.Sprintf("weather:v%d.3:", types.SchemaVersion); ns != want {
+		t.Errorf("CacheNamespace() = %q, want %q", ns, want)
+	}
+	// the namespace must not cover the keys that have to survive a deploy
+	for _, key := range []string{usageKey(time.Now()), rawKey("Kyiv")} {
+		if strings.HasPrefix(key, ns) {
+			t.Errorf("key %q is inside the weather cache namespace", key)
+		}
+	}
+}
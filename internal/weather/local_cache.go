@@ -0,0 +1,62 @@
+package weather
+
+import (
+	"sync"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+// localCacheSize bounds the cities kept by a localCache.
+const localCacheSize = 1000
+
+// localCache keeps weather in process memory while Redis has no room for it.
+type localCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]localEntry
+}
+
+type localEntry struct {
+	w       types.Weather
+	expires time.Time
+}
+
+func newLocalCache(ttl time.Duration) *localCache {
+	return &localCache{ttl: ttl, entries: map[string]localEntry{}}
+}
+
+func (c *localCache) get(city string) (types.Weather, bool) {
+	if c == nil {
+		return types.Weather{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[city]
+	if !ok || time.Now().After(e.expires) {
+		return types.Weather{}, false
+	}
+	return e.w, true
+}
+
+// set stores w, making room by dropping expired entries, then arbitrary ones.
+func (c *localCache) set(city string, w types.Weather) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= localCacheSize {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < localCacheSize {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[city] = localEntry{w: w, expires: now.Add(c.ttl)}
+}
@@ -5,12 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tracing"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+	"io"
 	"net/http"
+	"time"
 )
 
+// ProviderName identifies OpenWeatherMap in logs and usage reports.
+const ProviderName = "openweathermap.org"
+
+// maxResponseSize caps how much of a response body is read.
+const maxResponseSize = 1 << 20
+
 type Client struct {
 	apiKey string
+	raw    types.RawRecorder
 }
 
 func NewClient(cfg *config.Config) (*Client, error) {
@@ -21,6 +31,12 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	return &Client{apiKey: key}, nil
 }
 
+// WithRawRecorder makes the client hand every response body to r before parsing it.
+func (c *Client) WithRawRecorder(r types.RawRecorder) *Client {
+	c.raw = r
+	return c
+}
+
 func (c *Client) FetchCurrent(ctx context.Context, city string) (types.Weather, error) {
 	url := fmt.Sprintf(
 		"https://api.openweathermap.org/data/2.5/weather?q=%s&appid=%s&units=metric",
@@ -31,17 +47,27 @@ func (c *Client) FetchCurrent(ctx context.Context, city string) (types.Weather,
 	if err != nil {
 		return types.Weather{}, fmt.Errorf("openweathermap: failed to build request: %w", err)
 	}
+	trace := tracing.FromContextOrNew(ctx)
+	trace.Inject(req.Header)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return types.Weather{}, fmt.Errorf("openweathermap: HTTP request failed: %w", err)
+		return types.Weather{}, fmt.Errorf("openweathermap: HTTP request failed (%s): %w", trace.Describe(nil), err)
 	}
 	defer resp.Body.Close()
 
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return types.Weather{}, fmt.Errorf("openweathermap: failed to read response (%s): %w", trace.Describe(resp), err)
+	}
+	if c.raw != nil {
+		c.raw.RecordRaw(ctx, ProviderName, city, resp.StatusCode, raw)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return types.Weather{}, fmt.Errorf(
-			"openweathermap: unexpected status %d %s",
-			resp.StatusCode, http.StatusText(resp.StatusCode),
+			"openweathermap: unexpected status %d %s (%s)",
+			resp.StatusCode, http.StatusText(resp.StatusCode), trace.Describe(resp),
 		)
 	}
 
@@ -53,17 +79,29 @@ func (c *Client) FetchCurrent(ctx context.Context, city string) (types.Weather,
 		Weather []struct {
 			Description string `json:"description"`
 		} `json:"weather"`
+		Wind struct {
+			Speed float64 `json:"speed"` // m/s with units=metric
+		} `json:"wind"`
+		Sys struct {
+			Sunrise int64 `json:"sunrise"` // unix time; absent during polar day and night
+		} `json:"sys"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-		return types.Weather{}, fmt.Errorf("openweathermap: JSON decode error: %w", err)
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return types.Weather{}, fmt.Errorf("openweathermap: JSON decode error (%s): %w", trace.Describe(resp), err)
 	}
 	if len(body.Weather) == 0 {
 		return types.Weather{}, fmt.Errorf("openweathermap: no weather data in response")
 	}
 
-	return types.Weather{
+	w := types.Weather{
 		Temp:        body.Main.Temp,
 		Humidity:    body.Main.Humidity,
 		Description: body.Weather[0].Description,
-	}, nil
+		WindKph:     body.Wind.Speed * 3.6,
+		Provider:    ProviderName,
+	}
+	if body.Sys.Sunrise > 0 {
+		w.Sunrise = time.Unix(body.Sys.Sunrise, 0).UTC()
+	}
+	return w, nil
 }
@@ -0,0 +1,75 @@
+package weather
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+// DefaultPrefetchConcurrency is the in-flight cap of a PrefetchLimiter when none is
+// configured.
+const DefaultPrefetchConcurrency = 8
+
+// PrefetchLimiter caps the cities fetched at once by the PrefetchCities calls sharing
+// it, so that concurrent batches together stay within the cap, and so within provider
+// quotas.
+type PrefetchLimiter struct {
+	slots chan struct{}
+}
+
+// NewPrefetchLimiter returns a limiter of n fetches in flight, at least one.
+func NewPrefetchLimiter(n int) *PrefetchLimiter {
+	return &PrefetchLimiter{slots: make(chan struct{}, max(n, 1))}
+}
+
+// Prefetched is the current weather of a city fetched by PrefetchCities, or why it
+// could not be fetched.
+type Prefetched struct {
+	Weather types.Weather
+	Err     error
+}
+
+// PrefetchCities fetches the current weather of cities with fetcher concurrently, each
+// distinct city (case aside) once, within the cap of limiter (nil fetches them all at
+// once). The results are keyed by lower-cased city; cities not fetched when ctx ends
+// have its error.
+func PrefetchCities(ctx context.Context, fetcher Fetcher, limiter *PrefetchLimiter, cities []string,
+) map[string]Prefetched {
+	distinct := make(map[string]string)
+	for _, city := range cities {
+		key := strings.ToLower(city)
+		if _, ok := distinct[key]; !ok {
+			distinct[key] = city
+		}
+	}
+
+	out := make(map[string]Prefetched, len(distinct))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for key, city := range distinct {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var p Prefetched
+			switch {
+			case limiter == nil:
+				p.Weather, p.Err = fetcher.FetchCurrent(ctx, city)
+			default:
+				select {
+				case limiter.slots <- struct{}{}:
+					p.Weather, p.Err = fetcher.FetchCurrent(ctx, city)
+					<-limiter.slots
+				case <-ctx.Done():
+					p.Err = ctx.Err()
+				}
+			}
+			mu.Lock()
+			out[key] = p
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,46 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+)
+
+// inFlightFetcher records the most fetches it had in flight at once and how many it
+// made; it fails for "Nowhere".
+type inFlightFetcher struct {
+	now, peak, calls atomic.Int32
+}
+
+func (f *inFlightFetcher) FetchCurrent(_ context.Context, city string) (types.Weather, error) {
+	f.calls.Add(1)
+	n := f.now.Add(1)
+	defer f.now.Add(-1)
+	for p := f.peak.Load(); n > p && !f.peak.CompareAndSwap(p, n); p = f.peak.Load() {
+	}
+	time.Sleep(10 * time.Millisecond)
+	if city == "Nowhere" {
+		return types.Weather{}, errors.New("unknown city")
+	}
+	return types.Weather{Description: city}, nil
+}
+
+func TestPrefetchCities(t *testing.T) {
+	f := &inFlightFetcher{}
+	got := PrefetchCities(context.Background(), f, NewPrefetchLimiter(2),
+		[]string{"Kyiv", "kyiv", "Lviv", "Odesa", "Dnipro", "Nowhere"})
+
+	if f.calls.Load() != 5 || len(got) != 5 {
+		t.Errorf("fetched %d cities, %d results; want 5 distinct", f.calls.Load(), len(got))
+	}
+	if f.peak.Load() > 2 {
+		t.Errorf("%d fetches in flight at once, want at most 2", f.peak.Load())
+	}
+	if got["kyiv"].Weather.Description != "Kyiv" || got["nowhere"].Err == nil {
+		t.Errorf("results = %+v, want Kyiv fetched and Nowhere failed", got)
+	}
+}
@@ -0,0 +1,113 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
+)
+
+// RawResponse is the last response body a provider returned for a city.
+type RawResponse struct {
+	Status    int             `json:"status"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Truncated bool            `json:"truncated"`
+	Body      json.RawMessage `json:"body,omitempty"` // provider JSON as received
+	Text      string          `json:"text,omitempty"` // body that is not valid JSON (or was truncated)
+}
+
+// RawStore keeps the last raw response of each provider per city in Redis, so operators
+// can compare what a provider returned with what we parsed. Entries expire after ttl
+// and bodies are capped at maxBytes.
+type RawStore struct {
+	redis    *redis.Client
+	ttl      time.Duration
+	maxBytes int
+	memory   *redisclient.MemoryMonitor // optional, see WithMemoryMonitor
+	logger   *zap.Logger
+}
+
+func NewRawStore(rdb *redis.Client, ttl time.Duration, maxBytes int, logger *zap.Logger) *RawStore {
+	return &RawStore{redis: rdb, ttl: ttl, maxBytes: maxBytes, logger: logger}
+}
+
+// WithMemoryMonitor makes raw responses the first data given up under Redis memory
+// pressure: none are recorded while it lasts, and the stored ones are deleted when it begins.
+func (s *RawStore) WithMemoryMonitor(m *redisclient.MemoryMonitor) *RawStore {
+	s.memory = m
+	m.OnPressure(s.dropAll)
+	return s
+}
+
+// dropAll deletes every stored raw response.
+func (s *RawStore) dropAll(ctx context.Context) {
+	var dropped int
+	iter := s.redis.Scan(ctx, 0, rawKey("")+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.redis.Unlink(ctx, iter.Val()).Err(); err != nil {
+			s.logger.Warn("failed to drop raw provider response", zap.Error(err))
+			return
+		}
+		dropped++
+	}
+	if err := iter.Err(); err != nil {
+		s.logger.Warn("failed to list raw provider responses", zap.Error(err))
+	}
+	s.logger.Info("dropped raw provider responses to free redis memory", zap.Int("keys", dropped))
+}
+
+func rawKey(city string) string {
+	return "weather:raw:" + strings.ToLower(strings.TrimSpace(city))
+}
+
+// RecordRaw implements types.RawRecorder. Failures are logged and never affect the fetch.
+func (s *RawStore) RecordRaw(ctx context.Context, provider, city string, status int, body []byte) {
+	if s.memory.UnderPressure() {
+		return
+	}
+	entry := RawResponse{Status: status, FetchedAt: time.Now().UTC()}
+	if len(body) > s.maxBytes {
+		body, entry.Truncated = body[:s.maxBytes], true
+	}
+	if !entry.Truncated && json.Valid(body) {
+		entry.Body = body
+	} else {
+		entry.Text = string(body)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		s.logger.Warn("raw response marshal failed", zap.Error(err))
+		return
+	}
+	key := rawKey(city)
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, key, provider, data)
+	pipe.Expire(ctx, key, s.ttl)
+	if _, err := pipe.Exec(ctx); err != nil && !redisclient.IsOOM(err) {
+		s.logger.Warn("failed to store raw provider response", zap.String("provider", provider), zap.Error(err))
+	}
+}
+
+// Get returns the stored raw responses for city, keyed by provider.
+func (s *RawStore) Get(ctx context.Context, city string) (map[string]RawResponse, error) {
+	fields, err := s.redis.HGetAll(ctx, rawKey(city)).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]RawResponse, len(fields))
+	for provider, data := range fields {
+		var entry RawResponse
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			s.logger.Warn("cannot decode stored raw response", zap.String("provider", provider), zap.Error(err))
+			continue
+		}
+		out[provider] = entry
+	}
+	return out, nil
+}
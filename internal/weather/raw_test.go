@@ -0,0 +1,116 @@
+package weather
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// rawHook answers HSET, EXPIRE and HGETALL, piped or not, from maps in place of Redis.
+type rawHook struct {
+	hashes map[string]map[string]string
+	ttls   map[string]time.Duration
+}
+
+func (h *rawHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *rawHook) ProcessPipelineHook(redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(_ context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.process(cmd)
+		}
+		return nil
+	}
+}
+
+func (h *rawHook) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		h.process(cmd)
+		return nil
+	}
+}
+
+func (h *rawHook) process(cmd redis.Cmder) {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return // MULTI and EXEC
+	}
+	key, _ := args[1].(string)
+	switch cmd.Name() {
+	case "hset":
+		if h.hashes[key] == nil {
+			h.hashes[key] = map[string]string{}
+		}
+		field, _ := args[2].(string)
+		h.hashes[key][field] = string(args[3].([]byte))
+	case "expire":
+		h.ttls[key] = time.Duration(args[2].(int64)) * time.Second
+	case "hgetall":
+		cmd.(*redis.MapStringStringCmd).SetVal(h.hashes[key])
+	}
+}
+
+func TestRawStore_RecordRaw(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		body          string
+		wantBody      string
+		wantText      string
+		wantTruncated bool
+	}{
+		{"JSON", `{"current":{"temp_c":12.5}}`, `{"current":{"temp_c":12.5}}`, "", false},
+		{"not JSON", `<html>Bad Gateway</html>`, "", `<html>Bad Gateway</html>`, false},
+		{"too long", `{"current":{"temp_c":12.5,"condition":"Partly cloudy"}}`, "", `{"current":{"temp_c":12.5,"condi`, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hook := &rawHook{hashes: map[string]map[string]string{}, ttls: map[string]time.Duration{}}
+			rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+			rdb.AddHook(hook)
+			defer rdb.Close()
+			store := NewRawStore(rdb, time.Hour, 32, zap.NewNop())
+
+			store.RecordRaw(context.Background(), "weatherapi.com", " Kyiv ", 200, []byte(tc.body))
+			if hook.ttls["weather:raw:kyiv"] != time.Hour {
+				t.Errorf("raw responses of Kyiv expire in %s, want 1h", hook.ttls["weather:raw:kyiv"])
+			}
+			got, err := store.Get(context.Background(), "KYIV")
+			if err != nil {
+				t.Fatalf("Get() error: %v", err)
+			}
+			raw, ok := got["weatherapi.com"]
+			if !ok || len(got) != 1 {
+				t.Fatalf("Get() = %+v, want the response of weatherapi.com", got)
+			}
+			if string(raw.Body) != tc.wantBody || raw.Text != tc.wantText || raw.Truncated != tc.wantTruncated ||
+				raw.Status != 200 {
+				t.Errorf("Get() = %+v, want body %s, text %q, truncated %t", raw, tc.wantBody, tc.wantText, tc.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestRawStore_KeepsTheLastResponseOfEachProvider(t *testing.T) {
+	hook := &rawHook{hashes: map[string]map[string]string{}, ttls: map[string]time.Duration{}}
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	rdb.AddHook(hook)
+	defer rdb.Close()
+	store := NewRawStore(rdb, time.Hour, 1024, zap.NewNop())
+
+	ctx := context.Background()
+	store.RecordRaw(ctx, "weatherapi.com", "Kyiv", 200, []byte(`{"temp_c":12}`))
+	store.RecordRaw(ctx, "openweathermap.org", "Kyiv", 401, []byte(`{"cod":401}`))
+	store.RecordRaw(ctx, "weatherapi.com", "Kyiv", 200, []byte(`{"temp_c":13}`))
+	hook.hashes["weather:raw:kyiv"]["broken.example"] = "{"
+
+	got, err := store.Get(ctx, "Kyiv")
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Get() = %+v, %v; want 2 providers, the undecodable entry left out", got, err)
+	}
+	if !strings.Contains(string(got["weatherapi.com"].Body), "13") || got["openweathermap.org"].Status != 401 {
+		t.Errorf("Get() = %+v, want the last response of each provider", got)
+	}
+}
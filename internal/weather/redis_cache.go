@@ -4,28 +4,69 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/metrics"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
 	redis "github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"time"
 )
 
+// ErrNotCached is returned by a CachingFetcher on a cache miss of a cache-only fetch.
+var ErrNotCached = errors.New("weather not cached, try again later")
+
+var (
+	weatherQueries = metrics.NewCounter("weather_queries_total",
+		"Weather lookups, from the API and the scheduler's emails.")
+	weatherCacheMisses = metrics.NewCounter("weather_cache_misses_total",
+		"Weather lookups not answered from the cache.")
+)
+
+type cacheOnlyKey struct{}
+
+// WithCacheOnly makes CachingFetcher answer fetches under ctx from the cache alone,
+// returning ErrNotCached instead of calling the providers on a miss. It is used to shed
+// provider calls while the API is overloaded.
+func WithCacheOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheOnlyKey{}, true)
+}
+
+func cacheOnly(ctx context.Context) bool {
+	only, _ := ctx.Value(cacheOnlyKey{}).(bool)
+	return only
+}
+
 // CachingFetcher decorates another Fetcher with a Redis cache.
 type CachingFetcher struct {
-	inner  Fetcher
-	redis  *redis.Client
-	ttl    time.Duration
-	logger *zap.Logger
+	inner     Fetcher
+	redis     *redis.Client
+	ttl       time.Duration
+	namespace string                     // key prefix, see CacheNamespace
+	memory    *redisclient.MemoryMonitor // optional, see WithMemoryFallback
+	local     *localCache                // entries Redis had no room for
+	logger    *zap.Logger
 }
 
-// NewCachingFetcher returns a Fetcher that first looks in Redis,
+// NewCachingFetcher returns a Fetcher that first looks in Redis under namespace,
 // falling back to inner (e.g. a MainConcurrentFetcher) on cache-miss.
-func NewCachingFetcher(inner Fetcher, rdb *redis.Client, ttl time.Duration, logger *zap.Logger) *CachingFetcher {
-	return &CachingFetcher{inner: inner, redis: rdb, ttl: ttl, logger: logger}
+func NewCachingFetcher(inner Fetcher, rdb *redis.Client, ttl time.Duration, namespace string, logger *zap.Logger,
+) *CachingFetcher {
+	return &CachingFetcher{inner: inner, redis: rdb, ttl: ttl, namespace: namespace, logger: logger}
+}
+
+// WithMemoryFallback keeps entries in process memory instead of Redis while m reports
+// memory pressure, or when Redis refuses them for lack of memory.
+func (c *CachingFetcher) WithMemoryFallback(m *redisclient.MemoryMonitor) *CachingFetcher {
+	c.memory = m
+	c.local = newLocalCache(c.ttl)
+	return c
 }
 
 func (c *CachingFetcher) FetchCurrent(ctx context.Context, city string) (types.Weather, error) {
-	key := "weather:" + city
+	key := c.namespace + city
+	weatherQueries.Inc()
 
 	// 1) Try cache
 	raw, err := c.redis.Get(ctx, key).Result()
@@ -40,20 +81,67 @@ func (c *CachingFetcher) FetchCurrent(ctx context.Context, city string) (types.W
 	} else if !errors.Is(err, redis.Nil) {
 		c.logger.Warn("redis GET failed", zap.Error(err))
 	}
+	if w, ok := c.local.get(key); ok {
+		c.logger.Debug("local cache hit", zap.String("city", city))
+		return w, nil
+	}
 
-	// 2) Cache-miss -> delegate to inner
+	// 2) Cache-miss -> delegate to inner, unless providers are being shed
+	weatherCacheMisses.Inc()
+	if cacheOnly(ctx) {
+		return types.Weather{}, ErrNotCached
+	}
 	w, err := c.inner.FetchCurrent(ctx, city)
 	if err != nil {
 		return w, err
 	}
 
-	// 3) Store in cache
+	// 3) Store in cache; in memory while Redis is short of it
+	if c.local != nil && c.memory.UnderPressure() {
+		c.local.set(key, w)
+		return w, nil
+	}
 	blob, merr := json.Marshal(w)
 	if merr != nil {
 		c.logger.Warn("json marshal failed", zap.Error(merr))
 	} else if serr := c.redis.Set(ctx, key, blob, c.ttl).Err(); serr != nil {
-		c.logger.Warn("redis SET failed", zap.Error(serr))
+		if c.local != nil && redisclient.IsOOM(serr) {
+			c.local.set(key, w) // counted and logged once by the MemoryMonitor
+		} else {
+			c.logger.Warn("redis SET failed", zap.Error(serr))
+		}
 	}
 
 	return w, nil
 }
+
+// CacheNamespace is the prefix of the cached weather keys: "weather:v<schema>.<version>:",
+// from types.SchemaVersion and CACHE_VERSION. Bumping either makes every process of the
+// new deploy start with an empty cache, without flushing Redis; the entries of the old
+// namespace expire with their TTL. Usage counters, raw responses and rate-limiter state
+// live outside it.
+func CacheNamespace(cfg *config.Config) string {
+	return fmt.Sprintf("weather:v%d.%d:", types.SchemaVersion, cfg.CacheVersion)
+}
+
+// CityCache drops cached data of a city spelling merged into another one, so nothing
+// keeps being served under the old spelling; the canonical spelling is cached again on
+// its next fetch.
+type CityCache struct {
+	redis     *redis.Client
+	namespace string
+}
+
+// NewCityCache manages the cached weather under namespace, see CacheNamespace.
+func NewCityCache(rdb *redis.Client, namespace string) *CityCache {
+	return &CityCache{redis: rdb, namespace: namespace}
+}
+
+// MoveCity forgets the cached weather and raw responses of from.
+func (c *CityCache) MoveCity(ctx context.Context, from, to string) error {
+	keys := []string{c.namespace + from}
+	if rawKey(from) != rawKey(to) { // raw responses are keyed case-insensitively
+		keys = append(keys, rawKey(from))
+	}
+	return c.redis.Del(ctx, keys...).Err()
+}
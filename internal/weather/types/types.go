@@ -1,7 +1,55 @@
 package types
 
+import (
+	"context"
+	"time"
+)
+
+// SchemaVersion is the version of the Weather encoding in the cache. Bump it with a
+// change that makes cached values of an older release wrong, such as a renamed or
+// retyped field: the next deploy then reads and writes a fresh cache namespace. An
+// added optional field does not need it.
+const SchemaVersion = 1
+
 type Weather struct {
 	Temp        float64 `json:"temp"`
 	Humidity    int     `json:"humidity"`
 	Description string  `json:"description"`
+	// WindKph is the wind speed in km/h; zero for cached values that predate it.
+	WindKph float64 `json:"wind_kph,omitempty"`
+	// Sunrise is today's sunrise at the location; zero when the provider does not say
+	// or the sun does not rise (polar day and night).
+	Sunrise time.Time `json:"sunrise,omitzero"`
+	// Provider names the provider that supplied the observation, for its attribution.
+	Provider string `json:"provider,omitempty"`
+}
+
+// Forecast is the expected weather of a city for one local day.
+type Forecast struct {
+	Date         string  `json:"date"` // local date of the city, e.g. "2026-10-17"
+	MinTemp      float64 `json:"min_temp"`
+	MaxTemp      float64 `json:"max_temp"`
+	ChanceOfRain int     `json:"chance_of_rain"` // %, of any precipitation
+	MaxWindKph   float64 `json:"max_wind_kph,omitempty"`
+	Description  string  `json:"description"`
+	Provider     string  `json:"provider,omitempty"`
+}
+
+// Warning is a severe weather warning issued by a government agency for an area.
+type Warning struct {
+	ID          string    `json:"id"` // stable across fetches, derived from the warning itself
+	Event       string    `json:"event"`
+	Headline    string    `json:"headline"`
+	Severity    string    `json:"severity,omitempty"` // e.g. "Moderate", "Severe", "Extreme"
+	Areas       string    `json:"areas,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Instruction string    `json:"instruction,omitempty"`
+	Effective   time.Time `json:"effective,omitzero"`
+	Expires     time.Time `json:"expires,omitzero"` // zero when open-ended
+	Provider    string    `json:"provider,omitempty"`
+}
+
+// RawRecorder receives raw provider response bodies for debugging.
+type RawRecorder interface {
+	RecordRaw(ctx context.Context, provider, city string, status int, body []byte)
 }
@@ -0,0 +1,174 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// usageMonthLayout is the key suffix and the report month format (e.g. "2025-06").
+const usageMonthLayout = "2006-01"
+
+// UsageTracker counts provider calls per calendar month in Redis,
+// so that the API and the scheduler contribute to the same totals.
+type UsageTracker struct {
+	redis  *redis.Client
+	logger *zap.Logger
+}
+
+// NewUsageTracker returns a UsageTracker storing counters in rdb.
+func NewUsageTracker(rdb *redis.Client, logger *zap.Logger) *UsageTracker {
+	return &UsageTracker{redis: rdb, logger: logger}
+}
+
+func usageKey(month time.Time) string {
+	return "weather:usage:" + month.UTC().Format(usageMonthLayout)
+}
+
+// Record increments the call counter of provider for the month of t.
+// Failures are logged only: usage accounting must never break a weather lookup.
+func (u *UsageTracker) Record(ctx context.Context, provider string, t time.Time) {
+	// Use a detached context: a cancelled race loser still made a billable call.
+	ctx = context.WithoutCancel(ctx)
+	if err := u.redis.HIncrBy(ctx, usageKey(t), provider, 1).Err(); err != nil && !redisclient.IsOOM(err) {
+		u.logger.Warn("failed to record provider usage", zap.String("provider", provider), zap.Error(err))
+	}
+}
+
+// Counts returns the number of calls per provider recorded for the month of t.
+func (u *UsageTracker) Counts(ctx context.Context, month time.Time) (map[string]int64, error) {
+	raw, err := u.redis.HGetAll(ctx, usageKey(month)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis HGETALL usage: %w", err)
+	}
+	counts := make(map[string]int64, len(raw))
+	for provider, v := range raw {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid usage counter for %s: %w", provider, err)
+		}
+		counts[provider] = n
+	}
+	return counts, nil
+}
+
+// CountingFetcher decorates a single provider Fetcher, recording every call it makes.
+type CountingFetcher struct {
+	provider string
+	inner    Fetcher
+	usage    *UsageTracker
+}
+
+// NewCountingFetcher wraps inner, attributing its calls to provider.
+func NewCountingFetcher(provider string, inner Fetcher, usage *UsageTracker) *CountingFetcher {
+	return &CountingFetcher{provider: provider, inner: inner, usage: usage}
+}
+
+// Provider returns the name of the wrapped provider.
+func (c *CountingFetcher) Provider() string {
+	return c.provider
+}
+
+func (c *CountingFetcher) FetchCurrent(ctx context.Context, city string) (types.Weather, error) {
+	c.usage.Record(ctx, c.provider, time.Now())
+	return c.inner.FetchCurrent(ctx, city)
+}
+
+// countingForecastFetcher is the CountingFetcher of a ForecastFetcher.
+type countingForecastFetcher struct {
+	provider string
+	inner    ForecastFetcher
+	usage    *UsageTracker
+}
+
+func (c *countingForecastFetcher) FetchTomorrow(ctx context.Context, city string) (types.Forecast, error) {
+	c.usage.Record(ctx, c.provider, time.Now())
+	return c.inner.FetchTomorrow(ctx, city)
+}
+
+// countingWarningsFetcher is the CountingFetcher of a WarningsFetcher.
+type countingWarningsFetcher struct {
+	provider string
+	inner    WarningsFetcher
+	usage    *UsageTracker
+}
+
+func (c *countingWarningsFetcher) FetchWarnings(ctx context.Context, city string) ([]types.Warning, error) {
+	c.usage.Record(ctx, c.provider, time.Now())
+	return c.inner.FetchWarnings(ctx, city)
+}
+
+// countingTimezoneResolver is the CountingFetcher of a TimezoneResolver.
+type countingTimezoneResolver struct {
+	provider string
+	inner    TimezoneResolver
+	usage    *UsageTracker
+}
+
+func (c *countingTimezoneResolver) ResolveTimezone(ctx context.Context, city string) (string, error) {
+	c.usage.Record(ctx, c.provider, time.Now())
+	return c.inner.ResolveTimezone(ctx, city)
+}
+
+// ProviderCost is one line of a CostReport.
+type ProviderCost struct {
+	Provider     string  `json:"provider"`
+	Calls        int64   `json:"calls"`
+	PricePerCall float64 `json:"price_per_call"`
+	Cost         float64 `json:"cost"`
+}
+
+// CostReport is the estimated provider spend for one calendar month.
+type CostReport struct {
+	Month     string         `json:"month"`
+	Providers []ProviderCost `json:"providers"`
+	TotalCost float64        `json:"total_cost"`
+}
+
+// BuildCostReport applies per-call prices to the recorded counts for month.
+// Every priced provider is listed, even with zero calls, so the mix is visible.
+func BuildCostReport(ctx context.Context, usage *UsageTracker, prices map[string]float64, month time.Time) (CostReport, error) {
+	counts, err := usage.Counts(ctx, month)
+	if err != nil {
+		return CostReport{}, err
+	}
+
+	names := make(map[string]struct{}, len(prices)+len(counts))
+	for name := range prices {
+		names[name] = struct{}{}
+	}
+	for name := range counts {
+		names[name] = struct{}{}
+	}
+
+	report := CostReport{Month: month.UTC().Format(usageMonthLayout)}
+	for name := range names {
+		line := ProviderCost{
+			Provider:     name,
+			Calls:        counts[name],
+			PricePerCall: prices[name],
+		}
+		line.Cost = float64(line.Calls) * line.PricePerCall
+		report.TotalCost += line.Cost
+		report.Providers = append(report.Providers, line)
+	}
+	sort.Slice(report.Providers, func(i, j int) bool {
+		return report.Providers[i].Provider < report.Providers[j].Provider
+	})
+	return report, nil
+}
+
+// ParseReportMonth parses a "YYYY-MM" month; an empty string means the current month.
+func ParseReportMonth(s string) (time.Time, error) {
+	if s == "" {
+		return time.Now().UTC(), nil
+	}
+	return time.Parse(usageMonthLayout, s)
+}
@@ -0,0 +1,151 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// hashHook answers HINCRBY and HGETALL from maps in place of Redis.
+type hashHook struct {
+	hashes map[string]map[string]int64
+}
+
+func (h *hashHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *hashHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (h *hashHook) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		args := cmd.Args()
+		key, _ := args[1].(string)
+		switch cmd.Name() {
+		case "hincrby":
+			field, _ := args[2].(string)
+			if h.hashes[key] == nil {
+				h.hashes[key] = map[string]int64{}
+			}
+			h.hashes[key][field] += args[3].(int64)
+			cmd.(*redis.IntCmd).SetVal(h.hashes[key][field])
+		case "hgetall":
+			out := map[string]string{}
+			for field, n := range h.hashes[key] {
+				out[field] = strconv.FormatInt(n, 10)
+			}
+			cmd.(*redis.MapStringStringCmd).SetVal(out)
+		}
+		return nil
+	}
+}
+
+// newUsageTracker returns a UsageTracker over an in-memory hashHook.
+func newUsageTracker(t *testing.T) *UsageTracker {
+	t.Helper()
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	rdb.AddHook(&hashHook{hashes: map[string]map[string]int64{}})
+	t.Cleanup(func() { rdb.Close() })
+	return NewUsageTracker(rdb, zap.NewNop())
+}
+
+// fixedTimezone resolves every city to Europe/Kyiv.
+type fixedTimezone struct{}
+
+func (fixedTimezone) ResolveTimezone(context.Context, string) (string, error) {
+	return "Europe/Kyiv", nil
+}
+
+func TestCountingTimezoneResolver(t *testing.T) {
+	usage := newUsageTracker(t)
+	r := &countingTimezoneResolver{provider: "weatherapi.com", inner: fixedTimezone{}, usage: usage}
+	for range 2 {
+		if tz, err := r.ResolveTimezone(context.Background(), "Kyiv"); err != nil || tz != "Europe/Kyiv" {
+			t.Fatalf("ResolveTimezone() = %q, %v", tz, err)
+		}
+	}
+	counts, err := usage.Counts(context.Background(), time.Now())
+	if err != nil || counts["weatherapi.com"] != 2 {
+		t.Errorf("Counts() = %v, %v; want 2 weatherapi.com calls", counts, err)
+	}
+}
+
+func TestCountingFetcher(t *testing.T) {
+	usage := newUsageTracker(t)
+	ok := NewCountingFetcher("weatherapi.com", delayedFetcher{desc: "Sunny"}, usage)
+	failing := NewCountingFetcher("openweathermap.org", delayedFetcher{err: errors.New("401 Unauthorized")}, usage)
+
+	if w, err := ok.FetchCurrent(context.Background(), "Kyiv"); err != nil || w.Description != "Sunny" {
+		t.Fatalf("FetchCurrent() = %+v, %v", w, err)
+	}
+	// a failed call is billed all the same
+	for range 2 {
+		if _, err := failing.FetchCurrent(context.Background(), "Kyiv"); err == nil {
+			t.Fatal("FetchCurrent() of the failing provider succeeded")
+		}
+	}
+	counts, err := usage.Counts(context.Background(), time.Now())
+	if err != nil || counts["weatherapi.com"] != 1 || counts["openweathermap.org"] != 2 {
+		t.Errorf("Counts() = %v, %v; want 1 weatherapi.com and 2 openweathermap.org calls", counts, err)
+	}
+}
+
+func TestBuildCostReport(t *testing.T) {
+	usage := newUsageTracker(t)
+	june := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	for range 3 {
+		usage.Record(context.Background(), "weatherapi.com", june)
+	}
+	usage.Record(context.Background(), "openweathermap.org", june)
+	usage.Record(context.Background(), "weatherapi.com", june.AddDate(0, 1, 0)) // July
+
+	for _, tc := range []struct {
+		name   string
+		prices map[string]float64
+		want   []ProviderCost
+		total  float64
+	}{
+		{"priced providers", map[string]float64{"weatherapi.com": 0.5, "openweathermap.org": 0.25}, []ProviderCost{
+			{Provider: "openweathermap.org", Calls: 1, PricePerCall: 0.25, Cost: 0.25},
+			{Provider: "weatherapi.com", Calls: 3, PricePerCall: 0.5, Cost: 1.5},
+		}, 1.75},
+		{"an unused provider priced", map[string]float64{"weatherapi.com": 0.5, "openweathermap.org": 0.25, "tomorrow.io": 1},
+			[]ProviderCost{
+				{Provider: "openweathermap.org", Calls: 1, PricePerCall: 0.25, Cost: 0.25},
+				{Provider: "tomorrow.io", PricePerCall: 1},
+				{Provider: "weatherapi.com", Calls: 3, PricePerCall: 0.5, Cost: 1.5},
+			}, 1.75},
+		{"a used provider not priced", map[string]float64{"weatherapi.com": 0.5}, []ProviderCost{
+			{Provider: "openweathermap.org", Calls: 1},
+			{Provider: "weatherapi.com", Calls: 3, PricePerCall: 0.5, Cost: 1.5},
+		}, 1.5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report, err := BuildCostReport(context.Background(), usage, tc.prices, june)
+			if err != nil {
+				t.Fatalf("BuildCostReport() error: %v", err)
+			}
+			if report.Month != "2025-06" || !slices.Equal(report.Providers, tc.want) || report.TotalCost != tc.total {
+				t.Errorf("BuildCostReport() = %+v, want %+v costing %v", report, tc.want, tc.total)
+			}
+		})
+	}
+}
+
+func TestParseReportMonth(t *testing.T) {
+	if m, err := ParseReportMonth("2025-06"); err != nil || !m.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseReportMonth(2025-06) = %s, %v", m, err)
+	}
+	if m, err := ParseReportMonth(""); err != nil || m.Format(usageMonthLayout) != time.Now().UTC().Format(usageMonthLayout) {
+		t.Errorf("ParseReportMonth() = %s, %v; want the current month", m, err)
+	}
+	if _, err := ParseReportMonth("June"); err == nil {
+		t.Error("ParseReportMonth(June) succeeded")
+	}
+}
@@ -1,11 +1,12 @@
 package weather
 
 import (
-	"context"
 	"fmt"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/redisclient"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/openweathermap"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/weatherapi"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,20 +15,34 @@ import (
 )
 
 // BuildCachingFetcher constructs a Fetcher that:
-// 1) Builds the two concrete provider clients (OpenWeatherMap & WeatherAPI.com)
-// 2) Wraps them in a concurrent “race to first” fetcher
-// 3) Decorates that with a Redis cache (5 minute TTL)
-// It reads REDIS_PASSWORD, OPENWEATHERMAP_API_KEY and WEATHERAPI_COM_API_KEY from the environment.
-func BuildCachingFetcher(cfg *config.Config, logger *zap.Logger) (Fetcher, error) {
+// 1) Builds the two concrete provider clients (OpenWeatherMap & WeatherAPI.com),
+// each counted by a UsageTracker for the cost report
+// 2) Wraps them in a concurrent “race to first” fetcher, ranked by WEATHER_PROVIDER_PREFERENCE
+// (a less preferred result waits up to WEATHER_PREFERENCE_GRACE for the preferred ones)
+// 3) Decorates that with a Redis cache (5 minute TTL), kept in process memory while
+// memory reports Redis memory pressure
+// It reads OPENWEATHERMAP_API_KEY and WEATHERAPI_COM_API_KEY from the config.
+// When the raw response cache is enabled, providers also store their last raw JSON per city;
+// it is the first thing dropped under memory pressure.
+func BuildCachingFetcher(cfg *config.Config, rdb *redis.Client, memory *redisclient.MemoryMonitor, logger *zap.Logger,
+) (Fetcher, error) {
 	var fetchers []Fetcher
 	var errs []string
+	usage := NewUsageTracker(rdb, logger)
+	var raw *RawStore
+	if cfg.WeatherRawCacheEnabled {
+		raw = NewRawStore(rdb, cfg.WeatherRawCacheTTL, cfg.WeatherRawCacheMaxBytes, logger).WithMemoryMonitor(memory)
+	}
 
 	// OpenWeatherMap client
 	if owm, err := openweathermap.NewClient(cfg); err != nil {
 		logger.Warn("openweathermap client not configured", zap.Error(err))
 		errs = append(errs, fmt.Sprintf("owm: %v", err))
 	} else {
-		fetchers = append(fetchers, owm)
+		if raw != nil {
+			owm.WithRawRecorder(raw)
+		}
+		fetchers = append(fetchers, NewCountingFetcher(openweathermap.ProviderName, owm, usage))
 	}
 
 	// WeatherAPI.com client
@@ -35,7 +50,10 @@ func BuildCachingFetcher(cfg *config.Config, logger *zap.Logger) (Fetcher, error
 		logger.Warn("weatherapi client not configured", zap.Error(err))
 		errs = append(errs, fmt.Sprintf("weatherapi: %v", err))
 	} else {
-		fetchers = append(fetchers, wap)
+		if raw != nil {
+			wap.WithRawRecorder(raw)
+		}
+		fetchers = append(fetchers, NewCountingFetcher(weatherapi.ProviderName, wap, usage))
 	}
 
 	if len(fetchers) == 0 {
@@ -43,17 +61,93 @@ func BuildCachingFetcher(cfg *config.Config, logger *zap.Logger) (Fetcher, error
 	}
 
 	// 2) Race‐to‐first fetcher
-	base := NewMainConcurrentFetcher(logger, fetchers...)
+	rankProviders(fetchers, cfg.WeatherProviderPreference)
+	base := NewMainConcurrentFetcher(logger, fetchers...).WithPreferenceGrace(cfg.WeatherPreferenceGrace)
+
+	// 3) Redis cache decorator
+	namespace := CacheNamespace(cfg)
+	logger.Info("weather cache namespace", zap.String("namespace", namespace))
+	return NewCachingFetcher(base, rdb, 5*time.Minute, namespace, logger).WithMemoryFallback(memory), nil
+}
 
-	// 3) Redis client & cache decorator
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisAddr,
-		Password: cfg.RedisPassword,
-		DB:       0,
-	})
-	if err := rdb.Ping(context.Background()).Err(); err != nil {
-		return nil, fmt.Errorf("redis ping failed: %w", err)
+// BuildTimezoneResolver returns the geocoding timezone lookup used to schedule daily
+// emails in subscriber-local time, counted in the provider usage, or nil when no
+// provider offers one (WeatherAPI.com is the only one that returns IANA names).
+func BuildTimezoneResolver(cfg *config.Config, rdb *redis.Client, logger *zap.Logger) TimezoneResolver {
+	wap, err := weatherapi.NewClient(cfg)
+	if err != nil {
+		logger.Warn("no timezone resolver, subscriptions default to UTC unless a timezone is given", zap.Error(err))
+		return nil
 	}
+	return &countingTimezoneResolver{provider: weatherapi.ProviderName, inner: wap, usage: NewUsageTracker(rdb, logger)}
+}
 
-	return NewCachingFetcher(base, rdb, 5*time.Minute, logger), nil
+// BuildForecastFetcher returns the forecast lookup of daily emails, counted in the
+// provider usage, or nil when no provider offers one (only WeatherAPI.com is used for
+// forecasts).
+func BuildForecastFetcher(cfg *config.Config, rdb *redis.Client, logger *zap.Logger) ForecastFetcher {
+	wap, err := weatherapi.NewClient(cfg)
+	if err != nil {
+		logger.Warn("no forecast provider, daily emails go out without tomorrow's forecast", zap.Error(err))
+		return nil
+	}
+	return &countingForecastFetcher{provider: weatherapi.ProviderName, inner: wap, usage: NewUsageTracker(rdb, logger)}
+}
+
+// BuildWarningsFetcher returns the government warnings feed of warning subscriptions,
+// counted in the provider usage, or nil when no provider offers one (only WeatherAPI.com
+// is used for warnings).
+func BuildWarningsFetcher(cfg *config.Config, rdb *redis.Client, logger *zap.Logger) WarningsFetcher {
+	wap, err := weatherapi.NewClient(cfg)
+	if err != nil {
+		logger.Warn("no warnings provider, warning subscriptions get no emails", zap.Error(err))
+		return nil
+	}
+	return &countingWarningsFetcher{provider: weatherapi.ProviderName, inner: wap, usage: NewUsageTracker(rdb, logger)}
+}
+
+// ProviderPrices maps provider names to their configured per-call price.
+func ProviderPrices(cfg *config.Config) map[string]float64 {
+	return map[string]float64{
+		weatherapi.ProviderName:     cfg.WeatherAPIComPricePerCall,
+		openweathermap.ProviderName: cfg.OpenWeatherMapOrgPricePerCall,
+	}
+}
+
+// ProviderAttributions maps provider names to the attribution line their terms require.
+func ProviderAttributions(cfg *config.Config) Attributions {
+	return Attributions{
+		weatherapi.ProviderName:     cfg.WeatherAPIComAttribution,
+		openweathermap.ProviderName: cfg.OpenWeatherMapOrgAttribution,
+	}
+}
+
+// Attributions maps provider names to attribution lines. A nil map attributes nothing.
+type Attributions map[string]string
+
+// For returns the attribution lines of providers, without duplicates and in order of
+// first appearance. Unknown providers, such as cache entries written before providers
+// were recorded, are skipped.
+func (a Attributions) For(providers ...string) []string {
+	var lines []string
+	for _, p := range providers {
+		if line := a[p]; line != "" && !slices.Contains(lines, line) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// rankProviders orders fetchers by the position of their provider in preference;
+// unlisted providers keep their relative order after the listed ones.
+func rankProviders(fetchers []Fetcher, preference []string) {
+	rank := func(f Fetcher) int {
+		if p, ok := f.(interface{ Provider() string }); ok {
+			if i := slices.Index(preference, p.Provider()); i >= 0 {
+				return i
+			}
+		}
+		return len(preference)
+	}
+	slices.SortStableFunc(fetchers, func(a, b Fetcher) int { return rank(a) - rank(b) })
 }
@@ -2,16 +2,29 @@ package weatherapi
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/config"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/tracing"
+	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/astro"
 	"github.com/namefreezers/Software-Engineering-School-5.0-weather-api/internal/weather/types"
+	"io"
 	"net/http"
+	"time"
 )
 
+// ProviderName identifies WeatherAPI.com in logs and usage reports.
+const ProviderName = "weatherapi.com"
+
+// maxResponseSize caps how much of a response body is read.
+const maxResponseSize = 1 << 20
+
 // Client queries the WeatherAPI.com current.json endpoint.
 type Client struct {
 	apiKey string
+	raw    types.RawRecorder
 }
 
 // NewClient returns a new Client, or an error if the API key is not set.
@@ -23,6 +36,12 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	return &Client{apiKey: key}, nil
 }
 
+// WithRawRecorder makes the client hand every response body to r before parsing it.
+func (c *Client) WithRawRecorder(r types.RawRecorder) *Client {
+	c.raw = r
+	return c
+}
+
 // FetchCurrent implements weather.Fetcher.
 // It returns temperature (°C), humidity (%), and a brief description.
 func (c *Client) FetchCurrent(ctx context.Context, city string) (types.Weather, error) {
@@ -35,36 +54,232 @@ func (c *Client) FetchCurrent(ctx context.Context, city string) (types.Weather,
 	if err != nil {
 		return types.Weather{}, fmt.Errorf("weatherapi: failed to build request: %w", err)
 	}
+	trace := tracing.FromContextOrNew(ctx)
+	trace.Inject(req.Header)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return types.Weather{}, fmt.Errorf("weatherapi: HTTP request failed: %w", err)
+		return types.Weather{}, fmt.Errorf("weatherapi: HTTP request failed (%s): %w", trace.Describe(nil), err)
 	}
 	defer resp.Body.Close()
 
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return types.Weather{}, fmt.Errorf("weatherapi: failed to read response (%s): %w", trace.Describe(resp), err)
+	}
+	if c.raw != nil {
+		c.raw.RecordRaw(ctx, ProviderName, city, resp.StatusCode, raw)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return types.Weather{}, fmt.Errorf(
-			"weatherapi: unexpected status %d %s",
-			resp.StatusCode, http.StatusText(resp.StatusCode),
+			"weatherapi: unexpected status %d %s (%s)",
+			resp.StatusCode, http.StatusText(resp.StatusCode), trace.Describe(resp),
 		)
 	}
 
 	var body struct {
+		Location struct {
+			Lat float64 `json:"lat"`
+			Lon float64 `json:"lon"`
+		} `json:"location"`
 		Current struct {
 			TempC     float64 `json:"temp_c"`
 			Humidity  int     `json:"humidity"`
+			WindKph   float64 `json:"wind_kph"`
 			Condition struct {
 				Text string `json:"text"`
 			} `json:"condition"`
 		} `json:"current"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-		return types.Weather{}, fmt.Errorf("weatherapi: JSON decode error: %w", err)
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return types.Weather{}, fmt.Errorf("weatherapi: JSON decode error (%s): %w", trace.Describe(resp), err)
 	}
 
-	return types.Weather{
+	w := types.Weather{
 		Temp:        body.Current.TempC,
 		Humidity:    body.Current.Humidity,
 		Description: body.Current.Condition.Text,
+		WindKph:     body.Current.WindKph,
+		Provider:    ProviderName,
+	}
+	// current.json has no sun times, so compute the sunrise from the matched location
+	if sunrise, ok := astro.Sunrise(body.Location.Lat, body.Location.Lon, time.Now()); ok {
+		w.Sunrise = sunrise
+	}
+	return w, nil
+}
+
+// ResolveTimezone implements weather.TimezoneResolver with the timezone.json endpoint,
+// which geocodes city and returns the IANA timezone of the match.
+func (c *Client) ResolveTimezone(ctx context.Context, city string) (string, error) {
+	url := fmt.Sprintf(
+		"http://api.weatherapi.com/v1/timezone.json?key=%s&q=%s",
+		c.apiKey, city,
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("weatherapi: failed to build request: %w", err)
+	}
+	trace := tracing.FromContextOrNew(ctx)
+	trace.Inject(req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("weatherapi: HTTP request failed (%s): %w", trace.Describe(nil), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(
+			"weatherapi: unexpected status %d %s (%s)",
+			resp.StatusCode, http.StatusText(resp.StatusCode), trace.Describe(resp),
+		)
+	}
+
+	var body struct {
+		Location struct {
+			TzID string `json:"tz_id"`
+		} `json:"location"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("weatherapi: JSON decode error (%s): %w", trace.Describe(resp), err)
+	}
+	if body.Location.TzID == "" {
+		return "", fmt.Errorf("weatherapi: no timezone for %q", city)
+	}
+	return body.Location.TzID, nil
+}
+
+// FetchTomorrow implements weather.ForecastFetcher with the forecast.json endpoint. Its
+// days start at the city's local today, so tomorrow is the second one.
+func (c *Client) FetchTomorrow(ctx context.Context, city string) (types.Forecast, error) {
+	url := fmt.Sprintf(
+		"http://api.weatherapi.com/v1/forecast.json?key=%s&q=%s&days=2&aqi=no&alerts=no",
+		c.apiKey, city,
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return types.Forecast{}, fmt.Errorf("weatherapi: failed to build request: %w", err)
+	}
+	trace := tracing.FromContextOrNew(ctx)
+	trace.Inject(req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.Forecast{}, fmt.Errorf("weatherapi: HTTP request failed (%s): %w", trace.Describe(nil), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.Forecast{}, fmt.Errorf(
+			"weatherapi: unexpected status %d %s (%s)",
+			resp.StatusCode, http.StatusText(resp.StatusCode), trace.Describe(resp),
+		)
+	}
+
+	var body struct {
+		Forecast struct {
+			ForecastDay []struct {
+				Date string `json:"date"`
+				Day  struct {
+					MinTempC          float64 `json:"mintemp_c"`
+					MaxTempC          float64 `json:"maxtemp_c"`
+					DailyChanceOfRain int     `json:"daily_chance_of_rain"`
+					DailyChanceOfSnow int     `json:"daily_chance_of_snow"`
+					MaxWindKph        float64 `json:"maxwind_kph"`
+					Condition         struct {
+						Text string `json:"text"`
+					} `json:"condition"`
+				} `json:"day"`
+			} `json:"forecastday"`
+		} `json:"forecast"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return types.Forecast{}, fmt.Errorf("weatherapi: JSON decode error (%s): %w", trace.Describe(resp), err)
+	}
+	days := body.Forecast.ForecastDay
+	if len(days) < 2 {
+		return types.Forecast{}, fmt.Errorf("weatherapi: no forecast for tomorrow in %q", city)
+	}
+	d := days[1]
+	return types.Forecast{
+		Date:         d.Date,
+		MinTemp:      d.Day.MinTempC,
+		MaxTemp:      d.Day.MaxTempC,
+		ChanceOfRain: max(d.Day.DailyChanceOfRain, d.Day.DailyChanceOfSnow),
+		MaxWindKph:   d.Day.MaxWindKph,
+		Description:  d.Day.Condition.Text,
+		Provider:     ProviderName,
 	}, nil
 }
+
+// FetchWarnings implements weather.WarningsFetcher with the alerts.json endpoint, which
+// relays the warnings of government agencies (e.g. the US NWS, Meteoalarm in Europe).
+// The feed has no warning IDs, so one is derived from the warning's identity.
+func (c *Client) FetchWarnings(ctx context.Context, city string) ([]types.Warning, error) {
+	url := fmt.Sprintf(
+		"http://api.weatherapi.com/v1/alerts.json?key=%s&q=%s",
+		c.apiKey, city,
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("weatherapi: failed to build request: %w", err)
+	}
+	trace := tracing.FromContextOrNew(ctx)
+	trace.Inject(req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("weatherapi: HTTP request failed (%s): %w", trace.Describe(nil), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"weatherapi: unexpected status %d %s (%s)",
+			resp.StatusCode, http.StatusText(resp.StatusCode), trace.Describe(resp),
+		)
+	}
+
+	var body struct {
+		Alerts struct {
+			Alert []struct {
+				Headline    string `json:"headline"`
+				Severity    string `json:"severity"`
+				Areas       string `json:"areas"`
+				Event       string `json:"event"`
+				Effective   string `json:"effective"`
+				Expires     string `json:"expires"`
+				Desc        string `json:"desc"`
+				Instruction string `json:"instruction"`
+			} `json:"alert"`
+		} `json:"alerts"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("weatherapi: JSON decode error (%s): %w", trace.Describe(resp), err)
+	}
+
+	out := make([]types.Warning, 0, len(body.Alerts.Alert))
+	for _, a := range body.Alerts.Alert {
+		id := sha256.Sum256([]byte(a.Event + "\x00" + a.Effective + "\x00" + a.Areas + "\x00" + a.Headline))
+		w := types.Warning{
+			ID:          hex.EncodeToString(id[:16]),
+			Event:       a.Event,
+			Headline:    a.Headline,
+			Severity:    a.Severity,
+			Areas:       a.Areas,
+			Description: a.Desc,
+			Instruction: a.Instruction,
+			Provider:    ProviderName,
+		}
+		// unparsable times are left zero: the warning is still worth sending
+		w.Effective, _ = time.Parse(time.RFC3339, a.Effective)
+		w.Expires, _ = time.Parse(time.RFC3339, a.Expires)
+		out = append(out, w)
+	}
+	return out, nil
+}